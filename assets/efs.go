@@ -4,5 +4,5 @@ import (
 	"embed"
 )
 
-//go:embed "emails" "templates" "static" "build_egg.py" "migrations"
+//go:embed "emails" "templates" "static" "build_egg.py" "migrations" "openapi"
 var EmbeddedFiles embed.FS
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS task_webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id UUID NOT NULL UNIQUE,
+    secret BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by UUID,
+    last_fired_at DATETIME,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE TABLE IF NOT EXISTS webhook_nonces (
+    webhook_id INTEGER NOT NULL,
+    nonce TEXT NOT NULL,
+    seen_at DATETIME NOT NULL,
+    PRIMARY KEY (webhook_id, nonce),
+    FOREIGN KEY (webhook_id) REFERENCES task_webhooks(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_nonces_seen_at ON webhook_nonces(seen_at);
+ALTER TABLE jobs ADD COLUMN triggered_by TEXT;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN triggered_by;
+DROP INDEX IF EXISTS idx_webhook_nonces_seen_at;
+DROP TABLE IF EXISTS webhook_nonces;
+DROP TABLE IF EXISTS task_webhooks;
@@ -0,0 +1,46 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS roles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role TEXT NOT NULL,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission),
+    FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE
+);
+INSERT INTO roles (name, description) VALUES
+    ('viewer', 'Can look at nodes, tasks, jobs and logs'),
+    ('operator', 'Viewer, and can run and cancel spiders and manage tasks'),
+    ('deployer', 'Operator, and can deploy and delete project versions'),
+    ('admin', 'Full access, including nodes, users, settings and metrics');
+INSERT INTO role_permissions (role, permission) VALUES
+    ('viewer', 'jobs:view'),
+    ('operator', 'jobs:view'),
+    ('operator', 'jobs:run'),
+    ('operator', 'tasks:manage'),
+    ('deployer', 'jobs:view'),
+    ('deployer', 'jobs:run'),
+    ('deployer', 'tasks:manage'),
+    ('deployer', 'projects:deploy'),
+    ('admin', 'jobs:view'),
+    ('admin', 'jobs:run'),
+    ('admin', 'tasks:manage'),
+    ('admin', 'projects:deploy'),
+    ('admin', 'nodes:manage'),
+    ('admin', 'users:manage'),
+    ('admin', 'settings:manage'),
+    ('admin', 'metrics:view');
+-- SQLite can't add a REFERENCES column with a non-NULL default, the role is validated against the roles table instead.
+-- Admins keep full access, everybody else could deploy before roles existed so they become deployers.
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'viewer';
+UPDATE users SET role = CASE WHEN has_admin_privileges THEN 'admin' ELSE 'deployer' END;
+ALTER TABLE users DROP COLUMN has_admin_privileges;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN has_admin_privileges BOOL NOT NULL DEFAULT FALSE;
+UPDATE users SET has_admin_privileges = (role = 'admin');
+ALTER TABLE users DROP COLUMN role;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
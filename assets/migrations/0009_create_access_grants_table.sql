@@ -0,0 +1,21 @@
+-- +goose Up
+-- A grant limits a user, or everybody with a role, to a project and/or node. An empty project or node matches any.
+-- Users without grants aren't scoped.
+CREATE TABLE IF NOT EXISTS access_grants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id UUID,
+    role TEXT,
+    project TEXT NOT NULL DEFAULT '',
+    node TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((user_id IS NULL) <> (role IS NULL)),
+    FOREIGN KEY (user_id) REFERENCES users(ID) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_access_grants_user ON access_grants(user_id);
+CREATE INDEX IF NOT EXISTS idx_access_grants_role ON access_grants(role);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_access_grants_role;
+DROP INDEX IF EXISTS idx_access_grants_user;
+DROP TABLE IF EXISTS access_grants;
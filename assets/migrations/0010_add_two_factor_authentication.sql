@@ -0,0 +1,22 @@
+-- +goose Up
+-- totp_secret is encrypted with the same key as the node passwords. It's set when enrollment starts and only used for
+-- logins once totp_enabled is set, after the user confirmed a code. totp_required is set by an admin.
+ALTER TABLE users ADD COLUMN totp_secret BLOB;
+ALTER TABLE users ADD COLUMN totp_enabled BOOL NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN totp_required BOOL NOT NULL DEFAULT FALSE;
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id UUID NOT NULL,
+    code_hash BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(ID) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_recovery_codes_user;
+DROP TABLE IF EXISTS recovery_codes;
+ALTER TABLE users DROP COLUMN totp_required;
+ALTER TABLE users DROP COLUMN totp_enabled;
+ALTER TABLE users DROP COLUMN totp_secret;
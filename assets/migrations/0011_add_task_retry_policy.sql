@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE tasks ADD COLUMN retry_max_attempts INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE tasks ADD COLUMN retry_backoff_seconds INTEGER NOT NULL DEFAULT 30;
+ALTER TABLE tasks ADD COLUMN retry_max_backoff_seconds INTEGER NOT NULL DEFAULT 600;
+ALTER TABLE tasks ADD COLUMN retry_on TEXT NOT NULL DEFAULT 'network,http';
+ALTER TABLE jobs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE jobs ADD COLUMN next_retry_at DATETIME;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN next_retry_at;
+ALTER TABLE jobs DROP COLUMN attempts;
+ALTER TABLE tasks DROP COLUMN retry_on;
+ALTER TABLE tasks DROP COLUMN retry_max_backoff_seconds;
+ALTER TABLE tasks DROP COLUMN retry_backoff_seconds;
+ALTER TABLE tasks DROP COLUMN retry_max_attempts;
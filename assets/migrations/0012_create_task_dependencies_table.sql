@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id UUID NOT NULL,
+    upstream_task_id UUID NOT NULL,
+    min_items INTEGER NOT NULL DEFAULT 0,
+    -- Only upstream jobs with a higher ID count towards the next run, it moves forward every time the task fires
+    after_job_id INTEGER NOT NULL DEFAULT 0,
+    upstream_job_id INTEGER,
+    satisfied BOOL NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by UUID,
+    UNIQUE (task_id, upstream_task_id),
+    CHECK (task_id != upstream_task_id),
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (upstream_task_id) REFERENCES tasks(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_task_dependencies_upstream_task_id ON task_dependencies(upstream_task_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_task_dependencies_upstream_task_id;
+DROP TABLE IF EXISTS task_dependencies;
@@ -0,0 +1,83 @@
+-- +goose Up
+ALTER TABLE tasks ADD COLUMN overlap_policy TEXT NOT NULL DEFAULT 'allow';
+-- SQLite can't change a CHECK constraint in place, the jobs table is rebuilt to allow the queued and skipped statuses
+CREATE TABLE jobs_rebuilt (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project TEXT NOT NULL,
+    spider TEXT NOT NULL,
+    job TEXT NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('scheduled', 'queued', 'skipped', 'pending', 'running', 'finished', 'error')),
+    deleted BOOL NOT NULL DEFAULT false,
+    create_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pages INTEGER,
+    items INTEGER,
+    pid INTEGER,
+    start DATETIME,
+    runtime TEXT,
+    finish DATETIME,
+    href_log TEXT,
+    href_items TEXT,
+    node TEXT NOT NULL,
+    task_id UUID,
+    error TEXT,
+    started_by UUID,
+    stopped_by UUID,
+    triggered_by TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    next_retry_at DATETIME,
+    CONSTRAINT uniqueRow UNIQUE (project, spider, job),
+    FOREIGN KEY (started_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (stopped_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (node) REFERENCES scrapyd_nodes(nodeName) ON DELETE CASCADE ON UPDATE CASCADE
+);
+INSERT INTO jobs_rebuilt SELECT * FROM jobs;
+DROP INDEX IF EXISTS idx_job;
+DROP INDEX IF EXISTS idx_spider;
+DROP TABLE jobs;
+ALTER TABLE jobs_rebuilt RENAME TO jobs;
+CREATE INDEX IF NOT EXISTS idx_job ON jobs(job);
+CREATE INDEX IF NOT EXISTS idx_spider ON jobs(spider);
+
+-- +goose Down
+UPDATE jobs SET status = 'error' WHERE status IN ('queued', 'skipped');
+CREATE TABLE jobs_rebuilt (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project TEXT NOT NULL,
+    spider TEXT NOT NULL,
+    job TEXT NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('scheduled', 'pending', 'running', 'finished', 'error')),
+    deleted BOOL NOT NULL DEFAULT false,
+    create_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pages INTEGER,
+    items INTEGER,
+    pid INTEGER,
+    start DATETIME,
+    runtime TEXT,
+    finish DATETIME,
+    href_log TEXT,
+    href_items TEXT,
+    node TEXT NOT NULL,
+    task_id UUID,
+    error TEXT,
+    started_by UUID,
+    stopped_by UUID,
+    triggered_by TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    next_retry_at DATETIME,
+    CONSTRAINT uniqueRow UNIQUE (project, spider, job),
+    FOREIGN KEY (started_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (stopped_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (node) REFERENCES scrapyd_nodes(nodeName) ON DELETE CASCADE ON UPDATE CASCADE
+);
+INSERT INTO jobs_rebuilt SELECT * FROM jobs;
+DROP INDEX IF EXISTS idx_job;
+DROP INDEX IF EXISTS idx_spider;
+DROP TABLE jobs;
+ALTER TABLE jobs_rebuilt RENAME TO jobs;
+CREATE INDEX IF NOT EXISTS idx_job ON jobs(job);
+CREATE INDEX IF NOT EXISTS idx_spider ON jobs(spider);
+ALTER TABLE tasks DROP COLUMN overlap_policy;
@@ -0,0 +1,141 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS node_groups (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by UUID,
+    FOREIGN KEY (created_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE TABLE IF NOT EXISTS node_group_members (
+    group_id INTEGER NOT NULL,
+    node TEXT NOT NULL,
+    PRIMARY KEY (group_id, node),
+    FOREIGN KEY (group_id) REFERENCES node_groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (node) REFERENCES scrapyd_nodes(nodeName) ON DELETE CASCADE ON UPDATE CASCADE
+);
+-- tasks.selected_nodes referenced a single node, the table is rebuilt without it. Dropping the old table runs the
+-- ON DELETE actions of the tables referencing tasks, so their rows are copied aside first and put back afterwards.
+CREATE TABLE tasks_rebuild_jobs (id INTEGER PRIMARY KEY, task_id UUID NOT NULL);
+INSERT INTO tasks_rebuild_jobs (id, task_id) SELECT id, task_id FROM jobs WHERE task_id IS NOT NULL;
+CREATE TABLE tasks_rebuild_webhooks AS SELECT * FROM task_webhooks;
+CREATE TABLE tasks_rebuild_webhook_nonces AS SELECT * FROM webhook_nonces;
+CREATE TABLE tasks_rebuild_dependencies AS SELECT * FROM task_dependencies;
+CREATE TABLE tasks_single_node AS SELECT id, selected_nodes FROM tasks;
+CREATE TABLE tasks_rebuilt (
+    id UUID PRIMARY KEY UNIQUE,
+    name TEXT,
+    create_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    project TEXT NOT NULL,
+    spider TEXT NOT NULL,
+    jobid TEXT NOT NULL,
+    settings_arguments TEXT NOT NULL,
+    cron_string TEXT NOT NULL,
+    paused BOOL NOT NULL,
+    created_by UUID,
+    modified_by UUID,
+    retry_max_attempts INTEGER NOT NULL DEFAULT 1,
+    retry_backoff_seconds INTEGER NOT NULL DEFAULT 30,
+    retry_max_backoff_seconds INTEGER NOT NULL DEFAULT 600,
+    retry_on TEXT NOT NULL DEFAULT 'network,http',
+    overlap_policy TEXT NOT NULL DEFAULT 'allow',
+    fan_out TEXT NOT NULL DEFAULT 'all',
+    round_robin_next INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (created_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (modified_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE
+);
+INSERT INTO tasks_rebuilt (id, name, create_time, update_time, project, spider, jobid, settings_arguments, cron_string, paused,
+    created_by, modified_by, retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy)
+SELECT id, name, create_time, update_time, project, spider, jobid, settings_arguments, cron_string, paused,
+    created_by, modified_by, retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy
+FROM tasks;
+DROP TABLE tasks;
+ALTER TABLE tasks_rebuilt RENAME TO tasks;
+CREATE INDEX IF NOT EXISTS idx_task_name ON tasks(name);
+CREATE INDEX IF NOT EXISTS idx_task_spider ON tasks(spider);
+-- A target is either a node or a node group, groups are resolved to their members on every fire
+CREATE TABLE IF NOT EXISTS task_targets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id UUID NOT NULL,
+    node TEXT,
+    group_id INTEGER,
+    UNIQUE (task_id, node),
+    UNIQUE (task_id, group_id),
+    CHECK ((node IS NULL) <> (group_id IS NULL)),
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (node) REFERENCES scrapyd_nodes(nodeName) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (group_id) REFERENCES node_groups(id) ON DELETE CASCADE
+);
+-- Every existing task keeps running on the node it was created for
+INSERT INTO task_targets (task_id, node) SELECT id, selected_nodes FROM tasks_single_node;
+DROP TABLE tasks_single_node;
+UPDATE jobs SET task_id = (SELECT b.task_id FROM tasks_rebuild_jobs b WHERE b.id = jobs.id)
+WHERE id IN (SELECT id FROM tasks_rebuild_jobs);
+INSERT OR IGNORE INTO task_webhooks SELECT * FROM tasks_rebuild_webhooks;
+INSERT OR IGNORE INTO webhook_nonces SELECT * FROM tasks_rebuild_webhook_nonces;
+INSERT OR IGNORE INTO task_dependencies SELECT * FROM tasks_rebuild_dependencies;
+DROP TABLE tasks_rebuild_jobs;
+DROP TABLE tasks_rebuild_webhooks;
+DROP TABLE tasks_rebuild_webhook_nonces;
+DROP TABLE tasks_rebuild_dependencies;
+
+-- +goose Down
+-- Tasks without any node can't go back to a single node, they are deleted up front so the tables referencing them are
+-- updated
+DELETE FROM tasks WHERE NOT EXISTS (
+    SELECT 1 FROM task_targets tt LEFT JOIN node_group_members m ON m.group_id = tt.group_id
+    WHERE tt.task_id = tasks.id AND COALESCE(tt.node, m.node) IS NOT NULL
+);
+CREATE TABLE tasks_rebuild_jobs (id INTEGER PRIMARY KEY, task_id UUID NOT NULL);
+INSERT INTO tasks_rebuild_jobs (id, task_id) SELECT id, task_id FROM jobs WHERE task_id IS NOT NULL;
+CREATE TABLE tasks_rebuild_webhooks AS SELECT * FROM task_webhooks;
+CREATE TABLE tasks_rebuild_webhook_nonces AS SELECT * FROM webhook_nonces;
+CREATE TABLE tasks_rebuild_dependencies AS SELECT * FROM task_dependencies;
+CREATE TABLE tasks_rebuilt (
+    id UUID PRIMARY KEY UNIQUE,
+    name TEXT,
+    create_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    project TEXT NOT NULL,
+    spider TEXT NOT NULL,
+    jobid TEXT NOT NULL,
+    settings_arguments TEXT NOT NULL,
+    selected_nodes TEXT NOT NULL,
+    cron_string TEXT NOT NULL,
+    paused BOOL NOT NULL,
+    created_by UUID,
+    modified_by UUID,
+    retry_max_attempts INTEGER NOT NULL DEFAULT 1,
+    retry_backoff_seconds INTEGER NOT NULL DEFAULT 30,
+    retry_max_backoff_seconds INTEGER NOT NULL DEFAULT 600,
+    retry_on TEXT NOT NULL DEFAULT 'network,http',
+    overlap_policy TEXT NOT NULL DEFAULT 'allow',
+    FOREIGN KEY (created_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (modified_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (selected_nodes) REFERENCES scrapyd_nodes(nodeName) ON DELETE CASCADE ON UPDATE CASCADE
+);
+-- Only the first node a task resolves to is kept
+INSERT INTO tasks_rebuilt (id, name, create_time, update_time, project, spider, jobid, settings_arguments, cron_string, paused,
+    created_by, modified_by, retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy, selected_nodes)
+SELECT id, name, create_time, update_time, project, spider, jobid, settings_arguments, cron_string, paused,
+    created_by, modified_by, retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy,
+    (SELECT MIN(COALESCE(tt.node, m.node)) FROM task_targets tt
+        LEFT JOIN node_group_members m ON m.group_id = tt.group_id
+     WHERE tt.task_id = tasks.id)
+FROM tasks;
+DROP TABLE task_targets;
+DROP TABLE tasks;
+ALTER TABLE tasks_rebuilt RENAME TO tasks;
+CREATE INDEX IF NOT EXISTS idx_task_name ON tasks(name);
+CREATE INDEX IF NOT EXISTS idx_task_spider ON tasks(spider);
+UPDATE jobs SET task_id = (SELECT b.task_id FROM tasks_rebuild_jobs b WHERE b.id = jobs.id)
+WHERE id IN (SELECT id FROM tasks_rebuild_jobs);
+INSERT OR IGNORE INTO task_webhooks SELECT * FROM tasks_rebuild_webhooks;
+INSERT OR IGNORE INTO webhook_nonces SELECT * FROM tasks_rebuild_webhook_nonces;
+INSERT OR IGNORE INTO task_dependencies SELECT * FROM tasks_rebuild_dependencies;
+DROP TABLE tasks_rebuild_jobs;
+DROP TABLE tasks_rebuild_webhooks;
+DROP TABLE tasks_rebuild_webhook_nonces;
+DROP TABLE tasks_rebuild_dependencies;
+DROP TABLE IF EXISTS node_group_members;
+DROP TABLE IF EXISTS node_groups;
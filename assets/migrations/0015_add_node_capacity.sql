@@ -0,0 +1,7 @@
+-- +goose Up
+-- capacity is how many jobs a node runs comfortably, the least-loaded fan-out mode weighs the load of a node by it.
+-- Nodes without a capacity count as a capacity of 1.
+ALTER TABLE scrapyd_nodes ADD COLUMN capacity INTEGER CHECK (capacity IS NULL OR capacity > 0);
+
+-- +goose Down
+ALTER TABLE scrapyd_nodes DROP COLUMN capacity;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- The misfire policy decides what happens on startup with the fires a task missed while goscrapyd was down. Tasks
+-- default to running once so a daily task isn't lost to a restart at the wrong moment.
+ALTER TABLE tasks ADD COLUMN misfire_policy TEXT NOT NULL DEFAULT 'run_once';
+ALTER TABLE tasks ADD COLUMN misfire_max_runs INTEGER NOT NULL DEFAULT 1;
+-- last_fired_at is the last scheduled fire, or when the task was last registered with the scheduler
+ALTER TABLE tasks ADD COLUMN last_fired_at DATETIME;
+UPDATE tasks SET last_fired_at = (SELECT MAX(create_time) FROM jobs WHERE jobs.task_id = tasks.id);
+
+-- +goose Down
+ALTER TABLE tasks DROP COLUMN last_fired_at;
+ALTER TABLE tasks DROP COLUMN misfire_max_runs;
+ALTER TABLE tasks DROP COLUMN misfire_policy;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- The IANA time zone the schedule and blackout calendars of a task are evaluated in, empty is the -timezone of the process
+ALTER TABLE tasks ADD COLUMN timezone TEXT NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS blackout_calendars (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by UUID,
+    FOREIGN KEY (created_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE
+);
+-- A window covers a fire when every condition it sets matches, empty conditions match anything. Dates are YYYY-MM-DD and
+-- inclusive, weekdays a comma separated list such as sat,sun and times HH:MM, an end time before the start time wraps
+-- past midnight.
+CREATE TABLE IF NOT EXISTS blackout_windows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    calendar_id INTEGER NOT NULL,
+    start_date TEXT NOT NULL DEFAULT '',
+    end_date TEXT NOT NULL DEFAULT '',
+    weekdays TEXT NOT NULL DEFAULT '',
+    start_time TEXT NOT NULL DEFAULT '',
+    end_time TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (calendar_id) REFERENCES blackout_calendars(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS task_blackout_calendars (
+    task_id UUID NOT NULL,
+    calendar_id INTEGER NOT NULL,
+    PRIMARY KEY (task_id, calendar_id),
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (calendar_id) REFERENCES blackout_calendars(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS task_blackout_calendars;
+DROP TABLE IF EXISTS blackout_windows;
+DROP TABLE IF EXISTS blackout_calendars;
+ALTER TABLE tasks DROP COLUMN timezone;
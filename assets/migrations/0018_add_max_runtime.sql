@@ -0,0 +1,99 @@
+-- +goose Up
+-- max_runtime_seconds caps how long a job of the task may run, tasks without one fall back to the limit of their project
+ALTER TABLE tasks ADD COLUMN max_runtime_seconds INTEGER CHECK (max_runtime_seconds IS NULL OR max_runtime_seconds > 0);
+CREATE TABLE IF NOT EXISTS project_max_runtimes (
+    project TEXT PRIMARY KEY,
+    max_runtime_seconds INTEGER NOT NULL CHECK (max_runtime_seconds > 0),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by UUID,
+    FOREIGN KEY (created_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE
+);
+-- timed_out_at is when the job was first cancelled for running too long, killed_at when the cancel was escalated
+ALTER TABLE jobs ADD COLUMN timed_out_at DATETIME;
+ALTER TABLE jobs ADD COLUMN killed_at DATETIME;
+-- SQLite can't change a CHECK constraint in place, the jobs table is rebuilt to allow the timed_out status
+CREATE TABLE jobs_rebuilt (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project TEXT NOT NULL,
+    spider TEXT NOT NULL,
+    job TEXT NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('scheduled', 'queued', 'skipped', 'pending', 'running', 'finished', 'timed_out', 'error')),
+    deleted BOOL NOT NULL DEFAULT false,
+    create_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pages INTEGER,
+    items INTEGER,
+    pid INTEGER,
+    start DATETIME,
+    runtime TEXT,
+    finish DATETIME,
+    href_log TEXT,
+    href_items TEXT,
+    node TEXT NOT NULL,
+    task_id UUID,
+    error TEXT,
+    started_by UUID,
+    stopped_by UUID,
+    triggered_by TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    next_retry_at DATETIME,
+    timed_out_at DATETIME,
+    killed_at DATETIME,
+    CONSTRAINT uniqueRow UNIQUE (project, spider, job),
+    FOREIGN KEY (started_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (stopped_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (node) REFERENCES scrapyd_nodes(nodeName) ON DELETE CASCADE ON UPDATE CASCADE
+);
+INSERT INTO jobs_rebuilt SELECT * FROM jobs;
+DROP INDEX IF EXISTS idx_job;
+DROP INDEX IF EXISTS idx_spider;
+DROP TABLE jobs;
+ALTER TABLE jobs_rebuilt RENAME TO jobs;
+CREATE INDEX IF NOT EXISTS idx_job ON jobs(job);
+CREATE INDEX IF NOT EXISTS idx_spider ON jobs(spider);
+
+-- +goose Down
+UPDATE jobs SET status = 'finished' WHERE status = 'timed_out';
+CREATE TABLE jobs_rebuilt (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project TEXT NOT NULL,
+    spider TEXT NOT NULL,
+    job TEXT NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('scheduled', 'queued', 'skipped', 'pending', 'running', 'finished', 'error')),
+    deleted BOOL NOT NULL DEFAULT false,
+    create_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    update_time DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    pages INTEGER,
+    items INTEGER,
+    pid INTEGER,
+    start DATETIME,
+    runtime TEXT,
+    finish DATETIME,
+    href_log TEXT,
+    href_items TEXT,
+    node TEXT NOT NULL,
+    task_id UUID,
+    error TEXT,
+    started_by UUID,
+    stopped_by UUID,
+    triggered_by TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    next_retry_at DATETIME,
+    CONSTRAINT uniqueRow UNIQUE (project, spider, job),
+    FOREIGN KEY (started_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (stopped_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (node) REFERENCES scrapyd_nodes(nodeName) ON DELETE CASCADE ON UPDATE CASCADE
+);
+INSERT INTO jobs_rebuilt SELECT id, project, spider, job, status, deleted, create_time, update_time, pages, items, pid,
+    start, runtime, finish, href_log, href_items, node, task_id, error, started_by, stopped_by, triggered_by, attempts,
+    next_retry_at FROM jobs;
+DROP INDEX IF EXISTS idx_job;
+DROP INDEX IF EXISTS idx_spider;
+DROP TABLE jobs;
+ALTER TABLE jobs_rebuilt RENAME TO jobs;
+CREATE INDEX IF NOT EXISTS idx_job ON jobs(job);
+CREATE INDEX IF NOT EXISTS idx_spider ON jobs(spider);
+DROP TABLE IF EXISTS project_max_runtimes;
+ALTER TABLE tasks DROP COLUMN max_runtime_seconds;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The spider arguments and settings a job was scheduled with, after the templates in them were evaluated. Encoded the same
+-- way as tasks.settings_arguments.
+ALTER TABLE jobs ADD COLUMN spider_args TEXT;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN spider_args;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- With -ha several goscrapyd instances share the database, the instance holding the scheduler lease is the leader and the
+-- only one which fires tasks on their schedule. The leader renews the lease while it's alive, once expires_at passed
+-- another instance takes it over.
+CREATE TABLE IF NOT EXISTS scheduler_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    acquired_at DATETIME NOT NULL,
+    renewed_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS scheduler_leases;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Every execution of a task by the scheduler, one row per node a fire ran on. A fire which failed before it had a node,
+-- e.g. because none of its targets resolved, is recorded without one. The jobs table only knows about fires which got as
+-- far as a job.
+CREATE TABLE IF NOT EXISTS task_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id UUID NOT NULL,
+    trigger_source TEXT NOT NULL,
+    scheduled_at DATETIME,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    node TEXT NOT NULL DEFAULT '',
+    job_id TEXT NOT NULL DEFAULT '',
+    outcome TEXT NOT NULL,
+    error TEXT,
+    schedule_latency_ms INTEGER,
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_task_runs_task ON task_runs(task_id, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_task_runs_task;
+DROP TABLE IF EXISTS task_runs;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- A scheduled run fires a spider once at run_at, e.g. a backfill on Saturday night. It's fired on every one of its nodes
+-- and kept afterwards with how the fire went, status is pending until then and fired or failed after.
+CREATE TABLE IF NOT EXISTS scheduled_runs (
+    id UUID PRIMARY KEY,
+    project TEXT NOT NULL,
+    spider TEXT NOT NULL,
+    settings_arguments TEXT NOT NULL,
+    run_at DATETIME NOT NULL,
+    timezone TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    fired_at DATETIME,
+    error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by UUID,
+    modified_by UUID,
+    FOREIGN KEY (created_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (modified_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_scheduled_runs_due ON scheduled_runs(status, run_at);
+CREATE TABLE IF NOT EXISTS scheduled_run_nodes (
+    run_id UUID NOT NULL,
+    node TEXT NOT NULL,
+    PRIMARY KEY (run_id, node),
+    FOREIGN KEY (run_id) REFERENCES scheduled_runs(id) ON DELETE CASCADE,
+    FOREIGN KEY (node) REFERENCES scrapyd_nodes(nodeName) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS scheduled_run_nodes;
+DROP INDEX IF EXISTS idx_scheduled_runs_due;
+DROP TABLE IF EXISTS scheduled_runs;
@@ -0,0 +1,51 @@
+-- +goose Up
+-- Every version of the definition of a task. A revision is appended whenever a task is created, edited or reverted and is
+-- never changed afterward, the last revision of a task is its current definition. nodes, node_groups and
+-- blackout_calendars are JSON arrays of the task's targets and calendars at the time.
+CREATE TABLE IF NOT EXISTS task_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id UUID NOT NULL,
+    revision INTEGER NOT NULL,
+    name TEXT,
+    project TEXT NOT NULL,
+    spider TEXT NOT NULL,
+    settings_arguments TEXT NOT NULL,
+    cron_string TEXT NOT NULL,
+    timezone TEXT NOT NULL DEFAULT '',
+    retry_max_attempts INTEGER NOT NULL,
+    retry_backoff_seconds INTEGER NOT NULL,
+    retry_max_backoff_seconds INTEGER NOT NULL,
+    retry_on TEXT NOT NULL,
+    overlap_policy TEXT NOT NULL,
+    fan_out TEXT NOT NULL,
+    misfire_policy TEXT NOT NULL,
+    misfire_max_runs INTEGER NOT NULL,
+    max_runtime_seconds INTEGER,
+    nodes TEXT NOT NULL DEFAULT '[]',
+    node_groups TEXT NOT NULL DEFAULT '[]',
+    blackout_calendars TEXT NOT NULL DEFAULT '[]',
+    -- reverted_from is the revision this one restored, if it's a revert
+    reverted_from INTEGER,
+    created_by UUID,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (task_id, revision),
+    FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+-- The definitions of the existing tasks are their first revision
+INSERT INTO task_revisions (task_id, revision, name, project, spider, settings_arguments, cron_string, timezone,
+                            retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy,
+                            fan_out, misfire_policy, misfire_max_runs, max_runtime_seconds, nodes, node_groups,
+                            blackout_calendars, created_by, created_at)
+SELECT t.id, 1, t.name, t.project, t.spider, t.settings_arguments, t.cron_string, t.timezone, t.retry_max_attempts,
+       t.retry_backoff_seconds, t.retry_max_backoff_seconds, t.retry_on, t.overlap_policy, t.fan_out, t.misfire_policy,
+       t.misfire_max_runs, t.max_runtime_seconds,
+       (SELECT json_group_array(node) FROM (SELECT node FROM task_targets WHERE task_id = t.id AND node IS NOT NULL ORDER BY id)),
+       (SELECT json_group_array(group_id) FROM (SELECT group_id FROM task_targets WHERE task_id = t.id AND group_id IS NOT NULL ORDER BY id)),
+       (SELECT json_group_array(calendar_id) FROM (SELECT calendar_id FROM task_blackout_calendars WHERE task_id = t.id ORDER BY calendar_id)),
+       COALESCE(t.modified_by, t.created_by), t.update_time
+FROM tasks t;
+
+-- +goose Down
+DROP TABLE IF EXISTS task_revisions;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Cluster-wide maintenance mode, on while the table holds its single row and expires_at, if set, is yet to come. While it's
+-- on the scheduler suppresses fires, manual fires need an explicit override. The paused state of the tasks isn't touched.
+CREATE TABLE IF NOT EXISTS maintenance_mode (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    reason TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    started_by UUID,
+    expires_at DATETIME,
+    FOREIGN KEY (started_by) REFERENCES users(ID) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS maintenance_mode;
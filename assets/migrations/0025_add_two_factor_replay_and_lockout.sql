@@ -0,0 +1,12 @@
+-- +goose Up
+-- totp_last_step is the time step of the last TOTP code which was accepted, codes of that step or an earlier one are
+-- rejected so a code can't be used twice. totp_failed_attempts counts the incorrect codes in a row, once there are too
+-- many the second factor is locked until totp_locked_until.
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_locked_until DATETIME;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN totp_locked_until;
+ALTER TABLE users DROP COLUMN totp_failed_attempts;
+ALTER TABLE users DROP COLUMN totp_last_step;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- A restricted user, or every user with a restricted role, only sees what their access grants cover, none at all when
+-- they have no grants. Users and roles with grants so far are restricted.
+ALTER TABLE users ADD COLUMN access_restricted BOOL NOT NULL DEFAULT false;
+ALTER TABLE roles ADD COLUMN access_restricted BOOL NOT NULL DEFAULT false;
+UPDATE users SET access_restricted = true WHERE ID IN (SELECT user_id FROM access_grants WHERE user_id IS NOT NULL);
+UPDATE roles SET access_restricted = true WHERE name IN (SELECT role FROM access_grants WHERE role IS NOT NULL);
+
+-- +goose Down
+ALTER TABLE roles DROP COLUMN access_restricted;
+ALTER TABLE users DROP COLUMN access_restricted;
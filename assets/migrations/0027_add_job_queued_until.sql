@@ -0,0 +1,7 @@
+-- +goose Up
+-- The fire waiting on a queued job renews queued_until while it waits. Queued jobs past it have nobody waiting on them
+-- anymore, e.g. their instance stopped, and are failed so they don't block the later fires of their task.
+ALTER TABLE jobs ADD COLUMN queued_until DATETIME;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN queued_until;
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const scrapydBackendMaxFormBytes = 1 << 20
+
+// accessGrant lets a user work with a project on a node, an empty field matches any project or node.
+type accessGrant struct {
+	Project string `json:"project"`
+	Node    string `json:"node"`
+}
+
+// accessScope holds the grants of the authenticated user and of their role. A user who isn't restricted sees the whole
+// cluster, a restricted one only what the grants cover and nothing when there are none. The zero value is unrestricted.
+type accessScope struct {
+	restricted bool
+	grants     []accessGrant
+}
+
+func (s accessScope) unrestricted() bool {
+	return !s.restricted || slices.Contains(s.grants, accessGrant{})
+}
+
+// Allows reports whether the user may work with project on node. Pass an empty project or node to only check the other.
+func (s accessScope) Allows(project, node string) bool {
+	if s.unrestricted() {
+		return true
+	}
+	for _, grant := range s.grants {
+		if (project == "" || grant.Project == "" || grant.Project == project) && (node == "" || grant.Node == "" || grant.Node == node) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s accessScope) AllowsNode(node string) bool {
+	return s.Allows("", node)
+}
+
+func (s accessScope) AllowsProject(project string) bool {
+	return s.Allows(project, "")
+}
+
+// allowsEveryProject reports whether the user may work with any project on node, not just some of them.
+func (s accessScope) allowsEveryProject(node string) bool {
+	if s.unrestricted() {
+		return true
+	}
+	return slices.ContainsFunc(s.grants, func(grant accessGrant) bool {
+		return grant.Project == "" && (grant.Node == "" || grant.Node == node)
+	})
+}
+
+// queryGrants is the grants parameter of the job queries, which filter on it before they page. It's nil when the user
+// isn't restricted.
+func (s accessScope) queryGrants() (interface{}, error) {
+	if s.unrestricted() {
+		return nil, nil
+	}
+	grants := s.grants
+	if grants == nil {
+		grants = []accessGrant{}
+	}
+	encoded, err := json.Marshal(grants)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// nodes drops the nodes the user has no grant for, it reuses the passed slice.
+func (s accessScope) nodes(nodes []database.ScrapydNode) []database.ScrapydNode {
+	return slices.DeleteFunc(nodes, func(node database.ScrapydNode) bool {
+		return !s.AllowsNode(node.Nodename)
+	})
+}
+
+func (app *application) accessScopeForUser(ctx context.Context, user *database.User) (accessScope, error) {
+	restriction, err := app.DB.queries.GetAccessRestriction(ctx, user.ID)
+	if err != nil {
+		return accessScope{}, err
+	}
+	scope := accessScope{restricted: restriction.AccessRestricted || restriction.RoleAccessRestricted}
+	if !scope.restricted {
+		return scope, nil
+	}
+	grants, err := app.DB.queries.ListAccessGrantsForUser(ctx, database.ListAccessGrantsForUserParams{
+		UserID: user.ID,
+		Role:   sql.NullString{String: user.Role, Valid: true},
+	})
+	if err != nil {
+		return accessScope{}, err
+	}
+	for _, grant := range grants {
+		scope.grants = append(scope.grants, accessGrant{Project: grant.Project, Node: grant.Node})
+	}
+	return scope, nil
+}
+
+// pathInScope checks the node, project, task and job path values of the request against the user's grants. Tasks and
+// jobs which don't exist are left to the handler.
+func (app *application) pathInScope(r *http.Request) (bool, error) {
+	scope := contextGetAccessScope(r)
+	if scope.unrestricted() {
+		return true, nil
+	}
+	if !scope.Allows(r.PathValue("project"), r.PathValue("node")) {
+		return false, nil
+	}
+	if taskUUID, err := uuid.Parse(r.PathValue("taskUUID")); err == nil {
+		taskDb, err := app.DB.queries.GetTaskWithUUID(r.Context(), taskUUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return false, err
+		} else if err == nil {
+			allowed, err := app.taskInScope(r.Context(), scope, taskDb)
+			if err != nil || !allowed {
+				return false, err
+			}
+		}
+	}
+	if runID, err := uuid.Parse(r.PathValue("runID")); err == nil {
+		run, err := app.DB.queries.GetScheduledRun(r.Context(), runID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return false, err
+		} else if err == nil {
+			nodes, err := app.DB.queries.ListScheduledRunNodes(r.Context(), runID)
+			if err != nil || !scope.allowsTask(run.Project, nodes) {
+				return false, err
+			}
+		}
+	}
+	if jobID := r.PathValue("jobId"); jobID != "" {
+		job, err := app.DB.queries.GetProjectAndNodeForJob(r.Context(), jobID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return false, err
+		} else if err == nil && !scope.Allows(job.Project, job.Node) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// tasksInScope drops the tasks the user's grants don't cover, unknown tasks are kept and left to the caller.
+func (app *application) tasksInScope(ctx context.Context, scope accessScope, taskIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if scope.unrestricted() {
+		return taskIDs, nil
+	}
+	var allowed []uuid.UUID
+	for _, taskID := range taskIDs {
+		taskDb, err := app.DB.queries.GetTaskWithUUID(ctx, taskID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		} else if err == nil {
+			allowed, err := app.taskInScope(ctx, scope, taskDb)
+			if err != nil {
+				return nil, err
+			} else if !allowed {
+				continue
+			}
+		}
+		allowed = append(allowed, taskID)
+	}
+	return allowed, nil
+}
+
+// scrapydBackendProject finds the project a proxied Scrapyd request is about, the first path segment under logs/ or items/
+// or else the project parameter. A form body is read and put back for the proxy.
+func scrapydBackendProject(r *http.Request) (string, error) {
+	backendPath := strings.TrimPrefix(r.URL.Path, fmt.Sprintf("/%s/scrapyd-backend/", r.PathValue("node")))
+	segments := strings.Split(backendPath, "/")
+	if len(segments) > 1 && (segments[0] == "logs" || segments[0] == "items") {
+		return segments[1], nil
+	}
+	if project := r.URL.Query().Get("project"); project != "" {
+		return project, nil
+	}
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return "", nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, scrapydBackendMaxFormBytes))
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", err
+	}
+	return values.Get("project"), nil
+}
+
+type accessGrantForm struct {
+	Subject   string              `form:"subject"`
+	Project   string              `form:"project"`
+	Node      string              `form:"node"`
+	Validator validator.Validator `form:"-"`
+}
+
+// listAccessGrants shows every grant and adds new ones. The subject is either "user:<uuid>" or "role:<name>".
+func (app *application) listAccessGrants(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var form accessGrantForm
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		err := request.DecodePostForm(r, &form)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		params, err := app.validateAccessGrant(ctxwt, &form)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !form.Validator.HasErrors() {
+			err = app.insertAccessGrant(ctxwt, params)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, "/access-grants", http.StatusSeeOther)
+			return
+		}
+		status = http.StatusUnprocessableEntity
+	}
+	grants, err := app.DB.queries.ListAccessGrants(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	users, err := app.DB.queries.GetAllUsers(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	roles, err := app.DB.queries.ListRoles(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	nodes, err := app.DB.queries.ListScrapydNodes(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data["Grants"] = grants
+	data["Users"] = users
+	data["Roles"] = roles
+	data["Nodes"] = nodes
+	data["Form"] = form
+	app.render(w, r, status, accessGrantsPage, nil, data)
+}
+
+// insertAccessGrant adds a grant and restricts its user or role, which from then on only sees what their grants cover.
+func (app *application) insertAccessGrant(ctx context.Context, params database.InsertAccessGrantParams) error {
+	_, err := app.DB.queries.InsertAccessGrant(ctx, params)
+	if err != nil {
+		return err
+	}
+	if params.Role.Valid {
+		return app.DB.queries.SetRoleAccessRestricted(ctx, database.SetRoleAccessRestrictedParams{AccessRestricted: true, Name: params.Role.String})
+	}
+	userID, ok := params.UserID.(uuid.UUID)
+	if !ok {
+		return errors.New("access grant without a user or role")
+	}
+	return app.DB.queries.SetUserAccessRestricted(ctx, database.SetUserAccessRestrictedParams{AccessRestricted: true, ID: userID})
+}
+
+// liftAccessRestriction deletes the grants of a user or role and lets them see the whole cluster again. Deleting the last
+// grant on its own leaves them restricted to nothing.
+func (app *application) liftAccessRestriction(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	err := r.ParseForm()
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	kind, value, _ := strings.Cut(r.PostForm.Get("subject"), ":")
+	switch kind {
+	case "user":
+		userID, err := uuid.Parse(value)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		err = app.DB.queries.DeleteAccessGrantsForUser(ctxwt, userID)
+		if err == nil {
+			err = app.DB.queries.SetUserAccessRestricted(ctxwt, database.SetUserAccessRestrictedParams{AccessRestricted: false, ID: userID})
+		}
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	case "role":
+		err = app.DB.queries.DeleteAccessGrantsForRole(ctxwt, sql.NullString{String: value, Valid: true})
+		if err == nil {
+			err = app.DB.queries.SetRoleAccessRestricted(ctxwt, database.SetRoleAccessRestrictedParams{AccessRestricted: false, Name: value})
+		}
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	default:
+		app.badRequest(w, r, fmt.Errorf("invalid subject %q", r.PostForm.Get("subject")))
+		return
+	}
+	http.Redirect(w, r, "/access-grants", http.StatusSeeOther)
+}
+
+func (app *application) validateAccessGrant(ctx context.Context, form *accessGrantForm) (database.InsertAccessGrantParams, error) {
+	var params database.InsertAccessGrantParams
+	form.Validator.CheckField(validator.NotBlank(form.Project) || validator.NotBlank(form.Node), "project", "Limit the grant to a project, a node or both")
+	kind, value, _ := strings.Cut(form.Subject, ":")
+	switch kind {
+	case "user":
+		userID, err := uuid.Parse(value)
+		if err != nil {
+			form.Validator.AddFieldError("subject", "Select a user or a role")
+			return params, nil
+		}
+		_, err = app.DB.queries.GetUserWithID(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			form.Validator.AddFieldError("subject", "Select a user or a role")
+			return params, nil
+		} else if err != nil {
+			return params, err
+		}
+		params.UserID = userID
+	case "role":
+		_, err := app.DB.queries.GetRoleWithName(ctx, value)
+		if errors.Is(err, sql.ErrNoRows) {
+			form.Validator.AddFieldError("subject", "Select a user or a role")
+			return params, nil
+		} else if err != nil {
+			return params, err
+		}
+		params.Role = sql.NullString{String: value, Valid: true}
+	default:
+		form.Validator.AddFieldError("subject", "Select a user or a role")
+	}
+	if validator.NotBlank(form.Node) {
+		_, err := app.DB.queries.GetNodeWithName(ctx, form.Node)
+		if errors.Is(err, sql.ErrNoRows) {
+			form.Validator.AddFieldError("node", "Select one of the nodes")
+		} else if err != nil {
+			return params, err
+		}
+	}
+	params.Project = strings.TrimSpace(form.Project)
+	params.Node = form.Node
+	return params, nil
+}
+
+func (app *application) deleteAccessGrant(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	grantID, err := strconv.ParseInt(r.PathValue("grantID"), 10, 64)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	deleted, err := app.DB.queries.DeleteAccessGrant(ctxwt, grantID)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
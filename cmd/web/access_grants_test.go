@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/password"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAccessScopeAllows(t *testing.T) {
+	scope := accessScope{restricted: true, grants: []accessGrant{{Project: "shop", Node: "node_a"}, {Node: "node_b"}}}
+	tests := []struct {
+		name    string
+		scope   accessScope
+		project string
+		node    string
+		want    bool
+	}{
+		{"Unrestricted", accessScope{}, "news", "node_c", true},
+		{"Restricted without grants", accessScope{restricted: true}, "news", "node_c", false},
+		{"Granted project on node", scope, "shop", "node_a", true},
+		{"Other project on node", scope, "news", "node_a", false},
+		{"Any project on node", scope, "news", "node_b", true},
+		{"Other node", scope, "shop", "node_c", false},
+		{"Only the node", scope, "", "node_a", true},
+		{"Only the project", scope, "shop", "", true},
+		{"Project through a node grant", scope, "news", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.scope.Allows(tt.project, tt.node), tt.want)
+		})
+	}
+	assert.Equal(t, scope.allowsEveryProject("node_a"), false)
+	assert.Equal(t, scope.allowsEveryProject("node_b"), true)
+	nodes := scope.nodes([]database.ScrapydNode{{Nodename: "node_a"}, {Nodename: "node_b"}, {Nodename: "node_c"}})
+	assert.Equal(t, len(nodes), 2)
+	assert.Equal(t, nodes[1].Nodename, "node_b")
+}
+
+func TestScrapydBackendProject(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		body        string
+		contentType string
+		want        string
+	}{
+		{"Logs", http.MethodGet, "/node_a/scrapyd-backend/logs/shop/products/job.log", "", "", "shop"},
+		{"Items", http.MethodGet, "/node_a/scrapyd-backend/items/news/", "", "", "news"},
+		{"Query", http.MethodGet, "/node_a/scrapyd-backend/listspiders.json?project=shop", "", "", "shop"},
+		{"Form", http.MethodPost, "/node_a/scrapyd-backend/schedule.json", "project=shop&spider=products", "application/x-www-form-urlencoded", "shop"},
+		{"Root", http.MethodGet, "/node_a/scrapyd-backend/", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			r.SetPathValue("node", "node_a")
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			project, err := scrapydBackendProject(r)
+			assert.NilError(t, err)
+			assert.Equal(t, project, tt.want)
+			if tt.body != "" {
+				// The proxy still has to see the body
+				assert.NilError(t, r.ParseForm())
+				assert.Equal(t, r.PostForm.Get("spider"), "products")
+			}
+		})
+	}
+}
+
+func TestAccessGrants(t *testing.T) {
+	ta := newTestApplication(t)
+	ta.reverseProxy = &httputil.ReverseProxy{Rewrite: proxyRewriter, ErrorHandler: ta.reverseProxyErrHandler}
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ctx := context.Background()
+	scheduler, err := gocron.NewScheduler(gocron.WithClock(clockwork.NewFakeClock()))
+	assert.NilError(t, err)
+	ta.scheduler = scheduler
+	ta.scheduler.Start()
+	defer ta.scheduler.Shutdown()
+	mockScrapyd := httptest.NewServer(&mockScrapydNode{projects: []string{"shop", "news"}})
+	defer mockScrapyd.Close()
+	for _, name := range []string{"node_a", "node_b"} {
+		_, err = ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: name, Url: mockScrapyd.URL})
+		assert.NilError(t, err)
+	}
+	hashedPassword, err := password.Hash("ThisIsAVerySecurePasswordA$$word")
+	assert.NilError(t, err)
+	user, err := ta.DB.queries.CreateNewUser(ctx, database.CreateNewUserParams{
+		ID:             uuid.New(),
+		Username:       "shop_operator",
+		HashedPassword: hashedPassword,
+		Role:           roleOperator,
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, ta.insertAccessGrant(ctx, database.InsertAccessGrantParams{UserID: user.ID, Project: "shop", Node: "node_a"}))
+	token, _, err := ta.createAPIToken(ctx, user.ID, "ci", apiTokenScopeReadWrite, nil)
+	assert.NilError(t, err)
+	tasks := map[string]uuid.UUID{}
+	for name, node := range map[string]string{"shop": "node_a", "news": "node_b"} {
+		taskName := name + "_task"
+		taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+			ID:                uuid.New(),
+			Name:              database.CreateSqlNullString(&taskName),
+			Project:           name,
+			Spider:            "products",
+			Jobid:             taskName,
+			SettingsArguments: "project=" + name + "&spider=products",
+			CronString:        "* * * * *",
+			Paused:            true,
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{node}, nil))
+		tasks[name] = taskDb.ID
+	}
+
+	t.Run("API", func(t *testing.T) {
+		assert.Equal(t, ts.doWithBearer(t, http.MethodGet, "/api/v1/tasks/"+tasks["shop"].String(), token, ""), http.StatusOK)
+		assert.Equal(t, ts.doWithBearer(t, http.MethodGet, "/api/v1/tasks/"+tasks["news"].String(), token, ""), http.StatusNotFound)
+		assert.Equal(t, ts.doWithBearer(t, http.MethodGet, "/api/v1/nodes/node_b", token, ""), http.StatusNotFound)
+		body := `{"name": "t", "project": "news", "spider": "s", "cron": "* * * * *", "nodes": ["node_a"]}`
+		assert.Equal(t, ts.doWithBearer(t, http.MethodPost, "/api/v1/tasks", token, body), http.StatusUnprocessableEntity)
+	})
+	t.Run("Scrapyd facade", func(t *testing.T) {
+		code, body := ts.doScrapyd(t, http.MethodGet, "/scrapyd/listprojects.json", token, nil, "")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, len(body["projects"].([]any)), 1)
+		code, _ = ts.doScrapyd(t, http.MethodPost, "/scrapyd/schedule.json", token, strings.NewReader("project=news&spider=products"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+	t.Run("Pages", func(t *testing.T) {
+		ts.loginAs(t, "shop_operator", "ThisIsAVerySecurePasswordA$$word")
+		code, _, body := ts.get(t, "/list-tasks")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "shop_task")
+		assert.StringDoesNotContain(t, body, "news_task")
+		code, _, body = ts.get(t, "/list-nodes")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringDoesNotContain(t, body, "node_b")
+		code, _, _ = ts.get(t, "/node_b/jobs")
+		assert.Equal(t, code, http.StatusForbidden)
+		code, _, _ = ts.get(t, "/task/edit/"+tasks["news"].String())
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+	t.Run("Jobs are filtered before paging", func(t *testing.T) {
+		for _, job := range []struct{ name, project, node string }{
+			{"shop_job", "shop", "node_a"}, {"news_job_1", "news", "node_b"}, {"news_job_2", "news", "node_a"},
+		} {
+			_, err := ta.DB.queries.InsertJob(ctx, database.InsertJobParams{
+				Project: job.project, Spider: "products", Job: job.name, Status: "finished", Node: job.node,
+			})
+			assert.NilError(t, err)
+		}
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/jobs?limit=1", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var got struct {
+			Jobs []apiJob `json:"jobs"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &got))
+		assert.Equal(t, len(got.Jobs), 1)
+		assert.Equal(t, got.Jobs[0].Job, "shop_job")
+	})
+	t.Run("Scrapyd backend", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/node_a/scrapyd-backend/")
+		assert.Equal(t, code, http.StatusForbidden)
+		code, _, _ = ts.get(t, "/node_a/scrapyd-backend/logs/news/")
+		assert.Equal(t, code, http.StatusForbidden)
+		code, _, _ = ts.get(t, "/node_a/scrapyd-backend/logs/shop/")
+		assert.Equal(t, code, http.StatusOK)
+	})
+	t.Run("Manage grants", func(t *testing.T) {
+		ts := newTestServer(t, ta.routes())
+		defer ts.Close()
+		ts.login(t)
+		code, _, body := ts.get(t, "/access-grants")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "shop_operator")
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "subject": {"role:" + roleViewer}}
+		code, _, body = ts.postForm(t, "/access-grants", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Limit the grant to a project, a node or both")
+		form.Set("node", "node_b")
+		code, _, _ = ts.postForm(t, "/access-grants", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		grants, err := ta.DB.queries.ListAccessGrantsForUser(ctx, database.ListAccessGrantsForUserParams{
+			UserID: uuid.Nil,
+			Role:   sql.NullString{String: roleViewer, Valid: true},
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, len(grants), 1)
+		all, err := ta.DB.queries.ListAccessGrants(ctx)
+		assert.NilError(t, err)
+		for _, grant := range all {
+			code, _, _ = ts.delete(t, "/access-grants/"+strconv.FormatInt(grant.ID, 10))
+			assert.Equal(t, code, http.StatusOK)
+		}
+		code, _, _ = ts.delete(t, "/access-grants/12345")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+	t.Run("Restricted without grants", func(t *testing.T) {
+		// Deleting the last grant doesn't open up the cluster
+		code, _, body := ts.get(t, "/list-tasks")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringDoesNotContain(t, body, "shop_task")
+		assert.StringDoesNotContain(t, body, "news_task")
+
+		admin := newTestServer(t, ta.routes())
+		defer admin.Close()
+		admin.login(t)
+		code, _, body = admin.get(t, "/access-grants")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `value="user:`+user.ID.String()+`"`)
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "subject": {"user:" + user.ID.String()}}
+		code, _, _ = admin.postForm(t, "/access-grants/lift", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		code, _, body = ts.get(t, "/list-tasks")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "shop_task")
+		assert.StringContains(t, body, "news_task")
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/response"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const apiPathPrefix = "/api/"
+
+var errAPIUnsupportedMediaType = errors.New("content type must be application/json")
+
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiPathPrefix)
+}
+
+// readAPIJSON only accepts application/json bodies. Browsers can't send those cross-site without a CORS preflight,
+// which is what keeps the API safe without nosurf tokens.
+func readAPIJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errAPIUnsupportedMediaType
+	}
+	return request.DecodeJSONStrict(w, r, dst)
+}
+
+func (app *application) apiJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	err := response.JSON(w, status, data)
+	if err != nil {
+		app.apiServerError(w, r, err)
+	}
+}
+
+func (app *application) apiReadJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	err := readAPIJSON(w, r, dst)
+	if err != nil {
+		if errors.Is(err, errAPIUnsupportedMediaType) {
+			app.apiErrorResponse(w, r, http.StatusUnsupportedMediaType, err.Error(), nil)
+		} else {
+			app.apiBadRequest(w, r, err)
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/blazskufca/goscrapyd/assets"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const openAPIDocumentPath = "openapi/openapi.json"
+
+// openAPIMethods is the order operations of a path are listed in on the docs page.
+var openAPIMethods = []string{"get", "post", "put", "patch", "delete"}
+
+type openAPIDocument struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title       string `json:"title"`
+		Version     string `json:"version"`
+		Description string `json:"description"`
+	} `json:"info"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas   map[string]openAPISchema `json:"schemas"`
+		Responses map[string]struct {
+			Description string `json:"description"`
+		} `json:"responses"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Tags        []string           `json:"tags"`
+	OperationID string             `json:"operationId"`
+	Summary     string             `json:"summary"`
+	Description string             `json:"description"`
+	Parameters  []openAPIParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema openAPISchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Ref         string `json:"$ref"`
+		Description string `json:"description"`
+	} `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description"`
+	Required    bool          `json:"required"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref         string                   `json:"$ref"`
+	Type        string                   `json:"type"`
+	Format      string                   `json:"format"`
+	Description string                   `json:"description"`
+	Nullable    bool                     `json:"nullable"`
+	Enum        []string                 `json:"enum"`
+	Required    []string                 `json:"required"`
+	Properties  map[string]openAPISchema `json:"properties"`
+	Items       *openAPISchema           `json:"items"`
+	AllOf       []openAPISchema          `json:"allOf"`
+}
+
+// TypeName is a short, human-readable description of the schema type used on the docs page.
+func (s openAPISchema) TypeName() string {
+	var name string
+	switch {
+	case s.Ref != "":
+		name = path.Base(s.Ref)
+	case len(s.AllOf) == 1:
+		name = s.AllOf[0].TypeName()
+	case s.Type == "array" && s.Items != nil:
+		name = s.Items.TypeName() + "[]"
+	case s.Format != "":
+		name = s.Type + " (" + s.Format + ")"
+	default:
+		name = s.Type
+	}
+	if len(s.Enum) != 0 {
+		name += ": " + strings.Join(s.Enum, " | ")
+	}
+	if s.Nullable {
+		name += ", nullable"
+	}
+	return name
+}
+
+type apiDocsEndpoint struct {
+	Method      string
+	Path        string
+	Operation   openAPIOperation
+	PathParams  []openAPIParameter
+	RequestBody string
+	Responses   []apiDocsResponse
+}
+
+type apiDocsResponse struct {
+	Status      string
+	Description string
+}
+
+type apiDocsSchema struct {
+	Name       string
+	Schema     openAPISchema
+	Properties []apiDocsProperty
+}
+
+type apiDocsProperty struct {
+	Name     string
+	Required bool
+	Schema   openAPISchema
+}
+
+func loadOpenAPIDocument() (*openAPIDocument, error) {
+	data, err := assets.EmbeddedFiles.ReadFile(openAPIDocumentPath)
+	if err != nil {
+		return nil, err
+	}
+	var doc openAPIDocument
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// endpoints flattens the paths of the document into a sorted list for the docs page.
+func (doc *openAPIDocument) endpoints() ([]apiDocsEndpoint, error) {
+	var result []apiDocsEndpoint
+	for p, item := range doc.Paths {
+		var pathParams []openAPIParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &pathParams); err != nil {
+				return nil, err
+			}
+		}
+		for _, method := range openAPIMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			endpoint := apiDocsEndpoint{Method: strings.ToUpper(method), Path: p, PathParams: pathParams}
+			if err := json.Unmarshal(raw, &endpoint.Operation); err != nil {
+				return nil, err
+			}
+			if body := endpoint.Operation.RequestBody; body != nil {
+				endpoint.RequestBody = body.Content["application/json"].Schema.TypeName()
+			}
+			for status, resp := range endpoint.Operation.Responses {
+				description := resp.Description
+				if resp.Ref != "" {
+					description = doc.Components.Responses[path.Base(resp.Ref)].Description
+				}
+				endpoint.Responses = append(endpoint.Responses, apiDocsResponse{Status: status, Description: description})
+			}
+			sort.Slice(endpoint.Responses, func(i, j int) bool {
+				return endpoint.Responses[i].Status < endpoint.Responses[j].Status
+			})
+			result = append(result, endpoint)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Path != result[j].Path {
+			return result[i].Path < result[j].Path
+		}
+		return slices.Index(openAPIMethods, strings.ToLower(result[i].Method)) < slices.Index(openAPIMethods, strings.ToLower(result[j].Method))
+	})
+	return result, nil
+}
+
+func (doc *openAPIDocument) schemas() []apiDocsSchema {
+	var result []apiDocsSchema
+	for name, schema := range doc.Components.Schemas {
+		docsSchema := apiDocsSchema{Name: name, Schema: schema}
+		for propName, prop := range schema.Properties {
+			docsSchema.Properties = append(docsSchema.Properties, apiDocsProperty{
+				Name:     propName,
+				Required: slices.Contains(schema.Required, propName),
+				Schema:   prop,
+			})
+		}
+		sort.Slice(docsSchema.Properties, func(i, j int) bool {
+			return docsSchema.Properties[i].Name < docsSchema.Properties[j].Name
+		})
+		result = append(result, docsSchema)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (app *application) apiOpenAPI(w http.ResponseWriter, r *http.Request) {
+	data, err := assets.EmbeddedFiles.ReadFile(openAPIDocumentPath)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		app.reportServerError(r, err)
+	}
+}
+
+func (app *application) apiDocs(w http.ResponseWriter, r *http.Request) {
+	doc, err := loadOpenAPIDocument()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	endpoints, err := doc.endpoints()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data["Document"] = doc
+	data["Endpoints"] = endpoints
+	data["Schemas"] = doc.schemas()
+	app.render(w, r, http.StatusOK, apiDocsPage, nil, data)
+}
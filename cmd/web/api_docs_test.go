@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// registeredAPIRoutes parses routes.go and returns every "METHOD /api/...", "METHOD /scrapyd/..." and
+// "METHOD /hooks/..." pattern handed to the mux, the routes machines call rather than browsers.
+func registeredAPIRoutes(t *testing.T) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	assert.NilError(t, err)
+	var routes []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Handle" {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		pattern, err := strconv.Unquote(lit.Value)
+		assert.NilError(t, err)
+		_, p, _ := strings.Cut(pattern, " ")
+		if strings.HasPrefix(p, "/api/") || strings.HasPrefix(p, "/scrapyd/") || strings.HasPrefix(p, "/hooks/") {
+			routes = append(routes, pattern)
+		}
+		return true
+	})
+	sort.Strings(routes)
+	return routes
+}
+
+// jsonFields returns the JSON property names of a struct, skipping fields tagged "-".
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func schemaFields(schema openAPISchema) []string {
+	var fields []string
+	for name := range schema.Properties {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestOpenAPIDocumentCoversRoutes(t *testing.T) {
+	doc, err := loadOpenAPIDocument()
+	assert.NilError(t, err)
+	assert.Equal(t, doc.OpenAPI, "3.0.3")
+	var documented []string
+	for p, item := range doc.Paths {
+		for _, method := range openAPIMethods {
+			if _, ok := item[method]; ok {
+				documented = append(documented, strings.ToUpper(method)+" "+p)
+			}
+		}
+	}
+	sort.Strings(documented)
+	registered := registeredAPIRoutes(t)
+	for _, route := range registered {
+		if !slices.Contains(documented, route) {
+			t.Errorf("route %q is registered but not documented in openapi.json", route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(registered, route) {
+			t.Errorf("route %q is documented in openapi.json but not registered", route)
+		}
+	}
+	endpoints, err := doc.endpoints()
+	assert.NilError(t, err)
+	for _, endpoint := range endpoints {
+		for _, param := range endpoint.PathParams {
+			if !strings.Contains(endpoint.Path, "{"+param.Name+"}") {
+				t.Errorf("%s %s documents path parameter %q which isn't in the path", endpoint.Method, endpoint.Path, param.Name)
+			}
+		}
+		assert.NotEqual(t, endpoint.Operation.OperationID, "")
+	}
+}
+
+func TestOpenAPIDocumentMatchesTypes(t *testing.T) {
+	doc, err := loadOpenAPIDocument()
+	assert.NilError(t, err)
+	tests := []struct {
+		schema string
+		typ    reflect.Type
+	}{
+		{"Node", reflect.TypeOf(apiNode{})},
+		{"NodeInput", reflect.TypeOf(apiNodeInput{})},
+		{"NodeStatus", reflect.TypeOf(apiNodeStatus{})},
+		{"Task", reflect.TypeOf(apiTask{})},
+		{"TaskInput", reflect.TypeOf(apiTaskInput{})},
+		{"Job", reflect.TypeOf(apiJob{})},
+		{"Token", reflect.TypeOf(apiTokenView{})},
+		{"Webhook", reflect.TypeOf(apiWebhook{})},
+		{"TokenInput", reflect.TypeOf(apiTokenInput{})},
+		{"SchedulePreview", reflect.TypeOf(apiSchedulePreview{})},
+		{"ScheduleFire", reflect.TypeOf(apiScheduleFire{})},
+		{"Maintenance", reflect.TypeOf(maintenanceView{})},
+		{"MaintenanceInput", reflect.TypeOf(maintenanceInput{})},
+		{"Error", reflect.TypeOf(apiErrorEnvelope{})},
+		{"WebhookFireInput", reflect.TypeOf(webhookInput{})},
+		{"FiredJob", reflect.TypeOf(firedJob{})},
+		{"ScrapydError", reflect.TypeOf(scrapydFacadeError{})},
+		{"ScrapydDaemonStatus", reflect.TypeOf(scrapydDaemonStatusResponse{})},
+		{"ScrapydProjects", reflect.TypeOf(scrapydListProjects{})},
+		{"ScrapydSpiders", reflect.TypeOf(scrapydListSpidersResponse{})},
+		{"ScrapydJob", reflect.TypeOf(scrapydFacadeJob{})},
+		{"ScrapydJobs", reflect.TypeOf(scrapydFacadeListJobsResponse{})},
+		{"ScrapydScheduled", reflect.TypeOf(scrapydScheduleResponse{})},
+		{"ScrapydCancelled", reflect.TypeOf(scrapydCancelResponse{})},
+		{"ScrapydAddVersion", reflect.TypeOf(scrapydFacadeAddVersionResponse{})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[tt.schema]
+			if !ok {
+				t.Fatalf("schema %s is missing", tt.schema)
+			}
+			assert.Equal(t, strings.Join(schemaFields(schema), ","), strings.Join(jsonFields(tt.typ), ","))
+		})
+	}
+	t.Run("Error body", func(t *testing.T) {
+		schema := doc.Components.Schemas["Error"].Properties["error"]
+		assert.Equal(t, strings.Join(schemaFields(schema), ","), strings.Join(jsonFields(reflect.TypeOf(apiError{})), ","))
+	})
+	t.Run("Jobs query", func(t *testing.T) {
+		var params []openAPIParameter
+		assert.NilError(t, json.Unmarshal(doc.Paths["/api/v1/jobs"]["get"], &struct {
+			Parameters *[]openAPIParameter `json:"parameters"`
+		}{&params}))
+		var documented []string
+		for _, param := range params {
+			assert.Equal(t, param.In, "query")
+			documented = append(documented, param.Name)
+		}
+		sort.Strings(documented)
+		var fields []string
+		typ := reflect.TypeOf(apiJobsQuery{})
+		for i := 0; i < typ.NumField(); i++ {
+			if name := typ.Field(i).Tag.Get("form"); name != "-" {
+				fields = append(fields, name)
+			}
+		}
+		sort.Strings(fields)
+		assert.Equal(t, strings.Join(documented, ","), strings.Join(fields, ","))
+	})
+}
+
+func TestOpenAPIEndpoints(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	t.Run("Document is public", func(t *testing.T) {
+		code, header, body := ts.get(t, "/api/openapi.json")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, header.Get("Content-Type"), "application/json")
+		var doc openAPIDocument
+		assert.NilError(t, json.Unmarshal([]byte(body), &doc))
+		assert.Equal(t, doc.OpenAPI, "3.0.3")
+	})
+	t.Run("Docs page requires login", func(t *testing.T) {
+		code, header, _ := ts.get(t, "/api-docs")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/login")
+	})
+	t.Run("Docs page", func(t *testing.T) {
+		ts.login(t)
+		code, _, body := ts.get(t, "/api-docs")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "/api/v1/nodes/{node}/status")
+		assert.StringContains(t, body, "schema-TaskInput")
+	})
+}
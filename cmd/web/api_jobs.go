@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/funcs"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	apiJobsDefaultLimit = 100
+	apiJobsMaxLimit     = 1000
+)
+
+var apiJobStatuses = []string{"scheduled", "queued", "pending", "running", "finished", "timed_out", "error", "skipped"}
+
+type apiJobsQuery struct {
+	Node      string              `form:"node"`
+	Project   string              `form:"project"`
+	Spider    string              `form:"spider"`
+	Status    string              `form:"status"`
+	Task      string              `form:"task"`
+	StartedBy string              `form:"started_by"`
+	From      string              `form:"from"`
+	To        string              `form:"to"`
+	Cursor    string              `form:"cursor"`
+	Limit     string              `form:"limit"`
+	Validator validator.Validator `form:"-"`
+}
+
+type apiJob struct {
+	ID                int64      `json:"id"`
+	Project           string     `json:"project"`
+	Spider            string     `json:"spider"`
+	Job               string     `json:"job"`
+	Status            string     `json:"status"`
+	CreateTime        time.Time  `json:"create_time"`
+	UpdateTime        time.Time  `json:"update_time"`
+	Pages             *int64     `json:"pages"`
+	Items             *int64     `json:"items"`
+	Pid               *int64     `json:"pid"`
+	Start             *time.Time `json:"start"`
+	Runtime           *string    `json:"runtime"`
+	Finish            *time.Time `json:"finish"`
+	HrefLog           *string    `json:"href_log"`
+	HrefItems         *string    `json:"href_items"`
+	Node              string     `json:"node"`
+	Error             *string    `json:"error"`
+	StartedByUsername *string    `json:"started_by_username"`
+	StoppedByUsername *string    `json:"stopped_by_username"`
+	TriggeredBy       *string    `json:"triggered_by"`
+	Attempts          int64      `json:"attempts"`
+	NextRetryAt       *time.Time `json:"next_retry_at"`
+	TimedOutAt        *time.Time `json:"timed_out_at"`
+	SpiderArgs        *string    `json:"spider_args"`
+}
+
+func newAPIJob(job database.GetJobsForNodeRow) apiJob {
+	result := apiJob{
+		ID:                job.ID,
+		Project:           job.Project,
+		Spider:            job.Spider,
+		Job:               job.Job,
+		Status:            job.Status,
+		CreateTime:        job.CreateTime,
+		UpdateTime:        job.UpdateTime,
+		Pages:             nullInt64Ptr(job.Pages),
+		Items:             nullInt64Ptr(job.Items),
+		Pid:               nullInt64Ptr(job.Pid),
+		Start:             nullTimePtr(job.Start),
+		Runtime:           database.ReadSqlNullString(job.Runtime),
+		Finish:            nullTimePtr(job.Finish),
+		HrefLog:           database.ReadSqlNullString(job.HrefLog),
+		HrefItems:         database.ReadSqlNullString(job.HrefItems),
+		Node:              job.Node,
+		StartedByUsername: database.ReadSqlNullString(job.StartedByUsername),
+		StoppedByUsername: database.ReadSqlNullString(job.StoppedByUsername),
+		TriggeredBy:       database.ReadSqlNullString(job.TriggeredBy),
+		Attempts:          job.Attempts,
+		NextRetryAt:       nullTimePtr(job.NextRetryAt),
+		TimedOutAt:        nullTimePtr(job.TimedOutAt),
+		SpiderArgs:        database.ReadSqlNullString(job.SpiderArgs),
+	}
+	// Errors are stored base64 encoded, see afterTaskRunsWithError
+	if job.Error.Valid {
+		decoded := funcs.SafeBase64Decode(job.Error.String)
+		result.Error = &decoded
+	}
+	return result
+}
+
+func nullInt64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func optionalString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// encodeJobsCursor hides the keyset (the last returned job ID) so clients treat it as opaque.
+func encodeJobsCursor(lastID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastID, 10)))
+}
+
+func decodeJobsCursor(cursor string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(decoded), 10, 64)
+}
+
+// queryParams validates the query string and turns it into QueryJobsParams, fetching one extra row to detect the next page.
+func (q *apiJobsQuery) queryParams() database.QueryJobsParams {
+	params := database.QueryJobsParams{
+		Node:      optionalString(q.Node),
+		Project:   optionalString(q.Project),
+		Spider:    optionalString(q.Spider),
+		Status:    optionalString(q.Status),
+		StartedBy: optionalString(q.StartedBy),
+		Limit:     apiJobsDefaultLimit + 1,
+	}
+	if q.Status != "" {
+		q.Validator.CheckField(validator.In(q.Status, apiJobStatuses...), "status", fmt.Sprintf("Status must be one of %v", apiJobStatuses))
+	}
+	if q.Task != "" {
+		taskID, err := uuid.Parse(q.Task)
+		q.Validator.CheckField(err == nil, "task", "Task must be a valid UUID")
+		params.TaskID = taskID
+	}
+	var from, to time.Time
+	if q.From != "" {
+		var err error
+		from, err = time.Parse(time.RFC3339, q.From)
+		q.Validator.CheckField(err == nil, "from", "From must be an RFC 3339 timestamp")
+		params.CreatedAfter = from.UTC().Format(time.DateTime)
+	}
+	if q.To != "" {
+		var err error
+		to, err = time.Parse(time.RFC3339, q.To)
+		q.Validator.CheckField(err == nil, "to", "To must be an RFC 3339 timestamp")
+		params.CreatedBefore = to.UTC().Format(time.DateTime)
+	}
+	if !from.IsZero() && !to.IsZero() {
+		q.Validator.CheckField(from.Before(to), "to", "To must be after from")
+	}
+	if q.Limit != "" {
+		limit, err := strconv.Atoi(q.Limit)
+		q.Validator.CheckField(err == nil && validator.Between(limit, 1, apiJobsMaxLimit), "limit", fmt.Sprintf("Limit must be a number between 1 and %d", apiJobsMaxLimit))
+		params.Limit = int64(limit) + 1
+	}
+	if q.Cursor != "" {
+		beforeID, err := decodeJobsCursor(q.Cursor)
+		q.Validator.CheckField(err == nil, "cursor", "Cursor is not valid")
+		params.BeforeID = beforeID
+	}
+	return params
+}
+
+func (app *application) apiListJobs(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var query apiJobsQuery
+	err := request.DecodeQueryString(r, &query)
+	if err != nil {
+		app.apiBadRequest(w, r, err)
+		return
+	}
+	params := query.queryParams()
+	if query.Validator.HasErrors() {
+		app.apiFailedValidation(w, r, query.Validator)
+		return
+	}
+	params.Grants, err = contextGetAccessScope(r).queryGrants()
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	jobs, err := app.DB.queries.QueryJobs(ctxwt, params)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	var nextCursor *string
+	if int64(len(jobs)) == params.Limit {
+		jobs = jobs[:len(jobs)-1]
+		cursor := encodeJobsCursor(jobs[len(jobs)-1].ID)
+		nextCursor = &cursor
+	}
+	result := make([]apiJob, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, newAPIJob(database.GetJobsForNodeRow(job)))
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"jobs": result, "next_cursor": nextCursor})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAPIListJobs(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	for _, node := range []string{"node_a", "node_b"} {
+		_, err := ta.DB.queries.NewScrapydNode(context.Background(), database.NewScrapydNodeParams{
+			Nodename: node,
+			Url:      "http://" + node + ":6800",
+		})
+		assert.NilError(t, err)
+	}
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		node, status := "node_a", "finished"
+		if i%2 == 1 {
+			node, status = "node_b", "running"
+		}
+		_, err := ta.DB.queries.InsertJob(context.Background(), database.InsertJobParams{
+			Project:    "project",
+			Spider:     fmt.Sprintf("spider_%d", i),
+			Job:        fmt.Sprintf("job_%d", i),
+			Status:     status,
+			CreateTime: base.Add(time.Duration(i) * time.Hour),
+			UpdateTime: base.Add(time.Duration(i) * time.Hour),
+			Node:       node,
+		})
+		assert.NilError(t, err)
+	}
+	type jobsResponse struct {
+		Jobs       []apiJob `json:"jobs"`
+		NextCursor *string  `json:"next_cursor"`
+	}
+	t.Run("All jobs newest first", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/jobs", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var resp jobsResponse
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, len(resp.Jobs), 5)
+		assert.Equal(t, resp.Jobs[0].Job, "job_4")
+		assert.Equal(t, resp.NextCursor == nil, true)
+	})
+	t.Run("Filters", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/jobs?node=node_b&status=running", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var resp jobsResponse
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, len(resp.Jobs), 2)
+		for _, job := range resp.Jobs {
+			assert.Equal(t, job.Node, "node_b")
+		}
+	})
+	t.Run("Time range", func(t *testing.T) {
+		query := url.Values{}
+		query.Set("from", base.Add(time.Hour).Format(time.RFC3339))
+		query.Set("to", base.Add(3*time.Hour).Format(time.RFC3339))
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/jobs?"+query.Encode(), nil)
+		assert.Equal(t, code, http.StatusOK)
+		var resp jobsResponse
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, len(resp.Jobs), 2)
+		assert.Equal(t, resp.Jobs[0].Job, "job_2")
+		assert.Equal(t, resp.Jobs[1].Job, "job_1")
+	})
+	t.Run("Cursor pagination", func(t *testing.T) {
+		var seen []string
+		path := "/api/v1/jobs?limit=2"
+		for {
+			code, _, body := ts.doJSON(t, http.MethodGet, path, nil)
+			assert.Equal(t, code, http.StatusOK)
+			var resp jobsResponse
+			assert.NilError(t, json.Unmarshal(body, &resp))
+			for _, job := range resp.Jobs {
+				seen = append(seen, job.Job)
+			}
+			if resp.NextCursor == nil {
+				break
+			}
+			path = "/api/v1/jobs?limit=2&cursor=" + url.QueryEscape(*resp.NextCursor)
+		}
+		assert.Equal(t, fmt.Sprint(seen), "[job_4 job_3 job_2 job_1 job_0]")
+	})
+	t.Run("Invalid parameters", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/jobs?status=lost&limit=0&cursor=%21%21&task=nope", nil)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		var envelope apiErrorEnvelope
+		assert.NilError(t, json.Unmarshal(body, &envelope))
+		assert.Equal(t, len(envelope.Error.Fields), 4)
+	})
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+type apiNode struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	URL         string  `json:"url"`
+	Username    *string `json:"username"`
+	HasPassword bool    `json:"has_password"`
+	Capacity    *int    `json:"capacity"`
+}
+
+type apiNodeStatus struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Pending  int    `json:"pending"`
+	Running  int    `json:"running"`
+	Finished int    `json:"finished"`
+}
+
+type apiNodeInput struct {
+	Name      string              `json:"name"`
+	URL       string              `json:"url"`
+	Username  *string             `json:"username"`
+	Password  *string             `json:"password"`
+	Capacity  *int                `json:"capacity"`
+	Validator validator.Validator `json:"-"`
+}
+
+func newAPINode(node database.ScrapydNode) apiNode {
+	return apiNode{
+		ID:          node.ID,
+		Name:        node.Nodename,
+		URL:         node.Url,
+		Username:    database.ReadSqlNullString(node.Username),
+		HasPassword: node.Password != nil,
+		Capacity:    database.ReadSqlNullInt64AsInt(node.Capacity),
+	}
+}
+
+func (in *apiNodeInput) validate() {
+	in.Validator.CheckField(validator.NotBlank(in.Name), "name", "You must provide a name for this node")
+	in.Validator.CheckField(validator.NotBlank(in.URL), "url", "You must provide a URL for this node")
+	in.Validator.CheckField(validator.IsURL(in.URL), "url", "Node URL must be a valid URL")
+	validateNodeCapacity(&in.Validator, "capacity", in.Capacity)
+}
+
+func (in *apiNodeInput) hasUsername() bool {
+	return in.Username != nil && validator.NotBlank(*in.Username)
+}
+
+func (app *application) apiListNodes(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	nodes, err := app.DB.queries.ListScrapydNodes(ctxwt)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	nodes = contextGetAccessScope(r).nodes(nodes)
+	result := make([]apiNode, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, newAPINode(node))
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"nodes": result})
+}
+
+func (app *application) apiGetNode(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	node, err := app.DB.queries.GetNodeWithName(ctxwt, r.PathValue("node"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"node": newAPINode(node)})
+}
+
+func (app *application) apiCreateNode(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var input apiNodeInput
+	if !app.apiReadJSON(w, r, &input) {
+		return
+	}
+	input.validate()
+	if input.Validator.HasErrors() {
+		app.apiFailedValidation(w, r, input.Validator)
+		return
+	}
+	cleanUrl, err := url.ParseRequestURI(input.URL)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	cleanUrl.Path = ""
+	dbQueryParams := database.NewScrapydNodeParams{
+		Nodename: input.Name,
+		Url:      cleanUrl.String(),
+		Username: database.CreateSqlNullString(input.Username),
+		Capacity: database.CreateSqlNullInt64FromInt(input.Capacity),
+	}
+	if input.hasUsername() && input.Password != nil {
+		encryptedPassword, err := encrypt(*input.Password, app.config.ScrapydEncryptSecret)
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+		dbQueryParams.Password = encryptedPassword
+	}
+	node, err := app.DB.queries.NewScrapydNode(ctxwt, dbQueryParams)
+	if err != nil {
+		if strings.Contains(err.Error(), errScrapydTableUniqueConstraint.Error()) {
+			app.apiErrorResponse(w, r, http.StatusConflict, fmt.Sprintf(scrapydUniqueConstraintErr, input.Name, dbQueryParams.Url), nil)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	w.Header().Set("Location", "/api/v1/nodes/"+url.PathEscape(node.Nodename))
+	app.apiJSON(w, r, http.StatusCreated, map[string]any{"node": newAPINode(node)})
+}
+
+func (app *application) apiUpdateNode(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	existing, err := app.DB.queries.GetNodeWithName(ctxwt, r.PathValue("node"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	var input apiNodeInput
+	if !app.apiReadJSON(w, r, &input) {
+		return
+	}
+	input.validate()
+	if input.Validator.HasErrors() {
+		app.apiFailedValidation(w, r, input.Validator)
+		return
+	}
+	cleanUrl, err := url.ParseRequestURI(input.URL)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	cleanUrl.Path = ""
+	updateQuery := database.UpdateNodeWhereNameParams{
+		NewNodeName: input.Name,
+		NewURL:      cleanUrl.String(),
+		NewUsername: database.CreateSqlNullString(input.Username),
+		NewCapacity: database.CreateSqlNullInt64FromInt(input.Capacity),
+		OldNodeName: existing.Nodename,
+	}
+	// Unlike the HTML form the API keeps the stored password when none is sent, so a rename doesn't wipe credentials
+	switch {
+	case !input.hasUsername():
+		updateQuery.NewPassword = nil
+	case input.Password != nil && validator.NotBlank(*input.Password):
+		encryptedPassword, err := encrypt(*input.Password, app.config.ScrapydEncryptSecret)
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+		updateQuery.NewPassword = encryptedPassword
+	default:
+		updateQuery.NewPassword = existing.Password
+	}
+	err = app.DB.queries.UpdateNodeWhereName(ctxwt, updateQuery)
+	if err != nil {
+		if strings.Contains(err.Error(), errScrapydTableUniqueConstraint.Error()) {
+			app.apiErrorResponse(w, r, http.StatusConflict, fmt.Sprintf(scrapydUniqueConstraintErr, input.Name, updateQuery.NewURL), nil)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	node, err := app.DB.queries.GetNodeWithName(ctxwt, input.Name)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"node": newAPINode(node)})
+}
+
+func (app *application) apiDeleteNode(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	node, err := app.DB.queries.GetNodeWithName(ctxwt, r.PathValue("node"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	err = app.DB.queries.DeleteScrapydNodes(ctxwt, node.Nodename)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) apiNodeStatus(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	node, err := app.DB.queries.GetNodeWithName(ctxwt, r.PathValue("node"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	req, err := makeRequestToScrapyd(ctxwt, app.DB.queries, http.MethodGet, node.Nodename, func(url *url.URL) *url.URL {
+		url.Path = path.Join(url.Path, scrapydDaemonStatusReq)
+		return url
+	}, nil, nil, app.config.ScrapydEncryptSecret)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	daemonStatus, err := requestJSONResourceFromScrapyd[scrapydDaemonStatusResponse](req, app.logger)
+	if err != nil {
+		app.apiBadGateway(w, r, fmt.Errorf("node %s is unreachable: %w", node.Nodename, err))
+		return
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"status": apiNodeStatus{
+		Name:     node.Nodename,
+		Status:   daemonStatus.Status,
+		Pending:  daemonStatus.Pending,
+		Running:  daemonStatus.Running,
+		Finished: daemonStatus.Finished,
+	}})
+}
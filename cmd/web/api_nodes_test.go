@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPINodesRequireAuthentication(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	code, headers, body := ts.doJSON(t, http.MethodGet, "/api/v1/nodes", nil)
+	assert.Equal(t, code, http.StatusUnauthorized)
+	assert.Equal(t, headers.Get("Content-Type"), "application/json")
+	var envelope apiErrorEnvelope
+	assert.NilError(t, json.Unmarshal(body, &envelope))
+	assert.Equal(t, envelope.Error.Status, http.StatusUnauthorized)
+}
+
+func TestAPINodesCRUD(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ta.config.ScrapydEncryptSecret = "thisis16bytes123"
+	t.Run("Create", func(t *testing.T) {
+		code, headers, body := ts.doJSON(t, http.MethodPost, "/api/v1/nodes", map[string]any{
+			"name":     "TestNode",
+			"url":      "http://not-valid:6800/some/path",
+			"username": "test",
+			"password": "secret",
+		})
+		assert.Equal(t, code, http.StatusCreated)
+		assert.Equal(t, headers.Get("Location"), "/api/v1/nodes/TestNode")
+		var resp struct {
+			Node apiNode `json:"node"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, resp.Node.Name, "TestNode")
+		assert.Equal(t, resp.Node.URL, "http://not-valid:6800")
+		assert.Equal(t, resp.Node.HasPassword, true)
+		assert.Equal(t, strings.Contains(string(body), "secret"), false)
+		node, err := ta.DB.queries.GetNodeWithName(context.Background(), "TestNode")
+		assert.NilError(t, err)
+		decryptedPassword, err := decrypt(node.Password, ta.config.ScrapydEncryptSecret)
+		assert.NilError(t, err)
+		assert.Equal(t, decryptedPassword, "secret")
+	})
+	t.Run("Create duplicate", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPost, "/api/v1/nodes", map[string]any{
+			"name": "TestNode",
+			"url":  "http://not-valid:6800",
+		})
+		assert.Equal(t, code, http.StatusConflict)
+		assert.StringContains(t, string(body), "Node with name TestNode and URL http://not-valid:6800 already exists")
+	})
+	t.Run("Create fails validation", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPost, "/api/v1/nodes", map[string]any{
+			"url": "not a url",
+		})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		var envelope apiErrorEnvelope
+		assert.NilError(t, json.Unmarshal(body, &envelope))
+		assert.Equal(t, envelope.Error.Fields["name"], "You must provide a name for this node")
+		assert.Equal(t, envelope.Error.Fields["url"], "Node URL must be a valid URL")
+	})
+	t.Run("Create rejects unknown fields", func(t *testing.T) {
+		code, _, _ := ts.doJSON(t, http.MethodPost, "/api/v1/nodes", map[string]any{
+			"name":   "Other",
+			"url":    "http://other:6800",
+			"bogus":  true,
+			"second": 1,
+		})
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+	t.Run("Create rejects form bodies", func(t *testing.T) {
+		rs, err := ts.Client().Post(ts.URL+"/api/v1/nodes", "application/x-www-form-urlencoded", strings.NewReader("name=Other&url=http://other:6800"))
+		assert.NilError(t, err)
+		defer rs.Body.Close()
+		assert.Equal(t, rs.StatusCode, http.StatusUnsupportedMediaType)
+	})
+	t.Run("List", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/nodes", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var resp struct {
+			Nodes []apiNode `json:"nodes"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, len(resp.Nodes), 1)
+		assert.Equal(t, resp.Nodes[0].Name, "TestNode")
+	})
+	t.Run("Get missing node", func(t *testing.T) {
+		code, _, _ := ts.doJSON(t, http.MethodGet, "/api/v1/nodes/DoesNotExist", nil)
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+	t.Run("Update keeps password", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPut, "/api/v1/nodes/TestNode", map[string]any{
+			"name":     "RenamedNode",
+			"url":      "http://still-not-valid:6800",
+			"username": "test",
+		})
+		assert.Equal(t, code, http.StatusOK)
+		var resp struct {
+			Node apiNode `json:"node"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, resp.Node.Name, "RenamedNode")
+		assert.Equal(t, resp.Node.HasPassword, true)
+		node, err := ta.DB.queries.GetNodeWithName(context.Background(), "RenamedNode")
+		assert.NilError(t, err)
+		decryptedPassword, err := decrypt(node.Password, ta.config.ScrapydEncryptSecret)
+		assert.NilError(t, err)
+		assert.Equal(t, decryptedPassword, "secret")
+	})
+	t.Run("Delete", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodDelete, "/api/v1/nodes/RenamedNode", nil)
+		assert.Equal(t, code, http.StatusNoContent)
+		assert.Equal(t, len(body), 0)
+		code, _, _ = ts.doJSON(t, http.MethodDelete, "/api/v1/nodes/RenamedNode", nil)
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestAPINodeStatus(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/daemonstatus.json")
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"node_name": "mock", "status": "ok", "pending": 1, "running": 2, "finished": 3}`))
+		assert.NilError(t, err)
+	}))
+	defer mockScrapyd.Close()
+	_, err := ta.DB.queries.NewScrapydNode(context.Background(), database.NewScrapydNodeParams{
+		Nodename: "MockNode",
+		Url:      mockScrapyd.URL,
+	})
+	assert.NilError(t, err)
+	_, err = ta.DB.queries.NewScrapydNode(context.Background(), database.NewScrapydNodeParams{
+		Nodename: "OfflineNode",
+		Url:      "http://127.0.0.1:1",
+	})
+	assert.NilError(t, err)
+	t.Run("Online node", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/nodes/MockNode/status", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var resp struct {
+			Status apiNodeStatus `json:"status"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, resp.Status.Name, "MockNode")
+		assert.Equal(t, resp.Status.Status, "ok")
+		assert.Equal(t, resp.Status.Pending, 1)
+		assert.Equal(t, resp.Status.Running, 2)
+		assert.Equal(t, resp.Status.Finished, 3)
+	})
+	t.Run("Offline node", func(t *testing.T) {
+		code, _, _ := ts.doJSON(t, http.MethodGet, "/api/v1/nodes/OfflineNode/status", nil)
+		assert.Equal(t, code, http.StatusBadGateway)
+	})
+}
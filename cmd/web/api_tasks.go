@@ -0,0 +1,698 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiReservedSpiderArgs are the schedule.json parameters goscrapyd manages itself, they can't be passed as spider args.
+var apiReservedSpiderArgs = []string{"project", "spider", "jobid", "setting", "_version"}
+
+type apiTask struct {
+	ID                uuid.UUID         `json:"id"`
+	Name              string            `json:"name"`
+	Project           string            `json:"project"`
+	Spider            string            `json:"spider"`
+	Cron              string            `json:"cron"`
+	Timezone          string            `json:"timezone"`
+	Nodes             []string          `json:"nodes"`
+	Groups            []string          `json:"groups"`
+	FanOut            string            `json:"fan_out"`
+	Args              map[string]string `json:"args"`
+	Settings          map[string]string `json:"settings"`
+	Paused            bool              `json:"paused"`
+	Scheduled         bool              `json:"scheduled"`
+	NextRun           *time.Time        `json:"next_run"`
+	LastRun           *time.Time        `json:"last_run"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+	LastJob           *apiJob           `json:"last_job"`
+	Retry             apiRetryPolicy    `json:"retry"`
+	Overlap           string            `json:"overlap_policy"`
+	Misfire           apiMisfirePolicy  `json:"misfire"`
+	Blackout          []string          `json:"blackout_calendars"`
+	MaxRuntimeSeconds int64             `json:"max_runtime_seconds"`
+}
+
+type apiMisfirePolicy struct {
+	Policy  string `json:"policy"`
+	MaxRuns int    `json:"max_runs"`
+}
+
+type apiRetryPolicy struct {
+	MaxAttempts       int      `json:"max_attempts"`
+	BackoffSeconds    int      `json:"backoff_seconds"`
+	MaxBackoffSeconds int      `json:"max_backoff_seconds"`
+	RetryOn           []string `json:"retry_on"`
+}
+
+func newAPIRetryPolicy(p retryPolicy) apiRetryPolicy {
+	retryOn := p.RetryOn
+	if retryOn == nil {
+		retryOn = []string{}
+	}
+	return apiRetryPolicy{
+		MaxAttempts:       p.MaxAttempts,
+		BackoffSeconds:    p.BackoffSeconds(),
+		MaxBackoffSeconds: p.MaxBackoffSeconds(),
+		RetryOn:           retryOn,
+	}
+}
+
+type apiTaskInput struct {
+	Name              string              `json:"name"`
+	Project           string              `json:"project"`
+	Spider            string              `json:"spider"`
+	Cron              string              `json:"cron"`
+	Timezone          string              `json:"timezone"`
+	Nodes             []string            `json:"nodes"`
+	Groups            []string            `json:"groups"`
+	FanOut            string              `json:"fan_out"`
+	Args              map[string]string   `json:"args"`
+	Settings          map[string]string   `json:"settings"`
+	Paused            bool                `json:"paused"`
+	RunNow            bool                `json:"run_now"`
+	Retry             *apiRetryPolicy     `json:"retry"`
+	Overlap           string              `json:"overlap_policy"`
+	Misfire           *apiMisfirePolicy   `json:"misfire"`
+	Blackout          []string            `json:"blackout_calendars"`
+	MaxRuntimeSeconds int64               `json:"max_runtime_seconds"`
+	Validator         validator.Validator `json:"-"`
+	// targets and calendars are filled by validate, with the groups and calendars resolved to their IDs
+	targets   taskTargets
+	calendars taskCalendars
+}
+
+// retryPolicy is the default policy, which doesn't retry, when the input has none.
+func (in *apiTaskInput) retryPolicy() retryPolicy {
+	if in.Retry == nil {
+		return defaultRetryPolicy()
+	}
+	return retryPolicy{
+		MaxAttempts: in.Retry.MaxAttempts,
+		Backoff:     time.Duration(in.Retry.BackoffSeconds) * time.Second,
+		MaxBackoff:  time.Duration(in.Retry.MaxBackoffSeconds) * time.Second,
+		RetryOn:     in.Retry.RetryOn,
+	}
+}
+
+// misfirePolicy is the default policy, which runs once, when the input has none.
+func (in *apiTaskInput) misfirePolicy() misfirePolicy {
+	if in.Misfire == nil {
+		return defaultMisfirePolicy()
+	}
+	p := misfirePolicy{Policy: in.Misfire.Policy, MaxRuns: in.Misfire.MaxRuns}
+	if p.MaxRuns == 0 {
+		p.MaxRuns = 1
+	}
+	return p
+}
+
+func (in *apiTaskInput) maxRuntime() time.Duration {
+	return time.Duration(in.MaxRuntimeSeconds) * time.Second
+}
+
+// overlapPolicy is overlapAllow when the input has none.
+func (in *apiTaskInput) overlapPolicy() string {
+	if in.Overlap == "" {
+		return overlapAllow
+	}
+	return in.Overlap
+}
+
+func (in *apiTaskInput) validate(ctx context.Context, queries *database.Queries, scope accessScope) error {
+	in.targets = taskTargets{Nodes: in.Nodes, FanOut: in.FanOut}
+	if in.targets.FanOut == "" {
+		in.targets.FanOut = fanOutAll
+	}
+	_, cronParseError := cron.ParseStandard(in.Cron)
+	in.Validator.CheckField(validator.NotBlank(in.Name), "name", "Task name can not be blank")
+	in.Validator.CheckField(validator.NotBlank(in.Project), "project", "You must select at least one project")
+	in.Validator.CheckField(validator.NotBlank(in.Spider), "spider", "You must select at least one spider")
+	in.Validator.CheckField(validator.NotBlank(in.Cron), "cron", "You must schedule spider")
+	in.Validator.CheckField(cronParseError == nil, "cron", "Not a valid/supported cron string. Please see https://en.wikipedia.org/wiki/Cron")
+	in.retryPolicy().validate(&in.Validator, "retry")
+	validateOverlapPolicy(&in.Validator, "overlap_policy", in.overlapPolicy())
+	in.misfirePolicy().validate(&in.Validator, "misfire", "misfire")
+	validateMaxRuntime(&in.Validator, "max_runtime_seconds", in.maxRuntime())
+	validateSpiderArgs(&in.Validator, "args", in.spiderValues())
+	in.Timezone = strings.TrimSpace(in.Timezone)
+	in.calendars = taskCalendars{Timezone: in.Timezone}
+	validateTimezone(&in.Validator, "timezone", in.Timezone)
+	for _, name := range in.Blackout {
+		calendar, err := queries.GetBlackoutCalendarByName(ctx, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			in.Validator.AddFieldError("blackout_calendars", fmt.Sprintf("Blackout calendar %s does not exist", name))
+			continue
+		} else if err != nil {
+			return err
+		}
+		in.calendars.Calendars = append(in.calendars.Calendars, calendar.ID)
+	}
+	for key := range in.Args {
+		in.Validator.CheckField(!slices.Contains(apiReservedSpiderArgs, key), "args", fmt.Sprintf("%s can not be passed as a spider argument", key))
+	}
+	for _, node := range in.Nodes {
+		_, err := queries.GetNodeWithName(ctx, node)
+		if errors.Is(err, sql.ErrNoRows) {
+			in.Validator.AddFieldError("nodes", fmt.Sprintf("Node %s does not exist", node))
+		} else if err != nil {
+			return err
+		}
+	}
+	for _, name := range in.Groups {
+		group, err := queries.GetNodeGroupByName(ctx, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			in.Validator.AddFieldError("groups", fmt.Sprintf("Node group %s does not exist", name))
+			continue
+		} else if err != nil {
+			return err
+		}
+		in.targets.Groups = append(in.targets.Groups, group.ID)
+	}
+	return validateTaskTargets(ctx, queries, &in.Validator, scope, in.Project, in.targets, "nodes", "groups")
+}
+
+// spiderValues encodes the input the same way the add task form does, settings are sent as setting=NAME=VALUE.
+func (in *apiTaskInput) spiderValues() url.Values {
+	values := url.Values{}
+	values.Set("project", in.Project)
+	values.Set("spider", in.Spider)
+	for key, value := range in.Args {
+		values.Set(key, value)
+	}
+	for name, value := range in.Settings {
+		values.Add("setting", name+"="+value)
+	}
+	return values
+}
+
+func taskArgsAndSettings(settingsArguments string) (map[string]string, map[string]string, error) {
+	values, err := url.ParseQuery(settingsArguments)
+	if err != nil {
+		return nil, nil, err
+	}
+	args := make(map[string]string)
+	settings := make(map[string]string)
+	for _, setting := range values["setting"] {
+		name, value, _ := strings.Cut(setting, "=")
+		settings[name] = value
+	}
+	for key := range cleanUrlValues(values, append(apiReservedSpiderArgs, "version", "csrf_token")...) {
+		args[key] = values.Get(key)
+	}
+	return args, settings, nil
+}
+
+func (app *application) newAPITask(ctx context.Context, taskDb database.Task) (apiTask, error) {
+	args, settings, err := taskArgsAndSettings(taskDb.SettingsArguments)
+	if err != nil {
+		return apiTask{}, err
+	}
+	result := apiTask{
+		ID:                taskDb.ID,
+		Name:              taskDb.Name.String,
+		Project:           taskDb.Project,
+		Spider:            taskDb.Spider,
+		Cron:              taskDb.CronString,
+		Timezone:          taskDb.Timezone,
+		Nodes:             []string{},
+		Groups:            []string{},
+		FanOut:            taskDb.FanOut,
+		Args:              args,
+		Settings:          settings,
+		Paused:            taskDb.Paused,
+		CreatedAt:         taskDb.CreateTime,
+		UpdatedAt:         taskDb.UpdateTime,
+		Retry:             newAPIRetryPolicy(retryPolicyFromTask(taskDb)),
+		Overlap:           taskDb.OverlapPolicy,
+		Misfire:           apiMisfirePolicy{Policy: taskDb.MisfirePolicy, MaxRuns: int(taskDb.MisfireMaxRuns)},
+		Blackout:          []string{},
+		MaxRuntimeSeconds: int64(maxRuntimeFromTask(taskDb) / time.Second),
+	}
+	targets, err := app.DB.queries.ListTargetsForTask(ctx, taskDb.ID)
+	if err != nil {
+		return apiTask{}, err
+	}
+	for _, target := range targets {
+		if target.Node.Valid {
+			result.Nodes = append(result.Nodes, target.Node.String)
+		} else if target.GroupName.Valid {
+			result.Groups = append(result.Groups, target.GroupName.String)
+		}
+	}
+	calendars, err := app.DB.queries.ListBlackoutCalendarsForTask(ctx, taskDb.ID)
+	if err != nil {
+		return apiTask{}, err
+	}
+	result.Blackout = append(result.Blackout, calendars...)
+	if exists, job := app.isTaskRunning(taskDb.ID); exists {
+		result.Scheduled = true
+		if nextRun, err := job.NextRun(); err == nil && !nextRun.IsZero() {
+			result.NextRun = &nextRun
+		}
+		if lastRun, err := job.LastRun(); err == nil && !lastRun.IsZero() {
+			result.LastRun = &lastRun
+		}
+	}
+	lastJob, err := app.DB.queries.GetLatestJobForTask(ctx, taskDb.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return apiTask{}, err
+	} else if err == nil {
+		job := newAPIJob(database.GetJobsForNodeRow(lastJob))
+		result.LastJob = &job
+	}
+	return result, nil
+}
+
+// apiTaskFromPath loads the task referenced by the taskUUID path value, writing a 404 if it doesn't exist.
+func (app *application) apiTaskFromPath(ctx context.Context, w http.ResponseWriter, r *http.Request) (database.Task, bool) {
+	taskUUID, err := uuid.Parse(r.PathValue("taskUUID"))
+	if err != nil {
+		app.apiNotFound(w, r)
+		return database.Task{}, false
+	}
+	taskDb, err := app.DB.queries.GetTaskWithUUID(ctx, taskUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return database.Task{}, false
+	}
+	return taskDb, true
+}
+
+func (app *application) apiWriteTask(ctx context.Context, w http.ResponseWriter, r *http.Request, status int, taskDb database.Task) {
+	result, err := app.newAPITask(ctx, taskDb)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.apiJSON(w, r, status, map[string]any{"task": result})
+}
+
+func (app *application) apiListTasks(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	tasks, err := app.DB.queries.GetTasks(ctxwt)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	scope := contextGetAccessScope(r)
+	taskNodes, err := app.taskNodes(ctxwt)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	result := make([]apiTask, 0, len(tasks))
+	for _, taskDb := range tasks {
+		if !scope.allowsTask(taskDb.Project, taskNodes[taskDb.ID]) {
+			continue
+		}
+		converted, err := app.newAPITask(ctxwt, taskDb)
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+		result = append(result, converted)
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"tasks": result})
+}
+
+func (app *application) apiGetTask(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskDb, ok := app.apiTaskFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	app.apiWriteTask(ctxwt, w, r, http.StatusOK, taskDb)
+}
+
+func (app *application) apiCreateTask(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var input apiTaskInput
+	if !app.apiReadJSON(w, r, &input) {
+		return
+	}
+	if err := input.validate(ctxwt, app.DB.queries, contextGetAccessScope(r)); err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	if input.Validator.HasErrors() {
+		app.apiFailedValidation(w, r, input.Validator)
+		return
+	}
+	if input.RunNow && app.apiRefuseMaintenanceFire(ctxwt, w, r) {
+		return
+	}
+	spiderValues := input.spiderValues()
+	retry := input.retryPolicy()
+	createdTask, err := app.newTask(false, nil, input.Name, input.Spider, input.Project, "", spiderValues, nil)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	} else if createdTask == nil {
+		app.apiServerError(w, r, fmt.Errorf("failed to create a new task"))
+		return
+	}
+	createdTask.Retry = retry
+	createdTask.Overlap = input.overlapPolicy()
+	createdTask.FanOut = input.targets.FanOut
+	createdTask.Timezone = input.Timezone
+	queryParams := database.InsertTaskParams{
+		ID:                createdTask.ID,
+		Name:              database.CreateSqlNullString(&input.Name),
+		Project:           input.Project,
+		Spider:            input.Spider,
+		Jobid:             input.Name,
+		SettingsArguments: spiderValues.Encode(),
+		CronString:        input.Cron,
+		Paused:            input.Paused,
+		OverlapPolicy:     input.overlapPolicy(),
+		FanOut:            input.targets.FanOut,
+		Timezone:          input.Timezone,
+	}
+	setInsertTaskRetryPolicy(&queryParams, retry)
+	setInsertTaskMisfirePolicy(&queryParams, input.misfirePolicy())
+	queryParams.MaxRuntimeSeconds = nullMaxRuntime(input.maxRuntime())
+	if user := contextGetAuthenticatedUser(r); user != nil {
+		queryParams.CreatedBy = user.ID
+	}
+	taskDb, err := app.DB.queries.InsertTask(ctxwt, queryParams)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	err = app.setTaskTargets(ctxwt, taskDb.ID, input.targets.Nodes, input.targets.Groups)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	err = app.setTaskBlackoutCalendars(ctxwt, taskDb.ID, input.calendars.Calendars)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	err = app.recordTaskRevision(ctxwt, taskDb.ID, contextGetAuthenticatedUser(r), 0)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	if !input.Paused {
+		cronJob, err := createdTask.newCronJob(input.Cron)
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+		if input.RunNow {
+			err = app.runTaskNow(cronJob, triggerManual)
+			if err != nil {
+				app.apiServerError(w, r, err)
+				return
+			}
+		}
+	}
+	app.apiWriteTask(ctxwt, w, r, http.StatusCreated, taskDb)
+}
+
+func (app *application) apiUpdateTask(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskDb, ok := app.apiTaskFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	var input apiTaskInput
+	if !app.apiReadJSON(w, r, &input) {
+		return
+	}
+	if err := input.validate(ctxwt, app.DB.queries, contextGetAccessScope(r)); err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	if input.Validator.HasErrors() {
+		app.apiFailedValidation(w, r, input.Validator)
+		return
+	}
+	if input.RunNow && app.apiRefuseMaintenanceFire(ctxwt, w, r) {
+		return
+	}
+	spiderValues := input.spiderValues()
+	queryParams := database.UpdateTaskParams{
+		Name:              database.CreateSqlNullString(&input.Name),
+		Project:           input.Project,
+		Spider:            input.Spider,
+		Jobid:             input.Name,
+		SettingsArguments: spiderValues.Encode(),
+		CronString:        input.Cron,
+		Paused:            input.Paused,
+		OverlapPolicy:     input.overlapPolicy(),
+		FanOut:            input.targets.FanOut,
+		Timezone:          input.Timezone,
+		ID:                taskDb.ID,
+	}
+	setUpdateTaskRetryPolicy(&queryParams, input.retryPolicy())
+	setUpdateTaskMisfirePolicy(&queryParams, input.misfirePolicy())
+	queryParams.MaxRuntimeSeconds = nullMaxRuntime(input.maxRuntime())
+	if user := contextGetAuthenticatedUser(r); user != nil {
+		queryParams.ModifiedBy = user.ID
+	}
+	err := app.DB.queries.UpdateTask(ctxwt, queryParams)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	err = app.setTaskTargets(ctxwt, taskDb.ID, input.targets.Nodes, input.targets.Groups)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	err = app.setTaskBlackoutCalendars(ctxwt, taskDb.ID, input.calendars.Calendars)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	err = app.recordTaskRevision(ctxwt, taskDb.ID, contextGetAuthenticatedUser(r), 0)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	updatedTask, err := app.DB.queries.GetTaskWithUUID(ctxwt, taskDb.ID)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	exists, _ := app.isTaskRunning(taskDb.ID)
+	switch {
+	case exists && input.Paused:
+		app.cluster.markRegistered(taskDb.ID)
+		err = app.scheduler.RemoveJob(taskDb.ID)
+	case exists:
+		_, err = app.rescheduleTask(updatedTask)
+	case !input.Paused:
+		_, err = app.scheduleTask(updatedTask)
+	}
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	if !input.Paused && input.RunNow {
+		if exists, job := app.isTaskRunning(taskDb.ID); exists {
+			if err := app.runTaskNow(job, triggerManual); err != nil {
+				app.apiServerError(w, r, err)
+				return
+			}
+		}
+	}
+	app.apiWriteTask(ctxwt, w, r, http.StatusOK, updatedTask)
+}
+
+func (app *application) apiPauseTask(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskDb, ok := app.apiTaskFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	err := app.deleteTaskFromScheduler(ctxwt, taskDb.ID.String())
+	if errors.Is(err, gocron.ErrJobNotFound) {
+		// Already out of the scheduler, make sure the database agrees
+		err = app.DB.queries.UpdateTaskPaused(ctxwt, database.UpdateTaskPausedParams{
+			Paused: true,
+			ID:     taskDb.ID,
+		})
+	}
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	taskDb.Paused = true
+	app.apiWriteTask(ctxwt, w, r, http.StatusOK, taskDb)
+}
+
+func (app *application) apiResumeTask(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskDb, ok := app.apiTaskFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	if exists, _ := app.isTaskRunning(taskDb.ID); !exists {
+		_, err := app.scheduleTask(taskDb)
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+	}
+	err := app.DB.queries.UpdateTaskPaused(ctxwt, database.UpdateTaskPausedParams{
+		Paused: false,
+		ID:     taskDb.ID,
+	})
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	taskDb.Paused = false
+	app.apiWriteTask(ctxwt, w, r, http.StatusOK, taskDb)
+}
+
+func (app *application) apiFireTask(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskDb, ok := app.apiTaskFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	exists, job := app.isTaskRunning(taskDb.ID)
+	if !exists {
+		app.apiErrorResponse(w, r, http.StatusConflict, fmt.Sprintf("task %s is not in the scheduler, resume it before firing", taskDb.ID), nil)
+		return
+	}
+	if app.apiRefuseMaintenanceFire(ctxwt, w, r) {
+		return
+	}
+	err := app.runTaskNow(job, triggerManual)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.apiWriteTask(ctxwt, w, r, http.StatusAccepted, taskDb)
+}
+
+func (app *application) apiDeleteTask(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskDb, ok := app.apiTaskFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	err := app.deleteTaskFromScheduler(ctxwt, taskDb.ID.String())
+	if err != nil && !errors.Is(err, gocron.ErrJobNotFound) {
+		app.apiServerError(w, r, err)
+		return
+	}
+	err = app.DB.queries.DeleteTaskWhereUUID(ctxwt, taskDb.ID)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type apiSchedulePreviewQuery struct {
+	Cron      string              `form:"cron"`
+	Timezone  string              `form:"timezone"`
+	Count     string              `form:"count"`
+	Blackout  []string            `form:"blackout_calendars"`
+	Validator validator.Validator `form:"-"`
+}
+
+type apiSchedulePreview struct {
+	Cron        string            `json:"cron"`
+	Timezone    string            `json:"timezone"`
+	Description string            `json:"description"`
+	Fires       []apiScheduleFire `json:"fires"`
+}
+
+type apiScheduleFire struct {
+	At           time.Time `json:"at"`
+	SuppressedBy *string   `json:"suppressed_by"`
+}
+
+func newAPISchedulePreview(preview schedulePreview) apiSchedulePreview {
+	result := apiSchedulePreview{
+		Cron:        preview.Cron,
+		Timezone:    preview.Timezone,
+		Description: preview.Description,
+		Fires:       make([]apiScheduleFire, 0, len(preview.Fires)),
+	}
+	for _, fire := range preview.Fires {
+		var suppressedBy *string
+		if fire.SuppressedBy != "" {
+			suppressedBy = &fire.SuppressedBy
+		}
+		result.Fires = append(result.Fires, apiScheduleFire{At: fire.At, SuppressedBy: suppressedBy})
+	}
+	return result
+}
+
+// apiPreviewSchedule is a dry run of a cron expression, for checking a schedule before creating or updating a task with it.
+func (app *application) apiPreviewSchedule(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var query apiSchedulePreviewQuery
+	err := request.DecodeQueryString(r, &query)
+	if err != nil {
+		app.apiBadRequest(w, r, err)
+		return
+	}
+	query.Cron = strings.TrimSpace(query.Cron)
+	query.Timezone = strings.TrimSpace(query.Timezone)
+	validateSchedulePreview(&query.Validator, "cron", "timezone", query.Cron, query.Timezone)
+	count := schedulePreviewFires
+	if query.Count != "" {
+		count, err = strconv.Atoi(query.Count)
+		query.Validator.CheckField(err == nil && validator.Between(count, 1, schedulePreviewMaxFires), "count", fmt.Sprintf("Count must be a number between 1 and %d", schedulePreviewMaxFires))
+	}
+	var calendarIDs []int64
+	for _, name := range query.Blackout {
+		calendar, err := app.DB.queries.GetBlackoutCalendarByName(ctxwt, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			query.Validator.AddFieldError("blackout_calendars", fmt.Sprintf("Blackout calendar %s does not exist", name))
+			continue
+		} else if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+		calendarIDs = append(calendarIDs, calendar.ID)
+	}
+	if query.Validator.HasErrors() {
+		app.apiFailedValidation(w, r, query.Validator)
+		return
+	}
+	preview, err := app.previewSchedule(ctxwt, query.Cron, query.Timezone, calendarIDs, time.Now(), count)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.apiJSON(w, r, http.StatusOK, newAPISchedulePreview(preview))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/jonboulle/clockwork"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPITasksLifecycle(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ta.config.ScrapydEncryptSecret = "thisis16bytes123"
+	scheduler, err := gocron.NewScheduler(gocron.WithClock(clockwork.NewFakeClock()))
+	assert.NilError(t, err)
+	ta.scheduler = scheduler
+	ta.scheduler.Start()
+	defer ta.scheduler.Shutdown()
+	scheduled := make(chan map[string][]string, 1)
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			query := r.URL.Query()
+			scheduled <- query
+			_, err := w.Write([]byte(fmt.Sprintf(`{"node_name": "test_node", "status": "ok", "jobid": "%s"}`, query.Get("jobid"))))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err = ta.DB.queries.NewScrapydNode(context.Background(), database.NewScrapydNodeParams{
+		Nodename: "test_node",
+		Url:      mockScrapyd.URL,
+	})
+	assert.NilError(t, err)
+	var taskID string
+	t.Run("Create fails validation", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPost, "/api/v1/tasks", map[string]any{
+			"name":    "task",
+			"project": "testProject",
+			"spider":  "test_spider",
+			"cron":    "not a cron",
+			"nodes":   []string{"missing_node"},
+			"args":    map[string]string{"jobid": "mine"},
+			"retry":   map[string]any{"max_attempts": 3, "backoff_seconds": 60, "max_backoff_seconds": 10, "retry_on": []string{"network"}},
+		})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		var envelope apiErrorEnvelope
+		assert.NilError(t, json.Unmarshal(body, &envelope))
+		assert.Equal(t, envelope.Error.Fields["cron"], "Not a valid/supported cron string. Please see https://en.wikipedia.org/wiki/Cron")
+		assert.Equal(t, envelope.Error.Fields["nodes"], "Node missing_node does not exist")
+		assert.Equal(t, envelope.Error.Fields["args"], "jobid can not be passed as a spider argument")
+		assert.Equal(t, envelope.Error.Fields["retry"], "Max backoff must be between the backoff and 1h0m0s")
+	})
+	t.Run("Create", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPost, "/api/v1/tasks", map[string]any{
+			"name":           "nightly",
+			"project":        "testProject",
+			"spider":         "test_spider",
+			"cron":           "0 3 * * *",
+			"nodes":          []string{"test_node"},
+			"args":           map[string]string{"category": "books"},
+			"settings":       map[string]string{"DOWNLOAD_DELAY": "2"},
+			"retry":          map[string]any{"max_attempts": 3, "backoff_seconds": 30, "max_backoff_seconds": 300, "retry_on": []string{"network", "http"}},
+			"overlap_policy": "skip",
+		})
+		assert.Equal(t, code, http.StatusCreated)
+		var resp struct {
+			Task apiTask `json:"task"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		created := resp.Task
+		assert.Equal(t, created.Name, "nightly")
+		assert.Equal(t, strings.Join(created.Nodes, ","), "test_node")
+		assert.Equal(t, created.FanOut, fanOutAll)
+		assert.Equal(t, created.Args["category"], "books")
+		assert.Equal(t, created.Settings["DOWNLOAD_DELAY"], "2")
+		assert.Equal(t, created.Scheduled, true)
+		assert.Equal(t, created.NextRun != nil, true)
+		assert.Equal(t, created.LastJob == nil, true)
+		assert.Equal(t, created.Retry.MaxAttempts, 3)
+		assert.Equal(t, created.Retry.MaxBackoffSeconds, 300)
+		assert.Equal(t, strings.Join(created.Retry.RetryOn, ","), "network,http")
+		assert.Equal(t, created.Overlap, overlapSkip)
+		exists, _ := ta.isTaskRunning(created.ID)
+		assert.Equal(t, exists, true)
+		taskID = created.ID.String()
+	})
+	t.Run("Update", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPut, "/api/v1/tasks/"+taskID, map[string]any{
+			"name":    "hourly",
+			"project": "testProject",
+			"spider":  "test_spider",
+			"cron":    "0 * * * *",
+			"nodes":   []string{"test_node"},
+			"args":    map[string]string{"category": "music"},
+		})
+		assert.Equal(t, code, http.StatusOK)
+		var resp struct {
+			Task apiTask `json:"task"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, resp.Task.Name, "hourly")
+		assert.Equal(t, resp.Task.Cron, "0 * * * *")
+		assert.Equal(t, resp.Task.Args["category"], "music")
+		assert.Equal(t, len(resp.Task.Settings), 0)
+		assert.Equal(t, resp.Task.Retry.MaxAttempts, 1)
+		assert.Equal(t, resp.Task.Scheduled, true)
+		_, job := ta.isTaskRunning(resp.Task.ID)
+		assert.Equal(t, job.Name(), "hourly")
+	})
+	t.Run("Pause", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPost, "/api/v1/tasks/"+taskID+"/pause", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var resp struct {
+			Task apiTask `json:"task"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, resp.Task.Paused, true)
+		assert.Equal(t, resp.Task.Scheduled, false)
+		assert.Equal(t, resp.Task.NextRun == nil, true)
+		code, _, _ = ts.doJSON(t, http.MethodPost, "/api/v1/tasks/"+taskID+"/fire", nil)
+		assert.Equal(t, code, http.StatusConflict)
+	})
+	t.Run("Resume and fire", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPost, "/api/v1/tasks/"+taskID+"/resume", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var resp struct {
+			Task apiTask `json:"task"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, resp.Task.Paused, false)
+		assert.Equal(t, resp.Task.Scheduled, true)
+		code, _, _ = ts.doJSON(t, http.MethodPost, "/api/v1/tasks/"+taskID+"/fire", nil)
+		assert.Equal(t, code, http.StatusAccepted)
+		select {
+		case query := <-scheduled:
+			assert.Equal(t, query["category"][0], "music")
+			assert.Equal(t, query["project"][0], "testProject")
+		case <-time.After(5 * time.Second):
+			t.Fatal("task was not sent to scrapyd")
+		}
+		// Scrapyd answers before the job is recorded, poll until it is
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			code, _, body = ts.doJSON(t, http.MethodGet, "/api/v1/tasks/"+taskID, nil)
+			assert.Equal(t, code, http.StatusOK)
+			resp.Task = apiTask{}
+			assert.NilError(t, json.Unmarshal(body, &resp))
+			if resp.Task.LastJob != nil && resp.Task.LastJob.Status == "scheduled" || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if resp.Task.LastJob == nil {
+			t.Fatal("the fired job was not recorded")
+		}
+		assert.Equal(t, resp.Task.LastJob.Spider, "test_spider")
+		assert.Equal(t, resp.Task.LastJob.Status, "scheduled")
+	})
+	t.Run("List", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/tasks", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var resp struct {
+			Tasks []apiTask `json:"tasks"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, len(resp.Tasks), 1)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		code, _, _ := ts.doJSON(t, http.MethodDelete, "/api/v1/tasks/"+taskID, nil)
+		assert.Equal(t, code, http.StatusNoContent)
+		code, _, _ = ts.doJSON(t, http.MethodGet, "/api/v1/tasks/"+taskID, nil)
+		assert.Equal(t, code, http.StatusNotFound)
+		assert.Equal(t, len(ta.scheduler.Jobs()), 0)
+	})
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiTokenScopeRead      = "read"
+	apiTokenScopeReadWrite = "read-write"
+	apiTokenPrefix         = "gsd_"
+)
+
+// apiTokenExpiryOptions are the lifetimes, in days, offered by the token form. Zero means the token never expires.
+var apiTokenExpiryOptions = []int{30, 90, 365, 0}
+
+type apiTokenForm struct {
+	Name          string              `form:"name"`
+	Scope         string              `form:"scope"`
+	ExpiresInDays int                 `form:"expires_in_days"`
+	Validator     validator.Validator `form:"-"`
+}
+
+type apiTokenInput struct {
+	Name      string              `json:"name"`
+	Scope     string              `json:"scope"`
+	ExpiresAt *time.Time          `json:"expires_at"`
+	Validator validator.Validator `json:"-"`
+}
+
+type apiTokenView struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP *string    `json:"last_used_ip"`
+	Revoked    bool       `json:"revoked"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func newAPITokenView(token database.ApiToken) apiTokenView {
+	return apiTokenView{
+		ID:         token.ID,
+		Name:       token.Name,
+		Scope:      token.Scope,
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  nullTimePtr(token.ExpiresAt),
+		LastUsedAt: nullTimePtr(token.LastUsedAt),
+		LastUsedIP: database.ReadSqlNullString(token.LastUsedIp),
+		Revoked:    token.Revoked,
+	}
+}
+
+func hashAPIToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// createAPIToken generates a new token for the user. The plaintext is returned once and never stored, only its SHA-256 hash is.
+func (app *application) createAPIToken(ctx context.Context, userID uuid.UUID, name, scope string, expiresAt *time.Time) (string, database.ApiToken, error) {
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", database.ApiToken{}, err
+	}
+	plaintext := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(randomBytes)
+	params := database.InsertAPITokenParams{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashAPIToken(plaintext),
+		Scope:     scope,
+	}
+	if expiresAt != nil {
+		params.ExpiresAt = sql.NullTime{Time: *expiresAt, Valid: true}
+	}
+	token, err := app.DB.queries.InsertAPIToken(ctx, params)
+	if err != nil {
+		return "", database.ApiToken{}, err
+	}
+	return plaintext, token, nil
+}
+
+// authenticateAPIToken resolves a plaintext token to its user. Unknown, revoked and expired tokens return a nil user
+// and no error.
+func (app *application) authenticateAPIToken(ctx context.Context, plaintext, ip string) (*database.User, *database.ApiToken, error) {
+	token, err := app.DB.queries.GetAPITokenWithHash(ctx, hashAPIToken(plaintext))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if token.Revoked || (token.ExpiresAt.Valid && !token.ExpiresAt.Time.After(time.Now())) {
+		return nil, nil, nil
+	}
+	user, err := app.DB.queries.GetUserWithID(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	err = app.DB.queries.UpdateAPITokenLastUsed(ctx, database.UpdateAPITokenLastUsedParams{
+		LastUsedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		LastUsedIp: database.CreateSqlNullString(&ip),
+		ID:         token.ID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, &token, nil
+}
+
+func validateAPITokenFields(v *validator.Validator, name, scope string) {
+	v.CheckField(validator.NotBlank(name), "name", "Token name can not be blank")
+	v.CheckField(validator.MaxRunes(name, 100), "name", "Token name must not be more than 100 characters")
+	v.CheckField(validator.In(scope, apiTokenScopeRead, apiTokenScopeReadWrite), "scope", "Scope must be either read or read-write")
+}
+
+func (app *application) listAPITokens(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	user := contextGetAuthenticatedUser(r)
+	form := apiTokenForm{Scope: apiTokenScopeRead, ExpiresInDays: apiTokenExpiryOptions[0]}
+	status := http.StatusOK
+	var newToken string
+	if r.Method == http.MethodPost {
+		err := request.DecodePostForm(r, &form)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		validateAPITokenFields(&form.Validator, form.Name, form.Scope)
+		form.Validator.CheckField(validator.In(form.ExpiresInDays, apiTokenExpiryOptions...), "expires_in_days", "Not a valid expiry")
+		if form.Validator.HasErrors() {
+			status = http.StatusUnprocessableEntity
+		} else {
+			var expiresAt *time.Time
+			if form.ExpiresInDays > 0 {
+				expiry := time.Now().AddDate(0, 0, form.ExpiresInDays)
+				expiresAt = &expiry
+			}
+			newToken, _, err = app.createAPIToken(ctxwt, user.ID, form.Name, form.Scope, expiresAt)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			form = apiTokenForm{Scope: apiTokenScopeRead, ExpiresInDays: apiTokenExpiryOptions[0]}
+		}
+	}
+	tokens, err := app.DB.queries.ListAPITokensForUser(ctxwt, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data["Tokens"] = tokens
+	data["Form"] = form
+	data["NewToken"] = newToken
+	data["ExpiryOptions"] = apiTokenExpiryOptions
+	data["Now"] = time.Now()
+	app.render(w, r, status, apiTokensPage, nil, data)
+}
+
+func (app *application) revokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	tokenID, err := strconv.ParseInt(r.PathValue("tokenID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	revoked, err := app.DB.queries.RevokeAPIToken(ctxwt, database.RevokeAPITokenParams{
+		ID:     tokenID,
+		UserID: contextGetAuthenticatedUser(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if revoked == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *application) apiListTokens(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	tokens, err := app.DB.queries.ListAPITokensForUser(ctxwt, contextGetAuthenticatedUser(r).ID)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	result := make([]apiTokenView, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, newAPITokenView(token))
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"tokens": result})
+}
+
+func (app *application) apiCreateToken(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var input apiTokenInput
+	if !app.apiReadJSON(w, r, &input) {
+		return
+	}
+	validateAPITokenFields(&input.Validator, input.Name, input.Scope)
+	if input.ExpiresAt != nil {
+		input.Validator.CheckField(input.ExpiresAt.After(time.Now()), "expires_at", "Expiry must be in the future")
+	}
+	if input.Validator.HasErrors() {
+		app.apiFailedValidation(w, r, input.Validator)
+		return
+	}
+	plaintext, token, err := app.createAPIToken(ctxwt, contextGetAuthenticatedUser(r).ID, input.Name, input.Scope, input.ExpiresAt)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	result := newAPITokenView(token)
+	result.Token = plaintext
+	app.apiJSON(w, r, http.StatusCreated, map[string]any{"token": result})
+}
+
+func (app *application) apiRevokeToken(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	tokenID, err := strconv.ParseInt(r.PathValue("tokenID"), 10, 64)
+	if err != nil {
+		app.apiNotFound(w, r)
+		return
+	}
+	revoked, err := app.DB.queries.RevokeAPIToken(ctxwt, database.RevokeAPITokenParams{
+		ID:     tokenID,
+		UserID: contextGetAuthenticatedUser(r).ID,
+	})
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	if revoked == 0 {
+		app.apiNotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (ts *testServer) doWithBearer(t *testing.T, method, urlPath, token, body string) int {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	return rs.StatusCode
+}
+
+func TestAPITokenAuthentication(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	admin, err := ta.DB.queries.GetUserByUsername(context.Background(), "admin")
+	assert.NilError(t, err)
+	readWrite, _, err := ta.createAPIToken(context.Background(), admin.ID, "ci", apiTokenScopeReadWrite, nil)
+	assert.NilError(t, err)
+	readOnly, _, err := ta.createAPIToken(context.Background(), admin.ID, "dashboards", apiTokenScopeRead, nil)
+	assert.NilError(t, err)
+	expiry := time.Now().Add(-time.Minute)
+	expired, _, err := ta.createAPIToken(context.Background(), admin.ID, "old", apiTokenScopeReadWrite, &expiry)
+	assert.NilError(t, err)
+	t.Run("Valid token", func(t *testing.T) {
+		assert.Equal(t, ts.doWithBearer(t, http.MethodGet, "/api/v1/nodes", readWrite, ""), http.StatusOK)
+		tokens, err := ta.DB.queries.ListAPITokensForUser(context.Background(), admin.ID)
+		assert.NilError(t, err)
+		for _, token := range tokens {
+			if token.Name == "ci" {
+				assert.Equal(t, token.LastUsedAt.Valid, true)
+				assert.Equal(t, token.LastUsedIp.Valid, true)
+			}
+		}
+	})
+	t.Run("Write with read-write token", func(t *testing.T) {
+		code := ts.doWithBearer(t, http.MethodPost, "/api/v1/nodes", readWrite, `{"name": "node", "url": "http://node:6800"}`)
+		assert.Equal(t, code, http.StatusCreated)
+	})
+	t.Run("Write with read-only token", func(t *testing.T) {
+		assert.Equal(t, ts.doWithBearer(t, http.MethodGet, "/api/v1/nodes", readOnly, ""), http.StatusOK)
+		code := ts.doWithBearer(t, http.MethodDelete, "/api/v1/nodes/node", readOnly, "")
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+	t.Run("Unknown and expired tokens", func(t *testing.T) {
+		assert.Equal(t, ts.doWithBearer(t, http.MethodGet, "/api/v1/nodes", "gsd_nope", ""), http.StatusUnauthorized)
+		assert.Equal(t, ts.doWithBearer(t, http.MethodGet, "/api/v1/nodes", expired, ""), http.StatusUnauthorized)
+	})
+	t.Run("Tokens only work for the API", func(t *testing.T) {
+		code := ts.doWithBearer(t, http.MethodGet, "/list-nodes", readWrite, "")
+		assert.Equal(t, code, http.StatusSeeOther)
+	})
+	t.Run("Revoke", func(t *testing.T) {
+		var resp struct {
+			Tokens []apiTokenView `json:"tokens"`
+		}
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/tokens", nil)
+		assert.NilError(t, err)
+		req.Header.Set("Authorization", "Bearer "+readWrite)
+		rs, err := ts.Client().Do(req)
+		assert.NilError(t, err)
+		defer rs.Body.Close()
+		assert.NilError(t, json.NewDecoder(rs.Body).Decode(&resp))
+		assert.Equal(t, len(resp.Tokens), 3)
+		for _, token := range resp.Tokens {
+			assert.Equal(t, token.Token, "")
+			if token.Name == "dashboards" {
+				code := ts.doWithBearer(t, http.MethodDelete, "/api/v1/tokens/"+strconv.FormatInt(token.ID, 10), readWrite, "")
+				assert.Equal(t, code, http.StatusNoContent)
+			}
+		}
+		assert.Equal(t, ts.doWithBearer(t, http.MethodGet, "/api/v1/nodes", readOnly, ""), http.StatusUnauthorized)
+	})
+}
+
+var newAPITokenRX = regexp.MustCompile(`<pre id="new-api-token"[^>]*>(gsd_[A-Za-z0-9_-]+)</pre>`)
+
+func TestAPITokensPage(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	code, _, body := ts.get(t, "/api-tokens")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "You don't have any API tokens yet")
+	t.Run("Fails with blank name", func(t *testing.T) {
+		form := url.Values{}
+		form.Set("csrf_token", extractCSRFToken(t, body))
+		form.Set("scope", apiTokenScopeRead)
+		form.Set("expires_in_days", "30")
+		code, _, body := ts.postForm(t, "/api-tokens", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Token name can not be blank")
+	})
+	t.Run("Create", func(t *testing.T) {
+		form := url.Values{}
+		form.Set("csrf_token", extractCSRFToken(t, body))
+		form.Set("name", "reporting")
+		form.Set("scope", apiTokenScopeRead)
+		form.Set("expires_in_days", "0")
+		code, _, body := ts.postForm(t, "/api-tokens", form)
+		assert.Equal(t, code, http.StatusOK)
+		matches := newAPITokenRX.FindStringSubmatch(body)
+		if len(matches) < 2 {
+			t.Fatal("new token not shown on page")
+		}
+		user, _, err := ta.authenticateAPIToken(context.Background(), matches[1], "127.0.0.1")
+		assert.NilError(t, err)
+		assert.Equal(t, user.Username, "admin")
+		assert.StringContains(t, body, "reporting")
+	})
+}
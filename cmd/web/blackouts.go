@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	blackoutDateLayout = "2006-01-02"
+	blackoutTimeLayout = "15:04"
+)
+
+// weekdayNames are the weekdays of blackout windows as they're stored in blackout_windows.weekdays, indexed by
+// time.Weekday.
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// taskLocation is the time zone the schedule and blackout calendars of a task are evaluated in, time.Local for tasks
+// without one.
+func taskLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// cronInTimezone prefixes schedule with the time zone of the task, gocron and cron.ParseStandard both understand it.
+func cronInTimezone(schedule, timezone string) string {
+	if timezone == "" {
+		return schedule
+	}
+	return "CRON_TZ=" + timezone + " " + schedule
+}
+
+func validateTimezone(v *validator.Validator, field, timezone string) {
+	if timezone == "" {
+		return
+	}
+	_, err := time.LoadLocation(timezone)
+	v.CheckField(err == nil && timezone != "Local", field, "Not a known IANA time zone, such as Europe/Berlin")
+}
+
+// blackoutWindow is a stretch of time in which a blackout calendar suppresses the fires of its tasks. Dates and weekdays
+// are those of the day the fire falls on, so a Saturday window from 22:00 to 02:00 doesn't cover early Sunday.
+type blackoutWindow struct {
+	database.BlackoutWindow
+}
+
+// covers reports whether the window suppresses a fire at, which has to be in the time zone of the task already.
+func (w blackoutWindow) covers(at time.Time) bool {
+	date := at.Format(blackoutDateLayout)
+	if w.StartDate != "" && date < w.StartDate {
+		return false
+	}
+	if w.EndDate != "" && date > w.EndDate {
+		return false
+	}
+	if w.Weekdays != "" && !slices.Contains(strings.Split(w.Weekdays, ","), weekdayNames[at.Weekday()]) {
+		return false
+	}
+	if w.StartTime == "" {
+		return true
+	}
+	clock := at.Format(blackoutTimeLayout)
+	if w.StartTime < w.EndTime {
+		return clock >= w.StartTime && clock < w.EndTime
+	}
+	return clock >= w.StartTime || clock < w.EndTime
+}
+
+func (w blackoutWindow) String() string {
+	var parts []string
+	switch {
+	case w.StartDate != "" && w.EndDate != "":
+		parts = append(parts, w.StartDate+" to "+w.EndDate)
+	case w.StartDate != "":
+		parts = append(parts, "from "+w.StartDate)
+	case w.EndDate != "":
+		parts = append(parts, "until "+w.EndDate)
+	}
+	if w.Weekdays != "" {
+		parts = append(parts, strings.ReplaceAll(w.Weekdays, ",", ", "))
+	}
+	if w.StartTime != "" {
+		parts = append(parts, w.StartTime+" to "+w.EndTime)
+	}
+	description := strings.Join(parts, ", ")
+	if w.Note != "" {
+		description += " (" + w.Note + ")"
+	}
+	return description
+}
+
+// blackoutError is a fire which a blackout calendar of the task suppressed. It's recorded the same way as a fire the
+// overlap policy skipped.
+type blackoutError struct {
+	Calendar string
+	Window   string
+}
+
+func (e *blackoutError) Error() string {
+	return fmt.Sprintf("suppressed by blackout calendar %s, %s", e.Calendar, e.Window)
+}
+
+// checkBlackout returns a *blackoutError when one of the blackout calendars of the task covers at. The windows are read
+// on every fire, so changing a calendar applies to its tasks right away.
+func (t *task) checkBlackout(ctx context.Context, at time.Time) error {
+	if t.OneTimeJob {
+		return nil
+	}
+	rows, err := t.DB.ListBlackoutWindowsForTask(ctx, t.ID)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	loc, err := taskLocation(t.Timezone)
+	if err != nil {
+		return err
+	}
+	at = at.In(loc)
+	for _, row := range rows {
+		window := blackoutWindow{database.BlackoutWindow{
+			ID:         row.ID,
+			CalendarID: row.CalendarID,
+			StartDate:  row.StartDate,
+			EndDate:    row.EndDate,
+			Weekdays:   row.Weekdays,
+			StartTime:  row.StartTime,
+			EndTime:    row.EndTime,
+			Note:       row.Note,
+		}}
+		if window.covers(at) {
+			return &blackoutError{Calendar: row.CalendarName, Window: window.String()}
+		}
+	}
+	return nil
+}
+
+// taskCalendars are the time zone and blackout calendars of a task, as the task forms show them.
+type taskCalendars struct {
+	Timezone  string
+	Calendars []int64
+}
+
+func (tc taskCalendars) HasCalendar(calendarID int64) bool {
+	return slices.Contains(tc.Calendars, calendarID)
+}
+
+func (app *application) taskCalendars(ctx context.Context, taskDb database.Task) (taskCalendars, error) {
+	calendars, err := app.DB.queries.ListTaskBlackoutCalendars(ctx, taskDb.ID)
+	if err != nil {
+		return taskCalendars{}, err
+	}
+	return taskCalendars{Timezone: taskDb.Timezone, Calendars: calendars}, nil
+}
+
+func validateTaskCalendars(ctx context.Context, queries *database.Queries, v *validator.Validator, calendars taskCalendars, timezoneField, calendarsField string) error {
+	validateTimezone(v, timezoneField, calendars.Timezone)
+	for _, calendarID := range calendars.Calendars {
+		_, err := queries.GetBlackoutCalendar(ctx, calendarID)
+		if errors.Is(err, sql.ErrNoRows) {
+			v.AddFieldError(calendarsField, fmt.Sprintf("Blackout calendar %d does not exist", calendarID))
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTaskBlackoutCalendars replaces the blackout calendars of the task.
+func (app *application) setTaskBlackoutCalendars(ctx context.Context, taskID uuid.UUID, calendars []int64) error {
+	err := app.DB.queries.DeleteTaskBlackoutCalendars(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	for _, calendarID := range calendars {
+		err = app.DB.queries.InsertTaskBlackoutCalendar(ctx, database.InsertTaskBlackoutCalendarParams{TaskID: taskID, CalendarID: calendarID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type blackoutCalendarForm struct {
+	Name      string              `form:"name"`
+	Validator validator.Validator `form:"-"`
+}
+
+type blackoutWindowForm struct {
+	CalendarID int64               `form:"-"`
+	StartDate  string              `form:"start_date"`
+	EndDate    string              `form:"end_date"`
+	Weekdays   []string            `form:"weekdays"`
+	StartTime  string              `form:"start_time"`
+	EndTime    string              `form:"end_time"`
+	Note       string              `form:"note"`
+	Validator  validator.Validator `form:"-"`
+}
+
+func (f blackoutWindowForm) HasWeekday(day string) bool {
+	return slices.Contains(f.Weekdays, day)
+}
+
+func (f *blackoutWindowForm) validate() {
+	v := &f.Validator
+	startDate, startErr := time.Parse(blackoutDateLayout, f.StartDate)
+	endDate, endErr := time.Parse(blackoutDateLayout, f.EndDate)
+	v.CheckField(f.StartDate == "" || startErr == nil, "start_date", "Start date must be a date such as 2025-12-24")
+	v.CheckField(f.EndDate == "" || endErr == nil, "end_date", "End date must be a date such as 2025-12-24")
+	if startErr == nil && endErr == nil {
+		v.CheckField(!endDate.Before(startDate), "end_date", "End date can't be before the start date")
+	}
+	for _, day := range f.Weekdays {
+		v.CheckField(slices.Contains(weekdayNames, day), "weekdays", fmt.Sprintf("Weekdays must be one of %s", strings.Join(weekdayNames, ", ")))
+	}
+	_, startErr = time.Parse(blackoutTimeLayout, f.StartTime)
+	_, endErr = time.Parse(blackoutTimeLayout, f.EndTime)
+	v.CheckField(f.StartTime == "" || startErr == nil, "start_time", "Start time must be a time of day such as 22:30")
+	v.CheckField(f.EndTime == "" || endErr == nil, "end_time", "End time must be a time of day such as 22:30")
+	v.CheckField((f.StartTime == "") == (f.EndTime == ""), "end_time", "Set both a start and an end time, or neither")
+	v.CheckField(f.StartTime == "" || f.StartTime != f.EndTime, "end_time", "End time must differ from the start time")
+	v.CheckField(f.StartDate != "" || f.EndDate != "" || len(f.Weekdays) > 0 || f.StartTime != "", "window",
+		"A window needs at least a date, a weekday or a time of day")
+}
+
+// weekdays are the selected weekdays in week order, as they're stored.
+func (f *blackoutWindowForm) weekdays() string {
+	var days []string
+	for _, day := range weekdayNames {
+		if slices.Contains(f.Weekdays, day) {
+			days = append(days, day)
+		}
+	}
+	return strings.Join(days, ",")
+}
+
+// blackoutCalendar is a calendar with its windows, as the blackout calendars page shows it.
+type blackoutCalendar struct {
+	database.ListBlackoutCalendarsRow
+	Windows []blackoutWindow
+}
+
+// blackoutCalendars lists the calendars and creates new ones. Tasks using a calendar don't fire during its windows.
+func (app *application) blackoutCalendars(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var form blackoutCalendarForm
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		err := request.DecodePostForm(r, &form)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		form.Name = strings.TrimSpace(form.Name)
+		form.Validator.CheckField(validator.NotBlank(form.Name), "name", "Name can not be blank")
+		_, err = app.DB.queries.GetBlackoutCalendarByName(ctxwt, form.Name)
+		if err == nil {
+			form.Validator.AddFieldError("name", "A calendar with this name already exists")
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			app.serverError(w, r, err)
+			return
+		}
+		if !form.Validator.HasErrors() {
+			params := database.InsertBlackoutCalendarParams{Name: form.Name}
+			if user := contextGetAuthenticatedUser(r); user != nil {
+				params.CreatedBy = user.ID
+			}
+			_, err = app.DB.queries.InsertBlackoutCalendar(ctxwt, params)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, "/blackout-calendars", http.StatusSeeOther)
+			return
+		}
+		status = http.StatusUnprocessableEntity
+	}
+	app.renderBlackoutCalendars(w, r, status, form, blackoutWindowForm{})
+}
+
+func (app *application) renderBlackoutCalendars(w http.ResponseWriter, r *http.Request, status int, form blackoutCalendarForm, windowForm blackoutWindowForm) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	rows, err := app.DB.queries.ListBlackoutCalendars(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	windows, err := app.DB.queries.ListBlackoutWindows(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	calendars := make([]blackoutCalendar, 0, len(rows))
+	for _, row := range rows {
+		calendar := blackoutCalendar{ListBlackoutCalendarsRow: row}
+		for _, window := range windows {
+			if window.CalendarID == row.ID {
+				calendar.Windows = append(calendar.Windows, blackoutWindow{window})
+			}
+		}
+		calendars = append(calendars, calendar)
+	}
+	data := app.newTemplateData(r)
+	data["Calendars"] = calendars
+	data["Form"] = form
+	data["WindowForm"] = windowForm
+	data["Weekdays"] = weekdayNames
+	data["Timezone"] = time.Local.String()
+	app.render(w, r, status, blackoutCalendarsPage, nil, data)
+}
+
+// calendarFromPath loads the calendar referenced by the calendarID path value, writing a 400 if it doesn't exist.
+func (app *application) calendarFromPath(ctx context.Context, w http.ResponseWriter, r *http.Request) (database.BlackoutCalendar, bool) {
+	calendarID, err := strconv.ParseInt(r.PathValue("calendarID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return database.BlackoutCalendar{}, false
+	}
+	calendar, err := app.DB.queries.GetBlackoutCalendar(ctx, calendarID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.badRequest(w, r, fmt.Errorf("blackout calendar %d doesn't exist", calendarID))
+		return database.BlackoutCalendar{}, false
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return database.BlackoutCalendar{}, false
+	}
+	return calendar, true
+}
+
+// addBlackoutWindow adds a window to a calendar, its tasks skip the fires in it from then on.
+func (app *application) addBlackoutWindow(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	calendar, ok := app.calendarFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	var form blackoutWindowForm
+	err := request.DecodePostForm(r, &form)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	form.CalendarID = calendar.ID
+	form.Note = strings.TrimSpace(form.Note)
+	form.validate()
+	if form.Validator.HasErrors() {
+		app.renderBlackoutCalendars(w, r, http.StatusUnprocessableEntity, blackoutCalendarForm{}, form)
+		return
+	}
+	_, err = app.DB.queries.InsertBlackoutWindow(ctxwt, database.InsertBlackoutWindowParams{
+		CalendarID: calendar.ID,
+		StartDate:  form.StartDate,
+		EndDate:    form.EndDate,
+		Weekdays:   form.weekdays(),
+		StartTime:  form.StartTime,
+		EndTime:    form.EndTime,
+		Note:       form.Note,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/blackout-calendars", http.StatusSeeOther)
+}
+
+// deleteBlackoutCalendar removes the calendar from every task using it.
+func (app *application) deleteBlackoutCalendar(w http.ResponseWriter, r *http.Request) {
+	app.deleteBlackoutRow(w, r, "calendarID", app.DB.queries.DeleteBlackoutCalendar)
+}
+
+func (app *application) deleteBlackoutWindow(w http.ResponseWriter, r *http.Request) {
+	app.deleteBlackoutRow(w, r, "windowID", app.DB.queries.DeleteBlackoutWindow)
+}
+
+func (app *application) deleteBlackoutRow(w http.ResponseWriter, r *http.Request, pathValue string, deleteRow func(context.Context, int64) (int64, error)) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	id, err := strconv.ParseInt(r.PathValue(pathValue), 10, 64)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	deleted, err := deleteRow(ctxwt, id)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
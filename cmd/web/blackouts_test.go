@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/funcs"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBlackoutWindowCovers(t *testing.T) {
+	// A Saturday
+	at := time.Date(2024, 12, 28, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		window database.BlackoutWindow
+		covers bool
+	}{
+		{"Inside date range", database.BlackoutWindow{StartDate: "2024-12-24", EndDate: "2024-12-28"}, true},
+		{"After date range", database.BlackoutWindow{StartDate: "2024-12-24", EndDate: "2024-12-27"}, false},
+		{"Open ended", database.BlackoutWindow{StartDate: "2024-12-28"}, true},
+		{"Before start", database.BlackoutWindow{StartDate: "2024-12-29"}, false},
+		{"Weekday", database.BlackoutWindow{Weekdays: "sat,sun"}, true},
+		{"Other weekday", database.BlackoutWindow{Weekdays: "mon,tue"}, false},
+		{"Time of day", database.BlackoutWindow{StartTime: "23:00", EndTime: "23:45"}, true},
+		{"End time is exclusive", database.BlackoutWindow{StartTime: "23:00", EndTime: "23:30"}, false},
+		{"Wraps past midnight", database.BlackoutWindow{StartTime: "22:00", EndTime: "02:00"}, true},
+		{"Outside wrapped window", database.BlackoutWindow{StartTime: "00:30", EndTime: "06:00"}, false},
+		{"Every condition must match", database.BlackoutWindow{Weekdays: "sat", StartTime: "01:00", EndTime: "03:00"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, blackoutWindow{tt.window}.covers(at), tt.covers)
+		})
+	}
+}
+
+func TestBlackoutWindowFormValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		form  blackoutWindowForm
+		field string
+	}{
+		{"Valid", blackoutWindowForm{StartDate: "2024-12-24", EndDate: "2024-12-26", Weekdays: []string{"sat"}, StartTime: "22:00", EndTime: "02:00"}, ""},
+		{"Empty", blackoutWindowForm{}, "window"},
+		{"Bad date", blackoutWindowForm{StartDate: "24.12.2024"}, "start_date"},
+		{"End before start", blackoutWindowForm{StartDate: "2024-12-26", EndDate: "2024-12-24"}, "end_date"},
+		{"Unknown weekday", blackoutWindowForm{Weekdays: []string{"someday"}}, "weekdays"},
+		{"Start time only", blackoutWindowForm{StartTime: "22:00"}, "end_time"},
+		{"Same times", blackoutWindowForm{StartTime: "22:00", EndTime: "22:00"}, "end_time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.validate()
+			if tt.field == "" {
+				assert.Equal(t, tt.form.Validator.HasErrors(), false)
+				return
+			}
+			_, ok := tt.form.Validator.FieldErrors[tt.field]
+			assert.Equal(t, ok, true)
+		})
+	}
+	form := blackoutWindowForm{Weekdays: []string{"sun", "fri", "mon"}}
+	assert.Equal(t, form.weekdays(), "sun,mon,fri")
+}
+
+func TestTaskTimezone(t *testing.T) {
+	var v validator.Validator
+	validateTimezone(&v, "timezone", "America/New_York")
+	validateTimezone(&v, "timezone", "")
+	assert.Equal(t, v.HasErrors(), false)
+	validateTimezone(&v, "timezone", "Mars/Olympus_Mons")
+	assert.Equal(t, v.FieldErrors["timezone"], "Not a known IANA time zone, such as Europe/Berlin")
+
+	since := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	missed, err := missedFires(cronInTimezone("0 6 * * *", "America/New_York"), since, since.Add(24*time.Hour), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(missed), 1)
+	assert.Equal(t, missed[0].UTC().Format(time.RFC3339), "2024-07-01T10:00:00Z")
+}
+
+func TestBlackoutCalendars(t *testing.T) {
+	ta := newTestApplication(t)
+	scheduler, err := gocron.NewScheduler(gocron.WithClock(clockwork.NewFakeClock()))
+	assert.NilError(t, err)
+	ta.scheduler = scheduler
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ctx := context.Background()
+	var scheduled atomic.Int32
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			scheduled.Add(1)
+			_, err := w.Write([]byte(`{"node_name": "blackout_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err = ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: "blackout_node", Url: mockScrapyd.URL})
+	assert.NilError(t, err)
+
+	var calendar database.BlackoutCalendar
+	t.Run("Create calendar and window", func(t *testing.T) {
+		code, _, body := ts.get(t, "/blackout-calendars")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "No blackout calendars yet")
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "name": {"maintenance"}}
+		code, _, _ = ts.postForm(t, "/blackout-calendars", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		code, _, body = ts.postForm(t, "/blackout-calendars", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "A calendar with this name already exists")
+		calendar, err = ta.DB.queries.GetBlackoutCalendarByName(ctx, "maintenance")
+		assert.NilError(t, err)
+
+		windowURL := "/blackout-calendars/" + strconv.FormatInt(calendar.ID, 10)
+		window := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "start_time": {"22:00"}}
+		code, _, body = ts.postForm(t, windowURL, window)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Set both a start and an end time, or neither")
+		// Every day of the week, so the window covers whenever the test runs
+		window = url.Values{"csrf_token": {extractCSRFToken(t, body)}, "weekdays": weekdayNames, "note": {"site maintenance"}}
+		code, _, _ = ts.postForm(t, windowURL, window)
+		assert.Equal(t, code, http.StatusSeeOther)
+		code, _, body = ts.get(t, "/blackout-calendars")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "sun, mon, tue, wed, thu, fri, sat (site maintenance)")
+	})
+
+	t.Run("Task form", func(t *testing.T) {
+		code, _, body := ts.get(t, "/add-task")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `<option value="`+strconv.FormatInt(calendar.ID, 10)+`" >maintenance</option>`)
+		form := url.Values{
+			"csrf_token":         {extractCSRFToken(t, body)},
+			"project":            {"shop"},
+			"spider":             {"products"},
+			"task_name":          {"form_task"},
+			"cron_input":         {"0 6 * * *"},
+			"fireNode":           {"blackout_node"},
+			"timezone":           {"Mars/Olympus_Mons"},
+			"blackout_calendars": {strconv.FormatInt(calendar.ID, 10)},
+		}
+		code, _, body = ts.postForm(t, "/add-task", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Not a known IANA time zone, such as Europe/Berlin")
+		form.Set("timezone", "Asia/Tokyo")
+		code, _, _ = ts.postForm(t, "/add-task", form)
+		assert.Equal(t, code, http.StatusOK)
+		tasks, err := ta.DB.queries.GetTasks(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, len(tasks), 1)
+		assert.Equal(t, tasks[0].Timezone, "Asia/Tokyo")
+		calendars, err := ta.DB.queries.ListBlackoutCalendarsForTask(ctx, tasks[0].ID)
+		assert.NilError(t, err)
+		assert.Equal(t, strings.Join(calendars, ","), "maintenance")
+		values, err := url.ParseQuery(tasks[0].SettingsArguments)
+		assert.NilError(t, err)
+		assert.Equal(t, values.Has("timezone") || values.Has("blackout_calendars"), false)
+	})
+
+	t.Run("Suppressed fires are recorded", func(t *testing.T) {
+		taskName := "suppressed"
+		taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+			ID:                uuid.New(),
+			Name:              database.CreateSqlNullString(&taskName),
+			Project:           "shop",
+			Spider:            "products",
+			Jobid:             taskName,
+			SettingsArguments: "project=shop&spider=products",
+			CronString:        "0 6 * * *",
+			Paused:            true,
+			RetryMaxAttempts:  1,
+			OverlapPolicy:     overlapAllow,
+			FanOut:            fanOutAll,
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{"blackout_node"}, nil))
+		assert.NilError(t, ta.setTaskBlackoutCalendars(ctx, taskDb.ID, []int64{calendar.ID}))
+		fire, err := ta.taskFromDb(taskDb)
+		assert.NilError(t, err)
+		assert.NilError(t, fire.fireFunc(ctx))
+		assert.Equal(t, scheduled.Load(), int32(0))
+		jobs, err := ta.DB.queries.SearchNodeJobs(ctx, database.SearchNodeJobsParams{SearchTerm: "products", Node: "blackout_node"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(jobs), 1)
+		assert.Equal(t, jobs[0].Status, "skipped")
+		assert.Equal(t, funcs.SafeBase64Decode(jobs[0].Error.String), "suppressed by blackout calendar maintenance, sun, mon, tue, wed, thu, fri, sat (site maintenance)")
+
+		_, err = fire.fireNow("")
+		assert.Equal(t, isSkippedFire(err), true)
+		assert.Equal(t, scheduled.Load(), int32(0))
+
+		code, _, _ := ts.delete(t, "/blackout-calendars/"+strconv.FormatInt(calendar.ID, 10))
+		assert.Equal(t, code, http.StatusOK)
+		assert.NilError(t, fire.fireFunc(ctx))
+		assert.Equal(t, scheduled.Load(), int32(1))
+	})
+}
+
+func TestBlackoutCalendarsSuppressMissedFires(t *testing.T) {
+	ta := newTestApplication(t)
+	ctx := context.Background()
+	scheduled := make(chan string, 2)
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			scheduled <- r.URL.Query().Get("jobid")
+			_, err := w.Write([]byte(`{"node_name": "blackout_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err := ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: "blackout_node", Url: mockScrapyd.URL})
+	assert.NilError(t, err)
+	calendar, err := ta.DB.queries.InsertBlackoutCalendar(ctx, database.InsertBlackoutCalendarParams{Name: "holidays"})
+	assert.NilError(t, err)
+	// Only the day of the first missed fire, the catch-up itself runs well outside the window
+	_, err = ta.DB.queries.InsertBlackoutWindow(ctx, database.InsertBlackoutWindowParams{
+		CalendarID: calendar.ID, StartDate: "2024-03-01", EndDate: "2024-03-01",
+	})
+	assert.NilError(t, err)
+	taskName := "catch_up"
+	taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+		ID:                uuid.New(),
+		Name:              database.CreateSqlNullString(&taskName),
+		Project:           "shop",
+		Spider:            "products",
+		Jobid:             taskName,
+		SettingsArguments: "project=shop&spider=products",
+		CronString:        "0 6 * * *",
+		RetryMaxAttempts:  1,
+		OverlapPolicy:     overlapAllow,
+		FanOut:            fanOutAll,
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{"blackout_node"}, nil))
+	assert.NilError(t, ta.setTaskBlackoutCalendars(ctx, taskDb.ID, []int64{calendar.ID}))
+	fire, err := ta.taskFromDb(taskDb)
+	assert.NilError(t, err)
+
+	inside := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+	outside := time.Date(2024, 3, 2, 6, 0, 0, 0, time.UTC)
+	fire.catchUpMisfires([]time.Time{inside, outside})
+	jobName := func(at time.Time) string {
+		return "task_products_blackout_node_" + misfireTriggeredBy + "_" + at.Format("2006-01-02T15_04_05")
+	}
+	select {
+	case jobID := <-scheduled:
+		assert.Equal(t, jobID, jobName(outside))
+	case <-time.After(5 * time.Second):
+		t.Fatal("the missed fire outside the window was not sent to scrapyd")
+	}
+	assert.Equal(t, len(scheduled), 0)
+	jobs, err := ta.DB.queries.SearchNodeJobs(ctx, database.SearchNodeJobsParams{SearchTerm: misfireTriggeredBy, Node: "blackout_node"})
+	assert.NilError(t, err)
+	statuses := map[string]string{}
+	for _, job := range jobs {
+		statuses[job.Job] = job.Status
+	}
+	assert.Equal(t, statuses[jobName(inside)], "skipped")
+	assert.Equal(t, statuses[jobName(outside)], "scheduled")
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// schedulerLeaseName is the row of scheduler_leases the instances of a cluster compete for.
+const schedulerLeaseName = "scheduler"
+
+var errNotLeader = errors.New("another instance holds the scheduler lease")
+
+// cluster coordinates goscrapyd instances which share a database, started with -ha. Every instance registers every task
+// with its scheduler, so any of them can serve the UI and the API, but only the instance holding the scheduler lease, the
+// leader, fires tasks on their schedule. The leader renews the lease every third of its duration, when it dies another
+// instance takes the lease over once it expired and catches up on the fires missed in between.
+//
+// A nil *cluster is a single instance which is always the leader.
+type cluster struct {
+	db       *database.Queries
+	logger   *slog.Logger
+	instance string
+	duration time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu sync.Mutex
+	// leaderUntil is when this instance stops considering itself the leader unless it renews the lease. It's a third of
+	// the lease duration before the lease expires in the database, so clock skew between instances doesn't let two of
+	// them fire at once.
+	leaderUntil time.Time
+	leader      string
+	// schedules are the tasks as syncTasks last saw them in the database
+	schedules map[uuid.UUID]taskSchedule
+	// registered are the tasks this instance registered or removed itself since the last syncTasks
+	registered map[uuid.UUID]bool
+}
+
+func newCluster(db *database.Queries, logger *slog.Logger, instance string, duration time.Duration) *cluster {
+	return &cluster{
+		db:         db,
+		logger:     logger,
+		instance:   instance,
+		duration:   duration,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		schedules:  make(map[uuid.UUID]taskSchedule),
+		registered: make(map[uuid.UUID]bool),
+	}
+}
+
+// Lock implements gocron.Locker for the jobs only the leader runs, such as polling the nodes. Every instance would
+// otherwise cancel the jobs over their max runtime and fire the downstream tasks of finished jobs.
+func (c *cluster) Lock(context.Context, string) (gocron.Lock, error) {
+	if !c.isLeader() {
+		return nil, errNotLeader
+	}
+	return leaderLock{}, nil
+}
+
+// leaderLock is held for as long as the instance is the leader, there's nothing to unlock.
+type leaderLock struct{}
+
+func (leaderLock) Unlock(context.Context) error {
+	return nil
+}
+
+func (c *cluster) isLeader() bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Before(c.leaderUntil)
+}
+
+// renew takes or renews the scheduler lease. elected reports whether this instance just became the leader.
+func (c *cluster) renew(ctx context.Context, now time.Time) (elected bool, err error) {
+	rows, err := c.db.AcquireSchedulerLease(ctx, database.AcquireSchedulerLeaseParams{
+		Name:       schedulerLeaseName,
+		Holder:     c.instance,
+		AcquiredAt: now.UTC(),
+		RenewedAt:  now.UTC(),
+		ExpiresAt:  now.Add(c.duration).UTC(),
+	})
+	if err != nil {
+		// The instance stays the leader until leaderUntil, the next renew may well get through
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wasLeader := now.Before(c.leaderUntil)
+	if rows == 1 {
+		c.leaderUntil = now.Add(c.duration - c.duration/3)
+		c.leader = c.instance
+		if !wasLeader {
+			c.logger.Info("elected scheduler leader", slog.String("instance", c.instance))
+		}
+		return !wasLeader, nil
+	}
+	c.leaderUntil = time.Time{}
+	lease, err := c.db.GetSchedulerLease(ctx, schedulerLeaseName)
+	if err != nil {
+		return false, err
+	}
+	if wasLeader || lease.Holder != c.leader {
+		c.logger.Info("following scheduler leader", slog.String("instance", c.instance), slog.String("leader", lease.Holder))
+	}
+	c.leader = lease.Holder
+	return false, nil
+}
+
+// release gives the lease up on shutdown, so another instance takes over right away instead of once it expired.
+func (c *cluster) release(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	close(c.stop)
+	<-c.done
+	c.mu.Lock()
+	c.leaderUntil = time.Time{}
+	c.mu.Unlock()
+	return c.db.ReleaseSchedulerLease(ctx, database.ReleaseSchedulerLeaseParams{Name: schedulerLeaseName, Holder: c.instance})
+}
+
+// markRegistered records that this instance registered or removed the task itself, so syncTasks takes the task as it is
+// in the database instead of registering it again.
+func (c *cluster) markRegistered(taskID uuid.UUID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registered[taskID] = true
+}
+
+func (c *cluster) takeRegistered() map[uuid.UUID]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	registered := c.registered
+	c.registered = make(map[uuid.UUID]bool)
+	return registered
+}
+
+// taskSchedule is what taskFromDb builds a registered task from. When it changed in the database since the last sync,
+// another instance changed the task.
+type taskSchedule struct {
+	Name                   string
+	Project                string
+	Spider                 string
+	SettingsArguments      string
+	CronString             string
+	Paused                 bool
+	RetryMaxAttempts       int64
+	RetryBackoffSeconds    int64
+	RetryMaxBackoffSeconds int64
+	RetryOn                string
+	OverlapPolicy          string
+	FanOut                 string
+	Timezone               string
+}
+
+func taskScheduleOf(taskDb database.Task) taskSchedule {
+	return taskSchedule{
+		Name:                   taskDb.Name.String,
+		Project:                taskDb.Project,
+		Spider:                 taskDb.Spider,
+		SettingsArguments:      taskDb.SettingsArguments,
+		CronString:             taskDb.CronString,
+		Paused:                 taskDb.Paused,
+		RetryMaxAttempts:       taskDb.RetryMaxAttempts,
+		RetryBackoffSeconds:    taskDb.RetryBackoffSeconds,
+		RetryMaxBackoffSeconds: taskDb.RetryMaxBackoffSeconds,
+		RetryOn:                taskDb.RetryOn,
+		OverlapPolicy:          taskDb.OverlapPolicy,
+		FanOut:                 taskDb.FanOut,
+		Timezone:               taskDb.Timezone,
+	}
+}
+
+// runCluster renews the scheduler lease and syncs the registered tasks with the database until the cluster is released.
+func (app *application) runCluster() {
+	defer close(app.cluster.done)
+	ticker := time.NewTicker(app.cluster.duration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-app.cluster.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), app.cluster.duration/3)
+		elected, err := app.cluster.renew(ctx, time.Now())
+		if err != nil {
+			app.logger.Error("error renewing the scheduler lease", slog.Any("err", err))
+		}
+		if elected {
+			err = app.catchUpAfterFailover(ctx, time.Now())
+			if err != nil {
+				app.logger.Error("error catching up on fires missed before the election", slog.Any("err", err))
+			}
+		}
+		err = app.syncTasks(ctx)
+		if err != nil {
+			app.logger.Error("error syncing tasks with the database", slog.Any("err", err))
+		}
+		cancel()
+	}
+}
+
+// catchUpAfterFailover catches up on the fires the previous leader missed, between its last fire and the election, as
+// the misfire policy of each task says.
+func (app *application) catchUpAfterFailover(ctx context.Context, now time.Time) error {
+	tasks, err := app.DB.queries.GetTasks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, taskDb := range tasks {
+		if taskDb.Paused {
+			continue
+		}
+		missed, err := misfiresToCatchUp(taskDb, now)
+		if err != nil {
+			return err
+		}
+		if len(missed) == 0 {
+			continue
+		}
+		createdTask, err := app.taskFromDb(taskDb)
+		if err != nil {
+			return err
+		}
+		createdTask.markCaughtUp()
+		app.logger.Info("catching up on fires missed before the election", slog.Any("task", taskDb.ID), slog.Int("missed", len(missed)))
+		go createdTask.catchUpMisfires(missed)
+	}
+	return nil
+}
+
+// syncTasks registers the tasks another instance created, changed or resumed with the scheduler of this instance and
+// removes the ones it paused or deleted.
+func (app *application) syncTasks(ctx context.Context) error {
+	registered := app.cluster.takeRegistered()
+	tasks, err := app.DB.queries.GetTasks(ctx)
+	if err != nil {
+		return err
+	}
+	seen := make(map[uuid.UUID]bool, len(tasks))
+	for _, taskDb := range tasks {
+		seen[taskDb.ID] = true
+		schedule := taskScheduleOf(taskDb)
+		app.cluster.mu.Lock()
+		previous, known := app.cluster.schedules[taskDb.ID]
+		app.cluster.schedules[taskDb.ID] = schedule
+		app.cluster.mu.Unlock()
+		if registered[taskDb.ID] || (known && previous == schedule) {
+			continue
+		}
+		running, _ := app.isTaskRunning(taskDb.ID)
+		switch {
+		case schedule.Paused && running:
+			err = app.scheduler.RemoveJob(taskDb.ID)
+		case !schedule.Paused:
+			// Registering a task with an identifier which is already registered replaces it
+			_, err = app.scheduleTask(taskDb)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// scheduleTask marked the task as changed by this instance, but the change came from another one
+		app.cluster.mu.Lock()
+		delete(app.cluster.registered, taskDb.ID)
+		app.cluster.mu.Unlock()
+		app.logger.Info("synced task changed by another instance", slog.Any("task", taskDb.ID), slog.Bool("paused", schedule.Paused))
+	}
+	app.cluster.mu.Lock()
+	var deleted []uuid.UUID
+	for taskID := range app.cluster.schedules {
+		if !seen[taskID] {
+			deleted = append(deleted, taskID)
+			delete(app.cluster.schedules, taskID)
+		}
+	}
+	app.cluster.mu.Unlock()
+	for _, taskID := range deleted {
+		if running, _ := app.isTaskRunning(taskID); running {
+			err = app.scheduler.RemoveJob(taskID)
+			if err != nil {
+				return err
+			}
+			app.logger.Info("removed task deleted by another instance", slog.Any("task", taskID))
+		}
+	}
+	return nil
+}
+
+// runTaskNow fires a registered task right away, on this instance even when it isn't the leader. trigger is recorded on
+// the task run. Callers fire by hand and check maintenanceRefusal first, so the run overrides maintenance mode.
+func (app *application) runTaskNow(job gocron.Job, trigger string) error {
+	app.manualRuns.add(job.ID(), runTrigger{Source: trigger, OverrideMaintenance: true})
+	err := job.RunNow()
+	if err != nil {
+		app.manualRuns.take(job.ID())
+	}
+	return err
+}
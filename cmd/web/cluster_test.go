@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClusterLease(t *testing.T) {
+	ta := newTestApplication(t)
+	ctx := context.Background()
+	first := newCluster(ta.DB.queries, ta.logger, "first", 30*time.Second)
+	second := newCluster(ta.DB.queries, ta.logger, "second", 30*time.Second)
+	now := time.Now()
+
+	elected, err := first.renew(ctx, now)
+	assert.NilError(t, err)
+	assert.Equal(t, elected, true)
+	assert.Equal(t, first.isLeader(), true)
+	elected, err = second.renew(ctx, now)
+	assert.NilError(t, err)
+	assert.Equal(t, elected, false)
+	assert.Equal(t, second.isLeader(), false)
+	assert.Equal(t, second.leader, "first")
+
+	// Renewing keeps the lease, it isn't a new election
+	elected, err = first.renew(ctx, now.Add(10*time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, elected, false)
+	elected, err = second.renew(ctx, now.Add(39*time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, elected, false)
+
+	// The first instance died, the second takes over once the lease expired
+	elected, err = second.renew(ctx, now.Add(41*time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, elected, true)
+	elected, err = first.renew(ctx, now.Add(42*time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, elected, false)
+	assert.Equal(t, first.leader, "second")
+	lease, err := ta.DB.queries.GetSchedulerLease(ctx, schedulerLeaseName)
+	assert.NilError(t, err)
+	assert.Equal(t, lease.Holder, "second")
+	assert.Equal(t, lease.AcquiredAt.Equal(now.Add(41*time.Second)), true)
+
+	// Released on shutdown, another instance takes over right away
+	go close(second.done)
+	assert.NilError(t, second.release(ctx))
+	assert.Equal(t, second.isLeader(), false)
+	elected, err = first.renew(ctx, now.Add(43*time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, elected, true)
+}
+
+func TestClusterFires(t *testing.T) {
+	ta := newTestApplication(t)
+	scheduler, err := gocron.NewScheduler(gocron.WithClock(clockwork.NewFakeClock()))
+	assert.NilError(t, err)
+	ta.scheduler = scheduler
+	ta.scheduler.Start()
+	defer func() { assert.NilError(t, ta.scheduler.Shutdown()) }()
+	ctx := context.Background()
+	var scheduled atomic.Int32
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			scheduled.Add(1)
+			_, err := w.Write([]byte(`{"node_name": "cluster_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err = ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: "cluster_node", Url: mockScrapyd.URL})
+	assert.NilError(t, err)
+
+	// Another instance leads, this one follows
+	leader := newCluster(ta.DB.queries, ta.logger, "leader", 30*time.Second)
+	_, err = leader.renew(ctx, time.Now())
+	assert.NilError(t, err)
+	ta.cluster = newCluster(ta.DB.queries, ta.logger, "follower", 30*time.Second)
+	_, err = ta.cluster.renew(ctx, time.Now())
+	assert.NilError(t, err)
+
+	taskName := "cluster_task"
+	lastFired := time.Now().Add(-3 * time.Hour)
+	taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+		ID:                uuid.New(),
+		Name:              database.CreateSqlNullString(&taskName),
+		Project:           "shop",
+		Spider:            "products",
+		Jobid:             taskName,
+		SettingsArguments: "project=shop&spider=products",
+		CronString:        "0 * * * *",
+		RetryMaxAttempts:  1,
+		OverlapPolicy:     overlapAllow,
+		FanOut:            fanOutAll,
+		MisfirePolicy:     misfireRunOnce,
+		MisfireMaxRuns:    1,
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{"cluster_node"}, nil))
+	err = ta.DB.queries.UpdateTaskLastFiredAt(ctx, database.UpdateTaskLastFiredAtParams{
+		LastFiredAt: database.CreateCreateSqlNullTimeNonPtr(lastFired),
+		ID:          taskDb.ID,
+	})
+	assert.NilError(t, err)
+	waitForFires := func(t *testing.T, fires int32) {
+		deadline := time.Now().Add(10 * time.Second)
+		for scheduled.Load() < fires && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, scheduled.Load(), fires)
+	}
+	lastFiredAt := func(t *testing.T) time.Time {
+		taskDb, err := ta.DB.queries.GetTaskWithUUID(ctx, taskDb.ID)
+		assert.NilError(t, err)
+		return taskDb.LastFiredAt.Time
+	}
+
+	t.Run("Follower", func(t *testing.T) {
+		assert.NilError(t, ta.loadTasksOnStart())
+		running, job := ta.isTaskRunning(taskDb.ID)
+		assert.Equal(t, running, true)
+		// Registering on a follower leaves the last fire for a new leader to catch up from
+		assert.Equal(t, lastFiredAt(t).Equal(lastFired.UTC()), true)
+		createdTask, err := ta.taskFromDb(taskDb)
+		assert.NilError(t, err)
+		assert.NilError(t, createdTask.scheduledFire(ctx))
+		assert.Equal(t, scheduled.Load(), int32(0))
+		// Fired by hand the task runs on the follower
+		assert.NilError(t, ta.runTaskNow(job, triggerManual))
+		waitForFires(t, 1)
+	})
+	t.Run("Failover", func(t *testing.T) {
+		scheduled.Store(0)
+		elected, err := ta.cluster.renew(ctx, time.Now().Add(time.Minute))
+		assert.NilError(t, err)
+		assert.Equal(t, elected, true)
+		assert.NilError(t, ta.catchUpAfterFailover(ctx, time.Now()))
+		waitForFires(t, 1)
+		assert.Equal(t, lastFiredAt(t).After(lastFired), true)
+	})
+}
+
+func TestClusterSyncTasks(t *testing.T) {
+	ta := newTestApplication(t)
+	scheduler, err := gocron.NewScheduler(gocron.WithClock(clockwork.NewFakeClock()))
+	assert.NilError(t, err)
+	ta.scheduler = scheduler
+	ta.scheduler.Start()
+	defer func() { assert.NilError(t, ta.scheduler.Shutdown()) }()
+	ta.cluster = newCluster(ta.DB.queries, ta.logger, "instance", 30*time.Second)
+	ctx := context.Background()
+	running := func(taskID uuid.UUID) bool {
+		running, _ := ta.isTaskRunning(taskID)
+		return running
+	}
+
+	// Created by another instance
+	taskName := "synced_task"
+	taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+		ID:                uuid.New(),
+		Name:              database.CreateSqlNullString(&taskName),
+		Project:           "shop",
+		Spider:            "products",
+		Jobid:             taskName,
+		SettingsArguments: "project=shop&spider=products",
+		CronString:        "0 6 * * *",
+		RetryMaxAttempts:  1,
+		OverlapPolicy:     overlapAllow,
+		FanOut:            fanOutAll,
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, ta.syncTasks(ctx))
+	assert.Equal(t, running(taskDb.ID), true)
+
+	err = ta.DB.queries.UpdateTaskPaused(ctx, database.UpdateTaskPausedParams{Paused: true, ID: taskDb.ID})
+	assert.NilError(t, err)
+	assert.NilError(t, ta.syncTasks(ctx))
+	assert.Equal(t, running(taskDb.ID), false)
+
+	err = ta.DB.queries.UpdateTaskPaused(ctx, database.UpdateTaskPausedParams{Paused: false, ID: taskDb.ID})
+	assert.NilError(t, err)
+	assert.NilError(t, ta.syncTasks(ctx))
+	assert.Equal(t, running(taskDb.ID), true)
+
+	// Paused on this instance, the sync leaves it alone
+	assert.NilError(t, ta.deleteTaskFromScheduler(ctx, taskDb.ID.String()))
+	assert.NilError(t, ta.syncTasks(ctx))
+	assert.Equal(t, running(taskDb.ID), false)
+
+	_, err = ta.scheduleTask(taskDb)
+	assert.NilError(t, err)
+	assert.NilError(t, ta.syncTasks(ctx))
+	err = ta.DB.queries.DeleteTaskWhereUUID(ctx, taskDb.ID)
+	assert.NilError(t, err)
+	assert.NilError(t, ta.syncTasks(ctx))
+	assert.Equal(t, running(taskDb.ID), false)
+}
@@ -12,6 +12,9 @@ const (
 	authenticatedUserContextKey = contextKey("authenticatedUser")
 	backendUrl                  = contextKey("backendURL")
 	xForwardedForPrefix         = contextKey("xForwardedForPrefix")
+	apiTokenContextKey          = contextKey("apiToken")
+	permissionsContextKey       = contextKey("permissions")
+	accessScopeContextKey       = contextKey("accessScope")
 )
 
 func contextSetAuthenticatedUser(r *http.Request, user *database.User) *http.Request {
@@ -30,3 +33,48 @@ func contextGetAuthenticatedUser(r *http.Request) *database.User {
 
 	return user
 }
+
+func contextSetAPIToken(r *http.Request, token *database.ApiToken) *http.Request {
+	ctx := context.WithValue(r.Context(), apiTokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// contextGetAPIToken returns the token the request was authenticated with, nil for session authenticated requests.
+func contextGetAPIToken(r *http.Request) *database.ApiToken {
+	token, ok := r.Context().Value(apiTokenContextKey).(*database.ApiToken)
+	if !ok {
+		return nil
+	}
+
+	return token
+}
+
+func contextSetPermissions(r *http.Request, permissions permissionSet) *http.Request {
+	ctx := context.WithValue(r.Context(), permissionsContextKey, permissions)
+	return r.WithContext(ctx)
+}
+
+// contextGetPermissions returns the permissions of the authenticated user's role, an empty set for anonymous requests.
+func contextGetPermissions(r *http.Request) permissionSet {
+	permissions, ok := r.Context().Value(permissionsContextKey).(permissionSet)
+	if !ok {
+		return permissionSet{}
+	}
+
+	return permissions
+}
+
+func contextSetAccessScope(r *http.Request, scope accessScope) *http.Request {
+	ctx := context.WithValue(r.Context(), accessScopeContextKey, scope)
+	return r.WithContext(ctx)
+}
+
+// contextGetAccessScope returns the access scope of the authenticated user, an unrestricted one if none was set.
+func contextGetAccessScope(r *http.Request) accessScope {
+	scope, ok := r.Context().Value(accessScopeContextKey).(accessScope)
+	if !ok {
+		return accessScope{}
+	}
+
+	return scope
+}
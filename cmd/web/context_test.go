@@ -12,11 +12,11 @@ import (
 func TestContext(t *testing.T) {
 	t.Run("Context set/get user", func(t *testing.T) {
 		user := database.User{
-			ID:                 uuid.New(),
-			CreatedAt:          time.Now(),
-			Username:           "testUser",
-			HashedPassword:     "hashHere",
-			HasAdminPrivileges: true,
+			ID:             uuid.New(),
+			CreatedAt:      time.Now(),
+			Username:       "testUser",
+			HashedPassword: "hashHere",
+			Role:           roleAdmin,
 		}
 		req, err := http.NewRequest(http.MethodGet, "/users/"+user.Username, nil)
 		if err != nil {
@@ -26,7 +26,7 @@ func TestContext(t *testing.T) {
 		gotUser := contextGetAuthenticatedUser(req)
 		assert.Equal(t, user.Username, gotUser.Username)
 		assert.Equal(t, user.HashedPassword, gotUser.HashedPassword)
-		assert.Equal(t, user.HasAdminPrivileges, gotUser.HasAdminPrivileges)
+		assert.Equal(t, user.Role, gotUser.Role)
 		assert.Equal(t, user.ID, gotUser.ID)
 		assert.Equal(t, user.CreatedAt, gotUser.CreatedAt)
 	})
@@ -41,11 +41,11 @@ func TestContext(t *testing.T) {
 	})
 	t.Run("Context set/get user with nil request", func(t *testing.T) {
 		user := database.User{
-			ID:                 uuid.New(),
-			CreatedAt:          time.Now(),
-			Username:           "testUser",
-			HashedPassword:     "hashHere",
-			HasAdminPrivileges: true,
+			ID:             uuid.New(),
+			CreatedAt:      time.Now(),
+			Username:       "testUser",
+			HashedPassword: "hashHere",
+			Role:           roleAdmin,
 		}
 		req := contextSetAuthenticatedUser(nil, &user)
 		assert.Equal(t, req, nil)
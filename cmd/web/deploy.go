@@ -48,6 +48,8 @@ func (app *application) deploy(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
+	scope := contextGetAccessScope(r)
+	nodes = scope.nodes(nodes)
 	switch r.Method {
 	case http.MethodGet:
 		if hasSettings, err := app.DB.queries.CheckSettingsExist(ctxwt); err != nil {
@@ -83,6 +85,9 @@ func (app *application) deploy(w http.ResponseWriter, r *http.Request) {
 		formData.Validator.CheckField(validator.NotBlank(formData.ProjectName), "project_name", "You must provide a project name")
 		formData.Validator.CheckField(validator.NotBlank(formData.ProjectName), "project_location", "You must provide a project location")
 		formData.Validator.CheckField(len(formData.NodesToDeploy) != 0, "nodes_to_deploy", "You must provide at least one node to deploy")
+		for _, node := range formData.NodesToDeploy {
+			formData.Validator.CheckField(scope.Allows(formData.ProjectName, node), "nodes_to_deploy", fmt.Sprintf("You don't have access to project %s on node %s", formData.ProjectName, node))
+		}
 		if formData.Validator.HasErrors() {
 			data := app.newTemplateData(r)
 			data["Form"] = formData
@@ -169,7 +174,7 @@ func (app *application) buildAndDeployEggSSE(w http.ResponseWriter, r *http.Requ
 	jobs := make(chan string, numJobs)
 	results := make(chan addVersionResponse, numJobs)
 	for w := 0; w < app.config.workerCount; w++ {
-		go app.deployToNodeWorker(ctxwc, cookieData.ProjectName, cookieData.Version, egg, jobs, results)
+		go app.deployToNodeWorker(ctxwc, cookieData.ProjectName, cookieData.Version.String(), egg, jobs, results)
 	}
 
 	for _, node := range cookieData.Nodes {
@@ -191,7 +196,7 @@ func (app *application) buildAndDeployEggSSE(w http.ResponseWriter, r *http.Requ
 	app.writeSSEResponse(w, r, flusher, nil, deploymentDoneSSE, "deployment-complete", "sse:DeployDone")
 }
 
-func (app *application) deployToNodeWorker(ctx context.Context, project string, version uuid.UUID, egg []byte, jobs <-chan string, results chan<- addVersionResponse) {
+func (app *application) deployToNodeWorker(ctx context.Context, project, version string, egg []byte, jobs <-chan string, results chan<- addVersionResponse) {
 	defer func() {
 		err := recover()
 		if err != nil {
@@ -204,7 +209,7 @@ func (app *application) deployToNodeWorker(ctx context.Context, project string,
 		writer := multipart.NewWriter(form)
 		fieldsToWrite := map[string]string{
 			"project": project,
-			"version": version.String(),
+			"version": version,
 		}
 
 		for fieldName, fieldValue := range fieldsToWrite {
@@ -3,9 +3,11 @@ package main
 import (
 	"fmt"
 	"github.com/blazskufca/goscrapyd/internal/response"
+	"github.com/blazskufca/goscrapyd/internal/validator"
 	"log/slog"
 	"net/http"
 	"runtime/debug"
+	"strings"
 )
 
 func (app *application) reportServerError(r *http.Request, err error) {
@@ -94,3 +96,87 @@ func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	}
 
 }
+
+type apiError struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+type apiErrorEnvelope struct {
+	Error apiError `json:"error"`
+}
+
+func (app *application) apiErrorResponse(w http.ResponseWriter, r *http.Request, status int, message string, fields map[string]string) {
+	err := response.JSON(w, status, apiErrorEnvelope{Error: apiError{
+		Status:  status,
+		Message: message,
+		Fields:  fields,
+	}})
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (app *application) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.reportServerError(r, err)
+	app.apiErrorResponse(w, r, http.StatusInternalServerError, "The server encountered a problem and could not process your request", nil)
+}
+
+func (app *application) apiBadRequest(w http.ResponseWriter, r *http.Request, err error) {
+	app.apiErrorResponse(w, r, http.StatusBadRequest, err.Error(), nil)
+}
+
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.apiErrorResponse(w, r, http.StatusNotFound, "The requested resource could not be found", nil)
+}
+
+func (app *application) apiFailedValidation(w http.ResponseWriter, r *http.Request, v validator.Validator) {
+	message := "The request contains invalid fields"
+	if len(v.Errors) != 0 {
+		message = strings.Join(v.Errors, "; ")
+	}
+	app.apiErrorResponse(w, r, http.StatusUnprocessableEntity, message, v.FieldErrors)
+}
+
+func (app *application) apiAuthenticationRequired(w http.ResponseWriter, r *http.Request) {
+	app.apiErrorResponse(w, r, http.StatusUnauthorized, "You must be authenticated to access this resource", nil)
+}
+
+func (app *application) apiNotPermitted(w http.ResponseWriter, r *http.Request) {
+	app.apiErrorResponse(w, r, http.StatusForbidden, "Your user account doesn't have the necessary permissions to access this resource", nil)
+}
+
+func (app *application) apiRateLimitExceeded(w http.ResponseWriter, r *http.Request) {
+	app.apiErrorResponse(w, r, http.StatusTooManyRequests, "Rate limit exceeded", nil)
+}
+
+func (app *application) apiBadGateway(w http.ResponseWriter, r *http.Request, err error) {
+	app.reportServerError(r, err)
+	app.apiErrorResponse(w, r, http.StatusBadGateway, err.Error(), nil)
+}
+
+// scrapydErrorResponse answers Scrapyd-compatible endpoints in Scrapyd's own error shape, which clients like
+// scrapyd-client know how to print.
+func (app *application) scrapydErrorResponse(w http.ResponseWriter, r *http.Request, status int, message string) {
+	err := response.JSON(w, status, scrapydFacadeError{
+		NodeName: scrapydFacadeNodeName(),
+		Status:   "error",
+		Message:  message,
+	})
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (app *application) scrapydServerError(w http.ResponseWriter, r *http.Request, err error) {
+	app.reportServerError(r, err)
+	app.scrapydErrorResponse(w, r, http.StatusInternalServerError, "The server encountered a problem and could not process your request")
+}
+
+func (app *application) scrapydBadGateway(w http.ResponseWriter, r *http.Request, err error) {
+	app.reportServerError(r, err)
+	app.scrapydErrorResponse(w, r, http.StatusBadGateway, err.Error())
+}
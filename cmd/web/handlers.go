@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
 	"log/slog"
+	"maps"
 	"math"
 	"net/http"
 	"net/url"
@@ -19,6 +21,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type templateName string
@@ -56,6 +59,20 @@ const (
 	versionsPage           templateName = "versions.tmpl"
 	versionsPageHtmx       templateName = "htmx_versions.tmpl"
 	metricsPage            templateName = "metrics.tmpl"
+	apiTokensPage          templateName = "api_tokens.tmpl"
+	apiDocsPage            templateName = "api_docs.tmpl"
+	htmxTaskWebhook        templateName = "htmx_task_webhook.tmpl"
+	accessGrantsPage       templateName = "access_grants.tmpl"
+	twoFactorSettingsPage  templateName = "two_factor.tmpl"
+	loginTwoFactorPage     templateName = "login_two_factor.tmpl"
+	workflowsPage          templateName = "workflows.tmpl"
+	nodeGroupsPage         templateName = "node_groups.tmpl"
+	blackoutCalendarsPage  templateName = "blackout_calendars.tmpl"
+	htmxSchedulePreview    templateName = "htmx_schedule_preview.tmpl"
+	scheduledRunEditPage   templateName = "scheduled_run_edit.tmpl"
+	taskRunsPage           templateName = "task_runs.tmpl"
+	taskRevisionsPage      templateName = "task_revisions.tmpl"
+	maintenancePage        templateName = "maintenance.tmpl"
 )
 
 // Other various misc strings
@@ -80,6 +97,7 @@ const (
 
 var (
 	errScrapydTableUniqueConstraint error = errors.New("UNIQUE constraint failed: scrapyd_nodes.nodeName, scrapyd_nodes.URL")
+	errNoOnlineNodes                error = errors.New("no Scrapyd node is online")
 )
 
 type listScrapydNodesType struct {
@@ -87,18 +105,31 @@ type listScrapydNodesType struct {
 	Pending  int
 	Running  int
 	Finished int
+	// Capacity is 0 when the node has none set
+	Capacity int
 	Name     string
 	URL      string
 	Status   string
 	Error    error
 }
 
+// load is the share of its capacity a node uses, nodes without a capacity count as a capacity of 1.
+func (n listScrapydNodesType) load() float64 {
+	return float64(n.Running+n.Pending) / float64(max(n.Capacity, 1))
+}
+
 type scrapydListProjects struct {
 	NodeName string   `json:"node_name"`
 	Status   string   `json:"status"`
 	Projects []string `json:"projects"`
 }
 
+type scrapydCancelResponse struct {
+	NodeName  string `json:"node_name"`
+	Status    string `json:"status"`
+	Prevstate string `json:"prevstate"`
+}
+
 type scrapydListSpidersResponse struct {
 	NodeName string   `json:"node_name"`
 	Status   string   `json:"status"`
@@ -114,9 +145,15 @@ type editAddScrapydNode struct {
 	URL       string              `form:"url"`
 	Username  *string             `form:"username"`
 	Password  *string             `form:"password"`
+	Capacity  *int                `form:"capacity"`
 	Validator validator.Validator `form:"-"`
 }
 
+// validateNodeCapacity allows no capacity, nodes without one count as a capacity of 1 when picking the least-loaded node.
+func validateNodeCapacity(v *validator.Validator, field string, capacity *int) {
+	v.CheckField(capacity == nil || *capacity > 0, field, "Capacity must be at least 1 job")
+}
+
 func (app *application) insertNewScrapydNode(w http.ResponseWriter, r *http.Request) {
 	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
 	defer cancel()
@@ -134,6 +171,7 @@ func (app *application) insertNewScrapydNode(w http.ResponseWriter, r *http.Requ
 		fd.Validator.CheckField(validator.NotBlank(fd.NodeName), "nodeName", "You must provide a name for this node")
 		fd.Validator.CheckField(validator.NotBlank(fd.URL), "URL", "You must provide a URL for this node")
 		fd.Validator.CheckField(validator.IsURL(fd.URL), "URL", "Node URL must be a valid URL")
+		validateNodeCapacity(&fd.Validator, "capacity", fd.Capacity)
 		if fd.Validator.HasErrors() {
 			data := app.newTemplateData(r)
 			data["Form"] = fd
@@ -151,6 +189,7 @@ func (app *application) insertNewScrapydNode(w http.ResponseWriter, r *http.Requ
 			Nodename: fd.NodeName,
 			Url:      fd.URL,
 			Username: database.CreateSqlNullString(fd.Username),
+			Capacity: database.CreateSqlNullInt64FromInt(fd.Capacity),
 		}
 		if fd.Username != nil && validator.NotBlank(*fd.Username) && fd.Password != nil {
 			encryptedPassword, err := encrypt(*fd.Password, app.config.ScrapydEncryptSecret)
@@ -195,26 +234,9 @@ func (app *application) listScrapydNodes(w http.ResponseWriter, r *http.Request)
 		app.serverError(w, r, err)
 		return
 	}
-	var workerResults []listScrapydNodesType
-	numJobs := len(nodes)
-	jobs := make(chan database.ScrapydNode, numJobs)
-	results := make(chan listScrapydNodesType, numJobs)
-	for w := 0; w <= app.config.workerCount; w++ {
-		go app.listScrapydNodesWorkerFunc(ctxwt, r, jobs, results)
-	}
-	for _, job := range nodes {
-		jobs <- job
-	}
-	close(jobs)
-	for range numJobs {
-		workerResults = append(workerResults, <-results)
-	}
-	close(results)
+	nodes = contextGetAccessScope(r).nodes(nodes)
 	data := app.newTemplateData(r)
-	// Resort the result of async workers
-	// I don't know if it even makes sense to requests async and then do addition work sorting it back
-	// I guess it almost certainly does since requests are way more expensive but I don't really like I'm doing extra work after the fact
-	data["Nodes"] = sortScrapydNodes(workerResults)
+	data["Nodes"] = app.scrapydNodesStatus(ctxwt, r, nodes)
 	app.render(w, r, http.StatusOK, listNodesPage, nil, data)
 }
 
@@ -237,8 +259,14 @@ func (app *application) nodeJobs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	offset := (page - 1) * pageSize
+	grants, err := contextGetAccessScope(r).queryGrants()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	jobs, err := app.DB.queries.GetJobsForNode(ctxwt, database.GetJobsForNodeParams{
 		Node:   r.PathValue("node"),
+		Grants: grants,
 		Limit:  int64(pageSize),
 		Offset: int64(offset),
 	})
@@ -246,23 +274,34 @@ func (app *application) nodeJobs(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
-	totalNumberOfJobs, err := app.DB.queries.GetTotalJobCountForNode(ctxwt, r.PathValue("node"))
+	totalNumberOfJobs, err := app.DB.queries.GetTotalJobCountForNode(ctxwt, database.GetTotalJobCountForNodeParams{
+		Node:   r.PathValue("node"),
+		Grants: grants,
+	})
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 	totalPages := int(math.Ceil(float64(totalNumberOfJobs) / float64(pageSize)))
-	var errored, pending, running, finished []database.GetJobsForNodeRow
+	var errored, retrying, queued, skipped, pending, running, finished, timedOut []database.GetJobsForNodeRow
 	for _, job := range jobs {
-		switch job.Status {
-		case "error":
+		switch {
+		case job.Status == "error":
 			errored = append(errored, job)
-		case "pending":
+		case job.Status == "scheduled" && job.NextRetryAt.Valid:
+			retrying = append(retrying, job)
+		case job.Status == "queued":
+			queued = append(queued, job)
+		case job.Status == "skipped":
+			skipped = append(skipped, job)
+		case job.Status == "pending":
 			pending = append(pending, job)
-		case "running":
+		case job.Status == "running":
 			running = append(running, job)
-		case "finished":
+		case job.Status == "finished":
 			finished = append(finished, job)
+		case job.Status == "timed_out":
+			timedOut = append(timedOut, job)
 		}
 	}
 	paginationPages := make([]int, totalPages)
@@ -271,9 +310,13 @@ func (app *application) nodeJobs(w http.ResponseWriter, r *http.Request) {
 	}
 	data := app.newTemplateData(r)
 	data["ErrorJobs"] = errored
+	data["RetryingJobs"] = retrying
+	data["QueuedJobs"] = queued
+	data["SkippedJobs"] = skipped
 	data["PendingJobs"] = pending
 	data["RunningJobs"] = running
 	data["FinishedJobs"] = finished
+	data["TimedOutJobs"] = timedOut
 	data["NodeName"] = r.PathValue("node")
 	data["CurrentPage"] = page
 	data["TotalPages"] = totalPages
@@ -311,6 +354,8 @@ func (app *application) fireSpider(w http.ResponseWriter, r *http.Request) {
 		app.logger.ErrorContext(ctxwt, "failed to list nodes", slog.Any("error", err))
 		return
 	}
+	scope := contextGetAccessScope(r)
+	nodes = scope.nodes(nodes)
 	preconfiguredSettings, err := app.getPreconfiguredSettings(ctxwt)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -328,6 +373,8 @@ func (app *application) fireSpider(w http.ResponseWriter, r *http.Request) {
 			Spider    string              `form:"spider"`
 			Version   string              `form:"_version"`
 			Node      []string            `form:"fireNode"`
+			RunAt     string              `form:"run_at"`
+			Timezone  string              `form:"run_at_timezone"`
 			Validator validator.Validator `form:"-"`
 		}{}
 		err := request.DecodePostForm(r, &fullQuery)
@@ -338,6 +385,22 @@ func (app *application) fireSpider(w http.ResponseWriter, r *http.Request) {
 		fullQuery.Validator.CheckField(validator.NotBlank(fullQuery.Project), "project", "project can not be blank")
 		fullQuery.Validator.CheckField(validator.NotBlank(fullQuery.Spider), "spider", "spider can not be blank")
 		fullQuery.Validator.CheckField(len(fullQuery.Node) != 0, "node", "Select at least one node")
+		for _, node := range fullQuery.Node {
+			fullQuery.Validator.CheckField(scope.Allows(fullQuery.Project, node), "node", fmt.Sprintf("You don't have access to project %s on node %s", fullQuery.Project, node))
+		}
+		validateSpiderArgs(&fullQuery.Validator, "spider_args", cleanUrlValues(maps.Clone(r.Form), scheduledRunFormFields...))
+		fullQuery.Timezone = strings.TrimSpace(fullQuery.Timezone)
+		var runAt time.Time
+		if strings.TrimSpace(fullQuery.RunAt) != "" {
+			runAt = validateRunAt(&fullQuery.Validator, fullQuery.RunAt, fullQuery.Timezone, time.Now())
+		} else {
+			refusal, err := app.maintenanceRefusal(ctxwt, r)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			fullQuery.Validator.CheckField(refusal == "", "maintenance", refusal)
+		}
 		if fullQuery.Validator.HasErrors() {
 			data := app.newTemplateData(r)
 			data["Form"] = fullQuery
@@ -346,7 +409,17 @@ func (app *application) fireSpider(w http.ResponseWriter, r *http.Request) {
 			app.render(w, r, http.StatusUnprocessableEntity, fireSpiderPage, nil, data)
 			return
 		}
-		cleanForm := cleanUrlValues(r.Form, "fireNode", "csrf_token")
+		cleanForm := cleanUrlValues(r.Form, scheduledRunFormFields...)
+		if !runAt.IsZero() {
+			_, err = app.createScheduledRun(ctxwt, fullQuery.Project, fullQuery.Spider, cleanForm, runAt, fullQuery.Timezone,
+				fullQuery.Node, contextGetAuthenticatedUser(r))
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, "/list-tasks", http.StatusSeeOther)
+			return
+		}
 		type OneTimeFireResult struct {
 			gocron.Job
 			Node  string
@@ -361,6 +434,7 @@ func (app *application) fireSpider(w http.ResponseWriter, r *http.Request) {
 			if app.checkCreateTaskError(w, r, currentTask, err) {
 				return
 			}
+			currentTask.overrideMaintenance = true
 			cronJob, err := currentTask.newOneTimeJob()
 			if err != nil {
 				jobResult.Error = err
@@ -383,6 +457,11 @@ func (app *application) htmxFireForm(w http.ResponseWriter, r *http.Request) {
 		app.badRequest(w, r, errors.New("node id not supplied"))
 		return
 	}
+	scope := contextGetAccessScope(r)
+	if !scope.Allows(q.Get("project"), q.Get("node")) {
+		app.notPermittedResponse(w, r)
+		return
+	}
 	tempData := app.newTemplateData(r)
 	switch {
 	case q.Has("node") && !q.Has("project"):
@@ -399,7 +478,9 @@ func (app *application) htmxFireForm(w http.ResponseWriter, r *http.Request) {
 			app.serverError(w, r, err)
 			return
 		}
-		tempData["Values"] = scrapydProjects.Projects
+		tempData["Values"] = slices.DeleteFunc(scrapydProjects.Projects, func(project string) bool {
+			return !scope.Allows(project, q.Get("node"))
+		})
 		tempData["Placeholder"] = "Select a Project"
 	case q.Has("node") && q.Has("project"):
 		req, err := makeRequestToScrapyd(ctxwt, app.DB.queries, http.MethodGet, q.Get("node"), func(url *url.URL) *url.URL {
@@ -460,6 +541,7 @@ func (app *application) editNode(w http.ResponseWriter, r *http.Request) {
 		}
 		form.Username = database.ReadSqlNullString(node.Username)
 		form.NodeName = node.Nodename
+		form.Capacity = database.ReadSqlNullInt64AsInt(node.Capacity)
 		templateData["Form"] = form
 		app.render(w, r, http.StatusOK, nodeEditPage, nil, templateData)
 	case http.MethodPost:
@@ -471,6 +553,7 @@ func (app *application) editNode(w http.ResponseWriter, r *http.Request) {
 		form.Validator.CheckField(validator.NotBlank(form.NodeName), "nodeName", "You must provide a name for this node")
 		form.Validator.CheckField(validator.NotBlank(form.URL), "URL", "You must provide a URL for this node")
 		form.Validator.CheckField(validator.IsURL(form.URL), "URL", "Node URL must be a valid URL")
+		validateNodeCapacity(&form.Validator, "capacity", form.Capacity)
 		if form.Validator.HasErrors() {
 			data := app.newTemplateData(r)
 			data["Form"] = form
@@ -488,6 +571,7 @@ func (app *application) editNode(w http.ResponseWriter, r *http.Request) {
 			NewNodeName: form.NodeName,
 			NewURL:      form.URL,
 			NewUsername: database.CreateSqlNullString(form.Username),
+			NewCapacity: database.CreateSqlNullInt64FromInt(form.Capacity),
 			OldNodeName: r.PathValue("node"),
 		}
 		if form.Username != nil && validator.NotBlank(*form.Username) && form.Password != nil && validator.NotBlank(*form.Password) {
@@ -515,80 +599,73 @@ func (app *application) htmxListOnlineNodes(w http.ResponseWriter, r *http.Reque
 		app.serverError(w, r, err)
 		return
 	}
+	nodes = contextGetAccessScope(r).nodes(nodes)
 	var workerResults []listScrapydNodesType
-	numJobs := len(nodes)
-	jobs := make(chan database.ScrapydNode, numJobs)
-	results := make(chan listScrapydNodesType, numJobs)
-	for w := 0; w <= app.config.workerCount; w++ {
-		go app.listScrapydNodesWorkerFunc(ctxwt, r, jobs, results)
-	}
-	for _, job := range nodes {
-		jobs <- job
-	}
-	close(jobs)
-	for range numJobs {
-		if workResult := <-results; workResult.Error == nil {
+	for _, workResult := range app.scrapydNodesStatus(ctxwt, r, nodes) {
+		if workResult.Error == nil {
 			workerResults = append(workerResults, workResult)
 		} else {
 			app.logger.Debug("Not sending node to jobs dropdown", slog.Any("node", workResult.Name), slog.Any("because it has the following error", workResult.Error))
 		}
-
 	}
-	close(results)
 	templateData := app.newTemplateData(r)
-	templateData["Nodes"] = sortScrapydNodes(workerResults)
+	templateData["Nodes"] = workerResults
 	app.renderHTMX(w, r, http.StatusOK, htmxListNodes, nil, "htmx:list_of_nodes", templateData)
 }
 
 func (app *application) stopJob(w http.ResponseWriter, r *http.Request) {
 	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
 	defer cancel()
-	type CancelResponse struct {
-		NodeName  string `json:"node_name"`
-		Status    string `json:"status"`
-		Prevstate string `json:"prevstate"`
+	_, err := app.cancelScrapydJob(ctxwt, r.PathValue("node"), r.PathValue("project"), r.PathValue("job"), "", contextGetAuthenticatedUser(r))
+	if err != nil {
+		app.reportServerError(r, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
 	}
-	req, err := makeRequestToScrapyd(ctxwt, app.DB.queries, http.MethodPost, r.PathValue("node"), func(blankUlr *url.URL) *url.URL {
+	w.WriteHeader(http.StatusOK)
+}
+
+// cancelScrapydJob calls cancel.json on the node and records who stopped the job. An empty signal leaves the choice to
+// Scrapyd.
+func (app *application) cancelScrapydJob(ctx context.Context, node, project, job, signal string, user *database.User) (scrapydCancelResponse, error) {
+	req, err := makeRequestToScrapyd(ctx, app.DB.queries, http.MethodPost, node, func(blankUlr *url.URL) *url.URL {
 		query := blankUlr.Query()
-		query.Add("project", r.PathValue("project"))
-		query.Add("job", r.PathValue("job"))
+		query.Add("project", project)
+		query.Add("job", job)
+		if signal != "" {
+			query.Add("signal", signal)
+		}
 		blankUlr.Path = path.Join(blankUlr.Path, scrapydStopSpider)
 		blankUlr.RawQuery = query.Encode()
 		return blankUlr
 	}, nil, nil, app.config.ScrapydEncryptSecret)
 	if err != nil {
-		app.reportServerError(r, err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return scrapydCancelResponse{}, err
 	}
-	response, err := requestJSONResourceFromScrapyd[CancelResponse](req, app.logger)
+	response, err := requestJSONResourceFromScrapyd[scrapydCancelResponse](req, app.logger)
 	if err != nil {
-		app.reportServerError(r, err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return scrapydCancelResponse{}, err
 	}
 	if strings.ToLower(strings.TrimSpace(response.Status)) != "ok" {
-		app.reportServerError(r, errors.New(response.Status))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return response, errors.New(response.Status)
 	}
-
 	queryParams := database.SetStoppedByOnJobParams{
-		Job:     r.PathValue("job"),
-		Project: r.PathValue("project"),
-		Node:    r.PathValue("node"),
+		Job:     job,
+		Project: project,
+		Node:    node,
 	}
-	if user := contextGetAuthenticatedUser(r); user != nil {
+	if user != nil {
 		queryParams.StoppedBy = user.ID
 	}
-
-	err = app.DB.queries.SetStoppedByOnJob(ctxwt, queryParams)
+	err = app.DB.queries.SetStoppedByOnJob(ctx, queryParams)
 	if err != nil {
-		app.reportServerError(r, err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return response, err
 	}
-	w.WriteHeader(http.StatusOK)
+	return response, nil
 }
 
 func (app *application) searchJobs(w http.ResponseWriter, r *http.Request) {
@@ -601,33 +678,52 @@ func (app *application) searchJobs(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	grants, err := contextGetAccessScope(r).queryGrants()
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 	searchResults, err := app.DB.queries.SearchNodeJobs(ctxwt, database.SearchNodeJobsParams{
 		SearchTerm: searchForm.SearchTerm,
 		Node:       r.PathValue("node"),
+		Grants:     grants,
 	})
 	if err != nil {
 		app.reportServerError(r, err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	var errored, pending, running, finished []database.SearchNodeJobsRow
+	var errored, retrying, queued, skipped, pending, running, finished, timedOut []database.SearchNodeJobsRow
 	for _, job := range searchResults {
-		switch job.Status {
-		case "error":
+		switch {
+		case job.Status == "error":
 			errored = append(errored, job)
-		case "pending":
+		case job.Status == "scheduled" && job.NextRetryAt.Valid:
+			retrying = append(retrying, job)
+		case job.Status == "queued":
+			queued = append(queued, job)
+		case job.Status == "skipped":
+			skipped = append(skipped, job)
+		case job.Status == "pending":
 			pending = append(pending, job)
-		case "running":
+		case job.Status == "running":
 			running = append(running, job)
-		case "finished":
+		case job.Status == "finished":
 			finished = append(finished, job)
+		case job.Status == "timed_out":
+			timedOut = append(timedOut, job)
 		}
 	}
 	data := app.newTemplateData(r)
 	data["ErrorJobs"] = errored
+	data["RetryingJobs"] = retrying
+	data["QueuedJobs"] = queued
+	data["SkippedJobs"] = skipped
 	data["PendingJobs"] = pending
 	data["RunningJobs"] = running
 	data["FinishedJobs"] = finished
+	data["TimedOutJobs"] = timedOut
 	data["NodeName"] = r.PathValue("node")
 	app.renderHTMX(w, r, http.StatusOK, htmxJobsTable, nil, "htmx:jobsTable", data)
 }
@@ -641,7 +737,7 @@ func (app *application) listVersions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	templateData := app.newTemplateData(r)
-	templateData["Nodes"] = nodes
+	templateData["Nodes"] = contextGetAccessScope(r).nodes(nodes)
 	app.render(w, r, http.StatusOK, versionsPage, nil, templateData)
 }
 
@@ -659,6 +755,10 @@ func (app *application) listVersionsHTMX(w http.ResponseWriter, r *http.Request)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if !contextGetAccessScope(r).Allows(project, node) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
 	req, err := makeRequestToScrapyd(ctxwt, app.DB.queries, http.MethodGet, node, func(url *url.URL) *url.URL {
 		url.Path = path.Join(url.Path, scrapydListVersions)
 		query := url.Query()
@@ -737,33 +837,51 @@ func (app *application) importScrapydWebTimeTasksExport(w http.ResponseWriter, r
 				continue
 			}
 		}
-		for _, node := range nodes {
-			randomTaskId, err := uuid.NewRandom()
-			if err != nil {
-				hadErrors = true
-				app.reportServerError(r, err)
-				continue
-			}
-			taskName := row["name"]
-			importedTask, err := app.DB.queries.InsertTask(ctxwc, database.InsertTaskParams{
-				Name:              database.CreateSqlNullString(&taskName),
-				ID:                randomTaskId,
-				Project:           row["project"],
-				Spider:            row["spider"],
-				Jobid:             taskName,
-				SettingsArguments: urlValues.Encode(),
-				SelectedNodes:     node,
-				CronString:        constructedCronString,
-				Paused:            true,
-				CreatedBy:         contextGetAuthenticatedUser(r).ID,
-			})
-			if err != nil {
-				hadErrors = true
-				app.reportServerError(r, err)
-				continue
-			}
-			successfullyImported = append(successfullyImported, importedTask)
+		if len(nodes) == 0 {
+			continue
+		}
+		randomTaskId, err := uuid.NewRandom()
+		if err != nil {
+			hadErrors = true
+			app.reportServerError(r, err)
+			continue
+		}
+		taskName := row["name"]
+		queryParams := database.InsertTaskParams{
+			Name:              database.CreateSqlNullString(&taskName),
+			ID:                randomTaskId,
+			Project:           row["project"],
+			Spider:            row["spider"],
+			Jobid:             taskName,
+			SettingsArguments: urlValues.Encode(),
+			CronString:        constructedCronString,
+			Paused:            true,
+			CreatedBy:         contextGetAuthenticatedUser(r).ID,
+			OverlapPolicy:     overlapAllow,
+			FanOut:            fanOutAll,
+		}
+		setInsertTaskRetryPolicy(&queryParams, defaultRetryPolicy())
+		setInsertTaskMisfirePolicy(&queryParams, defaultMisfirePolicy())
+		importedTask, err := app.DB.queries.InsertTask(ctxwc, queryParams)
+		if err != nil {
+			hadErrors = true
+			app.reportServerError(r, err)
+			continue
+		}
+		// ScrapydWeb keeps one task for all of its nodes, so does goscrapyd
+		err = app.setTaskTargets(ctxwc, importedTask.ID, nodes, nil)
+		if err != nil {
+			hadErrors = true
+			app.reportServerError(r, err)
+			continue
+		}
+		err = app.recordTaskRevision(ctxwc, importedTask.ID, contextGetAuthenticatedUser(r), 0)
+		if err != nil {
+			hadErrors = true
+			app.reportServerError(r, err)
+			continue
 		}
+		successfullyImported = append(successfullyImported, importedTask)
 	}
 	if hadErrors {
 		templateData["ParagraphText"] = fmt.Sprintf("Imported %d tasks(s)! Some failed to import see logs for more info", len(successfullyImported))
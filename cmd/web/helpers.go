@@ -27,6 +27,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -39,10 +40,21 @@ type scrapydDaemonStatusResponse struct {
 }
 
 func (app *application) newTemplateData(r *http.Request) map[string]any {
+	user := contextGetAuthenticatedUser(r)
 	data := map[string]any{
-		"AuthenticatedUser": contextGetAuthenticatedUser(r),
+		"AuthenticatedUser": user,
+		"Can":               contextGetPermissions(r),
 		"Token":             nosurf.Token(r),
 		"Version":           version.Get(),
+		"Maintenance":       (*database.GetMaintenanceModeRow)(nil),
+	}
+	// Every page shows a banner while maintenance mode is on
+	if user != nil {
+		maintenance, err := app.activeMaintenance(r.Context())
+		if err != nil {
+			app.logger.Error("error looking up maintenance mode", slog.Any("err", err))
+		}
+		data["Maintenance"] = maintenance
 	}
 
 	return data
@@ -118,6 +130,15 @@ func makeRequestToScrapyd(ctx context.Context, DB *database.Queries, method, nod
 	return madeReq, err
 }
 
+// scrapydStatusCodeError is returned by requestJSONResourceFromScrapyd when Scrapyd doesn't answer with 200 OK.
+type scrapydStatusCodeError struct {
+	StatusCode int
+}
+
+func (e *scrapydStatusCodeError) Error() string {
+	return fmt.Sprintf("request returned status code %d", e.StatusCode)
+}
+
 func requestJSONResourceFromScrapyd[T any](req *http.Request, logger *slog.Logger) (T, error) {
 	var JSONResponse T
 	response, err := http.DefaultClient.Do(req)
@@ -136,7 +157,7 @@ func requestJSONResourceFromScrapyd[T any](req *http.Request, logger *slog.Logge
 	}()
 
 	if response.StatusCode != http.StatusOK {
-		return JSONResponse, fmt.Errorf("request returned status code %d", response.StatusCode)
+		return JSONResponse, &scrapydStatusCodeError{StatusCode: response.StatusCode}
 	}
 	rawBody, err := io.ReadAll(response.Body)
 	if err != nil {
@@ -154,7 +175,7 @@ func (app *application) listScrapydNodesWorkerFunc(ctx context.Context, r *http.
 	defer func() {
 		err := recover()
 		if err != nil {
-			app.reportServerError(r, fmt.Errorf("%s", err))
+			app.reportNodeStatusError(r, fmt.Errorf("%s", err))
 		}
 	}()
 	for node := range jobs {
@@ -162,20 +183,21 @@ func (app *application) listScrapydNodesWorkerFunc(ctx context.Context, r *http.
 		workResult.Name = node.Nodename
 		workResult.URL = node.Url
 		workResult.Id = node.ID
+		workResult.Capacity = int(node.Capacity.Int64)
 		req, err := makeRequestToScrapyd(ctx, app.DB.queries, http.MethodGet, node.Nodename, func(url *url.URL) *url.URL {
 			url.Path = path.Join(url.Path, scrapydDaemonStatusReq)
 			return url
 		}, nil, nil, app.config.ScrapydEncryptSecret)
 		if err != nil {
 			workResult.Error = fmt.Errorf("failed to create request: %w", err)
-			app.reportServerError(r, err)
+			app.reportNodeStatusError(r, err)
 			resultChan <- workResult
 			continue
 		}
 		scrapydDaemonStatus, err := requestJSONResourceFromScrapyd[scrapydDaemonStatusResponse](req, app.logger)
 		if err != nil {
 			workResult.Error = fmt.Errorf("request failed: %w", err)
-			app.reportServerError(r, err)
+			app.reportNodeStatusError(r, err)
 			resultChan <- workResult
 			continue
 		}
@@ -187,6 +209,58 @@ func (app *application) listScrapydNodesWorkerFunc(ctx context.Context, r *http.
 	}
 }
 
+// reportNodeStatusError reports a failed status request. Fires pick their node outside of any request, r is nil then and
+// the error is only logged.
+func (app *application) reportNodeStatusError(r *http.Request, err error) {
+	if r == nil {
+		app.logger.Warn("node status request failed", slog.Any("err", err))
+		return
+	}
+	app.reportServerError(r, err)
+}
+
+// scrapydNodesStatus requests daemonstatus.json from every node concurrently. The result is in the same order as nodes are
+// listed in the UI, unreachable nodes have Error set.
+func (app *application) scrapydNodesStatus(ctx context.Context, r *http.Request, nodes []database.ScrapydNode) []listScrapydNodesType {
+	var workerResults []listScrapydNodesType
+	numJobs := len(nodes)
+	jobs := make(chan database.ScrapydNode, numJobs)
+	results := make(chan listScrapydNodesType, numJobs)
+	for w := 0; w <= app.config.workerCount; w++ {
+		go app.listScrapydNodesWorkerFunc(ctx, r, jobs, results)
+	}
+	for _, job := range nodes {
+		jobs <- job
+	}
+	close(jobs)
+	for range numJobs {
+		workerResults = append(workerResults, <-results)
+	}
+	close(results)
+	// Resort the result of async workers
+	// I don't know if it even makes sense to requests async and then do addition work sorting it back
+	// I guess it almost certainly does since requests are way more expensive but I don't really like I'm doing extra work after the fact
+	return sortScrapydNodes(workerResults)
+}
+
+// leastLoadedNode picks the online node with the fewest running and pending jobs for its capacity. Ties go to the node
+// listed first. r may be nil outside of a request.
+func (app *application) leastLoadedNode(ctx context.Context, r *http.Request, nodes []database.ScrapydNode) (string, error) {
+	var best *listScrapydNodesType
+	for _, status := range app.scrapydNodesStatus(ctx, r, nodes) {
+		if status.Error != nil || strings.ToLower(strings.TrimSpace(status.Status)) != "ok" {
+			continue
+		}
+		if best == nil || status.load() < best.load() {
+			best = &status
+		}
+	}
+	if best == nil {
+		return "", errNoOnlineNodes
+	}
+	return best.Name, nil
+}
+
 func cleanUrlValues(urlValues url.Values, keysToRemove ...string) url.Values {
 	for _, key := range keysToRemove {
 		if urlValues.Has(key) {
@@ -227,6 +301,7 @@ func (app *application) deleteTaskFromScheduler(ctx context.Context, uuidString
 	if err != nil {
 		return err
 	}
+	app.cluster.markRegistered(uuidStringAsUUID)
 	err = app.scheduler.RemoveJob(uuidStringAsUUID)
 	if err != nil {
 		return err
@@ -242,34 +317,78 @@ func (app *application) loadTasksOnStart() error {
 	if err != nil {
 		return err
 	}
+	now := time.Now()
 	for _, task := range tasks {
 		if task.Paused {
 			continue
 		}
-		values, err := url.ParseQuery(task.SettingsArguments)
+		// The missed fires are worked out before the task is registered, which records it as caught up
+		missed, err := misfiresToCatchUp(task, now)
 		if err != nil {
-			app.logger.Error("Error parsing query:", slog.Any("err", err))
+			app.logger.Error("Error loading task:", slog.Any("err", err))
 			return err
 		}
-		var nameStr string
-		if task.Name.Valid {
-			nameStr = task.Name.String
-		}
-		createdTask, err := app.newTask(false, &task.ID, nameStr, task.Spider, task.Project, task.SelectedNodes, values, nil)
+		createdTask, err := app.taskFromDb(task)
 		if err != nil {
+			app.logger.Error("Error loading task:", slog.Any("err", err))
 			return err
-		} else if createdTask == nil {
-			return errors.New("failed to load task")
 		}
 		cronJob, err := createdTask.newCronJob(task.CronString)
 		if err != nil {
+			app.logger.Error("Error loading task:", slog.Any("err", err))
 			return err
 		}
 		app.logger.Info("loaded task", slog.Any("name", cronJob.Name()), slog.Any("id", cronJob.ID()))
+		// With -ha catching up is up to the leader, an instance elected later catches up in catchUpAfterFailover
+		if len(missed) > 0 && app.cluster.isLeader() {
+			app.logger.Info("catching up on missed fires", slog.Any("name", cronJob.Name()), slog.Int("missed", len(missed)))
+			go createdTask.catchUpMisfires(missed)
+		}
 	}
 	return nil
 }
 
+// scheduleTask registers a task, as it's stored in the database, with gocron.
+func (app *application) scheduleTask(taskDb database.Task) (gocron.Job, error) {
+	createdTask, err := app.taskFromDb(taskDb)
+	if err != nil {
+		return nil, err
+	}
+	return createdTask.newCronJob(taskDb.CronString)
+}
+
+// rescheduleTask replaces the registered job of taskDb with one built from the row as it is now.
+func (app *application) rescheduleTask(taskDb database.Task) (gocron.Job, error) {
+	replacedTask, err := app.taskFromDb(taskDb)
+	if err != nil {
+		return nil, err
+	}
+	return replacedTask.updatesResource(taskDb.ID, taskDb.CronString)
+}
+
+// taskFromDb builds the task which fires taskDb on its schedule.
+func (app *application) taskFromDb(taskDb database.Task) (*task, error) {
+	values, err := url.ParseQuery(taskDb.SettingsArguments)
+	if err != nil {
+		return nil, err
+	}
+	var nameStr string
+	if taskDb.Name.Valid {
+		nameStr = taskDb.Name.String
+	}
+	createdTask, err := app.newTask(false, &taskDb.ID, nameStr, taskDb.Spider, taskDb.Project, "", values, nil)
+	if err != nil {
+		return nil, err
+	} else if createdTask == nil {
+		return nil, errors.New("failed to load task")
+	}
+	createdTask.Retry = retryPolicyFromTask(taskDb)
+	createdTask.Overlap = taskDb.OverlapPolicy
+	createdTask.FanOut = taskDb.FanOut
+	createdTask.Timezone = taskDb.Timezone
+	return createdTask, nil
+}
+
 func stringListToUUIDList(list []string) ([]uuid.UUID, error) {
 	var uuidList []uuid.UUID
 	for _, v := range list {
@@ -26,6 +26,7 @@ import (
 	"os"
 	"runtime"
 	"runtime/debug"
+	"slices"
 	"sync"
 	"time"
 )
@@ -100,6 +101,12 @@ type config struct {
 	ScrapydEncryptSecret string
 	autoUpdateNodes      string
 	timezone             string
+	maxRuntimeKillAfter  time.Duration
+	ha                   struct {
+		enabled       bool
+		instance      string
+		leaseDuration time.Duration
+	}
 }
 
 type application struct {
@@ -113,8 +120,11 @@ type application struct {
 		dbConn  *sql.DB
 	}
 	scheduler     gocron.Scheduler
+	cluster       *cluster
+	manualRuns    manualRuns
 	reverseProxy  *httputil.ReverseProxy
 	globalMu      sync.Mutex
+	dependencyMu  sync.Mutex
 	templateCache map[templateName]*template.Template
 	eggBuildFunc  func(ctx context.Context, pythonPath, scrapyCfg string) ([]byte, error)
 }
@@ -149,7 +159,11 @@ func run(logger *slog.Logger) error {
 	flag.BoolVar(&cfg.db.autoMigrate, "auto-migrate", true, "Automatically migrate the database")
 	flag.BoolVar(&cfg.db.createDefaultUser, "create-default-user", false, "Create admin:admin user on startup (useful for first startup so you can login. Don't forget to create legit users afterwards and delete this insecure one)")
 	flag.StringVar(&cfg.autoUpdateNodes, "auto-update-interval", "*/10 * * * *", "Updates jobs info for all the nodes in the background on a given schedule. Expects CRON string.")
+	flag.DurationVar(&cfg.maxRuntimeKillAfter, "max-runtime-kill-after", 5*time.Minute, "How long a job cancelled for exceeding its max runtime has to stop before it's cancelled again with SIGKILL")
 	flag.StringVar(&cfg.timezone, "timezone", "", "If set, cron schedules will account for selected timezone. If not set time.Local (https://pkg.go.dev/time#Local) is used!")
+	flag.BoolVar(&cfg.ha.enabled, "ha", false, "Run alongside other goscrapyd instances sharing the database, only the elected leader fires scheduled tasks")
+	flag.StringVar(&cfg.ha.instance, "ha-instance", defaultInstanceName(), "Name this instance holds the scheduler lease under with -ha, unique among the instances")
+	flag.DurationVar(&cfg.ha.leaseDuration, "ha-lease-duration", 30*time.Second, "How long the scheduler lease lasts without being renewed with -ha, another instance takes over once it expired")
 	showVersion := flag.Bool("version", false, "display version and exit")
 	flag.Parse()
 	var timeLocal *time.Location
@@ -228,16 +242,33 @@ func run(logger *slog.Logger) error {
 	}
 	app.scheduler = s
 	app.scheduler.Start()
+	if cfg.ha.enabled {
+		app.cluster = newCluster(app.DB.queries, app.logger, cfg.ha.instance, cfg.ha.leaseDuration)
+		// Going for the lease before the tasks are loaded lets a leader catch up on missed fires right away
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.DefaultTimeout)
+		_, err = app.cluster.renew(ctx, time.Now())
+		cancel()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
 	err = app.loadTasksOnStart()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if app.cluster != nil {
+		go app.runCluster()
+	}
+	pollOptions := []gocron.JobOption{gocron.WithSingletonMode(gocron.LimitModeReschedule)}
+	if app.cluster != nil {
+		pollOptions = append(pollOptions, gocron.WithDistributedJobLocker(app.cluster))
+	}
 	job, err := app.scheduler.NewJob(gocron.CronJob(app.config.autoUpdateNodes, false), gocron.NewTask(app.updateAllNodesSchedule),
-		gocron.WithSingletonMode(gocron.LimitModeReschedule), gocron.WithEventListeners(gocron.AfterJobRunsWithError(func(jobID uuid.UUID, jobName string, err error) {
+		append(pollOptions, gocron.WithEventListeners(gocron.AfterJobRunsWithError(func(jobID uuid.UUID, jobName string, err error) {
 			log.Println("ERROR IN updateAllNodesSchedule", "jobID:", jobID, "jobName:", jobName, "err:", err)
 		}), gocron.AfterJobRunsWithPanic(func(jobID uuid.UUID, jobName string, recoverData any) {
 			log.Println("PANIC IN updateAllNodesSchedule:", "jobID:", jobID, "jobName:", jobName, "recoverData:", recoverData)
-		})))
+		})))...)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -245,6 +276,29 @@ func run(logger *slog.Logger) error {
 	if err != nil {
 		log.Fatalln(err)
 	}
+	// Runs scheduled for a later date are fired by the leader, the first check catches up on the ones which came due while
+	// goscrapyd was down
+	scheduledRunsJob, err := app.scheduler.NewJob(gocron.DurationJob(scheduledRunsInterval), gocron.NewTask(app.fireDueScheduledRuns),
+		append(slices.Clip(pollOptions), gocron.WithEventListeners(gocron.AfterJobRunsWithError(func(jobID uuid.UUID, jobName string, err error) {
+			app.logger.Error("error firing scheduled runs", slog.Any("err", err))
+		})))...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = scheduledRunsJob.RunNow()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	_, err = app.scheduler.NewJob(gocron.DurationJob(overlapQueueInterval), gocron.NewTask(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.DefaultTimeout)
+		defer cancel()
+		return app.failOrphanedQueuedJobs(ctx)
+	}), append(slices.Clip(pollOptions), gocron.WithEventListeners(gocron.AfterJobRunsWithError(func(jobID uuid.UUID, jobName string, err error) {
+		app.logger.Error("error failing orphaned queued jobs", slog.Any("err", err))
+	})))...)
+	if err != nil {
+		log.Fatalln(err)
+	}
 	if cfg.autoHTTPS.domain != "" {
 		return app.serveAutoHTTPS()
 	}
@@ -254,6 +308,15 @@ func run(logger *slog.Logger) error {
 	return app.serveHTTP()
 }
 
+// defaultInstanceName tells instances started with -ha apart, e.g. two instances on the same host.
+func defaultInstanceName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "goscrapyd"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
 func openDB(cfg config) (*database.Queries, *sql.DB, error) {
 	db, err := sql.Open("sqlite3", cfg.db.dsn)
 	if err != nil {
@@ -312,10 +375,10 @@ func openDB(cfg config) (*database.Queries, *sql.DB, error) {
 			return nil, nil, err
 		}
 		_, err = preparedDb.CreateNewUser(context.Background(), database.CreateNewUserParams{
-			ID:                 userUUID,
-			Username:           username,
-			HashedPassword:     passwordHash,
-			HasAdminPrivileges: true,
+			ID:             userUUID,
+			Username:       username,
+			HashedPassword: passwordHash,
+			Role:           roleAdmin,
 		})
 		if err != nil {
 			_ = db.Close()
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maintenanceOverrideField is the form field, or query parameter for the API, with which a manual fire runs while
+// maintenance mode is on. It's never passed on to the spider.
+const maintenanceOverrideField = "override_maintenance"
+
+// maintenanceDurationOptions are the expiries, in minutes, offered by the maintenance form. Zero means maintenance mode
+// stays on until it's ended.
+var maintenanceDurationOptions = []int{30, 60, 120, 240, 480, 0}
+
+// maintenanceError is a fire which maintenance mode suppressed. It's recorded the same way as a fire the overlap policy
+// skipped.
+type maintenanceError struct {
+	Reason string
+}
+
+func (e *maintenanceError) Error() string {
+	return fmt.Sprintf("suppressed by maintenance mode: %s", e.Reason)
+}
+
+// checkMaintenance returns a *maintenanceError while maintenance mode is on, unless the fire overrides it. Only fires
+// by hand do, they're refused before they get here when the user didn't ask to override maintenance mode, see
+// maintenanceRefusal. Paused tasks keep their state, maintenance mode is read on every fire.
+func (t *task) checkMaintenance(ctx context.Context, trigger runTrigger) error {
+	if trigger.OverrideMaintenance {
+		return nil
+	}
+	maintenance, err := t.DB.GetMaintenanceMode(ctx, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return &maintenanceError{Reason: maintenance.Reason}
+}
+
+// activeMaintenance returns maintenance mode while it's on, nil otherwise.
+func (app *application) activeMaintenance(ctx context.Context) (*database.GetMaintenanceModeRow, error) {
+	maintenance, err := app.DB.queries.GetMaintenanceMode(ctx, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &maintenance, nil
+}
+
+// maintenanceRefusal returns why a manual fire can't run, empty when maintenance mode is off or the request overrides it.
+func (app *application) maintenanceRefusal(ctx context.Context, r *http.Request) (string, error) {
+	if r.FormValue(maintenanceOverrideField) == "true" {
+		return "", nil
+	}
+	maintenance, err := app.activeMaintenance(ctx)
+	if err != nil || maintenance == nil {
+		return "", err
+	}
+	return fmt.Sprintf("Maintenance mode is on (%s), override it to fire anyway", maintenance.Reason), nil
+}
+
+// apiRefuseMaintenanceFire answers 409 and reports true when maintenance mode is on and the request doesn't override it.
+func (app *application) apiRefuseMaintenanceFire(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	refusal, err := app.maintenanceRefusal(ctx, r)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return true
+	}
+	if refusal != "" {
+		app.apiErrorResponse(w, r, http.StatusConflict, refusal, nil)
+		return true
+	}
+	return false
+}
+
+type maintenanceForm struct {
+	Reason          string              `form:"reason"`
+	DurationMinutes int                 `form:"duration_minutes"`
+	Validator       validator.Validator `form:"-"`
+}
+
+type maintenanceInput struct {
+	Reason    string              `json:"reason"`
+	ExpiresAt *time.Time          `json:"expires_at"`
+	Validator validator.Validator `json:"-"`
+}
+
+type maintenanceView struct {
+	Reason    string     `json:"reason"`
+	StartedAt time.Time  `json:"started_at"`
+	StartedBy *string    `json:"started_by"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func newMaintenanceView(maintenance database.GetMaintenanceModeRow) maintenanceView {
+	return maintenanceView{
+		Reason:    maintenance.Reason,
+		StartedAt: maintenance.StartedAt,
+		StartedBy: database.ReadSqlNullString(maintenance.StartedByUsername),
+		ExpiresAt: nullTimePtr(maintenance.ExpiresAt),
+	}
+}
+
+// startMaintenance turns maintenance mode on, or replaces the reason and expiry when it's on already.
+func (app *application) startMaintenance(ctx context.Context, reason string, expiresAt *time.Time, user *database.User) error {
+	params := database.StartMaintenanceModeParams{Reason: reason, StartedAt: time.Now()}
+	if expiresAt != nil {
+		params.ExpiresAt = sql.NullTime{Time: *expiresAt, Valid: true}
+	}
+	if user != nil {
+		params.StartedBy = user.ID
+	}
+	err := app.DB.queries.StartMaintenanceMode(ctx, params)
+	if err != nil {
+		return err
+	}
+	app.logger.Warn("maintenance mode started", slog.String("reason", reason), slog.Any("expires_at", expiresAt))
+	return nil
+}
+
+// maintenance shows maintenance mode and turns it on. While it's on scheduled fires are skipped and recorded as such,
+// whether tasks are paused isn't changed.
+func (app *application) maintenance(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var form maintenanceForm
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		err := request.DecodePostForm(r, &form)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		form.Reason = strings.TrimSpace(form.Reason)
+		form.Validator.CheckField(validator.NotBlank(form.Reason), "reason", "Reason can not be blank")
+		form.Validator.CheckField(form.DurationMinutes >= 0, "duration_minutes", "Duration can't be negative")
+		if !form.Validator.HasErrors() {
+			var expiresAt *time.Time
+			if form.DurationMinutes > 0 {
+				expiry := time.Now().Add(time.Duration(form.DurationMinutes) * time.Minute)
+				expiresAt = &expiry
+			}
+			err = app.startMaintenance(ctxwt, form.Reason, expiresAt, contextGetAuthenticatedUser(r))
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, "/maintenance", http.StatusSeeOther)
+			return
+		}
+		status = http.StatusUnprocessableEntity
+	}
+	data := app.newTemplateData(r)
+	data["Form"] = form
+	data["DurationOptions"] = maintenanceDurationOptions
+	app.render(w, r, status, maintenancePage, nil, data)
+}
+
+func (app *application) endMaintenance(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	_, err := app.DB.queries.EndMaintenanceMode(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.logger.Warn("maintenance mode ended")
+	http.Redirect(w, r, "/maintenance", http.StatusSeeOther)
+}
+
+func (app *application) apiGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	maintenance, err := app.activeMaintenance(ctxwt)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	var view *maintenanceView
+	if maintenance != nil {
+		result := newMaintenanceView(*maintenance)
+		view = &result
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"maintenance": view})
+}
+
+func (app *application) apiStartMaintenance(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var input maintenanceInput
+	if !app.apiReadJSON(w, r, &input) {
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	input.Validator.CheckField(validator.NotBlank(input.Reason), "reason", "Reason can not be blank")
+	if input.ExpiresAt != nil {
+		input.Validator.CheckField(input.ExpiresAt.After(time.Now()), "expires_at", "Expiry must be in the future")
+	}
+	if input.Validator.HasErrors() {
+		app.apiFailedValidation(w, r, input.Validator)
+		return
+	}
+	err := app.startMaintenance(ctxwt, input.Reason, input.ExpiresAt, contextGetAuthenticatedUser(r))
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	maintenance, err := app.DB.queries.GetMaintenanceMode(ctxwt, time.Now())
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"maintenance": newMaintenanceView(maintenance)})
+}
+
+func (app *application) apiEndMaintenance(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	_, err := app.DB.queries.EndMaintenanceMode(ctxwt)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	app.logger.Warn("maintenance mode ended")
+	w.WriteHeader(http.StatusNoContent)
+}
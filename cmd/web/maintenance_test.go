@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/funcs"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	ta := newTestApplication(t)
+	scheduler, err := gocron.NewScheduler(gocron.WithClock(clockwork.NewFakeClock()))
+	assert.NilError(t, err)
+	ta.scheduler = scheduler
+	ta.scheduler.Start()
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ctx := context.Background()
+	var scheduled atomic.Int32
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			scheduled.Add(1)
+			_, err := w.Write([]byte(`{"node_name": "maintenance_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err = ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: "maintenance_node", Url: mockScrapyd.URL})
+	assert.NilError(t, err)
+	tasks := map[string]database.Task{}
+	for _, paused := range []bool{false, true} {
+		taskName := "running_task"
+		if paused {
+			taskName = "paused_task"
+		}
+		taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+			ID:                uuid.New(),
+			Name:              database.CreateSqlNullString(&taskName),
+			Project:           "shop",
+			Spider:            "products",
+			Jobid:             taskName,
+			SettingsArguments: "project=shop&spider=products",
+			CronString:        "0 6 * * *",
+			Paused:            paused,
+			RetryMaxAttempts:  1,
+			OverlapPolicy:     overlapAllow,
+			FanOut:            fanOutAll,
+			MisfirePolicy:     misfireIgnore,
+			MisfireMaxRuns:    1,
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{"maintenance_node"}, nil))
+		if !paused {
+			_, err = ta.scheduleTask(taskDb)
+			assert.NilError(t, err)
+		}
+		tasks[taskName] = taskDb
+	}
+	running := tasks["running_task"]
+
+	t.Run("Start", func(t *testing.T) {
+		code, _, body := ts.get(t, "/maintenance")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Start maintenance mode")
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "reason": {" "}, "duration_minutes": {"60"}}
+		code, _, body = ts.postForm(t, "/maintenance", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Reason can not be blank")
+		form.Set("reason", "Upgrading Scrapyd")
+		code, _, _ = ts.postForm(t, "/maintenance", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		maintenance, err := ta.activeMaintenance(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, maintenance.Reason, "Upgrading Scrapyd")
+		assert.Equal(t, maintenance.StartedByUsername.String, "admin")
+		assert.Equal(t, maintenance.ExpiresAt.Valid, true)
+		assert.Equal(t, maintenance.ExpiresAt.Time.After(time.Now().Add(59*time.Minute)), true)
+	})
+	t.Run("Banner", func(t *testing.T) {
+		code, _, body := ts.get(t, "/list-tasks")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Maintenance mode is on:")
+		assert.StringContains(t, body, "Upgrading Scrapyd")
+		assert.StringContains(t, body, `name="override_maintenance"`)
+	})
+	t.Run("Scheduled fires are skipped", func(t *testing.T) {
+		fire, err := ta.taskFromDb(running)
+		assert.NilError(t, err)
+		assert.NilError(t, fire.fire(ctx, runTrigger{Source: triggerCron}))
+		fire.TriggeredBy = webhookTriggeredBy
+		_, err = fire.fireNow("")
+		assert.Equal(t, isSkippedFire(err), true)
+		assert.Equal(t, scheduled.Load(), int32(0))
+		jobs, err := ta.DB.queries.SearchNodeJobs(ctx, database.SearchNodeJobsParams{SearchTerm: "products", Node: "maintenance_node"})
+		assert.NilError(t, err)
+		// Both fires record on the job ID of the task
+		assert.Equal(t, len(jobs), 1)
+		for _, job := range jobs {
+			assert.Equal(t, job.Status, "skipped")
+			assert.Equal(t, funcs.SafeBase64Decode(job.Error.String), "suppressed by maintenance mode: Upgrading Scrapyd")
+		}
+	})
+	t.Run("Scheduled runs are skipped", func(t *testing.T) {
+		runID, err := ta.createScheduledRun(ctx, "shop", "backfill", url.Values{"project": {"shop"}, "spider": {"backfill"}},
+			time.Now().Add(-time.Minute), "", []string{"maintenance_node"}, nil)
+		assert.NilError(t, err)
+		assert.NilError(t, ta.fireDueScheduledRuns())
+		run, err := ta.DB.queries.GetScheduledRun(ctx, runID)
+		assert.NilError(t, err)
+		assert.Equal(t, run.Status, scheduledRunSkipped)
+		assert.Equal(t, scheduled.Load(), int32(0))
+	})
+	t.Run("Manual fires need an override", func(t *testing.T) {
+		code, _, body := ts.postForm(t, "/fire-task/"+running.ID.String(), url.Values{})
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Maintenance mode is on (Upgrading Scrapyd)")
+		code, _, body = ts.postForm(t, "/fire-task/"+running.ID.String(), url.Values{maintenanceOverrideField: {"true"}})
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Started task")
+
+		code, _, body = ts.get(t, "/fire-spider")
+		assert.Equal(t, code, http.StatusOK)
+		form := url.Values{
+			"csrf_token": {extractCSRFToken(t, body)},
+			"project":    {"shop"},
+			"spider":     {"products"},
+			"fireNode":   {"maintenance_node"},
+		}
+		code, _, body = ts.postForm(t, "/fire-spider", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "override it to fire anyway")
+
+		code, _, _ = ts.doJSON(t, http.MethodPost, "/api/v1/tasks/"+running.ID.String()+"/fire", nil)
+		assert.Equal(t, code, http.StatusConflict)
+		code, _, _ = ts.doJSON(t, http.MethodPost, "/api/v1/tasks/"+running.ID.String()+"/fire?override_maintenance=true", nil)
+		assert.Equal(t, code, http.StatusAccepted)
+	})
+	t.Run("Overridden fires aren't suppressed", func(t *testing.T) {
+		fire, err := ta.taskFromDb(running)
+		assert.NilError(t, err)
+		before := scheduled.Load()
+		// Without a trigger source fireNow records the fire as manual, that alone doesn't override maintenance mode
+		_, err = fire.fireNow("")
+		assert.Equal(t, isSkippedFire(err), true)
+		assert.Equal(t, scheduled.Load(), before)
+		fire.overrideMaintenance = true
+		assert.NilError(t, fire.fireFunc(ctx))
+		assert.Equal(t, scheduled.Load(), before+1)
+	})
+	t.Run("API", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/maintenance", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var got struct {
+			Maintenance *maintenanceView `json:"maintenance"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &got))
+		assert.Equal(t, got.Maintenance.Reason, "Upgrading Scrapyd")
+
+		code, _, _ = ts.doJSON(t, http.MethodPut, "/api/v1/maintenance", map[string]any{"reason": ""})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		code, _, body = ts.doJSON(t, http.MethodPut, "/api/v1/maintenance", map[string]any{"reason": "Node upgrade"})
+		assert.Equal(t, code, http.StatusOK)
+		assert.NilError(t, json.Unmarshal(body, &got))
+		assert.Equal(t, got.Maintenance.Reason, "Node upgrade")
+		assert.Equal(t, got.Maintenance.ExpiresAt == nil, true)
+
+		code, _, _ = ts.doJSON(t, http.MethodDelete, "/api/v1/maintenance", nil)
+		assert.Equal(t, code, http.StatusNoContent)
+		code, _, body = ts.doJSON(t, http.MethodGet, "/api/v1/maintenance", nil)
+		assert.Equal(t, code, http.StatusOK)
+		assert.NilError(t, json.Unmarshal(body, &got))
+		assert.Equal(t, got.Maintenance == nil, true)
+	})
+	t.Run("Expiry", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Second)
+		assert.NilError(t, ta.startMaintenance(ctx, "Already over", &expiresAt, nil))
+		maintenance, err := ta.activeMaintenance(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, maintenance == nil, true)
+		_, _, body := ts.get(t, "/list-tasks")
+		assert.StringDoesNotContain(t, body, "Maintenance mode is on:")
+	})
+	t.Run("Paused state is kept", func(t *testing.T) {
+		for name, want := range map[string]bool{"running_task": false, "paused_task": true} {
+			taskDb, err := ta.DB.queries.GetTaskWithUUID(ctx, tasks[name].ID)
+			assert.NilError(t, err)
+			assert.Equal(t, taskDb.Paused, want)
+		}
+		isRunning, _ := ta.isTaskRunning(running.ID)
+		assert.Equal(t, isRunning, true)
+		isRunning, _ = ta.isTaskRunning(tasks["paused_task"].ID)
+		assert.Equal(t, isRunning, false)
+	})
+}
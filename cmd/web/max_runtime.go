@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRuntimeKillSignal is sent by the second cancel of a job which is still running long after it was cancelled for
+// exceeding its max runtime. The first cancel leaves the signal to Scrapyd, which lets the spider shut down cleanly.
+const maxRuntimeKillSignal = "KILL"
+
+// maxRuntimeLimit caps the max runtime of tasks and projects, anything longer is as good as no limit.
+const maxRuntimeLimit = 90 * 24 * time.Hour
+
+// maxRuntimeFromTask is the max runtime of the task, 0 when it has none of its own.
+func maxRuntimeFromTask(taskDb database.Task) time.Duration {
+	if !taskDb.MaxRuntimeSeconds.Valid {
+		return 0
+	}
+	return time.Duration(taskDb.MaxRuntimeSeconds.Int64) * time.Second
+}
+
+// nullMaxRuntime stores a max runtime of 0, no limit, as NULL.
+func nullMaxRuntime(d time.Duration) sql.NullInt64 {
+	if d <= 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(d / time.Second), Valid: true}
+}
+
+// validateMaxRuntime accepts 0 for no limit, otherwise at least a minute.
+func validateMaxRuntime(v *validator.Validator, field string, d time.Duration) {
+	v.CheckField(d == 0 || (d >= time.Minute && d <= maxRuntimeLimit), field, fmt.Sprintf("Max runtime must be between 1 minute and %d days, or empty for no limit", maxRuntimeLimit/(24*time.Hour)))
+}
+
+// jobMaxRuntime is the max runtime of the job's task, or else of its project.
+func jobMaxRuntime(job database.ListRunningJobsWithMaxRuntimeRow) time.Duration {
+	if job.TaskMaxRuntimeSeconds.Valid {
+		return time.Duration(job.TaskMaxRuntimeSeconds.Int64) * time.Second
+	}
+	return time.Duration(job.ProjectMaxRuntimeSeconds.Int64) * time.Second
+}
+
+// enforceMaxRuntimes cancels the running jobs which are past their max runtime. A job still running
+// config.maxRuntimeKillAfter after it was cancelled is cancelled again with maxRuntimeKillSignal. It works off the job
+// statuses polling stored, so it runs right after the nodes were polled.
+func (app *application) enforceMaxRuntimes(ctx context.Context, now time.Time) {
+	jobs, err := app.DB.queries.ListRunningJobsWithMaxRuntime(ctx)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error listing running jobs with a max runtime", slog.Any("err", err))
+		return
+	}
+	for _, job := range jobs {
+		maxRuntime := jobMaxRuntime(job)
+		switch {
+		case !job.TimedOutAt.Valid && now.Sub(job.Start.Time) >= maxRuntime:
+			app.timeOutJob(ctx, job, maxRuntime, now)
+		case job.TimedOutAt.Valid && !job.KilledAt.Valid && now.Sub(job.TimedOutAt.Time) >= app.config.maxRuntimeKillAfter:
+			app.killTimedOutJob(ctx, job, now)
+		}
+	}
+}
+
+// timeOutJob cancels a job past its max runtime the same way stopping it from the jobs page does. Once Scrapyd reports
+// the job finished it is stored as timed_out, see InsertJob.
+func (app *application) timeOutJob(ctx context.Context, job database.ListRunningJobsWithMaxRuntimeRow, maxRuntime time.Duration, now time.Time) {
+	response, err := app.cancelScrapydJob(ctx, job.Node, job.Project, job.Job, "", nil)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error cancelling job past its max runtime", slog.Any("job", job.Job), slog.Any("node", job.Node), slog.Any("err", err))
+		return
+	}
+	// The job ended on its own since the nodes were polled
+	if response.Prevstate != "running" {
+		return
+	}
+	err = app.DB.queries.SetJobTimedOut(ctx, database.SetJobTimedOutParams{
+		TimedOutAt: database.CreateCreateSqlNullTimeNonPtr(now),
+		ID:         job.ID,
+	})
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error marking job as timed out", slog.Any("job", job.Job), slog.Any("err", err))
+		return
+	}
+	app.logger.WarnContext(ctx, "cancelled job past its max runtime", slog.Any("job", job.Job), slog.Any("node", job.Node),
+		slog.Any("project", job.Project), slog.Any("spider", job.Spider), slog.Any("maxRuntime", maxRuntime))
+	app.notifyJobTimedOut(job, maxRuntime, now)
+}
+
+// killTimedOutJob escalates the cancel of a timed out job which didn't stop.
+func (app *application) killTimedOutJob(ctx context.Context, job database.ListRunningJobsWithMaxRuntimeRow, now time.Time) {
+	_, err := app.cancelScrapydJob(ctx, job.Node, job.Project, job.Job, maxRuntimeKillSignal, nil)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error killing timed out job", slog.Any("job", job.Job), slog.Any("node", job.Node), slog.Any("err", err))
+		return
+	}
+	err = app.DB.queries.SetJobKilled(ctx, database.SetJobKilledParams{
+		KilledAt: database.CreateCreateSqlNullTimeNonPtr(now),
+		ID:       job.ID,
+	})
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error marking timed out job as killed", slog.Any("job", job.Job), slog.Any("err", err))
+		return
+	}
+	app.logger.WarnContext(ctx, "killed timed out job which kept running after it was cancelled", slog.Any("job", job.Job),
+		slog.Any("node", job.Node), slog.Any("timedOutAt", job.TimedOutAt.Time))
+}
+
+// notifyJobTimedOut emails the notifications address, if one is configured, about a job cancelled for running too long.
+func (app *application) notifyJobTimedOut(job database.ListRunningJobsWithMaxRuntimeRow, maxRuntime time.Duration, now time.Time) {
+	if app.config.notifications.email == "" {
+		return
+	}
+	data := app.newEmailData()
+	data["Node"] = job.Node
+	data["Project"] = job.Project
+	data["Spider"] = job.Spider
+	data["Job"] = job.Job
+	data["Start"] = job.Start.Time
+	data["MaxRuntime"] = maxRuntime
+	data["TimedOutAt"] = now
+	err := app.mailer.Send(app.config.notifications.email, data, "job-timed-out.tmpl")
+	if err != nil {
+		app.logger.Error("error sending job timed out notification", slog.Any("job", job.Job), slog.Any("err", err))
+	}
+}
+
+type projectMaxRuntime struct {
+	database.ProjectMaxRuntime
+}
+
+func (p projectMaxRuntime) MaxRuntime() time.Duration {
+	return time.Duration(p.MaxRuntimeSeconds) * time.Second
+}
+
+func (app *application) projectMaxRuntimes(ctx context.Context) ([]projectMaxRuntime, error) {
+	rows, err := app.DB.queries.ListProjectMaxRuntimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]projectMaxRuntime, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, projectMaxRuntime{row})
+	}
+	return result, nil
+}
+
+type projectMaxRuntimeForm struct {
+	Project    string              `form:"max_runtime_project"`
+	MaxRuntime int                 `form:"max_runtime_minutes"`
+	Validator  validator.Validator `form:"-"`
+}
+
+// saveProjectMaxRuntime sets the max runtime for jobs of a project, tasks with a max runtime of their own use theirs.
+func (app *application) saveProjectMaxRuntime(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var form projectMaxRuntimeForm
+	err := request.DecodePostForm(r, &form)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	form.Project = strings.TrimSpace(form.Project)
+	maxRuntime := time.Duration(form.MaxRuntime) * time.Minute
+	form.Validator.CheckField(validator.NotBlank(form.Project), "max_runtime_project", "Project can not be blank")
+	form.Validator.CheckField(maxRuntime > 0, "max_runtime_minutes", "Max runtime can not be empty")
+	validateMaxRuntime(&form.Validator, "max_runtime_minutes", maxRuntime)
+	if form.Validator.HasErrors() {
+		data, err := app.settingsTemplateData(ctxwt, r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data["MaxRuntimeForm"] = form
+		app.render(w, r, http.StatusUnprocessableEntity, settingsPage, nil, data)
+		return
+	}
+	params := database.UpsertProjectMaxRuntimeParams{
+		Project:           form.Project,
+		MaxRuntimeSeconds: int64(maxRuntime / time.Second),
+	}
+	if user := contextGetAuthenticatedUser(r); user != nil {
+		params.CreatedBy = user.ID
+	}
+	err = app.DB.queries.UpsertProjectMaxRuntime(ctxwt, params)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/edit-settings", http.StatusSeeOther)
+}
+
+func (app *application) deleteProjectMaxRuntime(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	deleted, err := app.DB.queries.DeleteProjectMaxRuntime(ctxwt, r.PathValue("project"))
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidateMaxRuntime(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRuntime time.Duration
+		invalid    bool
+	}{
+		{"No limit", 0, false},
+		{"Six hours", 6 * time.Hour, false},
+		{"Under a minute", 30 * time.Second, true},
+		{"Negative", -time.Hour, true},
+		{"Too long", maxRuntimeLimit + time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validator.Validator
+			validateMaxRuntime(&v, "max_runtime", tt.maxRuntime)
+			assert.Equal(t, v.HasErrors(), tt.invalid)
+		})
+	}
+	assert.Equal(t, nullMaxRuntime(0).Valid, false)
+	assert.Equal(t, nullMaxRuntime(90*time.Minute).Int64, int64(5400))
+}
+
+func TestEnforceMaxRuntimes(t *testing.T) {
+	ta := newTestApplication(t)
+	ta.config.maxRuntimeKillAfter = 5 * time.Minute
+	ctx := context.Background()
+	var mu sync.Mutex
+	var cancels []string
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var err error
+		switch r.URL.Path {
+		case "/cancel.json":
+			mu.Lock()
+			cancels = append(cancels, r.URL.Query().Get("job")+":"+r.URL.Query().Get("signal"))
+			mu.Unlock()
+			_, err = w.Write([]byte(`{"node_name": "runtime_node", "status": "ok", "prevstate": "running"}`))
+		case "/logs/stats.json":
+			_, err = w.Write([]byte(`{"status": "ok", "datas": {}}`))
+		case "/listjobs.json":
+			_, err = w.Write([]byte(`{"node_name": "runtime_node", "status": "ok", "pending": [], "running": [], "finished": []}`))
+		}
+		assert.NilError(t, err)
+	}))
+	defer mockScrapyd.Close()
+	takeCancels := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		result := strings.Join(cancels, ",")
+		cancels = nil
+		return result
+	}
+	_, err := ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: "runtime_node", Url: mockScrapyd.URL})
+	assert.NilError(t, err)
+
+	taskName := "limited"
+	taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+		ID:                uuid.New(),
+		Name:              database.CreateSqlNullString(&taskName),
+		Project:           "shop",
+		Spider:            "products",
+		Jobid:             taskName,
+		SettingsArguments: "project=shop&spider=products",
+		CronString:        "0 6 * * *",
+		Paused:            true,
+		RetryMaxAttempts:  1,
+		OverlapPolicy:     overlapAllow,
+		FanOut:            fanOutAll,
+		MaxRuntimeSeconds: nullMaxRuntime(time.Hour),
+	})
+	assert.NilError(t, err)
+	err = ta.DB.queries.UpsertProjectMaxRuntime(ctx, database.UpsertProjectMaxRuntimeParams{Project: "shop", MaxRuntimeSeconds: int64(3 * time.Hour / time.Second)})
+	assert.NilError(t, err)
+
+	now := time.Now()
+	insertJob := func(job, status string, taskID any, start, updated time.Time) database.Job {
+		inserted, err := ta.DB.queries.InsertJob(ctx, database.InsertJobParams{
+			Project:    "shop",
+			Spider:     "products",
+			Job:        job,
+			Status:     status,
+			CreateTime: start,
+			UpdateTime: updated,
+			Start:      database.CreateCreateSqlNullTimeNonPtr(start),
+			Node:       "runtime_node",
+			TaskID:     taskID,
+		})
+		assert.NilError(t, err)
+		return inserted
+	}
+	// The task's own limit of an hour wins over the three hours of the project
+	insertJob("task_job", "running", taskDb.ID, now.Add(-2*time.Hour), now)
+	insertJob("project_job", "running", nil, now.Add(-4*time.Hour), now)
+	insertJob("short_job", "running", nil, now.Add(-2*time.Hour), now)
+
+	ta.enforceMaxRuntimes(ctx, now)
+	assert.Equal(t, takeCancels(), "task_job:,project_job:")
+	// Cancelled jobs aren't cancelled again until the kill grace period is up
+	ta.enforceMaxRuntimes(ctx, now.Add(time.Minute))
+	assert.Equal(t, takeCancels(), "")
+	ta.enforceMaxRuntimes(ctx, now.Add(6*time.Minute))
+	assert.Equal(t, takeCancels(), "task_job:KILL,project_job:KILL")
+	ta.enforceMaxRuntimes(ctx, now.Add(12*time.Minute))
+	assert.Equal(t, takeCancels(), "")
+
+	// Polling reporting the jobs finished records them as timed out
+	timedOut := insertJob("task_job", "finished", nil, now.Add(-2*time.Hour), now.Add(10*time.Minute))
+	assert.Equal(t, timedOut.Status, "timed_out")
+	assert.Equal(t, timedOut.TimedOutAt.Valid, true)
+	assert.Equal(t, timedOut.KilledAt.Valid, true)
+	finished := insertJob("short_job", "finished", nil, now.Add(-2*time.Hour), now.Add(10*time.Minute))
+	assert.Equal(t, finished.Status, "finished")
+
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	code, _, body := ts.get(t, "/runtime_node/jobs")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Timed Out")
+	assert.StringContains(t, body, "for exceeding its max runtime")
+	assert.StringContains(t, body, "Over max runtime, cancelled at")
+}
+
+func TestProjectMaxRuntimes(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ctx := context.Background()
+
+	code, _, body := ts.get(t, "/edit-settings")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "No project max runtimes yet")
+	form := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "max_runtime_project": {"shop"}}
+	code, _, body = ts.postForm(t, "/edit-settings/max-runtimes", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Max runtime can not be empty")
+	form.Set("max_runtime_minutes", "360")
+	code, _, _ = ts.postForm(t, "/edit-settings/max-runtimes", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	code, _, body = ts.get(t, "/edit-settings")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "6 hours")
+
+	code, _, _ = ts.delete(t, "/edit-settings/max-runtimes/shop")
+	assert.Equal(t, code, http.StatusOK)
+	code, _, _ = ts.delete(t, "/edit-settings/max-runtimes/shop")
+	assert.Equal(t, code, http.StatusNotFound)
+	limits, err := ta.DB.queries.ListProjectMaxRuntimes(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(limits), 0)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/blazskufca/goscrapyd/internal/response"
 	"github.com/blazskufca/goscrapyd/internal/validator"
 	"github.com/google/uuid"
+	"github.com/justinas/alice"
 	"github.com/justinas/nosurf"
 	"github.com/tomasen/realip"
 	"golang.org/x/time/rate"
@@ -33,7 +34,11 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		defer func() {
 			err := recover()
 			if err != nil {
-				app.serverError(w, r, fmt.Errorf("%s", err))
+				if isAPIRequest(r) {
+					app.apiServerError(w, r, fmt.Errorf("%s", err))
+				} else {
+					app.serverError(w, r, fmt.Errorf("%s", err))
+				}
 			}
 		}()
 
@@ -117,6 +122,32 @@ func (app *application) preventCSRF(next http.Handler) http.Handler {
 
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// API clients authenticate with a bearer token instead of the session cookie
+		if token, ok := bearerToken(r); ok && isAPIRequest(r) {
+			user, apiToken, err := app.authenticateAPIToken(r.Context(), token, realip.FromRequest(r))
+			if err != nil {
+				app.apiServerError(w, r, err)
+				return
+			}
+			if user != nil {
+				permissions, err := app.permissionsForRole(r.Context(), user.Role)
+				if err != nil {
+					app.apiServerError(w, r, err)
+					return
+				}
+				scope, err := app.accessScopeForUser(r.Context(), user)
+				if err != nil {
+					app.apiServerError(w, r, err)
+					return
+				}
+				r = contextSetAuthenticatedUser(r, user)
+				r = contextSetAPIToken(r, apiToken)
+				r = contextSetPermissions(r, permissions)
+				r = contextSetAccessScope(r, scope)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
 		session, err := app.sessionStore.Get(r, "session")
 		if err != nil {
 			app.serverError(w, r, err)
@@ -137,7 +168,19 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 				found = true
 			}
 			if found {
+				permissions, err := app.permissionsForRole(r.Context(), user.Role)
+				if err != nil {
+					app.serverError(w, r, err)
+					return
+				}
+				scope, err := app.accessScopeForUser(r.Context(), &user)
+				if err != nil {
+					app.serverError(w, r, err)
+					return
+				}
 				r = contextSetAuthenticatedUser(r, &user)
+				r = contextSetPermissions(r, permissions)
+				r = contextSetAccessScope(r, scope)
 			}
 		}
 		next.ServeHTTP(w, r)
@@ -167,6 +210,81 @@ func (app *application) requireAuthenticatedUser(next http.Handler) http.Handler
 			return
 		}
 
+		// Users an admin requires 2FA for can't do anything else until they set it up
+		if twoFactorEnrollmentPending(authenticatedUser) && r.URL.Path != "/two-factor" && r.URL.Path != "/logout" {
+			http.Redirect(w, r, "/two-factor", http.StatusSeeOther)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) requireAuthenticatedAPIUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contextGetAuthenticatedUser(r) == nil {
+			app.apiAuthenticationRequired(w, r)
+			return
+		}
+		if contextGetAPIToken(r) == nil && twoFactorEnrollmentPending(contextGetAuthenticatedUser(r)) {
+			app.apiErrorResponse(w, r, http.StatusForbidden, "Set up two-factor authentication before using the API", nil)
+			return
+		}
+		if token := contextGetAPIToken(r); token != nil && token.Scope == apiTokenScopeRead && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			app.apiErrorResponse(w, r, http.StatusForbidden, "This API token is read-only", nil)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticateScrapydClient authenticates clients of the Scrapyd-compatible endpoints. Scrapyd only knows HTTP basic auth,
+// so an API token is accepted as the basic auth password (the username is ignored) as well as a bearer token.
+func (app *application) authenticateScrapydClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			_, token, ok = r.BasicAuth()
+		}
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="goscrapyd"`)
+			app.scrapydErrorResponse(w, r, http.StatusUnauthorized, "Authentication required, use an API token as the password")
+			return
+		}
+		user, apiToken, err := app.authenticateAPIToken(r.Context(), token, realip.FromRequest(r))
+		if err != nil {
+			app.scrapydServerError(w, r, err)
+			return
+		}
+		if user == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="goscrapyd"`)
+			app.scrapydErrorResponse(w, r, http.StatusUnauthorized, "Invalid or expired API token")
+			return
+		}
+		if apiToken.Scope == apiTokenScopeRead && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			app.scrapydErrorResponse(w, r, http.StatusForbidden, "This API token is read-only")
+			return
+		}
+		permissions, err := app.permissionsForRole(r.Context(), user.Role)
+		if err != nil {
+			app.scrapydServerError(w, r, err)
+			return
+		}
+		scope, err := app.accessScopeForUser(r.Context(), user)
+		if err != nil {
+			app.scrapydServerError(w, r, err)
+			return
+		}
+		r = contextSetAuthenticatedUser(r, user)
+		r = contextSetAPIToken(r, apiToken)
+		r = contextSetPermissions(r, permissions)
+		r = contextSetAccessScope(r, scope)
+
 		w.Header().Add("Cache-Control", "no-store")
 
 		next.ServeHTTP(w, r)
@@ -227,7 +345,11 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 			if !clients[ip].limiter.Allow() {
 				mu.Unlock()
 				app.logger.Info("rate limit exceeded", slog.Any("ip", ip))
-				app.rateLimitExceededResponse(w, r)
+				if isAPIRequest(r) {
+					app.apiRateLimitExceeded(w, r)
+				} else {
+					app.rateLimitExceededResponse(w, r)
+				}
 				return
 			}
 			mu.Unlock()
@@ -237,10 +359,91 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 	})
 }
 
-func (app *application) requirePermission(next http.Handler) http.Handler {
+// requirePermission only lets users whose role holds the permission through. Anonymous requests have no permissions,
+// so it belongs after requireAuthenticatedUser.
+func (app *application) requirePermission(perm permission) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !contextGetPermissions(r).Has(perm) {
+				app.notPermittedResponse(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *application) requireAPIPermission(perm permission) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !contextGetPermissions(r).Has(perm) {
+				app.apiNotPermitted(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *application) requireScrapydPermission(perm permission) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !contextGetPermissions(r).Has(perm) {
+				app.scrapydErrorResponse(w, r, http.StatusForbidden, "Your role doesn't allow this action")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireScope keeps scoped users away from nodes, projects, tasks and jobs in the path they have no grant for.
+func (app *application) requireScope(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user := contextGetAuthenticatedUser(r)
-		if !user.HasAdminPrivileges {
+		allowed, err := app.pathInScope(r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIScope answers 404 for resources outside the user's grants, they don't exist as far as the user is concerned.
+func (app *application) requireAPIScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, err := app.pathInScope(r)
+		if err != nil {
+			app.apiServerError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.apiNotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireScrapydBackendScope applies the grants to the reverse proxy. Users limited to some projects on the node only
+// get through with requests about one of those projects, the Scrapyd pages listing everything stay closed to them.
+func (app *application) requireScrapydBackendScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope, node := contextGetAccessScope(r), r.PathValue("node")
+		if scope.allowsEveryProject(node) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		project, err := scrapydBackendProject(r)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		if project == "" || !scope.Allows(project, node) {
 			app.notPermittedResponse(w, r)
 			return
 		}
@@ -330,23 +330,24 @@ func TestRateLimitMiddleware(t *testing.T) {
 	})
 }
 
-func TestRequirePrivileged(t *testing.T) {
+func TestRequirePermission(t *testing.T) {
 	app := newTestApplication(t)
 	t.Run("Privileged GET", func(t *testing.T) {
 		rr := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req = contextSetAuthenticatedUser(req, &database.User{
-			ID:                 uuid.New(),
-			CreatedAt:          time.Now(),
-			Username:           "TestUser",
-			HashedPassword:     "",
-			HasAdminPrivileges: true,
+			ID:             uuid.New(),
+			CreatedAt:      time.Now(),
+			Username:       "TestUser",
+			HashedPassword: "",
+			Role:           roleAdmin,
 		})
+		req = contextSetPermissions(req, permissionSet{permissionViewJobs, permissionManageUsers})
 		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := contextGetAuthenticatedUser(r)
 			assert.Equal(t, user.Username, "TestUser")
 		})
-		app.requirePermission(next).ServeHTTP(rr, req)
+		app.requirePermission(permissionManageUsers)(next).ServeHTTP(rr, req)
 		rs := rr.Result()
 		assert.Equal(t, rs.StatusCode, http.StatusOK)
 	})
@@ -354,16 +355,17 @@ func TestRequirePrivileged(t *testing.T) {
 		rr := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		req = contextSetAuthenticatedUser(req, &database.User{
-			ID:                 uuid.New(),
-			CreatedAt:          time.Now(),
-			Username:           "TestUser",
-			HashedPassword:     "",
-			HasAdminPrivileges: false,
+			ID:             uuid.New(),
+			CreatedAt:      time.Now(),
+			Username:       "TestUser",
+			HashedPassword: "",
+			Role:           roleViewer,
 		})
+		req = contextSetPermissions(req, permissionSet{permissionViewJobs})
 		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			t.Fatal("Unprivileged user managed to access privileged endpoint with GET!")
 		})
-		app.requirePermission(next).ServeHTTP(rr, req)
+		app.requirePermission(permissionManageUsers)(next).ServeHTTP(rr, req)
 		rs := rr.Result()
 		assert.Equal(t, rs.StatusCode, http.StatusForbidden)
 	})
@@ -371,17 +373,18 @@ func TestRequirePrivileged(t *testing.T) {
 		rr := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodPost, "/", nil)
 		req = contextSetAuthenticatedUser(req, &database.User{
-			ID:                 uuid.New(),
-			CreatedAt:          time.Now(),
-			Username:           "TestUser",
-			HashedPassword:     "",
-			HasAdminPrivileges: true,
+			ID:             uuid.New(),
+			CreatedAt:      time.Now(),
+			Username:       "TestUser",
+			HashedPassword: "",
+			Role:           roleAdmin,
 		})
+		req = contextSetPermissions(req, permissionSet{permissionViewJobs, permissionManageUsers})
 		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := contextGetAuthenticatedUser(r)
 			assert.Equal(t, user.Username, "TestUser")
 		})
-		app.requirePermission(next).ServeHTTP(rr, req)
+		app.requirePermission(permissionManageUsers)(next).ServeHTTP(rr, req)
 		rs := rr.Result()
 		assert.Equal(t, rs.StatusCode, http.StatusOK)
 	})
@@ -389,16 +392,17 @@ func TestRequirePrivileged(t *testing.T) {
 		rr := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodDelete, "/", nil)
 		req = contextSetAuthenticatedUser(req, &database.User{
-			ID:                 uuid.New(),
-			CreatedAt:          time.Now(),
-			Username:           "TestUser",
-			HashedPassword:     "",
-			HasAdminPrivileges: false,
+			ID:             uuid.New(),
+			CreatedAt:      time.Now(),
+			Username:       "TestUser",
+			HashedPassword: "",
+			Role:           roleViewer,
 		})
+		req = contextSetPermissions(req, permissionSet{permissionViewJobs})
 		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			t.Fatal("Unprivileged user managed to access privileged endpoint with POST!")
 		})
-		app.requirePermission(next).ServeHTTP(rr, req)
+		app.requirePermission(permissionManageUsers)(next).ServeHTTP(rr, req)
 		rs := rr.Result()
 		assert.Equal(t, rs.StatusCode, http.StatusForbidden)
 	})
@@ -406,17 +410,18 @@ func TestRequirePrivileged(t *testing.T) {
 		rr := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodDelete, "/", nil)
 		req = contextSetAuthenticatedUser(req, &database.User{
-			ID:                 uuid.New(),
-			CreatedAt:          time.Now(),
-			Username:           "TestUser",
-			HashedPassword:     "",
-			HasAdminPrivileges: true,
+			ID:             uuid.New(),
+			CreatedAt:      time.Now(),
+			Username:       "TestUser",
+			HashedPassword: "",
+			Role:           roleAdmin,
 		})
+		req = contextSetPermissions(req, permissionSet{permissionViewJobs, permissionManageUsers})
 		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := contextGetAuthenticatedUser(r)
 			assert.Equal(t, user.Username, "TestUser")
 		})
-		app.requirePermission(next).ServeHTTP(rr, req)
+		app.requirePermission(permissionManageUsers)(next).ServeHTTP(rr, req)
 		rs := rr.Result()
 		assert.Equal(t, rs.StatusCode, http.StatusOK)
 	})
@@ -424,16 +429,17 @@ func TestRequirePrivileged(t *testing.T) {
 		rr := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodDelete, "/", nil)
 		req = contextSetAuthenticatedUser(req, &database.User{
-			ID:                 uuid.New(),
-			CreatedAt:          time.Now(),
-			Username:           "TestUser",
-			HashedPassword:     "",
-			HasAdminPrivileges: false,
+			ID:             uuid.New(),
+			CreatedAt:      time.Now(),
+			Username:       "TestUser",
+			HashedPassword: "",
+			Role:           roleViewer,
 		})
+		req = contextSetPermissions(req, permissionSet{permissionViewJobs})
 		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			t.Fatal("Unprivileged user managed to access privileged endpoint with DELETE!")
 		})
-		app.requirePermission(next).ServeHTTP(rr, req)
+		app.requirePermission(permissionManageUsers)(next).ServeHTTP(rr, req)
 		rs := rr.Result()
 		assert.Equal(t, rs.StatusCode, http.StatusForbidden)
 	})
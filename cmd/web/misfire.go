@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/robfig/cron/v3"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Misfire policies decide what happens on startup with the fires a task missed while goscrapyd was down, stored in
+// tasks.misfire_policy.
+const (
+	// misfireIgnore drops the missed fires, the task waits for its next scheduled fire
+	misfireIgnore = "ignore"
+	// misfireRunOnce fires once for any number of missed fires
+	misfireRunOnce = "run_once"
+	// misfireRunAll fires once for every missed fire, up to tasks.misfire_max_runs of the most recent ones
+	misfireRunAll = "run_all"
+)
+
+var misfirePolicies = []string{misfireIgnore, misfireRunOnce, misfireRunAll}
+
+// misfireMaxRunsLimit caps how many missed fires a task can catch up on.
+const misfireMaxRunsLimit = 100
+
+// misfireTriggeredBy is recorded on the jobs of catch-up fires.
+const misfireTriggeredBy = "misfire"
+
+// misfirePolicy decides what a task does on startup with the fires it missed while goscrapyd was down.
+type misfirePolicy struct {
+	Policy string
+	// MaxRuns caps how many of the most recent missed fires misfireRunAll catches up on
+	MaxRuns int
+}
+
+// defaultMisfirePolicy runs once, it matches the defaults of the tasks table.
+func defaultMisfirePolicy() misfirePolicy {
+	return misfirePolicy{Policy: misfireRunOnce, MaxRuns: 1}
+}
+
+func misfirePolicyFromTask(taskDb database.Task) misfirePolicy {
+	return misfirePolicy{Policy: taskDb.MisfirePolicy, MaxRuns: int(taskDb.MisfireMaxRuns)}
+}
+
+func (p misfirePolicy) validate(v *validator.Validator, policyField, maxRunsField string) {
+	v.CheckField(slices.Contains(misfirePolicies, p.Policy), policyField, fmt.Sprintf("Misfire policy must be one of %s", strings.Join(misfirePolicies, ", ")))
+	v.CheckField(p.MaxRuns >= 1 && p.MaxRuns <= misfireMaxRunsLimit, maxRunsField, fmt.Sprintf("Missed runs to catch up on must be between 1 and %d", misfireMaxRunsLimit))
+}
+
+// limit is how many missed fires the policy runs.
+func (p misfirePolicy) limit() int {
+	switch p.Policy {
+	case misfireRunOnce:
+		return 1
+	case misfireRunAll:
+		return min(max(p.MaxRuns, 1), misfireMaxRunsLimit)
+	}
+	return 0
+}
+
+// missedFires returns the fire times of schedule after since and up to now, only the last limit of them are kept.
+func missedFires(schedule string, since, now time.Time, limit int) ([]time.Time, error) {
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, err
+	}
+	var missed []time.Time
+	for next := parsed.Next(since); !next.IsZero() && !next.After(now); next = parsed.Next(next) {
+		missed = append(missed, next)
+		if len(missed) > limit {
+			missed = missed[1:]
+		}
+	}
+	return missed, nil
+}
+
+// misfiresToCatchUp are the missed fires of the task which its misfire policy runs. Tasks which never fired have nothing
+// to catch up on.
+func misfiresToCatchUp(taskDb database.Task, now time.Time) ([]time.Time, error) {
+	limit := misfirePolicyFromTask(taskDb).limit()
+	if !taskDb.LastFiredAt.Valid || limit == 0 {
+		return nil, nil
+	}
+	// The database hands back times in UTC, the schedule runs in the time zone of the task
+	loc, err := taskLocation(taskDb.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return missedFires(cronInTimezone(taskDb.CronString, taskDb.Timezone), taskDb.LastFiredAt.Time.In(loc), now, limit)
+}
+
+// catchUpMisfires fires the task once for every missed fire, one after another so the overlap policy of the task applies
+// between them. The jobs are named after the fire they make up for.
+func (t *task) catchUpMisfires(missed []time.Time) {
+	for _, at := range missed {
+		run := *t
+		run.TriggeredBy = misfireTriggeredBy
+		run.missedFire = at
+		_, err := run.fireNow("")
+		if err != nil {
+			t.Logger.Error("error catching up on a missed fire", slog.Any("task", t.ID), slog.Time("missed", at), slog.Any("err", err))
+		}
+	}
+}
+
+// markCaughtUp records now as the last fire of the task. Registering a task with the scheduler starts its schedule over,
+// so fires from before, e.g. while the task was paused, are never caught up on. Only the leader records it, the last fire
+// is what a new leader catches up from.
+func (t *task) markCaughtUp() {
+	if t.OneTimeJob || !t.cluster.isLeader() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := t.DB.UpdateTaskLastFiredAt(ctx, database.UpdateTaskLastFiredAtParams{
+		LastFiredAt: database.CreateCreateSqlNullTimeNonPtr(time.Now()),
+		ID:          t.ID,
+	})
+	if err != nil {
+		t.Logger.Error("error recording the last fire of a task", slog.Any("task", t.ID), slog.Any("err", err))
+	}
+}
+
+func setInsertTaskMisfirePolicy(params *database.InsertTaskParams, p misfirePolicy) {
+	params.MisfirePolicy = p.Policy
+	params.MisfireMaxRuns = int64(p.MaxRuns)
+}
+
+func setUpdateTaskMisfirePolicy(params *database.UpdateTaskParams, p misfirePolicy) {
+	params.MisfirePolicy = p.Policy
+	params.MisfireMaxRuns = int64(p.MaxRuns)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMissedFires(t *testing.T) {
+	since := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		schedule string
+		now      time.Time
+		limit    int
+		want     []string
+	}{
+		{"None missed", "0 * * * *", since.Add(20 * time.Minute), 5, nil},
+		{"All kept", "0 * * * *", since.Add(3 * time.Hour), 5, []string{"11:00", "12:00", "13:00"}},
+		{"Fire at now is missed", "0 * * * *", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), 5, []string{"11:00", "12:00"}},
+		{"Most recent kept", "0 * * * *", since.Add(3 * time.Hour), 2, []string{"12:00", "13:00"}},
+		{"Daily", "0 6 * * *", since.Add(24 * time.Hour), 1, []string{"06:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missed, err := missedFires(tt.schedule, since, tt.now, tt.limit)
+			assert.NilError(t, err)
+			var got []string
+			for _, at := range missed {
+				got = append(got, at.Format("15:04"))
+			}
+			assert.Equal(t, strings.Join(got, ","), strings.Join(tt.want, ","))
+		})
+	}
+	_, err := missedFires("not a schedule", since, since.Add(time.Hour), 1)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestMisfirePolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  misfirePolicy
+		invalid bool
+		limit   int
+	}{
+		{"Default", defaultMisfirePolicy(), false, 1},
+		{"Ignore", misfirePolicy{Policy: misfireIgnore, MaxRuns: 1}, false, 0},
+		{"Run all", misfirePolicy{Policy: misfireRunAll, MaxRuns: 24}, false, 24},
+		{"Unknown policy", misfirePolicy{Policy: "sometimes", MaxRuns: 1}, true, 0},
+		{"No runs", misfirePolicy{Policy: misfireRunAll, MaxRuns: 0}, true, 1},
+		{"Too many runs", misfirePolicy{Policy: misfireRunAll, MaxRuns: misfireMaxRunsLimit + 1}, true, misfireMaxRunsLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validator.Validator
+			tt.policy.validate(&v, "misfire_policy", "misfire_max_runs")
+			assert.Equal(t, v.HasErrors(), tt.invalid)
+			assert.Equal(t, tt.policy.limit(), tt.limit)
+		})
+	}
+}
+
+func TestLoadTasksOnStartCatchesUpMisfires(t *testing.T) {
+	ta := newTestApplication(t)
+	scheduler, err := gocron.NewScheduler(gocron.WithClock(clockwork.NewFakeClock()))
+	assert.NilError(t, err)
+	ta.scheduler = scheduler
+	ctx := context.Background()
+	var scheduled atomic.Int32
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			scheduled.Add(1)
+			_, err := w.Write([]byte(`{"node_name": "misfire_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err = ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: "misfire_node", Url: mockScrapyd.URL})
+	assert.NilError(t, err)
+	// Half past the hour three hours ago, an hourly task has missed exactly three fires since
+	now := time.Now()
+	// Truncate works in UTC, which is off by half an hour in some locations
+	hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, time.Local).Add(-3 * time.Hour)
+	lastFired := hour.Add(30 * time.Minute)
+	newTask := func(name string, policy misfirePolicy, lastFired time.Time) database.Task {
+		params := database.InsertTaskParams{
+			ID:                uuid.New(),
+			Name:              database.CreateSqlNullString(&name),
+			Project:           "shop",
+			Spider:            name,
+			Jobid:             name,
+			SettingsArguments: "project=shop&spider=" + name,
+			CronString:        "0 * * * *",
+			RetryMaxAttempts:  1,
+			OverlapPolicy:     overlapAllow,
+			FanOut:            fanOutAll,
+		}
+		setInsertTaskMisfirePolicy(&params, policy)
+		taskDb, err := ta.DB.queries.InsertTask(ctx, params)
+		assert.NilError(t, err)
+		assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{"misfire_node"}, nil))
+		if !lastFired.IsZero() {
+			err = ta.DB.queries.UpdateTaskLastFiredAt(ctx, database.UpdateTaskLastFiredAtParams{
+				LastFiredAt: database.CreateCreateSqlNullTimeNonPtr(lastFired),
+				ID:          taskDb.ID,
+			})
+			assert.NilError(t, err)
+		}
+		return taskDb
+	}
+	runAll := newTask("run_all", misfirePolicy{Policy: misfireRunAll, MaxRuns: 2}, lastFired)
+	runOnce := newTask("run_once", defaultMisfirePolicy(), lastFired)
+	newTask("ignore", misfirePolicy{Policy: misfireIgnore, MaxRuns: 1}, lastFired)
+	newTask("never_fired", defaultMisfirePolicy(), time.Time{})
+
+	loadedAt := time.Now().Add(-time.Second)
+	assert.NilError(t, ta.loadTasksOnStart())
+	deadline := time.Now().Add(10 * time.Second)
+	for scheduled.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Give any fire which shouldn't happen a moment to show up
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, scheduled.Load(), int32(3))
+
+	jobs, err := ta.DB.queries.SearchNodeJobs(ctx, database.SearchNodeJobsParams{SearchTerm: misfireTriggeredBy, Node: "misfire_node"})
+	assert.NilError(t, err)
+	var names []string
+	for _, job := range jobs {
+		assert.Equal(t, job.TriggeredBy.String, misfireTriggeredBy)
+		names = append(names, job.Job)
+	}
+	sort.Strings(names)
+	fireName := func(spider string, hours time.Duration) string {
+		return "task_" + spider + "_misfire_node_misfire_" + hour.Add(hours*time.Hour).Format("2006-01-02T15_04_05")
+	}
+	want := []string{fireName("run_all", 2), fireName("run_all", 3), fireName("run_once", 3)}
+	sort.Strings(want)
+	assert.Equal(t, strings.Join(names, ","), strings.Join(want, ","))
+
+	for _, id := range []uuid.UUID{runAll.ID, runOnce.ID} {
+		taskDb, err := ta.DB.queries.GetTaskWithUUID(ctx, id)
+		assert.NilError(t, err)
+		assert.Equal(t, taskDb.LastFiredAt.Time.After(loadedAt), true)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type nodeGroupForm struct {
+	Name      string              `form:"name"`
+	Nodes     []string            `form:"nodes"`
+	Validator validator.Validator `form:"-"`
+}
+
+// nodeGroup is a group with its members, as the node groups page shows it.
+type nodeGroup struct {
+	database.ListNodeGroupsRow
+	Members []string
+}
+
+func (g nodeGroup) HasMember(node string) bool {
+	return slices.Contains(g.Members, node)
+}
+
+// nodeGroups lists the groups and creates new ones. Tasks can target a group instead of single nodes.
+func (app *application) nodeGroups(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var form nodeGroupForm
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		err := request.DecodePostForm(r, &form)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		form.Name = strings.TrimSpace(form.Name)
+		form.Validator.CheckField(validator.NotBlank(form.Name), "name", "Name can not be blank")
+		_, err = app.DB.queries.GetNodeGroupByName(ctxwt, form.Name)
+		if err == nil {
+			form.Validator.AddFieldError("name", "A group with this name already exists")
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			app.serverError(w, r, err)
+			return
+		}
+		err = app.validateNodeGroupMembers(ctxwt, &form)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !form.Validator.HasErrors() {
+			params := database.InsertNodeGroupParams{Name: form.Name}
+			if user := contextGetAuthenticatedUser(r); user != nil {
+				params.CreatedBy = user.ID
+			}
+			group, err := app.DB.queries.InsertNodeGroup(ctxwt, params)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			err = app.setNodeGroupMembers(ctxwt, group.ID, form.Nodes)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, "/node-groups", http.StatusSeeOther)
+			return
+		}
+		status = http.StatusUnprocessableEntity
+	}
+	app.renderNodeGroups(w, r, status, form)
+}
+
+func (app *application) renderNodeGroups(w http.ResponseWriter, r *http.Request, status int, form nodeGroupForm) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	rows, err := app.DB.queries.ListNodeGroups(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	members, err := app.DB.queries.ListNodeGroupMembers(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	nodes, err := app.DB.queries.ListScrapydNodes(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	groups := make([]nodeGroup, 0, len(rows))
+	for _, row := range rows {
+		group := nodeGroup{ListNodeGroupsRow: row}
+		for _, member := range members {
+			if member.GroupID == row.ID {
+				group.Members = append(group.Members, member.Node)
+			}
+		}
+		groups = append(groups, group)
+	}
+	data := app.newTemplateData(r)
+	data["Groups"] = groups
+	data["Nodes"] = nodes
+	data["Form"] = form
+	app.render(w, r, status, nodeGroupsPage, nil, data)
+}
+
+// updateNodeGroup replaces the members of a group, the tasks targeting it pick them up on their next fire.
+func (app *application) updateNodeGroup(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	group, err := app.DB.queries.GetNodeGroup(ctxwt, groupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.badRequest(w, r, fmt.Errorf("node group %d doesn't exist", groupID))
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	var form nodeGroupForm
+	err = request.DecodePostForm(r, &form)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	form.Name = group.Name
+	err = app.validateNodeGroupMembers(ctxwt, &form)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if form.Validator.HasErrors() {
+		app.renderNodeGroups(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+	err = app.setNodeGroupMembers(ctxwt, group.ID, form.Nodes)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/node-groups", http.StatusSeeOther)
+}
+
+func (app *application) validateNodeGroupMembers(ctx context.Context, form *nodeGroupForm) error {
+	for _, node := range form.Nodes {
+		_, err := app.DB.queries.GetNodeWithName(ctx, node)
+		if errors.Is(err, sql.ErrNoRows) {
+			form.Validator.AddFieldError("nodes", fmt.Sprintf("Node %s does not exist", node))
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *application) setNodeGroupMembers(ctx context.Context, groupID int64, nodes []string) error {
+	err := app.DB.queries.DeleteNodeGroupMembers(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		err = app.DB.queries.InsertNodeGroupMember(ctx, database.InsertNodeGroupMemberParams{GroupID: groupID, Node: node})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteNodeGroup removes the group from every task targeting it, a task left without targets errors on its next fire.
+func (app *application) deleteNodeGroup(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	deleted, err := app.DB.queries.DeleteNodeGroup(ctxwt, groupID)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Overlap policies decide what a fire does while an earlier run of the same task is still active, stored in
+// tasks.overlap_policy.
+const (
+	// overlapAllow fires anyway, runs stack up on the node
+	overlapAllow = "allow"
+	// overlapSkip doesn't fire, the job is recorded as skipped along with the run it overlapped
+	overlapSkip = "skip"
+	// overlapQueue waits until the earlier runs are done, only one fire of a task waits at a time
+	overlapQueue = "queue"
+	// overlapCancelPrevious cancels the earlier runs and fires
+	overlapCancelPrevious = "cancel_previous"
+)
+
+var overlapPolicies = []string{overlapAllow, overlapSkip, overlapQueue, overlapCancelPrevious}
+
+// overlapQueueInterval is how often a queued fire checks whether the earlier runs are done, it gives up after
+// overlapQueueMaxWait.
+var (
+	overlapQueueInterval = 30 * time.Second
+	overlapQueueMaxWait  = 24 * time.Hour
+)
+
+// errQueuedJobFailed stops a queued fire whose jobs were failed as orphaned while it waited, see failOrphanedQueuedJobs.
+// The jobs already record why.
+var errQueuedJobFailed = errors.New("the queued jobs were failed while the fire waited")
+
+func validateOverlapPolicy(v *validator.Validator, field, policy string) {
+	v.CheckField(slices.Contains(overlapPolicies, policy), field, fmt.Sprintf("Overlap policy must be one of %s", strings.Join(overlapPolicies, ", ")))
+}
+
+// overlapSkippedError is a fire which the overlap policy didn't let through.
+type overlapSkippedError struct {
+	Reason string
+}
+
+func (e *overlapSkippedError) Error() string {
+	return "skipped, " + e.Reason
+}
+
+// activeRuns lists the earlier jobs of the task which are still queued, pending or running, the jobs of the current fire
+// are passed in runs and left out. Jobs which were sent to
+// Scrapyd are checked against a live listjobs.json of their node, so a row which missed its last update doesn't block
+// the task forever. When the node can't be asked the jobs table is trusted.
+func (t *task) activeRuns(ctx context.Context, runs []*task) ([]database.ListActiveJobsForTaskRow, error) {
+	exclude := make([]string, 0, len(runs))
+	for _, run := range runs {
+		exclude = append(exclude, run.JobID)
+	}
+	rows, err := t.DB.ListActiveJobsForTask(ctx, database.ListActiveJobsForTaskParams{TaskID: t.ID, ExcludeJobs: exclude})
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	live := make(map[string]map[string]bool)
+	var active []database.ListActiveJobsForTaskRow
+	for _, row := range rows {
+		if row.Status == "queued" {
+			active = append(active, row)
+			continue
+		}
+		key := row.Node + "/" + row.Project
+		ids, ok := live[key]
+		if !ok {
+			ids, err = t.liveJobs(ctx, row.Node, row.Project)
+			if err != nil {
+				t.Logger.WarnContext(ctx, "can't list jobs on node, trusting the jobs table", slog.Any("node", row.Node), slog.Any("err", err))
+			}
+			live[key] = ids
+		}
+		if ids == nil || ids[row.Job] {
+			active = append(active, row)
+		}
+	}
+	return active, nil
+}
+
+// liveJobs returns the IDs of the pending and running jobs of project on node.
+func (t *task) liveJobs(ctx context.Context, node, project string) (map[string]bool, error) {
+	req, err := makeRequestToScrapyd(ctx, t.DB, http.MethodGet, node, func(listJobsURL *url.URL) *url.URL {
+		listJobsURL.Path = path.Join(listJobsURL.Path, scrapydListJobsReq)
+		listJobsURL.RawQuery = url.Values{"project": {project}}.Encode()
+		return listJobsURL
+	}, nil, nil, t.Secret)
+	if err != nil {
+		return nil, err
+	}
+	response, err := requestJSONResourceFromScrapyd[scrapydListJobsResponse](req, t.Logger)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, job := range slices.Concat(response.Pending, response.Running) {
+		ids[job.Id] = true
+	}
+	return ids, nil
+}
+
+// checkOverlap applies the overlap policy to a fire made of runs, whose rows already exist. It returns an
+// *overlapSkippedError when the fire must not go ahead and queued when it has to wait for the earlier runs first, see
+// waitInQueue. Earlier runs are cancelled right away.
+func (t *task) checkOverlap(ctx context.Context, runs []*task) (queued bool, err error) {
+	if t.OneTimeJob || t.Overlap == "" || t.Overlap == overlapAllow {
+		return false, nil
+	}
+	active, err := t.activeRuns(ctx, runs)
+	if err != nil || len(active) == 0 {
+		return false, err
+	}
+	switch t.Overlap {
+	case overlapSkip:
+		return false, &overlapSkippedError{Reason: fmt.Sprintf("job %s is still %s", active[0].Job, active[0].Status)}
+	case overlapQueue:
+		for _, run := range active {
+			if run.Status == "queued" {
+				return false, &overlapSkippedError{Reason: fmt.Sprintf("job %s is already queued", run.Job)}
+			}
+		}
+		err = queueRuns(ctx, runs)
+		return err == nil, err
+	case overlapCancelPrevious:
+		for _, run := range active {
+			if run.Status == "queued" {
+				continue
+			}
+			_, err = t.cancelJob(ctx, run.Node, run.Project, run.Job, "", nil)
+			if err != nil {
+				return false, fmt.Errorf("cancelling previous job %s: %w", run.Job, err)
+			}
+			t.Logger.InfoContext(ctx, "cancelled previous run of task", slog.Any("task", t.ID), slog.Any("job", run.Job))
+		}
+	}
+	return false, nil
+}
+
+// waitInQueue blocks until the earlier runs of the task are done and moves the queued jobs back to scheduled. It renews
+// queued_until of the jobs while it waits and returns errQueuedJobFailed when they were failed as orphaned meanwhile.
+func (t *task) waitInQueue(ctx context.Context, runs []*task) error {
+	ctx, cancel := context.WithTimeout(ctx, overlapQueueMaxWait)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("gave up waiting for the previous run after %s", overlapQueueMaxWait)
+			}
+			return errors.New("the task was stopped while its fire was queued")
+		case <-time.After(overlapQueueInterval):
+		}
+		err := renewQueuedRuns(ctx, runs)
+		if err != nil {
+			return err
+		}
+		active, err := t.activeRuns(ctx, runs)
+		if err != nil {
+			t.Logger.WarnContext(ctx, "error checking the previous runs of a queued fire", slog.Any("task", t.ID), slog.Any("err", err))
+			continue
+		}
+		if len(active) == 0 {
+			return scheduleQueuedJobs(ctx, runs)
+		}
+	}
+}
+
+// queuedUntil is how long a queued job is kept from being failed as orphaned, the fire waiting on it renews it every
+// overlapQueueInterval.
+func queuedUntil(now time.Time) sql.NullTime {
+	return sql.NullTime{Time: now.Add(3 * overlapQueueInterval).UTC(), Valid: true}
+}
+
+func queueRuns(ctx context.Context, runs []*task) error {
+	for _, run := range runs {
+		err := run.DB.QueueJob(ctx, database.QueueJobParams{
+			QueuedUntil: queuedUntil(time.Now()), JobID: run.JobID, Project: run.Project, Node: run.NodeName,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renewQueuedRuns(ctx context.Context, runs []*task) error {
+	for _, run := range runs {
+		renewed, err := run.DB.RenewQueuedJob(ctx, database.RenewQueuedJobParams{
+			QueuedUntil: queuedUntil(time.Now()), JobID: run.JobID, Project: run.Project, Node: run.NodeName,
+		})
+		if err != nil {
+			return err
+		}
+		if renewed == 0 {
+			return errQueuedJobFailed
+		}
+	}
+	return nil
+}
+
+// scheduleQueuedJobs moves the queued jobs of runs to scheduled, unless they were failed as orphaned in the meantime.
+func scheduleQueuedJobs(ctx context.Context, runs []*task) error {
+	for _, run := range runs {
+		scheduled, err := run.DB.ScheduleQueuedJob(ctx, database.ScheduleQueuedJobParams{
+			JobID: run.JobID, Project: run.Project, Node: run.NodeName,
+		})
+		if err != nil {
+			return err
+		}
+		if scheduled == 0 {
+			return errQueuedJobFailed
+		}
+	}
+	return nil
+}
+
+// failOrphanedQueuedJobs is a scheduler job which marks the queued jobs nobody waits on anymore as errors, e.g. their
+// instance stopped. Queued jobs of fires still waiting, on any instance, are left alone as their queued_until is renewed.
+// As queued jobs the orphans would keep every later fire of their task from going ahead.
+func (app *application) failOrphanedQueuedJobs(ctx context.Context) error {
+	reason := base64.StdEncoding.EncodeToString([]byte("the fire was queued when its instance stopped waiting on it"))
+	failed, err := app.DB.queries.FailQueuedJobs(ctx, database.FailQueuedJobsParams{
+		Reason: database.CreateSqlNullString(&reason),
+		Now:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		app.logger.Warn("failed orphaned queued jobs", slog.Int64("jobs", failed))
+	}
+	return nil
+}
+
+func setRunsStatus(ctx context.Context, runs []*task, status string) error {
+	for _, run := range runs {
+		err := run.DB.SetJobStatus(ctx, database.SetJobStatusParams{Status: status, JobID: run.JobID, Project: run.Project, Node: run.NodeName})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSkippedFire reports whether err is a fire which the overlap policy, a blackout calendar or maintenance mode didn't
+// let through, rather than one which failed.
+func isSkippedFire(err error) bool {
+	var skipped *overlapSkippedError
+	var blackout *blackoutError
+	var maintenance *maintenanceError
+	return errors.As(err, &skipped) || errors.As(err, &blackout) || errors.As(err, &maintenance)
+}
+
+// recordFireError stores why a fire didn't reach Scrapyd, skipped and suppressed fires are kept apart from errors.
+func (t *task) recordFireError(jobID string, err error) {
+	var skipped *overlapSkippedError
+	var blackout *blackoutError
+	var maintenance *maintenanceError
+	var reason, message string
+	switch {
+	case errors.As(err, &skipped):
+		reason, message = skipped.Reason, "fire skipped by the overlap policy"
+	case errors.As(err, &blackout):
+		reason, message = blackout.Error(), "fire suppressed by a blackout calendar"
+	case errors.As(err, &maintenance):
+		reason, message = maintenance.Error(), "fire suppressed by maintenance mode"
+	default:
+		t.recordJobError(jobID, err)
+		return
+	}
+	t.finishRun(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	encoded := base64.StdEncoding.EncodeToString([]byte(reason))
+	dbErr := t.DB.SetJobStatus(ctx, database.SetJobStatusParams{
+		Status:  "skipped",
+		Error:   database.CreateSqlNullString(&encoded),
+		JobID:   jobID,
+		Project: t.Project,
+		Node:    t.NodeName,
+	})
+	if dbErr != nil {
+		t.Logger.ErrorContext(ctx, "error saving skipped fire into database", slog.Any("jobID", jobID), slog.Any("err", dbErr))
+	}
+	t.Logger.Info(message, slog.Any("task", t.ID), slog.Any("jobID", jobID), slog.Any("reason", reason))
+}
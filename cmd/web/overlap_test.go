@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/funcs"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTaskOverlapPolicy(t *testing.T) {
+	ta := newTestApplication(t)
+	ctx := context.Background()
+	var mu sync.Mutex
+	running := []string{}
+	var scheduled, cancelled atomic.Int32
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/listjobs.json":
+			mu.Lock()
+			jobs := ""
+			for i, job := range running {
+				if i > 0 {
+					jobs += ","
+				}
+				jobs += fmt.Sprintf(`{"id": %q, "project": "shop", "spider": "products"}`, job)
+			}
+			mu.Unlock()
+			_, err := fmt.Fprintf(w, `{"node_name": "overlap_node", "status": "ok", "pending": [], "running": [%s], "finished": []}`, jobs)
+			assert.NilError(t, err)
+		case "/cancel.json":
+			cancelled.Add(1)
+			mu.Lock()
+			running = []string{}
+			mu.Unlock()
+			_, err := w.Write([]byte(`{"node_name": "overlap_node", "status": "ok", "prevstate": "running"}`))
+			assert.NilError(t, err)
+		case "/schedule.json":
+			scheduled.Add(1)
+			_, err := w.Write([]byte(`{"node_name": "overlap_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	setRunning := func(jobs ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		running = jobs
+	}
+	_, err := ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{
+		Nodename: "overlap_node",
+		Url:      mockScrapyd.URL,
+	})
+	assert.NilError(t, err)
+	taskName := "overlap_task"
+	taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+		ID:                uuid.New(),
+		Name:              database.CreateSqlNullString(&taskName),
+		Project:           "shop",
+		Spider:            "products",
+		Jobid:             taskName,
+		SettingsArguments: "project=shop&spider=products",
+		CronString:        "*/10 * * * *",
+		Paused:            true,
+		RetryMaxAttempts:  1,
+		OverlapPolicy:     overlapSkip,
+	})
+	assert.NilError(t, err)
+	_, err = ta.DB.queries.InsertJob(ctx, database.InsertJobParams{
+		Project: "shop", Spider: "products", Job: "previous_job", Status: "running",
+		CreateTime: time.Now(), Node: "overlap_node", TaskID: taskDb.ID,
+	})
+	assert.NilError(t, err)
+	newFire := func(policy, jobID string) *task {
+		createdTask, err := ta.newTask(false, &taskDb.ID, taskName, "products", "shop", "overlap_node", url.Values{}, nil)
+		assert.NilError(t, err)
+		createdTask.Overlap = policy
+		createdTask.JobID = jobID
+		createdTask.SpiderValues.Set("jobid", jobID)
+		return createdTask
+	}
+	jobStatus := func(t *testing.T, jobID string) database.SearchNodeJobsRow {
+		jobs, err := ta.DB.queries.SearchNodeJobs(ctx, database.SearchNodeJobsParams{SearchTerm: jobID, Node: "overlap_node"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(jobs), 1)
+		return jobs[0]
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		setRunning("previous_job")
+		scheduled.Store(0)
+		err := newFire(overlapSkip, "skip_job").fireFunc(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, scheduled.Load(), int32(0))
+		job := jobStatus(t, "skip_job")
+		assert.Equal(t, job.Status, "skipped")
+		assert.Equal(t, funcs.SafeBase64Decode(job.Error.String), "job previous_job is still running")
+	})
+	t.Run("Skip ignores stale rows", func(t *testing.T) {
+		setRunning()
+		scheduled.Store(0)
+		err := newFire(overlapSkip, "stale_job").fireFunc(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, scheduled.Load(), int32(1))
+		assert.Equal(t, jobStatus(t, "stale_job").Status, "scheduled")
+	})
+	t.Run("Allow", func(t *testing.T) {
+		setRunning("previous_job", "stale_job")
+		scheduled.Store(0)
+		err := newFire(overlapAllow, "allow_job").fireFunc(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, scheduled.Load(), int32(1))
+	})
+	t.Run("Queue", func(t *testing.T) {
+		interval := overlapQueueInterval
+		overlapQueueInterval = 10 * time.Millisecond
+		defer func() { overlapQueueInterval = interval }()
+		setRunning("previous_job")
+		scheduled.Store(0)
+		done := make(chan error)
+		go func() { done <- newFire(overlapQueue, "queued_job").fireFunc(ctx) }()
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, jobStatus(t, "queued_job").Status, "queued")
+		assert.Equal(t, scheduled.Load(), int32(0))
+		err := newFire(overlapQueue, "waiting_job").fireFunc(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, funcs.SafeBase64Decode(jobStatus(t, "waiting_job").Error.String), "job queued_job is already queued")
+		// The fire still waits on the job, another instance sweeping for orphans leaves it alone
+		assert.NilError(t, ta.failOrphanedQueuedJobs(ctx))
+		assert.Equal(t, jobStatus(t, "queued_job").Status, "queued")
+		setRunning()
+		select {
+		case err := <-done:
+			assert.NilError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("queued fire never went ahead")
+		}
+		assert.Equal(t, scheduled.Load(), int32(1))
+		assert.Equal(t, jobStatus(t, "queued_job").Status, "scheduled")
+	})
+	t.Run("Orphaned queue", func(t *testing.T) {
+		setRunning()
+		scheduled.Store(0)
+		// A fire of an instance which stopped while it was queued
+		orphan := newFire(overlapQueue, "orphaned_job")
+		assert.NilError(t, orphan.insertJobIntoDB(ctx, "orphaned_job"))
+		assert.NilError(t, setRunsStatus(ctx, []*task{orphan}, "queued"))
+		err := newFire(overlapQueue, "blocked_job").fireFunc(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, scheduled.Load(), int32(0))
+		assert.Equal(t, funcs.SafeBase64Decode(jobStatus(t, "blocked_job").Error.String), "job orphaned_job is already queued")
+		assert.NilError(t, ta.failOrphanedQueuedJobs(ctx))
+		assert.Equal(t, jobStatus(t, "orphaned_job").Status, "error")
+		err = newFire(overlapQueue, "unblocked_job").fireFunc(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, scheduled.Load(), int32(1))
+		assert.Equal(t, jobStatus(t, "unblocked_job").Status, "scheduled")
+	})
+	t.Run("Failed while queued", func(t *testing.T) {
+		interval := overlapQueueInterval
+		overlapQueueInterval = 10 * time.Millisecond
+		defer func() { overlapQueueInterval = interval }()
+		setRunning("unblocked_job")
+		scheduled.Store(0)
+		done := make(chan error)
+		go func() { done <- newFire(overlapQueue, "abandoned_job").fireFunc(ctx) }()
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, jobStatus(t, "abandoned_job").Status, "queued")
+		// A sweep which sees the job's queued_until as expired, e.g. with the waiting instance stalled
+		reason := "failed by the sweep"
+		failed, err := ta.DB.queries.FailQueuedJobs(ctx, database.FailQueuedJobsParams{
+			Reason: database.CreateSqlNullString(&reason),
+			Now:    time.Now().Add(time.Hour).UTC(),
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, failed, int64(1))
+		setRunning()
+		select {
+		case err := <-done:
+			assert.Equal(t, errors.Is(err, errQueuedJobFailed), true)
+		case <-time.After(5 * time.Second):
+			t.Fatal("queued fire never stopped")
+		}
+		assert.Equal(t, scheduled.Load(), int32(0))
+		job := jobStatus(t, "abandoned_job")
+		assert.Equal(t, job.Status, "error")
+		assert.Equal(t, job.Error.String, reason)
+	})
+	t.Run("Cancel previous", func(t *testing.T) {
+		setRunning("queued_job")
+		scheduled.Store(0)
+		err := newFire(overlapCancelPrevious, "cancelling_job").fireFunc(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, cancelled.Load(), int32(1))
+		assert.Equal(t, scheduled.Load(), int32(1))
+		assert.Equal(t, jobStatus(t, "cancelling_job").Status, "scheduled")
+	})
+}
@@ -202,13 +202,15 @@ func (app *application) handleListOfSpiders(ctx context.Context, status, node st
 			itemsUrl := "/" + node + "/scrapyd-backend" + *spider.ItemsUrl
 			queryParams.HrefItems = database.CreateSqlNullString(&itemsUrl)
 		}
-		_, err := app.DB.queries.InsertJob(ctx, queryParams)
+		insertedJob, err := app.DB.queries.InsertJob(ctx, queryParams)
 		switch {
 		case err != nil && errors.Is(err, sql.ErrNoRows):
 			app.logger.DebugContext(ctx, "insert rejected with sql.ErrNoRows", slog.Any("project", spider.Project), slog.Any("job", spider.Id), slog.Any("spider", spider.Spider))
 		case err != nil:
 			app.logger.ErrorContext(ctx, "error inserting job", slog.Any("project", spider.Project), slog.Any("job", spider.Id), slog.Any("spider", spider.Spider), slog.Any("err", err))
 			continue
+		default:
+			app.jobFinished(ctx, insertedJob)
 		}
 	}
 }
@@ -235,14 +237,16 @@ func (app *application) doPartialUpdate(ctx context.Context, node, status string
 		itemsUrl := "/" + node + "/scrapyd-backend" + *spider.ItemsUrl
 		queryParams.HrefItems = database.CreateSqlNullString(&itemsUrl)
 	}
-	_, err := app.DB.queries.InsertJob(ctx, queryParams)
+	insertedJob, err := app.DB.queries.InsertJob(ctx, queryParams)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			app.logger.DebugContext(ctx, "insert rejected with sql.ErrNoRows", slog.Any("job", spider.Id), slog.Any("spider", spider.Spider), slog.Any("job", spider.Id))
 		} else {
 			app.logger.ErrorContext(ctx, "error inserting job", slog.Any("project", spider.Project), slog.Any("job", spider.Id), slog.Any("spider", spider.Spider), slog.Any("err", err))
 		}
+		return
 	}
+	app.jobFinished(ctx, insertedJob)
 }
 
 func (app *application) updateAllNodesSchedule() error {
@@ -297,5 +301,8 @@ func (app *application) updateAllNodesSchedule() error {
 			return nil
 		})
 	}
-	return g.Wait()
+	err = g.Wait()
+	// Job statuses are fresh now, which the max runtime checks rely on
+	app.enforceMaxRuntimes(ctx, time.Now())
+	return err
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of failures a retry policy can retry, stored comma separated in tasks.retry_on.
+const (
+	// retryOnNetwork is a node which can't be reached or doesn't answer in time
+	retryOnNetwork = "network"
+	// retryOnHTTP is Scrapyd answering with a status code other than 200
+	retryOnHTTP = "http"
+	// retryOnStatus is schedule.json answering with a status other than ok
+	retryOnStatus = "status"
+)
+
+var retryOnKinds = []string{retryOnNetwork, retryOnHTTP, retryOnStatus}
+
+const (
+	retryMaxAttemptsLimit = 10
+	retryMaxBackoffLimit  = time.Hour
+)
+
+// retryPolicy decides how often a failed fire is attempted again. The wait doubles after every attempt, starting at
+// Backoff and capped at MaxBackoff, with up to half of it taken off at random so tasks on the same schedule spread out.
+type retryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	RetryOn     []string
+}
+
+// defaultRetryPolicy doesn't retry, it matches the defaults of the tasks table.
+func defaultRetryPolicy() retryPolicy {
+	return retryPolicy{
+		MaxAttempts: 1,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  10 * time.Minute,
+		RetryOn:     []string{retryOnNetwork, retryOnHTTP},
+	}
+}
+
+func retryPolicyFromTask(taskDb database.Task) retryPolicy {
+	return retryPolicy{
+		MaxAttempts: int(taskDb.RetryMaxAttempts),
+		Backoff:     time.Duration(taskDb.RetryBackoffSeconds) * time.Second,
+		MaxBackoff:  time.Duration(taskDb.RetryMaxBackoffSeconds) * time.Second,
+		RetryOn:     parseRetryOn(taskDb.RetryOn),
+	}
+}
+
+func parseRetryOn(retryOn string) []string {
+	var kinds []string
+	for _, kind := range strings.Split(retryOn, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+func (p retryPolicy) BackoffSeconds() int {
+	return int(p.Backoff / time.Second)
+}
+
+func (p retryPolicy) MaxBackoffSeconds() int {
+	return int(p.MaxBackoff / time.Second)
+}
+
+func (p retryPolicy) RetriesOn(kind string) bool {
+	return slices.Contains(p.RetryOn, kind)
+}
+
+func (p retryPolicy) validate(v *validator.Validator, field string) {
+	v.CheckField(p.MaxAttempts >= 1 && p.MaxAttempts <= retryMaxAttemptsLimit, field, fmt.Sprintf("Attempts must be between 1 and %d", retryMaxAttemptsLimit))
+	v.CheckField(p.Backoff >= time.Second, field, "Backoff must be at least a second")
+	v.CheckField(p.MaxBackoff >= p.Backoff && p.MaxBackoff <= retryMaxBackoffLimit, field, fmt.Sprintf("Max backoff must be between the backoff and %s", retryMaxBackoffLimit))
+	for _, kind := range p.RetryOn {
+		v.CheckField(slices.Contains(retryOnKinds, kind), field, fmt.Sprintf("Can't retry on %q, use one of %s", kind, strings.Join(retryOnKinds, ", ")))
+	}
+}
+
+// retryable reports whether a fire which failed with err should be attempted again.
+func (p retryPolicy) retryable(err error) bool {
+	kind := retryKind(err)
+	return kind != "" && slices.Contains(p.RetryOn, kind)
+}
+
+// delay is how long to wait after the failed attempt, attempts count from 1.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, p.MaxBackoff)
+	if wait <= 0 {
+		return 0
+	}
+	// #nosec G404 -- jitter doesn't need a cryptographically secure source
+	return wait - rand.N(wait/2+1)
+}
+
+// retryKind sorts a fire error into one of the retryOn kinds, errors which aren't about talking to Scrapyd (e.g. a
+// missing node) have no kind and are never retried.
+func retryKind(err error) string {
+	var statusCodeErr *scrapydStatusCodeError
+	var scheduleErr *scrapydScheduleError
+	var urlErr *url.Error
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusCodeErr):
+		return retryOnHTTP
+	case errors.As(err, &scheduleErr):
+		return retryOnStatus
+	case errors.As(err, &urlErr), errors.As(err, &netErr):
+		return retryOnNetwork
+	}
+	return ""
+}
+
+func setInsertTaskRetryPolicy(params *database.InsertTaskParams, p retryPolicy) {
+	params.RetryMaxAttempts = int64(p.MaxAttempts)
+	params.RetryBackoffSeconds = int64(p.BackoffSeconds())
+	params.RetryMaxBackoffSeconds = int64(p.MaxBackoffSeconds())
+	params.RetryOn = strings.Join(p.RetryOn, ",")
+}
+
+func setUpdateTaskRetryPolicy(params *database.UpdateTaskParams, p retryPolicy) {
+	params.RetryMaxAttempts = int64(p.MaxAttempts)
+	params.RetryBackoffSeconds = int64(p.BackoffSeconds())
+	params.RetryMaxBackoffSeconds = int64(p.MaxBackoffSeconds())
+	params.RetryOn = strings.Join(p.RetryOn, ",")
+}
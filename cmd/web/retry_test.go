@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/funcs"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := retryPolicy{MaxAttempts: 10, Backoff: 10 * time.Second, MaxBackoff: time.Minute}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{9, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("Attempt %d", tt.attempt), func(t *testing.T) {
+			for range 20 {
+				delay := policy.delay(tt.attempt)
+				assert.Equal(t, delay <= tt.want, true)
+				assert.Equal(t, delay >= tt.want/2, true)
+			}
+		})
+	}
+}
+
+func TestRetryKind(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Unreachable node", &url.Error{Op: "Post", URL: "http://node", Err: errors.New("connection refused")}, retryOnNetwork},
+		{"Status code", &scrapydStatusCodeError{StatusCode: http.StatusServiceUnavailable}, retryOnHTTP},
+		{"Schedule status", &scrapydScheduleError{Status: "error"}, retryOnStatus},
+		{"Wrapped", fmt.Errorf("scheduling: %w", &scrapydScheduleError{Status: "error"}), retryOnStatus},
+		{"Other", errors.New("sql: no rows in result set"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, retryKind(tt.err), tt.want)
+		})
+	}
+	policy := retryPolicy{RetryOn: []string{retryOnNetwork}}
+	assert.Equal(t, policy.retryable(tests[0].err), true)
+	assert.Equal(t, policy.retryable(tests[1].err), false)
+	assert.Equal(t, policy.retryable(tests[4].err), false)
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  retryPolicy
+		wantErr bool
+	}{
+		{"Default", defaultRetryPolicy(), false},
+		{"No attempts", retryPolicy{MaxAttempts: 0, Backoff: time.Second, MaxBackoff: time.Second}, true},
+		{"Too many attempts", retryPolicy{MaxAttempts: retryMaxAttemptsLimit + 1, Backoff: time.Second, MaxBackoff: time.Second}, true},
+		{"Max below backoff", retryPolicy{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Second}, true},
+		{"Max above limit", retryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 2 * retryMaxBackoffLimit}, true},
+		{"Unknown kind", retryPolicy{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: time.Second, RetryOn: []string{"always"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validator.Validator
+			tt.policy.validate(&v, "retry")
+			assert.Equal(t, v.HasErrors(), tt.wantErr)
+		})
+	}
+}
+
+func TestTaskRetries(t *testing.T) {
+	ta := newTestApplication(t)
+	var calls atomic.Int32
+	var failures atomic.Int32
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"node_name": "retry_node", "status": "ok"}`))
+		assert.NilError(t, err)
+	}))
+	defer mockScrapyd.Close()
+	_, err := ta.DB.queries.NewScrapydNode(context.Background(), database.NewScrapydNodeParams{
+		Nodename: "retry_node",
+		Url:      mockScrapyd.URL,
+	})
+	assert.NilError(t, err)
+	fire := func(t *testing.T, jobID string, policy retryPolicy) (database.GetJobsForNodeRow, error) {
+		calls.Store(0)
+		createdTask, err := ta.newTask(true, nil, "retry_task", "test_spider", "test_project", "retry_node", url.Values{}, nil)
+		assert.NilError(t, err)
+		createdTask.Retry = policy
+		createdTask.JobID = jobID
+		createdTask.SpiderValues.Set("jobid", jobID)
+		fireErr := createdTask.fireFunc(context.Background())
+		jobs, err := ta.DB.queries.SearchNodeJobs(context.Background(), database.SearchNodeJobsParams{SearchTerm: jobID, Node: "retry_node"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(jobs), 1)
+		return database.GetJobsForNodeRow(jobs[0]), fireErr
+	}
+	policy := retryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, RetryOn: []string{retryOnHTTP}}
+
+	t.Run("Succeeds after retrying", func(t *testing.T) {
+		failures.Store(2)
+		job, err := fire(t, "retry_job_1", policy)
+		assert.NilError(t, err)
+		assert.Equal(t, calls.Load(), int32(3))
+		assert.Equal(t, job.Status, "scheduled")
+		assert.Equal(t, job.Attempts, int64(3))
+		assert.Equal(t, job.NextRetryAt.Valid, false)
+		assert.Equal(t, job.Error.Valid, false)
+	})
+	t.Run("Gives up", func(t *testing.T) {
+		failures.Store(5)
+		job, err := fire(t, "retry_job_2", policy)
+		assert.Equal(t, err != nil, true)
+		assert.Equal(t, calls.Load(), int32(3))
+		assert.Equal(t, job.Status, "error")
+		assert.Equal(t, job.Attempts, int64(3))
+		assert.Equal(t, job.NextRetryAt.Valid, false)
+		assert.StringContains(t, funcs.SafeBase64Decode(job.Error.String), "gave up after 3 attempts: request returned status code 503")
+	})
+	t.Run("Not retryable", func(t *testing.T) {
+		failures.Store(5)
+		job, err := fire(t, "retry_job_3", retryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, RetryOn: []string{retryOnNetwork}})
+		assert.Equal(t, err != nil, true)
+		assert.Equal(t, calls.Load(), int32(1))
+		assert.Equal(t, job.Status, "error")
+		assert.Equal(t, job.Attempts, int64(1))
+		assert.Equal(t, funcs.SafeBase64Decode(job.Error.String), "request returned status code 503")
+	})
+	t.Run("Stopped while waiting", func(t *testing.T) {
+		failures.Store(5)
+		ctx, cancel := context.WithCancel(context.Background())
+		createdTask, err := ta.newTask(true, nil, "retry_task", "test_spider", "test_project", "retry_node", url.Values{}, nil)
+		assert.NilError(t, err)
+		createdTask.Retry = retryPolicy{MaxAttempts: 3, Backoff: time.Hour, MaxBackoff: time.Hour, RetryOn: []string{retryOnHTTP}}
+		createdTask.JobID = "retry_job_4"
+		done := make(chan error)
+		go func() { done <- createdTask.fireFunc(ctx) }()
+		time.Sleep(100 * time.Millisecond)
+		jobs, err := ta.DB.queries.SearchNodeJobs(context.Background(), database.SearchNodeJobsParams{SearchTerm: "retry_job_4", Node: "retry_node"})
+		assert.NilError(t, err)
+		assert.Equal(t, jobs[0].NextRetryAt.Valid, true)
+		cancel()
+		err = <-done
+		assert.StringContains(t, err.Error(), "the task was stopped")
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"slices"
+)
+
+type permission = string
+
+// Permissions checked by routes and templates. Which role holds which permission lives in the role_permissions table.
+const (
+	permissionViewJobs       permission = "jobs:view"
+	permissionRunJobs        permission = "jobs:run"
+	permissionManageTasks    permission = "tasks:manage"
+	permissionDeployProjects permission = "projects:deploy"
+	permissionManageNodes    permission = "nodes:manage"
+	permissionManageUsers    permission = "users:manage"
+	permissionManageSettings permission = "settings:manage"
+	permissionViewMetrics    permission = "metrics:view"
+)
+
+const (
+	roleViewer   = "viewer"
+	roleOperator = "operator"
+	roleDeployer = "deployer"
+	roleAdmin    = "admin"
+)
+
+// permissionSet is what the authenticated user is allowed to do, templates check it with {{if .Can.Has "jobs:run"}}.
+type permissionSet []permission
+
+func (p permissionSet) Has(perm permission) bool {
+	return slices.Contains(p, perm)
+}
+
+func (app *application) permissionsForRole(ctx context.Context, role string) (permissionSet, error) {
+	permissions, err := app.DB.queries.ListPermissionsForRole(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	return permissionSet(permissions), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/password"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPermissionsForRole(t *testing.T) {
+	app := newTestApplication(t)
+	tests := []struct {
+		role    string
+		has     []permission
+		hasNot  []permission
+		wantLen int
+	}{
+		{roleViewer, []permission{permissionViewJobs}, []permission{permissionRunJobs, permissionManageTasks}, 1},
+		{roleOperator, []permission{permissionViewJobs, permissionRunJobs, permissionManageTasks}, []permission{permissionDeployProjects}, 3},
+		{roleDeployer, []permission{permissionRunJobs, permissionDeployProjects}, []permission{permissionManageNodes, permissionManageUsers}, 4},
+		{roleAdmin, []permission{permissionDeployProjects, permissionManageNodes, permissionManageUsers, permissionManageSettings, permissionViewMetrics}, nil, 8},
+		{"unknown", nil, []permission{permissionViewJobs}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			permissions, err := app.permissionsForRole(context.Background(), tt.role)
+			assert.NilError(t, err)
+			assert.Equal(t, len(permissions), tt.wantLen)
+			for _, perm := range tt.has {
+				assert.Equal(t, permissions.Has(perm), true)
+			}
+			for _, perm := range tt.hasNot {
+				assert.Equal(t, permissions.Has(perm), false)
+			}
+		})
+	}
+}
+
+func TestRoleEnforcement(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	tokens := map[string]string{}
+	for _, role := range []string{roleViewer, roleOperator} {
+		hashedPassword, err := password.Hash("ThisIsAVerySecurePasswordA$$word")
+		assert.NilError(t, err)
+		user, err := ta.DB.queries.CreateNewUser(context.Background(), database.CreateNewUserParams{
+			ID:             uuid.New(),
+			Username:       role + "_user",
+			HashedPassword: hashedPassword,
+			Role:           role,
+		})
+		assert.NilError(t, err)
+		token, _, err := ta.createAPIToken(context.Background(), user.ID, "ci", apiTokenScopeReadWrite, nil)
+		assert.NilError(t, err)
+		tokens[role] = token
+	}
+
+	t.Run("Viewer API", func(t *testing.T) {
+		assert.Equal(t, ts.doWithBearer(t, http.MethodGet, "/api/v1/tasks", tokens[roleViewer], ""), http.StatusOK)
+		assert.Equal(t, ts.doWithBearer(t, http.MethodPost, "/api/v1/tasks", tokens[roleViewer], "{}"), http.StatusForbidden)
+		assert.Equal(t, ts.doWithBearer(t, http.MethodPost, "/api/v1/nodes", tokens[roleViewer], "{}"), http.StatusForbidden)
+	})
+	t.Run("Operator API", func(t *testing.T) {
+		assert.Equal(t, ts.doWithBearer(t, http.MethodPost, "/api/v1/tasks", tokens[roleOperator], "{}"), http.StatusUnprocessableEntity)
+		assert.Equal(t, ts.doWithBearer(t, http.MethodPost, "/api/v1/nodes", tokens[roleOperator], "{}"), http.StatusForbidden)
+	})
+	t.Run("Scrapyd facade", func(t *testing.T) {
+		code, body := ts.doScrapyd(t, http.MethodPost, "/scrapyd/schedule.json", tokens[roleViewer], strings.NewReader("project=shop&spider=products"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusForbidden)
+		assert.Equal(t, body["status"], any("error"))
+		code, _ = ts.doScrapyd(t, http.MethodPost, "/scrapyd/addversion.json", tokens[roleOperator], strings.NewReader("project=shop"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+	t.Run("Viewer pages", func(t *testing.T) {
+		ts.loginAs(t, "viewer_user", "ThisIsAVerySecurePasswordA$$word")
+		code, _, body := ts.get(t, "/list-tasks")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringDoesNotContain(t, body, `href="/fire-spider"`)
+		assert.StringDoesNotContain(t, body, `href="/deploy-project"`)
+		assert.StringDoesNotContain(t, body, `href="/list-users"`)
+		for _, path := range []string{"/deploy-project", "/fire-spider", "/add-task", "/list-users", "/edit-settings"} {
+			code, _, _ = ts.get(t, path)
+			assert.Equal(t, code, http.StatusForbidden)
+		}
+	})
+}
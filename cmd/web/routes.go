@@ -11,59 +11,138 @@ func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
 	appMiddleware := alice.New(app.authenticate, app.rateLimit, app.logAccess)
 	reverseProxyMiddleware := alice.New(app.authenticate, app.logAccess)
+	apiMiddleware := alice.New(app.authenticate, app.rateLimit, app.logAccess, app.requireAuthenticatedAPIUser)
+	scrapydMiddleware := alice.New(app.rateLimit, app.logAccess, app.authenticateScrapydClient)
 	// Authenticated, access logged, CSRF protected routes
-	mux.Handle("GET /add-task", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.createNewTask))
-	mux.Handle("POST /add-task", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.createNewTask))
-	mux.Handle("GET /add-user", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.addNewUser))
-	mux.Handle("POST /add-user", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.addNewUser))
-	mux.Handle("POST /bulk-update-tasks", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.doBulkAction))
-	mux.Handle("GET /deploy-project", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.deploy))
-	mux.Handle("POST /deploy-project", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.deploy))
-	mux.Handle("GET /fire-spider", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.fireSpider))
-	mux.Handle("POST /fire-spider", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.fireSpider))
-	mux.Handle("GET /task/edit/{taskUUID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.editTask))
-	mux.Handle("POST /task/edit/{taskUUID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.editTask))
-	mux.Handle("GET /list-tasks", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.listTasks))
+	mux.Handle("GET /add-task", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.createNewTask))
+	mux.Handle("POST /add-task", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.createNewTask))
+	mux.Handle("GET /add-user", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.addNewUser))
+	mux.Handle("POST /add-user", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.addNewUser))
+	mux.Handle("POST /bulk-update-tasks", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.doBulkAction))
+	mux.Handle("GET /deploy-project", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionDeployProjects)).ThenFunc(app.deploy))
+	mux.Handle("POST /deploy-project", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionDeployProjects)).ThenFunc(app.deploy))
+	mux.Handle("GET /fire-spider", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionRunJobs)).ThenFunc(app.fireSpider))
+	mux.Handle("POST /fire-spider", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionRunJobs)).ThenFunc(app.fireSpider))
+	mux.Handle("GET /task/edit/{taskUUID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks), app.requireScope).ThenFunc(app.editTask))
+	mux.Handle("GET /scheduled-run/edit/{runID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionRunJobs), app.requireScope).ThenFunc(app.editScheduledRun))
+	mux.Handle("POST /scheduled-run/edit/{runID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionRunJobs), app.requireScope).ThenFunc(app.editScheduledRun))
+	mux.Handle("POST /task/edit/{taskUUID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks), app.requireScope).ThenFunc(app.editTask))
+	mux.Handle("GET /api-tokens", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.listAPITokens))
+	mux.Handle("POST /api-tokens", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.listAPITokens))
+	mux.Handle("GET /two-factor", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.twoFactorPage))
+	mux.Handle("POST /two-factor", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.twoFactorPage))
+	mux.Handle("GET /workflows", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs)).ThenFunc(app.workflows))
+	mux.Handle("POST /workflows", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.workflows))
+	mux.Handle("GET /blackout-calendars", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.blackoutCalendars))
+	mux.Handle("POST /blackout-calendars", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.blackoutCalendars))
+	mux.Handle("POST /blackout-calendars/{calendarID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.addBlackoutWindow))
+	mux.Handle("GET /list-tasks", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs)).ThenFunc(app.listTasks))
 	// Authenticated, access logged, but not CSRF protected
-	mux.Handle("GET /htmx-list-online-nodes", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.htmxListOnlineNodes))
-	mux.Handle("GET /list-nodes", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.listScrapydNodes))
-	mux.Handle("GET /{node}/jobs", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.nodeJobs))
-	mux.Handle("DELETE /delete-job/{jobId}", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.deleteJob))
-	mux.Handle("POST /fire-task/{jobUUID}", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.fireTask))
-	mux.Handle("DELETE /stop-task/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.stopTask))
-	mux.Handle("POST /restart-task/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.restartTask))
-	mux.Handle("DELETE /delete-task/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.deleteTask))
-	mux.Handle("POST /task/search", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.searchTasksTable))
-	mux.Handle("GET /job/view-logs/{jobId}", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.viewJobLogs))
-	mux.Handle("GET /deploy-sse", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser).ThenFunc(app.buildAndDeployEggSSE))
+	mux.Handle("GET /htmx-list-online-nodes", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs)).ThenFunc(app.htmxListOnlineNodes))
+	mux.Handle("GET /list-nodes", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs)).ThenFunc(app.listScrapydNodes))
+	mux.Handle("GET /{node}/jobs", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs), app.requireScope).ThenFunc(app.nodeJobs))
+	mux.Handle("DELETE /delete-job/{jobId}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionRunJobs), app.requireScope).ThenFunc(app.deleteJob))
+	mux.Handle("POST /fire-task/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks), app.requireScope).ThenFunc(app.fireTask))
+	mux.Handle("DELETE /stop-task/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks), app.requireScope).ThenFunc(app.stopTask))
+	mux.Handle("POST /restart-task/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks), app.requireScope).ThenFunc(app.restartTask))
+	mux.Handle("DELETE /delete-task/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks), app.requireScope).ThenFunc(app.deleteTask))
+	mux.Handle("DELETE /scheduled-run/{runID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionRunJobs), app.requireScope).ThenFunc(app.cancelScheduledRun))
+	mux.Handle("GET /task/runs/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs), app.requireScope).ThenFunc(app.taskRunsHistory))
+	mux.Handle("GET /task/revisions/{taskUUID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs), app.requireScope).ThenFunc(app.taskRevisionsPage))
+	mux.Handle("POST /task/revisions/{taskUUID}/revert/{revision}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks), app.requireScope).ThenFunc(app.revertTask))
+	mux.Handle("POST /task/webhook/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks), app.requireScope).ThenFunc(app.htmxTaskWebhook))
+	mux.Handle("DELETE /task/webhook/{taskUUID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks), app.requireScope).ThenFunc(app.htmxTaskWebhook))
+	mux.Handle("DELETE /workflows/dependencies/{dependencyID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.deleteTaskDependency))
+	mux.Handle("DELETE /blackout-calendars/{calendarID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.deleteBlackoutCalendar))
+	mux.Handle("DELETE /blackout-windows/{windowID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageTasks)).ThenFunc(app.deleteBlackoutWindow))
+	mux.Handle("POST /task/search", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs)).ThenFunc(app.searchTasksTable))
+	mux.Handle("GET /job/view-logs/{jobId}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs), app.requireScope).ThenFunc(app.viewJobLogs))
+	mux.Handle("GET /deploy-sse", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionDeployProjects)).ThenFunc(app.buildAndDeployEggSSE))
 	mux.Handle("GET /logout", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.logout))
-	mux.Handle("GET /htmx-fire-form", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.htmxFireForm))
-	mux.Handle("DELETE /{node}/stop-job/{project}/{job}", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.stopJob))
-	mux.Handle("GET /{node}/scrapyd-backend/", reverseProxyMiddleware.Append(app.requireAuthenticatedUser, app.reverseProxyMiddleware).Then(app.reverseProxy))
-	mux.Handle("POST /{node}/scrapyd-backend/", reverseProxyMiddleware.Append(app.requireAuthenticatedUser, app.reverseProxyMiddleware).Then(app.reverseProxy))
-	mux.Handle("POST /{node}/job/search", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.searchJobs))
-	mux.Handle("GET /versions", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.listVersions))
-	mux.Handle("GET /versions-htmx", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.listVersionsHTMX))
+	mux.Handle("GET /htmx-fire-form", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs)).ThenFunc(app.htmxFireForm))
+	mux.Handle("GET /schedule-preview", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs)).ThenFunc(app.htmxSchedulePreview))
+	mux.Handle("DELETE /{node}/stop-job/{project}/{job}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionRunJobs), app.requireScope).ThenFunc(app.stopJob))
+	mux.Handle("GET /{node}/scrapyd-backend/", reverseProxyMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs), app.requireScrapydBackendScope, app.reverseProxyMiddleware).Then(app.reverseProxy))
+	mux.Handle("POST /{node}/scrapyd-backend/", reverseProxyMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionDeployProjects), app.requireScrapydBackendScope, app.reverseProxyMiddleware).Then(app.reverseProxy))
+	mux.Handle("POST /{node}/job/search", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs), app.requireScope).ThenFunc(app.searchJobs))
+	mux.Handle("DELETE /api-tokens/{tokenID}", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.revokeAPIToken))
+	mux.Handle("GET /versions", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs)).ThenFunc(app.listVersions))
+	mux.Handle("GET /api-docs", appMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.apiDocs))
+	mux.Handle("GET /versions-htmx", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewJobs)).ThenFunc(app.listVersionsHTMX))
 	mux.Handle("GET /", appMiddleware.Append(app.requireAuthenticatedUser).Then(http.RedirectHandler("/list-nodes", http.StatusMovedPermanently)))
 	// Admin only routes
-	mux.Handle("GET /add-node", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.insertNewScrapydNode))
-	mux.Handle("POST /add-node", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.insertNewScrapydNode))
-	mux.Handle("GET /edit-settings", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.settingPage))
-	mux.Handle("POST /edit-settings", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.settingPage))
-	mux.Handle("GET /list-users", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.listsUsers))
-	mux.Handle("DELETE /user/delete/{userID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.deleteUser))
-	mux.Handle("GET /user/edit/{userID}", appMiddleware.Append(app.preventCSRF, app.requirePermission, app.requirePermission).ThenFunc(app.updateUser))
-	mux.Handle("POST /user/edit/{userID}", appMiddleware.Append(app.preventCSRF, app.requirePermission, app.requirePermission).ThenFunc(app.updateUser))
-	mux.Handle("DELETE /delete-node/{node}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.deleteScrapydNode))
-	mux.Handle("GET /node/edit/{node}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.editNode))
-	mux.Handle("POST /node/edit/{node}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.editNode))
-	mux.Handle("GET /metrics", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.metricsHandler))
-	mux.Handle("GET /metrics/json", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission).Then(expvar.Handler()))
-	mux.Handle("POST /upload-exported-data", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.importScrapydWebTimeTasksExport))
-	mux.Handle("GET /debug/pprof/", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission).ThenFunc(app.pprofHandler))
+	mux.Handle("GET /add-node", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageNodes)).ThenFunc(app.insertNewScrapydNode))
+	mux.Handle("POST /add-node", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageNodes)).ThenFunc(app.insertNewScrapydNode))
+	mux.Handle("GET /edit-settings", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageSettings)).ThenFunc(app.settingPage))
+	mux.Handle("POST /edit-settings", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageSettings)).ThenFunc(app.settingPage))
+	mux.Handle("POST /edit-settings/max-runtimes", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageSettings)).ThenFunc(app.saveProjectMaxRuntime))
+	mux.Handle("DELETE /edit-settings/max-runtimes/{project}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageSettings)).ThenFunc(app.deleteProjectMaxRuntime))
+	mux.Handle("GET /access-grants", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.listAccessGrants))
+	mux.Handle("POST /access-grants", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.listAccessGrants))
+	mux.Handle("POST /access-grants/lift", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.liftAccessRestriction))
+	mux.Handle("DELETE /access-grants/{grantID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.deleteAccessGrant))
+	mux.Handle("GET /list-users", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.listsUsers))
+	mux.Handle("POST /user/reset-two-factor/{userID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.resetUserTwoFactor))
+	mux.Handle("DELETE /user/delete/{userID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.deleteUser))
+	mux.Handle("GET /user/edit/{userID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.updateUser))
+	mux.Handle("POST /user/edit/{userID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageUsers)).ThenFunc(app.updateUser))
+	mux.Handle("GET /node-groups", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageNodes)).ThenFunc(app.nodeGroups))
+	mux.Handle("POST /node-groups", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageNodes)).ThenFunc(app.nodeGroups))
+	mux.Handle("POST /node-groups/{groupID}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageNodes)).ThenFunc(app.updateNodeGroup))
+	mux.Handle("DELETE /node-groups/{groupID}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageNodes)).ThenFunc(app.deleteNodeGroup))
+	mux.Handle("DELETE /delete-node/{node}", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionManageNodes)).ThenFunc(app.deleteScrapydNode))
+	mux.Handle("GET /node/edit/{node}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageNodes)).ThenFunc(app.editNode))
+	mux.Handle("POST /node/edit/{node}", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageNodes)).ThenFunc(app.editNode))
+	mux.Handle("GET /metrics", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewMetrics)).ThenFunc(app.metricsHandler))
+	mux.Handle("GET /metrics/json", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewMetrics)).Then(expvar.Handler()))
+	mux.Handle("GET /maintenance", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageSettings)).ThenFunc(app.maintenance))
+	mux.Handle("POST /maintenance", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageSettings)).ThenFunc(app.maintenance))
+	mux.Handle("POST /maintenance/end", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageSettings)).ThenFunc(app.endMaintenance))
+	mux.Handle("POST /upload-exported-data", appMiddleware.Append(app.preventCSRF, app.requireAuthenticatedUser, app.requirePermission(permissionManageSettings)).ThenFunc(app.importScrapydWebTimeTasksExport))
+	mux.Handle("GET /debug/pprof/", appMiddleware.Append(app.requireAuthenticatedUser, app.requirePermission(permissionViewMetrics)).ThenFunc(app.pprofHandler))
+	// The API description is public so clients and code generators can fetch it without a token
+	mux.Handle("GET /api/openapi.json", alice.New(app.rateLimit, app.logAccess).ThenFunc(app.apiOpenAPI))
+	// JSON API routes, authenticated but not CSRF protected (see readAPIJSON)
+	mux.Handle("GET /api/v1/nodes", apiMiddleware.Append(app.requireAPIPermission(permissionViewJobs)).ThenFunc(app.apiListNodes))
+	mux.Handle("GET /api/v1/nodes/{node}", apiMiddleware.Append(app.requireAPIPermission(permissionViewJobs), app.requireAPIScope).ThenFunc(app.apiGetNode))
+	mux.Handle("GET /api/v1/nodes/{node}/status", apiMiddleware.Append(app.requireAPIPermission(permissionViewJobs), app.requireAPIScope).ThenFunc(app.apiNodeStatus))
+	mux.Handle("POST /api/v1/nodes", apiMiddleware.Append(app.requireAPIPermission(permissionManageNodes)).ThenFunc(app.apiCreateNode))
+	mux.Handle("PUT /api/v1/nodes/{node}", apiMiddleware.Append(app.requireAPIPermission(permissionManageNodes), app.requireAPIScope).ThenFunc(app.apiUpdateNode))
+	mux.Handle("DELETE /api/v1/nodes/{node}", apiMiddleware.Append(app.requireAPIPermission(permissionManageNodes), app.requireAPIScope).ThenFunc(app.apiDeleteNode))
+	mux.Handle("GET /api/v1/tasks", apiMiddleware.Append(app.requireAPIPermission(permissionViewJobs)).ThenFunc(app.apiListTasks))
+	mux.Handle("POST /api/v1/tasks", apiMiddleware.Append(app.requireAPIPermission(permissionManageTasks)).ThenFunc(app.apiCreateTask))
+	mux.Handle("GET /api/v1/tasks/{taskUUID}", apiMiddleware.Append(app.requireAPIPermission(permissionViewJobs), app.requireAPIScope).ThenFunc(app.apiGetTask))
+	mux.Handle("PUT /api/v1/tasks/{taskUUID}", apiMiddleware.Append(app.requireAPIPermission(permissionManageTasks), app.requireAPIScope).ThenFunc(app.apiUpdateTask))
+	mux.Handle("DELETE /api/v1/tasks/{taskUUID}", apiMiddleware.Append(app.requireAPIPermission(permissionManageTasks), app.requireAPIScope).ThenFunc(app.apiDeleteTask))
+	mux.Handle("POST /api/v1/tasks/{taskUUID}/pause", apiMiddleware.Append(app.requireAPIPermission(permissionManageTasks), app.requireAPIScope).ThenFunc(app.apiPauseTask))
+	mux.Handle("POST /api/v1/tasks/{taskUUID}/resume", apiMiddleware.Append(app.requireAPIPermission(permissionManageTasks), app.requireAPIScope).ThenFunc(app.apiResumeTask))
+	mux.Handle("POST /api/v1/tasks/{taskUUID}/fire", apiMiddleware.Append(app.requireAPIPermission(permissionManageTasks), app.requireAPIScope).ThenFunc(app.apiFireTask))
+	mux.Handle("GET /api/v1/tasks/{taskUUID}/webhook", apiMiddleware.Append(app.requireAPIPermission(permissionViewJobs), app.requireAPIScope).ThenFunc(app.apiGetTaskWebhook))
+	mux.Handle("POST /api/v1/tasks/{taskUUID}/webhook", apiMiddleware.Append(app.requireAPIPermission(permissionManageTasks), app.requireAPIScope).ThenFunc(app.apiCreateTaskWebhook))
+	mux.Handle("DELETE /api/v1/tasks/{taskUUID}/webhook", apiMiddleware.Append(app.requireAPIPermission(permissionManageTasks), app.requireAPIScope).ThenFunc(app.apiDeleteTaskWebhook))
+	mux.Handle("GET /api/v1/schedule-preview", apiMiddleware.Append(app.requireAPIPermission(permissionViewJobs)).ThenFunc(app.apiPreviewSchedule))
+	mux.Handle("GET /api/v1/jobs", apiMiddleware.Append(app.requireAPIPermission(permissionViewJobs)).ThenFunc(app.apiListJobs))
+	mux.Handle("GET /api/v1/maintenance", apiMiddleware.Append(app.requireAPIPermission(permissionViewJobs)).ThenFunc(app.apiGetMaintenance))
+	mux.Handle("PUT /api/v1/maintenance", apiMiddleware.Append(app.requireAPIPermission(permissionManageSettings)).ThenFunc(app.apiStartMaintenance))
+	mux.Handle("DELETE /api/v1/maintenance", apiMiddleware.Append(app.requireAPIPermission(permissionManageSettings)).ThenFunc(app.apiEndMaintenance))
+	mux.Handle("GET /api/v1/tokens", apiMiddleware.ThenFunc(app.apiListTokens))
+	mux.Handle("POST /api/v1/tokens", apiMiddleware.ThenFunc(app.apiCreateToken))
+	mux.Handle("DELETE /api/v1/tokens/{tokenID}", apiMiddleware.ThenFunc(app.apiRevokeToken))
+	// Scrapyd compatible endpoints, point scrapyd-client & co. at /scrapyd/ and authenticate with an API token
+	mux.Handle("GET /scrapyd/daemonstatus.json", scrapydMiddleware.Append(app.requireScrapydPermission(permissionViewJobs)).ThenFunc(app.facadeDaemonStatus))
+	mux.Handle("GET /scrapyd/listprojects.json", scrapydMiddleware.Append(app.requireScrapydPermission(permissionViewJobs)).ThenFunc(app.facadeListProjects))
+	mux.Handle("GET /scrapyd/listspiders.json", scrapydMiddleware.Append(app.requireScrapydPermission(permissionViewJobs)).ThenFunc(app.facadeListSpiders))
+	mux.Handle("GET /scrapyd/listjobs.json", scrapydMiddleware.Append(app.requireScrapydPermission(permissionViewJobs)).ThenFunc(app.facadeListJobs))
+	mux.Handle("POST /scrapyd/schedule.json", scrapydMiddleware.Append(app.requireScrapydPermission(permissionRunJobs)).ThenFunc(app.facadeSchedule))
+	mux.Handle("POST /scrapyd/cancel.json", scrapydMiddleware.Append(app.requireScrapydPermission(permissionRunJobs)).ThenFunc(app.facadeCancel))
+	mux.Handle("POST /scrapyd/addversion.json", scrapydMiddleware.Append(app.requireScrapydPermission(permissionDeployProjects)).ThenFunc(app.facadeAddVersion))
+	// Signed task webhooks, authenticated by their HMAC signature instead of a session or token
+	mux.Handle("POST /hooks/tasks/{taskUUID}", alice.New(app.rateLimit, app.logAccess).ThenFunc(app.fireTaskWebhook))
 	// Anonymous user routes
 	mux.Handle("GET /login", appMiddleware.Append(app.preventCSRF, app.requireAnonymousUser).ThenFunc(app.login))
 	mux.Handle("POST /login", appMiddleware.Append(app.preventCSRF, app.requireAnonymousUser).ThenFunc(app.login))
+	mux.Handle("GET /login/two-factor", appMiddleware.Append(app.preventCSRF, app.requireAnonymousUser).ThenFunc(app.loginTwoFactor))
+	mux.Handle("POST /login/two-factor", appMiddleware.Append(app.preventCSRF, app.requireAnonymousUser).ThenFunc(app.loginTwoFactor))
 	fileServer := http.FileServer(http.FS(assets.EmbeddedFiles))
 	mux.Handle("GET /ui/static/", http.StripPrefix("/ui", fileServer))
 	defaultMiddleware := alice.New(app.metricsMiddleware, app.recoverPanic, app.securityHeaders)
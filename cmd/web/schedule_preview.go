@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"github.com/blazskufca/goscrapyd/internal/crondesc"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/robfig/cron/v3"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// schedulePreviewFires is how many upcoming fires the task forms show.
+	schedulePreviewFires = 10
+	// schedulePreviewMaxFires caps the fires the API previews at once.
+	schedulePreviewMaxFires = 100
+)
+
+// scheduleFire is an upcoming fire of a schedule. SuppressedBy explains why a blackout calendar would skip it.
+type scheduleFire struct {
+	At           time.Time
+	SuppressedBy string
+}
+
+// schedulePreview is a dry run of a schedule, so mistakes like 0 * * * * versus * 0 * * * show before a task is saved.
+type schedulePreview struct {
+	Cron        string
+	Timezone    string
+	Description string
+	Fires       []scheduleFire
+}
+
+// upcomingFires are the next count fires of schedule after from, in the location of from. It parses the schedule the
+// same way the task forms validate it, so a prefix added by cronInTimezone is honoured.
+func upcomingFires(schedule string, from time.Time, count int) ([]time.Time, error) {
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, err
+	}
+	fires := make([]time.Time, 0, count)
+	for next := parsed.Next(from); !next.IsZero() && len(fires) < count; next = parsed.Next(next) {
+		fires = append(fires, next)
+	}
+	return fires, nil
+}
+
+// validateSchedulePreview checks what the task forms check for the cron expression and time zone of a task.
+func validateSchedulePreview(v *validator.Validator, cronField, timezoneField, schedule, timezone string) {
+	v.CheckField(validator.NotBlank(schedule), cronField, "Enter a cron expression to see when it fires")
+	validateTimezone(v, timezoneField, timezone)
+	if !v.HasErrors() {
+		_, err := cron.ParseStandard(cronInTimezone(schedule, timezone))
+		v.CheckField(err == nil, cronField, "Not a valid/supported cron string. Please see https://en.wikipedia.org/wiki/Cron")
+	}
+}
+
+// previewSchedule works out the next count fires of a schedule which has already passed validateSchedulePreview, and
+// which of them the blackout calendars would suppress.
+func (app *application) previewSchedule(ctx context.Context, schedule, timezone string, calendarIDs []int64, from time.Time, count int) (schedulePreview, error) {
+	preview := schedulePreview{Cron: schedule, Timezone: timezone}
+	// A schedule the describer doesn't follow still gets its fires previewed, just without the description
+	preview.Description, _ = crondesc.Describe(schedule)
+	loc, err := taskLocation(timezone)
+	if err != nil {
+		return schedulePreview{}, err
+	}
+	fires, err := upcomingFires(cronInTimezone(schedule, timezone), from.In(loc), count)
+	if err != nil {
+		return schedulePreview{}, err
+	}
+	blackouts, err := app.previewBlackouts(ctx, calendarIDs)
+	if err != nil {
+		return schedulePreview{}, err
+	}
+	for _, at := range fires {
+		fire := scheduleFire{At: at}
+		for _, blackout := range blackouts {
+			if blackout.Window.covers(at) {
+				fire.SuppressedBy = (&blackoutError{Calendar: blackout.Calendar, Window: blackout.Window.String()}).Error()
+				break
+			}
+		}
+		preview.Fires = append(preview.Fires, fire)
+	}
+	return preview, nil
+}
+
+// previewBlackout is a window of one of the previewed blackout calendars.
+type previewBlackout struct {
+	Calendar string
+	Window   blackoutWindow
+}
+
+// previewBlackouts are the windows of the calendars, in the order checkBlackout looks at them.
+func (app *application) previewBlackouts(ctx context.Context, calendarIDs []int64) ([]previewBlackout, error) {
+	if len(calendarIDs) == 0 {
+		return nil, nil
+	}
+	calendars, err := app.DB.queries.ListBlackoutCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := app.DB.queries.ListBlackoutWindows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var blackouts []previewBlackout
+	for _, calendar := range calendars {
+		if !slices.Contains(calendarIDs, calendar.ID) {
+			continue
+		}
+		for _, window := range windows {
+			if window.CalendarID == calendar.ID {
+				blackouts = append(blackouts, previewBlackout{Calendar: calendar.Name, Window: blackoutWindow{window}})
+			}
+		}
+	}
+	return blackouts, nil
+}
+
+type schedulePreviewForm struct {
+	CronTab   string              `form:"cron_input"`
+	Timezone  string              `form:"timezone"`
+	Calendars []int64             `form:"blackout_calendars"`
+	Validator validator.Validator `form:"-"`
+}
+
+// htmxSchedulePreview renders the schedule preview panel of the task forms from their cron, time zone and blackout
+// calendar fields. Invalid input is shown in the panel rather than as an error status, so htmx still swaps it in.
+func (app *application) htmxSchedulePreview(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	var form schedulePreviewForm
+	err := request.DecodeQueryString(r, &form)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	form.CronTab = strings.TrimSpace(form.CronTab)
+	form.Timezone = strings.TrimSpace(form.Timezone)
+	validateSchedulePreview(&form.Validator, "cron_input", "timezone", form.CronTab, form.Timezone)
+	data := map[string]any{"Form": form}
+	if form.Validator.HasErrors() {
+		app.renderHTMX(w, r, http.StatusOK, htmxSchedulePreview, nil, "htmx:SchedulePreview", data)
+		return
+	}
+	preview, err := app.previewSchedule(ctxwt, form.CronTab, form.Timezone, form.Calendars, time.Now(), schedulePreviewFires)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data["Preview"] = preview
+	app.renderHTMX(w, r, http.StatusOK, htmxSchedulePreview, nil, "htmx:SchedulePreview", data)
+}
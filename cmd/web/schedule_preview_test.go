@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/password"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpcomingFires(t *testing.T) {
+	from := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		schedule string
+		count    int
+		want     []string
+	}{
+		{"Hourly", "0 * * * *", 3, []string{"2024-03-01T11:00:00Z", "2024-03-01T12:00:00Z", "2024-03-01T13:00:00Z"}},
+		{"Every minute of an hour", "* 0 * * *", 2, []string{"2024-03-02T00:00:00Z", "2024-03-02T00:01:00Z"}},
+		{"In a time zone", cronInTimezone("0 6 * * *", "America/New_York"), 1, []string{"2024-03-01T11:00:00Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fires, err := upcomingFires(tt.schedule, from, tt.count)
+			assert.NilError(t, err)
+			var got []string
+			for _, at := range fires {
+				got = append(got, at.Format(time.RFC3339))
+			}
+			assert.Equal(t, strings.Join(got, ","), strings.Join(tt.want, ","))
+		})
+	}
+	_, err := upcomingFires("not a schedule", from, 1)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestSchedulePreview(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ctx := context.Background()
+
+	calendar, err := ta.DB.queries.InsertBlackoutCalendar(ctx, database.InsertBlackoutCalendarParams{Name: "weekends"})
+	assert.NilError(t, err)
+	_, err = ta.DB.queries.InsertBlackoutWindow(ctx, database.InsertBlackoutWindowParams{CalendarID: calendar.ID, Weekdays: "sat,sun"})
+	assert.NilError(t, err)
+
+	t.Run("Preview", func(t *testing.T) {
+		from := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+		preview, err := ta.previewSchedule(ctx, "0 9 * * *", "Asia/Tokyo", []int64{calendar.ID}, from, 3)
+		assert.NilError(t, err)
+		assert.Equal(t, preview.Description, "At 09:00")
+		assert.Equal(t, len(preview.Fires), 3)
+		// 10:30 UTC is already past 09:00 in Tokyo on Friday, the next two fires are on the weekend
+		assert.Equal(t, preview.Fires[0].At.Format(time.RFC3339), "2024-03-02T09:00:00+09:00")
+		assert.Equal(t, preview.Fires[0].SuppressedBy, "suppressed by blackout calendar weekends, sat, sun")
+		assert.Equal(t, preview.Fires[1].SuppressedBy, "suppressed by blackout calendar weekends, sat, sun")
+		assert.Equal(t, preview.Fires[2].At.Weekday(), time.Monday)
+		assert.Equal(t, preview.Fires[2].SuppressedBy, "")
+	})
+
+	t.Run("Task form panel", func(t *testing.T) {
+		query := url.Values{"cron_input": {"0 9 * * 1-5"}, "timezone": {"Europe/Berlin"}, "blackout_calendars": {strconv.FormatInt(calendar.ID, 10)}}
+		code, _, body := ts.get(t, "/schedule-preview?"+query.Encode())
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "At 09:00, on Monday through Friday (Europe/Berlin)")
+		assert.StringContains(t, body, "Next 10 fires")
+		assert.Equal(t, strings.Count(body, "<li>"), 10)
+
+		query.Set("cron_input", "0 9 * *")
+		code, _, body = ts.get(t, "/schedule-preview?"+query.Encode())
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Not a valid/supported cron string")
+		query.Set("timezone", "Mars/Olympus_Mons")
+		code, _, body = ts.get(t, "/schedule-preview?"+query.Encode())
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Not a known IANA time zone, such as Europe/Berlin")
+
+		code, _, body = ts.get(t, "/add-task")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `hx-get="/schedule-preview"`)
+	})
+
+	t.Run("API", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodGet, "/api/v1/schedule-preview?cron=*/15+*+*+*+*&count=4&blackout_calendars=weekends", nil)
+		assert.Equal(t, code, http.StatusOK)
+		var preview apiSchedulePreview
+		assert.NilError(t, json.Unmarshal(body, &preview))
+		assert.Equal(t, preview.Description, "Every 15 minutes")
+		assert.Equal(t, len(preview.Fires), 4)
+		assert.Equal(t, preview.Fires[1].At.Sub(preview.Fires[0].At), 15*time.Minute)
+
+		code, _, body = ts.doJSON(t, http.MethodGet, "/api/v1/schedule-preview?cron=0+6+*+*+*&count=500&blackout_calendars=holidays", nil)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		var envelope apiErrorEnvelope
+		assert.NilError(t, json.Unmarshal(body, &envelope))
+		assert.Equal(t, envelope.Error.Fields["count"], "Count must be a number between 1 and 100")
+		assert.Equal(t, envelope.Error.Fields["blackout_calendars"], "Blackout calendar holidays does not exist")
+	})
+
+	t.Run("Viewers can preview", func(t *testing.T) {
+		hashedPassword, err := password.Hash("ThisIsAVerySecurePasswordA$$word")
+		assert.NilError(t, err)
+		_, err = ta.DB.queries.CreateNewUser(ctx, database.CreateNewUserParams{
+			ID:             uuid.New(),
+			Username:       "preview_viewer",
+			HashedPassword: hashedPassword,
+			Role:           roleViewer,
+		})
+		assert.NilError(t, err)
+		viewer := newTestServer(t, ta.routes())
+		defer viewer.Close()
+		viewer.loginAs(t, "preview_viewer", "ThisIsAVerySecurePasswordA$$word")
+		code, _, _ := viewer.get(t, "/schedule-preview?cron_input=0+9+*+*+*")
+		assert.Equal(t, code, http.StatusOK)
+		code, _, _ = viewer.doJSON(t, http.MethodGet, "/api/v1/schedule-preview?cron=0+9+*+*+*", nil)
+		assert.Equal(t, code, http.StatusOK)
+	})
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Statuses of a scheduled run, stored in scheduled_runs.status.
+const (
+	scheduledRunPending = "pending"
+	// scheduledRunFiring is a run an instance claimed and is firing, see fireDueScheduledRuns
+	scheduledRunFiring = "firing"
+	scheduledRunFired  = "fired"
+	scheduledRunFailed = "failed"
+	// scheduledRunSkipped is a run none of whose nodes fired, because maintenance mode or the overlap policy suppressed
+	// the fire on every one of them
+	scheduledRunSkipped = "skipped"
+)
+
+// scheduledRunsInterval is how often the scheduled runs which are due are fired. They're looked up in the database
+// rather than registered with the scheduler, so runs created or changed on another instance started with -ha are
+// picked up as well.
+const scheduledRunsInterval = 15 * time.Second
+
+// scheduledRunTriggeredBy is recorded on the jobs of scheduled runs.
+const scheduledRunTriggeredBy = "scheduled_run"
+
+// runAtLayout is the format of datetime-local inputs.
+const runAtLayout = "2006-01-02T15:04"
+
+// scheduledRunForm is the part of the fire spider form which schedules the fire for later, and the form to change a
+// scheduled run before it fires.
+type scheduledRunForm struct {
+	RunAt     string              `form:"run_at"`
+	Timezone  string              `form:"run_at_timezone"`
+	Nodes     []string            `form:"fireNode"`
+	Validator validator.Validator `form:"-"`
+}
+
+// scheduledRunFormFields are the fields of scheduledRunForm, they aren't spider arguments.
+var scheduledRunFormFields = []string{"run_at", "run_at_timezone", "fireNode", "csrf_token", maintenanceOverrideField}
+
+// parseRunAt reads a datetime-local value in timezone, empty is time.Local.
+func parseRunAt(runAt, timezone string) (time.Time, error) {
+	loc, err := taskLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(runAtLayout, strings.TrimSpace(runAt), loc)
+}
+
+// validate checks the form and returns when the run fires.
+func (f *scheduledRunForm) validate(now time.Time) time.Time {
+	f.Timezone = strings.TrimSpace(f.Timezone)
+	return validateRunAt(&f.Validator, f.RunAt, f.Timezone, now)
+}
+
+// HasNode reports whether node is selected in the form.
+func (f scheduledRunForm) HasNode(node string) bool {
+	return slices.Contains(f.Nodes, node)
+}
+
+// validateRunAt checks that runAt, in timezone, is yet to come and returns it.
+func validateRunAt(v *validator.Validator, runAt, timezone string, now time.Time) time.Time {
+	validateTimezone(v, "run_at_timezone", timezone)
+	if _, invalid := v.FieldErrors["run_at_timezone"]; invalid {
+		return time.Time{}
+	}
+	at, err := parseRunAt(runAt, timezone)
+	v.CheckField(err == nil, "run_at", "Pick the date and time to run at")
+	if err == nil {
+		v.CheckField(at.After(now), "run_at", "The date and time to run at has already passed")
+	}
+	return at
+}
+
+// scheduledRun is a row of scheduled_runs with the nodes it fires on.
+type scheduledRun struct {
+	database.ListScheduledRunsRow
+	Nodes []string
+}
+
+// RunAtInZone is when the run fires in its own time zone.
+func (r scheduledRun) RunAtInZone() time.Time {
+	loc, err := taskLocation(r.Timezone)
+	if err != nil {
+		return r.RunAt
+	}
+	return r.RunAt.In(loc)
+}
+
+// Pending reports whether the run is yet to fire, only then it can be changed or cancelled.
+func (r scheduledRun) Pending() bool {
+	return r.Status == scheduledRunPending
+}
+
+// scheduledRuns lists the scheduled runs the user's grants cover.
+func (app *application) scheduledRuns(ctx context.Context, scope accessScope) ([]scheduledRun, error) {
+	rows, err := app.DB.queries.ListScheduledRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := app.DB.queries.ListAllScheduledRunNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	runNodes := make(map[uuid.UUID][]string)
+	for _, node := range nodes {
+		runNodes[node.RunID] = append(runNodes[node.RunID], node.Node)
+	}
+	runs := make([]scheduledRun, 0, len(rows))
+	for _, row := range rows {
+		run := scheduledRun{ListScheduledRunsRow: row, Nodes: runNodes[row.ID]}
+		if scope.allowsTask(run.Project, run.Nodes) {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+// createScheduledRun stores a run of the spider at runAt on every one of nodes.
+func (app *application) createScheduledRun(ctx context.Context, project, spider string, args url.Values, runAt time.Time, timezone string, nodes []string, user *database.User) (uuid.UUID, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	params := database.InsertScheduledRunParams{
+		ID:                id,
+		Project:           project,
+		Spider:            spider,
+		SettingsArguments: args.Encode(),
+		RunAt:             runAt.UTC(),
+		Timezone:          timezone,
+	}
+	if user != nil {
+		params.CreatedBy = user.ID
+	}
+	err = app.DB.queries.InsertScheduledRun(ctx, params)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, app.setScheduledRunNodes(ctx, id, nodes)
+}
+
+func (app *application) setScheduledRunNodes(ctx context.Context, id uuid.UUID, nodes []string) error {
+	err := app.DB.queries.DeleteScheduledRunNodes(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		err = app.DB.queries.InsertScheduledRunNode(ctx, database.InsertScheduledRunNodeParams{RunID: id, Node: node})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fireDueScheduledRuns is a scheduler job which fires the scheduled runs which are due, including the ones which came due
+// while goscrapyd was down. Every run is claimed before it fires, so it only fires once even with several instances.
+func (app *application) fireDueScheduledRuns() error {
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.DefaultTimeout)
+	defer cancel()
+	due, err := app.DB.queries.ListDueScheduledRuns(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for _, run := range due {
+		claimed, err := app.DB.queries.ClaimScheduledRun(ctx, database.ClaimScheduledRunParams{
+			FiredAt: database.CreateCreateSqlNullTimeNonPtr(time.Now()),
+			ID:      run.ID,
+			RunAt:   run.RunAt,
+		})
+		if err != nil {
+			return err
+		}
+		if claimed == 0 {
+			continue
+		}
+		app.fireScheduledRun(run)
+	}
+	return nil
+}
+
+// fireScheduledRun fires a claimed run on every one of its nodes and records how it went, the jobs of the run are
+// attributed to the user who scheduled it.
+func (app *application) fireScheduledRun(run database.ScheduledRun) {
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.DefaultTimeout)
+	defer cancel()
+	var errs []error
+	args, err := url.ParseQuery(run.SettingsArguments)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	nodes, err := app.DB.queries.ListScheduledRunNodes(ctx, run.ID)
+	if err != nil {
+		errs = append(errs, err)
+	} else if len(nodes) == 0 {
+		errs = append(errs, errors.New("none of the nodes of the run exist anymore"))
+	}
+	user := app.scheduledRunUser(ctx, run.CreatedBy)
+	if len(errs) == 0 {
+		for _, node := range nodes {
+			currentTask, err := app.newTask(true, nil, fmt.Sprintf("Scheduled run of spider %s on node %s", run.Spider, node),
+				run.Spider, run.Project, node, args, user)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			currentTask.TriggeredBy = scheduledRunTriggeredBy
+			_, err = currentTask.fireNow(currentTask.nextJobID(node))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("node %s: %w", node, err))
+			}
+		}
+	}
+	params := database.FinishScheduledRunParams{Status: scheduledRunFired, ID: run.ID}
+	if err := errors.Join(errs...); err != nil {
+		errAsString := err.Error()
+		params.Status = scheduledRunFailed
+		if !slices.ContainsFunc(errs, func(err error) bool { return !isSkippedFire(err) }) {
+			params.Status = scheduledRunSkipped
+		}
+		params.Error = database.CreateSqlNullString(&errAsString)
+		app.logger.Error("error firing scheduled run", slog.Any("run", run.ID), slog.Any("err", err))
+	}
+	err = app.DB.queries.FinishScheduledRun(ctx, params)
+	if err != nil {
+		app.logger.Error("error recording the outcome of a scheduled run", slog.Any("run", run.ID), slog.Any("err", err))
+	}
+}
+
+// scheduledRunUser looks up the user who scheduled a run, nil when they were deleted since.
+func (app *application) scheduledRunUser(ctx context.Context, createdBy any) *database.User {
+	var id uuid.UUID
+	var err error
+	switch v := createdBy.(type) {
+	case string:
+		id, err = uuid.Parse(v)
+	case []byte:
+		id, err = uuid.ParseBytes(v)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	user, err := app.DB.queries.GetUserWithID(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return &user
+}
+
+// scheduledRunFromPath loads the scheduled run referenced by the runID path value, writing a 400 if it doesn't exist.
+func (app *application) scheduledRunFromPath(ctx context.Context, w http.ResponseWriter, r *http.Request) (database.ScheduledRun, bool) {
+	runID, err := uuid.Parse(r.PathValue("runID"))
+	if err != nil {
+		app.badRequest(w, r, err)
+		return database.ScheduledRun{}, false
+	}
+	run, err := app.DB.queries.GetScheduledRun(ctx, runID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.badRequest(w, r, fmt.Errorf("scheduled run %s doesn't exist", runID))
+		return database.ScheduledRun{}, false
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return database.ScheduledRun{}, false
+	}
+	return run, true
+}
+
+// editScheduledRun changes when and where a scheduled run fires and the arguments it fires with, until it fired.
+func (app *application) editScheduledRun(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	run, ok := app.scheduledRunFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	if run.Status != scheduledRunPending {
+		app.badRequest(w, r, fmt.Errorf("scheduled run %s already fired", run.ID))
+		return
+	}
+	nodes, err := app.DB.queries.ListScrapydNodes(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	scope := contextGetAccessScope(r)
+	nodes = scope.nodes(nodes)
+	args, err := url.ParseQuery(run.SettingsArguments)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	form := scheduledRunForm{Timezone: run.Timezone}
+	status := http.StatusOK
+	switch r.Method {
+	case http.MethodGet:
+		form.RunAt = scheduledRun{ListScheduledRunsRow: database.ListScheduledRunsRow{RunAt: run.RunAt, Timezone: run.Timezone}}.RunAtInZone().Format(runAtLayout)
+		form.Nodes, err = app.DB.queries.ListScheduledRunNodes(ctxwt, run.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	case http.MethodPost:
+		err = request.DecodePostForm(r, &form)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		runAt := form.validate(time.Now())
+		form.Validator.CheckField(len(form.Nodes) != 0, "node", "Select at least one node")
+		for _, node := range form.Nodes {
+			form.Validator.CheckField(scope.Allows(run.Project, node), "node", fmt.Sprintf("You don't have access to project %s on node %s", run.Project, node))
+		}
+		args = cleanUrlValues(maps.Clone(r.Form), scheduledRunFormFields...)
+		// The project and spider of a run don't change, they're sent to schedule.json along with the arguments
+		args.Set("project", run.Project)
+		args.Set("spider", run.Spider)
+		validateSpiderArgs(&form.Validator, "spider_args", args)
+		if !form.Validator.HasErrors() {
+			params := database.UpdateScheduledRunParams{
+				SettingsArguments: args.Encode(),
+				RunAt:             runAt.UTC(),
+				Timezone:          form.Timezone,
+				ID:                run.ID,
+			}
+			if user := contextGetAuthenticatedUser(r); user != nil {
+				params.ModifiedBy = user.ID
+			}
+			updated, err := app.DB.queries.UpdateScheduledRun(ctxwt, params)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			if updated == 0 {
+				app.badRequest(w, r, fmt.Errorf("scheduled run %s fired in the meantime", run.ID))
+				return
+			}
+			err = app.setScheduledRunNodes(ctxwt, run.ID, form.Nodes)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, "/list-tasks", http.StatusSeeOther)
+			return
+		}
+		status = http.StatusUnprocessableEntity
+	}
+	args.Del("project")
+	args.Del("spider")
+	args.Del("jobid")
+	data := app.newTemplateData(r)
+	data["Run"] = run
+	data["Form"] = form
+	data["Nodes"] = nodes
+	data["Settings"] = args
+	app.render(w, r, status, scheduledRunEditPage, nil, data)
+}
+
+// cancelScheduledRun deletes a scheduled run, a run which is yet to fire doesn't fire anymore.
+func (app *application) cancelScheduledRun(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	run, ok := app.scheduledRunFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	if run.Status == scheduledRunFiring {
+		app.badRequest(w, r, fmt.Errorf("scheduled run %s is firing", run.ID))
+		return
+	}
+	_, err := app.DB.queries.DeleteScheduledRun(ctxwt, run.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+}
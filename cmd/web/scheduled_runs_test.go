@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestValidateRunAt(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		runAt    string
+		timezone string
+		field    string
+		want     time.Time
+	}{
+		{"Future", "2024-03-02T02:00", "UTC", "", time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC)},
+		{"Time zone", "2024-03-01T14:00", "Europe/Berlin", "", time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"Passed", "2024-03-01T11:59", "UTC", "run_at", time.Time{}},
+		{"Passed in time zone", "2024-03-01T12:30", "Europe/Berlin", "run_at", time.Time{}},
+		{"Empty", "", "UTC", "run_at", time.Time{}},
+		{"Unknown time zone", "2024-03-02T02:00", "Mars/Olympus", "run_at_timezone", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validator.Validator
+			got := validateRunAt(&v, tt.runAt, tt.timezone, now)
+			if tt.field == "" {
+				assert.Equal(t, v.HasErrors(), false)
+				assert.Equal(t, got.Equal(tt.want), true)
+				return
+			}
+			_, hasError := v.FieldErrors[tt.field]
+			assert.Equal(t, hasError, true)
+		})
+	}
+}
+
+func TestScheduledRuns(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ctx := context.Background()
+	ta.config.ScrapydEncryptSecret = "thisis16bytes123"
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			_, err := w.Write([]byte(`{"node_name": "scheduled_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err := ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: "scheduled_node", Url: mockScrapyd.URL})
+	assert.NilError(t, err)
+	scheduleRun := func(t *testing.T, runAt time.Time) (int, http.Header, string) {
+		_, _, body := ts.get(t, "/fire-spider")
+		form := url.Values{}
+		form.Set("csrf_token", extractCSRFToken(t, body))
+		form.Set("project", "shop")
+		form.Set("spider", "backfill")
+		form.Set("fireNode", "scheduled_node")
+		form.Set("run_at", runAt.Format(runAtLayout))
+		form.Set("run_at_timezone", "Europe/Berlin")
+		form.Set("since", "2024-01-01")
+		return ts.postFormFollowRedirects(t, "/fire-spider", form)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NilError(t, err)
+
+	t.Run("Passed run at", func(t *testing.T) {
+		code, _, body := scheduleRun(t, time.Now().In(berlin).Add(-time.Hour))
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "has already passed")
+	})
+	runAt := time.Now().In(berlin).Add(24 * time.Hour).Truncate(time.Minute)
+	code, _, body := scheduleRun(t, runAt)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Scheduled Runs")
+	assert.StringContains(t, body, "backfill")
+	runs, err := ta.scheduledRuns(ctx, accessScope{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(runs), 1)
+	run := runs[0]
+	assert.Equal(t, run.Status, scheduledRunPending)
+	assert.Equal(t, run.RunAt.Equal(runAt), true)
+	assert.Equal(t, run.Timezone, "Europe/Berlin")
+	assert.Equal(t, len(run.Nodes), 1)
+	assert.StringContains(t, run.SettingsArguments, "since=2024-01-01")
+
+	t.Run("Not due", func(t *testing.T) {
+		assert.NilError(t, ta.fireDueScheduledRuns())
+		got, err := ta.DB.queries.GetScheduledRun(ctx, run.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, got.Status, scheduledRunPending)
+	})
+	t.Run("Edit", func(t *testing.T) {
+		code, _, body := ts.get(t, "/scheduled-run/edit/"+run.ID.String())
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, runAt.Format(runAtLayout))
+		assert.StringContains(t, body, `value="2024-01-01"`)
+		form := url.Values{}
+		form.Set("csrf_token", extractCSRFToken(t, body))
+		form.Set("fireNode", "scheduled_node")
+		form.Set("run_at", runAt.Add(time.Hour).Format(runAtLayout))
+		form.Set("run_at_timezone", "Europe/Berlin")
+		form.Set("since", "2024-02-01")
+		code, _, _ = ts.postForm(t, "/scheduled-run/edit/"+run.ID.String(), form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		got, err := ta.DB.queries.GetScheduledRun(ctx, run.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, got.RunAt.Equal(runAt.Add(time.Hour)), true)
+		assert.StringContains(t, got.SettingsArguments, "since=2024-02-01")
+		assert.StringContains(t, got.SettingsArguments, "spider=backfill")
+	})
+	t.Run("Fire when due", func(t *testing.T) {
+		_, err := ta.DB.queries.UpdateScheduledRun(ctx, database.UpdateScheduledRunParams{
+			SettingsArguments: "project=shop&spider=backfill",
+			RunAt:             time.Now().Add(-time.Minute).UTC(),
+			Timezone:          "Europe/Berlin",
+			ID:                run.ID,
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, ta.fireDueScheduledRuns())
+		got, err := ta.DB.queries.GetScheduledRun(ctx, run.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, got.Status, scheduledRunFired)
+		assert.Equal(t, got.FiredAt.Valid, true)
+		assert.NilError(t, ta.fireDueScheduledRuns())
+		jobs, err := ta.DB.queries.GetJobsForNode(ctx, database.GetJobsForNodeParams{Node: "scheduled_node", Limit: 1000})
+		assert.NilError(t, err)
+		assert.Equal(t, len(jobs), 1)
+		assert.Equal(t, jobs[0].Spider, "backfill")
+		assert.Equal(t, jobs[0].Status, "scheduled")
+	})
+	t.Run("Fired runs can't be edited", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/scheduled-run/edit/"+run.ID.String())
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+	t.Run("Cancel", func(t *testing.T) {
+		id, err := ta.createScheduledRun(ctx, "shop", "backfill", url.Values{"project": {"shop"}, "spider": {"backfill"}},
+			time.Now().Add(time.Hour), "", []string{"scheduled_node"}, nil)
+		assert.NilError(t, err)
+		code, _, _ := ts.delete(t, "/scheduled-run/"+id.String())
+		assert.Equal(t, code, http.StatusOK)
+		runs, err := ta.scheduledRuns(ctx, accessScope{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(runs), 1)
+	})
+}
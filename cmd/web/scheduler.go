@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/blazskufca/goscrapyd/internal/database"
@@ -11,9 +12,12 @@ import (
 	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 )
 
 type tasksBulkForm struct {
@@ -22,13 +26,77 @@ type tasksBulkForm struct {
 }
 
 type taskEditAddFormData struct {
-	Project     string              `form:"project"`
-	Spider      string              `form:"spider"`
-	TaskName    string              `form:"task_name"`
-	CronTab     string              `form:"cron_input"`
-	FireNodes   []string            `form:"fireNode"`
-	Immediately *bool               `form:"immediately"`
-	Validator   validator.Validator `form:"-"`
+	Project          string              `form:"project"`
+	Spider           string              `form:"spider"`
+	TaskName         string              `form:"task_name"`
+	CronTab          string              `form:"cron_input"`
+	FireNodes        []string            `form:"fireNode"`
+	FireGroups       []int64             `form:"fireGroup"`
+	FanOut           string              `form:"fan_out"`
+	Immediately      *bool               `form:"immediately"`
+	RetryMaxAttempts *int                `form:"retry_max_attempts"`
+	RetryBackoff     int                 `form:"retry_backoff_seconds"`
+	RetryMaxBackoff  int                 `form:"retry_max_backoff_seconds"`
+	RetryOn          []string            `form:"retry_on"`
+	OverlapPolicy    string              `form:"overlap_policy"`
+	MisfirePolicy    string              `form:"misfire_policy"`
+	MisfireMaxRuns   int                 `form:"misfire_max_runs"`
+	Timezone         string              `form:"timezone"`
+	Calendars        []int64             `form:"blackout_calendars"`
+	MaxRuntime       int                 `form:"max_runtime_minutes"`
+	Validator        validator.Validator `form:"-"`
+}
+
+// taskFormFields are the form fields which configure the task itself, everything else is passed on to the spider.
+var taskFormFields = []string{"fireNode", "fireGroup", "fan_out", "csrf_token", "cron_input", "task_name", "immediately",
+	"retry_max_attempts", "retry_backoff_seconds", "retry_max_backoff_seconds", "retry_on", "overlap_policy", "misfire_policy",
+	"misfire_max_runs", "timezone", "blackout_calendars", "max_runtime_minutes", maintenanceOverrideField}
+
+// retryPolicy is the default policy for forms without the retry fields.
+func (f *taskEditAddFormData) retryPolicy() retryPolicy {
+	if f.RetryMaxAttempts == nil {
+		return defaultRetryPolicy()
+	}
+	return retryPolicy{
+		MaxAttempts: *f.RetryMaxAttempts,
+		Backoff:     time.Duration(f.RetryBackoff) * time.Second,
+		MaxBackoff:  time.Duration(f.RetryMaxBackoff) * time.Second,
+		RetryOn:     f.RetryOn,
+	}
+}
+
+// overlapPolicy is overlapAllow for forms without the overlap field.
+func (f *taskEditAddFormData) overlapPolicy() string {
+	if f.OverlapPolicy == "" {
+		return overlapAllow
+	}
+	return f.OverlapPolicy
+}
+
+// misfirePolicy is the default policy for forms without the misfire fields.
+func (f *taskEditAddFormData) misfirePolicy() misfirePolicy {
+	if f.MisfirePolicy == "" {
+		return defaultMisfirePolicy()
+	}
+	return misfirePolicy{Policy: f.MisfirePolicy, MaxRuns: f.MisfireMaxRuns}
+}
+
+// maxRuntime is 0, no limit of the task's own, for forms without the max runtime field.
+func (f *taskEditAddFormData) maxRuntime() time.Duration {
+	return time.Duration(f.MaxRuntime) * time.Minute
+}
+
+func (f *taskEditAddFormData) calendars() taskCalendars {
+	return taskCalendars{Timezone: strings.TrimSpace(f.Timezone), Calendars: f.Calendars}
+}
+
+// targets is fanOutAll for forms without the fan-out field.
+func (f *taskEditAddFormData) targets() taskTargets {
+	targets := taskTargets{Nodes: f.FireNodes, Groups: f.FireGroups, FanOut: f.FanOut}
+	if targets.FanOut == "" {
+		targets.FanOut = fanOutAll
+	}
+	return targets
 }
 
 func (app *application) createNewTask(w http.ResponseWriter, r *http.Request) {
@@ -40,6 +108,18 @@ func (app *application) createNewTask(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
+	scope := contextGetAccessScope(r)
+	nodes = scope.nodes(nodes)
+	nodeGroups, err := app.DB.queries.ListNodeGroups(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	blackoutCalendars, err := app.DB.queries.ListBlackoutCalendars(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	preconfiguredSettings, err := app.getPreconfiguredSettings(ctxwt)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -50,6 +130,14 @@ func (app *application) createNewTask(w http.ResponseWriter, r *http.Request) {
 		templateData := app.newTemplateData(r)
 		templateData["PreconfiguredSettings"] = preconfiguredSettings
 		templateData["Nodes"] = nodes
+		templateData["NodeGroups"] = nodeGroups
+		templateData["Targets"] = taskTargets{FanOut: fanOutAll}
+		templateData["Retry"] = defaultRetryPolicy()
+		templateData["Overlap"] = overlapAllow
+		templateData["Misfire"] = defaultMisfirePolicy()
+		templateData["BlackoutCalendars"] = blackoutCalendars
+		templateData["Blackout"] = taskCalendars{}
+		templateData["MaxRuntimeMinutes"] = 0
 		app.render(w, r, http.StatusOK, addTaskPage, nil, templateData)
 	case http.MethodPost:
 		err := request.DecodePostForm(r, &formData)
@@ -62,64 +150,118 @@ func (app *application) createNewTask(w http.ResponseWriter, r *http.Request) {
 		if cronParseError != nil {
 			app.logger.ErrorContext(ctxwt, "got invalid/unknown cron schedule according to parse standard", slog.Any("cronString", formData.CronTab), slog.Any("err", cronParseError))
 		}
-		formData.Validator.CheckField(len(formData.FireNodes) != 0, "fireNodes", "You must select at least one node")
 		formData.Validator.CheckField(validator.NotBlank(formData.Project), "project", "You must select at least one project")
 		formData.Validator.CheckField(validator.NotBlank(formData.Spider), "spider", "You must select at least one spider")
 		formData.Validator.CheckField(validator.NotBlank(formData.CronTab), "cron_input", "You must schedule spider")
 		formData.Validator.CheckField(cronParseError == nil, "cron_input", "Not a valid/supported cron string. Please see https://en.wikipedia.org/wiki/Cron")
 		formData.Validator.CheckField(validator.NotBlank(formData.TaskName), "task_name", "Task name can not be blank")
+		retry := formData.retryPolicy()
+		retry.validate(&formData.Validator, "retry")
+		validateOverlapPolicy(&formData.Validator, "overlap_policy", formData.overlapPolicy())
+		misfire := formData.misfirePolicy()
+		misfire.validate(&formData.Validator, "misfire_policy", "misfire_max_runs")
+		validateMaxRuntime(&formData.Validator, "max_runtime_minutes", formData.maxRuntime())
+		validateSpiderArgs(&formData.Validator, "spider_args", cleanUrlValues(maps.Clone(r.PostForm), taskFormFields...))
+		targets := formData.targets()
+		err = validateTaskTargets(ctxwt, app.DB.queries, &formData.Validator, scope, formData.Project, targets, "fireNode", "fireGroup")
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		calendars := formData.calendars()
+		err = validateTaskCalendars(ctxwt, app.DB.queries, &formData.Validator, calendars, "timezone", "blackout_calendars")
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if formData.Immediately != nil && *formData.Immediately {
+			refusal, err := app.maintenanceRefusal(ctxwt, r)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			formData.Validator.CheckField(refusal == "", "maintenance", refusal)
+		}
 		if formData.Validator.HasErrors() {
 			data := app.newTemplateData(r)
 			data["Form"] = formData
 			data["Nodes"] = nodes
+			data["NodeGroups"] = nodeGroups
+			data["Targets"] = targets
 			data["PreconfiguredSettings"] = preconfiguredSettings
+			data["Retry"] = retry
+			data["Overlap"] = formData.overlapPolicy()
+			data["Misfire"] = misfire
+			data["BlackoutCalendars"] = blackoutCalendars
+			data["Blackout"] = calendars
+			data["MaxRuntimeMinutes"] = formData.MaxRuntime
 			app.render(w, r, http.StatusUnprocessableEntity, addTaskPage, nil, data)
 			return
 		}
 		// Cleanup form data, remove the metadata
-		cleanForm := cleanUrlValues(r.PostForm, "fireNode", "csrf_token", "cron_input", "task_name", "immediately")
-		var result []gocron.Job
-		for _, node := range formData.FireNodes {
-			createdTask, err := app.newTask(false, nil, formData.TaskName, formData.Spider, formData.Project, node, cleanForm, nil)
-			if app.checkCreateTaskError(w, r, createdTask, err) {
-				return
-			}
-			cronJob, err := createdTask.newCronJob(formData.CronTab)
-			if err != nil {
-				app.serverError(w, r, err)
-				return
-			}
-			if formData.Immediately != nil && *formData.Immediately {
-				err = cronJob.RunNow()
-				if err != nil {
-					app.serverError(w, r, err)
-					return
-				}
-			}
-			result = append(result, cronJob)
-			queryParams := database.InsertTaskParams{
-				ID:                cronJob.ID(),
-				Name:              database.CreateSqlNullString(&formData.TaskName),
-				Project:           formData.Project,
-				Spider:            formData.Spider,
-				Jobid:             formData.TaskName,
-				SettingsArguments: cleanForm.Encode(),
-				SelectedNodes:     node,
-				CronString:        formData.CronTab,
-				Paused:            false,
-			}
-			if user := contextGetAuthenticatedUser(r); user != nil {
-				queryParams.CreatedBy = user.ID
-			}
-			_, err = app.DB.queries.InsertTask(ctxwt, queryParams)
+		cleanForm := cleanUrlValues(r.PostForm, taskFormFields...)
+		createdTask, err := app.newTask(false, nil, formData.TaskName, formData.Spider, formData.Project, "", cleanForm, nil)
+		if app.checkCreateTaskError(w, r, createdTask, err) {
+			return
+		}
+		createdTask.Retry = retry
+		createdTask.Overlap = formData.overlapPolicy()
+		createdTask.FanOut = targets.FanOut
+		createdTask.Timezone = calendars.Timezone
+		queryParams := database.InsertTaskParams{
+			ID:                createdTask.ID,
+			Name:              database.CreateSqlNullString(&formData.TaskName),
+			Project:           formData.Project,
+			Spider:            formData.Spider,
+			Jobid:             formData.TaskName,
+			SettingsArguments: cleanForm.Encode(),
+			CronString:        formData.CronTab,
+			Paused:            false,
+			FanOut:            targets.FanOut,
+			Timezone:          calendars.Timezone,
+		}
+		queryParams.OverlapPolicy = formData.overlapPolicy()
+		setInsertTaskRetryPolicy(&queryParams, retry)
+		setInsertTaskMisfirePolicy(&queryParams, misfire)
+		queryParams.MaxRuntimeSeconds = nullMaxRuntime(formData.maxRuntime())
+		if user := contextGetAuthenticatedUser(r); user != nil {
+			queryParams.CreatedBy = user.ID
+		}
+		_, err = app.DB.queries.InsertTask(ctxwt, queryParams)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		// The targets and calendars have to be stored before the first fire, it reads them from the database
+		err = app.setTaskTargets(ctxwt, createdTask.ID, targets.Nodes, targets.Groups)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		err = app.setTaskBlackoutCalendars(ctxwt, createdTask.ID, calendars.Calendars)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		err = app.recordTaskRevision(ctxwt, createdTask.ID, contextGetAuthenticatedUser(r), 0)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		cronJob, err := createdTask.newCronJob(formData.CronTab)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if formData.Immediately != nil && *formData.Immediately {
+			err = app.runTaskNow(cronJob, triggerManual)
 			if err != nil {
 				app.serverError(w, r, err)
 				return
 			}
-
 		}
 		templateData := app.newTemplateData(r)
-		templateData["Result"] = result
+		templateData["Result"] = []gocron.Job{cronJob}
 		app.render(w, r, http.StatusOK, addedTaskPage, nil, templateData)
 	}
 }
@@ -137,23 +279,51 @@ func (app *application) listTasks(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
+	scope := contextGetAccessScope(r)
+	if !scope.unrestricted() {
+		taskNodes, err := app.taskNodes(ctxwt)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		updatedTasks = slices.DeleteFunc(updatedTasks, func(task database.GetTasksWithLatestJobMetadataRow) bool {
+			return !scope.allowsTask(task.Project, taskNodes[task.TaskID])
+		})
+	}
+	scheduledRuns, err := app.scheduledRuns(ctxwt, scope)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	data := app.newTemplateData(r)
 	data["Tasks"] = updatedTasks
+	data["ScheduledRuns"] = scheduledRuns
 	app.render(w, r, http.StatusOK, allTasksPage, nil, data)
 }
 
 func (app *application) fireTask(w http.ResponseWriter, r *http.Request) {
-	if r.PathValue("jobUUID") == "" {
-		app.serverError(w, r, fmt.Errorf("job uuid is empty"))
+	if r.PathValue("taskUUID") == "" {
+		app.serverError(w, r, fmt.Errorf("task uuid is empty"))
 		return
 	}
-	juuid, err := uuid.Parse(r.PathValue("jobUUID"))
+	juuid, err := uuid.Parse(r.PathValue("taskUUID"))
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
+	refusal, err := app.maintenanceRefusal(r.Context(), r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if refusal != "" {
+		data := app.newTemplateData(r)
+		data["ParagraphText"] = refusal
+		app.renderHTMX(w, r, http.StatusOK, htmxParagraph, nil, "htmx:Paragraph", data)
+		return
+	}
 	if exists, task := app.isTaskRunning(juuid); exists {
-		err := task.RunNow()
+		err := app.runTaskNow(task, triggerManual)
 		if err != nil {
 			app.serverError(w, r, err)
 			return
@@ -228,11 +398,25 @@ func (app *application) doBulkAction(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
+	uuidList, err = app.tasksInScope(ctxwt, contextGetAccessScope(r), uuidList)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	switch requestedAction := strings.TrimSpace(strings.ToLower(formData.Action)); requestedAction {
 	case "fire":
+		refusal, err := app.maintenanceRefusal(ctxwt, r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if refusal != "" {
+			operationResults = append(operationResults, refusal)
+			break
+		}
 		for _, taskUUID := range uuidList {
 			if exists, task := app.isTaskRunning(taskUUID); exists {
-				err := task.RunNow()
+				err := app.runTaskNow(task, triggerBulk)
 				if err != nil {
 					app.serverError(w, r, err)
 					return
@@ -293,6 +477,18 @@ func (app *application) editTask(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
+	scope := contextGetAccessScope(r)
+	nodes = scope.nodes(nodes)
+	nodeGroups, err := app.DB.queries.ListNodeGroups(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	blackoutCalendars, err := app.DB.queries.ListBlackoutCalendars(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
 		taskDb, err := app.DB.queries.GetTaskWithUUID(ctxwt, taskAsUUID)
@@ -300,6 +496,11 @@ func (app *application) editTask(w http.ResponseWriter, r *http.Request) {
 			app.serverError(w, r, err)
 			return
 		}
+		targets, err := app.taskTargets(ctxwt, taskDb)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 		taskSettings, err := url.ParseQuery(taskDb.SettingsArguments)
 		if err != nil {
 			app.serverError(w, r, err)
@@ -309,11 +510,29 @@ func (app *application) editTask(w http.ResponseWriter, r *http.Request) {
 		templateData := app.newTemplateData(r)
 		templateData["Task"] = taskDb
 		templateData["Nodes"] = nodes
+		templateData["NodeGroups"] = nodeGroups
+		templateData["Targets"] = targets
 		templateData["Settings"] = taskSettings
+		templateData["Retry"] = retryPolicyFromTask(taskDb)
+		templateData["Overlap"] = taskDb.OverlapPolicy
+		templateData["Misfire"] = misfirePolicyFromTask(taskDb)
+		templateData["MaxRuntimeMinutes"] = int(maxRuntimeFromTask(taskDb) / time.Minute)
+		templateData["BlackoutCalendars"] = blackoutCalendars
+		templateData["Blackout"], err = app.taskCalendars(ctxwt, taskDb)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		webhook, err := app.DB.queries.GetWebhookForTask(ctxwt, taskDb.ID)
+		if err == nil {
+			templateData["Webhook"] = app.newAPIWebhook(webhook)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			app.serverError(w, r, err)
+			return
+		}
 		app.render(w, r, http.StatusOK, editTaskPage, nil, templateData)
 	case http.MethodPost:
 		var formData taskEditAddFormData
-		var isPaused bool
 		err := request.DecodePostForm(r, &formData)
 		if err != nil {
 			app.serverError(w, r, err)
@@ -324,45 +543,74 @@ func (app *application) editTask(w http.ResponseWriter, r *http.Request) {
 		if cronParseError != nil {
 			app.logger.ErrorContext(ctxwt, "got invalid/unknown cron schedule according to parse standard", slog.Any("cronString", formData.CronTab), slog.Any("err", cronParseError))
 		}
-		formData.Validator.CheckField(len(formData.FireNodes) != 0, "fireNodes", "You must select at least one node")
 		formData.Validator.CheckField(validator.NotBlank(formData.Project), "project", "You must select at least one project")
 		formData.Validator.CheckField(validator.NotBlank(formData.Spider), "spider", "You must select at least one spider")
 		formData.Validator.CheckField(validator.NotBlank(formData.CronTab), "cron_input", "You must schedule spider")
 		formData.Validator.CheckField(cronParseError == nil, "cron_input", "Not a valid/supported cron string. Please see https://en.wikipedia.org/wiki/Cron")
 		formData.Validator.CheckField(validator.NotBlank(formData.TaskName), "task_name", "Task name can not be blank")
+		retry := formData.retryPolicy()
+		retry.validate(&formData.Validator, "retry")
+		validateOverlapPolicy(&formData.Validator, "overlap_policy", formData.overlapPolicy())
+		misfire := formData.misfirePolicy()
+		misfire.validate(&formData.Validator, "misfire_policy", "misfire_max_runs")
+		validateMaxRuntime(&formData.Validator, "max_runtime_minutes", formData.maxRuntime())
+		validateSpiderArgs(&formData.Validator, "spider_args", cleanUrlValues(maps.Clone(r.PostForm), taskFormFields...))
+		targets := formData.targets()
+		err = validateTaskTargets(ctxwt, app.DB.queries, &formData.Validator, scope, formData.Project, targets, "fireNode", "fireGroup")
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		calendars := formData.calendars()
+		err = validateTaskCalendars(ctxwt, app.DB.queries, &formData.Validator, calendars, "timezone", "blackout_calendars")
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 		if formData.Validator.HasErrors() {
 			data := app.newTemplateData(r)
 			data["Form"] = formData
 			data["Nodes"] = nodes
+			data["NodeGroups"] = nodeGroups
+			data["Targets"] = targets
+			data["Retry"] = retry
+			data["Overlap"] = formData.overlapPolicy()
+			data["Misfire"] = misfire
+			data["BlackoutCalendars"] = blackoutCalendars
+			data["Blackout"] = calendars
+			data["MaxRuntimeMinutes"] = formData.MaxRuntime
 			app.render(w, r, http.StatusUnprocessableEntity, editTaskPage, nil, data)
 			return
 		}
-		cleanForm := cleanUrlValues(r.PostForm, "fireNode", "csrf_token", "cron_input", "task_name", "immediately")
-		if exists, _ := app.isTaskRunning(taskAsUUID); exists {
-			isPaused = false
-			replacedTask, err := app.newTask(false, &taskAsUUID, formData.TaskName, formData.Spider, formData.Project, formData.FireNodes[0], cleanForm, nil)
-			if app.checkCreateTaskError(w, r, replacedTask, err) {
-				return
-			}
-			_, err = replacedTask.updatesResource(taskAsUUID, formData.CronTab)
-			if err != nil {
-				app.serverError(w, r, err)
-				return
-			}
-		} else {
-			isPaused = true
+		cleanForm := cleanUrlValues(r.PostForm, taskFormFields...)
+		err = app.setTaskTargets(ctxwt, taskAsUUID, targets.Nodes, targets.Groups)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
 		}
+		err = app.setTaskBlackoutCalendars(ctxwt, taskAsUUID, calendars.Calendars)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		// A task which isn't registered with the scheduler is paused, it stays paused
+		exists, _ := app.isTaskRunning(taskAsUUID)
 		queryParams := database.UpdateTaskParams{
 			Name:              database.CreateSqlNullString(&formData.TaskName),
 			Project:           formData.Project,
 			Spider:            formData.Spider,
 			Jobid:             formData.TaskName,
 			SettingsArguments: cleanForm.Encode(),
-			SelectedNodes:     formData.FireNodes[0],
 			CronString:        formData.CronTab,
-			Paused:            isPaused,
+			Paused:            !exists,
+			OverlapPolicy:     formData.overlapPolicy(),
+			FanOut:            targets.FanOut,
+			Timezone:          calendars.Timezone,
 			ID:                taskAsUUID,
 		}
+		setUpdateTaskRetryPolicy(&queryParams, retry)
+		setUpdateTaskMisfirePolicy(&queryParams, misfire)
+		queryParams.MaxRuntimeSeconds = nullMaxRuntime(formData.maxRuntime())
 		if user := contextGetAuthenticatedUser(r); user != nil {
 			queryParams.ModifiedBy = user.ID
 		}
@@ -371,6 +619,23 @@ func (app *application) editTask(w http.ResponseWriter, r *http.Request) {
 			app.serverError(w, r, err)
 			return
 		}
+		err = app.recordTaskRevision(ctxwt, taskAsUUID, contextGetAuthenticatedUser(r), 0)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if exists {
+			updatedTask, err := app.DB.queries.GetTaskWithUUID(ctxwt, taskAsUUID)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			_, err = app.rescheduleTask(updatedTask)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
 		http.Redirect(w, r, "/list-tasks", http.StatusSeeOther)
 	}
 }
@@ -389,6 +654,17 @@ func (app *application) searchTasksTable(w http.ResponseWriter, r *http.Request)
 		app.serverError(w, r, err)
 		return
 	}
+	scope := contextGetAccessScope(r)
+	if !scope.unrestricted() {
+		taskNodes, err := app.taskNodes(ctxwt)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		tasks = slices.DeleteFunc(tasks, func(task database.SearchTasksTableRow) bool {
+			return !scope.allowsTask(task.Project, taskNodes[task.TaskID])
+		})
+	}
 	templateData := app.newTemplateData(r)
 	templateData["Tasks"] = tasks
 	app.renderHTMX(w, r, http.StatusOK, htmxTaskTable, nil, "htmx:TaskTable", templateData)
@@ -397,7 +673,6 @@ func (app *application) searchTasksTable(w http.ResponseWriter, r *http.Request)
 func (app *application) restartTask(w http.ResponseWriter, r *http.Request) {
 	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
 	defer cancel()
-	var taskName string
 	if r.PathValue("taskUUID") == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -412,19 +687,7 @@ func (app *application) restartTask(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
-	if taskDb.Name.Valid {
-		taskName = taskDb.Name.String
-	}
-	values, err := url.ParseQuery(taskDb.SettingsArguments)
-	if err != nil {
-		app.serverError(w, r, err)
-		return
-	}
-	restartedTask, err := app.newTask(false, &taskUUID, taskName, taskDb.Spider, taskDb.Project, taskDb.SelectedNodes, values, nil)
-	if app.checkCreateTaskError(w, r, restartedTask, err) {
-		return
-	}
-	cronJob, err := restartedTask.newCronJob(taskDb.CronString)
+	cronJob, err := app.scheduleTask(taskDb)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -59,7 +59,10 @@ func TestScheduler(t *testing.T) {
 				assert.Equal(t, tasks[0].Spider, "test_spider")
 				assert.Equal(t, tasks[0].Name.String, "test_task")
 				assert.Equal(t, tasks[0].CronString, "* * * * *")
-				assert.Equal(t, tasks[0].SelectedNodes, testNode.Nodename)
+				nodes, err := ta.DB.queries.ListTaskTargetNodes(context.Background(), tasks[0].ID)
+				assert.NilError(t, err)
+				assert.Equal(t, strings.Join(nodes, ","), testNode.Nodename)
+				assert.Equal(t, tasks[0].FanOut, fanOutAll)
 				assert.Equal(t, len(ta.scheduler.Jobs()), 1)
 			},
 		},
@@ -74,7 +77,7 @@ func TestScheduler(t *testing.T) {
 				"immediately": []string{strconv.FormatBool(false)},
 			},
 			expectedStatus: http.StatusUnprocessableEntity,
-			expectedBody:   []string{`<span>You must select at least one node</span>`},
+			expectedBody:   []string{`<span>You must select at least one node or node group</span>`},
 		},
 		{
 			name: "Invalid no project",
@@ -158,13 +161,82 @@ func TestScheduler(t *testing.T) {
 				assert.Equal(t, tasks[1].Spider, "test_spider")
 				assert.Equal(t, tasks[1].Name.String, "test_task")
 				assert.Equal(t, tasks[1].CronString, "* * * * *")
-				assert.Equal(t, tasks[1].SelectedNodes, testNode.Nodename)
+				nodes, err := ta.DB.queries.ListTaskTargetNodes(context.Background(), tasks[1].ID)
+				assert.NilError(t, err)
+				assert.Equal(t, strings.Join(nodes, ","), testNode.Nodename)
+				assert.Equal(t, tasks[1].FanOut, fanOutAll)
 				assert.Equal(t, len(ta.scheduler.Jobs()), 2)
 				parsedValues, err := url.ParseQuery(tasks[1].SettingsArguments)
 				assert.NilError(t, err)
 				assert.Equal(t, parsedValues.Has("setting"), true)
 			},
 		},
+		{
+			name: "Valid with retry policy",
+			urlValues: url.Values{
+				"project":                   []string{"test_project"},
+				"spider":                    []string{"test_spider"},
+				"task_name":                 []string{"retry_task"},
+				"cron_input":                []string{"* * * * *"},
+				"fireNode":                  []string{testNode.Nodename},
+				"retry_max_attempts":        []string{"4"},
+				"retry_backoff_seconds":     []string{"15"},
+				"retry_max_backoff_seconds": []string{"120"},
+				"retry_on":                  []string{"network", "status"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`retry_task`},
+			afterRequestsChecks: func(ta *application, t *testing.T) {
+				tasks, err := ta.DB.queries.GetTasks(context.Background())
+				assert.NilError(t, err)
+				assert.Equal(t, len(tasks), 3)
+				assert.Equal(t, tasks[2].RetryMaxAttempts, int64(4))
+				assert.Equal(t, tasks[2].RetryBackoffSeconds, int64(15))
+				assert.Equal(t, tasks[2].RetryMaxBackoffSeconds, int64(120))
+				assert.Equal(t, tasks[2].RetryOn, "network,status")
+				parsedValues, err := url.ParseQuery(tasks[2].SettingsArguments)
+				assert.NilError(t, err)
+				assert.Equal(t, parsedValues.Has("retry_on"), false)
+				assert.Equal(t, parsedValues.Has("retry_max_attempts"), false)
+			},
+		},
+		{
+			name: "Invalid retry policy",
+			urlValues: url.Values{
+				"project":                   []string{"test_project"},
+				"spider":                    []string{"test_spider"},
+				"task_name":                 []string{"retry_task"},
+				"cron_input":                []string{"* * * * *"},
+				"fireNode":                  []string{testNode.Nodename},
+				"retry_max_attempts":        []string{"50"},
+				"retry_backoff_seconds":     []string{"15"},
+				"retry_max_backoff_seconds": []string{"120"},
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   []string{`Attempts must be between 1 and 10`},
+		},
+		{
+			name: "Valid with overlap policy",
+			urlValues: url.Values{
+				"project":        []string{"test_project"},
+				"spider":         []string{"test_spider"},
+				"task_name":      []string{"overlap_task"},
+				"cron_input":     []string{"* * * * *"},
+				"fireNode":       []string{testNode.Nodename},
+				"overlap_policy": []string{"queue"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`overlap_task`},
+			afterRequestsChecks: func(ta *application, t *testing.T) {
+				tasks, err := ta.DB.queries.GetTasks(context.Background())
+				assert.NilError(t, err)
+				assert.Equal(t, len(tasks), 4)
+				assert.Equal(t, tasks[3].OverlapPolicy, overlapQueue)
+				parsedValues, err := url.ParseQuery(tasks[3].SettingsArguments)
+				assert.NilError(t, err)
+				assert.Equal(t, parsedValues.Has("overlap_policy"), false)
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -199,27 +271,27 @@ func TestListTasks(t *testing.T) {
 	assert.NilError(t, err)
 	firstTaskName, secondTaskName := "first_task", "second_task"
 	firstTask, err := ta.DB.queries.InsertTask(context.Background(), database.InsertTaskParams{
-		ID:            uuid.New(),
-		Name:          database.CreateSqlNullString(&firstTaskName),
-		Project:       "test_project",
-		Spider:        "test_spider",
-		Jobid:         "test_job",
-		SelectedNodes: testNode.Nodename,
-		CronString:    "* * * * *",
-		Paused:        false,
+		ID:         uuid.New(),
+		Name:       database.CreateSqlNullString(&firstTaskName),
+		Project:    "test_project",
+		Spider:     "test_spider",
+		Jobid:      "test_job",
+		CronString: "* * * * *",
+		Paused:     false,
 	})
 	assert.NilError(t, err)
 	secondTask, err := ta.DB.queries.InsertTask(context.Background(), database.InsertTaskParams{
-		ID:            uuid.New(),
-		Name:          database.CreateSqlNullString(&secondTaskName),
-		Project:       "test_project",
-		Spider:        "test_spider",
-		Jobid:         "test_job",
-		SelectedNodes: testNode.Nodename,
-		CronString:    "* * * * *",
-		Paused:        false,
+		ID:         uuid.New(),
+		Name:       database.CreateSqlNullString(&secondTaskName),
+		Project:    "test_project",
+		Spider:     "test_spider",
+		Jobid:      "test_job",
+		CronString: "* * * * *",
+		Paused:     false,
 	})
 	assert.NilError(t, err)
+	assert.NilError(t, ta.setTaskTargets(context.Background(), firstTask.ID, []string{testNode.Nodename}, nil))
+	assert.NilError(t, ta.setTaskTargets(context.Background(), secondTask.ID, []string{testNode.Nodename}, nil))
 	code, _, body := ts.get(t, "/list-tasks")
 	assert.Equal(t, code, http.StatusOK)
 	taskIdPlaceholder := `<td class="px-6 py-4 whitespace-nowrap text-center" data-collapse-toggle="task-%s-details">%s</td>`
@@ -259,7 +331,6 @@ func TestTaskFire(t *testing.T) {
 		Spider:            "spider",
 		Jobid:             "jobid",
 		SettingsArguments: "",
-		SelectedNodes:     testNode.Nodename,
 		CronString:        "* * * * *",
 	})
 	assert.NilError(t, err)
@@ -294,7 +365,6 @@ func TestStopTask(t *testing.T) {
 		Spider:            "spider",
 		Jobid:             "jobid",
 		SettingsArguments: "",
-		SelectedNodes:     testNode.Nodename,
 		CronString:        "* * * * *",
 	})
 	assert.NilError(t, err)
@@ -341,7 +411,6 @@ func TestDeleteTask(t *testing.T) {
 		Spider:            "spider",
 		Jobid:             "jobid",
 		SettingsArguments: "",
-		SelectedNodes:     testNode.Nodename,
 		CronString:        "* * * * *",
 	})
 	assert.NilError(t, err)
@@ -393,7 +462,6 @@ func TestDoBulkAction(t *testing.T) {
 			Spider:            "spider",
 			Jobid:             "jobid",
 			SettingsArguments: "",
-			SelectedNodes:     testNode.Nodename,
 			CronString:        "* * * * *",
 		})
 		assert.NilError(t, err)
@@ -461,11 +529,17 @@ func TestUpdateTask(t *testing.T) {
 		Spider:            "spider",
 		Jobid:             "jobid",
 		SettingsArguments: "",
-		SelectedNodes:     testNode.Nodename,
 		CronString:        "* * * * *",
+		FanOut:            fanOutAll,
 	})
 	assert.NilError(t, err)
-	createdTask, err := ta.newTask(false, &databaseTask.ID, databaseTask.Name.String, databaseTask.Spider, databaseTask.Project, testNode.Nodename, url.Values{}, nil)
+	assert.NilError(t, ta.setTaskTargets(context.Background(), databaseTask.ID, []string{testNode.Nodename}, nil))
+	secondNode, err := ta.DB.queries.NewScrapydNode(context.Background(), database.NewScrapydNodeParams{
+		Nodename: "second_node",
+		Url:      "http://does_not_exist.example.com",
+	})
+	assert.NilError(t, err)
+	createdTask, err := ta.newTask(false, &databaseTask.ID, databaseTask.Name.String, databaseTask.Spider, databaseTask.Project, "", url.Values{}, nil)
 	assert.NilError(t, err)
 	_, err = createdTask.newCronJob("* * * * *")
 	assert.NilError(t, err)
@@ -477,7 +551,8 @@ func TestUpdateTask(t *testing.T) {
 		assert.StringContains(t, body, databaseTask.Name.String)
 		assert.StringContains(t, body, databaseTask.Project)
 		assert.StringContains(t, body, databaseTask.Spider)
-		assert.StringContains(t, body, databaseTask.SelectedNodes)
+		assert.StringContains(t, body, `<option value="test_node" selected>test_node</option>`)
+		assert.StringContains(t, body, `<option value="second_node" >second_node</option>`)
 		assert.StringContains(t, body, databaseTask.CronString)
 	})
 	t.Run("POST update tasks", func(t *testing.T) {
@@ -490,7 +565,8 @@ func TestUpdateTask(t *testing.T) {
 			"spider":     {"updated_spider"},
 			"task_name":  {"updated_task"},
 			"cron_input": {"*/10 * * * *"},
-			"fireNode":   {testNode.Nodename},
+			"fireNode":   {testNode.Nodename, secondNode.Nodename},
+			"fan_out":    {fanOutRoundRobin},
 		}
 		code, _, _ = ts.postFormFollowRedirects(t, "/task/edit/"+createdTask.ID.String(), formValues)
 		assert.Equal(t, code, http.StatusOK)
@@ -500,6 +576,10 @@ func TestUpdateTask(t *testing.T) {
 		assert.Equal(t, updatedDatabaseTask.Project, "updated_project")
 		assert.Equal(t, updatedDatabaseTask.Spider, "updated_spider")
 		assert.Equal(t, updatedDatabaseTask.CronString, "*/10 * * * *")
+		assert.Equal(t, updatedDatabaseTask.FanOut, fanOutRoundRobin)
+		nodes, err := ta.DB.queries.ListTaskTargetNodes(context.Background(), createdTask.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, strings.Join(nodes, ","), "second_node,test_node")
 		assert.Equal(t, len(ta.scheduler.Jobs()), 1)
 		assert.Equal(t, ta.scheduler.Jobs()[0].Name(), "updated_task")
 	})
@@ -528,7 +608,6 @@ func TestSearchTasks(t *testing.T) {
 			Spider:            "spider",
 			Jobid:             "jobid",
 			SettingsArguments: "",
-			SelectedNodes:     testNode.Nodename,
 			CronString:        "* * * * *",
 		})
 		assert.NilError(t, err)
@@ -578,10 +657,10 @@ func TestRestartTask(t *testing.T) {
 		Spider:            "spider",
 		Jobid:             "jobid",
 		SettingsArguments: "",
-		SelectedNodes:     testNode.Nodename,
 		CronString:        "* * * * *",
 	})
 	assert.NilError(t, err)
+	assert.NilError(t, ta.setTaskTargets(context.Background(), databaseTask.ID, []string{testNode.Nodename}, nil))
 	t.Run("Test restarting task", func(t *testing.T) {
 		assert.Equal(t, len(ta.scheduler.Jobs()), 0)
 		code, headers, _ := ts.postForm(t, "/restart-task/"+databaseTask.ID.String(), nil)
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/response"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// scrapydFacadeNodeParam picks the node for schedule.json and addversion.json. The underscore keeps it apart from
+	// spider arguments, the same way Scrapyd does with _version.
+	scrapydFacadeNodeParam = "_node"
+	// scrapydFacadeFinishedToKeep matches Scrapyd's default finished_to_keep
+	scrapydFacadeFinishedToKeep = 100
+	scrapydFacadeMaxEggBytes    = 100 << 20
+	scrapydFacadeTimeFormat     = "2006-01-02 15:04:05.000000"
+)
+
+type scrapydFacadeError struct {
+	NodeName string `json:"node_name"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+}
+
+type scrapydFacadeListResponse struct {
+	Status   string   `json:"status"`
+	Message  string   `json:"message"`
+	Projects []string `json:"projects"`
+	Spiders  []string `json:"spiders"`
+}
+
+type scrapydFacadeJob struct {
+	ID        string  `json:"id"`
+	Project   string  `json:"project"`
+	Spider    string  `json:"spider"`
+	Node      string  `json:"node"`
+	Pid       *int64  `json:"pid,omitempty"`
+	StartTime *string `json:"start_time,omitempty"`
+	EndTime   *string `json:"end_time,omitempty"`
+	LogURL    *string `json:"log_url,omitempty"`
+	ItemsURL  *string `json:"items_url,omitempty"`
+}
+
+type scrapydFacadeListJobsResponse struct {
+	NodeName string             `json:"node_name"`
+	Status   string             `json:"status"`
+	Pending  []scrapydFacadeJob `json:"pending"`
+	Running  []scrapydFacadeJob `json:"running"`
+	Finished []scrapydFacadeJob `json:"finished"`
+}
+
+type scrapydFacadeAddVersionResponse struct {
+	NodeName string   `json:"node_name"`
+	Status   string   `json:"status"`
+	Project  string   `json:"project"`
+	Version  string   `json:"version"`
+	Spiders  int      `json:"spiders"`
+	Nodes    []string `json:"nodes"`
+}
+
+// scrapydFacadeNodeName is reported as node_name, just like Scrapyd reports the host it's running on.
+func scrapydFacadeNodeName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "goscrapyd"
+	}
+	return hostname
+}
+
+func newScrapydFacadeJob(job database.QueryJobsRow) scrapydFacadeJob {
+	result := scrapydFacadeJob{
+		ID:       job.Job,
+		Project:  job.Project,
+		Spider:   job.Spider,
+		Node:     job.Node,
+		Pid:      nullInt64Ptr(job.Pid),
+		LogURL:   database.ReadSqlNullString(job.HrefLog),
+		ItemsURL: database.ReadSqlNullString(job.HrefItems),
+	}
+	if job.Start.Valid {
+		start := job.Start.Time.Format(scrapydFacadeTimeFormat)
+		result.StartTime = &start
+	}
+	if job.Finish.Valid {
+		finish := job.Finish.Time.Format(scrapydFacadeTimeFormat)
+		result.EndTime = &finish
+	}
+	return result
+}
+
+// missingScrapydParam returns the first required parameter which is blank. Scrapyd reports them one at a time too.
+func missingScrapydParam(values url.Values, names ...string) string {
+	for _, name := range names {
+		if values.Get(name) == "" {
+			return name
+		}
+	}
+	return ""
+}
+
+func (app *application) scrapydJSON(w http.ResponseWriter, r *http.Request, data any) {
+	err := response.JSON(w, http.StatusOK, data)
+	if err != nil {
+		app.scrapydServerError(w, r, err)
+	}
+}
+
+// collectFromNodes sends the same list request to every node the user can see for the queried project and merges the
+// answers. Nodes which don't answer are skipped, it only fails when none of them did.
+func (app *application) collectFromNodes(ctx context.Context, scope accessScope, endpoint scrapydRequestType, query url.Values, values func(node string, resp scrapydFacadeListResponse) []string) ([]string, error) {
+	nodes, err := app.DB.queries.ListScrapydNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes = slices.DeleteFunc(nodes, func(node database.ScrapydNode) bool {
+		return !scope.Allows(query.Get("project"), node.Nodename)
+	})
+	var (
+		mu       sync.Mutex
+		seen     = make(map[string]struct{})
+		answered int
+		lastErr  error
+		g        errgroup.Group
+	)
+	g.SetLimit(app.config.workerCount)
+	for _, node := range nodes {
+		g.Go(func() error {
+			req, err := makeRequestToScrapyd(ctx, app.DB.queries, http.MethodGet, node.Nodename, func(url *url.URL) *url.URL {
+				url.Path = path.Join(url.Path, endpoint)
+				url.RawQuery = query.Encode()
+				return url
+			}, nil, nil, app.config.ScrapydEncryptSecret)
+			if err != nil {
+				return err
+			}
+			resp, err := requestJSONResourceFromScrapyd[scrapydFacadeListResponse](req, app.logger)
+			if err == nil && strings.ToLower(strings.TrimSpace(resp.Status)) != "ok" {
+				err = errors.New(resp.Message)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				app.logger.DebugContext(ctx, "node skipped in collectFromNodes", slog.String("node", node.Nodename), slog.Any("err", err))
+				lastErr = fmt.Errorf("node %s: %w", node.Nodename, err)
+				return nil
+			}
+			answered++
+			for _, value := range values(node.Nodename, resp) {
+				seen[value] = struct{}{}
+			}
+			return nil
+		})
+	}
+	err = g.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if answered == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	result := slices.Sorted(maps.Keys(seen))
+	if result == nil {
+		// Clients iterate over the list, null would break them
+		result = []string{}
+	}
+	return result, nil
+}
+
+// jobsWithStatuses merges the newest jobs of several statuses the user can see, newest first.
+func (app *application) jobsWithStatuses(ctx context.Context, scope accessScope, project string, limit int64, statuses ...string) ([]scrapydFacadeJob, error) {
+	grants, err := scope.queryGrants()
+	if err != nil {
+		return nil, err
+	}
+	var rows []database.QueryJobsRow
+	for _, status := range statuses {
+		jobs, err := app.DB.queries.QueryJobs(ctx, database.QueryJobsParams{
+			Project: optionalString(project),
+			Status:  status,
+			Grants:  grants,
+			Limit:   limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, jobs...)
+	}
+	slices.SortFunc(rows, func(a, b database.QueryJobsRow) int {
+		return int(b.ID - a.ID)
+	})
+	if int64(len(rows)) > limit {
+		rows = rows[:limit]
+	}
+	result := make([]scrapydFacadeJob, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, newScrapydFacadeJob(row))
+	}
+	return result, nil
+}
+
+func (app *application) facadeDaemonStatus(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	nodes, err := app.DB.queries.ListScrapydNodes(ctxwt)
+	if err != nil {
+		app.scrapydServerError(w, r, err)
+		return
+	}
+	nodes = contextGetAccessScope(r).nodes(nodes)
+	result := scrapydDaemonStatusResponse{NodeName: scrapydFacadeNodeName(), Status: "ok"}
+	for _, node := range app.scrapydNodesStatus(ctxwt, r, nodes) {
+		if node.Error != nil {
+			continue
+		}
+		result.Pending += node.Pending
+		result.Running += node.Running
+		result.Finished += node.Finished
+	}
+	app.scrapydJSON(w, r, result)
+}
+
+func (app *application) facadeListProjects(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	scope := contextGetAccessScope(r)
+	projects, err := app.collectFromNodes(ctxwt, scope, scrapydListProjectsReq, url.Values{}, func(node string, resp scrapydFacadeListResponse) []string {
+		return slices.DeleteFunc(resp.Projects, func(project string) bool {
+			return !scope.Allows(project, node)
+		})
+	})
+	if err != nil {
+		app.scrapydBadGateway(w, r, err)
+		return
+	}
+	app.scrapydJSON(w, r, scrapydListProjects{NodeName: scrapydFacadeNodeName(), Status: "ok", Projects: projects})
+}
+
+func (app *application) facadeListSpiders(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	query := r.URL.Query()
+	if missing := missingScrapydParam(query, "project"); missing != "" {
+		app.scrapydErrorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("'%s' parameter is required", missing))
+		return
+	}
+	spiders, err := app.collectFromNodes(ctxwt, contextGetAccessScope(r), scrapydListSpidersReq, query, func(_ string, resp scrapydFacadeListResponse) []string {
+		return resp.Spiders
+	})
+	if err != nil {
+		app.scrapydBadGateway(w, r, err)
+		return
+	}
+	app.scrapydJSON(w, r, scrapydListSpidersResponse{NodeName: scrapydFacadeNodeName(), Status: "ok", Spiders: spiders})
+}
+
+// facadeListJobs answers from the jobs table, so the whole cluster shows up as one Scrapyd. Each job carries an extra
+// node field.
+func (app *application) facadeListJobs(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	project := r.URL.Query().Get("project")
+	scope := contextGetAccessScope(r)
+	pending, err := app.jobsWithStatuses(ctxwt, scope, project, apiJobsMaxLimit, "scheduled", "queued", "pending")
+	if err != nil {
+		app.scrapydServerError(w, r, err)
+		return
+	}
+	running, err := app.jobsWithStatuses(ctxwt, scope, project, apiJobsMaxLimit, "running")
+	if err != nil {
+		app.scrapydServerError(w, r, err)
+		return
+	}
+	finished, err := app.jobsWithStatuses(ctxwt, scope, project, scrapydFacadeFinishedToKeep, "finished", "timed_out", "error")
+	if err != nil {
+		app.scrapydServerError(w, r, err)
+		return
+	}
+	app.scrapydJSON(w, r, scrapydFacadeListJobsResponse{
+		NodeName: scrapydFacadeNodeName(),
+		Status:   "ok",
+		Pending:  pending,
+		Running:  running,
+		Finished: finished,
+	})
+}
+
+// facadeSchedule runs the spider on the node given in _node, or on the least loaded one. Every other parameter is passed
+// through to the node's schedule.json untouched.
+func (app *application) facadeSchedule(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	err := r.ParseForm()
+	if err != nil {
+		app.scrapydErrorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	project, spider := r.Form.Get("project"), r.Form.Get("spider")
+	if missing := missingScrapydParam(r.Form, "project", "spider"); missing != "" {
+		app.scrapydErrorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("'%s' parameter is required", missing))
+		return
+	}
+	nodes, err := app.DB.queries.ListScrapydNodes(ctxwt)
+	if err != nil {
+		app.scrapydServerError(w, r, err)
+		return
+	}
+	scope := contextGetAccessScope(r)
+	nodes = slices.DeleteFunc(nodes, func(node database.ScrapydNode) bool {
+		return !scope.Allows(project, node.Nodename)
+	})
+	if len(nodes) == 0 {
+		app.scrapydErrorResponse(w, r, http.StatusForbidden, fmt.Sprintf("You don't have access to project %s", project))
+		return
+	}
+	nodeName := r.Form.Get(scrapydFacadeNodeParam)
+	if nodeName != "" {
+		if !slices.ContainsFunc(nodes, func(node database.ScrapydNode) bool { return node.Nodename == nodeName }) {
+			app.scrapydErrorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("Node %s doesn't exist", nodeName))
+			return
+		}
+	} else {
+		nodeName, err = app.leastLoadedNode(ctxwt, r, nodes)
+		if err != nil {
+			app.scrapydErrorResponse(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+	}
+	refusal, err := app.maintenanceRefusal(ctxwt, r)
+	if err != nil {
+		app.scrapydServerError(w, r, err)
+		return
+	}
+	if refusal != "" {
+		app.scrapydErrorResponse(w, r, http.StatusServiceUnavailable, refusal)
+		return
+	}
+	jobID := r.Form.Get("jobid")
+	if jobID == "" {
+		// Scrapyd uses uuid1().hex for job IDs
+		jobID = strings.ReplaceAll(uuid.NewString(), "-", "")
+	}
+	currentTask, err := app.newTask(true, nil, fmt.Sprintf("Scrapyd API job for spider %s on node %s", spider, nodeName),
+		spider, project, nodeName, cleanUrlValues(r.Form, scrapydFacadeNodeParam, maintenanceOverrideField), contextGetAuthenticatedUser(r))
+	if err != nil {
+		app.scrapydServerError(w, r, err)
+		return
+	}
+	currentTask.rawArgs = true
+	currentTask.overrideMaintenance = true
+	_, err = currentTask.fireNow(jobID)
+	if err != nil {
+		app.scrapydBadGateway(w, r, fmt.Errorf("scheduling on node %s failed: %w", nodeName, err))
+		return
+	}
+	app.scrapydJSON(w, r, scrapydScheduleResponse{NodeName: nodeName, Status: "ok", Jobid: jobID})
+}
+
+// facadeCancel looks up which node runs the job and cancels it there.
+func (app *application) facadeCancel(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	err := r.ParseForm()
+	if err != nil {
+		app.scrapydErrorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	project, job := r.Form.Get("project"), r.Form.Get("job")
+	if missing := missingScrapydParam(r.Form, "project", "job"); missing != "" {
+		app.scrapydErrorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("'%s' parameter is required", missing))
+		return
+	}
+	node, err := app.DB.queries.GetNodeForJob(ctxwt, database.GetNodeForJobParams{Job: job, Project: project})
+	if err == nil && !contextGetAccessScope(r).Allows(project, node) {
+		// Jobs outside the user's grants look the same as unknown ones
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.scrapydErrorResponse(w, r, http.StatusNotFound, fmt.Sprintf("Job %s of project %s is unknown", job, project))
+		} else {
+			app.scrapydServerError(w, r, err)
+		}
+		return
+	}
+	resp, err := app.cancelScrapydJob(ctxwt, node, project, job, r.Form.Get("signal"), contextGetAuthenticatedUser(r))
+	if err != nil {
+		app.scrapydBadGateway(w, r, fmt.Errorf("canceling on node %s failed: %w", node, err))
+		return
+	}
+	resp.NodeName = node
+	app.scrapydJSON(w, r, resp)
+}
+
+// facadeAddVersion deploys the uploaded egg to every node (or the ones given in _node) the same way the deploy page does.
+func (app *application) facadeAddVersion(w http.ResponseWriter, r *http.Request) {
+	ctxwc, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	r.Body = http.MaxBytesReader(w, r.Body, scrapydFacadeMaxEggBytes)
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		app.scrapydErrorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	project, version := r.Form.Get("project"), r.Form.Get("version")
+	if missing := missingScrapydParam(r.Form, "project", "version"); missing != "" {
+		app.scrapydErrorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("'%s' parameter is required", missing))
+		return
+	}
+	eggFile, _, err := r.FormFile("egg")
+	if err != nil {
+		app.scrapydErrorResponse(w, r, http.StatusBadRequest, "'egg' parameter is required")
+		return
+	}
+	defer eggFile.Close()
+	egg, err := io.ReadAll(eggFile)
+	if err != nil {
+		app.scrapydServerError(w, r, err)
+		return
+	}
+	scope := contextGetAccessScope(r)
+	targets := r.Form[scrapydFacadeNodeParam]
+	for _, target := range targets {
+		if !scope.Allows(project, target) {
+			app.scrapydErrorResponse(w, r, http.StatusForbidden, fmt.Sprintf("You don't have access to project %s on node %s", project, target))
+			return
+		}
+	}
+	if len(targets) == 0 {
+		nodes, err := app.DB.queries.ListScrapydNodes(ctxwc)
+		if err != nil {
+			app.scrapydServerError(w, r, err)
+			return
+		}
+		for _, node := range nodes {
+			if scope.Allows(project, node.Nodename) {
+				targets = append(targets, node.Nodename)
+			}
+		}
+	}
+	if len(targets) == 0 && !scope.AllowsProject(project) {
+		app.scrapydErrorResponse(w, r, http.StatusForbidden, fmt.Sprintf("You don't have access to project %s", project))
+		return
+	}
+	if len(targets) == 0 {
+		app.scrapydErrorResponse(w, r, http.StatusServiceUnavailable, "There are no nodes to deploy to")
+		return
+	}
+
+	// Same lock as the deploy page, deploys are never run concurrently
+	app.globalMu.Lock()
+	defer app.globalMu.Unlock()
+
+	numJobs := len(targets)
+	jobs := make(chan string, numJobs)
+	results := make(chan addVersionResponse, numJobs)
+	for worker := 0; worker < app.config.workerCount; worker++ {
+		go app.deployToNodeWorker(ctxwc, project, version, egg, jobs, results)
+	}
+	for _, node := range targets {
+		jobs <- node
+	}
+	close(jobs)
+
+	result := scrapydFacadeAddVersionResponse{NodeName: scrapydFacadeNodeName(), Status: "ok", Project: project, Version: version}
+	var failures []string
+	for range numJobs {
+		res := <-results
+		switch {
+		case res.Error != nil:
+			app.reportServerError(r, res.Error)
+			failures = append(failures, fmt.Sprintf("%s: %v", res.ActualNodeName, res.Error))
+		case strings.ToLower(strings.TrimSpace(res.Status)) != "ok":
+			failures = append(failures, fmt.Sprintf("%s: %s", res.ActualNodeName, res.Status))
+		default:
+			result.Nodes = append(result.Nodes, res.ActualNodeName)
+			result.Spiders = max(result.Spiders, res.Spiders)
+		}
+	}
+	close(results)
+	if len(failures) != 0 {
+		slices.Sort(failures)
+		app.scrapydErrorResponse(w, r, http.StatusBadGateway, "Deploy failed on "+strings.Join(failures, "; "))
+		return
+	}
+	slices.Sort(result.Nodes)
+	app.scrapydJSON(w, r, result)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// doScrapyd calls a Scrapyd compatible endpoint the way scrapyd-client does, with the token as basic auth password.
+func (ts *testServer) doScrapyd(t *testing.T, method, urlPath, token string, body io.Reader, contentType string) (int, map[string]any) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.SetBasicAuth("anything", token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	var result map[string]any
+	err = json.NewDecoder(rs.Body).Decode(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, result
+}
+
+type mockScrapydNode struct {
+	mu        sync.Mutex
+	running   int
+	projects  []string
+	scheduled []url.Values
+	canceled  []url.Values
+	versions  []string
+}
+
+func (m *mockScrapydNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/daemonstatus.json":
+		fmt.Fprintf(w, `{"node_name": "mock", "status": "ok", "pending": 1, "running": %d, "finished": 2}`, m.running)
+	case "/listprojects.json":
+		projects, _ := json.Marshal(m.projects)
+		fmt.Fprintf(w, `{"node_name": "mock", "status": "ok", "projects": %s}`, projects)
+	case "/listspiders.json":
+		fmt.Fprintf(w, `{"node_name": "mock", "status": "ok", "spiders": ["%s_spider"]}`, r.URL.Query().Get("project"))
+	case "/schedule.json":
+		m.scheduled = append(m.scheduled, r.URL.Query())
+		fmt.Fprintf(w, `{"node_name": "mock", "status": "ok", "jobid": "%s"}`, r.URL.Query().Get("jobid"))
+	case "/cancel.json":
+		m.canceled = append(m.canceled, r.URL.Query())
+		fmt.Fprint(w, `{"node_name": "mock", "status": "ok", "prevstate": "running"}`)
+	case "/addversion.json":
+		_ = r.ParseMultipartForm(1 << 20)
+		m.versions = append(m.versions, r.FormValue("version"))
+		fmt.Fprint(w, `{"node_name": "mock", "status": "ok", "spiders": 3}`)
+	}
+}
+
+func TestScrapydFacade(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	admin, err := ta.DB.queries.GetUserByUsername(context.Background(), "admin")
+	assert.NilError(t, err)
+	readWrite, _, err := ta.createAPIToken(context.Background(), admin.ID, "ci", apiTokenScopeReadWrite, nil)
+	assert.NilError(t, err)
+	readOnly, _, err := ta.createAPIToken(context.Background(), admin.ID, "dashboards", apiTokenScopeRead, nil)
+	assert.NilError(t, err)
+
+	busy := &mockScrapydNode{running: 5, projects: []string{"shop", "news"}}
+	idle := &mockScrapydNode{running: 0, projects: []string{"shop"}}
+	busyServer := httptest.NewServer(busy)
+	defer busyServer.Close()
+	idleServer := httptest.NewServer(idle)
+	defer idleServer.Close()
+	for name, server := range map[string]*httptest.Server{"busy_node": busyServer, "idle_node": idleServer} {
+		_, err := ta.DB.queries.NewScrapydNode(context.Background(), database.NewScrapydNodeParams{Nodename: name, Url: server.URL})
+		assert.NilError(t, err)
+	}
+
+	t.Run("Requires a token", func(t *testing.T) {
+		code, body := ts.doScrapyd(t, http.MethodGet, "/scrapyd/daemonstatus.json", "", nil, "")
+		assert.Equal(t, code, http.StatusUnauthorized)
+		assert.Equal(t, body["status"], any("error"))
+		code, _ = ts.doScrapyd(t, http.MethodGet, "/scrapyd/daemonstatus.json", "gsd_wrong", nil, "")
+		assert.Equal(t, code, http.StatusUnauthorized)
+	})
+	t.Run("Read-only token can't schedule", func(t *testing.T) {
+		code, _ := ts.doScrapyd(t, http.MethodPost, "/scrapyd/schedule.json", readOnly, strings.NewReader("project=shop&spider=products"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+	t.Run("daemonstatus.json sums the cluster", func(t *testing.T) {
+		code, body := ts.doScrapyd(t, http.MethodGet, "/scrapyd/daemonstatus.json", readOnly, nil, "")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body["status"], any("ok"))
+		assert.Equal(t, body["running"], any(float64(5)))
+		assert.Equal(t, body["pending"], any(float64(2)))
+		assert.Equal(t, body["finished"], any(float64(4)))
+	})
+	t.Run("listprojects.json merges nodes", func(t *testing.T) {
+		code, body := ts.doScrapyd(t, http.MethodGet, "/scrapyd/listprojects.json", readOnly, nil, "")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, fmt.Sprint(body["projects"]), "[news shop]")
+	})
+	t.Run("listspiders.json", func(t *testing.T) {
+		code, _ := ts.doScrapyd(t, http.MethodGet, "/scrapyd/listspiders.json", readOnly, nil, "")
+		assert.Equal(t, code, http.StatusBadRequest)
+		code, body := ts.doScrapyd(t, http.MethodGet, "/scrapyd/listspiders.json?project=shop", readOnly, nil, "")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, fmt.Sprint(body["spiders"]), "[shop_spider]")
+	})
+	t.Run("schedule.json picks the least loaded node", func(t *testing.T) {
+		code, body := ts.doScrapyd(t, http.MethodPost, "/scrapyd/schedule.json", readWrite, strings.NewReader("project=shop&spider=products&category=books"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body["status"], any("ok"))
+		assert.Equal(t, body["node_name"], any("idle_node"))
+		jobID, _ := body["jobid"].(string)
+		assert.NotEqual(t, jobID, "")
+		if len(idle.scheduled) != 1 {
+			t.Fatalf("got %d jobs scheduled on idle_node; want 1", len(idle.scheduled))
+		}
+		assert.Equal(t, idle.scheduled[0].Get("category"), "books")
+		assert.Equal(t, idle.scheduled[0].Get("jobid"), jobID)
+		jobs, err := ta.DB.queries.QueryJobs(context.Background(), database.QueryJobsParams{Node: "idle_node", Limit: 10})
+		assert.NilError(t, err)
+		if len(jobs) != 1 {
+			t.Fatalf("got %d jobs on idle_node; want 1", len(jobs))
+		}
+		assert.Equal(t, jobs[0].Job, jobID)
+		assert.Equal(t, jobs[0].StartedByUsername.String, "admin")
+	})
+	t.Run("schedule.json on a chosen node", func(t *testing.T) {
+		code, body := ts.doScrapyd(t, http.MethodPost, "/scrapyd/schedule.json", readWrite, strings.NewReader("project=news&spider=headlines&_node=busy_node&jobid=my_job"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body["node_name"], any("busy_node"))
+		assert.Equal(t, body["jobid"], any("my_job"))
+		if len(busy.scheduled) != 1 {
+			t.Fatalf("got %d jobs scheduled on busy_node; want 1", len(busy.scheduled))
+		}
+		assert.Equal(t, busy.scheduled[0].Has(scrapydFacadeNodeParam), false)
+		code, _ = ts.doScrapyd(t, http.MethodPost, "/scrapyd/schedule.json", readWrite, strings.NewReader("project=news&spider=headlines&_node=nope"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusBadRequest)
+		code, body = ts.doScrapyd(t, http.MethodPost, "/scrapyd/schedule.json", readWrite, strings.NewReader("project=news"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusBadRequest)
+		assert.Equal(t, body["message"], any("'spider' parameter is required"))
+	})
+	t.Run("listjobs.json reads the jobs table", func(t *testing.T) {
+		_, err := ta.DB.queries.InsertJob(context.Background(), database.InsertJobParams{
+			Project:    "shop",
+			Spider:     "products",
+			Job:        "old_job",
+			Status:     "finished",
+			CreateTime: time.Now(),
+			UpdateTime: time.Now(),
+			Node:       "busy_node",
+			Start:      sql.NullTime{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true},
+		})
+		assert.NilError(t, err)
+		// Fires waiting on the overlap policy haven't reached Scrapyd yet, they're pending to a client
+		_, err = ta.DB.queries.InsertJob(context.Background(), database.InsertJobParams{
+			Project:    "shop",
+			Spider:     "products",
+			Job:        "queued_job",
+			Status:     "queued",
+			CreateTime: time.Now(),
+			UpdateTime: time.Now(),
+			Node:       "busy_node",
+		})
+		assert.NilError(t, err)
+		code, body := ts.doScrapyd(t, http.MethodGet, "/scrapyd/listjobs.json?project=shop", readOnly, nil, "")
+		assert.Equal(t, code, http.StatusOK)
+		pending, _ := body["pending"].([]any)
+		finished, _ := body["finished"].([]any)
+		assert.Equal(t, len(pending), 2)
+		assert.Equal(t, len(finished), 1)
+		job, _ := finished[0].(map[string]any)
+		assert.Equal(t, job["id"], any("old_job"))
+		assert.Equal(t, job["node"], any("busy_node"))
+		assert.Equal(t, job["start_time"], any("2024-01-02 03:04:05.000000"))
+	})
+	t.Run("cancel.json finds the node", func(t *testing.T) {
+		code, body := ts.doScrapyd(t, http.MethodPost, "/scrapyd/cancel.json", readWrite, strings.NewReader("project=news&job=my_job"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body["prevstate"], any("running"))
+		assert.Equal(t, body["node_name"], any("busy_node"))
+		assert.Equal(t, len(busy.canceled), 1)
+		code, _ = ts.doScrapyd(t, http.MethodPost, "/scrapyd/cancel.json", readWrite, strings.NewReader("project=news&job=unknown"), "application/x-www-form-urlencoded")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+	t.Run("addversion.json deploys to every node", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		assert.NilError(t, writer.WriteField("project", "shop"))
+		assert.NilError(t, writer.WriteField("version", "r42"))
+		egg, err := writer.CreateFormFile("egg", "shop.egg")
+		assert.NilError(t, err)
+		_, err = egg.Write([]byte("egg"))
+		assert.NilError(t, err)
+		assert.NilError(t, writer.Close())
+		code, body := ts.doScrapyd(t, http.MethodPost, "/scrapyd/addversion.json", readWrite, &buf, writer.FormDataContentType())
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body["spiders"], any(float64(3)))
+		assert.Equal(t, fmt.Sprint(body["nodes"]), "[busy_node idle_node]")
+		assert.Equal(t, fmt.Sprint(busy.versions), "[r42]")
+		assert.Equal(t, fmt.Sprint(idle.versions), "[r42]")
+	})
+}
@@ -168,6 +168,12 @@ func (app *application) serve(srv *http.Server) error {
 	if err != nil {
 		return err
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownPeriod)
+	defer cancel()
+	err = app.cluster.release(ctx)
+	if err != nil {
+		app.logger.Warn("failed to release the scheduler lease", "error", err)
+	}
 
 	app.logger.Info("stopped server", slog.Group("server", "addr", srv.Addr))
 
@@ -19,27 +19,10 @@ func (app *application) settingPage(w http.ResponseWriter, r *http.Request) {
 	}
 	switch r.Method {
 	case http.MethodGet:
-		templateData := app.newTemplateData(r)
-		if settingsExists == 1 {
-			settings, err := app.DB.queries.GetSettings(ctxwt)
-			if err != nil {
-				app.serverError(w, r, err)
-				return
-			}
-			if settings.PersistedSpiderSettings.Valid {
-				persistedSettings, err := url.ParseQuery(settings.PersistedSpiderSettings.String)
-				if err != nil {
-					app.serverError(w, r, err)
-					return
-				}
-				templateData["Settings"] = cleanUrlValues(persistedSettings, "spider", "project", "version", "csrf_token")
-			}
-			if settings.DefaultProjectPath.Valid {
-				templateData["ProjectPath"] = settings.DefaultProjectPath.String
-			}
-			if settings.DefaultProjectName.Valid {
-				templateData["ProjectName"] = settings.DefaultProjectName.String
-			}
+		templateData, err := app.settingsTemplateData(ctxwt, r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
 		}
 		app.render(w, r, http.StatusOK, settingsPage, nil, templateData)
 	case http.MethodPost:
@@ -64,6 +47,11 @@ func (app *application) settingPage(w http.ResponseWriter, r *http.Request) {
 			data := app.newTemplateData(r)
 			data["Form"] = formData
 			data["ProjectName"] = formData.ProjectName
+			data["ProjectMaxRuntimes"], err = app.projectMaxRuntimes(ctxwt)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
 			app.render(w, r, http.StatusUnprocessableEntity, settingsPage, nil, data)
 			return
 		}
@@ -93,3 +81,36 @@ func (app *application) settingPage(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/edit-settings", http.StatusFound)
 	}
 }
+
+// settingsTemplateData loads the stored settings and the project max runtimes for the settings page.
+func (app *application) settingsTemplateData(ctx context.Context, r *http.Request) (map[string]any, error) {
+	templateData := app.newTemplateData(r)
+	settingsExists, err := app.DB.queries.CheckSettingsExist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if settingsExists == 1 {
+		settings, err := app.DB.queries.GetSettings(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if settings.PersistedSpiderSettings.Valid {
+			persistedSettings, err := url.ParseQuery(settings.PersistedSpiderSettings.String)
+			if err != nil {
+				return nil, err
+			}
+			templateData["Settings"] = cleanUrlValues(persistedSettings, "spider", "project", "version", "csrf_token")
+		}
+		if settings.DefaultProjectPath.Valid {
+			templateData["ProjectPath"] = settings.DefaultProjectPath.String
+		}
+		if settings.DefaultProjectName.Valid {
+			templateData["ProjectName"] = settings.DefaultProjectName.String
+		}
+	}
+	templateData["ProjectMaxRuntimes"], err = app.projectMaxRuntimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return templateData, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"net/url"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// spiderArgMaxLength caps what a templated spider argument evaluates to.
+const spiderArgMaxLength = 8 << 10
+
+var errSpiderArgTooLong = fmt.Errorf("evaluates to more than %d bytes", spiderArgMaxLength)
+
+// spiderArgFuncs are the only functions spider argument templates can call, the text/template builtins such as printf
+// and call aren't allowed, see checkSpiderArgPipe. now is the time of the fire in the time zone of the task, it's replaced
+// on every fire. The rest take the time last, so they chain: {{ now | addDays -1 | format "2006-01-02" }}.
+var spiderArgFuncs = template.FuncMap{
+	"now": time.Now,
+	"add": func(duration string, t time.Time) (time.Time, error) {
+		d, err := time.ParseDuration(duration)
+		return t.Add(d), err
+	},
+	"addDays": func(days int, t time.Time) time.Time {
+		return t.AddDate(0, 0, days)
+	},
+	"addMonths": func(months int, t time.Time) time.Time {
+		return t.AddDate(0, months, 0)
+	},
+	"format": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"inZone": func(timezone string, t time.Time) (time.Time, error) {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.In(loc), nil
+	},
+	"utc": func(t time.Time) time.Time {
+		return t.UTC()
+	},
+	"unix": func(t time.Time) int64 {
+		return t.Unix()
+	},
+	"isoWeek": func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	},
+}
+
+// spiderArgData is what a spider argument template can refer to, e.g. {{ .JobID }}. PreviousJobID is the last job of the
+// task which finished, empty if none has.
+type spiderArgData struct {
+	TaskName      string
+	TaskID        string
+	Project       string
+	Spider        string
+	Node          string
+	JobID         string
+	PreviousJobID string
+}
+
+// isSpiderArgTemplate reports whether a spider argument has to be evaluated, values without {{ are sent as they are. A
+// literal {{ is written as {{"{{"}}.
+func isSpiderArgTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+// spiderArgSyntaxError is a spider argument with a {{ which doesn't parse as a template. Such values are refused when a
+// task is saved, but tasks saved before spider arguments were templates may hold them, they're sent as they are.
+type spiderArgSyntaxError struct {
+	Err error
+}
+
+func (e *spiderArgSyntaxError) Error() string {
+	return fmt.Sprintf(`%v, write {{"{{"}} for a literal {{`, e.Err)
+}
+
+func (e *spiderArgSyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// parseSpiderArg parses a spider argument template. Only plain {{ }} expressions calling spiderArgFuncs are allowed,
+// control structures such as if, range and template could make a fire loop or fail in ways that only show when it
+// fires, builtins such as printf could make it allocate without bound.
+func parseSpiderArg(value string) (*template.Template, error) {
+	tmpl, err := template.New("spider_arg").Funcs(spiderArgFuncs).Parse(value)
+	if err != nil {
+		return nil, &spiderArgSyntaxError{Err: err}
+	}
+	for _, node := range tmpl.Tree.Root.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+		case *parse.ActionNode:
+			err = checkSpiderArgPipe(node.Pipe)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("only {{ }} expressions are allowed, not %s", node)
+		}
+	}
+	return tmpl, nil
+}
+
+// checkSpiderArgPipe walks the commands of a pipeline, parenthesized pipelines included, and refuses the functions which
+// aren't spiderArgFuncs.
+func checkSpiderArgPipe(pipe *parse.PipeNode) error {
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			if err := checkSpiderArgNode(arg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkSpiderArgNode(node parse.Node) error {
+	switch node := node.(type) {
+	case *parse.IdentifierNode:
+		if _, ok := spiderArgFuncs[node.Ident]; !ok {
+			return fmt.Errorf("function %s isn't allowed", node.Ident)
+		}
+	case *parse.PipeNode:
+		return checkSpiderArgPipe(node)
+	case *parse.ChainNode:
+		return checkSpiderArgNode(node.Node)
+	}
+	return nil
+}
+
+// spiderArgBuffer errors once an evaluated template grows past spiderArgMaxLength.
+type spiderArgBuffer struct {
+	strings.Builder
+}
+
+func (b *spiderArgBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > spiderArgMaxLength {
+		return 0, errSpiderArgTooLong
+	}
+	return b.Builder.Write(p)
+}
+
+// renderSpiderArgs evaluates the templates in the values, now is what the now function of the templates returns. Values
+// which don't parse as templates are sent as they are, see spiderArgSyntaxError.
+func renderSpiderArgs(values url.Values, data spiderArgData, now time.Time) (url.Values, error) {
+	rendered := make(url.Values, len(values))
+	for key, vs := range values {
+		for _, value := range vs {
+			if isSpiderArgTemplate(value) {
+				tmpl, err := parseSpiderArg(value)
+				var syntaxErr *spiderArgSyntaxError
+				if errors.As(err, &syntaxErr) {
+					rendered[key] = append(rendered[key], value)
+					continue
+				} else if err != nil {
+					return nil, fmt.Errorf("spider argument %s: %w", key, err)
+				}
+				tmpl.Funcs(template.FuncMap{"now": func() time.Time { return now }})
+				var buffer spiderArgBuffer
+				err = tmpl.Execute(&buffer, data)
+				if err != nil {
+					return nil, fmt.Errorf("spider argument %s: %w", key, err)
+				}
+				value = buffer.String()
+			}
+			rendered[key] = append(rendered[key], value)
+		}
+	}
+	return rendered, nil
+}
+
+// validateSpiderArgs checks the templates in spider values when a task is saved, rather than when it fires. It evaluates
+// them with placeholder data, so mistakes such as calling a function with the wrong arguments show up too.
+func validateSpiderArgs(v *validator.Validator, field string, values url.Values) {
+	for key, vs := range values {
+		for _, value := range vs {
+			if !isSpiderArgTemplate(value) {
+				continue
+			}
+			if _, err := parseSpiderArg(value); err != nil {
+				v.AddFieldError(field, fmt.Sprintf("Invalid spider argument template: spider argument %s: %v", key, err))
+				return
+			}
+		}
+	}
+	placeholder := spiderArgData{TaskName: "task", TaskID: "id", Project: "project", Spider: "spider", Node: "node", JobID: "job"}
+	_, err := renderSpiderArgs(values, placeholder, time.Now())
+	v.CheckField(err == nil, field, fmt.Sprintf("Invalid spider argument template: %v", err))
+}
+
+// fireTime is what now returns in spider argument templates, the fire a catch-up fire makes up for or else the current
+// time, in the time zone of the task.
+func (t *task) fireTime() (time.Time, error) {
+	loc, err := taskLocation(t.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !t.missedFire.IsZero() {
+		return t.missedFire.In(loc), nil
+	}
+	return time.Now().In(loc), nil
+}
+
+// renderSpiderArgs evaluates the spider argument templates of every run of a fire and records the values each run is
+// scheduled with on its job. Jobs from the Scrapyd compatible endpoints are scheduled with their arguments as they are.
+func (t *task) renderSpiderArgs(ctx context.Context, runs []*task) error {
+	now, err := t.fireTime()
+	if err != nil {
+		return err
+	}
+	var previousJobID string
+	if !t.rawArgs && !t.OneTimeJob && hasSpiderArgTemplates(t.SpiderValues) {
+		previousJobID, err = t.DB.GetLastFinishedJobForTask(ctx, t.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+	for _, run := range runs {
+		if !t.rawArgs {
+			run.SpiderValues, err = renderSpiderArgs(run.SpiderValues, spiderArgData{
+				TaskName:      t.TaskName,
+				TaskID:        t.ID.String(),
+				Project:       t.Project,
+				Spider:        t.Spider,
+				Node:          run.NodeName,
+				JobID:         run.JobID,
+				PreviousJobID: previousJobID,
+			}, now)
+			if err != nil {
+				return err
+			}
+		}
+		encoded := run.SpiderValues.Encode()
+		err = t.DB.SetJobSpiderArgs(ctx, database.SetJobSpiderArgsParams{
+			SpiderArgs: database.CreateSqlNullString(&encoded),
+			JobID:      run.JobID,
+			Project:    run.Project,
+			Node:       run.NodeName,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasSpiderArgTemplates(values url.Values) bool {
+	for _, vs := range values {
+		for _, value := range vs {
+			if isSpiderArgTemplate(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/funcs"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRenderSpiderArgs(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 30, 0, 0, time.FixedZone("CET", 3600))
+	data := spiderArgData{TaskName: "nightly", TaskID: "id", Project: "shop", Spider: "products", Node: "node1", JobID: "job1", PreviousJobID: "job0"}
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"Plain value", "books", "books"},
+		{"Yesterday", `{{ now | addDays -1 | format "2006-01-02" }}`, "2024-02-29"},
+		{"Last month", `{{ now | addMonths -1 | format "2006-01" }}`, "2024-02"},
+		{"Duration", `{{ now | add "-36h" | format "2006-01-02T15" }}`, "2024-02-28T12"},
+		{"UTC", `{{ now | utc | format "2006-01-02" }}`, "2024-02-29"},
+		{"Zone", `{{ now | inZone "Asia/Tokyo" | format "15:04" }}`, "08:30"},
+		{"Unix", `{{ now | unix }}`, "1709249400"},
+		{"ISO week", `{{ now | isoWeek }}`, "2024-W09"},
+		{"Text around", `s3://bucket/{{ .Project }}/{{ .Spider }}/{{ .JobID }}.jl`, "s3://bucket/shop/products/job1.jl"},
+		{"Previous job", `{{ .PreviousJobID }}`, "job0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := renderSpiderArgs(url.Values{"arg": {tt.value}}, data, now)
+			assert.NilError(t, err)
+			assert.Equal(t, rendered.Get("arg"), tt.want)
+		})
+	}
+
+	for _, value := range []string{
+		`{{ if true }}yes{{ end }}`,
+		`{{ range .Project }}{{ end }}`,
+		`{{ .Missing }}`,
+		`{{ now | add "tomorrow" }}`,
+		`{{ now | inZone "Mars/Olympus_Mons" }}`,
+		`{{ printf "%999999999d" 1 }}`,
+		`{{ now | format (printf "%999999999d" 1) }}`,
+		`{{ (print 1).Year }}`,
+		`{{ call .Project }}`,
+		`{{ len .Project }}`,
+	} {
+		t.Run(value, func(t *testing.T) {
+			_, err := renderSpiderArgs(url.Values{"arg": {value}}, data, now)
+			assert.Equal(t, err != nil, true)
+		})
+	}
+	// Tasks saved before spider arguments were templates may hold a literal {{
+	for _, value := range []string{`{{ now`, `{{ now | nope }}`, `a {{b}} c`} {
+		t.Run("Literal "+value, func(t *testing.T) {
+			rendered, err := renderSpiderArgs(url.Values{"arg": {value}}, data, now)
+			assert.NilError(t, err)
+			assert.Equal(t, rendered.Get("arg"), value)
+		})
+	}
+	t.Run("Escaped", func(t *testing.T) {
+		rendered, err := renderSpiderArgs(url.Values{"arg": {`{{"{{"}}.JobID}}`}}, data, now)
+		assert.NilError(t, err)
+		assert.Equal(t, rendered.Get("arg"), "{{.JobID}}")
+	})
+	t.Run("Too long", func(t *testing.T) {
+		_, err := renderSpiderArgs(url.Values{"arg": {strings.Repeat("{{ .TaskName }}", spiderArgMaxLength)}}, data, now)
+		assert.Equal(t, err != nil, true)
+		assert.StringContains(t, err.Error(), errSpiderArgTooLong.Error())
+	})
+}
+
+func TestTaskFireRendersSpiderArgs(t *testing.T) {
+	ta := newTestApplication(t)
+	ctx := context.Background()
+	var mu sync.Mutex
+	var scheduled []url.Values
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/listjobs.json":
+			_, err := w.Write([]byte(`{"node_name": "args_node", "status": "ok", "pending": [], "running": [], "finished": []}`))
+			assert.NilError(t, err)
+		case "/schedule.json":
+			assert.NilError(t, r.ParseForm())
+			mu.Lock()
+			scheduled = append(scheduled, r.Form)
+			mu.Unlock()
+			_, err := w.Write([]byte(`{"node_name": "args_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err := ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: "args_node", Url: mockScrapyd.URL})
+	assert.NilError(t, err)
+	taskName := "args_task"
+	taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+		ID:                uuid.New(),
+		Name:              database.CreateSqlNullString(&taskName),
+		Project:           "shop",
+		Spider:            "products",
+		Jobid:             taskName,
+		SettingsArguments: "project=shop&spider=products",
+		CronString:        "0 6 * * *",
+		Paused:            true,
+		RetryMaxAttempts:  1,
+		OverlapPolicy:     overlapAllow,
+	})
+	assert.NilError(t, err)
+	_, err = ta.DB.queries.InsertJob(ctx, database.InsertJobParams{
+		Project: "shop", Spider: "products", Job: "finished_job", Status: "finished",
+		CreateTime: time.Now(), Node: "args_node", TaskID: taskDb.ID,
+	})
+	assert.NilError(t, err)
+	newFire := func(jobID string, args url.Values) *task {
+		createdTask, err := ta.newTask(false, &taskDb.ID, taskName, "products", "shop", "args_node", args, nil)
+		assert.NilError(t, err)
+		createdTask.Overlap = overlapAllow
+		createdTask.Timezone = "UTC"
+		createdTask.JobID = jobID
+		createdTask.SpiderValues.Set("jobid", jobID)
+		return createdTask
+	}
+	job := func(t *testing.T, jobID string) database.SearchNodeJobsRow {
+		jobs, err := ta.DB.queries.SearchNodeJobs(ctx, database.SearchNodeJobsParams{SearchTerm: jobID, Node: "args_node"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(jobs), 1)
+		return jobs[0]
+	}
+
+	t.Run("Catch-up fire", func(t *testing.T) {
+		fire := newFire("templated_job", url.Values{
+			"date":     {`{{ now | addDays -1 | format "2006-01-02" }}`},
+			"output":   {`{{ .Spider }}-{{ .JobID }}`},
+			"previous": {`{{ .PreviousJobID }}`},
+			"category": {"books"},
+		})
+		fire.missedFire = time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+		err := fire.fireFunc(ctx)
+		assert.NilError(t, err)
+		mu.Lock()
+		sent := scheduled[len(scheduled)-1]
+		mu.Unlock()
+		assert.Equal(t, sent.Get("date"), "2024-02-29")
+		assert.Equal(t, sent.Get("output"), "products-templated_job")
+		assert.Equal(t, sent.Get("previous"), "finished_job")
+		assert.Equal(t, sent.Get("category"), "books")
+		stored, err := url.ParseQuery(job(t, "templated_job").SpiderArgs.String)
+		assert.NilError(t, err)
+		assert.Equal(t, stored.Get("date"), "2024-02-29")
+		assert.Equal(t, stored.Get("output"), "products-templated_job")
+	})
+	t.Run("Failing template", func(t *testing.T) {
+		mu.Lock()
+		sentBefore := len(scheduled)
+		mu.Unlock()
+		err := newFire("failing_job", url.Values{"date": {`{{ now | inZone "Mars/Olympus_Mons" }}`}}).fireFunc(ctx)
+		assert.Equal(t, err != nil, true)
+		mu.Lock()
+		assert.Equal(t, len(scheduled), sentBefore)
+		mu.Unlock()
+		failed := job(t, "failing_job")
+		assert.Equal(t, failed.Status, "error")
+		assert.StringContains(t, funcs.SafeBase64Decode(failed.Error.String), "spider argument date")
+	})
+}
+
+func TestSpiderArgsValidation(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+
+	t.Run("Task form", func(t *testing.T) {
+		code, _, body := ts.get(t, "/add-task")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Values can be templates which are evaluated on every fire")
+		form := url.Values{
+			"csrf_token": {extractCSRFToken(t, body)},
+			"project":    {"shop"},
+			"spider":     {"products"},
+			"task_name":  {"templated_task"},
+			"cron_input": {"0 6 * * *"},
+			"date":       {`{{ now | nope }}`},
+		}
+		code, _, body = ts.postForm(t, "/add-task", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Invalid spider argument template: spider argument date")
+	})
+	t.Run("API", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPost, "/api/v1/tasks", map[string]any{
+			"name":    "templated_task",
+			"project": "shop",
+			"spider":  "products",
+			"cron":    "0 6 * * *",
+			"args":    map[string]string{"date": `{{ if true }}yes{{ end }}`},
+		})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		var envelope apiErrorEnvelope
+		assert.NilError(t, json.Unmarshal(body, &envelope))
+		assert.StringContains(t, envelope.Error.Fields["args"], "only {{ }} expressions are allowed")
+
+		code, _, body = ts.doJSON(t, http.MethodPost, "/api/v1/tasks", map[string]any{
+			"name":    "templated_task",
+			"project": "shop",
+			"spider":  "products",
+			"cron":    "0 6 * * *",
+			"args":    map[string]string{"padding": `{{ printf "%999999999d" 1 }}`},
+		})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.NilError(t, json.Unmarshal(body, &envelope))
+		assert.StringContains(t, envelope.Error.Fields["args"], "function printf isn't allowed")
+	})
+}
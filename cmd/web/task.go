@@ -20,11 +20,13 @@ import (
 )
 
 type task struct {
-	ID           uuid.UUID
-	JobID        string
-	Project      string
-	Spider       string
-	TaskName     string
+	ID       uuid.UUID
+	JobID    string
+	Project  string
+	Spider   string
+	TaskName string
+	// NodeName is the node a one-time job runs on, tasks with targets pick their nodes on every fire and each run gets
+	// its own copy of the task with NodeName set, see runsForFire
 	NodeName     string
 	Secret       string
 	SpiderValues url.Values
@@ -32,8 +34,35 @@ type task struct {
 	Logger       *slog.Logger
 	User         *database.User
 	OneTimeJob   bool
-	mu           *sync.Mutex
-	scheduler    gocron.Scheduler
+	Retry        retryPolicy
+	Overlap      string
+	FanOut       string
+	// Timezone is the IANA time zone the schedule and blackout calendars are evaluated in, empty is time.Local
+	Timezone string
+	// TriggeredBy records what started the job when it wasn't a user or the schedule, e.g. a webhook
+	TriggeredBy string
+	// missedFire is the scheduled fire a catch-up fire makes up for, see catchUpMisfires
+	missedFire time.Time
+	// rawArgs sends SpiderValues without evaluating the templates in them, see renderSpiderArgs
+	rawArgs bool
+	// overrideMaintenance lets one-time jobs and fires through fireNow run while maintenance mode is on, set by the
+	// entry points firing by hand once maintenanceRefusal passed
+	overrideMaintenance bool
+	// cluster decides whether a run on the schedule fires on this instance, see scheduledFire
+	cluster *cluster
+	// manualRuns are the triggers of the runs fired by hand, see scheduledFire
+	manualRuns *manualRuns
+	// schedule is the cron expression the task is registered with, scheduledFire works out when a fire was due from it
+	schedule string
+	// runID is the task_runs row of a run, see startRuns
+	runID int64
+	// scheduleLatency is how long the last schedule.json call of a run took
+	scheduleLatency time.Duration
+	mu              *sync.Mutex
+	scheduler       gocron.Scheduler
+	cancelJob       func(ctx context.Context, node, project, job, signal string, user *database.User) (scrapydCancelResponse, error)
+	// leastLoaded picks a node for the least-loaded fan-out, fires pass a nil request
+	leastLoaded func(ctx context.Context, r *http.Request, nodes []database.ScrapydNode) (string, error)
 }
 
 type scrapydScheduleResponse struct {
@@ -52,10 +81,17 @@ func (app *application) newTask(oneTimeJob bool, taskID *uuid.UUID, taskName, sp
 		SpiderValues: make(url.Values, len(spiderValues)),
 		TaskName:     taskName,
 		OneTimeJob:   oneTimeJob,
+		Retry:        defaultRetryPolicy(),
+		Overlap:      overlapAllow,
+		FanOut:       fanOutAll,
 		User:         user,
 		Secret:       app.config.ScrapydEncryptSecret,
 		mu:           &sync.Mutex{},
 		scheduler:    app.scheduler,
+		cluster:      app.cluster,
+		manualRuns:   &app.manualRuns,
+		cancelJob:    app.cancelScrapydJob,
+		leastLoaded:  app.leastLoadedNode,
 	}
 
 	if taskID == nil {
@@ -80,31 +116,265 @@ func (t *task) removeOneTimeJobFromScheduler(jobid uuid.UUID) {
 	}
 }
 
-func (t *task) fireFunc() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+// scheduledFire is the gocron task of a task's schedule. With -ha only the leader fires on the schedule, runs fired by hand
+// through runTaskNow fire on any instance.
+func (t *task) scheduledFire(ctx context.Context) error {
+	trigger, manual := t.manualRuns.take(t.ID)
+	if manual {
+		return t.fire(ctx, trigger)
+	}
+	if !t.cluster.isLeader() {
+		t.Logger.Debug("not the scheduler leader, skipping the fire", slog.Any("task", t.ID))
+		return nil
+	}
+	t.mu.Lock()
+	schedule := t.schedule
+	t.mu.Unlock()
+	return t.fire(ctx, runTrigger{Source: triggerCron, ScheduledAt: scheduledTime(schedule, time.Now())})
+}
+
+// fireFunc is the gocron task of one-time jobs, see fire.
+func (t *task) fireFunc(ctx context.Context) error {
+	return t.fire(ctx, runTrigger{Source: triggerManual, OverrideMaintenance: t.overrideMaintenance})
+}
+
+// fire runs the task, ctx is cancelled when the task is stopped or the scheduler shuts down. Every node the fire runs on
+// gets its own job, all of them are recorded before the overlap policy is applied to the fire as a whole. The fire and
+// how each of its runs ended is recorded in task_runs.
+func (t *task) fire(ctx context.Context, trigger runTrigger) error {
+	var runs []*task
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			t.recordPanic(trigger, runs, recovered)
+			panic(recovered)
+		}
+	}()
+	t.mu.Lock()
+	jobID := t.JobID
+	t.mu.Unlock()
+	t.markCaughtUp()
+	insertCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	if err := t.insertJobIntoDB(ctx); err != nil {
+	runs, err := t.startFire(insertCtx, trigger, jobID)
+	if err != nil {
+		return err
+	}
+	queued, err := t.checkFire(insertCtx, trigger, runs)
+	if isSkippedFire(err) {
+		return nil
+	} else if err != nil {
 		return err
 	}
+	if queued {
+		return t.scheduleQueuedRuns(ctx, runs)
+	}
+	return scheduleRuns(ctx, runs, false)
+}
 
-	req, err := t.createScrapydRequest(ctx)
+// startFire records a run in task_runs and a job for every node the fire runs on, see runsForFire.
+func (t *task) startFire(ctx context.Context, trigger runTrigger, jobID string) ([]*task, error) {
+	runs, err := t.runsForFire(ctx, jobID)
 	if err != nil {
-		return err
+		t.recordFailedFire(trigger, err)
+		return nil, err
 	}
+	t.startRuns(ctx, trigger, runs)
+	for _, run := range runs {
+		if err := run.insertJobIntoDB(ctx, run.JobID); err != nil {
+			finishRuns(runs, err)
+			return nil, err
+		}
+	}
+	return runs, nil
+}
 
-	if err := t.scheduleSpider(req); err != nil {
+// checkFire decides whether the runs of a fire go ahead, through maintenance mode, the blackout calendars and the
+// overlap policy, and renders their spider arguments. A fire which can't go ahead is recorded on every job and its error
+// returned, queued reports whether it has to wait for the earlier runs first, see scheduleQueuedRuns.
+func (t *task) checkFire(ctx context.Context, trigger runTrigger, runs []*task) (queued bool, err error) {
+	err = t.checkMaintenance(ctx, trigger)
+	if err == nil {
+		// A catch-up fire is suppressed by the windows its missed fire fell in, see fireTime
+		var at time.Time
+		at, err = t.fireTime()
+		if err == nil {
+			err = t.checkBlackout(ctx, at)
+		}
+	}
+	if err == nil {
+		queued, err = t.checkOverlap(ctx, runs)
+	}
+	if err == nil {
+		err = t.renderSpiderArgs(ctx, runs)
+	}
+	if err != nil {
+		for _, run := range runs {
+			run.recordFireError(run.JobID, err)
+		}
+		return false, err
+	}
+	return queued, nil
+}
+
+// scheduleQueuedRuns waits until the earlier runs of the task are done and schedules the runs of a queued fire.
+func (t *task) scheduleQueuedRuns(ctx context.Context, runs []*task) error {
+	err := t.waitInQueue(ctx, runs)
+	if errors.Is(err, errQueuedJobFailed) {
+		return err
+	} else if err != nil {
+		for _, run := range runs {
+			run.recordFireError(run.JobID, err)
+		}
 		return err
 	}
+	return scheduleRuns(ctx, runs, false)
+}
+
+// runsForFire returns a copy of the task for every node the fire runs on, each with its own job ID and spider values so
+// beforeJobRuns can prepare the next fire while these are still retrying. A task with a NodeName runs there as jobID,
+// otherwise the nodes are picked from its targets and jobID is ignored.
+func (t *task) runsForFire(ctx context.Context, jobID string) ([]*task, error) {
+	if t.NodeName != "" {
+		return []*task{t.onNode(t.NodeName, jobID)}, nil
+	}
+	nodes, err := t.fireNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*task, 0, len(nodes))
+	for _, node := range nodes {
+		runs = append(runs, t.onNode(node, t.nextJobID(node)))
+	}
+	return runs, nil
+}
+
+func (t *task) onNode(node, jobID string) *task {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	run := *t
+	run.NodeName = node
+	run.JobID = jobID
+	run.SpiderValues = maps.Clone(t.SpiderValues)
+	run.SpiderValues.Set("jobid", jobID)
+	return &run
+}
+
+// scheduleRuns schedules every run of a fire at the same time, each retrying on its own. Failures are recorded on the
+// job of the run and joined into the returned error. With retryInBackground a run whose first attempt failed but may be
+// retried carries on retrying after scheduleRuns returned and doesn't count as failed.
+func scheduleRuns(ctx context.Context, runs []*task, retryInBackground bool) error {
+	errs := make([]error, len(runs))
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := run.scheduleAttempt(ctx, run.SpiderValues)
+			if err != nil && retryInBackground && run.Retry.MaxAttempts > 1 && run.Retry.retryable(err) {
+				go func() {
+					_ = run.finishAttempts(context.Background(), err)
+				}()
+				return
+			}
+			err = run.finishAttempts(ctx, err)
+			if err != nil {
+				errs[i] = err
+				if len(runs) > 1 {
+					errs[i] = fmt.Errorf("node %s: %w", run.NodeName, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
 
+// finishAttempts retries the run after its first attempt failed with err, if it did, and records how the run ended.
+func (t *task) finishAttempts(ctx context.Context, err error) error {
+	if err != nil {
+		err = t.retryFailedAttempt(ctx, t.JobID, t.SpiderValues, 1, err)
+	}
+	if err != nil {
+		t.recordFireError(t.JobID, err)
+		return err
+	}
+	t.finishRun(nil)
 	return nil
 }
 
-func (t *task) insertJobIntoDB(ctx context.Context) error {
+// scheduleAttempt sends a single schedule.json request, how long Scrapyd took to answer is kept in scheduleLatency.
+func (t *task) scheduleAttempt(ctx context.Context, spiderValues url.Values) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := t.createScrapydRequest(ctx, spiderValues)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = t.scheduleSpider(req)
+	t.scheduleLatency = time.Since(start)
+	return err
+}
+
+// retryFailedAttempt keeps scheduling the spider after attempt failed with err, for as long as the retry policy allows.
+// Every failed attempt is recorded on the job along with when the next one is due. It returns nil once an attempt
+// succeeds, otherwise the last error, which says how many attempts were made if the fire was retried.
+func (t *task) retryFailedAttempt(ctx context.Context, jobID string, spiderValues url.Values, attempt int, err error) error {
+	for {
+		if attempt >= t.Retry.MaxAttempts || !t.Retry.retryable(err) {
+			if attempt == 1 {
+				return err
+			}
+			t.recordAttempt(jobID, attempt, err, time.Time{})
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		}
+		delay := t.Retry.delay(attempt)
+		t.recordAttempt(jobID, attempt, err, time.Now().Add(delay))
+		t.Logger.Warn("failed to schedule task, retrying", slog.Any("task", t.ID), slog.Any("jobID", jobID),
+			slog.Any("attempt", attempt), slog.Any("delay", delay), slog.Any("err", err))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up after %d attempts, the task was stopped: %w", attempt, err)
+		case <-time.After(delay):
+		}
+		attempt++
+		err = t.scheduleAttempt(ctx, spiderValues)
+		if err == nil {
+			t.recordAttempt(jobID, attempt, nil, time.Time{})
+			return nil
+		}
+	}
+}
+
+// recordAttempt stores the attempt count on the job, with the error of the failed attempt and when the next one is due.
+// A zero nextRetry means there won't be another attempt.
+func (t *task) recordAttempt(jobID string, attempt int, err error, nextRetry time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	params := database.SetJobAttemptParams{
+		Attempts:    int64(attempt),
+		NextRetryAt: sql.NullTime{Time: nextRetry, Valid: !nextRetry.IsZero()},
+		JobID:       jobID,
+		Project:     t.Project,
+		Node:        t.NodeName,
+	}
+	if err != nil {
+		errAsString := base64.StdEncoding.EncodeToString([]byte(err.Error()))
+		params.Error = database.CreateSqlNullString(&errAsString)
+	}
+	if dbErr := t.DB.SetJobAttempt(ctx, params); dbErr != nil {
+		t.Logger.ErrorContext(ctx, "error saving attempt for task into database", slog.Any("jobID", jobID), slog.Any("err", dbErr))
+	}
+}
+
+func (t *task) insertJobIntoDB(ctx context.Context, jobID string) error {
 	insertParam := database.InsertJobParams{
 		Project:    t.Project,
 		Spider:     t.Spider,
-		Job:        t.JobID,
+		Job:        jobID,
 		Status:     "scheduled",
 		Deleted:    false,
 		CreateTime: time.Now(),
@@ -117,14 +387,15 @@ func (t *task) insertJobIntoDB(ctx context.Context) error {
 	if t.User != nil && t.OneTimeJob {
 		insertParam.StartedBy = t.User.ID
 	}
+	insertParam.TriggeredBy = database.CreateSqlNullString(&t.TriggeredBy)
 	_, err := t.DB.InsertJob(ctx, insertParam)
 	return err
 }
 
-func (t *task) createScrapydRequest(ctx context.Context) (*http.Request, error) {
+func (t *task) createScrapydRequest(ctx context.Context, spiderValues url.Values) (*http.Request, error) {
 	return makeRequestToScrapyd(ctx, t.DB, http.MethodPost, t.NodeName, func(url *url.URL) *url.URL {
 		url.Path = path.Join(url.Path, scrapydScheduleSpider)
-		url.RawQuery = t.SpiderValues.Encode()
+		url.RawQuery = spiderValues.Encode()
 		return url
 	}, nil, &http.Header{
 		"Content-Type": []string{"application/x-www-form-urlencoded"},
@@ -138,52 +409,114 @@ func (t *task) scheduleSpider(req *http.Request) error {
 	}
 
 	if strings.ToLower(strings.TrimSpace(scheduleResp.Status)) != "ok" {
-		return errors.New(scheduleResp.Status)
+		return &scrapydScheduleError{Status: scheduleResp.Status}
 	}
 
 	return nil
 }
 
+// scrapydScheduleError is schedule.json answering with a status other than ok.
+type scrapydScheduleError struct {
+	Status string
+}
+
+func (e *scrapydScheduleError) Error() string {
+	return e.Status
+}
+
+// beforeJobRuns names the next job of a task bound to a node, runsForFire names the jobs of tasks with targets.
 func (t *task) beforeJobRuns(jobID uuid.UUID, jobName string) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	if t.OneTimeJob {
-		t.JobID = fmt.Sprintf("one_time_job_%s_%s_%s", t.Spider, t.NodeName, time.Now().Format("2006-01-02T15_04_05"))
-	} else {
-		t.JobID = fmt.Sprintf("task_%s_%s_%s", t.Spider, t.NodeName, time.Now().Format("2006-01-02T15_04_05"))
+	if t.NodeName == "" {
+		return
 	}
+	t.JobID = t.nextJobID(t.NodeName)
 	t.SpiderValues.Set("jobid", t.JobID)
 }
 
+func (t *task) nextJobID(node string) string {
+	if t.OneTimeJob {
+		return fmt.Sprintf("one_time_job_%s_%s_%s", t.Spider, node, time.Now().Format("2006-01-02T15_04_05"))
+	}
+	if !t.missedFire.IsZero() {
+		return fmt.Sprintf("task_%s_%s_%s_%s", t.Spider, node, misfireTriggeredBy, t.missedFire.Format("2006-01-02T15_04_05"))
+	}
+	return fmt.Sprintf("task_%s_%s_%s", t.Spider, node, time.Now().Format("2006-01-02T15_04_05"))
+}
+
 func (t *task) afterTaskRunsWithSuccess(jobID uuid.UUID, jobName string) {
 	defer t.removeOneTimeJobFromScheduler(jobID)
 	t.Logger.Debug("task started successfully", slog.Any("jobID", jobID), slog.Any("jobName", jobName))
 }
 
+// afterTaskRunsWithError only logs, fireFunc records the error on its own job since by now the next run could have
+// changed t.JobID.
 func (t *task) afterTaskRunsWithError(jobID uuid.UUID, jobName string, err error) {
 	defer t.removeOneTimeJobFromScheduler(jobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		t.Logger.Error("no row in database, insert failed?", slog.Any("jobID", jobID), slog.Any("jobName", jobName))
+		return
+	}
+	t.Logger.Error("error in task", slog.Any("task", jobID), slog.Any("jobName", jobName), slog.Any("err", err))
+}
+
+// recordJobError stores the final error on the job row, base64 encoded, and marks it as errored. Readers decode it with
+// funcs.SafeBase64Decode. The task run of the job is recorded as failed.
+func (t *task) recordJobError(jobID string, err error) {
+	t.finishRun(err)
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
+	errAsString := base64.StdEncoding.EncodeToString([]byte(err.Error()))
+	dbErr := t.DB.SetErrorWhereJobId(ctx, database.SetErrorWhereJobIdParams{
+		Error:   database.CreateSqlNullString(&errAsString),
+		JobID:   jobID,
+		Project: t.Project,
+		Node:    t.NodeName,
+	})
+	if dbErr != nil {
+		t.Logger.ErrorContext(ctx, "error saving error for task into database", slog.Any("jobID", jobID), slog.Any("err", dbErr))
+	}
+}
 
-	defer func() {
-		if r := recover(); r != nil {
-			t.Logger.Error("panic in afterTaskRunsWithError", slog.Any("jobID", jobID), slog.Any("jobName", jobName), slog.Any("recoverData", r))
-		}
-	}()
-	t.Logger.ErrorContext(ctx, "error in task", slog.Any("task", jobID), slog.Any("jobName", jobName), slog.Any("err", err))
-	if !errors.Is(err, sql.ErrNoRows) {
-		errAsString := base64.StdEncoding.EncodeToString([]byte(err.Error()))
-		if dbErr := t.DB.SetErrorWhereJobId(ctx, database.SetErrorWhereJobIdParams{
-			Error:   database.CreateSqlNullString(&errAsString),
-			JobID:   t.JobID,
-			Project: t.Project,
-			Node:    t.NodeName,
-		}); dbErr != nil {
-			t.Logger.ErrorContext(ctx, "error saving error for task into database", slog.Any("jobID", jobID), slog.Any("jobName", jobName), slog.Any("err", dbErr))
-		}
-	} else {
-		t.Logger.Error("no row in database, insert failed?", slog.Any("jobID", jobID), slog.Any("jobName", jobName))
+// firedJob is a job fireNow started, the Scrapyd job ID and the node it runs on.
+type firedJob struct {
+	Node string `json:"node"`
+	Job  string `json:"job"`
+}
+
+// fireNow schedules the spider right away instead of through gocron, for callers which have to answer with the Scrapyd
+// job IDs. A task with a NodeName runs there as jobID, otherwise on the nodes its targets resolve to. If the first
+// attempt on a node fails and the retry policy allows another, the retries carry on in the background and the node
+// doesn't count as failed, failures are recorded on the job the same way they are for scheduled runs. A fire which the
+// overlap policy queues waits in the background as well, a skipped one returns an *overlapSkippedError, one a
+// blackout calendar suppressed a *blackoutError and one maintenance mode suppressed a *maintenanceError.
+func (t *task) fireNow(jobID string) ([]firedJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	trigger := t.fireNowTrigger()
+	runs, err := t.startFire(ctx, trigger, jobID)
+	if err != nil {
+		return nil, err
+	}
+	fired := make([]firedJob, 0, len(runs))
+	for _, run := range runs {
+		fired = append(fired, firedJob{Node: run.NodeName, Job: run.JobID})
 	}
+	queued, err := t.checkFire(ctx, trigger, runs)
+	if err != nil {
+		return nil, err
+	}
+	if queued {
+		go func() {
+			err := t.scheduleQueuedRuns(context.Background(), runs)
+			if err != nil {
+				t.Logger.Error("error scheduling queued fire", slog.Any("task", t.ID), slog.Any("err", err))
+			}
+		}()
+		return fired, nil
+	}
+	return fired, scheduleRuns(ctx, runs, true)
 }
 
 func (t *task) afterTaskPanics(jobID uuid.UUID, jobName string, recoverData any) {
@@ -203,7 +536,10 @@ func (t *task) newOneTimeJob() (job gocron.Job, err error) {
 }
 
 func (t *task) newCronJob(schedule string) (job gocron.Job, err error) {
-	return t.scheduler.NewJob(gocron.CronJob(schedule, false), gocron.NewTask(t.fireFunc),
+	t.markCaughtUp()
+	t.setSchedule(schedule)
+	t.cluster.markRegistered(t.ID)
+	return t.scheduler.NewJob(gocron.CronJob(cronInTimezone(schedule, t.Timezone), false), gocron.NewTask(t.scheduledFire),
 		gocron.WithName(t.TaskName), gocron.WithIdentifier(t.ID), gocron.WithEventListeners(
 			gocron.BeforeJobRuns(t.beforeJobRuns),
 			gocron.AfterJobRuns(t.afterTaskRunsWithSuccess),
@@ -213,7 +549,10 @@ func (t *task) newCronJob(schedule string) (job gocron.Job, err error) {
 }
 
 func (t *task) updatesResource(toUpdate uuid.UUID, schedule string) (job gocron.Job, err error) {
-	return t.scheduler.Update(toUpdate, gocron.CronJob(schedule, false), gocron.NewTask(t.fireFunc),
+	t.markCaughtUp()
+	t.setSchedule(schedule)
+	t.cluster.markRegistered(t.ID)
+	return t.scheduler.Update(toUpdate, gocron.CronJob(cronInTimezone(schedule, t.Timezone), false), gocron.NewTask(t.scheduledFire),
 		gocron.WithName(t.TaskName), gocron.WithIdentifier(t.ID), gocron.WithEventListeners(
 			gocron.BeforeJobRuns(t.beforeJobRuns),
 			gocron.AfterJobRuns(t.afterTaskRunsWithSuccess),
@@ -221,3 +560,9 @@ func (t *task) updatesResource(toUpdate uuid.UUID, schedule string) (job gocron.
 			gocron.AfterJobRunsWithPanic(t.afterTaskPanics),
 		))
 }
+
+func (t *task) setSchedule(schedule string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.schedule = cronInTimezone(schedule, t.Timezone)
+}
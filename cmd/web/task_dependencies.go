@@ -0,0 +1,373 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+const (
+	dependenciesTriggeredBy = "dependencies"
+	workflowRunsLimit       = 25
+)
+
+// A task dependency makes its task fire once every upstream task has finished a job since the task last fired (or
+// since the dependency was added) with at least MinItems items. Dependencies fire the task even when its own schedule
+// is paused, pause it to only run it after its upstream tasks.
+
+// dependencyCreatesCycle reports whether making taskID depend on upstreamID would let a task wait on itself, that is
+// when taskID already is upstream of upstreamID.
+func dependencyCreatesCycle(deps []database.ListTaskDependenciesRow, taskID, upstreamID uuid.UUID) bool {
+	upstreams := make(map[uuid.UUID][]uuid.UUID)
+	for _, dep := range deps {
+		upstreams[dep.TaskID] = append(upstreams[dep.TaskID], dep.UpstreamTaskID)
+	}
+	seen := make(map[uuid.UUID]bool)
+	stack := []uuid.UUID{upstreamID}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == taskID {
+			return true
+		}
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+		stack = append(stack, upstreams[current]...)
+	}
+	return false
+}
+
+// workflowLevels places every task of the graph in a column, tasks without upstream tasks are at level 0 and every
+// other task sits one level after its furthest upstream task. The graph must not contain cycles.
+func workflowLevels(deps []database.ListTaskDependenciesRow) map[uuid.UUID]int {
+	upstreams := make(map[uuid.UUID][]uuid.UUID)
+	levels := make(map[uuid.UUID]int)
+	for _, dep := range deps {
+		upstreams[dep.TaskID] = append(upstreams[dep.TaskID], dep.UpstreamTaskID)
+		levels[dep.TaskID] = -1
+		levels[dep.UpstreamTaskID] = -1
+	}
+	var level func(taskID uuid.UUID) int
+	level = func(taskID uuid.UUID) int {
+		if levels[taskID] >= 0 {
+			return levels[taskID]
+		}
+		deepest := 0
+		for _, upstreamID := range upstreams[taskID] {
+			deepest = max(deepest, level(upstreamID)+1)
+		}
+		levels[taskID] = deepest
+		return deepest
+	}
+	for taskID := range levels {
+		level(taskID)
+	}
+	return levels
+}
+
+type workflowTask struct {
+	ID        uuid.UUID
+	Name      string
+	Project   string
+	Targets   string
+	Upstreams []database.ListTaskDependenciesRow
+}
+
+// workflowColumns groups the tasks of the graph by their level, each task carries the dependencies it waits on.
+func workflowColumns(deps []database.ListTaskDependenciesRow) [][]*workflowTask {
+	levels := workflowLevels(deps)
+	tasks := make(map[uuid.UUID]*workflowTask)
+	add := func(id uuid.UUID, name sql.NullString, project, targets string) *workflowTask {
+		if _, ok := tasks[id]; !ok {
+			tasks[id] = &workflowTask{ID: id, Name: name.String, Project: project, Targets: targets}
+		}
+		return tasks[id]
+	}
+	for _, dep := range deps {
+		add(dep.UpstreamTaskID, dep.UpstreamName, dep.UpstreamProject, dep.UpstreamTargets)
+		downstream := add(dep.TaskID, dep.TaskName, dep.TaskProject, dep.TaskTargets)
+		downstream.Upstreams = append(downstream.Upstreams, dep)
+	}
+	var columns [][]*workflowTask
+	for id, wt := range tasks {
+		for len(columns) <= levels[id] {
+			columns = append(columns, nil)
+		}
+		columns[levels[id]] = append(columns[levels[id]], wt)
+	}
+	for _, column := range columns {
+		slices.SortFunc(column, func(a, b *workflowTask) int {
+			return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID.String(), b.ID.String()))
+		})
+	}
+	return columns
+}
+
+// jobFinished is called by the polling code for every finished job it sees. The job satisfies the dependencies on its
+// task which are still waiting, and tasks whose dependencies are all satisfied are fired.
+func (app *application) jobFinished(ctx context.Context, job database.Job) {
+	if job.Status != "finished" {
+		return
+	}
+	// Fires retry on their own and may wait on the overlap policy, so they run without holding up polling or dependencyMu
+	for _, t := range app.satisfyDependencies(ctx, job) {
+		go func() {
+			_, err := t.fireNow("")
+			if err != nil {
+				app.logger.Error("error firing task with satisfied dependencies", slog.Any("taskID", t.ID), slog.Any("err", err))
+				return
+			}
+			app.logger.Info("fired task after its dependencies finished", slog.Any("taskID", t.ID))
+		}()
+	}
+}
+
+// satisfyDependencies records the job on the dependencies waiting on it and returns the tasks whose dependencies are
+// now all satisfied.
+func (app *application) satisfyDependencies(ctx context.Context, job database.Job) []*task {
+	app.dependencyMu.Lock()
+	defer app.dependencyMu.Unlock()
+	deps, err := app.DB.queries.ListDependenciesWaitingOnJob(ctx, job.ID)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error listing dependencies waiting on job", slog.Any("job", job.Job), slog.Any("err", err))
+		return nil
+	}
+	var ready []uuid.UUID
+	for _, dep := range deps {
+		satisfied := job.Items.Int64 >= dep.MinItems
+		// Polling sees finished jobs over and over, only newer jobs or changed item counts are of interest
+		if dep.UpstreamJobID.Valid && (dep.UpstreamJobID.Int64 > job.ID || (dep.UpstreamJobID.Int64 == job.ID && dep.Satisfied == satisfied)) {
+			continue
+		}
+		err = app.DB.queries.SetTaskDependencyUpstreamJob(ctx, database.SetTaskDependencyUpstreamJobParams{
+			UpstreamJobID: sql.NullInt64{Int64: job.ID, Valid: true},
+			Satisfied:     satisfied,
+			ID:            dep.ID,
+		})
+		if err != nil {
+			app.logger.ErrorContext(ctx, "error updating task dependency", slog.Any("dependency", dep.ID), slog.Any("err", err))
+			continue
+		}
+		if satisfied && !slices.Contains(ready, dep.TaskID) {
+			ready = append(ready, dep.TaskID)
+		}
+	}
+	var tasks []*task
+	for _, taskID := range ready {
+		if t := app.taskWithSatisfiedDependencies(ctx, taskID); t != nil {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks
+}
+
+// taskWithSatisfiedDependencies loads the task to fire if all of its dependencies are satisfied, nil otherwise.
+func (app *application) taskWithSatisfiedDependencies(ctx context.Context, taskID uuid.UUID) *task {
+	deps, err := app.DB.queries.ListDependenciesForTask(ctx, taskID)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error listing task dependencies", slog.Any("taskID", taskID), slog.Any("err", err))
+		return nil
+	}
+	for _, dep := range deps {
+		if !dep.Satisfied {
+			return nil
+		}
+	}
+	// Reset first so a failed fire waits for the next round of upstream jobs instead of firing on every poll
+	err = app.DB.queries.ResetTaskDependencies(ctx, taskID)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error resetting task dependencies", slog.Any("taskID", taskID), slog.Any("err", err))
+		return nil
+	}
+	taskDb, err := app.DB.queries.GetTaskWithUUID(ctx, taskID)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error getting task with satisfied dependencies", slog.Any("taskID", taskID), slog.Any("err", err))
+		return nil
+	}
+	t, err := app.taskFromDb(taskDb)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "error creating task with satisfied dependencies", slog.Any("taskID", taskID), slog.Any("err", err))
+		return nil
+	}
+	t.TriggeredBy = dependenciesTriggeredBy
+	return t
+}
+
+type workflowDependencyForm struct {
+	TaskID         string              `form:"task_id"`
+	UpstreamTaskID string              `form:"upstream_task_id"`
+	MinItems       int64               `form:"min_items"`
+	Validator      validator.Validator `form:"-"`
+}
+
+// workflows shows the dependency graph with the state of every dependency and adds new dependencies.
+func (app *application) workflows(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	scope := contextGetAccessScope(r)
+	var form workflowDependencyForm
+	status := http.StatusOK
+	deps, err := app.DB.queries.ListTaskDependencies(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if r.Method == http.MethodPost {
+		err = request.DecodePostForm(r, &form)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		params, err := app.validateWorkflowDependency(ctxwt, scope, deps, &form)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !form.Validator.HasErrors() {
+			user := contextGetAuthenticatedUser(r)
+			if user != nil {
+				params.CreatedBy = user.ID
+			}
+			_, err = app.DB.queries.InsertTaskDependency(ctxwt, params)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, "/workflows", http.StatusSeeOther)
+			return
+		}
+		status = http.StatusUnprocessableEntity
+	}
+	taskNodes, err := app.taskNodes(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	deps = slices.DeleteFunc(deps, func(dep database.ListTaskDependenciesRow) bool {
+		return !scope.allowsTask(dep.TaskProject, taskNodes[dep.TaskID]) || !scope.allowsTask(dep.UpstreamProject, taskNodes[dep.UpstreamTaskID])
+	})
+	tasks, err := app.DB.queries.GetTasks(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	tasks = slices.DeleteFunc(tasks, func(taskDb database.Task) bool {
+		return !scope.allowsTask(taskDb.Project, taskNodes[taskDb.ID])
+	})
+	runs, err := app.DB.queries.ListDependencyRuns(ctxwt, workflowRunsLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	runs = slices.DeleteFunc(runs, func(run database.ListDependencyRunsRow) bool {
+		return !scope.Allows(run.TaskProject, run.Node)
+	})
+	data := app.newTemplateData(r)
+	data["Columns"] = workflowColumns(deps)
+	data["Dependencies"] = deps
+	data["Tasks"] = tasks
+	data["Runs"] = runs
+	data["Form"] = form
+	app.render(w, r, status, workflowsPage, nil, data)
+}
+
+func (app *application) validateWorkflowDependency(ctx context.Context, scope accessScope, deps []database.ListTaskDependenciesRow, form *workflowDependencyForm) (database.InsertTaskDependencyParams, error) {
+	var params database.InsertTaskDependencyParams
+	form.Validator.CheckField(form.MinItems >= 0, "min_items", "Minimum items can't be negative")
+	taskID, err := app.workflowFormTask(ctx, scope, form.TaskID)
+	if err != nil {
+		return params, err
+	} else if taskID == uuid.Nil {
+		form.Validator.AddFieldError("task_id", "Select one of the tasks")
+	}
+	upstreamID, err := app.workflowFormTask(ctx, scope, form.UpstreamTaskID)
+	if err != nil {
+		return params, err
+	} else if upstreamID == uuid.Nil {
+		form.Validator.AddFieldError("upstream_task_id", "Select one of the tasks")
+	}
+	if form.Validator.HasErrors() {
+		return params, nil
+	}
+	switch {
+	case taskID == upstreamID:
+		form.Validator.AddFieldError("upstream_task_id", "A task can't depend on itself")
+	case slices.ContainsFunc(deps, func(dep database.ListTaskDependenciesRow) bool {
+		return dep.TaskID == taskID && dep.UpstreamTaskID == upstreamID
+	}):
+		form.Validator.AddFieldError("upstream_task_id", "The task already depends on this task")
+	case dependencyCreatesCycle(deps, taskID, upstreamID):
+		form.Validator.AddFieldError("upstream_task_id", "The upstream task already waits on this task, the workflow would never run")
+	}
+	params.TaskID = taskID
+	params.UpstreamTaskID = upstreamID
+	params.MinItems = form.MinItems
+	return params, nil
+}
+
+// workflowFormTask returns uuid.Nil when the submitted task doesn't exist or isn't in the user's scope.
+func (app *application) workflowFormTask(ctx context.Context, scope accessScope, value string) (uuid.UUID, error) {
+	taskID, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, nil
+	}
+	taskDb, err := app.DB.queries.GetTaskWithUUID(ctx, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil
+	} else if err != nil {
+		return uuid.Nil, err
+	}
+	allowed, err := app.taskInScope(ctx, scope, taskDb)
+	if err != nil || !allowed {
+		return uuid.Nil, err
+	}
+	return taskDb.ID, nil
+}
+
+func (app *application) deleteTaskDependency(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	dependencyID, err := strconv.ParseInt(r.PathValue("dependencyID"), 10, 64)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	dep, err := app.DB.queries.GetTaskDependency(ctxwt, dependencyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	allowed, err := app.tasksInScope(ctxwt, contextGetAccessScope(r), []uuid.UUID{dep.TaskID})
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(allowed) == 0 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	_, err = app.DB.queries.DeleteTaskDependency(ctxwt, dependencyID)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
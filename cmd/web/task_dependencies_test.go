@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	categories, products, reviews, images := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	deps := []database.ListTaskDependenciesRow{
+		{TaskID: products, UpstreamTaskID: categories},
+		{TaskID: reviews, UpstreamTaskID: products},
+		{TaskID: reviews, UpstreamTaskID: categories},
+		{TaskID: images, UpstreamTaskID: categories},
+	}
+	t.Run("Cycles", func(t *testing.T) {
+		assert.Equal(t, dependencyCreatesCycle(deps, categories, reviews), true)
+		assert.Equal(t, dependencyCreatesCycle(deps, products, reviews), true)
+		assert.Equal(t, dependencyCreatesCycle(deps, images, products), false)
+		assert.Equal(t, dependencyCreatesCycle(deps, reviews, images), false)
+		assert.Equal(t, dependencyCreatesCycle(nil, reviews, images), false)
+	})
+	t.Run("Levels", func(t *testing.T) {
+		levels := workflowLevels(deps)
+		assert.Equal(t, levels[categories], 0)
+		assert.Equal(t, levels[products], 1)
+		assert.Equal(t, levels[images], 1)
+		assert.Equal(t, levels[reviews], 2)
+		columns := workflowColumns(deps)
+		assert.Equal(t, len(columns), 3)
+		assert.Equal(t, len(columns[1]), 2)
+		assert.Equal(t, columns[2][0].ID, reviews)
+		assert.Equal(t, len(columns[2][0].Upstreams), 2)
+	})
+}
+
+func TestTaskDependencies(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ctx := context.Background()
+	scheduled := make(chan string, 10)
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			scheduled <- r.URL.Query().Get("spider")
+			_, err := w.Write([]byte(`{"node_name": "test_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err := ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{
+		Nodename: "test_node",
+		Url:      mockScrapyd.URL,
+	})
+	assert.NilError(t, err)
+	newTask := func(spider string) database.Task {
+		taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+			ID:                uuid.New(),
+			Name:              database.CreateSqlNullString(&spider),
+			Project:           "shop",
+			Spider:            spider,
+			Jobid:             spider,
+			SettingsArguments: "project=shop&spider=" + spider,
+			CronString:        "0 0 * * *",
+			Paused:            true,
+			RetryMaxAttempts:  1,
+			FanOut:            fanOutAll,
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{"test_node"}, nil))
+		return taskDb
+	}
+	categories, products, reviews := newTask("categories"), newTask("products"), newTask("reviews")
+	expectFired := func(t *testing.T, spider string) {
+		select {
+		case got := <-scheduled:
+			assert.Equal(t, got, spider)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was not fired", spider)
+		}
+	}
+	expectNothingFired := func(t *testing.T) {
+		select {
+		case got := <-scheduled:
+			t.Fatalf("%s was fired", got)
+		default:
+		}
+	}
+
+	t.Run("Add dependencies", func(t *testing.T) {
+		code, _, body := ts.get(t, "/workflows")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "No dependencies yet")
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}}
+		add := func(task, upstream database.Task, minItems int) (int, string) {
+			form.Set("task_id", task.ID.String())
+			form.Set("upstream_task_id", upstream.ID.String())
+			form.Set("min_items", strconv.Itoa(minItems))
+			code, _, body := ts.postForm(t, "/workflows", form)
+			return code, body
+		}
+		code, _ = add(products, categories, 0)
+		assert.Equal(t, code, http.StatusSeeOther)
+		code, _ = add(reviews, products, 1)
+		assert.Equal(t, code, http.StatusSeeOther)
+		code, body = add(reviews, products, 1)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "The task already depends on this task")
+		code, body = add(categories, reviews, 0)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "the workflow would never run")
+		code, _ = add(categories, categories, 0)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		code, _ = add(categories, products, -1)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	})
+	t.Run("Fires when upstream finishes", func(t *testing.T) {
+		_, err := ta.DB.queries.InsertJob(ctx, database.InsertJobParams{
+			Project: "shop", Spider: "categories", Job: "categories_job", Status: "running",
+			CreateTime: time.Now(), Node: "test_node", TaskID: categories.ID,
+		})
+		assert.NilError(t, err)
+		ta.doPartialUpdate(ctx, "test_node", "finished", scrapydJobType{Id: "categories_job", Project: "shop", Spider: "categories"})
+		expectFired(t, "products")
+		job, err := ta.DB.queries.GetLatestJobForTask(ctx, products.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, job.TriggeredBy.String, dependenciesTriggeredBy)
+		// Seeing the same finished job again doesn't fire the task twice
+		ta.doPartialUpdate(ctx, "test_node", "finished", scrapydJobType{Id: "categories_job", Project: "shop", Spider: "categories"})
+		expectNothingFired(t)
+	})
+	t.Run("Waits for enough items", func(t *testing.T) {
+		upstream, err := ta.DB.queries.GetLatestJobForTask(ctx, products.ID)
+		assert.NilError(t, err)
+		ta.doPartialUpdate(ctx, "test_node", "finished", scrapydJobType{Id: upstream.Job, Project: "shop", Spider: "products"})
+		expectNothingFired(t)
+		code, _, body := ts.get(t, "/workflows")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Condition not met, 0 items")
+		job, err := ta.DB.queries.InsertJob(ctx, database.InsertJobParams{
+			Project: "shop", Spider: "products", Job: upstream.Job, Status: "finished",
+			CreateTime: time.Now(), UpdateTime: time.Now(), Node: "test_node",
+			Items: sql.NullInt64{Int64: 12, Valid: true},
+		})
+		assert.NilError(t, err)
+		ta.jobFinished(ctx, job)
+		expectFired(t, "reviews")
+		code, _, body = ts.get(t, "/workflows")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Recent workflow runs")
+		assert.StringContains(t, body, "Waiting")
+	})
+	t.Run("Delete dependency", func(t *testing.T) {
+		deps, err := ta.DB.queries.ListDependenciesForTask(ctx, reviews.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(deps), 1)
+		code, _, _ := ts.delete(t, "/workflows/dependencies/"+strconv.FormatInt(deps[0].ID, 10))
+		assert.Equal(t, code, http.StatusOK)
+		code, _, _ = ts.delete(t, "/workflows/dependencies/"+strconv.FormatInt(deps[0].ID, 10))
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
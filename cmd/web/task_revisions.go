@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// taskRevision is a revision of a task with its targets and calendars decoded.
+type taskRevision struct {
+	database.TaskRevision
+	Nodes     []string
+	Groups    []int64
+	Calendars []int64
+}
+
+func newTaskRevision(rev database.TaskRevision) (taskRevision, error) {
+	revision := taskRevision{TaskRevision: rev}
+	for _, list := range []struct {
+		encoded string
+		into    any
+	}{{rev.Nodes, &revision.Nodes}, {rev.NodeGroups, &revision.Groups}, {rev.BlackoutCalendars, &revision.Calendars}} {
+		if err := json.Unmarshal([]byte(list.encoded), list.into); err != nil {
+			return taskRevision{}, fmt.Errorf("revision %d of task %s: %w", rev.Revision, rev.TaskID, err)
+		}
+	}
+	return revision, nil
+}
+
+// jsonList encodes list as a JSON array, an empty list too.
+func jsonList[T any](list []T) (string, error) {
+	if list == nil {
+		list = []T{}
+	}
+	encoded, err := json.Marshal(list)
+	return string(encoded), err
+}
+
+// recordTaskRevision appends the definition the task has in the database now to its revisions. revertedFrom is the
+// revision the task was reverted to, 0 if it wasn't.
+func (app *application) recordTaskRevision(ctx context.Context, taskID uuid.UUID, user *database.User, revertedFrom int64) error {
+	taskDb, err := app.DB.queries.GetTaskWithUUID(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	targets, err := app.taskTargets(ctx, taskDb)
+	if err != nil {
+		return err
+	}
+	calendars, err := app.DB.queries.ListTaskBlackoutCalendars(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	params := database.InsertTaskRevisionParams{
+		TaskID:                 taskID,
+		Name:                   taskDb.Name,
+		Project:                taskDb.Project,
+		Spider:                 taskDb.Spider,
+		SettingsArguments:      taskDb.SettingsArguments,
+		CronString:             taskDb.CronString,
+		Timezone:               taskDb.Timezone,
+		RetryMaxAttempts:       taskDb.RetryMaxAttempts,
+		RetryBackoffSeconds:    taskDb.RetryBackoffSeconds,
+		RetryMaxBackoffSeconds: taskDb.RetryMaxBackoffSeconds,
+		RetryOn:                taskDb.RetryOn,
+		OverlapPolicy:          taskDb.OverlapPolicy,
+		FanOut:                 taskDb.FanOut,
+		MisfirePolicy:          taskDb.MisfirePolicy,
+		MisfireMaxRuns:         taskDb.MisfireMaxRuns,
+		MaxRuntimeSeconds:      taskDb.MaxRuntimeSeconds,
+		RevertedFrom:           sql.NullInt64{Int64: revertedFrom, Valid: revertedFrom != 0},
+		CreatedAt:              time.Now(),
+	}
+	if params.Nodes, err = jsonList(targets.Nodes); err != nil {
+		return err
+	}
+	if params.NodeGroups, err = jsonList(targets.Groups); err != nil {
+		return err
+	}
+	if params.BlackoutCalendars, err = jsonList(calendars); err != nil {
+		return err
+	}
+	if user != nil {
+		params.CreatedBy = user.ID
+	}
+	_, err = app.DB.queries.InsertTaskRevision(ctx, params)
+	return err
+}
+
+// revisionChange is a field which differs between two revisions, an empty value is a field which isn't set.
+type revisionChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// revisionNames names the node groups and blackout calendars revisions refer to by their identifier.
+type revisionNames struct {
+	groups    map[int64]string
+	calendars map[int64]string
+}
+
+func (app *application) revisionNames(ctx context.Context) (revisionNames, error) {
+	names := revisionNames{groups: make(map[int64]string), calendars: make(map[int64]string)}
+	groups, err := app.DB.queries.ListNodeGroups(ctx)
+	if err != nil {
+		return revisionNames{}, err
+	}
+	for _, group := range groups {
+		names.groups[group.ID] = group.Name
+	}
+	calendars, err := app.DB.queries.ListBlackoutCalendars(ctx)
+	if err != nil {
+		return revisionNames{}, err
+	}
+	for _, calendar := range calendars {
+		names.calendars[calendar.ID] = calendar.Name
+	}
+	return names, nil
+}
+
+// nameIDs names ids, the ones which were deleted since go by their identifier.
+func nameIDs(ids []int64, names map[int64]string) string {
+	named := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if name, ok := names[id]; ok {
+			named = append(named, name)
+		} else {
+			named = append(named, fmt.Sprintf("#%d", id))
+		}
+	}
+	return strings.Join(named, ", ")
+}
+
+// fields are the fields of the revision which are compared, in the order they're shown. The spider arguments and
+// settings are compared one by one, the project and spider they're scheduled with are fields of their own.
+func (r taskRevision) fields(names revisionNames) ([][2]string, url.Values) {
+	if r.Revision == 0 {
+		return nil, nil
+	}
+	var maxRuntime string
+	if r.MaxRuntimeSeconds.Valid {
+		maxRuntime = (time.Duration(r.MaxRuntimeSeconds.Int64) * time.Second).String()
+	}
+	fields := [][2]string{
+		{"Name", r.Name.String},
+		{"Project", r.Project},
+		{"Spider", r.Spider},
+		{"Cron schedule", r.CronString},
+		{"Time zone", r.Timezone},
+		{"Nodes", strings.Join(r.Nodes, ", ")},
+		{"Node groups", nameIDs(r.Groups, names.groups)},
+		{"Fan-out", r.FanOut},
+		{"Retry max attempts", strconv.FormatInt(r.RetryMaxAttempts, 10)},
+		{"Retry backoff", (time.Duration(r.RetryBackoffSeconds) * time.Second).String()},
+		{"Retry max backoff", (time.Duration(r.RetryMaxBackoffSeconds) * time.Second).String()},
+		{"Retry on", r.RetryOn},
+		{"Overlap policy", r.OverlapPolicy},
+		{"Misfire policy", r.MisfirePolicy},
+		{"Misfire max runs", strconv.FormatInt(r.MisfireMaxRuns, 10)},
+		{"Max runtime", maxRuntime},
+		{"Blackout calendars", nameIDs(r.Calendars, names.calendars)},
+	}
+	args, err := url.ParseQuery(r.SettingsArguments)
+	if err != nil {
+		args = url.Values{"": {r.SettingsArguments}}
+	}
+	args.Del("project")
+	args.Del("spider")
+	return fields, args
+}
+
+// diffTaskRevisions lists the fields which changed from one revision to another. A zero from is the state before the task
+// existed, every field of to which is set is a change.
+func diffTaskRevisions(from, to taskRevision, names revisionNames) []revisionChange {
+	fromFields, fromArgs := from.fields(names)
+	toFields, toArgs := to.fields(names)
+	var changes []revisionChange
+	for i, field := range toFields {
+		var before string
+		if fromFields != nil {
+			before = fromFields[i][1]
+		}
+		if before != field[1] {
+			changes = append(changes, revisionChange{Field: field[0], From: before, To: field[1]})
+		}
+	}
+	keys := slices.Collect(maps.Keys(toArgs))
+	for key := range fromArgs {
+		if !toArgs.Has(key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		before, after := strings.Join(fromArgs[key], ", "), strings.Join(toArgs[key], ", ")
+		if before != after {
+			changes = append(changes, revisionChange{Field: "Argument " + key, From: before, To: after})
+		}
+	}
+	return changes
+}
+
+// taskRevisionEntry is a revision on the revisions page of a task, with what changed since the revision before it.
+type taskRevisionEntry struct {
+	taskRevision
+	Author  sql.NullString
+	Changes []revisionChange
+	Current bool
+}
+
+func (app *application) taskRevisions(ctx context.Context, taskID uuid.UUID, names revisionNames) ([]taskRevisionEntry, error) {
+	rows, err := app.DB.queries.ListTaskRevisions(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]taskRevisionEntry, len(rows))
+	for i, row := range rows {
+		revision, err := newTaskRevision(row.TaskRevision)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = taskRevisionEntry{taskRevision: revision, Author: row.CreatedByUsername, Current: i == 0}
+	}
+	// Newest first, the revision before every revision is the next one in the list
+	for i := range entries {
+		var previous taskRevision
+		if i+1 < len(entries) {
+			previous = entries[i+1].taskRevision
+		}
+		entries[i].Changes = diffTaskRevisions(previous, entries[i].taskRevision, names)
+	}
+	return entries, nil
+}
+
+func findRevision(entries []taskRevisionEntry, revision int64) (taskRevisionEntry, bool) {
+	for _, entry := range entries {
+		if entry.Revision == revision {
+			return entry, true
+		}
+	}
+	return taskRevisionEntry{}, false
+}
+
+// taskRevisionsPage lists every revision of a task, newest first, with what changed in each of them. Two revisions are
+// compared with the from and to query parameters.
+func (app *application) taskRevisionsPage(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskID, err := uuid.Parse(r.PathValue("taskUUID"))
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	taskDb, err := app.DB.queries.GetTaskWithUUID(ctxwt, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.badRequest(w, r, fmt.Errorf("task %s doesn't exist", taskID))
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	names, err := app.revisionNames(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	revisions, err := app.taskRevisions(ctxwt, taskID, names)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	// The compare form picks the latest change by default
+	data["CompareFrom"], data["CompareTo"] = int64(0), int64(0)
+	if len(revisions) > 1 {
+		data["CompareFrom"], data["CompareTo"] = revisions[1].Revision, revisions[0].Revision
+	}
+	data["Compared"] = false
+	query := r.URL.Query()
+	if query.Has("from") || query.Has("to") {
+		var compared [2]taskRevisionEntry
+		for i, param := range []string{"from", "to"} {
+			revision, err := strconv.ParseInt(query.Get(param), 10, 64)
+			found := false
+			if err == nil {
+				compared[i], found = findRevision(revisions, revision)
+			}
+			if !found {
+				app.badRequest(w, r, fmt.Errorf("task %s has no revision %q", taskID, query.Get(param)))
+				return
+			}
+		}
+		data["CompareFrom"] = compared[0].Revision
+		data["CompareTo"] = compared[1].Revision
+		data["Compared"] = true
+		data["Comparison"] = diffTaskRevisions(compared[0].taskRevision, compared[1].taskRevision, names)
+	}
+	data["Task"] = taskDb
+	data["Revisions"] = revisions
+	app.render(w, r, http.StatusOK, taskRevisionsPage, nil, data)
+}
+
+// revertTask restores a revision of a task and registers it with the scheduler again. Whether the task is paused isn't
+// part of its revisions, it stays as it is.
+func (app *application) revertTask(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskID, err := uuid.Parse(r.PathValue("taskUUID"))
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	revisionNumber, err := strconv.ParseInt(r.PathValue("revision"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	taskDb, err := app.DB.queries.GetTaskWithUUID(ctxwt, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.badRequest(w, r, fmt.Errorf("task %s doesn't exist", taskID))
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	revisionDb, err := app.DB.queries.GetTaskRevision(ctxwt, database.GetTaskRevisionParams{TaskID: taskID, Revision: revisionNumber})
+	if errors.Is(err, sql.ErrNoRows) {
+		app.badRequest(w, r, fmt.Errorf("task %s has no revision %d", taskID, revisionNumber))
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	revision, err := newTaskRevision(revisionDb)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	// The nodes, groups and calendars of the revision may have been deleted or the user may not have access to them
+	var v validator.Validator
+	nodes, err := app.DB.queries.ListScrapydNodes(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	for _, node := range revision.Nodes {
+		v.CheckField(slices.ContainsFunc(nodes, func(n database.ScrapydNode) bool { return n.Nodename == node }), "nodes", fmt.Sprintf("Node %s does not exist", node))
+	}
+	targets := taskTargets{Nodes: revision.Nodes, Groups: revision.Groups, FanOut: revision.FanOut}
+	err = validateTaskTargets(ctxwt, app.DB.queries, &v, contextGetAccessScope(r), revision.Project, targets, "nodes", "node_groups")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	calendars := taskCalendars{Timezone: revision.Timezone, Calendars: revision.Calendars}
+	err = validateTaskCalendars(ctxwt, app.DB.queries, &v, calendars, "timezone", "blackout_calendars")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if v.HasErrors() {
+		problems := slices.Sorted(maps.Values(v.FieldErrors))
+		app.badRequest(w, r, fmt.Errorf("can't revert to revision %d: %s", revisionNumber, strings.Join(problems, ", ")))
+		return
+	}
+	queryParams := database.UpdateTaskParams{
+		Name:                   revision.Name,
+		Project:                revision.Project,
+		Spider:                 revision.Spider,
+		Jobid:                  revision.Name.String,
+		SettingsArguments:      revision.SettingsArguments,
+		CronString:             revision.CronString,
+		Paused:                 taskDb.Paused,
+		RetryMaxAttempts:       revision.RetryMaxAttempts,
+		RetryBackoffSeconds:    revision.RetryBackoffSeconds,
+		RetryMaxBackoffSeconds: revision.RetryMaxBackoffSeconds,
+		RetryOn:                revision.RetryOn,
+		OverlapPolicy:          revision.OverlapPolicy,
+		FanOut:                 revision.FanOut,
+		MisfirePolicy:          revision.MisfirePolicy,
+		MisfireMaxRuns:         revision.MisfireMaxRuns,
+		Timezone:               revision.Timezone,
+		MaxRuntimeSeconds:      revision.MaxRuntimeSeconds,
+		ID:                     taskID,
+	}
+	user := contextGetAuthenticatedUser(r)
+	if user != nil {
+		queryParams.ModifiedBy = user.ID
+	}
+	err = app.DB.queries.UpdateTask(ctxwt, queryParams)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.setTaskTargets(ctxwt, taskID, targets.Nodes, targets.Groups)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	err = app.setTaskBlackoutCalendars(ctxwt, taskID, calendars.Calendars)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !taskDb.Paused {
+		updatedTask, err := app.DB.queries.GetTaskWithUUID(ctxwt, taskID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		// Registering a task with an identifier which is already registered replaces it
+		_, err = app.scheduleTask(updatedTask)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	err = app.recordTaskRevision(ctxwt, taskID, user, revisionNumber)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/task/revisions/%s", taskID), http.StatusSeeOther)
+}
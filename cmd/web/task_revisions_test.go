@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/jonboulle/clockwork"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestDiffTaskRevisions(t *testing.T) {
+	names := revisionNames{groups: map[int64]string{1: "eu"}, calendars: map[int64]string{}}
+	from := taskRevision{
+		TaskRevision: database.TaskRevision{
+			Revision:          1,
+			Project:           "shop",
+			Spider:            "products",
+			CronString:        "0 6 * * *",
+			SettingsArguments: "project=shop&spider=products&since=2024-01-01&setting=DOWNLOAD_DELAY%3D1",
+			RetryMaxAttempts:  1,
+			OverlapPolicy:     overlapAllow,
+			FanOut:            fanOutAll,
+		},
+		Nodes: []string{"node_a"},
+	}
+	to := from
+	to.Revision = 2
+	to.CronString = "0 7 * * *"
+	to.SettingsArguments = "project=shop&spider=products&since=2024-02-01&category=books"
+	to.Groups = []int64{1, 2}
+	to.MaxRuntimeSeconds = sql.NullInt64{Int64: 3600, Valid: true}
+	changes := diffTaskRevisions(from, to, names)
+	assert.Equal(t, len(changes), 6)
+	want := []revisionChange{
+		{Field: "Cron schedule", From: "0 6 * * *", To: "0 7 * * *"},
+		{Field: "Node groups", From: "", To: "eu, #2"},
+		{Field: "Max runtime", From: "", To: "1h0m0s"},
+		{Field: "Argument category", From: "", To: "books"},
+		{Field: "Argument setting", From: "DOWNLOAD_DELAY=1", To: ""},
+		{Field: "Argument since", From: "2024-01-01", To: "2024-02-01"},
+	}
+	for i := range want {
+		assert.Equal(t, changes[i], want[i])
+	}
+	assert.Equal(t, len(diffTaskRevisions(from, from, names)), 0)
+	created := diffTaskRevisions(taskRevision{}, from, names)
+	assert.Equal(t, created[0], revisionChange{Field: "Project", From: "", To: "shop"})
+}
+
+func TestTaskRevisions(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ctx := context.Background()
+	scheduler, err := gocron.NewScheduler(gocron.WithClock(clockwork.NewFakeClock()))
+	assert.NilError(t, err)
+	ta.scheduler = scheduler
+	ta.scheduler.Start()
+	for _, node := range []string{"first_node", "second_node"} {
+		_, err := ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: node, Url: "http://does_not_exist.example.com"})
+		assert.NilError(t, err)
+	}
+	taskName := "revised_task"
+	taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+		ID:                uuid.New(),
+		Name:              database.CreateSqlNullString(&taskName),
+		Project:           "shop",
+		Spider:            "products",
+		Jobid:             taskName,
+		SettingsArguments: "project=shop&spider=products&since=2024-01-01",
+		CronString:        "0 6 * * *",
+		RetryMaxAttempts:  1,
+		OverlapPolicy:     overlapAllow,
+		FanOut:            fanOutAll,
+		MisfirePolicy:     misfireIgnore,
+		MisfireMaxRuns:    1,
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{"first_node"}, nil))
+	assert.NilError(t, ta.recordTaskRevision(ctx, taskDb.ID, nil, 0))
+	_, err = ta.scheduleTask(taskDb)
+	assert.NilError(t, err)
+	revisionsPage := "/task/revisions/" + taskDb.ID.String()
+
+	t.Run("Edit appends a revision", func(t *testing.T) {
+		_, _, body := ts.get(t, "/task/edit/"+taskDb.ID.String())
+		form := url.Values{
+			"csrf_token":       {extractCSRFToken(t, body)},
+			"project":          {"shop"},
+			"spider":           {"products"},
+			"task_name":        {taskName},
+			"cron_input":       {"30 7 * * *"},
+			"fireNode":         {"first_node", "second_node"},
+			"fan_out":          {fanOutAll},
+			"overlap_policy":   {overlapAllow},
+			"misfire_policy":   {misfireIgnore},
+			"misfire_max_runs": {"1"},
+			"since":            {"2024-02-01"},
+		}
+		code, _, _ := ts.postForm(t, "/task/edit/"+taskDb.ID.String(), form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		revisions, err := ta.DB.queries.ListTaskRevisions(ctx, taskDb.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(revisions), 2)
+		latest := revisions[0].TaskRevision
+		assert.Equal(t, latest.Revision, int64(2))
+		assert.Equal(t, latest.CronString, "30 7 * * *")
+		assert.Equal(t, latest.Nodes, `["first_node","second_node"]`)
+		assert.Equal(t, revisions[0].CreatedByUsername.String, "admin")
+	})
+	t.Run("Revisions page", func(t *testing.T) {
+		code, _, body := ts.get(t, revisionsPage)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Revisions of revised_task")
+		assert.StringContains(t, body, "Cron schedule")
+		assert.StringContains(t, body, "30 7 * * *")
+		assert.StringContains(t, body, "Argument since")
+		assert.StringContains(t, body, "first_node, second_node")
+		assert.StringContains(t, body, "/task/revisions/"+taskDb.ID.String()+"/revert/1")
+		code, _, body = ts.get(t, revisionsPage+"?from=1&to=2")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Revision 1 compared with revision 2")
+		code, _, _ = ts.get(t, revisionsPage+"?from=1&to=9")
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+	t.Run("Revert", func(t *testing.T) {
+		_, _, body := ts.get(t, revisionsPage)
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}}
+		code, _, _ := ts.postForm(t, revisionsPage+"/revert/1", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		reverted, err := ta.DB.queries.GetTaskWithUUID(ctx, taskDb.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, reverted.CronString, "0 6 * * *")
+		assert.StringContains(t, reverted.SettingsArguments, "since=2024-01-01")
+		assert.Equal(t, reverted.Paused, false)
+		nodes, err := ta.DB.queries.ListTaskTargetNodes(ctx, taskDb.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(nodes), 1)
+		assert.Equal(t, nodes[0], "first_node")
+		revisions, err := ta.DB.queries.ListTaskRevisions(ctx, taskDb.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(revisions), 3)
+		assert.Equal(t, revisions[0].TaskRevision.RevertedFrom.Int64, int64(1))
+		assert.Equal(t, revisions[0].TaskRevision.CronString, "0 6 * * *")
+		assert.Equal(t, len(ta.scheduler.Jobs()), 1)
+		running, job := ta.isTaskRunning(taskDb.ID)
+		assert.Equal(t, running, true)
+		nextRun, err := job.NextRun()
+		assert.NilError(t, err)
+		assert.Equal(t, nextRun.Hour(), 6)
+	})
+	t.Run("Revert to a deleted node", func(t *testing.T) {
+		_, err := ta.DB.queries.InsertTaskRevision(ctx, database.InsertTaskRevisionParams{
+			TaskID: taskDb.ID, Project: "shop", Spider: "products", SettingsArguments: "project=shop&spider=products",
+			CronString: "0 6 * * *", RetryMaxAttempts: 1, OverlapPolicy: overlapAllow, FanOut: fanOutAll,
+			MisfirePolicy: misfireIgnore, MisfireMaxRuns: 1, Nodes: `["gone_node"]`, NodeGroups: "[]", BlackoutCalendars: "[]",
+		})
+		assert.NilError(t, err)
+		_, _, body := ts.get(t, revisionsPage)
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}}
+		code, _, _ := ts.postForm(t, revisionsPage+"/revert/4", form)
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trigger sources are recorded on task_runs, what made the scheduler execute a task. Runs started by the dependencies of a
+// task or to catch up on a missed fire record dependenciesTriggeredBy and misfireTriggeredBy.
+const (
+	triggerCron    = "cron"
+	triggerManual  = "manual"
+	triggerBulk    = "bulk"
+	triggerWebhook = webhookTriggeredBy
+)
+
+// Outcomes of a task run, stored in task_runs.outcome.
+const (
+	// runPending is a run which hasn't reached Scrapyd yet, it's queued by the overlap policy or retrying
+	runPending   = "pending"
+	runScheduled = "scheduled"
+	// runSkipped is a run the overlap policy, a blackout calendar or maintenance mode didn't let through
+	runSkipped  = "skipped"
+	runFailed   = "failed"
+	runPanicked = "panicked"
+)
+
+// taskRunsKept is how many of its most recent runs are kept for every task.
+const taskRunsKept = 1000
+
+// taskRunsPerPage is how many runs the history page of a task shows at a time.
+const taskRunsPerPage = 50
+
+// runTrigger is what started a fire and, for fires on the schedule or catching up on one, when it was due.
+type runTrigger struct {
+	Source      string
+	ScheduledAt time.Time
+	// OverrideMaintenance lets the fire through maintenance mode, the entry points firing by hand set it once
+	// maintenanceRefusal passed
+	OverrideMaintenance bool
+}
+
+// manualRuns are the triggers of registered tasks fired through runTaskNow, scheduledFire takes them to tell such runs
+// apart from the ones on the schedule. The zero value is ready to use.
+type manualRuns struct {
+	mu       sync.Mutex
+	triggers map[uuid.UUID][]runTrigger
+}
+
+func (m *manualRuns) add(taskID uuid.UUID, trigger runTrigger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.triggers == nil {
+		m.triggers = make(map[uuid.UUID][]runTrigger)
+	}
+	m.triggers[taskID] = append(m.triggers[taskID], trigger)
+}
+
+// take pops the oldest trigger of the task, ok is false when the task wasn't fired by hand.
+func (m *manualRuns) take(taskID uuid.UUID) (trigger runTrigger, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	triggers := m.triggers[taskID]
+	if len(triggers) == 0 {
+		return runTrigger{}, false
+	}
+	if len(triggers) == 1 {
+		delete(m.triggers, taskID)
+	} else {
+		m.triggers[taskID] = triggers[1:]
+	}
+	return triggers[0], true
+}
+
+// scheduledTime is the fire of schedule which is due at now. gocron fires on the minute, a fire which started more than a
+// minute late doesn't know when it was due and gets a zero time.
+func scheduledTime(schedule string, now time.Time) time.Time {
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return time.Time{}
+	}
+	due := parsed.Next(now.Truncate(time.Minute).Add(-time.Second))
+	if due.After(now) {
+		return time.Time{}
+	}
+	return due
+}
+
+// fireNowTrigger is the trigger of a fire through fireNow, from what TriggeredBy records on its jobs.
+func (t *task) fireNowTrigger() runTrigger {
+	trigger := runTrigger{Source: t.TriggeredBy, ScheduledAt: t.missedFire, OverrideMaintenance: t.overrideMaintenance}
+	switch {
+	case t.TriggeredBy == "":
+		trigger.Source = triggerManual
+	case strings.HasPrefix(t.TriggeredBy, webhookTriggeredBy):
+		trigger.Source = triggerWebhook
+	}
+	return trigger
+}
+
+// recordsRuns reports whether the fires of the task are recorded in task_runs, one-time jobs aren't tasks.
+func (t *task) recordsRuns() bool {
+	return !t.OneTimeJob
+}
+
+// startRuns records a pending task run for every run of a fire, each run keeps the identifier of its row in runID.
+func (t *task) startRuns(ctx context.Context, trigger runTrigger, runs []*task) {
+	if !t.recordsRuns() {
+		return
+	}
+	for _, run := range runs {
+		id, err := t.insertTaskRun(ctx, trigger, run.NodeName, run.JobID, runPending, nil)
+		if err != nil {
+			t.Logger.ErrorContext(ctx, "error recording task run", slog.Any("task", t.ID), slog.Any("jobID", run.JobID), slog.Any("err", err))
+			continue
+		}
+		run.runID = id
+	}
+	t.pruneTaskRuns(ctx)
+}
+
+// recordFailedFire records a fire which failed before it had any runs, e.g. because none of its targets resolved to a
+// node. Such a fire never got a job.
+func (t *task) recordFailedFire(trigger runTrigger, err error) {
+	if !t.recordsRuns() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, dbErr := t.insertTaskRun(ctx, trigger, "", "", runOutcome(err), err)
+	if dbErr != nil {
+		t.Logger.ErrorContext(ctx, "error recording task run", slog.Any("task", t.ID), slog.Any("err", dbErr))
+		return
+	}
+	t.pruneTaskRuns(ctx)
+}
+
+func (t *task) insertTaskRun(ctx context.Context, trigger runTrigger, node, jobID, outcome string, err error) (int64, error) {
+	params := database.InsertTaskRunParams{
+		TaskID:        t.ID,
+		TriggerSource: trigger.Source,
+		ScheduledAt:   sql.NullTime{Time: trigger.ScheduledAt, Valid: !trigger.ScheduledAt.IsZero()},
+		StartedAt:     time.Now(),
+		Node:          node,
+		JobID:         jobID,
+		Outcome:       outcome,
+	}
+	if err != nil {
+		errAsString := err.Error()
+		params.Error = database.CreateSqlNullString(&errAsString)
+		params.FinishedAt = database.CreateCreateSqlNullTimeNonPtr(params.StartedAt)
+	}
+	return t.DB.InsertTaskRun(ctx, params)
+}
+
+func (t *task) pruneTaskRuns(ctx context.Context) {
+	err := t.DB.PruneTaskRuns(ctx, database.PruneTaskRunsParams{TaskID: t.ID, Keep: taskRunsKept})
+	if err != nil {
+		t.Logger.ErrorContext(ctx, "error pruning task runs", slog.Any("task", t.ID), slog.Any("err", err))
+	}
+}
+
+// finishRun records the outcome of the run, a nil err is a run which reached Scrapyd.
+func (t *task) finishRun(err error) {
+	if t.runID == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	params := database.FinishTaskRunParams{
+		Outcome:    runOutcome(err),
+		FinishedAt: database.CreateCreateSqlNullTimeNonPtr(time.Now()),
+		ID:         t.runID,
+	}
+	if err != nil {
+		errAsString := err.Error()
+		params.Error = database.CreateSqlNullString(&errAsString)
+	}
+	if t.scheduleLatency > 0 {
+		params.ScheduleLatencyMs = sql.NullInt64{Int64: t.scheduleLatency.Milliseconds(), Valid: true}
+	}
+	if dbErr := t.DB.FinishTaskRun(ctx, params); dbErr != nil {
+		t.Logger.ErrorContext(ctx, "error recording the outcome of a task run", slog.Any("task", t.ID), slog.Any("jobID", t.JobID), slog.Any("err", dbErr))
+	}
+}
+
+func finishRuns(runs []*task, err error) {
+	for _, run := range runs {
+		run.finishRun(err)
+	}
+}
+
+// firePanicError is a fire which panicked, gocron recovers it and calls afterTaskPanics.
+type firePanicError struct {
+	Recovered any
+}
+
+func (e *firePanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Recovered)
+}
+
+func runOutcome(err error) string {
+	var panicked *firePanicError
+	switch {
+	case err == nil:
+		return runScheduled
+	case errors.As(err, &panicked):
+		return runPanicked
+	case isSkippedFire(err):
+		return runSkipped
+	}
+	return runFailed
+}
+
+// recordPanic records a fire which panicked on its runs, or on its own when it panicked before it had any.
+func (t *task) recordPanic(trigger runTrigger, runs []*task, recovered any) {
+	err := &firePanicError{Recovered: recovered}
+	if len(runs) == 0 {
+		t.recordFailedFire(trigger, err)
+		return
+	}
+	finishRuns(runs, err)
+}
+
+// taskRunsHistory lists every execution of a task by the scheduler, newest first.
+func (app *application) taskRunsHistory(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskID, err := uuid.Parse(r.PathValue("taskUUID"))
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	page := 1
+	if value := r.URL.Query().Get("page"); value != "" {
+		page, err = strconv.Atoi(value)
+		if err != nil || page < 1 {
+			app.badRequest(w, r, fmt.Errorf("invalid page %q", value))
+			return
+		}
+	}
+	taskDb, err := app.DB.queries.GetTaskWithUUID(ctxwt, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		app.badRequest(w, r, fmt.Errorf("task %s doesn't exist", taskID))
+		return
+	} else if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	runs, err := app.DB.queries.ListTaskRuns(ctxwt, database.ListTaskRunsParams{
+		TaskID: taskID,
+		Limit:  taskRunsPerPage,
+		Offset: int64((page - 1) * taskRunsPerPage),
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	total, err := app.DB.queries.CountTaskRuns(ctxwt, taskID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data["Task"] = taskDb
+	data["Runs"] = runs
+	data["Page"] = page
+	data["HasNext"] = int64(page*taskRunsPerPage) < total
+	data["Total"] = total
+	app.render(w, r, http.StatusOK, taskRunsPage, nil, data)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScheduledTime(t *testing.T) {
+	due := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		schedule string
+		now      time.Time
+		want     time.Time
+	}{
+		{"On time", "0 6 * * *", due.Add(150 * time.Millisecond), due},
+		{"Late within the minute", "0 6 * * *", due.Add(59 * time.Second), due},
+		{"More than a minute late", "0 6 * * *", due.Add(61 * time.Second), time.Time{}},
+		{"Time zone", "CRON_TZ=Europe/Berlin 0 7 * * *", due.Add(time.Second), due},
+		{"Invalid", "not a schedule", due, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, scheduledTime(tt.schedule, tt.now).Equal(tt.want), true)
+		})
+	}
+}
+
+func TestTaskRuns(t *testing.T) {
+	ta := newTestApplication(t)
+	ctx := context.Background()
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			_, err := w.Write([]byte(`{"node_name": "runs_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err := ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: "runs_node", Url: mockScrapyd.URL})
+	assert.NilError(t, err)
+	taskName := "runs_task"
+	taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+		ID:                uuid.New(),
+		Name:              database.CreateSqlNullString(&taskName),
+		Project:           "shop",
+		Spider:            "products",
+		Jobid:             taskName,
+		SettingsArguments: "project=shop&spider=products",
+		CronString:        "* * * * *",
+		RetryMaxAttempts:  1,
+		OverlapPolicy:     overlapAllow,
+		FanOut:            fanOutAll,
+	})
+	assert.NilError(t, err)
+	createdTask, err := ta.taskFromDb(taskDb)
+	assert.NilError(t, err)
+	createdTask.setSchedule(taskDb.CronString)
+	lastRun := func(t *testing.T) database.TaskRun {
+		runs, err := ta.DB.queries.ListTaskRuns(ctx, database.ListTaskRunsParams{TaskID: taskDb.ID, Limit: 1})
+		assert.NilError(t, err)
+		assert.Equal(t, len(runs), 1)
+		return runs[0]
+	}
+
+	t.Run("No nodes", func(t *testing.T) {
+		assert.Equal(t, createdTask.scheduledFire(ctx) != nil, true)
+		run := lastRun(t)
+		assert.Equal(t, run.TriggerSource, triggerCron)
+		assert.Equal(t, run.Outcome, runFailed)
+		assert.Equal(t, run.Node, "")
+		assert.Equal(t, run.JobID, "")
+		assert.StringContains(t, run.Error.String, "no nodes to run on")
+		assert.Equal(t, run.FinishedAt.Valid, true)
+	})
+	assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{"runs_node"}, nil))
+	t.Run("Cron", func(t *testing.T) {
+		before := time.Now()
+		assert.NilError(t, createdTask.scheduledFire(ctx))
+		run := lastRun(t)
+		assert.Equal(t, run.TriggerSource, triggerCron)
+		assert.Equal(t, run.Outcome, runScheduled)
+		assert.Equal(t, run.Node, "runs_node")
+		assert.StringContains(t, run.JobID, "task_products_runs_node_")
+		assert.Equal(t, run.ScheduledAt.Valid, true)
+		assert.Equal(t, run.ScheduledAt.Time.Before(before.Truncate(time.Minute)), false)
+		assert.Equal(t, run.ScheduleLatencyMs.Valid, true)
+	})
+	t.Run("Manual", func(t *testing.T) {
+		ta.manualRuns.add(taskDb.ID, runTrigger{Source: triggerBulk, OverrideMaintenance: true})
+		assert.NilError(t, createdTask.scheduledFire(ctx))
+		run := lastRun(t)
+		assert.Equal(t, run.TriggerSource, triggerBulk)
+		assert.Equal(t, run.ScheduledAt.Valid, false)
+	})
+	t.Run("Webhook", func(t *testing.T) {
+		fire := *createdTask
+		fire.TriggeredBy = webhookTriggeredBy + ":ci"
+		_, err := fire.fireNow("")
+		assert.NilError(t, err)
+		run := lastRun(t)
+		assert.Equal(t, run.TriggerSource, triggerWebhook)
+		assert.Equal(t, run.Outcome, runScheduled)
+	})
+	t.Run("Skipped", func(t *testing.T) {
+		_, err := ta.DB.queries.InsertJob(ctx, database.InsertJobParams{
+			Project: "shop", Spider: "products", Job: "queued_job", Status: "queued",
+			CreateTime: time.Now(), Node: "runs_node", TaskID: taskDb.ID,
+		})
+		assert.NilError(t, err)
+		fire := *createdTask
+		fire.Overlap = overlapSkip
+		assert.NilError(t, fire.fireFunc(ctx))
+		run := lastRun(t)
+		assert.Equal(t, run.TriggerSource, triggerManual)
+		assert.Equal(t, run.Outcome, runSkipped)
+	})
+	t.Run("Panic", func(t *testing.T) {
+		createdTask.recordPanic(runTrigger{Source: triggerCron}, nil, "boom")
+		run := lastRun(t)
+		assert.Equal(t, run.Outcome, runPanicked)
+		assert.Equal(t, run.Error.String, "panic: boom")
+	})
+	t.Run("History page", func(t *testing.T) {
+		ts := newTestServer(t, ta.routes())
+		defer ts.Close()
+		ts.login(t)
+		code, _, body := ts.get(t, "/task/runs/"+taskDb.ID.String())
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "History of runs_task")
+		assert.StringContains(t, body, "no nodes to run on")
+		assert.StringContains(t, body, "webhook")
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// Fan-out modes decide which of the nodes a task targets get a job on every fire, stored in tasks.fan_out.
+const (
+	// fanOutAll fires on every node
+	fanOutAll = "all"
+	// fanOutRandom fires on one node picked at random
+	fanOutRandom = "random"
+	// fanOutRoundRobin fires on one node, taking turns in node name order
+	fanOutRoundRobin = "round_robin"
+	// fanOutLeastLoaded fires on the online node with the fewest running and pending jobs for its capacity
+	fanOutLeastLoaded = "least_loaded"
+)
+
+var fanOutModes = []string{fanOutAll, fanOutRandom, fanOutRoundRobin, fanOutLeastLoaded}
+
+func validateFanOut(v *validator.Validator, field, fanOut string) {
+	v.CheckField(slices.Contains(fanOutModes, fanOut), field, fmt.Sprintf("Fan-out must be one of %s", strings.Join(fanOutModes, ", ")))
+}
+
+// taskTargets are the nodes and node groups a task runs on. Groups are resolved to their members on every fire, so a
+// node added to a group is picked up without editing its tasks.
+type taskTargets struct {
+	Nodes  []string
+	Groups []int64
+	FanOut string
+}
+
+func (tt taskTargets) HasNode(node string) bool {
+	return slices.Contains(tt.Nodes, node)
+}
+
+func (tt taskTargets) HasGroup(groupID int64) bool {
+	return slices.Contains(tt.Groups, groupID)
+}
+
+func (app *application) taskTargets(ctx context.Context, taskDb database.Task) (taskTargets, error) {
+	rows, err := app.DB.queries.ListTargetsForTask(ctx, taskDb.ID)
+	if err != nil {
+		return taskTargets{}, err
+	}
+	targets := taskTargets{FanOut: taskDb.FanOut}
+	for _, row := range rows {
+		if row.Node.Valid {
+			targets.Nodes = append(targets.Nodes, row.Node.String)
+		} else if row.GroupID.Valid {
+			targets.Groups = append(targets.Groups, row.GroupID.Int64)
+		}
+	}
+	return targets, nil
+}
+
+// setTaskTargets replaces the targets of the task.
+func (app *application) setTaskTargets(ctx context.Context, taskID uuid.UUID, nodes []string, groups []int64) error {
+	err := app.DB.queries.DeleteTaskTargets(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		err = app.DB.queries.InsertTaskTarget(ctx, database.InsertTaskTargetParams{
+			TaskID: taskID,
+			Node:   sql.NullString{String: node, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, group := range groups {
+		err = app.DB.queries.InsertTaskTarget(ctx, database.InsertTaskTargetParams{
+			TaskID:  taskID,
+			GroupID: sql.NullInt64{Int64: group, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTaskTargets checks that the task targets at least one node or group, that the groups exist and that the user
+// may work with project on every node the targets resolve to. Errors about nodes and groups go to nodesField and
+// groupsField, the form and the API name them differently.
+func validateTaskTargets(ctx context.Context, queries *database.Queries, v *validator.Validator, scope accessScope, project string, targets taskTargets, nodesField, groupsField string) error {
+	v.CheckField(len(targets.Nodes) > 0 || len(targets.Groups) > 0, nodesField, "You must select at least one node or node group")
+	validateFanOut(v, "fan_out", targets.FanOut)
+	for _, node := range targets.Nodes {
+		v.CheckField(scope.Allows(project, node), nodesField, fmt.Sprintf("You don't have access to project %s on node %s", project, node))
+	}
+	for _, groupID := range targets.Groups {
+		group, err := queries.GetNodeGroup(ctx, groupID)
+		if errors.Is(err, sql.ErrNoRows) {
+			v.AddFieldError(groupsField, fmt.Sprintf("Node group %d does not exist", groupID))
+			continue
+		} else if err != nil {
+			return err
+		}
+		members, err := queries.ListNodesInGroup(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		for _, node := range members {
+			v.CheckField(scope.Allows(project, node), groupsField, fmt.Sprintf("You don't have access to project %s on node %s of group %s", project, node, group.Name))
+		}
+	}
+	return nil
+}
+
+// allowsTask reports whether the user may work with a task of project running on nodes. A task without any node is only
+// checked against its project.
+func (s accessScope) allowsTask(project string, nodes []string) bool {
+	if len(nodes) == 0 {
+		return s.AllowsProject(project)
+	}
+	for _, node := range nodes {
+		if !s.Allows(project, node) {
+			return false
+		}
+	}
+	return true
+}
+
+func (app *application) taskInScope(ctx context.Context, scope accessScope, taskDb database.Task) (bool, error) {
+	if scope.unrestricted() {
+		return true, nil
+	}
+	nodes, err := app.DB.queries.ListTaskTargetNodes(ctx, taskDb.ID)
+	if err != nil {
+		return false, err
+	}
+	return scope.allowsTask(taskDb.Project, nodes), nil
+}
+
+// taskNodes maps every task to the nodes its targets resolve to, for filtering task lists.
+func (app *application) taskNodes(ctx context.Context) (map[uuid.UUID][]string, error) {
+	rows, err := app.DB.queries.ListResolvedTaskNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[uuid.UUID][]string)
+	for _, row := range rows {
+		nodes[row.TaskID] = append(nodes[row.TaskID], row.Node)
+	}
+	return nodes, nil
+}
+
+// fireNodes picks the nodes a fire of the task runs on according to its fan-out mode.
+func (t *task) fireNodes(ctx context.Context) ([]string, error) {
+	nodes, err := t.DB.ListTaskTargetNodes(ctx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("the task has no nodes to run on")
+	}
+	switch t.FanOut {
+	case fanOutRandom:
+		return []string{nodes[rand.IntN(len(nodes))]}, nil
+	case fanOutRoundRobin:
+		next, err := t.DB.AdvanceTaskRoundRobin(ctx, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		return []string{nodes[int((next-1)%int64(len(nodes)))]}, nil
+	case fanOutLeastLoaded:
+		allNodes, err := t.DB.ListScrapydNodes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		candidates := slices.DeleteFunc(allNodes, func(node database.ScrapydNode) bool {
+			return !slices.Contains(nodes, node.Nodename)
+		})
+		node, err := t.leastLoaded(ctx, nil, candidates)
+		if err != nil {
+			return nil, err
+		}
+		return []string{node}, nil
+	}
+	return nodes, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTaskFanOut(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ctx := context.Background()
+	nodes := []string{"node_a", "node_b", "node_c"}
+	scheduled := map[string]*atomic.Int32{}
+	// running is the number of running jobs daemonstatus.json reports for a node, -1 takes the node offline
+	running := map[string]*atomic.Int32{}
+	nodeURLs := map[string]string{}
+	for _, node := range nodes {
+		counter, load := &atomic.Int32{}, &atomic.Int32{}
+		scheduled[node], running[node] = counter, load
+		mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/schedule.json":
+				counter.Add(1)
+				_, err := w.Write([]byte(`{"node_name": "` + node + `", "status": "ok"}`))
+				assert.NilError(t, err)
+			case "/daemonstatus.json":
+				if load.Load() < 0 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				_, err := w.Write([]byte(`{"node_name": "` + node + `", "status": "ok", "pending": 0, "running": ` + strconv.Itoa(int(load.Load())) + `, "finished": 0}`))
+				assert.NilError(t, err)
+			}
+		}))
+		defer mockScrapyd.Close()
+		nodeURLs[node] = mockScrapyd.URL
+		_, err := ta.DB.queries.NewScrapydNode(ctx, database.NewScrapydNodeParams{Nodename: node, Url: mockScrapyd.URL})
+		assert.NilError(t, err)
+	}
+	counts := func() string {
+		var got []string
+		for _, node := range nodes {
+			got = append(got, node+"="+strconv.Itoa(int(scheduled[node].Swap(0))))
+		}
+		return strings.Join(got, ",")
+	}
+	var group database.NodeGroup
+	t.Run("Create group", func(t *testing.T) {
+		code, _, body := ts.get(t, "/node-groups")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "No node groups yet")
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "name": {"eu"}, "nodes": {"node_b", "node_c"}}
+		code, _, _ = ts.postForm(t, "/node-groups", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		code, _, body = ts.postForm(t, "/node-groups", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "A group with this name already exists")
+		var err error
+		group, err = ta.DB.queries.GetNodeGroupByName(ctx, "eu")
+		assert.NilError(t, err)
+		members, err := ta.DB.queries.ListNodesInGroup(ctx, group.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, strings.Join(members, ","), "node_b,node_c")
+	})
+	newFire := func(t *testing.T, taskName, fanOut string) *task {
+		taskDb, err := ta.DB.queries.InsertTask(ctx, database.InsertTaskParams{
+			ID:                uuid.New(),
+			Name:              database.CreateSqlNullString(&taskName),
+			Project:           "shop",
+			Spider:            "products",
+			Jobid:             taskName,
+			SettingsArguments: "project=shop&spider=products",
+			CronString:        "0 0 * * *",
+			Paused:            true,
+			RetryMaxAttempts:  1,
+			OverlapPolicy:     overlapAllow,
+			FanOut:            fanOut,
+		})
+		assert.NilError(t, err)
+		assert.NilError(t, ta.setTaskTargets(ctx, taskDb.ID, []string{"node_a"}, []int64{group.ID}))
+		createdTask, err := ta.newTask(false, &taskDb.ID, taskName, "products", "shop", "", url.Values{}, nil)
+		assert.NilError(t, err)
+		createdTask.FanOut = fanOut
+		createdTask.Overlap = overlapAllow
+		return createdTask
+	}
+
+	t.Run("All", func(t *testing.T) {
+		fire := newFire(t, "all", fanOutAll)
+		assert.NilError(t, fire.fireFunc(ctx))
+		assert.Equal(t, counts(), "node_a=1,node_b=1,node_c=1")
+	})
+	t.Run("Random", func(t *testing.T) {
+		fire := newFire(t, "random", fanOutRandom)
+		for range 3 {
+			assert.NilError(t, fire.fireFunc(ctx))
+		}
+		total := 0
+		for _, node := range nodes {
+			total += int(scheduled[node].Swap(0))
+		}
+		assert.Equal(t, total, 3)
+	})
+	t.Run("Round-robin", func(t *testing.T) {
+		fire := newFire(t, "round_robin", fanOutRoundRobin)
+		for _, want := range []string{"node_a=1,node_b=0,node_c=0", "node_a=0,node_b=1,node_c=0", "node_a=0,node_b=0,node_c=1", "node_a=1,node_b=0,node_c=0"} {
+			assert.NilError(t, fire.fireFunc(ctx))
+			assert.Equal(t, counts(), want)
+		}
+	})
+	t.Run("Least-loaded", func(t *testing.T) {
+		fire := newFire(t, "least_loaded", fanOutLeastLoaded)
+		running["node_a"].Store(2)
+		running["node_b"].Store(1)
+		running["node_c"].Store(3)
+		assert.NilError(t, fire.fireFunc(ctx))
+		assert.Equal(t, counts(), "node_a=0,node_b=1,node_c=0")
+		code, _, body := ts.get(t, "/node/edit/node_c")
+		assert.Equal(t, code, http.StatusOK)
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "nodeName": {"node_c"}, "url": {nodeURLs["node_c"]}, "capacity": {"0"}}
+		code, _, body = ts.postForm(t, "/node/edit/node_c", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Capacity must be at least 1 job")
+		form.Set("capacity", "4")
+		code, _, _ = ts.postForm(t, "/node/edit/node_c", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.NilError(t, fire.fireFunc(ctx))
+		assert.Equal(t, counts(), "node_a=0,node_b=0,node_c=1")
+		running["node_c"].Store(-1)
+		assert.NilError(t, fire.fireFunc(ctx))
+		assert.Equal(t, counts(), "node_a=0,node_b=1,node_c=0")
+		running["node_a"].Store(-1)
+		running["node_b"].Store(-1)
+		assert.Equal(t, errors.Is(fire.fireFunc(ctx), errNoOnlineNodes), true)
+		assert.Equal(t, counts(), "node_a=0,node_b=0,node_c=0")
+	})
+	t.Run("Group members are resolved on every fire", func(t *testing.T) {
+		code, _, body := ts.get(t, "/node-groups")
+		assert.Equal(t, code, http.StatusOK)
+		form := url.Values{"csrf_token": {extractCSRFToken(t, body)}, "nodes": {"node_b"}}
+		code, _, _ = ts.postForm(t, "/node-groups/"+strconv.FormatInt(group.ID, 10), form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		fire := newFire(t, "after_edit", fanOutAll)
+		assert.NilError(t, fire.fireFunc(ctx))
+		assert.Equal(t, counts(), "node_a=1,node_b=1,node_c=0")
+	})
+}
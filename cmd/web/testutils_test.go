@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"github.com/blazskufca/goscrapyd/assets"
 	"github.com/blazskufca/goscrapyd/internal/assert"
 	"github.com/blazskufca/goscrapyd/internal/database"
@@ -19,6 +20,7 @@ import (
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"regexp"
 	"sync"
 	"testing"
@@ -44,7 +46,8 @@ func newTestApplication(t *testing.T) *application {
 			autoMigrate       bool
 			createDefaultUser bool
 		}{
-			dsn:               ":memory:",
+			// Every connection to :memory: would be a database of its own, a file is shared by the whole pool
+			dsn:               "file:" + filepath.Join(t.TempDir(), "goscrapyd.db") + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_synchronous=NORMAL",
 			maxOpenConns:      25,
 			maxIdleConns:      25,
 			maxIdleTime:       30 * time.Minute,
@@ -131,10 +134,10 @@ func newTestApplication(t *testing.T) *application {
 				return nil, nil, err
 			}
 			_, err = preparedDb.CreateNewUser(context.Background(), database.CreateNewUserParams{
-				ID:                 userUUID,
-				Username:           username,
-				HashedPassword:     passwordHash,
-				HasAdminPrivileges: true,
+				ID:             userUUID,
+				Username:       username,
+				HashedPassword: passwordHash,
+				Role:           roleAdmin,
 			})
 			if err != nil {
 				_ = db.Close()
@@ -196,6 +199,7 @@ func newTestServer(t *testing.T, h http.Handler) *testServer {
 	}
 
 	ts.Client().Jar = jar
+	ts.Client().Transport = &originTransport{origin: ts.URL, next: ts.Client().Transport}
 	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		return http.ErrUseLastResponse
 	}
@@ -203,12 +207,28 @@ func newTestServer(t *testing.T, h http.Handler) *testServer {
 	return &testServer{ts}
 }
 
+// originTransport sets the Origin header a browser would send, nosurf rejects same-origin requests without it.
+type originTransport struct {
+	origin string
+	next   http.RoundTripper
+}
+
+func (ot *originTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Origin", ot.origin)
+	return ot.next.RoundTrip(r)
+}
+
 func (ts *testServer) login(t *testing.T) {
+	ts.loginAs(t, "admin", "admin")
+}
+
+func (ts *testServer) loginAs(t *testing.T, username, password string) {
 	_, _, body := ts.get(t, "/login")
 	token := extractCSRFToken(t, body)
 	form := url.Values{}
-	form.Add("username", "admin")
-	form.Add("password", "admin")
+	form.Add("username", username)
+	form.Add("password", password)
 	form.Add("csrf_token", token)
 	responseStatus, headers, _ := ts.postForm(t, "/login", form)
 	if responseStatus != http.StatusSeeOther {
@@ -309,3 +329,34 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	body = bytes.TrimSpace(body)
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+func (ts *testServer) doJSON(t *testing.T, method, urlPath string, body any) (int, http.Header, []byte) {
+	var reqBody io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(js)
+	}
+	req, err := http.NewRequest(method, ts.URL+urlPath, reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	respBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, respBody
+}
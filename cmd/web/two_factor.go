@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/request"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"html/template"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpIssuer        = "goscrapyd"
+	recoveryCodeCount = 10
+	// twoFactorLoginTimeout is how long the code can be entered after the password was accepted.
+	twoFactorLoginTimeout = 5 * time.Minute
+	// totpPeriod is the time step of the TOTP codes, totp.Generate's default
+	totpPeriod = 30 * time.Second
+	// twoFactorMaxFailures incorrect codes in a row lock the user's second factor for twoFactorLockout. The count is
+	// kept with the user, so entering the password again doesn't start it over.
+	twoFactorMaxFailures = 5
+	twoFactorLockout     = 15 * time.Minute
+)
+
+// errTwoFactorLockedOut is returned for a user whose second factor is locked after too many incorrect codes.
+var errTwoFactorLockedOut = errors.New("too many incorrect codes, try again later")
+
+type twoFactorForm struct {
+	Action    string              `form:"action"`
+	Code      string              `form:"code"`
+	Validator validator.Validator `form:"-"`
+}
+
+// twoFactorEnrollmentPending reports whether an admin requires 2FA for the user and they haven't set it up yet.
+func twoFactorEnrollmentPending(user *database.User) bool {
+	return user != nil && user.TotpRequired && !user.TotpEnabled
+}
+
+func hashRecoveryCode(code string) []byte {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(normalized))
+	return hash[:]
+}
+
+// createRecoveryCodes replaces the user's recovery codes. Like API tokens the plaintext is returned once and only the
+// SHA-256 hash is stored.
+func (app *application) createRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	err := app.DB.queries.DeleteRecoveryCodesForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		randomBytes := make([]byte, 5)
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, err
+		}
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(randomBytes))
+		code := encoded[:4] + "-" + encoded[4:]
+		err = app.DB.queries.InsertRecoveryCode(ctx, database.InsertRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// totpKey rebuilds the key of the user's encrypted secret, the secret is only ever decrypted in memory.
+func (app *application) totpKey(user *database.User) (*otp.Key, error) {
+	if user.TotpSecret == nil {
+		return nil, errors.New("user has no TOTP secret")
+	}
+	secret, err := decrypt(user.TotpSecret, app.config.ScrapydEncryptSecret)
+	if err != nil {
+		return nil, err
+	}
+	keyURL := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + totpIssuer + ":" + user.Username,
+		RawQuery: url.Values{"secret": {secret}, "issuer": {totpIssuer}}.Encode(),
+	}
+	return otp.NewKeyFromURL(keyURL.String())
+}
+
+// totpStep returns the time step of a TOTP code which is valid at now, one step of clock skew either way is allowed like
+// totp.Validate does.
+func totpStep(code, secret string, now time.Time) (int64, bool) {
+	opts := totp.ValidateOpts{Period: uint(totpPeriod / time.Second), Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+	for _, skew := range []time.Duration{-totpPeriod, 0, totpPeriod} {
+		at := now.Add(skew)
+		expected, err := totp.GenerateCodeCustom(secret, at, opts)
+		if err == nil && subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return at.Unix() / int64(opts.Period), true
+		}
+	}
+	return 0, false
+}
+
+// acceptTOTP accepts a TOTP code once, a code of the same or an earlier time step than the last accepted one is a replay
+// and rejected.
+func (app *application) acceptTOTP(ctx context.Context, user *database.User, code string) (bool, error) {
+	key, err := app.totpKey(user)
+	if err != nil {
+		return false, err
+	}
+	step, ok := totpStep(code, key.Secret(), time.Now())
+	if !ok {
+		return false, nil
+	}
+	accepted, err := app.DB.queries.UseTOTPStep(ctx, database.UseTOTPStepParams{Step: step, ID: user.ID})
+	if err != nil {
+		return false, err
+	}
+	return accepted == 1, nil
+}
+
+// verifySecondFactor accepts a TOTP code or an unused recovery code, which is used up. Incorrect codes are counted and
+// too many in a row lock the second factor, while it's locked every code is refused with errTwoFactorLockedOut.
+func (app *application) verifySecondFactor(ctx context.Context, user *database.User, code string) (bool, error) {
+	if user.TotpLockedUntil.Valid && time.Now().Before(user.TotpLockedUntil.Time) {
+		return false, errTwoFactorLockedOut
+	}
+	valid, err := app.checkSecondFactor(ctx, user, strings.TrimSpace(code))
+	if err != nil {
+		return false, err
+	}
+	if valid {
+		return true, app.DB.queries.ResetTwoFactorFailures(ctx, user.ID)
+	}
+	err = app.DB.queries.RecordTwoFactorFailure(ctx, database.RecordTwoFactorFailureParams{
+		MaxFailures: twoFactorMaxFailures,
+		LockedUntil: sql.NullTime{Time: time.Now().Add(twoFactorLockout), Valid: true},
+		ID:          user.ID,
+	})
+	return false, err
+}
+
+func (app *application) checkSecondFactor(ctx context.Context, user *database.User, code string) (bool, error) {
+	if code == "" {
+		return false, nil
+	}
+	valid, err := app.acceptTOTP(ctx, user, code)
+	if err != nil || valid {
+		return valid, err
+	}
+	used, err := app.DB.queries.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   user.ID,
+		CodeHash: hashRecoveryCode(code),
+	})
+	if err != nil {
+		return false, err
+	}
+	return used == 1, nil
+}
+
+// qrCodeDataURL renders the key as a PNG QR code, inlined so the secret never hits a URL.
+func qrCodeDataURL(key *otp.Key) (template.URL, error) {
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return "", err
+	}
+	// #nosec G203 -- the data URL is built from our own PNG, not from user input
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// twoFactorPage lets users set up, check and turn off two-factor authentication. The POST action is one of enroll,
+// confirm, recovery-codes or disable.
+func (app *application) twoFactorPage(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	user := contextGetAuthenticatedUser(r)
+	var form twoFactorForm
+	status := http.StatusOK
+	data := app.newTemplateData(r)
+	if r.Method == http.MethodPost {
+		err := request.DecodePostForm(r, &form)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		switch form.Action {
+		case "enroll":
+			if user.TotpEnabled {
+				app.badRequest(w, r, errors.New("two-factor authentication is already enabled"))
+				return
+			}
+			key, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: user.Username})
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			encryptedSecret, err := encrypt(key.Secret(), app.config.ScrapydEncryptSecret)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			err = app.DB.queries.SetUserTOTPSecret(ctxwt, database.SetUserTOTPSecretParams{TotpSecret: encryptedSecret, ID: user.ID})
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			user.TotpSecret = encryptedSecret
+		case "confirm":
+			if user.TotpEnabled || user.TotpSecret == nil {
+				app.badRequest(w, r, errors.New("two-factor enrollment wasn't started"))
+				return
+			}
+			valid, err := app.acceptTOTP(ctxwt, user, strings.TrimSpace(form.Code))
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			form.Validator.CheckField(valid, "code", "Code is incorrect, check your device's clock")
+			if form.Validator.HasErrors() {
+				status = http.StatusUnprocessableEntity
+				break
+			}
+			err = app.DB.queries.EnableUserTOTP(ctxwt, user.ID)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			user.TotpEnabled = true
+			data["RecoveryCodes"], err = app.createRecoveryCodes(ctxwt, user.ID)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		case "recovery-codes", "disable":
+			if !user.TotpEnabled {
+				app.badRequest(w, r, errors.New("two-factor authentication isn't enabled"))
+				return
+			}
+			valid, err := app.verifySecondFactor(ctxwt, user, form.Code)
+			if errors.Is(err, errTwoFactorLockedOut) {
+				form.Validator.AddFieldError("code", "Too many incorrect codes, try again later")
+			} else if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			form.Validator.CheckField(valid, "code", "Code is incorrect")
+			if form.Action == "disable" {
+				form.Validator.CheckField(!user.TotpRequired, "code", "An admin requires two-factor authentication for your account")
+			}
+			if form.Validator.HasErrors() {
+				status = http.StatusUnprocessableEntity
+				break
+			}
+			if form.Action == "disable" {
+				err = app.resetTwoFactor(ctxwt, user.ID)
+				user.TotpEnabled, user.TotpSecret = false, nil
+			} else {
+				data["RecoveryCodes"], err = app.createRecoveryCodes(ctxwt, user.ID)
+			}
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		default:
+			app.badRequest(w, r, fmt.Errorf("unknown action %q", form.Action))
+			return
+		}
+	}
+	if !user.TotpEnabled && user.TotpSecret != nil && (form.Action == "enroll" || form.Action == "confirm") {
+		key, err := app.totpKey(user)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data["QRCode"], err = qrCodeDataURL(key)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data["Secret"] = key.Secret()
+	}
+	if user.TotpEnabled {
+		unused, err := app.DB.queries.CountUnusedRecoveryCodes(ctxwt, user.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data["UnusedRecoveryCodes"] = unused
+	}
+	data["User"] = user
+	data["Form"] = form
+	app.render(w, r, status, twoFactorSettingsPage, nil, data)
+}
+
+func (app *application) resetTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	err := app.DB.queries.ResetUserTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return app.DB.queries.DeleteRecoveryCodesForUser(ctx, userID)
+}
+
+// loginTwoFactor is the second login step for users with 2FA, login stores who passed the password check in the session.
+func (app *application) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	session, err := app.sessionStore.Get(r, "session")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	userID, ok := session.Values["twoFactorUserID"].(uuid.UUID)
+	startedAt, _ := session.Values["twoFactorStartedAt"].(int64)
+	if !ok || time.Since(time.Unix(startedAt, 0)) > twoFactorLoginTimeout {
+		delete(session.Values, "twoFactorUserID")
+		delete(session.Values, "twoFactorStartedAt")
+		err = session.Save(r, w)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	var form twoFactorForm
+	switch r.Method {
+	case http.MethodGet:
+		data := app.newTemplateData(r)
+		data["Form"] = form
+		app.render(w, r, http.StatusOK, loginTwoFactorPage, nil, data)
+	case http.MethodPost:
+		err = request.DecodePostForm(r, &form)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		user, err := app.DB.queries.GetUserWithID(ctxwt, userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		valid, err := app.verifySecondFactor(ctxwt, &user, form.Code)
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, errTwoFactorLockedOut) {
+			form.Validator.AddFieldError("code", "Too many incorrect codes, try again later")
+			status = http.StatusTooManyRequests
+		} else if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		form.Validator.CheckField(valid, "code", "Code is incorrect")
+		if form.Validator.HasErrors() {
+			data := app.newTemplateData(r)
+			data["Form"] = form
+			app.render(w, r, status, loginTwoFactorPage, nil, data)
+			return
+		}
+		delete(session.Values, "twoFactorUserID")
+		delete(session.Values, "twoFactorStartedAt")
+		app.completeLogin(w, r, session, user.ID)
+	}
+}
+
+// resetUserTwoFactor lets an admin turn off 2FA for a user who lost their device and recovery codes.
+func (app *application) resetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	err = app.resetTwoFactor(ctxwt, userID)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/password"
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	totpSecretRX    = regexp.MustCompile(`id="totp-secret"[^>]*>([A-Z2-7]+)<`)
+	recoveryCodesRX = regexp.MustCompile(`(?s)id="recovery-codes"[^>]*>(.*?)</pre>`)
+)
+
+func TestHashRecoveryCode(t *testing.T) {
+	assert.Equal(t, string(hashRecoveryCode("abcd-efgh")), string(hashRecoveryCode(" ABCD EFGH")))
+	assert.NotEqual(t, string(hashRecoveryCode("abcd-efgh")), string(hashRecoveryCode("abcd-efgi")))
+}
+
+func TestTwoFactorEnrollmentPending(t *testing.T) {
+	assert.Equal(t, twoFactorEnrollmentPending(nil), false)
+	assert.Equal(t, twoFactorEnrollmentPending(&database.User{TotpRequired: true}), true)
+	assert.Equal(t, twoFactorEnrollmentPending(&database.User{TotpRequired: true, TotpEnabled: true}), false)
+	assert.Equal(t, twoFactorEnrollmentPending(&database.User{}), false)
+}
+
+func TestTwoFactor(t *testing.T) {
+	ta := newTestApplication(t)
+	ta.config.ScrapydEncryptSecret = "thisis16bytes123"
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ctx := context.Background()
+	hashedPassword, err := password.Hash("ThisIsAVerySecurePasswordA$$word")
+	assert.NilError(t, err)
+	user, err := ta.DB.queries.CreateNewUser(ctx, database.CreateNewUserParams{
+		ID:             uuid.New(),
+		Username:       "careful",
+		HashedPassword: hashedPassword,
+		Role:           roleDeployer,
+		TotpRequired:   true,
+	})
+	assert.NilError(t, err)
+
+	var secret, recoveryCode string
+	t.Run("Enrollment is enforced", func(t *testing.T) {
+		ts.loginAs(t, "careful", "ThisIsAVerySecurePasswordA$$word")
+		code, headers, _ := ts.get(t, "/list-nodes")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/two-factor")
+		code, _, _ = ts.doJSON(t, http.MethodGet, "/api/v1/nodes", nil)
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+	t.Run("Enroll", func(t *testing.T) {
+		code, _, body := ts.get(t, "/two-factor")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "An admin requires two-factor authentication")
+		csrfToken := extractCSRFToken(t, body)
+		code, _, body = ts.postForm(t, "/two-factor", url.Values{"csrf_token": {csrfToken}, "action": {"enroll"}})
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `src="data:image/png;base64,`)
+		matches := totpSecretRX.FindStringSubmatch(body)
+		if len(matches) < 2 {
+			t.Fatal("no TOTP secret in body")
+		}
+		secret = matches[1]
+		stored, err := ta.DB.queries.GetUserWithID(ctx, user.ID)
+		assert.NilError(t, err)
+		assert.StringDoesNotContain(t, string(stored.TotpSecret), secret)
+		code, _, body = ts.postForm(t, "/two-factor", url.Values{"csrf_token": {csrfToken}, "action": {"confirm"}, "code": {"000000"}})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Code is incorrect")
+		valid, err := totp.GenerateCode(secret, time.Now())
+		assert.NilError(t, err)
+		code, _, body = ts.postForm(t, "/two-factor", url.Values{"csrf_token": {csrfToken}, "action": {"confirm"}, "code": {valid}})
+		assert.Equal(t, code, http.StatusOK)
+		matches = recoveryCodesRX.FindStringSubmatch(body)
+		if len(matches) < 2 {
+			t.Fatal("no recovery codes in body")
+		}
+		codes := strings.Fields(matches[1])
+		assert.Equal(t, len(codes), recoveryCodeCount)
+		recoveryCode = codes[0]
+		assert.StringDoesNotContain(t, body, "Turn off two-factor authentication")
+		code, _, _ = ts.get(t, "/list-nodes")
+		assert.Equal(t, code, http.StatusOK)
+	})
+	t.Run("Login asks for a code", func(t *testing.T) {
+		ts := newTestServer(t, ta.routes())
+		defer ts.Close()
+		ts.loginAs(t, "careful", "ThisIsAVerySecurePasswordA$$word")
+		code, headers, _ := ts.get(t, "/list-nodes")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/login")
+		code, _, body := ts.get(t, "/login/two-factor")
+		assert.Equal(t, code, http.StatusOK)
+		csrfToken := extractCSRFToken(t, body)
+		code, _, _ = ts.postForm(t, "/login/two-factor", url.Values{"csrf_token": {csrfToken}, "code": {"000000"}})
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		// The confirmation used up the code of the current step
+		valid, err := totp.GenerateCode(secret, time.Now().Add(totpPeriod))
+		assert.NilError(t, err)
+		code, headers, _ = ts.postForm(t, "/login/two-factor", url.Values{"csrf_token": {csrfToken}, "code": {valid}})
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/list-nodes")
+		code, _, _ = ts.get(t, "/list-nodes")
+		assert.Equal(t, code, http.StatusOK)
+	})
+	t.Run("Codes can't be replayed", func(t *testing.T) {
+		for _, at := range []time.Time{time.Now().Add(totpPeriod), time.Now()} {
+			ts := newTestServer(t, ta.routes())
+			ts.loginAs(t, "careful", "ThisIsAVerySecurePasswordA$$word")
+			_, _, body := ts.get(t, "/login/two-factor")
+			replayed, err := totp.GenerateCode(secret, at)
+			assert.NilError(t, err)
+			code, _, body := ts.postForm(t, "/login/two-factor", url.Values{"csrf_token": {extractCSRFToken(t, body)}, "code": {replayed}})
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+			assert.StringContains(t, body, "Code is incorrect")
+			ts.Close()
+		}
+		assert.NilError(t, ta.DB.queries.ResetTwoFactorFailures(ctx, user.ID))
+	})
+	t.Run("Recovery codes work once", func(t *testing.T) {
+		for _, want := range []int{http.StatusSeeOther, http.StatusUnprocessableEntity} {
+			ts := newTestServer(t, ta.routes())
+			ts.loginAs(t, "careful", "ThisIsAVerySecurePasswordA$$word")
+			_, _, body := ts.get(t, "/login/two-factor")
+			code, _, _ := ts.postForm(t, "/login/two-factor", url.Values{"csrf_token": {extractCSRFToken(t, body)}, "code": {recoveryCode}})
+			assert.Equal(t, code, want)
+			ts.Close()
+		}
+		unused, err := ta.DB.queries.CountUnusedRecoveryCodes(ctx, user.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, unused, int64(recoveryCodeCount-1))
+	})
+	t.Run("Lockout", func(t *testing.T) {
+		assert.NilError(t, ta.DB.queries.ResetTwoFactorFailures(ctx, user.ID))
+		ts := newTestServer(t, ta.routes())
+		defer ts.Close()
+		for range twoFactorMaxFailures {
+			ts.loginAs(t, "careful", "ThisIsAVerySecurePasswordA$$word")
+			_, _, body := ts.get(t, "/login/two-factor")
+			code, _, _ := ts.postForm(t, "/login/two-factor", url.Values{"csrf_token": {extractCSRFToken(t, body)}, "code": {"000000"}})
+			assert.Equal(t, code, http.StatusUnprocessableEntity)
+		}
+		// Entering the password again doesn't lift the lockout, nor does a correct code
+		ts.loginAs(t, "careful", "ThisIsAVerySecurePasswordA$$word")
+		_, _, body := ts.get(t, "/login/two-factor")
+		valid, err := totp.GenerateCode(secret, time.Now().Add(2*totpPeriod))
+		assert.NilError(t, err)
+		code, _, body := ts.postForm(t, "/login/two-factor", url.Values{"csrf_token": {extractCSRFToken(t, body)}, "code": {valid}})
+		assert.Equal(t, code, http.StatusTooManyRequests)
+		assert.StringContains(t, body, "Too many incorrect codes")
+		locked, err := ta.DB.queries.GetUserWithID(ctx, user.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, locked.TotpLockedUntil.Time.After(time.Now().Add(twoFactorLockout-time.Minute)), true)
+	})
+	t.Run("Admin reset", func(t *testing.T) {
+		ts := newTestServer(t, ta.routes())
+		defer ts.Close()
+		ts.login(t)
+		code, _, body := ts.get(t, "/user/edit/"+user.ID.String())
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `hx-post="/user/reset-two-factor/`+user.ID.String()+`"`)
+		code, _, _ = ts.postForm(t, "/user/reset-two-factor/"+user.ID.String(), url.Values{})
+		assert.Equal(t, code, http.StatusBadRequest)
+		code, _, _ = ts.postForm(t, "/user/reset-two-factor/"+user.ID.String(), url.Values{"csrf_token": {extractCSRFToken(t, body)}})
+		assert.Equal(t, code, http.StatusOK)
+		reset, err := ta.DB.queries.GetUserWithID(ctx, user.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, reset.TotpEnabled, false)
+		assert.Equal(t, reset.TotpSecret == nil, true)
+		unused, err := ta.DB.queries.CountUnusedRecoveryCodes(ctx, user.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, unused, int64(0))
+	})
+}
@@ -9,15 +9,28 @@ import (
 	"github.com/blazskufca/goscrapyd/internal/request"
 	"github.com/blazskufca/goscrapyd/internal/validator"
 	"github.com/google/uuid"
+	"github.com/gorilla/sessions"
 	"net/http"
+	"time"
 )
 
 type userAddEditForm struct {
-	Username           string              `form:"username"`
-	Password           string              `form:"password"`
-	PasswordConfirm    string              `form:"password_confirm"`
-	HasAdminPrivileges bool                `form:"grant_admin"`
-	Validator          validator.Validator `form:"-"`
+	Username        string              `form:"username"`
+	Password        string              `form:"password"`
+	PasswordConfirm string              `form:"password_confirm"`
+	Role            string              `form:"role"`
+	TotpRequired    bool                `form:"totp_required"`
+	Validator       validator.Validator `form:"-"`
+}
+
+// validateRole checks the selected role against the roles table.
+func (app *application) validateRole(ctx context.Context, v *validator.Validator, role string) error {
+	_, err := app.DB.queries.GetRoleWithName(ctx, role)
+	if errors.Is(err, sql.ErrNoRows) {
+		v.AddFieldError("Role", "Select one of the roles")
+		return nil
+	}
+	return err
 }
 
 func (app *application) listsUsers(w http.ResponseWriter, r *http.Request) {
@@ -36,14 +49,20 @@ func (app *application) listsUsers(w http.ResponseWriter, r *http.Request) {
 func (app *application) addNewUser(w http.ResponseWriter, r *http.Request) {
 	ctxwt, cancelFunc := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
 	defer cancelFunc()
-	var form userAddEditForm
+	form := userAddEditForm{Role: roleViewer}
+	roles, err := app.DB.queries.ListRoles(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
 		data := app.newTemplateData(r)
 		data["Form"] = form
+		data["Roles"] = roles
 		app.render(w, r, http.StatusOK, addUserFormPage, nil, data)
 	case http.MethodPost:
-		err := request.DecodePostForm(r, &form)
+		err = request.DecodePostForm(r, &form)
 		if err != nil {
 			app.badRequest(w, r, err)
 			return
@@ -56,10 +75,16 @@ func (app *application) addNewUser(w http.ResponseWriter, r *http.Request) {
 		form.Validator.CheckField(validator.NotIn(form.Password, password.CommonPasswords...), "Password", "Password is too common")
 		form.Validator.CheckField(form.Password != "", "PasswordConfirm", "You need to confirm the selected password")
 		form.Validator.CheckField(form.PasswordConfirm == form.Password, "PasswordConfirm", "Passwords do not match")
+		err = app.validateRole(ctxwt, &form.Validator, form.Role)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 
 		if form.Validator.HasErrors() {
 			data := app.newTemplateData(r)
 			data["Form"] = form
+			data["Roles"] = roles
 			app.render(w, r, http.StatusUnprocessableEntity, addUserFormPage, nil, data)
 			return
 		}
@@ -76,10 +101,11 @@ func (app *application) addNewUser(w http.ResponseWriter, r *http.Request) {
 		}
 
 		_, err = app.DB.queries.CreateNewUser(ctxwt, database.CreateNewUserParams{
-			ID:                 userUUID,
-			Username:           form.Username,
-			HashedPassword:     hashedPassword,
-			HasAdminPrivileges: form.HasAdminPrivileges,
+			ID:             userUUID,
+			Username:       form.Username,
+			HashedPassword: hashedPassword,
+			Role:           form.Role,
+			TotpRequired:   form.TotpRequired,
 		})
 		if err != nil {
 			app.serverError(w, r, err)
@@ -144,23 +170,40 @@ func (app *application) login(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		session.Values["userID"] = user.ID
-
-		redirectPath, ok := session.Values["redirectPathAfterLogin"].(string)
-		if ok {
-			delete(session.Values, "redirectPathAfterLogin")
-		} else {
-			redirectPath = "/list-nodes"
-		}
-
-		err = session.Save(r, w)
-		if err != nil {
-			app.serverError(w, r, err)
+		if user.TotpEnabled {
+			// The password was right, the user is logged in once loginTwoFactor accepts their code
+			session.Values["twoFactorUserID"] = user.ID
+			session.Values["twoFactorStartedAt"] = time.Now().Unix()
+			err = session.Save(r, w)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, "/login/two-factor", http.StatusSeeOther)
 			return
 		}
 
-		http.Redirect(w, r, redirectPath, http.StatusSeeOther)
+		app.completeLogin(w, r, session, user.ID)
+	}
+}
+
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, session *sessions.Session, userID uuid.UUID) {
+	session.Values["userID"] = userID
+
+	redirectPath, ok := session.Values["redirectPathAfterLogin"].(string)
+	if ok {
+		delete(session.Values, "redirectPathAfterLogin")
+	} else {
+		redirectPath = "/list-nodes"
+	}
+
+	err := session.Save(r, w)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
+
+	http.Redirect(w, r, redirectPath, http.StatusSeeOther)
 }
 
 func (app *application) logout(w http.ResponseWriter, r *http.Request) {
@@ -217,13 +260,21 @@ func (app *application) updateUser(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 		return
 	}
+	roles, err := app.DB.queries.ListRoles(ctxwt)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
 		FormData.Username = user.Username
+		FormData.Role = user.Role
+		FormData.TotpRequired = user.TotpRequired
 		templateData := app.newTemplateData(r)
 		templateData["Form"] = FormData
-		templateData["IsAdmin"] = user.HasAdminPrivileges
+		templateData["Roles"] = roles
 		templateData["ID"] = user.ID
+		templateData["TotpEnabled"] = user.TotpEnabled
 		app.render(w, r, http.StatusOK, editUserPage, nil, templateData)
 	case http.MethodPost:
 		var userPassword string
@@ -242,11 +293,19 @@ func (app *application) updateUser(w http.ResponseWriter, r *http.Request) {
 			FormData.Validator.CheckField(FormData.Password != "", "PasswordConfirm", "You need to confirm the selected password")
 			FormData.Validator.CheckField(FormData.PasswordConfirm == FormData.Password, "PasswordConfirm", "Passwords do not match")
 		}
+		err = app.validateRole(ctxwt, &FormData.Validator, FormData.Role)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		// Changing your own role could lock every admin out of the user management
+		FormData.Validator.CheckField(user.ID != contextGetAuthenticatedUser(r).ID || FormData.Role == user.Role, "Role", "You can't change your own role")
 		if FormData.Validator.HasErrors() {
 			data := app.newTemplateData(r)
 			data["Form"] = FormData
-			data["IsAdmin"] = FormData.HasAdminPrivileges
+			data["Roles"] = roles
 			data["ID"] = user.ID
+			data["TotpEnabled"] = user.TotpEnabled
 			app.render(w, r, http.StatusUnprocessableEntity, editUserPage, nil, data)
 			return
 		}
@@ -260,10 +319,11 @@ func (app *application) updateUser(w http.ResponseWriter, r *http.Request) {
 			userPassword = user.HashedPassword
 		}
 		err = app.DB.queries.UpdateUserWhereUUID(ctxwt, database.UpdateUserWhereUUIDParams{
-			Username:           FormData.Username,
-			HashedPassword:     userPassword,
-			HasAdminPrivileges: FormData.HasAdminPrivileges,
-			ID:                 user.ID,
+			Username:       FormData.Username,
+			HashedPassword: userPassword,
+			Role:           FormData.Role,
+			TotpRequired:   FormData.TotpRequired,
+			ID:             user.ID,
 		})
 		if err != nil {
 			app.serverError(w, r, err)
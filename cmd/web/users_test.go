@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
-	"strconv"
 	"strings"
 	"testing"
 )
@@ -183,7 +182,7 @@ func TestAddNewUser(t *testing.T) {
 		username        string
 		password        string
 		passwordConfirm string
-		isAdmin         bool
+		role            string
 		wantCode        int
 		wantBody        string
 	}{
@@ -192,7 +191,7 @@ func TestAddNewUser(t *testing.T) {
 			username:        "TestUser",
 			password:        "ThisIsAVerySecurePasswordA$$word",
 			passwordConfirm: "ThisIsAVerySecurePasswordA$$word",
-			isAdmin:         false,
+			role:            roleViewer,
 			wantCode:        http.StatusOK,
 			wantBody:        "TestUser",
 		},
@@ -201,16 +200,25 @@ func TestAddNewUser(t *testing.T) {
 			username:        "AdminUser",
 			password:        "ThisIsAVerySecurePasswordA$$word",
 			passwordConfirm: "ThisIsAVerySecurePasswordA$$word",
-			isAdmin:         true,
+			role:            roleAdmin,
 			wantCode:        http.StatusOK,
-			wantBody:        "Yes",
+			wantBody:        "AdminUser",
+		},
+		{
+			name:            "Test Add New User Operator",
+			username:        "OperatorUser",
+			password:        "ThisIsAVerySecurePasswordA$$word",
+			passwordConfirm: "ThisIsAVerySecurePasswordA$$word",
+			role:            roleOperator,
+			wantCode:        http.StatusOK,
+			wantBody:        ">operator</span>",
 		},
 		{
 			name:            "Test No Password",
 			username:        "NoPassword",
 			password:        "",
 			passwordConfirm: "",
-			isAdmin:         false,
+			role:            roleViewer,
 			wantCode:        http.StatusUnprocessableEntity,
 			wantBody:        "Password is required",
 		},
@@ -219,7 +227,7 @@ func TestAddNewUser(t *testing.T) {
 			username:        "TestUser",
 			password:        "ThisIsAVerySecurePasswordA$$word",
 			passwordConfirm: "This is not",
-			isAdmin:         false,
+			role:            roleViewer,
 			wantCode:        http.StatusUnprocessableEntity,
 			wantBody:        "Passwords do not match",
 		},
@@ -228,7 +236,7 @@ func TestAddNewUser(t *testing.T) {
 			username:        "",
 			password:        "ThisIsAVerySecurePasswordA$$word",
 			passwordConfirm: "ThisIsAVerySecurePasswordA$$word",
-			isAdmin:         false,
+			role:            roleViewer,
 			wantCode:        http.StatusUnprocessableEntity,
 			wantBody:        "Username is required",
 		},
@@ -237,10 +245,19 @@ func TestAddNewUser(t *testing.T) {
 			username:        "TestUser",
 			password:        "password",
 			passwordConfirm: "password",
-			isAdmin:         false,
+			role:            roleViewer,
 			wantCode:        http.StatusUnprocessableEntity,
 			wantBody:        "Password is too common",
 		},
+		{
+			name:            "Test Unknown role",
+			username:        "RoleUser",
+			password:        "ThisIsAVerySecurePasswordA$$word",
+			passwordConfirm: "ThisIsAVerySecurePasswordA$$word",
+			role:            "superuser",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "Select one of the roles",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -257,7 +274,7 @@ func TestAddNewUser(t *testing.T) {
 			form.Add("password", tt.password)
 			form.Add("csrf_token", csrfToken)
 			form.Add("password_confirm", tt.passwordConfirm)
-			form.Add("grant_admin", strconv.FormatBool(tt.isAdmin))
+			form.Add("role", tt.role)
 			status, _, body := ts.postFormFollowRedirects(t, "/add-user", form)
 			if status != tt.wantCode {
 				t.Fatalf("got status %d; want %d", status, tt.wantCode)
@@ -282,10 +299,10 @@ func TestEditUser(t *testing.T) {
 			t.Fatal(err)
 		}
 		user, err = app.DB.queries.CreateNewUser(context.Background(), database.CreateNewUserParams{
-			ID:                 userUUID,
-			Username:           "TestUser",
-			HashedPassword:     hashedPassword,
-			HasAdminPrivileges: true,
+			ID:             userUUID,
+			Username:       "TestUser",
+			HashedPassword: hashedPassword,
+			Role:           roleAdmin,
 		})
 		if err != nil {
 			t.Fatal(err)
@@ -305,7 +322,7 @@ func TestEditUser(t *testing.T) {
 		form.Add("password", "")
 		form.Add("csrf_token", csrfToken)
 		form.Add("password_confirm", "")
-		form.Add("grant_admin", strconv.FormatBool(user.HasAdminPrivileges))
+		form.Add("role", user.Role)
 		status, _, body := ts.postFormFollowRedirects(t, "/user/edit/"+user.ID.String(), form)
 		if status != http.StatusOK {
 			t.Fatalf("got status %d; want %d", status, http.StatusOK)
@@ -327,10 +344,10 @@ func TestDeleteUser(t *testing.T) {
 			t.Fatal(err)
 		}
 		user, err = app.DB.queries.CreateNewUser(context.Background(), database.CreateNewUserParams{
-			ID:                 userUUID,
-			Username:           "TestUser",
-			HashedPassword:     hashedPassword,
-			HasAdminPrivileges: true,
+			ID:             userUUID,
+			Username:       "TestUser",
+			HashedPassword: hashedPassword,
+			Role:           roleAdmin,
 		})
 		if err != nil {
 			t.Fatal(err)
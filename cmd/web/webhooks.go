@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/blazskufca/goscrapyd/internal/validator"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	webhookSignatureHeader = "X-Goscrapyd-Signature"
+	webhookTimestampHeader = "X-Goscrapyd-Timestamp"
+	webhookNonceHeader     = "X-Goscrapyd-Nonce"
+	webhookSignaturePrefix = "sha256="
+	webhookSecretPrefix    = "whsec_"
+	webhookTriggeredBy     = "webhook"
+	// webhookTolerance is how far the signed timestamp may drift from our clock. Nonces are remembered for twice as
+	// long, so a request can't be replayed once its nonce is forgotten because the timestamp check rejects it first.
+	webhookTolerance    = 5 * time.Minute
+	webhookMaxBodyBytes = 64 << 10
+	webhookMaxNonceLen  = 128
+)
+
+var errWebhookSignature = errors.New("missing or invalid webhook signature")
+
+// webhookInput is the optional JSON body of a webhook call, args and settings are merged over the ones saved on the task.
+type webhookInput struct {
+	Source    string              `json:"source"`
+	Args      map[string]string   `json:"args"`
+	Settings  map[string]string   `json:"settings"`
+	Validator validator.Validator `json:"-"`
+}
+
+type apiWebhook struct {
+	URL         string     `json:"url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastFiredAt *time.Time `json:"last_fired_at"`
+	Secret      string     `json:"secret,omitempty"`
+}
+
+func (app *application) webhookURL(taskID uuid.UUID) string {
+	return strings.TrimSuffix(app.config.baseURL, "/") + "/hooks/tasks/" + taskID.String()
+}
+
+func (app *application) newAPIWebhook(webhook database.TaskWebhook) apiWebhook {
+	return apiWebhook{
+		URL:         app.webhookURL(webhook.TaskID),
+		CreatedAt:   webhook.CreatedAt,
+		LastFiredAt: nullTimePtr(webhook.LastFiredAt),
+	}
+}
+
+// signWebhook computes the hex encoded HMAC-SHA256 of "timestamp.nonce.body", which callers send as
+// "X-Goscrapyd-Signature: sha256=<hex>".
+func signWebhook(secret, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhookSignature checks the signature and that the timestamp is within webhookTolerance of now.
+func verifyWebhookSignature(header http.Header, secret string, body []byte, now time.Time) error {
+	timestamp := header.Get(webhookTimestampHeader)
+	nonce := header.Get(webhookNonceHeader)
+	signature, found := strings.CutPrefix(header.Get(webhookSignatureHeader), webhookSignaturePrefix)
+	if !found || timestamp == "" || nonce == "" || len(nonce) > webhookMaxNonceLen {
+		return errWebhookSignature
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errWebhookSignature
+	}
+	if drift := now.Sub(time.Unix(unix, 0)); drift > webhookTolerance || drift < -webhookTolerance {
+		return errWebhookSignature
+	}
+	expected := signWebhook(secret, timestamp, nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return errWebhookSignature
+	}
+	return nil
+}
+
+// webhookUnauthorized is the one answer to every webhook call which can't be authenticated.
+func (app *application) webhookUnauthorized(w http.ResponseWriter, r *http.Request) {
+	app.apiErrorResponse(w, r, http.StatusUnauthorized, errWebhookSignature.Error(), nil)
+}
+
+func (in *webhookInput) validate() {
+	in.Validator.CheckField(validator.MaxRunes(in.Source, 100), "source", "Source must not be more than 100 characters")
+	for key := range in.Args {
+		in.Validator.CheckField(!slices.Contains(apiReservedSpiderArgs, key), "args", fmt.Sprintf("%s can not be passed as a spider argument", key))
+	}
+	for name := range in.Settings {
+		in.Validator.CheckField(validator.NotBlank(name) && !strings.Contains(name, "="), "settings", fmt.Sprintf("%q is not a valid setting name", name))
+	}
+}
+
+// mergeInto overrides the task's saved spider values, settings are matched by name in their setting=NAME=VALUE form.
+func (in *webhookInput) mergeInto(values url.Values) {
+	for key, value := range in.Args {
+		values.Set(key, value)
+	}
+	if len(in.Settings) == 0 {
+		return
+	}
+	var settings []string
+	for _, setting := range values["setting"] {
+		name, _, _ := strings.Cut(setting, "=")
+		if _, overridden := in.Settings[name]; !overridden {
+			settings = append(settings, setting)
+		}
+	}
+	for name, value := range in.Settings {
+		settings = append(settings, name+"="+value)
+	}
+	slices.Sort(settings)
+	values["setting"] = settings
+}
+
+func (in *webhookInput) triggeredBy() string {
+	if source := strings.TrimSpace(in.Source); source != "" {
+		return webhookTriggeredBy + ":" + source
+	}
+	return webhookTriggeredBy
+}
+
+// createTaskWebhook generates a new secret for the task, replacing the previous one. The plaintext secret is returned
+// once, it's stored encrypted because it has to be read back to verify signatures.
+func (app *application) createTaskWebhook(ctx context.Context, taskID uuid.UUID, user *database.User) (string, database.TaskWebhook, error) {
+	randomBytes := make([]byte, 32)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", database.TaskWebhook{}, err
+	}
+	secret := webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(randomBytes)
+	encrypted, err := encrypt(secret, app.config.ScrapydEncryptSecret)
+	if err != nil {
+		return "", database.TaskWebhook{}, err
+	}
+	params := database.UpsertTaskWebhookParams{
+		TaskID:    taskID,
+		Secret:    encrypted,
+		CreatedAt: time.Now(),
+	}
+	if user != nil {
+		params.CreatedBy = user.ID
+	}
+	webhook, err := app.DB.queries.UpsertTaskWebhook(ctx, params)
+	if err != nil {
+		return "", database.TaskWebhook{}, err
+	}
+	return secret, webhook, nil
+}
+
+// fireTaskWebhook lets other systems start a task without a session. The request must be signed with the task's
+// webhook secret (see signWebhook) and every nonce is only accepted once. Paused tasks are fired as well, pausing only
+// stops the schedule.
+func (app *application) fireTaskWebhook(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBodyBytes))
+	if err != nil {
+		app.apiErrorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", webhookMaxBodyBytes), nil)
+		return
+	}
+	// Unknown tasks and tasks without a webhook answer like a bad signature, callers without the secret can't tell
+	// which task IDs exist
+	taskUUID, err := uuid.Parse(r.PathValue("taskUUID"))
+	if err != nil {
+		app.webhookUnauthorized(w, r)
+		return
+	}
+	taskDb, err := app.DB.queries.GetTaskWithUUID(ctxwt, taskUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.webhookUnauthorized(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	webhook, err := app.DB.queries.GetWebhookForTask(ctxwt, taskDb.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.webhookUnauthorized(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	secret, err := decrypt(webhook.Secret, app.config.ScrapydEncryptSecret)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	now := time.Now()
+	err = verifyWebhookSignature(r.Header, secret, body, now)
+	if err != nil {
+		app.webhookUnauthorized(w, r)
+		return
+	}
+	var input webhookInput
+	if len(bytes.TrimSpace(body)) != 0 {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&input); err != nil {
+			app.apiBadRequest(w, r, fmt.Errorf("body contains invalid JSON: %w", err))
+			return
+		}
+	}
+	input.validate()
+	if input.Validator.HasErrors() {
+		app.apiFailedValidation(w, r, input.Validator)
+		return
+	}
+	err = app.DB.queries.DeleteWebhookNoncesSeenBefore(ctxwt, now.Add(-2*webhookTolerance))
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	inserted, err := app.DB.queries.InsertWebhookNonce(ctxwt, database.InsertWebhookNonceParams{
+		WebhookID: webhook.ID,
+		Nonce:     r.Header.Get(webhookNonceHeader),
+		SeenAt:    now,
+	})
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	if inserted == 0 {
+		app.apiErrorResponse(w, r, http.StatusConflict, "this nonce was already used", nil)
+		return
+	}
+	t, err := app.taskFromDb(taskDb)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	input.mergeInto(t.SpiderValues)
+	t.TriggeredBy = input.triggeredBy()
+	fired, err := t.fireNow("")
+	if isSkippedFire(err) {
+		app.apiErrorResponse(w, r, http.StatusConflict, err.Error(), nil)
+		return
+	} else if err != nil {
+		app.apiBadGateway(w, r, err)
+		return
+	}
+	err = app.DB.queries.UpdateWebhookLastFired(ctxwt, database.UpdateWebhookLastFiredParams{
+		LastFiredAt: sql.NullTime{Time: now, Valid: true},
+		ID:          webhook.ID,
+	})
+	if err != nil {
+		app.logger.ErrorContext(ctxwt, "error updating webhook last fired time", slog.Any("taskID", taskDb.ID), slog.Any("err", err))
+	}
+	app.apiJSON(w, r, http.StatusAccepted, map[string]any{"jobs": fired})
+}
+
+func (app *application) apiGetTaskWebhook(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskDb, ok := app.apiTaskFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	webhook, err := app.DB.queries.GetWebhookForTask(ctxwt, taskDb.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			app.apiNotFound(w, r)
+		} else {
+			app.apiServerError(w, r, err)
+		}
+		return
+	}
+	app.apiJSON(w, r, http.StatusOK, map[string]any{"webhook": app.newAPIWebhook(webhook)})
+}
+
+func (app *application) apiCreateTaskWebhook(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskDb, ok := app.apiTaskFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	secret, webhook, err := app.createTaskWebhook(ctxwt, taskDb.ID, contextGetAuthenticatedUser(r))
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	result := app.newAPIWebhook(webhook)
+	result.Secret = secret
+	app.apiJSON(w, r, http.StatusCreated, map[string]any{"webhook": result})
+}
+
+func (app *application) apiDeleteTaskWebhook(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskDb, ok := app.apiTaskFromPath(ctxwt, w, r)
+	if !ok {
+		return
+	}
+	deleted, err := app.DB.queries.DeleteWebhookForTask(ctxwt, taskDb.ID)
+	if err != nil {
+		app.apiServerError(w, r, err)
+		return
+	}
+	if deleted == 0 {
+		app.apiNotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// htmxTaskWebhook creates (POST) or removes (DELETE) the webhook of a task from the edit task page.
+func (app *application) htmxTaskWebhook(w http.ResponseWriter, r *http.Request) {
+	ctxwt, cancel := context.WithTimeout(r.Context(), app.config.DefaultTimeout)
+	defer cancel()
+	taskUUID, err := uuid.Parse(r.PathValue("taskUUID"))
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	taskDb, err := app.DB.queries.GetTaskWithUUID(ctxwt, taskUUID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data := app.newTemplateData(r)
+	data["Task"] = taskDb
+	switch r.Method {
+	case http.MethodPost:
+		secret, webhook, err := app.createTaskWebhook(ctxwt, taskDb.ID, contextGetAuthenticatedUser(r))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data["Webhook"] = app.newAPIWebhook(webhook)
+		data["WebhookSecret"] = secret
+	case http.MethodDelete:
+		_, err := app.DB.queries.DeleteWebhookForTask(ctxwt, taskDb.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	app.renderHTMX(w, r, http.StatusOK, htmxTaskWebhook, nil, "htmx:TaskWebhook", data)
+}
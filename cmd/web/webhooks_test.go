@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blazskufca/goscrapyd/internal/assert"
+	"github.com/blazskufca/goscrapyd/internal/database"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// doWebhook signs and sends a webhook call the way an upstream system would.
+func (ts *testServer) doWebhook(t *testing.T, hookURL, secret, nonce string, timestamp time.Time, body []byte) (int, map[string]any) {
+	req, err := http.NewRequest(http.MethodPost, hookURL, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	req.Header.Set(webhookTimestampHeader, unix)
+	req.Header.Set(webhookNonceHeader, nonce)
+	req.Header.Set(webhookSignatureHeader, webhookSignaturePrefix+signWebhook(secret, unix, nonce, body))
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	var result map[string]any
+	err = json.NewDecoder(rs.Body).Decode(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, result
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"source": "cms"}`)
+	signed := func(secret string, at time.Time, nonce string) http.Header {
+		unix := strconv.FormatInt(at.Unix(), 10)
+		header := http.Header{}
+		header.Set(webhookTimestampHeader, unix)
+		header.Set(webhookNonceHeader, nonce)
+		header.Set(webhookSignatureHeader, webhookSignaturePrefix+signWebhook(secret, unix, nonce, body))
+		return header
+	}
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr bool
+	}{
+		{"Valid", signed("secret", now, "n1"), false},
+		{"Slightly in the future", signed("secret", now.Add(time.Minute), "n1"), false},
+		{"Wrong secret", signed("other", now, "n1"), true},
+		{"Stale", signed("secret", now.Add(-webhookTolerance-time.Second), "n1"), true},
+		{"Too far in the future", signed("secret", now.Add(webhookTolerance+time.Second), "n1"), true},
+		{"Missing nonce", signed("secret", now, ""), true},
+		{"No headers", http.Header{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyWebhookSignature(tt.header, "secret", body, now)
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+	t.Run("Tampered body", func(t *testing.T) {
+		err := verifyWebhookSignature(signed("secret", now, "n1"), "secret", []byte(`{"source": "evil"}`), now)
+		assert.Equal(t, err != nil, true)
+	})
+}
+
+func TestWebhookInputMergeInto(t *testing.T) {
+	values := url.Values{"project": {"shop"}, "category": {"books"}, "setting": {"DOWNLOAD_DELAY=2", "LOG_LEVEL=INFO"}}
+	input := webhookInput{Args: map[string]string{"category": "toys"}, Settings: map[string]string{"LOG_LEVEL": "DEBUG"}}
+	input.mergeInto(values)
+	assert.Equal(t, values.Get("category"), "toys")
+	assert.Equal(t, values.Get("project"), "shop")
+	assert.Equal(t, len(values["setting"]), 2)
+	assert.Equal(t, values["setting"][0], "DOWNLOAD_DELAY=2")
+	assert.Equal(t, values["setting"][1], "LOG_LEVEL=DEBUG")
+	assert.Equal(t, input.triggeredBy(), "webhook")
+	input.Source = "cms"
+	assert.Equal(t, input.triggeredBy(), "webhook:cms")
+}
+
+func TestTaskWebhooks(t *testing.T) {
+	ta := newTestApplication(t)
+	ts := newTestServer(t, ta.routes())
+	defer ts.Close()
+	ts.login(t)
+	ta.config.ScrapydEncryptSecret = "thisis16bytes123"
+	scheduled := make(chan url.Values, 1)
+	mockScrapyd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedule.json" {
+			scheduled <- r.URL.Query()
+			_, err := w.Write([]byte(`{"node_name": "test_node", "status": "ok"}`))
+			assert.NilError(t, err)
+		}
+	}))
+	defer mockScrapyd.Close()
+	_, err := ta.DB.queries.NewScrapydNode(context.Background(), database.NewScrapydNodeParams{
+		Nodename: "test_node",
+		Url:      mockScrapyd.URL,
+	})
+	assert.NilError(t, err)
+	taskName := "webhook_task"
+	taskDb, err := ta.DB.queries.InsertTask(context.Background(), database.InsertTaskParams{
+		ID:                uuid.New(),
+		Name:              database.CreateSqlNullString(&taskName),
+		Project:           "test_project",
+		Spider:            "test_spider",
+		Jobid:             taskName,
+		SettingsArguments: "project=test_project&spider=test_spider&category=books&setting=LOG_LEVEL%3DINFO",
+		CronString:        "* * * * *",
+		Paused:            true,
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, ta.setTaskTargets(context.Background(), taskDb.ID, []string{"test_node"}, nil))
+	webhookPath := "/api/v1/tasks/" + taskDb.ID.String() + "/webhook"
+	hookURL := ts.URL + "/hooks/tasks/" + taskDb.ID.String()
+
+	t.Run("No webhook yet", func(t *testing.T) {
+		code, _, _ := ts.doJSON(t, http.MethodGet, webhookPath, nil)
+		assert.Equal(t, code, http.StatusNotFound)
+		code, noWebhook := ts.doWebhook(t, hookURL, "whsec_guess", "n0", time.Now(), nil)
+		assert.Equal(t, code, http.StatusUnauthorized)
+		code, unknownTask := ts.doWebhook(t, ts.URL+"/hooks/tasks/"+uuid.New().String(), "whsec_guess", "n0", time.Now(), nil)
+		assert.Equal(t, code, http.StatusUnauthorized)
+		assert.Equal(t, fmt.Sprint(unknownTask), fmt.Sprint(noWebhook))
+	})
+	var secret string
+	t.Run("Create", func(t *testing.T) {
+		code, _, body := ts.doJSON(t, http.MethodPost, webhookPath, nil)
+		assert.Equal(t, code, http.StatusCreated)
+		var result struct {
+			Webhook apiWebhook `json:"webhook"`
+		}
+		assert.NilError(t, json.Unmarshal(body, &result))
+		secret = result.Webhook.Secret
+		assert.StringContains(t, secret, webhookSecretPrefix)
+		assert.StringContains(t, result.Webhook.URL, "/hooks/tasks/"+taskDb.ID.String())
+		code, _, body = ts.doJSON(t, http.MethodGet, webhookPath, nil)
+		assert.Equal(t, code, http.StatusOK)
+		result.Webhook = apiWebhook{}
+		assert.NilError(t, json.Unmarshal(body, &result))
+		assert.Equal(t, result.Webhook.Secret, "")
+	})
+	t.Run("Bad signature", func(t *testing.T) {
+		code, badSignature := ts.doWebhook(t, hookURL, "whsec_wrong", "n1", time.Now(), nil)
+		assert.Equal(t, code, http.StatusUnauthorized)
+		code, unknownTask := ts.doWebhook(t, ts.URL+"/hooks/tasks/"+uuid.New().String(), "whsec_wrong", "n1", time.Now(), nil)
+		assert.Equal(t, code, http.StatusUnauthorized)
+		assert.Equal(t, fmt.Sprint(unknownTask), fmt.Sprint(badSignature))
+	})
+	t.Run("Stale timestamp", func(t *testing.T) {
+		code, _ := ts.doWebhook(t, hookURL, secret, "n2", time.Now().Add(-time.Hour), nil)
+		assert.Equal(t, code, http.StatusUnauthorized)
+	})
+	t.Run("Reserved argument", func(t *testing.T) {
+		code, _ := ts.doWebhook(t, hookURL, secret, "n3", time.Now(), []byte(`{"args": {"jobid": "mine"}}`))
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	})
+	t.Run("Fires with overrides", func(t *testing.T) {
+		body := []byte(`{"source": "cms", "args": {"category": "toys"}, "settings": {"LOG_LEVEL": "DEBUG"}}`)
+		code, result := ts.doWebhook(t, hookURL, secret, "n4", time.Now(), body)
+		assert.Equal(t, code, http.StatusAccepted)
+		jobs := result["jobs"].([]any)
+		assert.Equal(t, len(jobs), 1)
+		fired := jobs[0].(map[string]any)
+		assert.Equal(t, fired["node"], any("test_node"))
+		query := <-scheduled
+		assert.Equal(t, query.Get("category"), "toys")
+		assert.Equal(t, query.Get("setting"), "LOG_LEVEL=DEBUG")
+		assert.Equal(t, query.Get("jobid"), fired["job"].(string))
+		job, err := ta.DB.queries.GetLatestJobForTask(context.Background(), taskDb.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, job.Job, fired["job"].(string))
+		assert.Equal(t, job.TriggeredBy.String, "webhook:cms")
+		webhook, err := ta.DB.queries.GetWebhookForTask(context.Background(), taskDb.ID)
+		assert.NilError(t, err)
+		assert.Equal(t, webhook.LastFiredAt.Valid, true)
+	})
+	t.Run("Replayed nonce", func(t *testing.T) {
+		code, _ := ts.doWebhook(t, hookURL, secret, "n4", time.Now(), nil)
+		assert.Equal(t, code, http.StatusConflict)
+	})
+	t.Run("Rotate and delete", func(t *testing.T) {
+		code, _, _ := ts.doJSON(t, http.MethodPost, webhookPath, nil)
+		assert.Equal(t, code, http.StatusCreated)
+		code, _ = ts.doWebhook(t, hookURL, secret, "n5", time.Now(), nil)
+		assert.Equal(t, code, http.StatusUnauthorized)
+		code, _, _ = ts.doJSON(t, http.MethodDelete, webhookPath, nil)
+		assert.Equal(t, code, http.StatusNoContent)
+		code, _, _ = ts.doJSON(t, http.MethodDelete, webhookPath, nil)
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+	t.Run("Edit page", func(t *testing.T) {
+		code, _, body := ts.get(t, "/task/edit/"+taskDb.ID.String())
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, `hx-post="/task/webhook/`+taskDb.ID.String()+`"`)
+		code, _, body = ts.postForm(t, "/task/webhook/"+taskDb.ID.String(), url.Values{})
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "new-webhook-secret")
+	})
+}
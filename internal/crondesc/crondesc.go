@@ -0,0 +1,339 @@
+package crondesc
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxClockTimes is how many times of day are listed as such, "At 06:00 and 18:00", before falling back to minutes past
+// hours.
+const maxClockTimes = 6
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	unit     string
+	min, max int
+	// names are the lower case abbreviations accepted in place of numbers, indexed by value - min
+	names []string
+	// labels are how values are written out, indexed by value - min
+	labels []string
+}
+
+var (
+	minuteField = field{unit: "minute", min: 0, max: 59}
+	hourField   = field{unit: "hour", min: 0, max: 23}
+	domField    = field{unit: "day", min: 1, max: 31}
+	monthField  = field{
+		unit:   "month",
+		min:    1,
+		max:    12,
+		names:  []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"},
+		labels: []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	}
+	dowField = field{
+		unit:   "day of the week",
+		min:    0,
+		max:    6,
+		names:  []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"},
+		labels: []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	}
+)
+
+// part is one comma separated part of a cron field, a single value when start equals end and step is 1.
+type part struct {
+	start, end, step int
+	all              bool
+}
+
+func (p part) single() bool {
+	return !p.all && p.start == p.end
+}
+
+// Describe writes a standard five field cron expression, or one of the @ descriptors, out in plain English. It accepts
+// what cron.ParseStandard from robfig/cron accepts, including a leading CRON_TZ= or TZ=, which it ignores.
+func Describe(expr string) (string, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		_, rest, found := strings.Cut(expr, " ")
+		if !found {
+			return "", fmt.Errorf("missing schedule after %q", expr)
+		}
+		expr = strings.TrimSpace(rest)
+	}
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return "", err
+		}
+		return "Every " + d.String(), nil
+	}
+	if strings.HasPrefix(expr, "@") {
+		standard, ok := descriptors[expr]
+		if !ok {
+			return "", fmt.Errorf("unrecognized descriptor %q", expr)
+		}
+		expr = standard
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return "", fmt.Errorf("expected 5 fields, found %d: %s", len(fields), expr)
+	}
+	var parsed [5][]part
+	for i, f := range []field{minuteField, hourField, domField, monthField, dowField} {
+		parts, err := f.parse(fields[i])
+		if err != nil {
+			return "", err
+		}
+		parsed[i] = parts
+	}
+	minutes, hours, doms, months, dows := parsed[0], parsed[1], parsed[2], parsed[3], parsed[4]
+
+	description := describeTime(minutes, hours)
+	var days, weekdays string
+	if fields[2] != "*" && fields[2] != "?" {
+		days = describeDays(doms)
+	}
+	if fields[4] != "*" && fields[4] != "?" {
+		weekdays = describeWeekdays(dows)
+	}
+	// Like cron, a day of the month and a day of the week which are both restricted match either of them
+	if !isWildcard(fields[2]) && !isWildcard(fields[4]) {
+		description += ", " + days + " or " + weekdays
+	} else {
+		for _, s := range []string{days, weekdays} {
+			if s != "" {
+				description += ", " + s
+			}
+		}
+	}
+	if fields[3] != "*" && fields[3] != "?" {
+		description += ", " + describeMonths(months)
+	}
+	return description, nil
+}
+
+// isWildcard matches robfig/cron, which treats a day field starting with * or ? as unrestricted.
+func isWildcard(s string) bool {
+	return strings.HasPrefix(s, "*") || strings.HasPrefix(s, "?")
+}
+
+func describeTime(minutes, hours []part) string {
+	if allSingle(minutes) && allSingle(hours) && len(minutes)*len(hours) <= maxClockTimes {
+		var times []string
+		for _, h := range sortedValues(hours) {
+			for _, m := range sortedValues(minutes) {
+				times = append(times, fmt.Sprintf("%02d:%02d", h, m))
+			}
+		}
+		return "At " + joinList(times)
+	}
+	var description string
+	if stepped(minutes) {
+		description = capitalize(minuteField.describe(minutes))
+	} else {
+		description = "At " + minuteField.plural(minutes) + " " + minuteField.describe(minutes)
+	}
+	switch {
+	case len(hours) == 1 && hours[0].all && hours[0].step == 1:
+		if !minutes[0].all || len(minutes) > 1 {
+			description += " past every hour"
+		}
+	case stepped(hours):
+		description += " past " + hourField.describe(hours)
+	default:
+		description += " past " + hourField.plural(hours) + " " + hourField.describe(hours)
+	}
+	return description
+}
+
+func describeDays(doms []part) string {
+	if len(doms) == 1 && doms[0].all {
+		return domField.describe(doms)
+	}
+	if stepped(doms) {
+		return domField.describe(doms) + " of the month"
+	}
+	return "on " + domField.plural(doms) + " " + domField.describe(doms) + " of the month"
+}
+
+func describeWeekdays(dows []part) string {
+	if len(dows) == 1 && dows[0].all {
+		return dowField.describe(dows)
+	}
+	return "on " + dowField.describe(dows)
+}
+
+func describeMonths(months []part) string {
+	if len(months) == 1 && months[0].all {
+		return monthField.describe(months)
+	}
+	return "in " + monthField.describe(months)
+}
+
+func (f field) parse(s string) ([]part, error) {
+	var parts []part
+	for _, raw := range strings.Split(s, ",") {
+		p, err := f.parsePart(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s field %q: %w", f.unit, s, err)
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+func (f field) parsePart(s string) (part, error) {
+	p := part{start: f.min, end: f.max, step: 1}
+	rangeAndStep := strings.Split(s, "/")
+	if len(rangeAndStep) > 2 {
+		return part{}, fmt.Errorf("too many slashes in %q", s)
+	}
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+	switch {
+	case lowAndHigh[0] == "*" || lowAndHigh[0] == "?":
+		if len(lowAndHigh) > 1 {
+			return part{}, fmt.Errorf("a wildcard can't start a range in %q", s)
+		}
+		p.all = true
+	case len(lowAndHigh) == 1:
+		value, err := f.value(lowAndHigh[0])
+		if err != nil {
+			return part{}, err
+		}
+		p.start, p.end = value, value
+	case len(lowAndHigh) == 2:
+		var err error
+		p.start, err = f.value(lowAndHigh[0])
+		if err != nil {
+			return part{}, err
+		}
+		p.end, err = f.value(lowAndHigh[1])
+		if err != nil {
+			return part{}, err
+		}
+		if p.end < p.start {
+			return part{}, fmt.Errorf("range %q ends before it starts", s)
+		}
+	default:
+		return part{}, fmt.Errorf("too many hyphens in %q", s)
+	}
+	if len(rangeAndStep) == 2 {
+		step, err := strconv.Atoi(rangeAndStep[1])
+		if err != nil || step < 1 {
+			return part{}, fmt.Errorf("step of %q must be a positive number", s)
+		}
+		// As in robfig/cron, N/step means from N to the end of the range
+		if !p.all && len(lowAndHigh) == 1 {
+			p.end = f.max
+		}
+		p.step = step
+	}
+	return p, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if i := slices.Index(f.names, strings.ToLower(s)); i >= 0 {
+		return f.min + i, nil
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid %s", s, f.unit)
+	}
+	if value < f.min || value > f.max {
+		return 0, fmt.Errorf("%s %d is not between %d and %d", f.unit, value, f.min, f.max)
+	}
+	return value, nil
+}
+
+func (f field) label(value int) string {
+	if f.labels != nil {
+		return f.labels[value-f.min]
+	}
+	return strconv.Itoa(value)
+}
+
+// plural is the unit to put in front of the described parts, "minute 5" but "minutes 5 and 10".
+func (f field) plural(parts []part) string {
+	if len(parts) == 1 && parts[0].single() {
+		return f.unit
+	}
+	return f.unit + "s"
+}
+
+func (f field) describe(parts []part) string {
+	items := make([]string, 0, len(parts))
+	for _, p := range parts {
+		items = append(items, f.describePart(p))
+	}
+	return joinList(items)
+}
+
+func (f field) describePart(p part) string {
+	every := "every " + f.unit
+	if p.step > 1 {
+		every = fmt.Sprintf("every %d %ss", p.step, f.unit)
+		if f.unit == dowField.unit {
+			every = fmt.Sprintf("every %d days of the week", p.step)
+		}
+	}
+	switch {
+	case p.all:
+		return every
+	case p.single():
+		return f.label(p.start)
+	case p.step == 1:
+		return f.label(p.start) + " through " + f.label(p.end)
+	default:
+		return every + " from " + f.label(p.start) + " through " + f.label(p.end)
+	}
+}
+
+// stepped reports whether the field is a single wildcard or stepped range, which describe as "every ...".
+func stepped(parts []part) bool {
+	return len(parts) == 1 && (parts[0].all || parts[0].step > 1)
+}
+
+func allSingle(parts []part) bool {
+	for _, p := range parts {
+		if !p.single() {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedValues(parts []part) []int {
+	values := make([]int, 0, len(parts))
+	for _, p := range parts {
+		values = append(values, p.start)
+	}
+	slices.Sort(values)
+	return slices.Compact(values)
+}
+
+// joinList joins items as "a", "a and b" or "a, b and c".
+func joinList(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
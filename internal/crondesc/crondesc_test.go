@@ -0,0 +1,59 @@
+package crondesc
+
+import (
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"* * * * *", "Every minute"},
+		{"*/5 * * * *", "Every 5 minutes"},
+		{"0 * * * *", "At minute 0 past every hour"},
+		{"* 0 * * *", "Every minute past hour 0"},
+		{"0,30 * * * *", "At minutes 0 and 30 past every hour"},
+		{"0 6 * * *", "At 06:00"},
+		{"30 18,6 * * *", "At 06:30 and 18:30"},
+		{"0 */2 * * *", "At minute 0 past every 2 hours"},
+		{"15 9-17 * * *", "At minute 15 past hours 9 through 17"},
+		{"5/15 * * * *", "Every 15 minutes from 5 through 59 past every hour"},
+		{"0 1-23/2 * * *", "At minute 0 past every 2 hours from 1 through 23"},
+		{"0 0 1-15/2 * *", "At 00:00, every 2 days from 1 through 15 of the month"},
+		{"0 9 * * 1-5", "At 09:00, on Monday through Friday"},
+		{"0 9 * * MON,wed,Fri", "At 09:00, on Monday, Wednesday and Friday"},
+		{"30 2 1 * *", "At 02:30, on day 1 of the month"},
+		{"0 0 1,15 * *", "At 00:00, on days 1 and 15 of the month"},
+		{"0 0 */2 * 1", "At 00:00, every 2 days, on Monday"},
+		{"0 0 13 * 5", "At 00:00, on day 13 of the month or on Friday"},
+		{"0 0 1 jan-mar *", "At 00:00, on day 1 of the month, in January through March"},
+		{"0 0 1 */3 *", "At 00:00, on day 1 of the month, every 3 months"},
+		{"@daily", "At 00:00"},
+		{"@yearly", "At 00:00, on day 1 of the month, in January"},
+		{"@every 90m", "Every 1h30m0s"},
+		{"CRON_TZ=Europe/Berlin 0 6 * * *", "At 06:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			description, err := Describe(tt.expr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if description != tt.expected {
+				t.Errorf("got %q; want %q", description, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDescribeInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * 7", "5-1 * * * *", "*/0 * * * *", "* * * foo *", "@fortnightly", "CRON_TZ=UTC"} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Describe(expr)
+			if err == nil {
+				t.Errorf("expected an error for %q", expr)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: access_grants.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteAccessGrant = `-- name: DeleteAccessGrant :execrows
+DELETE FROM access_grants WHERE id = ?
+`
+
+func (q *Queries) DeleteAccessGrant(ctx context.Context, id int64) (int64, error) {
+	result, err := q.exec(ctx, q.deleteAccessGrantStmt, deleteAccessGrant, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteAccessGrantsForRole = `-- name: DeleteAccessGrantsForRole :exec
+DELETE FROM access_grants WHERE role = ?
+`
+
+func (q *Queries) DeleteAccessGrantsForRole(ctx context.Context, role sql.NullString) error {
+	_, err := q.exec(ctx, q.deleteAccessGrantsForRoleStmt, deleteAccessGrantsForRole, role)
+	return err
+}
+
+const deleteAccessGrantsForUser = `-- name: DeleteAccessGrantsForUser :exec
+DELETE FROM access_grants WHERE user_id = ?
+`
+
+func (q *Queries) DeleteAccessGrantsForUser(ctx context.Context, userID interface{}) error {
+	_, err := q.exec(ctx, q.deleteAccessGrantsForUserStmt, deleteAccessGrantsForUser, userID)
+	return err
+}
+
+const getAccessRestriction = `-- name: GetAccessRestriction :one
+SELECT u.access_restricted, COALESCE(r.access_restricted, false) AS role_access_restricted
+FROM users u
+         LEFT JOIN roles r ON r.name = u.role
+WHERE u.ID = ?
+`
+
+type GetAccessRestrictionRow struct {
+	AccessRestricted     bool
+	RoleAccessRestricted bool
+}
+
+func (q *Queries) GetAccessRestriction(ctx context.Context, id uuid.UUID) (GetAccessRestrictionRow, error) {
+	row := q.queryRow(ctx, q.getAccessRestrictionStmt, getAccessRestriction, id)
+	var i GetAccessRestrictionRow
+	err := row.Scan(&i.AccessRestricted, &i.RoleAccessRestricted)
+	return i, err
+}
+
+const getProjectAndNodeForJob = `-- name: GetProjectAndNodeForJob :one
+SELECT project, node FROM jobs WHERE job = ? AND deleted = 0 ORDER BY id DESC LIMIT 1
+`
+
+type GetProjectAndNodeForJobRow struct {
+	Project string
+	Node    string
+}
+
+func (q *Queries) GetProjectAndNodeForJob(ctx context.Context, job string) (GetProjectAndNodeForJobRow, error) {
+	row := q.queryRow(ctx, q.getProjectAndNodeForJobStmt, getProjectAndNodeForJob, job)
+	var i GetProjectAndNodeForJobRow
+	err := row.Scan(&i.Project, &i.Node)
+	return i, err
+}
+
+const insertAccessGrant = `-- name: InsertAccessGrant :one
+INSERT INTO access_grants (user_id, role, project, node) VALUES (?, ?, ?, ?) RETURNING id, user_id, role, project, node, created_at
+`
+
+type InsertAccessGrantParams struct {
+	UserID  interface{}
+	Role    sql.NullString
+	Project string
+	Node    string
+}
+
+func (q *Queries) InsertAccessGrant(ctx context.Context, arg InsertAccessGrantParams) (AccessGrant, error) {
+	row := q.queryRow(ctx, q.insertAccessGrantStmt, insertAccessGrant,
+		arg.UserID,
+		arg.Role,
+		arg.Project,
+		arg.Node,
+	)
+	var i AccessGrant
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Role,
+		&i.Project,
+		&i.Node,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAccessGrants = `-- name: ListAccessGrants :many
+SELECT g.id, g.user_id, g.role, g.project, g.node, g.created_at, u.username
+FROM access_grants g
+         LEFT JOIN users u ON g.user_id = u.ID
+ORDER BY g.id
+`
+
+type ListAccessGrantsRow struct {
+	ID        int64
+	UserID    interface{}
+	Role      sql.NullString
+	Project   string
+	Node      string
+	CreatedAt time.Time
+	Username  sql.NullString
+}
+
+func (q *Queries) ListAccessGrants(ctx context.Context) ([]ListAccessGrantsRow, error) {
+	rows, err := q.query(ctx, q.listAccessGrantsStmt, listAccessGrants)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAccessGrantsRow
+	for rows.Next() {
+		var i ListAccessGrantsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Role,
+			&i.Project,
+			&i.Node,
+			&i.CreatedAt,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccessGrantsForUser = `-- name: ListAccessGrantsForUser :many
+SELECT project, node FROM access_grants WHERE user_id = ?1 OR role = ?2 ORDER BY id
+`
+
+type ListAccessGrantsForUserParams struct {
+	UserID interface{}
+	Role   sql.NullString
+}
+
+type ListAccessGrantsForUserRow struct {
+	Project string
+	Node    string
+}
+
+func (q *Queries) ListAccessGrantsForUser(ctx context.Context, arg ListAccessGrantsForUserParams) ([]ListAccessGrantsForUserRow, error) {
+	rows, err := q.query(ctx, q.listAccessGrantsForUserStmt, listAccessGrantsForUser, arg.UserID, arg.Role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAccessGrantsForUserRow
+	for rows.Next() {
+		var i ListAccessGrantsForUserRow
+		if err := rows.Scan(&i.Project, &i.Node); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setRoleAccessRestricted = `-- name: SetRoleAccessRestricted :exec
+UPDATE roles SET access_restricted = ? WHERE name = ?
+`
+
+type SetRoleAccessRestrictedParams struct {
+	AccessRestricted bool
+	Name             string
+}
+
+func (q *Queries) SetRoleAccessRestricted(ctx context.Context, arg SetRoleAccessRestrictedParams) error {
+	_, err := q.exec(ctx, q.setRoleAccessRestrictedStmt, setRoleAccessRestricted, arg.AccessRestricted, arg.Name)
+	return err
+}
+
+const setUserAccessRestricted = `-- name: SetUserAccessRestricted :exec
+UPDATE users SET access_restricted = ? WHERE ID = ?
+`
+
+type SetUserAccessRestrictedParams struct {
+	AccessRestricted bool
+	ID               uuid.UUID
+}
+
+func (q *Queries) SetUserAccessRestricted(ctx context.Context, arg SetUserAccessRestrictedParams) error {
+	_, err := q.exec(ctx, q.setUserAccessRestrictedStmt, setUserAccessRestricted, arg.AccessRestricted, arg.ID)
+	return err
+}
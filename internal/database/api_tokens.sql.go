@@ -0,0 +1,141 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: api_tokens.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const getAPITokenWithHash = `-- name: GetAPITokenWithHash :one
+SELECT id, user_id, name, token_hash, scope, created_at, expires_at, last_used_at, last_used_ip, revoked FROM api_tokens WHERE token_hash = ? LIMIT 1
+`
+
+func (q *Queries) GetAPITokenWithHash(ctx context.Context, tokenHash []byte) (ApiToken, error) {
+	row := q.queryRow(ctx, q.getAPITokenWithHashStmt, getAPITokenWithHash, tokenHash)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.LastUsedIp,
+		&i.Revoked,
+	)
+	return i, err
+}
+
+const insertAPIToken = `-- name: InsertAPIToken :one
+INSERT INTO api_tokens (user_id, name, token_hash, scope, expires_at) VALUES (?, ?, ?, ?, ?) RETURNING id, user_id, name, token_hash, scope, created_at, expires_at, last_used_at, last_used_ip, revoked
+`
+
+type InsertAPITokenParams struct {
+	UserID    uuid.UUID
+	Name      string
+	TokenHash []byte
+	Scope     string
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) InsertAPIToken(ctx context.Context, arg InsertAPITokenParams) (ApiToken, error) {
+	row := q.queryRow(ctx, q.insertAPITokenStmt, insertAPIToken,
+		arg.UserID,
+		arg.Name,
+		arg.TokenHash,
+		arg.Scope,
+		arg.ExpiresAt,
+	)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scope,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.LastUsedIp,
+		&i.Revoked,
+	)
+	return i, err
+}
+
+const listAPITokensForUser = `-- name: ListAPITokensForUser :many
+SELECT id, user_id, name, token_hash, scope, created_at, expires_at, last_used_at, last_used_ip, revoked FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC, id DESC
+`
+
+func (q *Queries) ListAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]ApiToken, error) {
+	rows, err := q.query(ctx, q.listAPITokensForUserStmt, listAPITokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiToken
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.TokenHash,
+			&i.Scope,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.LastUsedIp,
+			&i.Revoked,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAPIToken = `-- name: RevokeAPIToken :execrows
+UPDATE api_tokens SET revoked = TRUE WHERE id = ? AND user_id = ?
+`
+
+type RevokeAPITokenParams struct {
+	ID     int64
+	UserID uuid.UUID
+}
+
+func (q *Queries) RevokeAPIToken(ctx context.Context, arg RevokeAPITokenParams) (int64, error) {
+	result, err := q.exec(ctx, q.revokeAPITokenStmt, revokeAPIToken, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateAPITokenLastUsed = `-- name: UpdateAPITokenLastUsed :exec
+UPDATE api_tokens SET last_used_at = ?, last_used_ip = ? WHERE id = ?
+`
+
+type UpdateAPITokenLastUsedParams struct {
+	LastUsedAt sql.NullTime
+	LastUsedIp sql.NullString
+	ID         int64
+}
+
+func (q *Queries) UpdateAPITokenLastUsed(ctx context.Context, arg UpdateAPITokenLastUsedParams) error {
+	_, err := q.exec(ctx, q.updateAPITokenLastUsedStmt, updateAPITokenLastUsed, arg.LastUsedAt, arg.LastUsedIp, arg.ID)
+	return err
+}
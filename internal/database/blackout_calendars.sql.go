@@ -0,0 +1,341 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: blackout_calendars.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteBlackoutCalendar = `-- name: DeleteBlackoutCalendar :execrows
+DELETE FROM blackout_calendars WHERE id = ?
+`
+
+func (q *Queries) DeleteBlackoutCalendar(ctx context.Context, id int64) (int64, error) {
+	result, err := q.exec(ctx, q.deleteBlackoutCalendarStmt, deleteBlackoutCalendar, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteBlackoutWindow = `-- name: DeleteBlackoutWindow :execrows
+DELETE FROM blackout_windows WHERE id = ?
+`
+
+func (q *Queries) DeleteBlackoutWindow(ctx context.Context, id int64) (int64, error) {
+	result, err := q.exec(ctx, q.deleteBlackoutWindowStmt, deleteBlackoutWindow, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteTaskBlackoutCalendars = `-- name: DeleteTaskBlackoutCalendars :exec
+DELETE FROM task_blackout_calendars WHERE task_id = ?
+`
+
+func (q *Queries) DeleteTaskBlackoutCalendars(ctx context.Context, taskID uuid.UUID) error {
+	_, err := q.exec(ctx, q.deleteTaskBlackoutCalendarsStmt, deleteTaskBlackoutCalendars, taskID)
+	return err
+}
+
+const getBlackoutCalendar = `-- name: GetBlackoutCalendar :one
+SELECT id, name, created_at, created_by FROM blackout_calendars WHERE id = ?
+`
+
+func (q *Queries) GetBlackoutCalendar(ctx context.Context, id int64) (BlackoutCalendar, error) {
+	row := q.queryRow(ctx, q.getBlackoutCalendarStmt, getBlackoutCalendar, id)
+	var i BlackoutCalendar
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const getBlackoutCalendarByName = `-- name: GetBlackoutCalendarByName :one
+SELECT id, name, created_at, created_by FROM blackout_calendars WHERE name = ?
+`
+
+func (q *Queries) GetBlackoutCalendarByName(ctx context.Context, name string) (BlackoutCalendar, error) {
+	row := q.queryRow(ctx, q.getBlackoutCalendarByNameStmt, getBlackoutCalendarByName, name)
+	var i BlackoutCalendar
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const insertBlackoutCalendar = `-- name: InsertBlackoutCalendar :one
+INSERT INTO blackout_calendars (name, created_by) VALUES (?, ?) RETURNING id, name, created_at, created_by
+`
+
+type InsertBlackoutCalendarParams struct {
+	Name      string
+	CreatedBy interface{}
+}
+
+func (q *Queries) InsertBlackoutCalendar(ctx context.Context, arg InsertBlackoutCalendarParams) (BlackoutCalendar, error) {
+	row := q.queryRow(ctx, q.insertBlackoutCalendarStmt, insertBlackoutCalendar, arg.Name, arg.CreatedBy)
+	var i BlackoutCalendar
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const insertBlackoutWindow = `-- name: InsertBlackoutWindow :one
+INSERT INTO blackout_windows (calendar_id, start_date, end_date, weekdays, start_time, end_time, note)
+VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id, calendar_id, start_date, end_date, weekdays, start_time, end_time, note
+`
+
+type InsertBlackoutWindowParams struct {
+	CalendarID int64
+	StartDate  string
+	EndDate    string
+	Weekdays   string
+	StartTime  string
+	EndTime    string
+	Note       string
+}
+
+func (q *Queries) InsertBlackoutWindow(ctx context.Context, arg InsertBlackoutWindowParams) (BlackoutWindow, error) {
+	row := q.queryRow(ctx, q.insertBlackoutWindowStmt, insertBlackoutWindow,
+		arg.CalendarID,
+		arg.StartDate,
+		arg.EndDate,
+		arg.Weekdays,
+		arg.StartTime,
+		arg.EndTime,
+		arg.Note,
+	)
+	var i BlackoutWindow
+	err := row.Scan(
+		&i.ID,
+		&i.CalendarID,
+		&i.StartDate,
+		&i.EndDate,
+		&i.Weekdays,
+		&i.StartTime,
+		&i.EndTime,
+		&i.Note,
+	)
+	return i, err
+}
+
+const insertTaskBlackoutCalendar = `-- name: InsertTaskBlackoutCalendar :exec
+INSERT OR IGNORE INTO task_blackout_calendars (task_id, calendar_id) VALUES (?, ?)
+`
+
+type InsertTaskBlackoutCalendarParams struct {
+	TaskID     uuid.UUID
+	CalendarID int64
+}
+
+func (q *Queries) InsertTaskBlackoutCalendar(ctx context.Context, arg InsertTaskBlackoutCalendarParams) error {
+	_, err := q.exec(ctx, q.insertTaskBlackoutCalendarStmt, insertTaskBlackoutCalendar, arg.TaskID, arg.CalendarID)
+	return err
+}
+
+const listBlackoutCalendars = `-- name: ListBlackoutCalendars :many
+SELECT c.id, c.name, c.created_at, u.username AS created_by_username
+FROM blackout_calendars c
+         LEFT JOIN users u ON c.created_by = u.ID
+ORDER BY c.name
+`
+
+type ListBlackoutCalendarsRow struct {
+	ID                int64
+	Name              string
+	CreatedAt         time.Time
+	CreatedByUsername sql.NullString
+}
+
+func (q *Queries) ListBlackoutCalendars(ctx context.Context) ([]ListBlackoutCalendarsRow, error) {
+	rows, err := q.query(ctx, q.listBlackoutCalendarsStmt, listBlackoutCalendars)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBlackoutCalendarsRow
+	for rows.Next() {
+		var i ListBlackoutCalendarsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.CreatedByUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBlackoutCalendarsForTask = `-- name: ListBlackoutCalendarsForTask :many
+SELECT c.name FROM task_blackout_calendars tc JOIN blackout_calendars c ON tc.calendar_id = c.id
+WHERE tc.task_id = ?
+ORDER BY c.name
+`
+
+func (q *Queries) ListBlackoutCalendarsForTask(ctx context.Context, taskID uuid.UUID) ([]string, error) {
+	rows, err := q.query(ctx, q.listBlackoutCalendarsForTaskStmt, listBlackoutCalendarsForTask, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBlackoutWindows = `-- name: ListBlackoutWindows :many
+SELECT id, calendar_id, start_date, end_date, weekdays, start_time, end_time, note FROM blackout_windows ORDER BY calendar_id, id
+`
+
+func (q *Queries) ListBlackoutWindows(ctx context.Context) ([]BlackoutWindow, error) {
+	rows, err := q.query(ctx, q.listBlackoutWindowsStmt, listBlackoutWindows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BlackoutWindow
+	for rows.Next() {
+		var i BlackoutWindow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CalendarID,
+			&i.StartDate,
+			&i.EndDate,
+			&i.Weekdays,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Note,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBlackoutWindowsForTask = `-- name: ListBlackoutWindowsForTask :many
+SELECT w.id, w.calendar_id, w.start_date, w.end_date, w.weekdays, w.start_time, w.end_time, w.note, c.name AS calendar_name
+FROM task_blackout_calendars tc
+         JOIN blackout_calendars c ON tc.calendar_id = c.id
+         JOIN blackout_windows w ON w.calendar_id = c.id
+WHERE tc.task_id = ?
+ORDER BY c.name, w.id
+`
+
+type ListBlackoutWindowsForTaskRow struct {
+	ID           int64
+	CalendarID   int64
+	StartDate    string
+	EndDate      string
+	Weekdays     string
+	StartTime    string
+	EndTime      string
+	Note         string
+	CalendarName string
+}
+
+func (q *Queries) ListBlackoutWindowsForTask(ctx context.Context, taskID uuid.UUID) ([]ListBlackoutWindowsForTaskRow, error) {
+	rows, err := q.query(ctx, q.listBlackoutWindowsForTaskStmt, listBlackoutWindowsForTask, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBlackoutWindowsForTaskRow
+	for rows.Next() {
+		var i ListBlackoutWindowsForTaskRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CalendarID,
+			&i.StartDate,
+			&i.EndDate,
+			&i.Weekdays,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Note,
+			&i.CalendarName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTaskBlackoutCalendars = `-- name: ListTaskBlackoutCalendars :many
+SELECT calendar_id FROM task_blackout_calendars WHERE task_id = ? ORDER BY calendar_id
+`
+
+func (q *Queries) ListTaskBlackoutCalendars(ctx context.Context, taskID uuid.UUID) ([]int64, error) {
+	rows, err := q.query(ctx, q.listTaskBlackoutCalendarsStmt, listTaskBlackoutCalendars, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var calendar_id int64
+		if err := rows.Scan(&calendar_id); err != nil {
+			return nil, err
+		}
+		items = append(items, calendar_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
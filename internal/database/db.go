@@ -24,33 +24,159 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.acquireSchedulerLeaseStmt, err = db.PrepareContext(ctx, acquireSchedulerLease); err != nil {
+		return nil, fmt.Errorf("error preparing query AcquireSchedulerLease: %w", err)
+	}
+	if q.advanceTaskRoundRobinStmt, err = db.PrepareContext(ctx, advanceTaskRoundRobin); err != nil {
+		return nil, fmt.Errorf("error preparing query AdvanceTaskRoundRobin: %w", err)
+	}
 	if q.checkSettingsExistStmt, err = db.PrepareContext(ctx, checkSettingsExist); err != nil {
 		return nil, fmt.Errorf("error preparing query CheckSettingsExist: %w", err)
 	}
+	if q.claimScheduledRunStmt, err = db.PrepareContext(ctx, claimScheduledRun); err != nil {
+		return nil, fmt.Errorf("error preparing query ClaimScheduledRun: %w", err)
+	}
+	if q.countTaskRunsStmt, err = db.PrepareContext(ctx, countTaskRuns); err != nil {
+		return nil, fmt.Errorf("error preparing query CountTaskRuns: %w", err)
+	}
+	if q.countUnusedRecoveryCodesStmt, err = db.PrepareContext(ctx, countUnusedRecoveryCodes); err != nil {
+		return nil, fmt.Errorf("error preparing query CountUnusedRecoveryCodes: %w", err)
+	}
 	if q.createNewUserStmt, err = db.PrepareContext(ctx, createNewUser); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateNewUser: %w", err)
 	}
+	if q.deleteAccessGrantStmt, err = db.PrepareContext(ctx, deleteAccessGrant); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteAccessGrant: %w", err)
+	}
+	if q.deleteAccessGrantsForRoleStmt, err = db.PrepareContext(ctx, deleteAccessGrantsForRole); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteAccessGrantsForRole: %w", err)
+	}
+	if q.deleteAccessGrantsForUserStmt, err = db.PrepareContext(ctx, deleteAccessGrantsForUser); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteAccessGrantsForUser: %w", err)
+	}
+	if q.deleteBlackoutCalendarStmt, err = db.PrepareContext(ctx, deleteBlackoutCalendar); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteBlackoutCalendar: %w", err)
+	}
+	if q.deleteBlackoutWindowStmt, err = db.PrepareContext(ctx, deleteBlackoutWindow); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteBlackoutWindow: %w", err)
+	}
+	if q.deleteNodeGroupStmt, err = db.PrepareContext(ctx, deleteNodeGroup); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteNodeGroup: %w", err)
+	}
+	if q.deleteNodeGroupMembersStmt, err = db.PrepareContext(ctx, deleteNodeGroupMembers); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteNodeGroupMembers: %w", err)
+	}
+	if q.deleteProjectMaxRuntimeStmt, err = db.PrepareContext(ctx, deleteProjectMaxRuntime); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteProjectMaxRuntime: %w", err)
+	}
+	if q.deleteRecoveryCodesForUserStmt, err = db.PrepareContext(ctx, deleteRecoveryCodesForUser); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteRecoveryCodesForUser: %w", err)
+	}
+	if q.deleteScheduledRunStmt, err = db.PrepareContext(ctx, deleteScheduledRun); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteScheduledRun: %w", err)
+	}
+	if q.deleteScheduledRunNodesStmt, err = db.PrepareContext(ctx, deleteScheduledRunNodes); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteScheduledRunNodes: %w", err)
+	}
 	if q.deleteScrapydNodesStmt, err = db.PrepareContext(ctx, deleteScrapydNodes); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteScrapydNodes: %w", err)
 	}
+	if q.deleteTaskBlackoutCalendarsStmt, err = db.PrepareContext(ctx, deleteTaskBlackoutCalendars); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteTaskBlackoutCalendars: %w", err)
+	}
+	if q.deleteTaskDependencyStmt, err = db.PrepareContext(ctx, deleteTaskDependency); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteTaskDependency: %w", err)
+	}
+	if q.deleteTaskTargetsStmt, err = db.PrepareContext(ctx, deleteTaskTargets); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteTaskTargets: %w", err)
+	}
 	if q.deleteTaskWhereUUIDStmt, err = db.PrepareContext(ctx, deleteTaskWhereUUID); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteTaskWhereUUID: %w", err)
 	}
 	if q.deleteUserByUUIDStmt, err = db.PrepareContext(ctx, deleteUserByUUID); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteUserByUUID: %w", err)
 	}
+	if q.deleteWebhookForTaskStmt, err = db.PrepareContext(ctx, deleteWebhookForTask); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteWebhookForTask: %w", err)
+	}
+	if q.deleteWebhookNoncesSeenBeforeStmt, err = db.PrepareContext(ctx, deleteWebhookNoncesSeenBefore); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteWebhookNoncesSeenBefore: %w", err)
+	}
+	if q.enableUserTOTPStmt, err = db.PrepareContext(ctx, enableUserTOTP); err != nil {
+		return nil, fmt.Errorf("error preparing query EnableUserTOTP: %w", err)
+	}
+	if q.endMaintenanceModeStmt, err = db.PrepareContext(ctx, endMaintenanceMode); err != nil {
+		return nil, fmt.Errorf("error preparing query EndMaintenanceMode: %w", err)
+	}
+	if q.failQueuedJobsStmt, err = db.PrepareContext(ctx, failQueuedJobs); err != nil {
+		return nil, fmt.Errorf("error preparing query FailQueuedJobs: %w", err)
+	}
+	if q.finishScheduledRunStmt, err = db.PrepareContext(ctx, finishScheduledRun); err != nil {
+		return nil, fmt.Errorf("error preparing query FinishScheduledRun: %w", err)
+	}
+	if q.finishTaskRunStmt, err = db.PrepareContext(ctx, finishTaskRun); err != nil {
+		return nil, fmt.Errorf("error preparing query FinishTaskRun: %w", err)
+	}
+	if q.getAPITokenWithHashStmt, err = db.PrepareContext(ctx, getAPITokenWithHash); err != nil {
+		return nil, fmt.Errorf("error preparing query GetAPITokenWithHash: %w", err)
+	}
+	if q.getAccessRestrictionStmt, err = db.PrepareContext(ctx, getAccessRestriction); err != nil {
+		return nil, fmt.Errorf("error preparing query GetAccessRestriction: %w", err)
+	}
 	if q.getAllUsersStmt, err = db.PrepareContext(ctx, getAllUsers); err != nil {
 		return nil, fmt.Errorf("error preparing query GetAllUsers: %w", err)
 	}
+	if q.getBlackoutCalendarStmt, err = db.PrepareContext(ctx, getBlackoutCalendar); err != nil {
+		return nil, fmt.Errorf("error preparing query GetBlackoutCalendar: %w", err)
+	}
+	if q.getBlackoutCalendarByNameStmt, err = db.PrepareContext(ctx, getBlackoutCalendarByName); err != nil {
+		return nil, fmt.Errorf("error preparing query GetBlackoutCalendarByName: %w", err)
+	}
 	if q.getJobsForNodeStmt, err = db.PrepareContext(ctx, getJobsForNode); err != nil {
 		return nil, fmt.Errorf("error preparing query GetJobsForNode: %w", err)
 	}
+	if q.getLastFinishedJobForTaskStmt, err = db.PrepareContext(ctx, getLastFinishedJobForTask); err != nil {
+		return nil, fmt.Errorf("error preparing query GetLastFinishedJobForTask: %w", err)
+	}
+	if q.getLatestJobForTaskStmt, err = db.PrepareContext(ctx, getLatestJobForTask); err != nil {
+		return nil, fmt.Errorf("error preparing query GetLatestJobForTask: %w", err)
+	}
+	if q.getMaintenanceModeStmt, err = db.PrepareContext(ctx, getMaintenanceMode); err != nil {
+		return nil, fmt.Errorf("error preparing query GetMaintenanceMode: %w", err)
+	}
+	if q.getNodeForJobStmt, err = db.PrepareContext(ctx, getNodeForJob); err != nil {
+		return nil, fmt.Errorf("error preparing query GetNodeForJob: %w", err)
+	}
+	if q.getNodeGroupStmt, err = db.PrepareContext(ctx, getNodeGroup); err != nil {
+		return nil, fmt.Errorf("error preparing query GetNodeGroup: %w", err)
+	}
+	if q.getNodeGroupByNameStmt, err = db.PrepareContext(ctx, getNodeGroupByName); err != nil {
+		return nil, fmt.Errorf("error preparing query GetNodeGroupByName: %w", err)
+	}
 	if q.getNodeWithNameStmt, err = db.PrepareContext(ctx, getNodeWithName); err != nil {
 		return nil, fmt.Errorf("error preparing query GetNodeWithName: %w", err)
 	}
+	if q.getProjectAndNodeForJobStmt, err = db.PrepareContext(ctx, getProjectAndNodeForJob); err != nil {
+		return nil, fmt.Errorf("error preparing query GetProjectAndNodeForJob: %w", err)
+	}
+	if q.getRoleWithNameStmt, err = db.PrepareContext(ctx, getRoleWithName); err != nil {
+		return nil, fmt.Errorf("error preparing query GetRoleWithName: %w", err)
+	}
+	if q.getScheduledRunStmt, err = db.PrepareContext(ctx, getScheduledRun); err != nil {
+		return nil, fmt.Errorf("error preparing query GetScheduledRun: %w", err)
+	}
+	if q.getSchedulerLeaseStmt, err = db.PrepareContext(ctx, getSchedulerLease); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSchedulerLease: %w", err)
+	}
 	if q.getSettingsStmt, err = db.PrepareContext(ctx, getSettings); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSettings: %w", err)
 	}
+	if q.getTaskDependencyStmt, err = db.PrepareContext(ctx, getTaskDependency); err != nil {
+		return nil, fmt.Errorf("error preparing query GetTaskDependency: %w", err)
+	}
+	if q.getTaskRevisionStmt, err = db.PrepareContext(ctx, getTaskRevision); err != nil {
+		return nil, fmt.Errorf("error preparing query GetTaskRevision: %w", err)
+	}
 	if q.getTaskWithUUIDStmt, err = db.PrepareContext(ctx, getTaskWithUUID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetTaskWithUUID: %w", err)
 	}
@@ -69,21 +195,189 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getUserWithIDStmt, err = db.PrepareContext(ctx, getUserWithID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetUserWithID: %w", err)
 	}
+	if q.getWebhookForTaskStmt, err = db.PrepareContext(ctx, getWebhookForTask); err != nil {
+		return nil, fmt.Errorf("error preparing query GetWebhookForTask: %w", err)
+	}
+	if q.insertAPITokenStmt, err = db.PrepareContext(ctx, insertAPIToken); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertAPIToken: %w", err)
+	}
+	if q.insertAccessGrantStmt, err = db.PrepareContext(ctx, insertAccessGrant); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertAccessGrant: %w", err)
+	}
+	if q.insertBlackoutCalendarStmt, err = db.PrepareContext(ctx, insertBlackoutCalendar); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertBlackoutCalendar: %w", err)
+	}
+	if q.insertBlackoutWindowStmt, err = db.PrepareContext(ctx, insertBlackoutWindow); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertBlackoutWindow: %w", err)
+	}
 	if q.insertJobStmt, err = db.PrepareContext(ctx, insertJob); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertJob: %w", err)
 	}
+	if q.insertNodeGroupStmt, err = db.PrepareContext(ctx, insertNodeGroup); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertNodeGroup: %w", err)
+	}
+	if q.insertNodeGroupMemberStmt, err = db.PrepareContext(ctx, insertNodeGroupMember); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertNodeGroupMember: %w", err)
+	}
+	if q.insertRecoveryCodeStmt, err = db.PrepareContext(ctx, insertRecoveryCode); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertRecoveryCode: %w", err)
+	}
+	if q.insertScheduledRunStmt, err = db.PrepareContext(ctx, insertScheduledRun); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertScheduledRun: %w", err)
+	}
+	if q.insertScheduledRunNodeStmt, err = db.PrepareContext(ctx, insertScheduledRunNode); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertScheduledRunNode: %w", err)
+	}
 	if q.insertSettingsStmt, err = db.PrepareContext(ctx, insertSettings); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertSettings: %w", err)
 	}
 	if q.insertTaskStmt, err = db.PrepareContext(ctx, insertTask); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertTask: %w", err)
 	}
+	if q.insertTaskBlackoutCalendarStmt, err = db.PrepareContext(ctx, insertTaskBlackoutCalendar); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertTaskBlackoutCalendar: %w", err)
+	}
+	if q.insertTaskDependencyStmt, err = db.PrepareContext(ctx, insertTaskDependency); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertTaskDependency: %w", err)
+	}
+	if q.insertTaskRevisionStmt, err = db.PrepareContext(ctx, insertTaskRevision); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertTaskRevision: %w", err)
+	}
+	if q.insertTaskRunStmt, err = db.PrepareContext(ctx, insertTaskRun); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertTaskRun: %w", err)
+	}
+	if q.insertTaskTargetStmt, err = db.PrepareContext(ctx, insertTaskTarget); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertTaskTarget: %w", err)
+	}
+	if q.insertWebhookNonceStmt, err = db.PrepareContext(ctx, insertWebhookNonce); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertWebhookNonce: %w", err)
+	}
+	if q.listAPITokensForUserStmt, err = db.PrepareContext(ctx, listAPITokensForUser); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAPITokensForUser: %w", err)
+	}
+	if q.listAccessGrantsStmt, err = db.PrepareContext(ctx, listAccessGrants); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAccessGrants: %w", err)
+	}
+	if q.listAccessGrantsForUserStmt, err = db.PrepareContext(ctx, listAccessGrantsForUser); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAccessGrantsForUser: %w", err)
+	}
+	if q.listActiveJobsForTaskStmt, err = db.PrepareContext(ctx, listActiveJobsForTask); err != nil {
+		return nil, fmt.Errorf("error preparing query ListActiveJobsForTask: %w", err)
+	}
+	if q.listAllScheduledRunNodesStmt, err = db.PrepareContext(ctx, listAllScheduledRunNodes); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAllScheduledRunNodes: %w", err)
+	}
+	if q.listBlackoutCalendarsStmt, err = db.PrepareContext(ctx, listBlackoutCalendars); err != nil {
+		return nil, fmt.Errorf("error preparing query ListBlackoutCalendars: %w", err)
+	}
+	if q.listBlackoutCalendarsForTaskStmt, err = db.PrepareContext(ctx, listBlackoutCalendarsForTask); err != nil {
+		return nil, fmt.Errorf("error preparing query ListBlackoutCalendarsForTask: %w", err)
+	}
+	if q.listBlackoutWindowsStmt, err = db.PrepareContext(ctx, listBlackoutWindows); err != nil {
+		return nil, fmt.Errorf("error preparing query ListBlackoutWindows: %w", err)
+	}
+	if q.listBlackoutWindowsForTaskStmt, err = db.PrepareContext(ctx, listBlackoutWindowsForTask); err != nil {
+		return nil, fmt.Errorf("error preparing query ListBlackoutWindowsForTask: %w", err)
+	}
+	if q.listDependenciesForTaskStmt, err = db.PrepareContext(ctx, listDependenciesForTask); err != nil {
+		return nil, fmt.Errorf("error preparing query ListDependenciesForTask: %w", err)
+	}
+	if q.listDependenciesWaitingOnJobStmt, err = db.PrepareContext(ctx, listDependenciesWaitingOnJob); err != nil {
+		return nil, fmt.Errorf("error preparing query ListDependenciesWaitingOnJob: %w", err)
+	}
+	if q.listDependencyRunsStmt, err = db.PrepareContext(ctx, listDependencyRuns); err != nil {
+		return nil, fmt.Errorf("error preparing query ListDependencyRuns: %w", err)
+	}
+	if q.listDueScheduledRunsStmt, err = db.PrepareContext(ctx, listDueScheduledRuns); err != nil {
+		return nil, fmt.Errorf("error preparing query ListDueScheduledRuns: %w", err)
+	}
+	if q.listNodeGroupMembersStmt, err = db.PrepareContext(ctx, listNodeGroupMembers); err != nil {
+		return nil, fmt.Errorf("error preparing query ListNodeGroupMembers: %w", err)
+	}
+	if q.listNodeGroupsStmt, err = db.PrepareContext(ctx, listNodeGroups); err != nil {
+		return nil, fmt.Errorf("error preparing query ListNodeGroups: %w", err)
+	}
+	if q.listNodesInGroupStmt, err = db.PrepareContext(ctx, listNodesInGroup); err != nil {
+		return nil, fmt.Errorf("error preparing query ListNodesInGroup: %w", err)
+	}
+	if q.listPermissionsForRoleStmt, err = db.PrepareContext(ctx, listPermissionsForRole); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPermissionsForRole: %w", err)
+	}
+	if q.listProjectMaxRuntimesStmt, err = db.PrepareContext(ctx, listProjectMaxRuntimes); err != nil {
+		return nil, fmt.Errorf("error preparing query ListProjectMaxRuntimes: %w", err)
+	}
+	if q.listResolvedTaskNodesStmt, err = db.PrepareContext(ctx, listResolvedTaskNodes); err != nil {
+		return nil, fmt.Errorf("error preparing query ListResolvedTaskNodes: %w", err)
+	}
+	if q.listRolesStmt, err = db.PrepareContext(ctx, listRoles); err != nil {
+		return nil, fmt.Errorf("error preparing query ListRoles: %w", err)
+	}
+	if q.listRunningJobsWithMaxRuntimeStmt, err = db.PrepareContext(ctx, listRunningJobsWithMaxRuntime); err != nil {
+		return nil, fmt.Errorf("error preparing query ListRunningJobsWithMaxRuntime: %w", err)
+	}
+	if q.listScheduledRunNodesStmt, err = db.PrepareContext(ctx, listScheduledRunNodes); err != nil {
+		return nil, fmt.Errorf("error preparing query ListScheduledRunNodes: %w", err)
+	}
+	if q.listScheduledRunsStmt, err = db.PrepareContext(ctx, listScheduledRuns); err != nil {
+		return nil, fmt.Errorf("error preparing query ListScheduledRuns: %w", err)
+	}
 	if q.listScrapydNodesStmt, err = db.PrepareContext(ctx, listScrapydNodes); err != nil {
 		return nil, fmt.Errorf("error preparing query ListScrapydNodes: %w", err)
 	}
+	if q.listTargetsForTaskStmt, err = db.PrepareContext(ctx, listTargetsForTask); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTargetsForTask: %w", err)
+	}
+	if q.listTaskBlackoutCalendarsStmt, err = db.PrepareContext(ctx, listTaskBlackoutCalendars); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTaskBlackoutCalendars: %w", err)
+	}
+	if q.listTaskDependenciesStmt, err = db.PrepareContext(ctx, listTaskDependencies); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTaskDependencies: %w", err)
+	}
+	if q.listTaskRevisionsStmt, err = db.PrepareContext(ctx, listTaskRevisions); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTaskRevisions: %w", err)
+	}
+	if q.listTaskRunsStmt, err = db.PrepareContext(ctx, listTaskRuns); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTaskRuns: %w", err)
+	}
+	if q.listTaskTargetNodesStmt, err = db.PrepareContext(ctx, listTaskTargetNodes); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTaskTargetNodes: %w", err)
+	}
 	if q.newScrapydNodeStmt, err = db.PrepareContext(ctx, newScrapydNode); err != nil {
 		return nil, fmt.Errorf("error preparing query NewScrapydNode: %w", err)
 	}
+	if q.pruneTaskRunsStmt, err = db.PrepareContext(ctx, pruneTaskRuns); err != nil {
+		return nil, fmt.Errorf("error preparing query PruneTaskRuns: %w", err)
+	}
+	if q.queryJobsStmt, err = db.PrepareContext(ctx, queryJobs); err != nil {
+		return nil, fmt.Errorf("error preparing query QueryJobs: %w", err)
+	}
+	if q.queueJobStmt, err = db.PrepareContext(ctx, queueJob); err != nil {
+		return nil, fmt.Errorf("error preparing query QueueJob: %w", err)
+	}
+	if q.recordTwoFactorFailureStmt, err = db.PrepareContext(ctx, recordTwoFactorFailure); err != nil {
+		return nil, fmt.Errorf("error preparing query RecordTwoFactorFailure: %w", err)
+	}
+	if q.releaseSchedulerLeaseStmt, err = db.PrepareContext(ctx, releaseSchedulerLease); err != nil {
+		return nil, fmt.Errorf("error preparing query ReleaseSchedulerLease: %w", err)
+	}
+	if q.renewQueuedJobStmt, err = db.PrepareContext(ctx, renewQueuedJob); err != nil {
+		return nil, fmt.Errorf("error preparing query RenewQueuedJob: %w", err)
+	}
+	if q.resetTaskDependenciesStmt, err = db.PrepareContext(ctx, resetTaskDependencies); err != nil {
+		return nil, fmt.Errorf("error preparing query ResetTaskDependencies: %w", err)
+	}
+	if q.resetTwoFactorFailuresStmt, err = db.PrepareContext(ctx, resetTwoFactorFailures); err != nil {
+		return nil, fmt.Errorf("error preparing query ResetTwoFactorFailures: %w", err)
+	}
+	if q.resetUserTOTPStmt, err = db.PrepareContext(ctx, resetUserTOTP); err != nil {
+		return nil, fmt.Errorf("error preparing query ResetUserTOTP: %w", err)
+	}
+	if q.revokeAPITokenStmt, err = db.PrepareContext(ctx, revokeAPIToken); err != nil {
+		return nil, fmt.Errorf("error preparing query RevokeAPIToken: %w", err)
+	}
+	if q.scheduleQueuedJobStmt, err = db.PrepareContext(ctx, scheduleQueuedJob); err != nil {
+		return nil, fmt.Errorf("error preparing query ScheduleQueuedJob: %w", err)
+	}
 	if q.searchNodeJobsStmt, err = db.PrepareContext(ctx, searchNodeJobs); err != nil {
 		return nil, fmt.Errorf("error preparing query SearchNodeJobs: %w", err)
 	}
@@ -93,24 +387,63 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.setErrorWhereJobIdStmt, err = db.PrepareContext(ctx, setErrorWhereJobId); err != nil {
 		return nil, fmt.Errorf("error preparing query SetErrorWhereJobId: %w", err)
 	}
+	if q.setJobAttemptStmt, err = db.PrepareContext(ctx, setJobAttempt); err != nil {
+		return nil, fmt.Errorf("error preparing query SetJobAttempt: %w", err)
+	}
+	if q.setJobKilledStmt, err = db.PrepareContext(ctx, setJobKilled); err != nil {
+		return nil, fmt.Errorf("error preparing query SetJobKilled: %w", err)
+	}
+	if q.setJobSpiderArgsStmt, err = db.PrepareContext(ctx, setJobSpiderArgs); err != nil {
+		return nil, fmt.Errorf("error preparing query SetJobSpiderArgs: %w", err)
+	}
+	if q.setJobStatusStmt, err = db.PrepareContext(ctx, setJobStatus); err != nil {
+		return nil, fmt.Errorf("error preparing query SetJobStatus: %w", err)
+	}
+	if q.setJobTimedOutStmt, err = db.PrepareContext(ctx, setJobTimedOut); err != nil {
+		return nil, fmt.Errorf("error preparing query SetJobTimedOut: %w", err)
+	}
+	if q.setRoleAccessRestrictedStmt, err = db.PrepareContext(ctx, setRoleAccessRestricted); err != nil {
+		return nil, fmt.Errorf("error preparing query SetRoleAccessRestricted: %w", err)
+	}
 	if q.setStoppedByOnJobStmt, err = db.PrepareContext(ctx, setStoppedByOnJob); err != nil {
 		return nil, fmt.Errorf("error preparing query SetStoppedByOnJob: %w", err)
 	}
+	if q.setTaskDependencyUpstreamJobStmt, err = db.PrepareContext(ctx, setTaskDependencyUpstreamJob); err != nil {
+		return nil, fmt.Errorf("error preparing query SetTaskDependencyUpstreamJob: %w", err)
+	}
+	if q.setUserAccessRestrictedStmt, err = db.PrepareContext(ctx, setUserAccessRestricted); err != nil {
+		return nil, fmt.Errorf("error preparing query SetUserAccessRestricted: %w", err)
+	}
+	if q.setUserTOTPSecretStmt, err = db.PrepareContext(ctx, setUserTOTPSecret); err != nil {
+		return nil, fmt.Errorf("error preparing query SetUserTOTPSecret: %w", err)
+	}
 	if q.softDeleteJobStmt, err = db.PrepareContext(ctx, softDeleteJob); err != nil {
 		return nil, fmt.Errorf("error preparing query SoftDeleteJob: %w", err)
 	}
 	if q.startFinishRuntimeLogsItemsForJobWithJobIDStmt, err = db.PrepareContext(ctx, startFinishRuntimeLogsItemsForJobWithJobID); err != nil {
 		return nil, fmt.Errorf("error preparing query StartFinishRuntimeLogsItemsForJobWithJobID: %w", err)
 	}
+	if q.startMaintenanceModeStmt, err = db.PrepareContext(ctx, startMaintenanceMode); err != nil {
+		return nil, fmt.Errorf("error preparing query StartMaintenanceMode: %w", err)
+	}
+	if q.updateAPITokenLastUsedStmt, err = db.PrepareContext(ctx, updateAPITokenLastUsed); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateAPITokenLastUsed: %w", err)
+	}
 	if q.updateNodeWhereNameStmt, err = db.PrepareContext(ctx, updateNodeWhereName); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateNodeWhereName: %w", err)
 	}
+	if q.updateScheduledRunStmt, err = db.PrepareContext(ctx, updateScheduledRun); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateScheduledRun: %w", err)
+	}
 	if q.updateSettingsStmt, err = db.PrepareContext(ctx, updateSettings); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateSettings: %w", err)
 	}
 	if q.updateTaskStmt, err = db.PrepareContext(ctx, updateTask); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateTask: %w", err)
 	}
+	if q.updateTaskLastFiredAtStmt, err = db.PrepareContext(ctx, updateTaskLastFiredAt); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateTaskLastFiredAt: %w", err)
+	}
 	if q.updateTaskPausedStmt, err = db.PrepareContext(ctx, updateTaskPaused); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateTaskPaused: %w", err)
 	}
@@ -120,26 +453,136 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.updateUsersPasswordWhereIDStmt, err = db.PrepareContext(ctx, updateUsersPasswordWhereID); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateUsersPasswordWhereID: %w", err)
 	}
+	if q.updateWebhookLastFiredStmt, err = db.PrepareContext(ctx, updateWebhookLastFired); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateWebhookLastFired: %w", err)
+	}
+	if q.upsertProjectMaxRuntimeStmt, err = db.PrepareContext(ctx, upsertProjectMaxRuntime); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertProjectMaxRuntime: %w", err)
+	}
+	if q.upsertTaskWebhookStmt, err = db.PrepareContext(ctx, upsertTaskWebhook); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertTaskWebhook: %w", err)
+	}
+	if q.useRecoveryCodeStmt, err = db.PrepareContext(ctx, useRecoveryCode); err != nil {
+		return nil, fmt.Errorf("error preparing query UseRecoveryCode: %w", err)
+	}
+	if q.useTOTPStepStmt, err = db.PrepareContext(ctx, useTOTPStep); err != nil {
+		return nil, fmt.Errorf("error preparing query UseTOTPStep: %w", err)
+	}
 	return &q, nil
 }
 
 func (q *Queries) Close() error {
 	var err error
+	if q.acquireSchedulerLeaseStmt != nil {
+		if cerr := q.acquireSchedulerLeaseStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing acquireSchedulerLeaseStmt: %w", cerr)
+		}
+	}
+	if q.advanceTaskRoundRobinStmt != nil {
+		if cerr := q.advanceTaskRoundRobinStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing advanceTaskRoundRobinStmt: %w", cerr)
+		}
+	}
 	if q.checkSettingsExistStmt != nil {
 		if cerr := q.checkSettingsExistStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing checkSettingsExistStmt: %w", cerr)
 		}
 	}
+	if q.claimScheduledRunStmt != nil {
+		if cerr := q.claimScheduledRunStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing claimScheduledRunStmt: %w", cerr)
+		}
+	}
+	if q.countTaskRunsStmt != nil {
+		if cerr := q.countTaskRunsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countTaskRunsStmt: %w", cerr)
+		}
+	}
+	if q.countUnusedRecoveryCodesStmt != nil {
+		if cerr := q.countUnusedRecoveryCodesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countUnusedRecoveryCodesStmt: %w", cerr)
+		}
+	}
 	if q.createNewUserStmt != nil {
 		if cerr := q.createNewUserStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createNewUserStmt: %w", cerr)
 		}
 	}
+	if q.deleteAccessGrantStmt != nil {
+		if cerr := q.deleteAccessGrantStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteAccessGrantStmt: %w", cerr)
+		}
+	}
+	if q.deleteAccessGrantsForRoleStmt != nil {
+		if cerr := q.deleteAccessGrantsForRoleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteAccessGrantsForRoleStmt: %w", cerr)
+		}
+	}
+	if q.deleteAccessGrantsForUserStmt != nil {
+		if cerr := q.deleteAccessGrantsForUserStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteAccessGrantsForUserStmt: %w", cerr)
+		}
+	}
+	if q.deleteBlackoutCalendarStmt != nil {
+		if cerr := q.deleteBlackoutCalendarStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteBlackoutCalendarStmt: %w", cerr)
+		}
+	}
+	if q.deleteBlackoutWindowStmt != nil {
+		if cerr := q.deleteBlackoutWindowStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteBlackoutWindowStmt: %w", cerr)
+		}
+	}
+	if q.deleteNodeGroupStmt != nil {
+		if cerr := q.deleteNodeGroupStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteNodeGroupStmt: %w", cerr)
+		}
+	}
+	if q.deleteNodeGroupMembersStmt != nil {
+		if cerr := q.deleteNodeGroupMembersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteNodeGroupMembersStmt: %w", cerr)
+		}
+	}
+	if q.deleteProjectMaxRuntimeStmt != nil {
+		if cerr := q.deleteProjectMaxRuntimeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteProjectMaxRuntimeStmt: %w", cerr)
+		}
+	}
+	if q.deleteRecoveryCodesForUserStmt != nil {
+		if cerr := q.deleteRecoveryCodesForUserStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteRecoveryCodesForUserStmt: %w", cerr)
+		}
+	}
+	if q.deleteScheduledRunStmt != nil {
+		if cerr := q.deleteScheduledRunStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteScheduledRunStmt: %w", cerr)
+		}
+	}
+	if q.deleteScheduledRunNodesStmt != nil {
+		if cerr := q.deleteScheduledRunNodesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteScheduledRunNodesStmt: %w", cerr)
+		}
+	}
 	if q.deleteScrapydNodesStmt != nil {
 		if cerr := q.deleteScrapydNodesStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteScrapydNodesStmt: %w", cerr)
 		}
 	}
+	if q.deleteTaskBlackoutCalendarsStmt != nil {
+		if cerr := q.deleteTaskBlackoutCalendarsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteTaskBlackoutCalendarsStmt: %w", cerr)
+		}
+	}
+	if q.deleteTaskDependencyStmt != nil {
+		if cerr := q.deleteTaskDependencyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteTaskDependencyStmt: %w", cerr)
+		}
+	}
+	if q.deleteTaskTargetsStmt != nil {
+		if cerr := q.deleteTaskTargetsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteTaskTargetsStmt: %w", cerr)
+		}
+	}
 	if q.deleteTaskWhereUUIDStmt != nil {
 		if cerr := q.deleteTaskWhereUUIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteTaskWhereUUIDStmt: %w", cerr)
@@ -150,26 +593,141 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteUserByUUIDStmt: %w", cerr)
 		}
 	}
+	if q.deleteWebhookForTaskStmt != nil {
+		if cerr := q.deleteWebhookForTaskStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteWebhookForTaskStmt: %w", cerr)
+		}
+	}
+	if q.deleteWebhookNoncesSeenBeforeStmt != nil {
+		if cerr := q.deleteWebhookNoncesSeenBeforeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteWebhookNoncesSeenBeforeStmt: %w", cerr)
+		}
+	}
+	if q.enableUserTOTPStmt != nil {
+		if cerr := q.enableUserTOTPStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing enableUserTOTPStmt: %w", cerr)
+		}
+	}
+	if q.endMaintenanceModeStmt != nil {
+		if cerr := q.endMaintenanceModeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing endMaintenanceModeStmt: %w", cerr)
+		}
+	}
+	if q.failQueuedJobsStmt != nil {
+		if cerr := q.failQueuedJobsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing failQueuedJobsStmt: %w", cerr)
+		}
+	}
+	if q.finishScheduledRunStmt != nil {
+		if cerr := q.finishScheduledRunStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing finishScheduledRunStmt: %w", cerr)
+		}
+	}
+	if q.finishTaskRunStmt != nil {
+		if cerr := q.finishTaskRunStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing finishTaskRunStmt: %w", cerr)
+		}
+	}
+	if q.getAPITokenWithHashStmt != nil {
+		if cerr := q.getAPITokenWithHashStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getAPITokenWithHashStmt: %w", cerr)
+		}
+	}
+	if q.getAccessRestrictionStmt != nil {
+		if cerr := q.getAccessRestrictionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getAccessRestrictionStmt: %w", cerr)
+		}
+	}
 	if q.getAllUsersStmt != nil {
 		if cerr := q.getAllUsersStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getAllUsersStmt: %w", cerr)
 		}
 	}
+	if q.getBlackoutCalendarStmt != nil {
+		if cerr := q.getBlackoutCalendarStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getBlackoutCalendarStmt: %w", cerr)
+		}
+	}
+	if q.getBlackoutCalendarByNameStmt != nil {
+		if cerr := q.getBlackoutCalendarByNameStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getBlackoutCalendarByNameStmt: %w", cerr)
+		}
+	}
 	if q.getJobsForNodeStmt != nil {
 		if cerr := q.getJobsForNodeStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getJobsForNodeStmt: %w", cerr)
 		}
 	}
+	if q.getLastFinishedJobForTaskStmt != nil {
+		if cerr := q.getLastFinishedJobForTaskStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getLastFinishedJobForTaskStmt: %w", cerr)
+		}
+	}
+	if q.getLatestJobForTaskStmt != nil {
+		if cerr := q.getLatestJobForTaskStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getLatestJobForTaskStmt: %w", cerr)
+		}
+	}
+	if q.getMaintenanceModeStmt != nil {
+		if cerr := q.getMaintenanceModeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getMaintenanceModeStmt: %w", cerr)
+		}
+	}
+	if q.getNodeForJobStmt != nil {
+		if cerr := q.getNodeForJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getNodeForJobStmt: %w", cerr)
+		}
+	}
+	if q.getNodeGroupStmt != nil {
+		if cerr := q.getNodeGroupStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getNodeGroupStmt: %w", cerr)
+		}
+	}
+	if q.getNodeGroupByNameStmt != nil {
+		if cerr := q.getNodeGroupByNameStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getNodeGroupByNameStmt: %w", cerr)
+		}
+	}
 	if q.getNodeWithNameStmt != nil {
 		if cerr := q.getNodeWithNameStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getNodeWithNameStmt: %w", cerr)
 		}
 	}
+	if q.getProjectAndNodeForJobStmt != nil {
+		if cerr := q.getProjectAndNodeForJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getProjectAndNodeForJobStmt: %w", cerr)
+		}
+	}
+	if q.getRoleWithNameStmt != nil {
+		if cerr := q.getRoleWithNameStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getRoleWithNameStmt: %w", cerr)
+		}
+	}
+	if q.getScheduledRunStmt != nil {
+		if cerr := q.getScheduledRunStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getScheduledRunStmt: %w", cerr)
+		}
+	}
+	if q.getSchedulerLeaseStmt != nil {
+		if cerr := q.getSchedulerLeaseStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSchedulerLeaseStmt: %w", cerr)
+		}
+	}
 	if q.getSettingsStmt != nil {
 		if cerr := q.getSettingsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getSettingsStmt: %w", cerr)
 		}
 	}
+	if q.getTaskDependencyStmt != nil {
+		if cerr := q.getTaskDependencyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getTaskDependencyStmt: %w", cerr)
+		}
+	}
+	if q.getTaskRevisionStmt != nil {
+		if cerr := q.getTaskRevisionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getTaskRevisionStmt: %w", cerr)
+		}
+	}
 	if q.getTaskWithUUIDStmt != nil {
 		if cerr := q.getTaskWithUUIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getTaskWithUUIDStmt: %w", cerr)
@@ -200,11 +758,61 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getUserWithIDStmt: %w", cerr)
 		}
 	}
+	if q.getWebhookForTaskStmt != nil {
+		if cerr := q.getWebhookForTaskStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getWebhookForTaskStmt: %w", cerr)
+		}
+	}
+	if q.insertAPITokenStmt != nil {
+		if cerr := q.insertAPITokenStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertAPITokenStmt: %w", cerr)
+		}
+	}
+	if q.insertAccessGrantStmt != nil {
+		if cerr := q.insertAccessGrantStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertAccessGrantStmt: %w", cerr)
+		}
+	}
+	if q.insertBlackoutCalendarStmt != nil {
+		if cerr := q.insertBlackoutCalendarStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertBlackoutCalendarStmt: %w", cerr)
+		}
+	}
+	if q.insertBlackoutWindowStmt != nil {
+		if cerr := q.insertBlackoutWindowStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertBlackoutWindowStmt: %w", cerr)
+		}
+	}
 	if q.insertJobStmt != nil {
 		if cerr := q.insertJobStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing insertJobStmt: %w", cerr)
 		}
 	}
+	if q.insertNodeGroupStmt != nil {
+		if cerr := q.insertNodeGroupStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertNodeGroupStmt: %w", cerr)
+		}
+	}
+	if q.insertNodeGroupMemberStmt != nil {
+		if cerr := q.insertNodeGroupMemberStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertNodeGroupMemberStmt: %w", cerr)
+		}
+	}
+	if q.insertRecoveryCodeStmt != nil {
+		if cerr := q.insertRecoveryCodeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertRecoveryCodeStmt: %w", cerr)
+		}
+	}
+	if q.insertScheduledRunStmt != nil {
+		if cerr := q.insertScheduledRunStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertScheduledRunStmt: %w", cerr)
+		}
+	}
+	if q.insertScheduledRunNodeStmt != nil {
+		if cerr := q.insertScheduledRunNodeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertScheduledRunNodeStmt: %w", cerr)
+		}
+	}
 	if q.insertSettingsStmt != nil {
 		if cerr := q.insertSettingsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing insertSettingsStmt: %w", cerr)
@@ -215,16 +823,246 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing insertTaskStmt: %w", cerr)
 		}
 	}
+	if q.insertTaskBlackoutCalendarStmt != nil {
+		if cerr := q.insertTaskBlackoutCalendarStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertTaskBlackoutCalendarStmt: %w", cerr)
+		}
+	}
+	if q.insertTaskDependencyStmt != nil {
+		if cerr := q.insertTaskDependencyStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertTaskDependencyStmt: %w", cerr)
+		}
+	}
+	if q.insertTaskRevisionStmt != nil {
+		if cerr := q.insertTaskRevisionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertTaskRevisionStmt: %w", cerr)
+		}
+	}
+	if q.insertTaskRunStmt != nil {
+		if cerr := q.insertTaskRunStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertTaskRunStmt: %w", cerr)
+		}
+	}
+	if q.insertTaskTargetStmt != nil {
+		if cerr := q.insertTaskTargetStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertTaskTargetStmt: %w", cerr)
+		}
+	}
+	if q.insertWebhookNonceStmt != nil {
+		if cerr := q.insertWebhookNonceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertWebhookNonceStmt: %w", cerr)
+		}
+	}
+	if q.listAPITokensForUserStmt != nil {
+		if cerr := q.listAPITokensForUserStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAPITokensForUserStmt: %w", cerr)
+		}
+	}
+	if q.listAccessGrantsStmt != nil {
+		if cerr := q.listAccessGrantsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAccessGrantsStmt: %w", cerr)
+		}
+	}
+	if q.listAccessGrantsForUserStmt != nil {
+		if cerr := q.listAccessGrantsForUserStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAccessGrantsForUserStmt: %w", cerr)
+		}
+	}
+	if q.listActiveJobsForTaskStmt != nil {
+		if cerr := q.listActiveJobsForTaskStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listActiveJobsForTaskStmt: %w", cerr)
+		}
+	}
+	if q.listAllScheduledRunNodesStmt != nil {
+		if cerr := q.listAllScheduledRunNodesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAllScheduledRunNodesStmt: %w", cerr)
+		}
+	}
+	if q.listBlackoutCalendarsStmt != nil {
+		if cerr := q.listBlackoutCalendarsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listBlackoutCalendarsStmt: %w", cerr)
+		}
+	}
+	if q.listBlackoutCalendarsForTaskStmt != nil {
+		if cerr := q.listBlackoutCalendarsForTaskStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listBlackoutCalendarsForTaskStmt: %w", cerr)
+		}
+	}
+	if q.listBlackoutWindowsStmt != nil {
+		if cerr := q.listBlackoutWindowsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listBlackoutWindowsStmt: %w", cerr)
+		}
+	}
+	if q.listBlackoutWindowsForTaskStmt != nil {
+		if cerr := q.listBlackoutWindowsForTaskStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listBlackoutWindowsForTaskStmt: %w", cerr)
+		}
+	}
+	if q.listDependenciesForTaskStmt != nil {
+		if cerr := q.listDependenciesForTaskStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listDependenciesForTaskStmt: %w", cerr)
+		}
+	}
+	if q.listDependenciesWaitingOnJobStmt != nil {
+		if cerr := q.listDependenciesWaitingOnJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listDependenciesWaitingOnJobStmt: %w", cerr)
+		}
+	}
+	if q.listDependencyRunsStmt != nil {
+		if cerr := q.listDependencyRunsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listDependencyRunsStmt: %w", cerr)
+		}
+	}
+	if q.listDueScheduledRunsStmt != nil {
+		if cerr := q.listDueScheduledRunsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listDueScheduledRunsStmt: %w", cerr)
+		}
+	}
+	if q.listNodeGroupMembersStmt != nil {
+		if cerr := q.listNodeGroupMembersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listNodeGroupMembersStmt: %w", cerr)
+		}
+	}
+	if q.listNodeGroupsStmt != nil {
+		if cerr := q.listNodeGroupsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listNodeGroupsStmt: %w", cerr)
+		}
+	}
+	if q.listNodesInGroupStmt != nil {
+		if cerr := q.listNodesInGroupStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listNodesInGroupStmt: %w", cerr)
+		}
+	}
+	if q.listPermissionsForRoleStmt != nil {
+		if cerr := q.listPermissionsForRoleStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPermissionsForRoleStmt: %w", cerr)
+		}
+	}
+	if q.listProjectMaxRuntimesStmt != nil {
+		if cerr := q.listProjectMaxRuntimesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listProjectMaxRuntimesStmt: %w", cerr)
+		}
+	}
+	if q.listResolvedTaskNodesStmt != nil {
+		if cerr := q.listResolvedTaskNodesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listResolvedTaskNodesStmt: %w", cerr)
+		}
+	}
+	if q.listRolesStmt != nil {
+		if cerr := q.listRolesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listRolesStmt: %w", cerr)
+		}
+	}
+	if q.listRunningJobsWithMaxRuntimeStmt != nil {
+		if cerr := q.listRunningJobsWithMaxRuntimeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listRunningJobsWithMaxRuntimeStmt: %w", cerr)
+		}
+	}
+	if q.listScheduledRunNodesStmt != nil {
+		if cerr := q.listScheduledRunNodesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listScheduledRunNodesStmt: %w", cerr)
+		}
+	}
+	if q.listScheduledRunsStmt != nil {
+		if cerr := q.listScheduledRunsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listScheduledRunsStmt: %w", cerr)
+		}
+	}
 	if q.listScrapydNodesStmt != nil {
 		if cerr := q.listScrapydNodesStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listScrapydNodesStmt: %w", cerr)
 		}
 	}
+	if q.listTargetsForTaskStmt != nil {
+		if cerr := q.listTargetsForTaskStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTargetsForTaskStmt: %w", cerr)
+		}
+	}
+	if q.listTaskBlackoutCalendarsStmt != nil {
+		if cerr := q.listTaskBlackoutCalendarsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTaskBlackoutCalendarsStmt: %w", cerr)
+		}
+	}
+	if q.listTaskDependenciesStmt != nil {
+		if cerr := q.listTaskDependenciesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTaskDependenciesStmt: %w", cerr)
+		}
+	}
+	if q.listTaskRevisionsStmt != nil {
+		if cerr := q.listTaskRevisionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTaskRevisionsStmt: %w", cerr)
+		}
+	}
+	if q.listTaskRunsStmt != nil {
+		if cerr := q.listTaskRunsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTaskRunsStmt: %w", cerr)
+		}
+	}
+	if q.listTaskTargetNodesStmt != nil {
+		if cerr := q.listTaskTargetNodesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTaskTargetNodesStmt: %w", cerr)
+		}
+	}
 	if q.newScrapydNodeStmt != nil {
 		if cerr := q.newScrapydNodeStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing newScrapydNodeStmt: %w", cerr)
 		}
 	}
+	if q.pruneTaskRunsStmt != nil {
+		if cerr := q.pruneTaskRunsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing pruneTaskRunsStmt: %w", cerr)
+		}
+	}
+	if q.queryJobsStmt != nil {
+		if cerr := q.queryJobsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing queryJobsStmt: %w", cerr)
+		}
+	}
+	if q.queueJobStmt != nil {
+		if cerr := q.queueJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing queueJobStmt: %w", cerr)
+		}
+	}
+	if q.recordTwoFactorFailureStmt != nil {
+		if cerr := q.recordTwoFactorFailureStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing recordTwoFactorFailureStmt: %w", cerr)
+		}
+	}
+	if q.releaseSchedulerLeaseStmt != nil {
+		if cerr := q.releaseSchedulerLeaseStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing releaseSchedulerLeaseStmt: %w", cerr)
+		}
+	}
+	if q.renewQueuedJobStmt != nil {
+		if cerr := q.renewQueuedJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing renewQueuedJobStmt: %w", cerr)
+		}
+	}
+	if q.resetTaskDependenciesStmt != nil {
+		if cerr := q.resetTaskDependenciesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing resetTaskDependenciesStmt: %w", cerr)
+		}
+	}
+	if q.resetTwoFactorFailuresStmt != nil {
+		if cerr := q.resetTwoFactorFailuresStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing resetTwoFactorFailuresStmt: %w", cerr)
+		}
+	}
+	if q.resetUserTOTPStmt != nil {
+		if cerr := q.resetUserTOTPStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing resetUserTOTPStmt: %w", cerr)
+		}
+	}
+	if q.revokeAPITokenStmt != nil {
+		if cerr := q.revokeAPITokenStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing revokeAPITokenStmt: %w", cerr)
+		}
+	}
+	if q.scheduleQueuedJobStmt != nil {
+		if cerr := q.scheduleQueuedJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing scheduleQueuedJobStmt: %w", cerr)
+		}
+	}
 	if q.searchNodeJobsStmt != nil {
 		if cerr := q.searchNodeJobsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing searchNodeJobsStmt: %w", cerr)
@@ -240,11 +1078,56 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing setErrorWhereJobIdStmt: %w", cerr)
 		}
 	}
+	if q.setJobAttemptStmt != nil {
+		if cerr := q.setJobAttemptStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setJobAttemptStmt: %w", cerr)
+		}
+	}
+	if q.setJobKilledStmt != nil {
+		if cerr := q.setJobKilledStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setJobKilledStmt: %w", cerr)
+		}
+	}
+	if q.setJobSpiderArgsStmt != nil {
+		if cerr := q.setJobSpiderArgsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setJobSpiderArgsStmt: %w", cerr)
+		}
+	}
+	if q.setJobStatusStmt != nil {
+		if cerr := q.setJobStatusStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setJobStatusStmt: %w", cerr)
+		}
+	}
+	if q.setJobTimedOutStmt != nil {
+		if cerr := q.setJobTimedOutStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setJobTimedOutStmt: %w", cerr)
+		}
+	}
+	if q.setRoleAccessRestrictedStmt != nil {
+		if cerr := q.setRoleAccessRestrictedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setRoleAccessRestrictedStmt: %w", cerr)
+		}
+	}
 	if q.setStoppedByOnJobStmt != nil {
 		if cerr := q.setStoppedByOnJobStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing setStoppedByOnJobStmt: %w", cerr)
 		}
 	}
+	if q.setTaskDependencyUpstreamJobStmt != nil {
+		if cerr := q.setTaskDependencyUpstreamJobStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setTaskDependencyUpstreamJobStmt: %w", cerr)
+		}
+	}
+	if q.setUserAccessRestrictedStmt != nil {
+		if cerr := q.setUserAccessRestrictedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setUserAccessRestrictedStmt: %w", cerr)
+		}
+	}
+	if q.setUserTOTPSecretStmt != nil {
+		if cerr := q.setUserTOTPSecretStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setUserTOTPSecretStmt: %w", cerr)
+		}
+	}
 	if q.softDeleteJobStmt != nil {
 		if cerr := q.softDeleteJobStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing softDeleteJobStmt: %w", cerr)
@@ -255,11 +1138,26 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing startFinishRuntimeLogsItemsForJobWithJobIDStmt: %w", cerr)
 		}
 	}
+	if q.startMaintenanceModeStmt != nil {
+		if cerr := q.startMaintenanceModeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing startMaintenanceModeStmt: %w", cerr)
+		}
+	}
+	if q.updateAPITokenLastUsedStmt != nil {
+		if cerr := q.updateAPITokenLastUsedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateAPITokenLastUsedStmt: %w", cerr)
+		}
+	}
 	if q.updateNodeWhereNameStmt != nil {
 		if cerr := q.updateNodeWhereNameStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateNodeWhereNameStmt: %w", cerr)
 		}
 	}
+	if q.updateScheduledRunStmt != nil {
+		if cerr := q.updateScheduledRunStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateScheduledRunStmt: %w", cerr)
+		}
+	}
 	if q.updateSettingsStmt != nil {
 		if cerr := q.updateSettingsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateSettingsStmt: %w", cerr)
@@ -270,6 +1168,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing updateTaskStmt: %w", cerr)
 		}
 	}
+	if q.updateTaskLastFiredAtStmt != nil {
+		if cerr := q.updateTaskLastFiredAtStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateTaskLastFiredAtStmt: %w", cerr)
+		}
+	}
 	if q.updateTaskPausedStmt != nil {
 		if cerr := q.updateTaskPausedStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateTaskPausedStmt: %w", cerr)
@@ -285,6 +1188,31 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing updateUsersPasswordWhereIDStmt: %w", cerr)
 		}
 	}
+	if q.updateWebhookLastFiredStmt != nil {
+		if cerr := q.updateWebhookLastFiredStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateWebhookLastFiredStmt: %w", cerr)
+		}
+	}
+	if q.upsertProjectMaxRuntimeStmt != nil {
+		if cerr := q.upsertProjectMaxRuntimeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertProjectMaxRuntimeStmt: %w", cerr)
+		}
+	}
+	if q.upsertTaskWebhookStmt != nil {
+		if cerr := q.upsertTaskWebhookStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertTaskWebhookStmt: %w", cerr)
+		}
+	}
+	if q.useRecoveryCodeStmt != nil {
+		if cerr := q.useRecoveryCodeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useRecoveryCodeStmt: %w", cerr)
+		}
+	}
+	if q.useTOTPStepStmt != nil {
+		if cerr := q.useTOTPStepStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing useTOTPStepStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -324,75 +1252,307 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 type Queries struct {
 	db                                             DBTX
 	tx                                             *sql.Tx
+	acquireSchedulerLeaseStmt                      *sql.Stmt
+	advanceTaskRoundRobinStmt                      *sql.Stmt
 	checkSettingsExistStmt                         *sql.Stmt
+	claimScheduledRunStmt                          *sql.Stmt
+	countTaskRunsStmt                              *sql.Stmt
+	countUnusedRecoveryCodesStmt                   *sql.Stmt
 	createNewUserStmt                              *sql.Stmt
+	deleteAccessGrantStmt                          *sql.Stmt
+	deleteAccessGrantsForRoleStmt                  *sql.Stmt
+	deleteAccessGrantsForUserStmt                  *sql.Stmt
+	deleteBlackoutCalendarStmt                     *sql.Stmt
+	deleteBlackoutWindowStmt                       *sql.Stmt
+	deleteNodeGroupStmt                            *sql.Stmt
+	deleteNodeGroupMembersStmt                     *sql.Stmt
+	deleteProjectMaxRuntimeStmt                    *sql.Stmt
+	deleteRecoveryCodesForUserStmt                 *sql.Stmt
+	deleteScheduledRunStmt                         *sql.Stmt
+	deleteScheduledRunNodesStmt                    *sql.Stmt
 	deleteScrapydNodesStmt                         *sql.Stmt
+	deleteTaskBlackoutCalendarsStmt                *sql.Stmt
+	deleteTaskDependencyStmt                       *sql.Stmt
+	deleteTaskTargetsStmt                          *sql.Stmt
 	deleteTaskWhereUUIDStmt                        *sql.Stmt
 	deleteUserByUUIDStmt                           *sql.Stmt
+	deleteWebhookForTaskStmt                       *sql.Stmt
+	deleteWebhookNoncesSeenBeforeStmt              *sql.Stmt
+	enableUserTOTPStmt                             *sql.Stmt
+	endMaintenanceModeStmt                         *sql.Stmt
+	failQueuedJobsStmt                             *sql.Stmt
+	finishScheduledRunStmt                         *sql.Stmt
+	finishTaskRunStmt                              *sql.Stmt
+	getAPITokenWithHashStmt                        *sql.Stmt
+	getAccessRestrictionStmt                       *sql.Stmt
 	getAllUsersStmt                                *sql.Stmt
+	getBlackoutCalendarStmt                        *sql.Stmt
+	getBlackoutCalendarByNameStmt                  *sql.Stmt
 	getJobsForNodeStmt                             *sql.Stmt
+	getLastFinishedJobForTaskStmt                  *sql.Stmt
+	getLatestJobForTaskStmt                        *sql.Stmt
+	getMaintenanceModeStmt                         *sql.Stmt
+	getNodeForJobStmt                              *sql.Stmt
+	getNodeGroupStmt                               *sql.Stmt
+	getNodeGroupByNameStmt                         *sql.Stmt
 	getNodeWithNameStmt                            *sql.Stmt
+	getProjectAndNodeForJobStmt                    *sql.Stmt
+	getRoleWithNameStmt                            *sql.Stmt
+	getScheduledRunStmt                            *sql.Stmt
+	getSchedulerLeaseStmt                          *sql.Stmt
 	getSettingsStmt                                *sql.Stmt
+	getTaskDependencyStmt                          *sql.Stmt
+	getTaskRevisionStmt                            *sql.Stmt
 	getTaskWithUUIDStmt                            *sql.Stmt
 	getTasksStmt                                   *sql.Stmt
 	getTasksWithLatestJobMetadataStmt              *sql.Stmt
 	getTotalJobCountForNodeStmt                    *sql.Stmt
 	getUserByUsernameStmt                          *sql.Stmt
 	getUserWithIDStmt                              *sql.Stmt
+	getWebhookForTaskStmt                          *sql.Stmt
+	insertAPITokenStmt                             *sql.Stmt
+	insertAccessGrantStmt                          *sql.Stmt
+	insertBlackoutCalendarStmt                     *sql.Stmt
+	insertBlackoutWindowStmt                       *sql.Stmt
 	insertJobStmt                                  *sql.Stmt
+	insertNodeGroupStmt                            *sql.Stmt
+	insertNodeGroupMemberStmt                      *sql.Stmt
+	insertRecoveryCodeStmt                         *sql.Stmt
+	insertScheduledRunStmt                         *sql.Stmt
+	insertScheduledRunNodeStmt                     *sql.Stmt
 	insertSettingsStmt                             *sql.Stmt
 	insertTaskStmt                                 *sql.Stmt
+	insertTaskBlackoutCalendarStmt                 *sql.Stmt
+	insertTaskDependencyStmt                       *sql.Stmt
+	insertTaskRevisionStmt                         *sql.Stmt
+	insertTaskRunStmt                              *sql.Stmt
+	insertTaskTargetStmt                           *sql.Stmt
+	insertWebhookNonceStmt                         *sql.Stmt
+	listAPITokensForUserStmt                       *sql.Stmt
+	listAccessGrantsStmt                           *sql.Stmt
+	listAccessGrantsForUserStmt                    *sql.Stmt
+	listActiveJobsForTaskStmt                      *sql.Stmt
+	listAllScheduledRunNodesStmt                   *sql.Stmt
+	listBlackoutCalendarsStmt                      *sql.Stmt
+	listBlackoutCalendarsForTaskStmt               *sql.Stmt
+	listBlackoutWindowsStmt                        *sql.Stmt
+	listBlackoutWindowsForTaskStmt                 *sql.Stmt
+	listDependenciesForTaskStmt                    *sql.Stmt
+	listDependenciesWaitingOnJobStmt               *sql.Stmt
+	listDependencyRunsStmt                         *sql.Stmt
+	listDueScheduledRunsStmt                       *sql.Stmt
+	listNodeGroupMembersStmt                       *sql.Stmt
+	listNodeGroupsStmt                             *sql.Stmt
+	listNodesInGroupStmt                           *sql.Stmt
+	listPermissionsForRoleStmt                     *sql.Stmt
+	listProjectMaxRuntimesStmt                     *sql.Stmt
+	listResolvedTaskNodesStmt                      *sql.Stmt
+	listRolesStmt                                  *sql.Stmt
+	listRunningJobsWithMaxRuntimeStmt              *sql.Stmt
+	listScheduledRunNodesStmt                      *sql.Stmt
+	listScheduledRunsStmt                          *sql.Stmt
 	listScrapydNodesStmt                           *sql.Stmt
+	listTargetsForTaskStmt                         *sql.Stmt
+	listTaskBlackoutCalendarsStmt                  *sql.Stmt
+	listTaskDependenciesStmt                       *sql.Stmt
+	listTaskRevisionsStmt                          *sql.Stmt
+	listTaskRunsStmt                               *sql.Stmt
+	listTaskTargetNodesStmt                        *sql.Stmt
 	newScrapydNodeStmt                             *sql.Stmt
+	pruneTaskRunsStmt                              *sql.Stmt
+	queryJobsStmt                                  *sql.Stmt
+	queueJobStmt                                   *sql.Stmt
+	recordTwoFactorFailureStmt                     *sql.Stmt
+	releaseSchedulerLeaseStmt                      *sql.Stmt
+	renewQueuedJobStmt                             *sql.Stmt
+	resetTaskDependenciesStmt                      *sql.Stmt
+	resetTwoFactorFailuresStmt                     *sql.Stmt
+	resetUserTOTPStmt                              *sql.Stmt
+	revokeAPITokenStmt                             *sql.Stmt
+	scheduleQueuedJobStmt                          *sql.Stmt
 	searchNodeJobsStmt                             *sql.Stmt
 	searchTasksTableStmt                           *sql.Stmt
 	setErrorWhereJobIdStmt                         *sql.Stmt
+	setJobAttemptStmt                              *sql.Stmt
+	setJobKilledStmt                               *sql.Stmt
+	setJobSpiderArgsStmt                           *sql.Stmt
+	setJobStatusStmt                               *sql.Stmt
+	setJobTimedOutStmt                             *sql.Stmt
+	setRoleAccessRestrictedStmt                    *sql.Stmt
 	setStoppedByOnJobStmt                          *sql.Stmt
+	setTaskDependencyUpstreamJobStmt               *sql.Stmt
+	setUserAccessRestrictedStmt                    *sql.Stmt
+	setUserTOTPSecretStmt                          *sql.Stmt
 	softDeleteJobStmt                              *sql.Stmt
 	startFinishRuntimeLogsItemsForJobWithJobIDStmt *sql.Stmt
+	startMaintenanceModeStmt                       *sql.Stmt
+	updateAPITokenLastUsedStmt                     *sql.Stmt
 	updateNodeWhereNameStmt                        *sql.Stmt
+	updateScheduledRunStmt                         *sql.Stmt
 	updateSettingsStmt                             *sql.Stmt
 	updateTaskStmt                                 *sql.Stmt
+	updateTaskLastFiredAtStmt                      *sql.Stmt
 	updateTaskPausedStmt                           *sql.Stmt
 	updateUserWhereUUIDStmt                        *sql.Stmt
 	updateUsersPasswordWhereIDStmt                 *sql.Stmt
+	updateWebhookLastFiredStmt                     *sql.Stmt
+	upsertProjectMaxRuntimeStmt                    *sql.Stmt
+	upsertTaskWebhookStmt                          *sql.Stmt
+	useRecoveryCodeStmt                            *sql.Stmt
+	useTOTPStepStmt                                *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                                tx,
-		tx:                                tx,
-		checkSettingsExistStmt:            q.checkSettingsExistStmt,
-		createNewUserStmt:                 q.createNewUserStmt,
-		deleteScrapydNodesStmt:            q.deleteScrapydNodesStmt,
-		deleteTaskWhereUUIDStmt:           q.deleteTaskWhereUUIDStmt,
-		deleteUserByUUIDStmt:              q.deleteUserByUUIDStmt,
-		getAllUsersStmt:                   q.getAllUsersStmt,
-		getJobsForNodeStmt:                q.getJobsForNodeStmt,
-		getNodeWithNameStmt:               q.getNodeWithNameStmt,
-		getSettingsStmt:                   q.getSettingsStmt,
-		getTaskWithUUIDStmt:               q.getTaskWithUUIDStmt,
-		getTasksStmt:                      q.getTasksStmt,
-		getTasksWithLatestJobMetadataStmt: q.getTasksWithLatestJobMetadataStmt,
-		getTotalJobCountForNodeStmt:       q.getTotalJobCountForNodeStmt,
-		getUserByUsernameStmt:             q.getUserByUsernameStmt,
-		getUserWithIDStmt:                 q.getUserWithIDStmt,
-		insertJobStmt:                     q.insertJobStmt,
-		insertSettingsStmt:                q.insertSettingsStmt,
-		insertTaskStmt:                    q.insertTaskStmt,
-		listScrapydNodesStmt:              q.listScrapydNodesStmt,
-		newScrapydNodeStmt:                q.newScrapydNodeStmt,
-		searchNodeJobsStmt:                q.searchNodeJobsStmt,
-		searchTasksTableStmt:              q.searchTasksTableStmt,
-		setErrorWhereJobIdStmt:            q.setErrorWhereJobIdStmt,
-		setStoppedByOnJobStmt:             q.setStoppedByOnJobStmt,
-		softDeleteJobStmt:                 q.softDeleteJobStmt,
+		db:                                             tx,
+		tx:                                             tx,
+		acquireSchedulerLeaseStmt:                      q.acquireSchedulerLeaseStmt,
+		advanceTaskRoundRobinStmt:                      q.advanceTaskRoundRobinStmt,
+		checkSettingsExistStmt:                         q.checkSettingsExistStmt,
+		claimScheduledRunStmt:                          q.claimScheduledRunStmt,
+		countTaskRunsStmt:                              q.countTaskRunsStmt,
+		countUnusedRecoveryCodesStmt:                   q.countUnusedRecoveryCodesStmt,
+		createNewUserStmt:                              q.createNewUserStmt,
+		deleteAccessGrantStmt:                          q.deleteAccessGrantStmt,
+		deleteAccessGrantsForRoleStmt:                  q.deleteAccessGrantsForRoleStmt,
+		deleteAccessGrantsForUserStmt:                  q.deleteAccessGrantsForUserStmt,
+		deleteBlackoutCalendarStmt:                     q.deleteBlackoutCalendarStmt,
+		deleteBlackoutWindowStmt:                       q.deleteBlackoutWindowStmt,
+		deleteNodeGroupStmt:                            q.deleteNodeGroupStmt,
+		deleteNodeGroupMembersStmt:                     q.deleteNodeGroupMembersStmt,
+		deleteProjectMaxRuntimeStmt:                    q.deleteProjectMaxRuntimeStmt,
+		deleteRecoveryCodesForUserStmt:                 q.deleteRecoveryCodesForUserStmt,
+		deleteScheduledRunStmt:                         q.deleteScheduledRunStmt,
+		deleteScheduledRunNodesStmt:                    q.deleteScheduledRunNodesStmt,
+		deleteScrapydNodesStmt:                         q.deleteScrapydNodesStmt,
+		deleteTaskBlackoutCalendarsStmt:                q.deleteTaskBlackoutCalendarsStmt,
+		deleteTaskDependencyStmt:                       q.deleteTaskDependencyStmt,
+		deleteTaskTargetsStmt:                          q.deleteTaskTargetsStmt,
+		deleteTaskWhereUUIDStmt:                        q.deleteTaskWhereUUIDStmt,
+		deleteUserByUUIDStmt:                           q.deleteUserByUUIDStmt,
+		deleteWebhookForTaskStmt:                       q.deleteWebhookForTaskStmt,
+		deleteWebhookNoncesSeenBeforeStmt:              q.deleteWebhookNoncesSeenBeforeStmt,
+		enableUserTOTPStmt:                             q.enableUserTOTPStmt,
+		endMaintenanceModeStmt:                         q.endMaintenanceModeStmt,
+		failQueuedJobsStmt:                             q.failQueuedJobsStmt,
+		finishScheduledRunStmt:                         q.finishScheduledRunStmt,
+		finishTaskRunStmt:                              q.finishTaskRunStmt,
+		getAPITokenWithHashStmt:                        q.getAPITokenWithHashStmt,
+		getAccessRestrictionStmt:                       q.getAccessRestrictionStmt,
+		getAllUsersStmt:                                q.getAllUsersStmt,
+		getBlackoutCalendarStmt:                        q.getBlackoutCalendarStmt,
+		getBlackoutCalendarByNameStmt:                  q.getBlackoutCalendarByNameStmt,
+		getJobsForNodeStmt:                             q.getJobsForNodeStmt,
+		getLastFinishedJobForTaskStmt:                  q.getLastFinishedJobForTaskStmt,
+		getLatestJobForTaskStmt:                        q.getLatestJobForTaskStmt,
+		getMaintenanceModeStmt:                         q.getMaintenanceModeStmt,
+		getNodeForJobStmt:                              q.getNodeForJobStmt,
+		getNodeGroupStmt:                               q.getNodeGroupStmt,
+		getNodeGroupByNameStmt:                         q.getNodeGroupByNameStmt,
+		getNodeWithNameStmt:                            q.getNodeWithNameStmt,
+		getProjectAndNodeForJobStmt:                    q.getProjectAndNodeForJobStmt,
+		getRoleWithNameStmt:                            q.getRoleWithNameStmt,
+		getScheduledRunStmt:                            q.getScheduledRunStmt,
+		getSchedulerLeaseStmt:                          q.getSchedulerLeaseStmt,
+		getSettingsStmt:                                q.getSettingsStmt,
+		getTaskDependencyStmt:                          q.getTaskDependencyStmt,
+		getTaskRevisionStmt:                            q.getTaskRevisionStmt,
+		getTaskWithUUIDStmt:                            q.getTaskWithUUIDStmt,
+		getTasksStmt:                                   q.getTasksStmt,
+		getTasksWithLatestJobMetadataStmt:              q.getTasksWithLatestJobMetadataStmt,
+		getTotalJobCountForNodeStmt:                    q.getTotalJobCountForNodeStmt,
+		getUserByUsernameStmt:                          q.getUserByUsernameStmt,
+		getUserWithIDStmt:                              q.getUserWithIDStmt,
+		getWebhookForTaskStmt:                          q.getWebhookForTaskStmt,
+		insertAPITokenStmt:                             q.insertAPITokenStmt,
+		insertAccessGrantStmt:                          q.insertAccessGrantStmt,
+		insertBlackoutCalendarStmt:                     q.insertBlackoutCalendarStmt,
+		insertBlackoutWindowStmt:                       q.insertBlackoutWindowStmt,
+		insertJobStmt:                                  q.insertJobStmt,
+		insertNodeGroupStmt:                            q.insertNodeGroupStmt,
+		insertNodeGroupMemberStmt:                      q.insertNodeGroupMemberStmt,
+		insertRecoveryCodeStmt:                         q.insertRecoveryCodeStmt,
+		insertScheduledRunStmt:                         q.insertScheduledRunStmt,
+		insertScheduledRunNodeStmt:                     q.insertScheduledRunNodeStmt,
+		insertSettingsStmt:                             q.insertSettingsStmt,
+		insertTaskStmt:                                 q.insertTaskStmt,
+		insertTaskBlackoutCalendarStmt:                 q.insertTaskBlackoutCalendarStmt,
+		insertTaskDependencyStmt:                       q.insertTaskDependencyStmt,
+		insertTaskRevisionStmt:                         q.insertTaskRevisionStmt,
+		insertTaskRunStmt:                              q.insertTaskRunStmt,
+		insertTaskTargetStmt:                           q.insertTaskTargetStmt,
+		insertWebhookNonceStmt:                         q.insertWebhookNonceStmt,
+		listAPITokensForUserStmt:                       q.listAPITokensForUserStmt,
+		listAccessGrantsStmt:                           q.listAccessGrantsStmt,
+		listAccessGrantsForUserStmt:                    q.listAccessGrantsForUserStmt,
+		listActiveJobsForTaskStmt:                      q.listActiveJobsForTaskStmt,
+		listAllScheduledRunNodesStmt:                   q.listAllScheduledRunNodesStmt,
+		listBlackoutCalendarsStmt:                      q.listBlackoutCalendarsStmt,
+		listBlackoutCalendarsForTaskStmt:               q.listBlackoutCalendarsForTaskStmt,
+		listBlackoutWindowsStmt:                        q.listBlackoutWindowsStmt,
+		listBlackoutWindowsForTaskStmt:                 q.listBlackoutWindowsForTaskStmt,
+		listDependenciesForTaskStmt:                    q.listDependenciesForTaskStmt,
+		listDependenciesWaitingOnJobStmt:               q.listDependenciesWaitingOnJobStmt,
+		listDependencyRunsStmt:                         q.listDependencyRunsStmt,
+		listDueScheduledRunsStmt:                       q.listDueScheduledRunsStmt,
+		listNodeGroupMembersStmt:                       q.listNodeGroupMembersStmt,
+		listNodeGroupsStmt:                             q.listNodeGroupsStmt,
+		listNodesInGroupStmt:                           q.listNodesInGroupStmt,
+		listPermissionsForRoleStmt:                     q.listPermissionsForRoleStmt,
+		listProjectMaxRuntimesStmt:                     q.listProjectMaxRuntimesStmt,
+		listResolvedTaskNodesStmt:                      q.listResolvedTaskNodesStmt,
+		listRolesStmt:                                  q.listRolesStmt,
+		listRunningJobsWithMaxRuntimeStmt:              q.listRunningJobsWithMaxRuntimeStmt,
+		listScheduledRunNodesStmt:                      q.listScheduledRunNodesStmt,
+		listScheduledRunsStmt:                          q.listScheduledRunsStmt,
+		listScrapydNodesStmt:                           q.listScrapydNodesStmt,
+		listTargetsForTaskStmt:                         q.listTargetsForTaskStmt,
+		listTaskBlackoutCalendarsStmt:                  q.listTaskBlackoutCalendarsStmt,
+		listTaskDependenciesStmt:                       q.listTaskDependenciesStmt,
+		listTaskRevisionsStmt:                          q.listTaskRevisionsStmt,
+		listTaskRunsStmt:                               q.listTaskRunsStmt,
+		listTaskTargetNodesStmt:                        q.listTaskTargetNodesStmt,
+		newScrapydNodeStmt:                             q.newScrapydNodeStmt,
+		pruneTaskRunsStmt:                              q.pruneTaskRunsStmt,
+		queryJobsStmt:                                  q.queryJobsStmt,
+		queueJobStmt:                                   q.queueJobStmt,
+		recordTwoFactorFailureStmt:                     q.recordTwoFactorFailureStmt,
+		releaseSchedulerLeaseStmt:                      q.releaseSchedulerLeaseStmt,
+		renewQueuedJobStmt:                             q.renewQueuedJobStmt,
+		resetTaskDependenciesStmt:                      q.resetTaskDependenciesStmt,
+		resetTwoFactorFailuresStmt:                     q.resetTwoFactorFailuresStmt,
+		resetUserTOTPStmt:                              q.resetUserTOTPStmt,
+		revokeAPITokenStmt:                             q.revokeAPITokenStmt,
+		scheduleQueuedJobStmt:                          q.scheduleQueuedJobStmt,
+		searchNodeJobsStmt:                             q.searchNodeJobsStmt,
+		searchTasksTableStmt:                           q.searchTasksTableStmt,
+		setErrorWhereJobIdStmt:                         q.setErrorWhereJobIdStmt,
+		setJobAttemptStmt:                              q.setJobAttemptStmt,
+		setJobKilledStmt:                               q.setJobKilledStmt,
+		setJobSpiderArgsStmt:                           q.setJobSpiderArgsStmt,
+		setJobStatusStmt:                               q.setJobStatusStmt,
+		setJobTimedOutStmt:                             q.setJobTimedOutStmt,
+		setRoleAccessRestrictedStmt:                    q.setRoleAccessRestrictedStmt,
+		setStoppedByOnJobStmt:                          q.setStoppedByOnJobStmt,
+		setTaskDependencyUpstreamJobStmt:               q.setTaskDependencyUpstreamJobStmt,
+		setUserAccessRestrictedStmt:                    q.setUserAccessRestrictedStmt,
+		setUserTOTPSecretStmt:                          q.setUserTOTPSecretStmt,
+		softDeleteJobStmt:                              q.softDeleteJobStmt,
 		startFinishRuntimeLogsItemsForJobWithJobIDStmt: q.startFinishRuntimeLogsItemsForJobWithJobIDStmt,
+		startMaintenanceModeStmt:                       q.startMaintenanceModeStmt,
+		updateAPITokenLastUsedStmt:                     q.updateAPITokenLastUsedStmt,
 		updateNodeWhereNameStmt:                        q.updateNodeWhereNameStmt,
+		updateScheduledRunStmt:                         q.updateScheduledRunStmt,
 		updateSettingsStmt:                             q.updateSettingsStmt,
 		updateTaskStmt:                                 q.updateTaskStmt,
+		updateTaskLastFiredAtStmt:                      q.updateTaskLastFiredAtStmt,
 		updateTaskPausedStmt:                           q.updateTaskPausedStmt,
 		updateUserWhereUUIDStmt:                        q.updateUserWhereUUIDStmt,
 		updateUsersPasswordWhereIDStmt:                 q.updateUsersPasswordWhereIDStmt,
+		updateWebhookLastFiredStmt:                     q.updateWebhookLastFiredStmt,
+		upsertProjectMaxRuntimeStmt:                    q.upsertProjectMaxRuntimeStmt,
+		upsertTaskWebhookStmt:                          q.upsertTaskWebhookStmt,
+		useRecoveryCodeStmt:                            q.useRecoveryCodeStmt,
+		useTOTPStepStmt:                                q.useTOTPStepStmt,
 	}
 }
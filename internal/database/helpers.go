@@ -38,3 +38,11 @@ func ReadSqlNullString(data sql.NullString) *string {
 	}
 	return nil
 }
+
+func ReadSqlNullInt64AsInt(data sql.NullInt64) *int {
+	if data.Valid {
+		value := int(data.Int64)
+		return &value
+	}
+	return nil
+}
@@ -180,6 +180,41 @@ func TestReadSqlNullString(t *testing.T) {
 	}
 }
 
+func TestReadSqlNullInt64AsInt(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    sql.NullInt64
+		expected *int
+	}{
+		{
+			name:     "invalid null int",
+			input:    sql.NullInt64{Valid: false, Int64: 0},
+			expected: nil,
+		},
+		{
+			name:     "valid zero",
+			input:    sql.NullInt64{Valid: true, Int64: 0},
+			expected: intPtr(0),
+		},
+		{
+			name:     "valid int",
+			input:    sql.NullInt64{Valid: true, Int64: 42},
+			expected: intPtr(42),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ReadSqlNullInt64AsInt(tt.input)
+			if tt.expected == nil {
+				assert.Equal(t, result, tt.expected)
+			} else if result != nil && tt.expected != nil {
+				assert.Equal(t, *tt.expected, *result)
+			}
+		})
+	}
+}
+
 // Helper functions for creating pointers
 func intPtr(i int) *int {
 	return &i
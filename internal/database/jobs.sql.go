@@ -8,28 +8,54 @@ package database
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 )
 
+const failQueuedJobs = `-- name: FailQueuedJobs :execrows
+UPDATE jobs
+SET status = 'error', error = ?1, queued_until = NULL
+WHERE status = 'queued' AND deleted = 0
+  AND (queued_until IS NULL OR julianday(queued_until) < julianday(?2))
+`
+
+type FailQueuedJobsParams struct {
+	Reason sql.NullString
+	Now    interface{}
+}
+
+// Fails the queued jobs nobody renewed queued_until of, see RenewQueuedJob.
+func (q *Queries) FailQueuedJobs(ctx context.Context, arg FailQueuedJobsParams) (int64, error) {
+	result, err := q.exec(ctx, q.failQueuedJobsStmt, failQueuedJobs, arg.Reason, arg.Now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getJobsForNode = `-- name: GetJobsForNode :many
 SELECT j.id, j.project, j.spider, j.job, j.status, j.deleted, j.create_time, j.update_time, j.pages, j.items, j.pid,
        j.start, j.runtime, j.finish, j.href_log, j.href_items, j.node, j.error, u1.username AS started_by_username,
-       u2.username AS stopped_by_username
+       u2.username AS stopped_by_username, j.triggered_by, j.attempts, j.next_retry_at, j.timed_out_at, j.spider_args
 FROM jobs j
          LEFT JOIN users u1 ON j.started_by = u1.ID
          LEFT JOIN users u2 ON j.stopped_by = u2.ID
-WHERE j.node = ? AND j.deleted = 0
+WHERE j.node = ?1 AND j.deleted = 0
+  AND (?2 IS NULL OR EXISTS (
+      SELECT 1 FROM (SELECT ?2 AS grants) s, json_each(s.grants) g
+      WHERE json_extract(g.value, '$.project') IN ('', j.project) AND json_extract(g.value, '$.node') IN ('', j.node)))
 ORDER BY CASE
              WHEN j.finish IS NULL THEN j.runtime
              ELSE j.finish
              END DESC
-LIMIT ? OFFSET ?
+LIMIT ?4 OFFSET ?3
 `
 
 type GetJobsForNodeParams struct {
 	Node   string
-	Limit  int64
+	Grants interface{}
 	Offset int64
+	Limit  int64
 }
 
 type GetJobsForNodeRow struct {
@@ -53,10 +79,21 @@ type GetJobsForNodeRow struct {
 	Error             sql.NullString
 	StartedByUsername sql.NullString
 	StoppedByUsername sql.NullString
+	TriggeredBy       sql.NullString
+	Attempts          int64
+	NextRetryAt       sql.NullTime
+	TimedOutAt        sql.NullTime
+	SpiderArgs        sql.NullString
 }
 
+// grants is the JSON array of the access grants of a restricted user, see accessScope.queryGrants, NULL matches any job.
 func (q *Queries) GetJobsForNode(ctx context.Context, arg GetJobsForNodeParams) ([]GetJobsForNodeRow, error) {
-	rows, err := q.query(ctx, q.getJobsForNodeStmt, getJobsForNode, arg.Node, arg.Limit, arg.Offset)
+	rows, err := q.query(ctx, q.getJobsForNodeStmt, getJobsForNode,
+		arg.Node,
+		arg.Grants,
+		arg.Offset,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +122,11 @@ func (q *Queries) GetJobsForNode(ctx context.Context, arg GetJobsForNodeParams)
 			&i.Error,
 			&i.StartedByUsername,
 			&i.StoppedByUsername,
+			&i.TriggeredBy,
+			&i.Attempts,
+			&i.NextRetryAt,
+			&i.TimedOutAt,
+			&i.SpiderArgs,
 		); err != nil {
 			return nil, err
 		}
@@ -99,12 +141,124 @@ func (q *Queries) GetJobsForNode(ctx context.Context, arg GetJobsForNodeParams)
 	return items, nil
 }
 
+const getLastFinishedJobForTask = `-- name: GetLastFinishedJobForTask :one
+SELECT job FROM jobs
+WHERE task_id = ? AND status = 'finished' AND deleted = 0
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLastFinishedJobForTask(ctx context.Context, taskID interface{}) (string, error) {
+	row := q.queryRow(ctx, q.getLastFinishedJobForTaskStmt, getLastFinishedJobForTask, taskID)
+	var job string
+	err := row.Scan(&job)
+	return job, err
+}
+
+const getLatestJobForTask = `-- name: GetLatestJobForTask :one
+SELECT j.id, j.project, j.spider, j.job, j.status, j.deleted, j.create_time, j.update_time, j.pages, j.items, j.pid,
+       j.start, j.runtime, j.finish, j.href_log, j.href_items, j.node, j.error, u1.username AS started_by_username,
+       u2.username AS stopped_by_username, j.triggered_by, j.attempts, j.next_retry_at, j.timed_out_at, j.spider_args
+FROM jobs j
+         LEFT JOIN users u1 ON j.started_by = u1.ID
+         LEFT JOIN users u2 ON j.stopped_by = u2.ID
+WHERE j.task_id = ? AND j.deleted = 0
+ORDER BY j.create_time DESC, j.id DESC
+LIMIT 1
+`
+
+type GetLatestJobForTaskRow struct {
+	ID                int64
+	Project           string
+	Spider            string
+	Job               string
+	Status            string
+	Deleted           bool
+	CreateTime        time.Time
+	UpdateTime        time.Time
+	Pages             sql.NullInt64
+	Items             sql.NullInt64
+	Pid               sql.NullInt64
+	Start             sql.NullTime
+	Runtime           sql.NullString
+	Finish            sql.NullTime
+	HrefLog           sql.NullString
+	HrefItems         sql.NullString
+	Node              string
+	Error             sql.NullString
+	StartedByUsername sql.NullString
+	StoppedByUsername sql.NullString
+	TriggeredBy       sql.NullString
+	Attempts          int64
+	NextRetryAt       sql.NullTime
+	TimedOutAt        sql.NullTime
+	SpiderArgs        sql.NullString
+}
+
+func (q *Queries) GetLatestJobForTask(ctx context.Context, taskID interface{}) (GetLatestJobForTaskRow, error) {
+	row := q.queryRow(ctx, q.getLatestJobForTaskStmt, getLatestJobForTask, taskID)
+	var i GetLatestJobForTaskRow
+	err := row.Scan(
+		&i.ID,
+		&i.Project,
+		&i.Spider,
+		&i.Job,
+		&i.Status,
+		&i.Deleted,
+		&i.CreateTime,
+		&i.UpdateTime,
+		&i.Pages,
+		&i.Items,
+		&i.Pid,
+		&i.Start,
+		&i.Runtime,
+		&i.Finish,
+		&i.HrefLog,
+		&i.HrefItems,
+		&i.Node,
+		&i.Error,
+		&i.StartedByUsername,
+		&i.StoppedByUsername,
+		&i.TriggeredBy,
+		&i.Attempts,
+		&i.NextRetryAt,
+		&i.TimedOutAt,
+		&i.SpiderArgs,
+	)
+	return i, err
+}
+
+const getNodeForJob = `-- name: GetNodeForJob :one
+SELECT node FROM jobs WHERE job = ? AND project = ? AND deleted = 0 ORDER BY id DESC LIMIT 1
+`
+
+type GetNodeForJobParams struct {
+	Job     string
+	Project string
+}
+
+func (q *Queries) GetNodeForJob(ctx context.Context, arg GetNodeForJobParams) (string, error) {
+	row := q.queryRow(ctx, q.getNodeForJobStmt, getNodeForJob, arg.Job, arg.Project)
+	var node string
+	err := row.Scan(&node)
+	return node, err
+}
+
 const getTotalJobCountForNode = `-- name: GetTotalJobCountForNode :one
-SELECT COUNT(*) FROM jobs WHERE node = ? AND deleted = 0
+SELECT COUNT(*) FROM jobs j
+WHERE j.node = ?1 AND j.deleted = 0
+  AND (?2 IS NULL OR EXISTS (
+      SELECT 1 FROM (SELECT ?2 AS grants) s, json_each(s.grants) g
+      WHERE json_extract(g.value, '$.project') IN ('', j.project) AND json_extract(g.value, '$.node') IN ('', j.node)))
 `
 
-func (q *Queries) GetTotalJobCountForNode(ctx context.Context, node string) (int64, error) {
-	row := q.queryRow(ctx, q.getTotalJobCountForNodeStmt, getTotalJobCountForNode, node)
+type GetTotalJobCountForNodeParams struct {
+	Node   string
+	Grants interface{}
+}
+
+func (q *Queries) GetTotalJobCountForNode(ctx context.Context, arg GetTotalJobCountForNodeParams) (int64, error) {
+	row := q.queryRow(ctx, q.getTotalJobCountForNodeStmt, getTotalJobCountForNode, arg.Node, arg.Grants)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
@@ -113,7 +267,7 @@ func (q *Queries) GetTotalJobCountForNode(ctx context.Context, node string) (int
 const insertJob = `-- name: InsertJob :one
 INSERT INTO jobs (
     project, spider, job, status, deleted, create_time, update_time,
-    pages, items, pid, start, runtime, finish, href_log, href_items, node, task_id, started_by, stopped_by
+    pages, items, pid, start, runtime, finish, href_log, href_items, node, task_id, started_by, stopped_by, triggered_by
 )
 VALUES (
     ?1,
@@ -134,11 +288,13 @@ VALUES (
     ?16,
     ?17,
     ?18,
-        ?19
+    ?19,
+    ?20
        )
     ON CONFLICT(project, spider, job)
 DO UPDATE SET
-    status = EXCLUDED.status,
+    -- A job cancelled for running past its max runtime keeps that outcome once Scrapyd reports it finished
+    status = CASE WHEN jobs.timed_out_at IS NOT NULL AND EXCLUDED.status = 'finished' THEN 'timed_out' ELSE EXCLUDED.status END,
     update_time = EXCLUDED.update_time,
     pages = COALESCE(EXCLUDED.pages, jobs.pages),
     items = COALESCE(EXCLUDED.items, jobs.items),
@@ -149,32 +305,34 @@ DO UPDATE SET
     href_log = COALESCE(EXCLUDED.href_log, jobs.href_log),
     href_items = COALESCE(EXCLUDED.href_items, jobs.href_items),
     started_by = COALESCE(EXCLUDED.started_by, jobs.started_by),
-    stopped_by = COALESCE(EXCLUDED.stopped_by, jobs.stopped_by)
+    stopped_by = COALESCE(EXCLUDED.stopped_by, jobs.stopped_by),
+    triggered_by = COALESCE(EXCLUDED.triggered_by, jobs.triggered_by)
 WHERE jobs.deleted = 0
 AND EXCLUDED.update_time >= jobs.update_time
-RETURNING id, project, spider, job, status, deleted, create_time, update_time, pages, items, pid, start, runtime, finish, href_log, href_items, node, task_id, error, started_by, stopped_by
+RETURNING id, project, spider, job, status, deleted, create_time, update_time, pages, items, pid, start, runtime, finish, href_log, href_items, node, task_id, error, started_by, stopped_by, triggered_by, attempts, next_retry_at, timed_out_at, killed_at, spider_args, queued_until
 `
 
 type InsertJobParams struct {
-	Project    string
-	Spider     string
-	Job        string
-	Status     string
-	Deleted    bool
-	CreateTime time.Time
-	UpdateTime time.Time
-	Pages      sql.NullInt64
-	Items      sql.NullInt64
-	Pid        sql.NullInt64
-	Start      sql.NullTime
-	Runtime    sql.NullString
-	Finish     sql.NullTime
-	HrefLog    sql.NullString
-	HrefItems  sql.NullString
-	Node       string
-	TaskID     interface{}
-	StartedBy  interface{}
-	StoppedBy  interface{}
+	Project     string
+	Spider      string
+	Job         string
+	Status      string
+	Deleted     bool
+	CreateTime  time.Time
+	UpdateTime  time.Time
+	Pages       sql.NullInt64
+	Items       sql.NullInt64
+	Pid         sql.NullInt64
+	Start       sql.NullTime
+	Runtime     sql.NullString
+	Finish      sql.NullTime
+	HrefLog     sql.NullString
+	HrefItems   sql.NullString
+	Node        string
+	TaskID      interface{}
+	StartedBy   interface{}
+	StoppedBy   interface{}
+	TriggeredBy sql.NullString
 }
 
 func (q *Queries) InsertJob(ctx context.Context, arg InsertJobParams) (Job, error) {
@@ -198,6 +356,7 @@ func (q *Queries) InsertJob(ctx context.Context, arg InsertJobParams) (Job, erro
 		arg.TaskID,
 		arg.StartedBy,
 		arg.StoppedBy,
+		arg.TriggeredBy,
 	)
 	var i Job
 	err := row.Scan(
@@ -222,14 +381,336 @@ func (q *Queries) InsertJob(ctx context.Context, arg InsertJobParams) (Job, erro
 		&i.Error,
 		&i.StartedBy,
 		&i.StoppedBy,
+		&i.TriggeredBy,
+		&i.Attempts,
+		&i.NextRetryAt,
+		&i.TimedOutAt,
+		&i.KilledAt,
+		&i.SpiderArgs,
+		&i.QueuedUntil,
 	)
 	return i, err
 }
 
+const listActiveJobsForTask = `-- name: ListActiveJobsForTask :many
+SELECT job, project, node, status FROM jobs
+WHERE task_id = ?1 AND job NOT IN (/*SLICE:exclude_jobs*/?) AND deleted = 0
+  AND status IN ('scheduled', 'queued', 'pending', 'running')
+ORDER BY id
+`
+
+type ListActiveJobsForTaskParams struct {
+	TaskID      interface{}
+	ExcludeJobs []string
+}
+
+type ListActiveJobsForTaskRow struct {
+	Job     string
+	Project string
+	Node    string
+	Status  string
+}
+
+func (q *Queries) ListActiveJobsForTask(ctx context.Context, arg ListActiveJobsForTaskParams) ([]ListActiveJobsForTaskRow, error) {
+	query := listActiveJobsForTask
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.TaskID)
+	if len(arg.ExcludeJobs) > 0 {
+		for _, v := range arg.ExcludeJobs {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:exclude_jobs*/?", strings.Repeat(",?", len(arg.ExcludeJobs))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:exclude_jobs*/?", "NULL", 1)
+	}
+	rows, err := q.query(ctx, nil, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListActiveJobsForTaskRow
+	for rows.Next() {
+		var i ListActiveJobsForTaskRow
+		if err := rows.Scan(
+			&i.Job,
+			&i.Project,
+			&i.Node,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRunningJobsWithMaxRuntime = `-- name: ListRunningJobsWithMaxRuntime :many
+SELECT j.id, j.project, j.spider, j.job, j.node, j.start, j.timed_out_at, j.killed_at,
+       t.max_runtime_seconds AS task_max_runtime_seconds, p.max_runtime_seconds AS project_max_runtime_seconds
+FROM jobs j
+         LEFT JOIN tasks t ON j.task_id = t.id
+         LEFT JOIN project_max_runtimes p ON j.project = p.project
+WHERE j.status = 'running' AND j.deleted = 0 AND j.start IS NOT NULL
+  AND (t.max_runtime_seconds IS NOT NULL OR p.max_runtime_seconds IS NOT NULL)
+ORDER BY j.id
+`
+
+type ListRunningJobsWithMaxRuntimeRow struct {
+	ID                       int64
+	Project                  string
+	Spider                   string
+	Job                      string
+	Node                     string
+	Start                    sql.NullTime
+	TimedOutAt               sql.NullTime
+	KilledAt                 sql.NullTime
+	TaskMaxRuntimeSeconds    sql.NullInt64
+	ProjectMaxRuntimeSeconds sql.NullInt64
+}
+
+func (q *Queries) ListRunningJobsWithMaxRuntime(ctx context.Context) ([]ListRunningJobsWithMaxRuntimeRow, error) {
+	rows, err := q.query(ctx, q.listRunningJobsWithMaxRuntimeStmt, listRunningJobsWithMaxRuntime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRunningJobsWithMaxRuntimeRow
+	for rows.Next() {
+		var i ListRunningJobsWithMaxRuntimeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Project,
+			&i.Spider,
+			&i.Job,
+			&i.Node,
+			&i.Start,
+			&i.TimedOutAt,
+			&i.KilledAt,
+			&i.TaskMaxRuntimeSeconds,
+			&i.ProjectMaxRuntimeSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queryJobs = `-- name: QueryJobs :many
+SELECT j.id, j.project, j.spider, j.job, j.status, j.deleted, j.create_time, j.update_time, j.pages, j.items, j.pid,
+       j.start, j.runtime, j.finish, j.href_log, j.href_items, j.node, j.error, u1.username AS started_by_username,
+       u2.username AS stopped_by_username, j.triggered_by, j.attempts, j.next_retry_at, j.timed_out_at, j.spider_args
+FROM jobs j
+         LEFT JOIN users u1 ON j.started_by = u1.ID
+         LEFT JOIN users u2 ON j.stopped_by = u2.ID
+WHERE j.deleted = 0
+  AND (?1 IS NULL OR j.node = ?1)
+  AND (?2 IS NULL OR j.project = ?2)
+  AND (?3 IS NULL OR j.spider = ?3)
+  AND (?4 IS NULL OR j.status = ?4)
+  AND (?5 IS NULL OR j.task_id = ?5)
+  AND (?6 IS NULL OR u1.username = ?6)
+  AND (?7 IS NULL OR julianday(j.create_time) >= julianday(?7))
+  AND (?8 IS NULL OR julianday(j.create_time) < julianday(?8))
+  AND (?9 IS NULL OR j.id < ?9)
+  AND (?10 IS NULL OR EXISTS (
+      SELECT 1 FROM (SELECT ?10 AS grants) s, json_each(s.grants) g
+      WHERE json_extract(g.value, '$.project') IN ('', j.project) AND json_extract(g.value, '$.node') IN ('', j.node)))
+ORDER BY j.id DESC
+LIMIT ?11
+`
+
+type QueryJobsParams struct {
+	Node          interface{}
+	Project       interface{}
+	Spider        interface{}
+	Status        interface{}
+	TaskID        interface{}
+	StartedBy     interface{}
+	CreatedAfter  interface{}
+	CreatedBefore interface{}
+	BeforeID      interface{}
+	Grants        interface{}
+	Limit         int64
+}
+
+type QueryJobsRow struct {
+	ID                int64
+	Project           string
+	Spider            string
+	Job               string
+	Status            string
+	Deleted           bool
+	CreateTime        time.Time
+	UpdateTime        time.Time
+	Pages             sql.NullInt64
+	Items             sql.NullInt64
+	Pid               sql.NullInt64
+	Start             sql.NullTime
+	Runtime           sql.NullString
+	Finish            sql.NullTime
+	HrefLog           sql.NullString
+	HrefItems         sql.NullString
+	Node              string
+	Error             sql.NullString
+	StartedByUsername sql.NullString
+	StoppedByUsername sql.NullString
+	TriggeredBy       sql.NullString
+	Attempts          int64
+	NextRetryAt       sql.NullTime
+	TimedOutAt        sql.NullTime
+	SpiderArgs        sql.NullString
+}
+
+func (q *Queries) QueryJobs(ctx context.Context, arg QueryJobsParams) ([]QueryJobsRow, error) {
+	rows, err := q.query(ctx, q.queryJobsStmt, queryJobs,
+		arg.Node,
+		arg.Project,
+		arg.Spider,
+		arg.Status,
+		arg.TaskID,
+		arg.StartedBy,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.BeforeID,
+		arg.Grants,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []QueryJobsRow
+	for rows.Next() {
+		var i QueryJobsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Project,
+			&i.Spider,
+			&i.Job,
+			&i.Status,
+			&i.Deleted,
+			&i.CreateTime,
+			&i.UpdateTime,
+			&i.Pages,
+			&i.Items,
+			&i.Pid,
+			&i.Start,
+			&i.Runtime,
+			&i.Finish,
+			&i.HrefLog,
+			&i.HrefItems,
+			&i.Node,
+			&i.Error,
+			&i.StartedByUsername,
+			&i.StoppedByUsername,
+			&i.TriggeredBy,
+			&i.Attempts,
+			&i.NextRetryAt,
+			&i.TimedOutAt,
+			&i.SpiderArgs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const queueJob = `-- name: QueueJob :exec
+UPDATE jobs
+SET status = 'queued', queued_until = ?1
+WHERE jobs.job = ?2 AND jobs.project=?3 AND jobs.node=?4
+`
+
+type QueueJobParams struct {
+	QueuedUntil sql.NullTime
+	JobID       string
+	Project     string
+	Node        string
+}
+
+func (q *Queries) QueueJob(ctx context.Context, arg QueueJobParams) error {
+	_, err := q.exec(ctx, q.queueJobStmt, queueJob,
+		arg.QueuedUntil,
+		arg.JobID,
+		arg.Project,
+		arg.Node,
+	)
+	return err
+}
+
+const renewQueuedJob = `-- name: RenewQueuedJob :execrows
+UPDATE jobs
+SET queued_until = ?1
+WHERE jobs.job = ?2 AND jobs.project=?3 AND jobs.node=?4
+  AND status = 'queued' AND deleted = 0
+`
+
+type RenewQueuedJobParams struct {
+	QueuedUntil sql.NullTime
+	JobID       string
+	Project     string
+	Node        string
+}
+
+// No rows are affected once the job isn't queued anymore, e.g. it was failed as orphaned.
+func (q *Queries) RenewQueuedJob(ctx context.Context, arg RenewQueuedJobParams) (int64, error) {
+	result, err := q.exec(ctx, q.renewQueuedJobStmt, renewQueuedJob,
+		arg.QueuedUntil,
+		arg.JobID,
+		arg.Project,
+		arg.Node,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const scheduleQueuedJob = `-- name: ScheduleQueuedJob :execrows
+UPDATE jobs
+SET status = 'scheduled', queued_until = NULL
+WHERE jobs.job = ?1 AND jobs.project=?2 AND jobs.node=?3
+  AND status = 'queued' AND deleted = 0
+`
+
+type ScheduleQueuedJobParams struct {
+	JobID   string
+	Project string
+	Node    string
+}
+
+func (q *Queries) ScheduleQueuedJob(ctx context.Context, arg ScheduleQueuedJobParams) (int64, error) {
+	result, err := q.exec(ctx, q.scheduleQueuedJobStmt, scheduleQueuedJob, arg.JobID, arg.Project, arg.Node)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const searchNodeJobs = `-- name: SearchNodeJobs :many
 SELECT j.id, j.project, j.spider, j.job, j.status, j.deleted, j.create_time, j.update_time, j.pages, j.items, j.pid,
        j.start, j.runtime, j.finish, j.href_log, j.href_items, j.node, j.error, u1.username AS started_by_username,
-       u2.username AS stopped_by_username
+       u2.username AS stopped_by_username, j.triggered_by, j.attempts, j.next_retry_at, j.timed_out_at, j.spider_args
 FROM jobs j
          LEFT JOIN users u1 ON j.started_by = u1.ID
          LEFT JOIN users u2 ON j.stopped_by = u2.ID
@@ -238,6 +719,9 @@ WHERE
      LOWER(j.job) LIKE '%' || LOWER(?1) || '%')
   AND j.node = ?2
   AND j.deleted = 0
+  AND (?3 IS NULL OR EXISTS (
+      SELECT 1 FROM (SELECT ?3 AS grants) s, json_each(s.grants) g
+      WHERE json_extract(g.value, '$.project') IN ('', j.project) AND json_extract(g.value, '$.node') IN ('', j.node)))
 ORDER BY CASE
              WHEN j.finish IS NULL THEN j.runtime
              ELSE j.finish
@@ -247,6 +731,7 @@ ORDER BY CASE
 type SearchNodeJobsParams struct {
 	SearchTerm string
 	Node       string
+	Grants     interface{}
 }
 
 type SearchNodeJobsRow struct {
@@ -270,10 +755,15 @@ type SearchNodeJobsRow struct {
 	Error             sql.NullString
 	StartedByUsername sql.NullString
 	StoppedByUsername sql.NullString
+	TriggeredBy       sql.NullString
+	Attempts          int64
+	NextRetryAt       sql.NullTime
+	TimedOutAt        sql.NullTime
+	SpiderArgs        sql.NullString
 }
 
 func (q *Queries) SearchNodeJobs(ctx context.Context, arg SearchNodeJobsParams) ([]SearchNodeJobsRow, error) {
-	rows, err := q.query(ctx, q.searchNodeJobsStmt, searchNodeJobs, arg.SearchTerm, arg.Node)
+	rows, err := q.query(ctx, q.searchNodeJobsStmt, searchNodeJobs, arg.SearchTerm, arg.Node, arg.Grants)
 	if err != nil {
 		return nil, err
 	}
@@ -302,6 +792,11 @@ func (q *Queries) SearchNodeJobs(ctx context.Context, arg SearchNodeJobsParams)
 			&i.Error,
 			&i.StartedByUsername,
 			&i.StoppedByUsername,
+			&i.TriggeredBy,
+			&i.Attempts,
+			&i.NextRetryAt,
+			&i.TimedOutAt,
+			&i.SpiderArgs,
 		); err != nil {
 			return nil, err
 		}
@@ -318,7 +813,7 @@ func (q *Queries) SearchNodeJobs(ctx context.Context, arg SearchNodeJobsParams)
 
 const setErrorWhereJobId = `-- name: SetErrorWhereJobId :exec
 UPDATE jobs
-SET error = ?, status = 'error'
+SET error = ?, status = 'error', next_retry_at = NULL
 WHERE jobs.job = ?2 AND jobs.project=?3 AND jobs.node=?4
 `
 
@@ -339,6 +834,109 @@ func (q *Queries) SetErrorWhereJobId(ctx context.Context, arg SetErrorWhereJobId
 	return err
 }
 
+const setJobAttempt = `-- name: SetJobAttempt :exec
+UPDATE jobs
+SET attempts = ?, error = ?, next_retry_at = ?
+WHERE jobs.job = ?4 AND jobs.project=?5 AND jobs.node=?6
+`
+
+type SetJobAttemptParams struct {
+	Attempts    int64
+	Error       sql.NullString
+	NextRetryAt sql.NullTime
+	JobID       string
+	Project     string
+	Node        string
+}
+
+func (q *Queries) SetJobAttempt(ctx context.Context, arg SetJobAttemptParams) error {
+	_, err := q.exec(ctx, q.setJobAttemptStmt, setJobAttempt,
+		arg.Attempts,
+		arg.Error,
+		arg.NextRetryAt,
+		arg.JobID,
+		arg.Project,
+		arg.Node,
+	)
+	return err
+}
+
+const setJobKilled = `-- name: SetJobKilled :exec
+UPDATE jobs SET killed_at = ? WHERE id = ?
+`
+
+type SetJobKilledParams struct {
+	KilledAt sql.NullTime
+	ID       int64
+}
+
+func (q *Queries) SetJobKilled(ctx context.Context, arg SetJobKilledParams) error {
+	_, err := q.exec(ctx, q.setJobKilledStmt, setJobKilled, arg.KilledAt, arg.ID)
+	return err
+}
+
+const setJobSpiderArgs = `-- name: SetJobSpiderArgs :exec
+UPDATE jobs
+SET spider_args = ?
+WHERE jobs.job = ?2 AND jobs.project=?3 AND jobs.node=?4
+`
+
+type SetJobSpiderArgsParams struct {
+	SpiderArgs sql.NullString
+	JobID      string
+	Project    string
+	Node       string
+}
+
+func (q *Queries) SetJobSpiderArgs(ctx context.Context, arg SetJobSpiderArgsParams) error {
+	_, err := q.exec(ctx, q.setJobSpiderArgsStmt, setJobSpiderArgs,
+		arg.SpiderArgs,
+		arg.JobID,
+		arg.Project,
+		arg.Node,
+	)
+	return err
+}
+
+const setJobStatus = `-- name: SetJobStatus :exec
+UPDATE jobs
+SET status = ?, error = ?
+WHERE jobs.job = ?3 AND jobs.project=?4 AND jobs.node=?5
+`
+
+type SetJobStatusParams struct {
+	Status  string
+	Error   sql.NullString
+	JobID   string
+	Project string
+	Node    string
+}
+
+func (q *Queries) SetJobStatus(ctx context.Context, arg SetJobStatusParams) error {
+	_, err := q.exec(ctx, q.setJobStatusStmt, setJobStatus,
+		arg.Status,
+		arg.Error,
+		arg.JobID,
+		arg.Project,
+		arg.Node,
+	)
+	return err
+}
+
+const setJobTimedOut = `-- name: SetJobTimedOut :exec
+UPDATE jobs SET timed_out_at = ? WHERE id = ?
+`
+
+type SetJobTimedOutParams struct {
+	TimedOutAt sql.NullTime
+	ID         int64
+}
+
+func (q *Queries) SetJobTimedOut(ctx context.Context, arg SetJobTimedOutParams) error {
+	_, err := q.exec(ctx, q.setJobTimedOutStmt, setJobTimedOut, arg.TimedOutAt, arg.ID)
+	return err
+}
+
 const setStoppedByOnJob = `-- name: SetStoppedByOnJob :exec
 UPDATE jobs SET stopped_by=? WHERE job=? AND project=? AND node=?
 `
@@ -0,0 +1,78 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: maintenance_mode.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const endMaintenanceMode = `-- name: EndMaintenanceMode :execrows
+DELETE FROM maintenance_mode
+`
+
+func (q *Queries) EndMaintenanceMode(ctx context.Context) (int64, error) {
+	result, err := q.exec(ctx, q.endMaintenanceModeStmt, endMaintenanceMode)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getMaintenanceMode = `-- name: GetMaintenanceMode :one
+SELECT mm.id, mm.reason, mm.started_at, mm.started_by, mm.expires_at, u.username AS started_by_username
+FROM maintenance_mode mm
+         LEFT JOIN users u ON mm.started_by = u.id
+WHERE mm.expires_at IS NULL OR julianday(mm.expires_at) > julianday(?1)
+LIMIT 1
+`
+
+type GetMaintenanceModeRow struct {
+	ID                int64
+	Reason            string
+	StartedAt         time.Time
+	StartedBy         interface{}
+	ExpiresAt         sql.NullTime
+	StartedByUsername sql.NullString
+}
+
+// No rows while maintenance mode is off, including once it expired
+func (q *Queries) GetMaintenanceMode(ctx context.Context, now interface{}) (GetMaintenanceModeRow, error) {
+	row := q.queryRow(ctx, q.getMaintenanceModeStmt, getMaintenanceMode, now)
+	var i GetMaintenanceModeRow
+	err := row.Scan(
+		&i.ID,
+		&i.Reason,
+		&i.StartedAt,
+		&i.StartedBy,
+		&i.ExpiresAt,
+		&i.StartedByUsername,
+	)
+	return i, err
+}
+
+const startMaintenanceMode = `-- name: StartMaintenanceMode :exec
+INSERT OR REPLACE INTO maintenance_mode (id, reason, started_at, started_by, expires_at) VALUES (1, ?, ?, ?, ?)
+`
+
+type StartMaintenanceModeParams struct {
+	Reason    string
+	StartedAt time.Time
+	StartedBy interface{}
+	ExpiresAt sql.NullTime
+}
+
+// Starting maintenance mode while it's on replaces its reason and expiry
+func (q *Queries) StartMaintenanceMode(ctx context.Context, arg StartMaintenanceModeParams) error {
+	_, err := q.exec(ctx, q.startMaintenanceModeStmt, startMaintenanceMode,
+		arg.Reason,
+		arg.StartedAt,
+		arg.StartedBy,
+		arg.ExpiresAt,
+	)
+	return err
+}
@@ -11,28 +11,150 @@ import (
 	"github.com/google/uuid"
 )
 
-type Job struct {
+type AccessGrant struct {
+	ID        int64
+	UserID    interface{}
+	Role      sql.NullString
+	Project   string
+	Node      string
+	CreatedAt time.Time
+}
+
+type ApiToken struct {
+	ID         int64
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  []byte
+	Scope      string
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	LastUsedIp sql.NullString
+	Revoked    bool
+}
+
+type BlackoutCalendar struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time
+	CreatedBy interface{}
+}
+
+type BlackoutWindow struct {
 	ID         int64
-	Project    string
-	Spider     string
-	Job        string
-	Status     string
-	Deleted    bool
-	CreateTime time.Time
-	UpdateTime time.Time
-	Pages      sql.NullInt64
-	Items      sql.NullInt64
-	Pid        sql.NullInt64
-	Start      sql.NullTime
-	Runtime    sql.NullString
-	Finish     sql.NullTime
-	HrefLog    sql.NullString
-	HrefItems  sql.NullString
-	Node       string
-	TaskID     interface{}
-	Error      sql.NullString
-	StartedBy  interface{}
-	StoppedBy  interface{}
+	CalendarID int64
+	StartDate  string
+	EndDate    string
+	Weekdays   string
+	StartTime  string
+	EndTime    string
+	Note       string
+}
+
+type Job struct {
+	ID          int64
+	Project     string
+	Spider      string
+	Job         string
+	Status      string
+	Deleted     bool
+	CreateTime  time.Time
+	UpdateTime  time.Time
+	Pages       sql.NullInt64
+	Items       sql.NullInt64
+	Pid         sql.NullInt64
+	Start       sql.NullTime
+	Runtime     sql.NullString
+	Finish      sql.NullTime
+	HrefLog     sql.NullString
+	HrefItems   sql.NullString
+	Node        string
+	TaskID      interface{}
+	Error       sql.NullString
+	StartedBy   interface{}
+	StoppedBy   interface{}
+	TriggeredBy sql.NullString
+	Attempts    int64
+	NextRetryAt sql.NullTime
+	TimedOutAt  sql.NullTime
+	KilledAt    sql.NullTime
+	SpiderArgs  sql.NullString
+	QueuedUntil sql.NullTime
+}
+
+type MaintenanceMode struct {
+	ID        int64
+	Reason    string
+	StartedAt time.Time
+	StartedBy interface{}
+	ExpiresAt sql.NullTime
+}
+
+type NodeGroup struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time
+	CreatedBy interface{}
+}
+
+type NodeGroupMember struct {
+	GroupID int64
+	Node    string
+}
+
+type ProjectMaxRuntime struct {
+	Project           string
+	MaxRuntimeSeconds int64
+	CreatedAt         time.Time
+	CreatedBy         interface{}
+}
+
+type RecoveryCode struct {
+	ID        int64
+	UserID    uuid.UUID
+	CodeHash  []byte
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Role struct {
+	ID               int64
+	Name             string
+	Description      string
+	AccessRestricted bool
+}
+
+type RolePermission struct {
+	Role       string
+	Permission string
+}
+
+type ScheduledRun struct {
+	ID                uuid.UUID
+	Project           string
+	Spider            string
+	SettingsArguments string
+	RunAt             time.Time
+	Timezone          string
+	Status            string
+	FiredAt           sql.NullTime
+	Error             sql.NullString
+	CreatedAt         time.Time
+	CreatedBy         interface{}
+	ModifiedBy        interface{}
+}
+
+type ScheduledRunNode struct {
+	RunID uuid.UUID
+	Node  string
+}
+
+type SchedulerLease struct {
+	Name       string
+	Holder     string
+	AcquiredAt time.Time
+	RenewedAt  time.Time
+	ExpiresAt  time.Time
 }
 
 type ScrapydNode struct {
@@ -41,6 +163,7 @@ type ScrapydNode struct {
 	Url      string
 	Username sql.NullString
 	Password []byte
+	Capacity sql.NullInt64
 }
 
 type Setting struct {
@@ -50,19 +173,104 @@ type Setting struct {
 }
 
 type Task struct {
-	ID                uuid.UUID
-	Name              sql.NullString
-	CreateTime        time.Time
-	UpdateTime        time.Time
-	Project           string
-	Spider            string
-	Jobid             string
-	SettingsArguments string
-	SelectedNodes     string
-	CronString        string
-	Paused            bool
-	CreatedBy         interface{}
-	ModifiedBy        interface{}
+	ID                     uuid.UUID
+	Name                   sql.NullString
+	CreateTime             time.Time
+	UpdateTime             time.Time
+	Project                string
+	Spider                 string
+	Jobid                  string
+	SettingsArguments      string
+	CronString             string
+	Paused                 bool
+	CreatedBy              interface{}
+	ModifiedBy             interface{}
+	RetryMaxAttempts       int64
+	RetryBackoffSeconds    int64
+	RetryMaxBackoffSeconds int64
+	RetryOn                string
+	OverlapPolicy          string
+	FanOut                 string
+	RoundRobinNext         int64
+	MisfirePolicy          string
+	MisfireMaxRuns         int64
+	LastFiredAt            sql.NullTime
+	Timezone               string
+	MaxRuntimeSeconds      sql.NullInt64
+}
+
+type TaskBlackoutCalendar struct {
+	TaskID     uuid.UUID
+	CalendarID int64
+}
+
+type TaskDependency struct {
+	ID             int64
+	TaskID         uuid.UUID
+	UpstreamTaskID uuid.UUID
+	MinItems       int64
+	AfterJobID     int64
+	UpstreamJobID  sql.NullInt64
+	Satisfied      bool
+	CreatedAt      time.Time
+	CreatedBy      interface{}
+}
+
+type TaskRevision struct {
+	ID                     int64
+	TaskID                 uuid.UUID
+	Revision               int64
+	Name                   sql.NullString
+	Project                string
+	Spider                 string
+	SettingsArguments      string
+	CronString             string
+	Timezone               string
+	RetryMaxAttempts       int64
+	RetryBackoffSeconds    int64
+	RetryMaxBackoffSeconds int64
+	RetryOn                string
+	OverlapPolicy          string
+	FanOut                 string
+	MisfirePolicy          string
+	MisfireMaxRuns         int64
+	MaxRuntimeSeconds      sql.NullInt64
+	Nodes                  string
+	NodeGroups             string
+	BlackoutCalendars      string
+	RevertedFrom           sql.NullInt64
+	CreatedBy              interface{}
+	CreatedAt              time.Time
+}
+
+type TaskRun struct {
+	ID                int64
+	TaskID            uuid.UUID
+	TriggerSource     string
+	ScheduledAt       sql.NullTime
+	StartedAt         time.Time
+	FinishedAt        sql.NullTime
+	Node              string
+	JobID             string
+	Outcome           string
+	Error             sql.NullString
+	ScheduleLatencyMs sql.NullInt64
+}
+
+type TaskTarget struct {
+	ID      int64
+	TaskID  uuid.UUID
+	Node    sql.NullString
+	GroupID sql.NullInt64
+}
+
+type TaskWebhook struct {
+	ID          int64
+	TaskID      uuid.UUID
+	Secret      []byte
+	CreatedAt   time.Time
+	CreatedBy   interface{}
+	LastFiredAt sql.NullTime
 }
 
 type User struct {
@@ -70,5 +278,18 @@ type User struct {
 	CreatedAt          time.Time
 	Username           string
 	HashedPassword     string
-	HasAdminPrivileges bool
+	Role               string
+	TotpSecret         []byte
+	TotpEnabled        bool
+	TotpRequired       bool
+	TotpLastStep       int64
+	TotpFailedAttempts int64
+	TotpLockedUntil    sql.NullTime
+	AccessRestricted   bool
+}
+
+type WebhookNonce struct {
+	WebhookID int64
+	Nonce     string
+	SeenAt    time.Time
 }
@@ -0,0 +1,196 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: node_groups.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const deleteNodeGroup = `-- name: DeleteNodeGroup :execrows
+DELETE FROM node_groups WHERE id = ?
+`
+
+func (q *Queries) DeleteNodeGroup(ctx context.Context, id int64) (int64, error) {
+	result, err := q.exec(ctx, q.deleteNodeGroupStmt, deleteNodeGroup, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteNodeGroupMembers = `-- name: DeleteNodeGroupMembers :exec
+DELETE FROM node_group_members WHERE group_id = ?
+`
+
+func (q *Queries) DeleteNodeGroupMembers(ctx context.Context, groupID int64) error {
+	_, err := q.exec(ctx, q.deleteNodeGroupMembersStmt, deleteNodeGroupMembers, groupID)
+	return err
+}
+
+const getNodeGroup = `-- name: GetNodeGroup :one
+SELECT id, name, created_at, created_by FROM node_groups WHERE id = ?
+`
+
+func (q *Queries) GetNodeGroup(ctx context.Context, id int64) (NodeGroup, error) {
+	row := q.queryRow(ctx, q.getNodeGroupStmt, getNodeGroup, id)
+	var i NodeGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const getNodeGroupByName = `-- name: GetNodeGroupByName :one
+SELECT id, name, created_at, created_by FROM node_groups WHERE name = ?
+`
+
+func (q *Queries) GetNodeGroupByName(ctx context.Context, name string) (NodeGroup, error) {
+	row := q.queryRow(ctx, q.getNodeGroupByNameStmt, getNodeGroupByName, name)
+	var i NodeGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const insertNodeGroup = `-- name: InsertNodeGroup :one
+INSERT INTO node_groups (name, created_by) VALUES (?, ?) RETURNING id, name, created_at, created_by
+`
+
+type InsertNodeGroupParams struct {
+	Name      string
+	CreatedBy interface{}
+}
+
+func (q *Queries) InsertNodeGroup(ctx context.Context, arg InsertNodeGroupParams) (NodeGroup, error) {
+	row := q.queryRow(ctx, q.insertNodeGroupStmt, insertNodeGroup, arg.Name, arg.CreatedBy)
+	var i NodeGroup
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const insertNodeGroupMember = `-- name: InsertNodeGroupMember :exec
+INSERT OR IGNORE INTO node_group_members (group_id, node) VALUES (?, ?)
+`
+
+type InsertNodeGroupMemberParams struct {
+	GroupID int64
+	Node    string
+}
+
+func (q *Queries) InsertNodeGroupMember(ctx context.Context, arg InsertNodeGroupMemberParams) error {
+	_, err := q.exec(ctx, q.insertNodeGroupMemberStmt, insertNodeGroupMember, arg.GroupID, arg.Node)
+	return err
+}
+
+const listNodeGroupMembers = `-- name: ListNodeGroupMembers :many
+SELECT group_id, node FROM node_group_members ORDER BY group_id, node
+`
+
+func (q *Queries) ListNodeGroupMembers(ctx context.Context) ([]NodeGroupMember, error) {
+	rows, err := q.query(ctx, q.listNodeGroupMembersStmt, listNodeGroupMembers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NodeGroupMember
+	for rows.Next() {
+		var i NodeGroupMember
+		if err := rows.Scan(&i.GroupID, &i.Node); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNodeGroups = `-- name: ListNodeGroups :many
+SELECT g.id, g.name, g.created_at, u.username AS created_by_username
+FROM node_groups g
+         LEFT JOIN users u ON g.created_by = u.ID
+ORDER BY g.name
+`
+
+type ListNodeGroupsRow struct {
+	ID                int64
+	Name              string
+	CreatedAt         time.Time
+	CreatedByUsername sql.NullString
+}
+
+func (q *Queries) ListNodeGroups(ctx context.Context) ([]ListNodeGroupsRow, error) {
+	rows, err := q.query(ctx, q.listNodeGroupsStmt, listNodeGroups)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListNodeGroupsRow
+	for rows.Next() {
+		var i ListNodeGroupsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.CreatedByUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNodesInGroup = `-- name: ListNodesInGroup :many
+SELECT node FROM node_group_members WHERE group_id = ? ORDER BY node
+`
+
+func (q *Queries) ListNodesInGroup(ctx context.Context, groupID int64) ([]string, error) {
+	rows, err := q.query(ctx, q.listNodesInGroupStmt, listNodesInGroup, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var node string
+		if err := rows.Scan(&node); err != nil {
+			return nil, err
+		}
+		items = append(items, node)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,71 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: project_max_runtimes.sql
+
+package database
+
+import (
+	"context"
+)
+
+const deleteProjectMaxRuntime = `-- name: DeleteProjectMaxRuntime :execrows
+DELETE FROM project_max_runtimes WHERE project = ?
+`
+
+func (q *Queries) DeleteProjectMaxRuntime(ctx context.Context, project string) (int64, error) {
+	result, err := q.exec(ctx, q.deleteProjectMaxRuntimeStmt, deleteProjectMaxRuntime, project)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listProjectMaxRuntimes = `-- name: ListProjectMaxRuntimes :many
+SELECT project, max_runtime_seconds, created_at, created_by FROM project_max_runtimes ORDER BY project
+`
+
+func (q *Queries) ListProjectMaxRuntimes(ctx context.Context) ([]ProjectMaxRuntime, error) {
+	rows, err := q.query(ctx, q.listProjectMaxRuntimesStmt, listProjectMaxRuntimes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ProjectMaxRuntime
+	for rows.Next() {
+		var i ProjectMaxRuntime
+		if err := rows.Scan(
+			&i.Project,
+			&i.MaxRuntimeSeconds,
+			&i.CreatedAt,
+			&i.CreatedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertProjectMaxRuntime = `-- name: UpsertProjectMaxRuntime :exec
+INSERT INTO project_max_runtimes (project, max_runtime_seconds, created_by)
+VALUES (?, ?, ?)
+ON CONFLICT(project) DO UPDATE SET max_runtime_seconds = EXCLUDED.max_runtime_seconds, created_by = EXCLUDED.created_by
+`
+
+type UpsertProjectMaxRuntimeParams struct {
+	Project           string
+	MaxRuntimeSeconds int64
+	CreatedBy         interface{}
+}
+
+func (q *Queries) UpsertProjectMaxRuntime(ctx context.Context, arg UpsertProjectMaxRuntimeParams) error {
+	_, err := q.exec(ctx, q.upsertProjectMaxRuntimeStmt, upsertProjectMaxRuntime, arg.Project, arg.MaxRuntimeSeconds, arg.CreatedBy)
+	return err
+}
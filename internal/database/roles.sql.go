@@ -0,0 +1,85 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: roles.sql
+
+package database
+
+import (
+	"context"
+)
+
+const getRoleWithName = `-- name: GetRoleWithName :one
+SELECT id, name, description, access_restricted FROM roles WHERE name = ? LIMIT 1
+`
+
+func (q *Queries) GetRoleWithName(ctx context.Context, name string) (Role, error) {
+	row := q.queryRow(ctx, q.getRoleWithNameStmt, getRoleWithName, name)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.AccessRestricted,
+	)
+	return i, err
+}
+
+const listPermissionsForRole = `-- name: ListPermissionsForRole :many
+SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission
+`
+
+func (q *Queries) ListPermissionsForRole(ctx context.Context, role string) ([]string, error) {
+	rows, err := q.query(ctx, q.listPermissionsForRoleStmt, listPermissionsForRole, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		items = append(items, permission)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRoles = `-- name: ListRoles :many
+SELECT id, name, description, access_restricted FROM roles ORDER BY id
+`
+
+func (q *Queries) ListRoles(ctx context.Context) ([]Role, error) {
+	rows, err := q.query(ctx, q.listRolesStmt, listRoles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Role
+	for rows.Next() {
+		var i Role
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.AccessRestricted,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,321 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: scheduled_runs.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimScheduledRun = `-- name: ClaimScheduledRun :execrows
+UPDATE scheduled_runs SET status = 'firing', fired_at = ?1
+WHERE id = ?2 AND status = 'pending' AND julianday(run_at) = julianday(?3)
+`
+
+type ClaimScheduledRunParams struct {
+	FiredAt sql.NullTime
+	ID      uuid.UUID
+	RunAt   interface{}
+}
+
+// Claims a due run for firing, no rows are affected when it was cancelled, changed or claimed in the meantime
+func (q *Queries) ClaimScheduledRun(ctx context.Context, arg ClaimScheduledRunParams) (int64, error) {
+	result, err := q.exec(ctx, q.claimScheduledRunStmt, claimScheduledRun, arg.FiredAt, arg.ID, arg.RunAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteScheduledRun = `-- name: DeleteScheduledRun :execrows
+DELETE FROM scheduled_runs WHERE id = ?
+`
+
+func (q *Queries) DeleteScheduledRun(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.exec(ctx, q.deleteScheduledRunStmt, deleteScheduledRun, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteScheduledRunNodes = `-- name: DeleteScheduledRunNodes :exec
+DELETE FROM scheduled_run_nodes WHERE run_id = ?
+`
+
+func (q *Queries) DeleteScheduledRunNodes(ctx context.Context, runID uuid.UUID) error {
+	_, err := q.exec(ctx, q.deleteScheduledRunNodesStmt, deleteScheduledRunNodes, runID)
+	return err
+}
+
+const finishScheduledRun = `-- name: FinishScheduledRun :exec
+UPDATE scheduled_runs SET status = ?, error = ? WHERE id = ?
+`
+
+type FinishScheduledRunParams struct {
+	Status string
+	Error  sql.NullString
+	ID     uuid.UUID
+}
+
+func (q *Queries) FinishScheduledRun(ctx context.Context, arg FinishScheduledRunParams) error {
+	_, err := q.exec(ctx, q.finishScheduledRunStmt, finishScheduledRun, arg.Status, arg.Error, arg.ID)
+	return err
+}
+
+const getScheduledRun = `-- name: GetScheduledRun :one
+SELECT id, project, spider, settings_arguments, run_at, timezone, status, fired_at, error, created_at, created_by, modified_by FROM scheduled_runs WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetScheduledRun(ctx context.Context, id uuid.UUID) (ScheduledRun, error) {
+	row := q.queryRow(ctx, q.getScheduledRunStmt, getScheduledRun, id)
+	var i ScheduledRun
+	err := row.Scan(
+		&i.ID,
+		&i.Project,
+		&i.Spider,
+		&i.SettingsArguments,
+		&i.RunAt,
+		&i.Timezone,
+		&i.Status,
+		&i.FiredAt,
+		&i.Error,
+		&i.CreatedAt,
+		&i.CreatedBy,
+		&i.ModifiedBy,
+	)
+	return i, err
+}
+
+const insertScheduledRun = `-- name: InsertScheduledRun :exec
+INSERT INTO scheduled_runs (id, project, spider, settings_arguments, run_at, timezone, created_by)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+`
+
+type InsertScheduledRunParams struct {
+	ID                uuid.UUID
+	Project           string
+	Spider            string
+	SettingsArguments string
+	RunAt             time.Time
+	Timezone          string
+	CreatedBy         interface{}
+}
+
+func (q *Queries) InsertScheduledRun(ctx context.Context, arg InsertScheduledRunParams) error {
+	_, err := q.exec(ctx, q.insertScheduledRunStmt, insertScheduledRun,
+		arg.ID,
+		arg.Project,
+		arg.Spider,
+		arg.SettingsArguments,
+		arg.RunAt,
+		arg.Timezone,
+		arg.CreatedBy,
+	)
+	return err
+}
+
+const insertScheduledRunNode = `-- name: InsertScheduledRunNode :exec
+INSERT OR IGNORE INTO scheduled_run_nodes (run_id, node) VALUES (?, ?)
+`
+
+type InsertScheduledRunNodeParams struct {
+	RunID uuid.UUID
+	Node  string
+}
+
+func (q *Queries) InsertScheduledRunNode(ctx context.Context, arg InsertScheduledRunNodeParams) error {
+	_, err := q.exec(ctx, q.insertScheduledRunNodeStmt, insertScheduledRunNode, arg.RunID, arg.Node)
+	return err
+}
+
+const listAllScheduledRunNodes = `-- name: ListAllScheduledRunNodes :many
+SELECT run_id, node FROM scheduled_run_nodes ORDER BY run_id, node
+`
+
+func (q *Queries) ListAllScheduledRunNodes(ctx context.Context) ([]ScheduledRunNode, error) {
+	rows, err := q.query(ctx, q.listAllScheduledRunNodesStmt, listAllScheduledRunNodes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledRunNode
+	for rows.Next() {
+		var i ScheduledRunNode
+		if err := rows.Scan(&i.RunID, &i.Node); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDueScheduledRuns = `-- name: ListDueScheduledRuns :many
+SELECT id, project, spider, settings_arguments, run_at, timezone, status, fired_at, error, created_at, created_by, modified_by FROM scheduled_runs WHERE status = 'pending' AND julianday(run_at) <= julianday(?1) ORDER BY run_at
+`
+
+func (q *Queries) ListDueScheduledRuns(ctx context.Context, now interface{}) ([]ScheduledRun, error) {
+	rows, err := q.query(ctx, q.listDueScheduledRunsStmt, listDueScheduledRuns, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledRun
+	for rows.Next() {
+		var i ScheduledRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.Project,
+			&i.Spider,
+			&i.SettingsArguments,
+			&i.RunAt,
+			&i.Timezone,
+			&i.Status,
+			&i.FiredAt,
+			&i.Error,
+			&i.CreatedAt,
+			&i.CreatedBy,
+			&i.ModifiedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledRunNodes = `-- name: ListScheduledRunNodes :many
+SELECT node FROM scheduled_run_nodes WHERE run_id = ? ORDER BY node
+`
+
+func (q *Queries) ListScheduledRunNodes(ctx context.Context, runID uuid.UUID) ([]string, error) {
+	rows, err := q.query(ctx, q.listScheduledRunNodesStmt, listScheduledRunNodes, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var node string
+		if err := rows.Scan(&node); err != nil {
+			return nil, err
+		}
+		items = append(items, node)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledRuns = `-- name: ListScheduledRuns :many
+SELECT sr.id, sr.project, sr.spider, sr.settings_arguments, sr.run_at, sr.timezone, sr.status, sr.fired_at, sr.error, sr.created_at, sr.created_by, sr.modified_by, u.username AS created_by_username
+FROM scheduled_runs sr
+         LEFT JOIN users u ON sr.created_by = u.id
+ORDER BY sr.status != 'pending',
+         CASE WHEN sr.status = 'pending' THEN julianday(sr.run_at) ELSE -julianday(sr.fired_at) END
+`
+
+type ListScheduledRunsRow struct {
+	ID                uuid.UUID
+	Project           string
+	Spider            string
+	SettingsArguments string
+	RunAt             time.Time
+	Timezone          string
+	Status            string
+	FiredAt           sql.NullTime
+	Error             sql.NullString
+	CreatedAt         time.Time
+	CreatedBy         interface{}
+	ModifiedBy        interface{}
+	CreatedByUsername sql.NullString
+}
+
+// Runs which are yet to fire come first, soonest first, followed by the ones which already fired, most recent first
+func (q *Queries) ListScheduledRuns(ctx context.Context) ([]ListScheduledRunsRow, error) {
+	rows, err := q.query(ctx, q.listScheduledRunsStmt, listScheduledRuns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListScheduledRunsRow
+	for rows.Next() {
+		var i ListScheduledRunsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Project,
+			&i.Spider,
+			&i.SettingsArguments,
+			&i.RunAt,
+			&i.Timezone,
+			&i.Status,
+			&i.FiredAt,
+			&i.Error,
+			&i.CreatedAt,
+			&i.CreatedBy,
+			&i.ModifiedBy,
+			&i.CreatedByUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateScheduledRun = `-- name: UpdateScheduledRun :execrows
+UPDATE scheduled_runs
+SET settings_arguments = ?, run_at = ?, timezone = ?, modified_by = ?
+WHERE id = ? AND status = 'pending'
+`
+
+type UpdateScheduledRunParams struct {
+	SettingsArguments string
+	RunAt             time.Time
+	Timezone          string
+	ModifiedBy        interface{}
+	ID                uuid.UUID
+}
+
+// Only a run which hasn't fired yet can be changed
+func (q *Queries) UpdateScheduledRun(ctx context.Context, arg UpdateScheduledRunParams) (int64, error) {
+	result, err := q.exec(ctx, q.updateScheduledRunStmt, updateScheduledRun,
+		arg.SettingsArguments,
+		arg.RunAt,
+		arg.Timezone,
+		arg.ModifiedBy,
+		arg.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
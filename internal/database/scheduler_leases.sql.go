@@ -0,0 +1,76 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: scheduler_leases.sql
+
+package database
+
+import (
+	"context"
+	"time"
+)
+
+const acquireSchedulerLease = `-- name: AcquireSchedulerLease :execrows
+INSERT INTO scheduler_leases (name, holder, acquired_at, renewed_at, expires_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+    holder = EXCLUDED.holder,
+    acquired_at = CASE WHEN scheduler_leases.holder = EXCLUDED.holder THEN scheduler_leases.acquired_at ELSE EXCLUDED.acquired_at END,
+    renewed_at = EXCLUDED.renewed_at,
+    expires_at = EXCLUDED.expires_at
+WHERE scheduler_leases.holder = EXCLUDED.holder OR julianday(scheduler_leases.expires_at) <= julianday(EXCLUDED.renewed_at)
+`
+
+type AcquireSchedulerLeaseParams struct {
+	Name       string
+	Holder     string
+	AcquiredAt time.Time
+	RenewedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// Takes the lease when nobody holds it, renews it when the holder already has it or takes it over once it expired. No
+// rows are affected while another holder has it.
+func (q *Queries) AcquireSchedulerLease(ctx context.Context, arg AcquireSchedulerLeaseParams) (int64, error) {
+	result, err := q.exec(ctx, q.acquireSchedulerLeaseStmt, acquireSchedulerLease,
+		arg.Name,
+		arg.Holder,
+		arg.AcquiredAt,
+		arg.RenewedAt,
+		arg.ExpiresAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getSchedulerLease = `-- name: GetSchedulerLease :one
+SELECT name, holder, acquired_at, renewed_at, expires_at FROM scheduler_leases WHERE name = ? LIMIT 1
+`
+
+func (q *Queries) GetSchedulerLease(ctx context.Context, name string) (SchedulerLease, error) {
+	row := q.queryRow(ctx, q.getSchedulerLeaseStmt, getSchedulerLease, name)
+	var i SchedulerLease
+	err := row.Scan(
+		&i.Name,
+		&i.Holder,
+		&i.AcquiredAt,
+		&i.RenewedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const releaseSchedulerLease = `-- name: ReleaseSchedulerLease :exec
+DELETE FROM scheduler_leases WHERE name = ? AND holder = ?
+`
+
+type ReleaseSchedulerLeaseParams struct {
+	Name   string
+	Holder string
+}
+
+func (q *Queries) ReleaseSchedulerLease(ctx context.Context, arg ReleaseSchedulerLeaseParams) error {
+	_, err := q.exec(ctx, q.releaseSchedulerLeaseStmt, releaseSchedulerLease, arg.Name, arg.Holder)
+	return err
+}
@@ -20,7 +20,7 @@ func (q *Queries) DeleteScrapydNodes(ctx context.Context, nodename string) error
 }
 
 const getNodeWithName = `-- name: GetNodeWithName :one
-SELECT id, nodename, url, username, password FROM scrapyd_nodes WHERE nodeName = ? LIMIT 1
+SELECT id, nodename, url, username, password, capacity FROM scrapyd_nodes WHERE nodeName = ? LIMIT 1
 `
 
 func (q *Queries) GetNodeWithName(ctx context.Context, nodename string) (ScrapydNode, error) {
@@ -32,12 +32,13 @@ func (q *Queries) GetNodeWithName(ctx context.Context, nodename string) (Scrapyd
 		&i.Url,
 		&i.Username,
 		&i.Password,
+		&i.Capacity,
 	)
 	return i, err
 }
 
 const listScrapydNodes = `-- name: ListScrapydNodes :many
-SELECT id, nodename, url, username, password FROM scrapyd_nodes
+SELECT id, nodename, url, username, password, capacity FROM scrapyd_nodes
 `
 
 func (q *Queries) ListScrapydNodes(ctx context.Context) ([]ScrapydNode, error) {
@@ -55,6 +56,7 @@ func (q *Queries) ListScrapydNodes(ctx context.Context) ([]ScrapydNode, error) {
 			&i.Url,
 			&i.Username,
 			&i.Password,
+			&i.Capacity,
 		); err != nil {
 			return nil, err
 		}
@@ -71,8 +73,8 @@ func (q *Queries) ListScrapydNodes(ctx context.Context) ([]ScrapydNode, error) {
 
 const newScrapydNode = `-- name: NewScrapydNode :one
 INSERT INTO scrapyd_nodes (
-    nodeName, URL, username, password
-) VALUES (?, ?, ?, ?) RETURNING id, nodename, url, username, password
+    nodeName, URL, username, password, capacity
+) VALUES (?, ?, ?, ?, ?) RETURNING id, nodename, url, username, password, capacity
 `
 
 type NewScrapydNodeParams struct {
@@ -80,6 +82,7 @@ type NewScrapydNodeParams struct {
 	Url      string
 	Username sql.NullString
 	Password []byte
+	Capacity sql.NullInt64
 }
 
 func (q *Queries) NewScrapydNode(ctx context.Context, arg NewScrapydNodeParams) (ScrapydNode, error) {
@@ -88,6 +91,7 @@ func (q *Queries) NewScrapydNode(ctx context.Context, arg NewScrapydNodeParams)
 		arg.Url,
 		arg.Username,
 		arg.Password,
+		arg.Capacity,
 	)
 	var i ScrapydNode
 	err := row.Scan(
@@ -96,13 +100,14 @@ func (q *Queries) NewScrapydNode(ctx context.Context, arg NewScrapydNodeParams)
 		&i.Url,
 		&i.Username,
 		&i.Password,
+		&i.Capacity,
 	)
 	return i, err
 }
 
 const updateNodeWhereName = `-- name: UpdateNodeWhereName :exec
 UPDATE scrapyd_nodes SET nodeName = ?1, URL = ?2, username = ?3,
-                         password = ?4 WHERE nodeName = ?5
+                         password = ?4, capacity = ?5 WHERE nodeName = ?6
 `
 
 type UpdateNodeWhereNameParams struct {
@@ -110,6 +115,7 @@ type UpdateNodeWhereNameParams struct {
 	NewURL      string
 	NewUsername sql.NullString
 	NewPassword []byte
+	NewCapacity sql.NullInt64
 	OldNodeName string
 }
 
@@ -119,6 +125,7 @@ func (q *Queries) UpdateNodeWhereName(ctx context.Context, arg UpdateNodeWhereNa
 		arg.NewURL,
 		arg.NewUsername,
 		arg.NewPassword,
+		arg.NewCapacity,
 		arg.OldNodeName,
 	)
 	return err
@@ -0,0 +1,313 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: task_dependencies.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteTaskDependency = `-- name: DeleteTaskDependency :execrows
+DELETE FROM task_dependencies WHERE id = ?
+`
+
+func (q *Queries) DeleteTaskDependency(ctx context.Context, id int64) (int64, error) {
+	result, err := q.exec(ctx, q.deleteTaskDependencyStmt, deleteTaskDependency, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getTaskDependency = `-- name: GetTaskDependency :one
+SELECT id, task_id, upstream_task_id, min_items, after_job_id, upstream_job_id, satisfied, created_at, created_by FROM task_dependencies WHERE id = ?
+`
+
+func (q *Queries) GetTaskDependency(ctx context.Context, id int64) (TaskDependency, error) {
+	row := q.queryRow(ctx, q.getTaskDependencyStmt, getTaskDependency, id)
+	var i TaskDependency
+	err := row.Scan(
+		&i.ID,
+		&i.TaskID,
+		&i.UpstreamTaskID,
+		&i.MinItems,
+		&i.AfterJobID,
+		&i.UpstreamJobID,
+		&i.Satisfied,
+		&i.CreatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const insertTaskDependency = `-- name: InsertTaskDependency :one
+INSERT INTO task_dependencies (task_id, upstream_task_id, min_items, after_job_id, created_by)
+VALUES (?1, ?2, ?3,
+        (SELECT COALESCE(MAX(id), 0) FROM jobs WHERE jobs.task_id = ?2), ?4)
+RETURNING id, task_id, upstream_task_id, min_items, after_job_id, upstream_job_id, satisfied, created_at, created_by
+`
+
+type InsertTaskDependencyParams struct {
+	TaskID         uuid.UUID
+	UpstreamTaskID uuid.UUID
+	MinItems       int64
+	CreatedBy      interface{}
+}
+
+func (q *Queries) InsertTaskDependency(ctx context.Context, arg InsertTaskDependencyParams) (TaskDependency, error) {
+	row := q.queryRow(ctx, q.insertTaskDependencyStmt, insertTaskDependency,
+		arg.TaskID,
+		arg.UpstreamTaskID,
+		arg.MinItems,
+		arg.CreatedBy,
+	)
+	var i TaskDependency
+	err := row.Scan(
+		&i.ID,
+		&i.TaskID,
+		&i.UpstreamTaskID,
+		&i.MinItems,
+		&i.AfterJobID,
+		&i.UpstreamJobID,
+		&i.Satisfied,
+		&i.CreatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const listDependenciesForTask = `-- name: ListDependenciesForTask :many
+SELECT id, task_id, upstream_task_id, min_items, after_job_id, upstream_job_id, satisfied, created_at, created_by FROM task_dependencies WHERE task_id = ? ORDER BY id
+`
+
+func (q *Queries) ListDependenciesForTask(ctx context.Context, taskID uuid.UUID) ([]TaskDependency, error) {
+	rows, err := q.query(ctx, q.listDependenciesForTaskStmt, listDependenciesForTask, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TaskDependency
+	for rows.Next() {
+		var i TaskDependency
+		if err := rows.Scan(
+			&i.ID,
+			&i.TaskID,
+			&i.UpstreamTaskID,
+			&i.MinItems,
+			&i.AfterJobID,
+			&i.UpstreamJobID,
+			&i.Satisfied,
+			&i.CreatedAt,
+			&i.CreatedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDependenciesWaitingOnJob = `-- name: ListDependenciesWaitingOnJob :many
+SELECT d.id, d.task_id, d.upstream_task_id, d.min_items, d.after_job_id, d.upstream_job_id, d.satisfied, d.created_at, d.created_by FROM task_dependencies d
+    JOIN jobs j ON j.task_id = d.upstream_task_id
+WHERE j.id = ? AND j.id > d.after_job_id
+ORDER BY d.id
+`
+
+func (q *Queries) ListDependenciesWaitingOnJob(ctx context.Context, id int64) ([]TaskDependency, error) {
+	rows, err := q.query(ctx, q.listDependenciesWaitingOnJobStmt, listDependenciesWaitingOnJob, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TaskDependency
+	for rows.Next() {
+		var i TaskDependency
+		if err := rows.Scan(
+			&i.ID,
+			&i.TaskID,
+			&i.UpstreamTaskID,
+			&i.MinItems,
+			&i.AfterJobID,
+			&i.UpstreamJobID,
+			&i.Satisfied,
+			&i.CreatedAt,
+			&i.CreatedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDependencyRuns = `-- name: ListDependencyRuns :many
+SELECT j.id, j.job, j.status, j.items, j.create_time, j.node, t.id AS task_id, t.name AS task_name, t.project AS task_project
+FROM jobs j
+         JOIN tasks t ON j.task_id = t.id
+WHERE j.triggered_by = 'dependencies' AND j.deleted = 0
+ORDER BY j.id DESC
+LIMIT ?
+`
+
+type ListDependencyRunsRow struct {
+	ID          int64
+	Job         string
+	Status      string
+	Items       sql.NullInt64
+	CreateTime  time.Time
+	Node        string
+	TaskID      uuid.UUID
+	TaskName    sql.NullString
+	TaskProject string
+}
+
+func (q *Queries) ListDependencyRuns(ctx context.Context, limit int64) ([]ListDependencyRunsRow, error) {
+	rows, err := q.query(ctx, q.listDependencyRunsStmt, listDependencyRuns, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDependencyRunsRow
+	for rows.Next() {
+		var i ListDependencyRunsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Job,
+			&i.Status,
+			&i.Items,
+			&i.CreateTime,
+			&i.Node,
+			&i.TaskID,
+			&i.TaskName,
+			&i.TaskProject,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTaskDependencies = `-- name: ListTaskDependencies :many
+SELECT d.id, d.task_id, d.upstream_task_id, d.min_items, d.upstream_job_id, d.satisfied, d.created_at,
+       t.name AS task_name, t.project AS task_project, CAST(COALESCE((SELECT GROUP_CONCAT(COALESCE(tt.node, g.name), ', ') FROM task_targets tt
+           LEFT JOIN node_groups g ON tt.group_id = g.id WHERE tt.task_id = t.id), '') AS TEXT) AS task_targets,
+       u.name AS upstream_name, u.project AS upstream_project, CAST(COALESCE((SELECT GROUP_CONCAT(COALESCE(tt.node, g.name), ', ') FROM task_targets tt
+           LEFT JOIN node_groups g ON tt.group_id = g.id WHERE tt.task_id = u.id), '') AS TEXT) AS upstream_targets,
+       j.job AS upstream_job, j.status AS upstream_job_status, j.items AS upstream_job_items
+FROM task_dependencies d
+         JOIN tasks t ON d.task_id = t.id
+         JOIN tasks u ON d.upstream_task_id = u.id
+         LEFT JOIN jobs j ON d.upstream_job_id = j.id
+ORDER BY d.id
+`
+
+type ListTaskDependenciesRow struct {
+	ID                int64
+	TaskID            uuid.UUID
+	UpstreamTaskID    uuid.UUID
+	MinItems          int64
+	UpstreamJobID     sql.NullInt64
+	Satisfied         bool
+	CreatedAt         time.Time
+	TaskName          sql.NullString
+	TaskProject       string
+	TaskTargets       string
+	UpstreamName      sql.NullString
+	UpstreamProject   string
+	UpstreamTargets   string
+	UpstreamJob       sql.NullString
+	UpstreamJobStatus sql.NullString
+	UpstreamJobItems  sql.NullInt64
+}
+
+func (q *Queries) ListTaskDependencies(ctx context.Context) ([]ListTaskDependenciesRow, error) {
+	rows, err := q.query(ctx, q.listTaskDependenciesStmt, listTaskDependencies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTaskDependenciesRow
+	for rows.Next() {
+		var i ListTaskDependenciesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TaskID,
+			&i.UpstreamTaskID,
+			&i.MinItems,
+			&i.UpstreamJobID,
+			&i.Satisfied,
+			&i.CreatedAt,
+			&i.TaskName,
+			&i.TaskProject,
+			&i.TaskTargets,
+			&i.UpstreamName,
+			&i.UpstreamProject,
+			&i.UpstreamTargets,
+			&i.UpstreamJob,
+			&i.UpstreamJobStatus,
+			&i.UpstreamJobItems,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resetTaskDependencies = `-- name: ResetTaskDependencies :exec
+UPDATE task_dependencies
+SET after_job_id = COALESCE(upstream_job_id, after_job_id), upstream_job_id = NULL, satisfied = FALSE
+WHERE task_id = ?
+`
+
+func (q *Queries) ResetTaskDependencies(ctx context.Context, taskID uuid.UUID) error {
+	_, err := q.exec(ctx, q.resetTaskDependenciesStmt, resetTaskDependencies, taskID)
+	return err
+}
+
+const setTaskDependencyUpstreamJob = `-- name: SetTaskDependencyUpstreamJob :exec
+UPDATE task_dependencies SET upstream_job_id = ?, satisfied = ? WHERE id = ?
+`
+
+type SetTaskDependencyUpstreamJobParams struct {
+	UpstreamJobID sql.NullInt64
+	Satisfied     bool
+	ID            int64
+}
+
+func (q *Queries) SetTaskDependencyUpstreamJob(ctx context.Context, arg SetTaskDependencyUpstreamJobParams) error {
+	_, err := q.exec(ctx, q.setTaskDependencyUpstreamJobStmt, setTaskDependencyUpstreamJob, arg.UpstreamJobID, arg.Satisfied, arg.ID)
+	return err
+}
@@ -0,0 +1,215 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: task_revisions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getTaskRevision = `-- name: GetTaskRevision :one
+SELECT id, task_id, revision, name, project, spider, settings_arguments, cron_string, timezone, retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy, fan_out, misfire_policy, misfire_max_runs, max_runtime_seconds, nodes, node_groups, blackout_calendars, reverted_from, created_by, created_at FROM task_revisions WHERE task_id = ? AND revision = ? LIMIT 1
+`
+
+type GetTaskRevisionParams struct {
+	TaskID   uuid.UUID
+	Revision int64
+}
+
+func (q *Queries) GetTaskRevision(ctx context.Context, arg GetTaskRevisionParams) (TaskRevision, error) {
+	row := q.queryRow(ctx, q.getTaskRevisionStmt, getTaskRevision, arg.TaskID, arg.Revision)
+	var i TaskRevision
+	err := row.Scan(
+		&i.ID,
+		&i.TaskID,
+		&i.Revision,
+		&i.Name,
+		&i.Project,
+		&i.Spider,
+		&i.SettingsArguments,
+		&i.CronString,
+		&i.Timezone,
+		&i.RetryMaxAttempts,
+		&i.RetryBackoffSeconds,
+		&i.RetryMaxBackoffSeconds,
+		&i.RetryOn,
+		&i.OverlapPolicy,
+		&i.FanOut,
+		&i.MisfirePolicy,
+		&i.MisfireMaxRuns,
+		&i.MaxRuntimeSeconds,
+		&i.Nodes,
+		&i.NodeGroups,
+		&i.BlackoutCalendars,
+		&i.RevertedFrom,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const insertTaskRevision = `-- name: InsertTaskRevision :one
+INSERT INTO task_revisions (task_id, revision, name, project, spider, settings_arguments, cron_string, timezone,
+                            retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy,
+                            fan_out, misfire_policy, misfire_max_runs, max_runtime_seconds, nodes, node_groups,
+                            blackout_calendars, reverted_from, created_by, created_at)
+VALUES (?1,
+        (SELECT COALESCE(MAX(r.revision), 0) + 1 FROM task_revisions r WHERE r.task_id = ?1),
+        ?2, ?3, ?4, ?5, ?6,
+        ?7, ?8, ?9,
+        ?10, ?11, ?12, ?13,
+        ?14, ?15, ?16, ?17,
+        ?18, ?19, ?20, ?21,
+        ?22)
+RETURNING id, task_id, revision, name, project, spider, settings_arguments, cron_string, timezone, retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy, fan_out, misfire_policy, misfire_max_runs, max_runtime_seconds, nodes, node_groups, blackout_calendars, reverted_from, created_by, created_at
+`
+
+type InsertTaskRevisionParams struct {
+	TaskID                 uuid.UUID
+	Name                   sql.NullString
+	Project                string
+	Spider                 string
+	SettingsArguments      string
+	CronString             string
+	Timezone               string
+	RetryMaxAttempts       int64
+	RetryBackoffSeconds    int64
+	RetryMaxBackoffSeconds int64
+	RetryOn                string
+	OverlapPolicy          string
+	FanOut                 string
+	MisfirePolicy          string
+	MisfireMaxRuns         int64
+	MaxRuntimeSeconds      sql.NullInt64
+	Nodes                  string
+	NodeGroups             string
+	BlackoutCalendars      string
+	RevertedFrom           sql.NullInt64
+	CreatedBy              interface{}
+	CreatedAt              time.Time
+}
+
+// Revisions are only ever appended, there's no query to change one
+func (q *Queries) InsertTaskRevision(ctx context.Context, arg InsertTaskRevisionParams) (TaskRevision, error) {
+	row := q.queryRow(ctx, q.insertTaskRevisionStmt, insertTaskRevision,
+		arg.TaskID,
+		arg.Name,
+		arg.Project,
+		arg.Spider,
+		arg.SettingsArguments,
+		arg.CronString,
+		arg.Timezone,
+		arg.RetryMaxAttempts,
+		arg.RetryBackoffSeconds,
+		arg.RetryMaxBackoffSeconds,
+		arg.RetryOn,
+		arg.OverlapPolicy,
+		arg.FanOut,
+		arg.MisfirePolicy,
+		arg.MisfireMaxRuns,
+		arg.MaxRuntimeSeconds,
+		arg.Nodes,
+		arg.NodeGroups,
+		arg.BlackoutCalendars,
+		arg.RevertedFrom,
+		arg.CreatedBy,
+		arg.CreatedAt,
+	)
+	var i TaskRevision
+	err := row.Scan(
+		&i.ID,
+		&i.TaskID,
+		&i.Revision,
+		&i.Name,
+		&i.Project,
+		&i.Spider,
+		&i.SettingsArguments,
+		&i.CronString,
+		&i.Timezone,
+		&i.RetryMaxAttempts,
+		&i.RetryBackoffSeconds,
+		&i.RetryMaxBackoffSeconds,
+		&i.RetryOn,
+		&i.OverlapPolicy,
+		&i.FanOut,
+		&i.MisfirePolicy,
+		&i.MisfireMaxRuns,
+		&i.MaxRuntimeSeconds,
+		&i.Nodes,
+		&i.NodeGroups,
+		&i.BlackoutCalendars,
+		&i.RevertedFrom,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listTaskRevisions = `-- name: ListTaskRevisions :many
+SELECT r.id, r.task_id, r.revision, r.name, r.project, r.spider, r.settings_arguments, r.cron_string, r.timezone, r.retry_max_attempts, r.retry_backoff_seconds, r.retry_max_backoff_seconds, r.retry_on, r.overlap_policy, r.fan_out, r.misfire_policy, r.misfire_max_runs, r.max_runtime_seconds, r.nodes, r.node_groups, r.blackout_calendars, r.reverted_from, r.created_by, r.created_at, u.username AS created_by_username
+FROM task_revisions r
+         LEFT JOIN users u ON r.created_by = u.id
+WHERE r.task_id = ?
+ORDER BY r.revision DESC
+`
+
+type ListTaskRevisionsRow struct {
+	TaskRevision      TaskRevision
+	CreatedByUsername sql.NullString
+}
+
+// Newest first
+func (q *Queries) ListTaskRevisions(ctx context.Context, taskID uuid.UUID) ([]ListTaskRevisionsRow, error) {
+	rows, err := q.query(ctx, q.listTaskRevisionsStmt, listTaskRevisions, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTaskRevisionsRow
+	for rows.Next() {
+		var i ListTaskRevisionsRow
+		if err := rows.Scan(
+			&i.TaskRevision.ID,
+			&i.TaskRevision.TaskID,
+			&i.TaskRevision.Revision,
+			&i.TaskRevision.Name,
+			&i.TaskRevision.Project,
+			&i.TaskRevision.Spider,
+			&i.TaskRevision.SettingsArguments,
+			&i.TaskRevision.CronString,
+			&i.TaskRevision.Timezone,
+			&i.TaskRevision.RetryMaxAttempts,
+			&i.TaskRevision.RetryBackoffSeconds,
+			&i.TaskRevision.RetryMaxBackoffSeconds,
+			&i.TaskRevision.RetryOn,
+			&i.TaskRevision.OverlapPolicy,
+			&i.TaskRevision.FanOut,
+			&i.TaskRevision.MisfirePolicy,
+			&i.TaskRevision.MisfireMaxRuns,
+			&i.TaskRevision.MaxRuntimeSeconds,
+			&i.TaskRevision.Nodes,
+			&i.TaskRevision.NodeGroups,
+			&i.TaskRevision.BlackoutCalendars,
+			&i.TaskRevision.RevertedFrom,
+			&i.TaskRevision.CreatedBy,
+			&i.TaskRevision.CreatedAt,
+			&i.CreatedByUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
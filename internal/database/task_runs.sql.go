@@ -0,0 +1,145 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: task_runs.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const countTaskRuns = `-- name: CountTaskRuns :one
+SELECT COUNT(*) FROM task_runs WHERE task_id = ?
+`
+
+func (q *Queries) CountTaskRuns(ctx context.Context, taskID uuid.UUID) (int64, error) {
+	row := q.queryRow(ctx, q.countTaskRunsStmt, countTaskRuns, taskID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const finishTaskRun = `-- name: FinishTaskRun :exec
+UPDATE task_runs SET outcome = ?, error = ?, schedule_latency_ms = ?, finished_at = ? WHERE id = ?
+`
+
+type FinishTaskRunParams struct {
+	Outcome           string
+	Error             sql.NullString
+	ScheduleLatencyMs sql.NullInt64
+	FinishedAt        sql.NullTime
+	ID                int64
+}
+
+func (q *Queries) FinishTaskRun(ctx context.Context, arg FinishTaskRunParams) error {
+	_, err := q.exec(ctx, q.finishTaskRunStmt, finishTaskRun,
+		arg.Outcome,
+		arg.Error,
+		arg.ScheduleLatencyMs,
+		arg.FinishedAt,
+		arg.ID,
+	)
+	return err
+}
+
+const insertTaskRun = `-- name: InsertTaskRun :one
+INSERT INTO task_runs (task_id, trigger_source, scheduled_at, started_at, finished_at, node, job_id, outcome, error)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id
+`
+
+type InsertTaskRunParams struct {
+	TaskID        uuid.UUID
+	TriggerSource string
+	ScheduledAt   sql.NullTime
+	StartedAt     time.Time
+	FinishedAt    sql.NullTime
+	Node          string
+	JobID         string
+	Outcome       string
+	Error         sql.NullString
+}
+
+func (q *Queries) InsertTaskRun(ctx context.Context, arg InsertTaskRunParams) (int64, error) {
+	row := q.queryRow(ctx, q.insertTaskRunStmt, insertTaskRun,
+		arg.TaskID,
+		arg.TriggerSource,
+		arg.ScheduledAt,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.Node,
+		arg.JobID,
+		arg.Outcome,
+		arg.Error,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const listTaskRuns = `-- name: ListTaskRuns :many
+SELECT id, task_id, trigger_source, scheduled_at, started_at, finished_at, node, job_id, outcome, error, schedule_latency_ms FROM task_runs WHERE task_id = ? ORDER BY id DESC LIMIT ? OFFSET ?
+`
+
+type ListTaskRunsParams struct {
+	TaskID uuid.UUID
+	Limit  int64
+	Offset int64
+}
+
+func (q *Queries) ListTaskRuns(ctx context.Context, arg ListTaskRunsParams) ([]TaskRun, error) {
+	rows, err := q.query(ctx, q.listTaskRunsStmt, listTaskRuns, arg.TaskID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TaskRun
+	for rows.Next() {
+		var i TaskRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.TaskID,
+			&i.TriggerSource,
+			&i.ScheduledAt,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.Node,
+			&i.JobID,
+			&i.Outcome,
+			&i.Error,
+			&i.ScheduleLatencyMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pruneTaskRuns = `-- name: PruneTaskRuns :exec
+DELETE FROM task_runs
+WHERE task_runs.task_id = ?1
+  AND task_runs.id <= (SELECT r.id FROM task_runs r WHERE r.task_id = ?1 ORDER BY r.id DESC LIMIT 1 OFFSET ?2)
+`
+
+type PruneTaskRunsParams struct {
+	TaskID uuid.UUID
+	Keep   int64
+}
+
+// Keeps the most recent runs of the task
+func (q *Queries) PruneTaskRuns(ctx context.Context, arg PruneTaskRunsParams) error {
+	_, err := q.exec(ctx, q.pruneTaskRunsStmt, pruneTaskRuns, arg.TaskID, arg.Keep)
+	return err
+}
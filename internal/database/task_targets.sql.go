@@ -0,0 +1,154 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: task_targets.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const advanceTaskRoundRobin = `-- name: AdvanceTaskRoundRobin :one
+UPDATE tasks SET round_robin_next = round_robin_next + 1 WHERE id = ? RETURNING round_robin_next
+`
+
+func (q *Queries) AdvanceTaskRoundRobin(ctx context.Context, id uuid.UUID) (int64, error) {
+	row := q.queryRow(ctx, q.advanceTaskRoundRobinStmt, advanceTaskRoundRobin, id)
+	var round_robin_next int64
+	err := row.Scan(&round_robin_next)
+	return round_robin_next, err
+}
+
+const deleteTaskTargets = `-- name: DeleteTaskTargets :exec
+DELETE FROM task_targets WHERE task_id = ?
+`
+
+func (q *Queries) DeleteTaskTargets(ctx context.Context, taskID uuid.UUID) error {
+	_, err := q.exec(ctx, q.deleteTaskTargetsStmt, deleteTaskTargets, taskID)
+	return err
+}
+
+const insertTaskTarget = `-- name: InsertTaskTarget :exec
+INSERT OR IGNORE INTO task_targets (task_id, node, group_id) VALUES (?1, ?2, ?3)
+`
+
+type InsertTaskTargetParams struct {
+	TaskID  uuid.UUID
+	Node    sql.NullString
+	GroupID sql.NullInt64
+}
+
+func (q *Queries) InsertTaskTarget(ctx context.Context, arg InsertTaskTargetParams) error {
+	_, err := q.exec(ctx, q.insertTaskTargetStmt, insertTaskTarget, arg.TaskID, arg.Node, arg.GroupID)
+	return err
+}
+
+const listResolvedTaskNodes = `-- name: ListResolvedTaskNodes :many
+SELECT DISTINCT tt.task_id, COALESCE(tt.node, m.node) AS node
+FROM task_targets tt
+         LEFT JOIN node_group_members m ON m.group_id = tt.group_id
+WHERE COALESCE(tt.node, m.node) IS NOT NULL
+ORDER BY tt.task_id, node
+`
+
+type ListResolvedTaskNodesRow struct {
+	TaskID uuid.UUID
+	Node   string
+}
+
+func (q *Queries) ListResolvedTaskNodes(ctx context.Context) ([]ListResolvedTaskNodesRow, error) {
+	rows, err := q.query(ctx, q.listResolvedTaskNodesStmt, listResolvedTaskNodes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListResolvedTaskNodesRow
+	for rows.Next() {
+		var i ListResolvedTaskNodesRow
+		if err := rows.Scan(&i.TaskID, &i.Node); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTargetsForTask = `-- name: ListTargetsForTask :many
+SELECT tt.node, tt.group_id, g.name AS group_name
+FROM task_targets tt
+         LEFT JOIN node_groups g ON tt.group_id = g.id
+WHERE tt.task_id = ?
+ORDER BY tt.id
+`
+
+type ListTargetsForTaskRow struct {
+	Node      sql.NullString
+	GroupID   sql.NullInt64
+	GroupName sql.NullString
+}
+
+func (q *Queries) ListTargetsForTask(ctx context.Context, taskID uuid.UUID) ([]ListTargetsForTaskRow, error) {
+	rows, err := q.query(ctx, q.listTargetsForTaskStmt, listTargetsForTask, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTargetsForTaskRow
+	for rows.Next() {
+		var i ListTargetsForTaskRow
+		if err := rows.Scan(&i.Node, &i.GroupID, &i.GroupName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTaskTargetNodes = `-- name: ListTaskTargetNodes :many
+SELECT DISTINCT n.nodeName
+FROM task_targets tt
+         LEFT JOIN node_group_members m ON m.group_id = tt.group_id
+         JOIN scrapyd_nodes n ON n.nodeName = COALESCE(tt.node, m.node)
+WHERE tt.task_id = ?
+ORDER BY n.nodeName
+`
+
+// The nodes a task runs on, its node targets plus the members of its groups
+func (q *Queries) ListTaskTargetNodes(ctx context.Context, taskID uuid.UUID) ([]string, error) {
+	rows, err := q.query(ctx, q.listTaskTargetNodesStmt, listTaskTargetNodes, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var nodename string
+		if err := rows.Scan(&nodename); err != nil {
+			return nil, err
+		}
+		items = append(items, nodename)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
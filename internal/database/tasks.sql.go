@@ -23,7 +23,7 @@ func (q *Queries) DeleteTaskWhereUUID(ctx context.Context, id uuid.UUID) error {
 }
 
 const getTaskWithUUID = `-- name: GetTaskWithUUID :one
-SELECT id, name, create_time, update_time, project, spider, jobid, settings_arguments, selected_nodes, cron_string, paused, created_by, modified_by FROM tasks WHERE id = ?
+SELECT id, name, create_time, update_time, project, spider, jobid, settings_arguments, cron_string, paused, created_by, modified_by, retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy, fan_out, round_robin_next, misfire_policy, misfire_max_runs, last_fired_at, timezone, max_runtime_seconds FROM tasks WHERE id = ?
 `
 
 func (q *Queries) GetTaskWithUUID(ctx context.Context, id uuid.UUID) (Task, error) {
@@ -38,17 +38,28 @@ func (q *Queries) GetTaskWithUUID(ctx context.Context, id uuid.UUID) (Task, erro
 		&i.Spider,
 		&i.Jobid,
 		&i.SettingsArguments,
-		&i.SelectedNodes,
 		&i.CronString,
 		&i.Paused,
 		&i.CreatedBy,
 		&i.ModifiedBy,
+		&i.RetryMaxAttempts,
+		&i.RetryBackoffSeconds,
+		&i.RetryMaxBackoffSeconds,
+		&i.RetryOn,
+		&i.OverlapPolicy,
+		&i.FanOut,
+		&i.RoundRobinNext,
+		&i.MisfirePolicy,
+		&i.MisfireMaxRuns,
+		&i.LastFiredAt,
+		&i.Timezone,
+		&i.MaxRuntimeSeconds,
 	)
 	return i, err
 }
 
 const getTasks = `-- name: GetTasks :many
-SELECT id, name, create_time, update_time, project, spider, jobid, settings_arguments, selected_nodes, cron_string, paused, created_by, modified_by FROM tasks
+SELECT id, name, create_time, update_time, project, spider, jobid, settings_arguments, cron_string, paused, created_by, modified_by, retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy, fan_out, round_robin_next, misfire_policy, misfire_max_runs, last_fired_at, timezone, max_runtime_seconds FROM tasks
 `
 
 func (q *Queries) GetTasks(ctx context.Context) ([]Task, error) {
@@ -69,11 +80,22 @@ func (q *Queries) GetTasks(ctx context.Context) ([]Task, error) {
 			&i.Spider,
 			&i.Jobid,
 			&i.SettingsArguments,
-			&i.SelectedNodes,
 			&i.CronString,
 			&i.Paused,
 			&i.CreatedBy,
 			&i.ModifiedBy,
+			&i.RetryMaxAttempts,
+			&i.RetryBackoffSeconds,
+			&i.RetryMaxBackoffSeconds,
+			&i.RetryOn,
+			&i.OverlapPolicy,
+			&i.FanOut,
+			&i.RoundRobinNext,
+			&i.MisfirePolicy,
+			&i.MisfireMaxRuns,
+			&i.LastFiredAt,
+			&i.Timezone,
+			&i.MaxRuntimeSeconds,
 		); err != nil {
 			return nil, err
 		}
@@ -98,9 +120,16 @@ SELECT
     t.spider,
     t.jobid,
     t.settings_arguments,
-    t.selected_nodes,
+    CAST(COALESCE((SELECT GROUP_CONCAT(COALESCE(tt.node, g.name), ', ') FROM task_targets tt
+        LEFT JOIN node_groups g ON tt.group_id = g.id WHERE tt.task_id = t.id), '') AS TEXT) AS targets,
+    t.fan_out,
     t.cron_string,
+    t.timezone,
     t.paused,
+    t.retry_max_attempts,
+    t.overlap_policy,
+    t.misfire_policy,
+    t.misfire_max_runs,
     creator.username AS created_by_username,
     modifier.username AS modified_by_username,
     j.id AS job_id,
@@ -124,7 +153,6 @@ FROM tasks t
 ) j_max ON j_max.task_id = t.id
          LEFT JOIN jobs j ON j.task_id = j_max.task_id
     AND j.update_time = j_max.latest_update
-    AND j.node = t.selected_nodes
 GROUP BY t.id
 ORDER BY t.name DESC
 `
@@ -138,9 +166,15 @@ type GetTasksWithLatestJobMetadataRow struct {
 	Spider             string
 	Jobid              string
 	SettingsArguments  string
-	SelectedNodes      string
+	Targets            string
+	FanOut             string
 	CronString         string
+	Timezone           string
 	Paused             bool
+	RetryMaxAttempts   int64
+	OverlapPolicy      string
+	MisfirePolicy      string
+	MisfireMaxRuns     int64
 	CreatedByUsername  sql.NullString
 	ModifiedByUsername sql.NullString
 	JobID              sql.NullInt64
@@ -173,9 +207,15 @@ func (q *Queries) GetTasksWithLatestJobMetadata(ctx context.Context) ([]GetTasks
 			&i.Spider,
 			&i.Jobid,
 			&i.SettingsArguments,
-			&i.SelectedNodes,
+			&i.Targets,
+			&i.FanOut,
 			&i.CronString,
+			&i.Timezone,
 			&i.Paused,
+			&i.RetryMaxAttempts,
+			&i.OverlapPolicy,
+			&i.MisfirePolicy,
+			&i.MisfireMaxRuns,
 			&i.CreatedByUsername,
 			&i.ModifiedByUsername,
 			&i.JobID,
@@ -204,23 +244,34 @@ func (q *Queries) GetTasksWithLatestJobMetadata(ctx context.Context) ([]GetTasks
 
 const insertTask = `-- name: InsertTask :one
 INSERT INTO tasks (
-   id, name, project, spider, jobid, settings_arguments, selected_nodes, cron_string, paused, created_by
+   id, name, project, spider, jobid, settings_arguments, cron_string, paused, created_by,
+   retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy, fan_out,
+   misfire_policy, misfire_max_runs, timezone, max_runtime_seconds
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
-) RETURNING id, name, create_time, update_time, project, spider, jobid, settings_arguments, selected_nodes, cron_string, paused, created_by, modified_by
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+) RETURNING id, name, create_time, update_time, project, spider, jobid, settings_arguments, cron_string, paused, created_by, modified_by, retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy, fan_out, round_robin_next, misfire_policy, misfire_max_runs, last_fired_at, timezone, max_runtime_seconds
 `
 
 type InsertTaskParams struct {
-	ID                uuid.UUID
-	Name              sql.NullString
-	Project           string
-	Spider            string
-	Jobid             string
-	SettingsArguments string
-	SelectedNodes     string
-	CronString        string
-	Paused            bool
-	CreatedBy         interface{}
+	ID                     uuid.UUID
+	Name                   sql.NullString
+	Project                string
+	Spider                 string
+	Jobid                  string
+	SettingsArguments      string
+	CronString             string
+	Paused                 bool
+	CreatedBy              interface{}
+	RetryMaxAttempts       int64
+	RetryBackoffSeconds    int64
+	RetryMaxBackoffSeconds int64
+	RetryOn                string
+	OverlapPolicy          string
+	FanOut                 string
+	MisfirePolicy          string
+	MisfireMaxRuns         int64
+	Timezone               string
+	MaxRuntimeSeconds      sql.NullInt64
 }
 
 func (q *Queries) InsertTask(ctx context.Context, arg InsertTaskParams) (Task, error) {
@@ -231,10 +282,19 @@ func (q *Queries) InsertTask(ctx context.Context, arg InsertTaskParams) (Task, e
 		arg.Spider,
 		arg.Jobid,
 		arg.SettingsArguments,
-		arg.SelectedNodes,
 		arg.CronString,
 		arg.Paused,
 		arg.CreatedBy,
+		arg.RetryMaxAttempts,
+		arg.RetryBackoffSeconds,
+		arg.RetryMaxBackoffSeconds,
+		arg.RetryOn,
+		arg.OverlapPolicy,
+		arg.FanOut,
+		arg.MisfirePolicy,
+		arg.MisfireMaxRuns,
+		arg.Timezone,
+		arg.MaxRuntimeSeconds,
 	)
 	var i Task
 	err := row.Scan(
@@ -246,11 +306,22 @@ func (q *Queries) InsertTask(ctx context.Context, arg InsertTaskParams) (Task, e
 		&i.Spider,
 		&i.Jobid,
 		&i.SettingsArguments,
-		&i.SelectedNodes,
 		&i.CronString,
 		&i.Paused,
 		&i.CreatedBy,
 		&i.ModifiedBy,
+		&i.RetryMaxAttempts,
+		&i.RetryBackoffSeconds,
+		&i.RetryMaxBackoffSeconds,
+		&i.RetryOn,
+		&i.OverlapPolicy,
+		&i.FanOut,
+		&i.RoundRobinNext,
+		&i.MisfirePolicy,
+		&i.MisfireMaxRuns,
+		&i.LastFiredAt,
+		&i.Timezone,
+		&i.MaxRuntimeSeconds,
 	)
 	return i, err
 }
@@ -265,9 +336,16 @@ SELECT
     t.spider,
     t.jobid,
     t.settings_arguments,
-    t.selected_nodes,
+    CAST(COALESCE((SELECT GROUP_CONCAT(COALESCE(tt.node, g.name), ', ') FROM task_targets tt
+        LEFT JOIN node_groups g ON tt.group_id = g.id WHERE tt.task_id = t.id), '') AS TEXT) AS targets,
+    t.fan_out,
     t.cron_string,
+    t.timezone,
     t.paused,
+    t.retry_max_attempts,
+    t.overlap_policy,
+    t.misfire_policy,
+    t.misfire_max_runs,
     creator.username AS created_by_username,
     modifier.username AS modified_by_username,
     j.id AS job_id,
@@ -291,7 +369,6 @@ FROM tasks t
 ) j_max ON j_max.task_id = t.id
          LEFT JOIN jobs j ON j.task_id = j_max.task_id
     AND j.update_time = j_max.latest_update
-    AND j.node = t.selected_nodes
 WHERE
     LOWER(t.name) LIKE '%' || LOWER(?1) || '%' OR
     LOWER(t.spider) LIKE '%' || LOWER(?1) || '%'
@@ -308,9 +385,15 @@ type SearchTasksTableRow struct {
 	Spider             string
 	Jobid              string
 	SettingsArguments  string
-	SelectedNodes      string
+	Targets            string
+	FanOut             string
 	CronString         string
+	Timezone           string
 	Paused             bool
+	RetryMaxAttempts   int64
+	OverlapPolicy      string
+	MisfirePolicy      string
+	MisfireMaxRuns     int64
 	CreatedByUsername  sql.NullString
 	ModifiedByUsername sql.NullString
 	JobID              sql.NullInt64
@@ -343,9 +426,15 @@ func (q *Queries) SearchTasksTable(ctx context.Context, searchterm string) ([]Se
 			&i.Spider,
 			&i.Jobid,
 			&i.SettingsArguments,
-			&i.SelectedNodes,
+			&i.Targets,
+			&i.FanOut,
 			&i.CronString,
+			&i.Timezone,
 			&i.Paused,
+			&i.RetryMaxAttempts,
+			&i.OverlapPolicy,
+			&i.MisfirePolicy,
+			&i.MisfireMaxRuns,
 			&i.CreatedByUsername,
 			&i.ModifiedByUsername,
 			&i.JobID,
@@ -380,24 +469,42 @@ SET
     spider = ?,
     jobid = ?,
     settings_arguments = ?,
-    selected_nodes = ?,
     cron_string = ?,
     paused = ?,
-    modified_by = ?
+    modified_by = ?,
+    retry_max_attempts = ?,
+    retry_backoff_seconds = ?,
+    retry_max_backoff_seconds = ?,
+    retry_on = ?,
+    overlap_policy = ?,
+    fan_out = ?,
+    misfire_policy = ?,
+    misfire_max_runs = ?,
+    timezone = ?,
+    max_runtime_seconds = ?
 WHERE id = ?
 `
 
 type UpdateTaskParams struct {
-	Name              sql.NullString
-	Project           string
-	Spider            string
-	Jobid             string
-	SettingsArguments string
-	SelectedNodes     string
-	CronString        string
-	Paused            bool
-	ModifiedBy        interface{}
-	ID                uuid.UUID
+	Name                   sql.NullString
+	Project                string
+	Spider                 string
+	Jobid                  string
+	SettingsArguments      string
+	CronString             string
+	Paused                 bool
+	ModifiedBy             interface{}
+	RetryMaxAttempts       int64
+	RetryBackoffSeconds    int64
+	RetryMaxBackoffSeconds int64
+	RetryOn                string
+	OverlapPolicy          string
+	FanOut                 string
+	MisfirePolicy          string
+	MisfireMaxRuns         int64
+	Timezone               string
+	MaxRuntimeSeconds      sql.NullInt64
+	ID                     uuid.UUID
 }
 
 func (q *Queries) UpdateTask(ctx context.Context, arg UpdateTaskParams) error {
@@ -407,15 +514,38 @@ func (q *Queries) UpdateTask(ctx context.Context, arg UpdateTaskParams) error {
 		arg.Spider,
 		arg.Jobid,
 		arg.SettingsArguments,
-		arg.SelectedNodes,
 		arg.CronString,
 		arg.Paused,
 		arg.ModifiedBy,
+		arg.RetryMaxAttempts,
+		arg.RetryBackoffSeconds,
+		arg.RetryMaxBackoffSeconds,
+		arg.RetryOn,
+		arg.OverlapPolicy,
+		arg.FanOut,
+		arg.MisfirePolicy,
+		arg.MisfireMaxRuns,
+		arg.Timezone,
+		arg.MaxRuntimeSeconds,
 		arg.ID,
 	)
 	return err
 }
 
+const updateTaskLastFiredAt = `-- name: UpdateTaskLastFiredAt :exec
+UPDATE tasks SET last_fired_at = ? WHERE id = ?
+`
+
+type UpdateTaskLastFiredAtParams struct {
+	LastFiredAt sql.NullTime
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateTaskLastFiredAt(ctx context.Context, arg UpdateTaskLastFiredAtParams) error {
+	_, err := q.exec(ctx, q.updateTaskLastFiredAtStmt, updateTaskLastFiredAt, arg.LastFiredAt, arg.ID)
+	return err
+}
+
 const updateTaskPaused = `-- name: UpdateTaskPaused :exec
 UPDATE tasks SET paused=? WHERE id = ?
 `
@@ -0,0 +1,142 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: two_factor.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const countUnusedRecoveryCodes = `-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL
+`
+
+func (q *Queries) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.queryRow(ctx, q.countUnusedRecoveryCodesStmt, countUnusedRecoveryCodes, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteRecoveryCodesForUser = `-- name: DeleteRecoveryCodesForUser :exec
+DELETE FROM recovery_codes WHERE user_id = ?
+`
+
+func (q *Queries) DeleteRecoveryCodesForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.exec(ctx, q.deleteRecoveryCodesForUserStmt, deleteRecoveryCodesForUser, userID)
+	return err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :exec
+UPDATE users SET totp_enabled = TRUE WHERE ID = ?
+`
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.exec(ctx, q.enableUserTOTPStmt, enableUserTOTP, id)
+	return err
+}
+
+const insertRecoveryCode = `-- name: InsertRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)
+`
+
+type InsertRecoveryCodeParams struct {
+	UserID   uuid.UUID
+	CodeHash []byte
+}
+
+func (q *Queries) InsertRecoveryCode(ctx context.Context, arg InsertRecoveryCodeParams) error {
+	_, err := q.exec(ctx, q.insertRecoveryCodeStmt, insertRecoveryCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const recordTwoFactorFailure = `-- name: RecordTwoFactorFailure :exec
+UPDATE users
+SET totp_failed_attempts = CASE WHEN totp_failed_attempts + 1 >= ?1 THEN 0 ELSE totp_failed_attempts + 1 END,
+    totp_locked_until    = CASE WHEN totp_failed_attempts + 1 >= ?1 THEN ?2 ELSE totp_locked_until END
+WHERE ID = ?3
+`
+
+type RecordTwoFactorFailureParams struct {
+	MaxFailures int64
+	LockedUntil sql.NullTime
+	ID          uuid.UUID
+}
+
+// The max_failures-th incorrect code in a row locks the second factor until locked_until and starts the count over
+func (q *Queries) RecordTwoFactorFailure(ctx context.Context, arg RecordTwoFactorFailureParams) error {
+	_, err := q.exec(ctx, q.recordTwoFactorFailureStmt, recordTwoFactorFailure, arg.MaxFailures, arg.LockedUntil, arg.ID)
+	return err
+}
+
+const resetTwoFactorFailures = `-- name: ResetTwoFactorFailures :exec
+UPDATE users SET totp_failed_attempts = 0, totp_locked_until = NULL WHERE ID = ?
+`
+
+func (q *Queries) ResetTwoFactorFailures(ctx context.Context, id uuid.UUID) error {
+	_, err := q.exec(ctx, q.resetTwoFactorFailuresStmt, resetTwoFactorFailures, id)
+	return err
+}
+
+const resetUserTOTP = `-- name: ResetUserTOTP :exec
+UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_failed_attempts = 0, totp_locked_until = NULL WHERE ID = ?
+`
+
+func (q *Queries) ResetUserTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.exec(ctx, q.resetUserTOTPStmt, resetUserTOTP, id)
+	return err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users SET totp_secret = ?, totp_enabled = FALSE WHERE ID = ?
+`
+
+type SetUserTOTPSecretParams struct {
+	TotpSecret []byte
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.exec(ctx, q.setUserTOTPSecretStmt, setUserTOTPSecret, arg.TotpSecret, arg.ID)
+	return err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+`
+
+type UseRecoveryCodeParams struct {
+	UserID   uuid.UUID
+	CodeHash []byte
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error) {
+	result, err := q.exec(ctx, q.useRecoveryCodeStmt, useRecoveryCode, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users SET totp_last_step = ?1 WHERE ID = ?2 AND totp_last_step < ?1
+`
+
+type UseTOTPStepParams struct {
+	Step int64
+	ID   uuid.UUID
+}
+
+// Affects no rows when a code of the step or a later one was accepted already
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.exec(ctx, q.useTOTPStepStmt, useTOTPStep, arg.Step, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
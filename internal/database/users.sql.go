@@ -12,14 +12,15 @@ import (
 )
 
 const createNewUser = `-- name: CreateNewUser :one
-INSERT INTO users (ID, username, hashed_password, has_admin_privileges) VALUES (?, ?, ?, ?) RETURNING id, created_at, username, hashed_password, has_admin_privileges
+INSERT INTO users (ID, username, hashed_password, role, totp_required) VALUES (?, ?, ?, ?, ?) RETURNING id, created_at, username, hashed_password, role, totp_secret, totp_enabled, totp_required, totp_last_step, totp_failed_attempts, totp_locked_until, access_restricted
 `
 
 type CreateNewUserParams struct {
-	ID                 uuid.UUID
-	Username           string
-	HashedPassword     string
-	HasAdminPrivileges bool
+	ID             uuid.UUID
+	Username       string
+	HashedPassword string
+	Role           string
+	TotpRequired   bool
 }
 
 func (q *Queries) CreateNewUser(ctx context.Context, arg CreateNewUserParams) (User, error) {
@@ -27,7 +28,8 @@ func (q *Queries) CreateNewUser(ctx context.Context, arg CreateNewUserParams) (U
 		arg.ID,
 		arg.Username,
 		arg.HashedPassword,
-		arg.HasAdminPrivileges,
+		arg.Role,
+		arg.TotpRequired,
 	)
 	var i User
 	err := row.Scan(
@@ -35,7 +37,14 @@ func (q *Queries) CreateNewUser(ctx context.Context, arg CreateNewUserParams) (U
 		&i.CreatedAt,
 		&i.Username,
 		&i.HashedPassword,
-		&i.HasAdminPrivileges,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpRequired,
+		&i.TotpLastStep,
+		&i.TotpFailedAttempts,
+		&i.TotpLockedUntil,
+		&i.AccessRestricted,
 	)
 	return i, err
 }
@@ -50,7 +59,7 @@ func (q *Queries) DeleteUserByUUID(ctx context.Context, id uuid.UUID) error {
 }
 
 const getAllUsers = `-- name: GetAllUsers :many
-SELECT id, created_at, username, hashed_password, has_admin_privileges FROM users
+SELECT id, created_at, username, hashed_password, role, totp_secret, totp_enabled, totp_required, totp_last_step, totp_failed_attempts, totp_locked_until, access_restricted FROM users
 `
 
 func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
@@ -67,7 +76,14 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.Username,
 			&i.HashedPassword,
-			&i.HasAdminPrivileges,
+			&i.Role,
+			&i.TotpSecret,
+			&i.TotpEnabled,
+			&i.TotpRequired,
+			&i.TotpLastStep,
+			&i.TotpFailedAttempts,
+			&i.TotpLockedUntil,
+			&i.AccessRestricted,
 		); err != nil {
 			return nil, err
 		}
@@ -83,7 +99,7 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, created_at, username, hashed_password, has_admin_privileges FROM users WHERE username = ? LIMIT 1
+SELECT id, created_at, username, hashed_password, role, totp_secret, totp_enabled, totp_required, totp_last_step, totp_failed_attempts, totp_locked_until, access_restricted FROM users WHERE username = ? LIMIT 1
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -94,13 +110,20 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.CreatedAt,
 		&i.Username,
 		&i.HashedPassword,
-		&i.HasAdminPrivileges,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpRequired,
+		&i.TotpLastStep,
+		&i.TotpFailedAttempts,
+		&i.TotpLockedUntil,
+		&i.AccessRestricted,
 	)
 	return i, err
 }
 
 const getUserWithID = `-- name: GetUserWithID :one
-SELECT id, created_at, username, hashed_password, has_admin_privileges FROM users WHERE ID = ? LIMIT 1
+SELECT id, created_at, username, hashed_password, role, totp_secret, totp_enabled, totp_required, totp_last_step, totp_failed_attempts, totp_locked_until, access_restricted FROM users WHERE ID = ? LIMIT 1
 `
 
 // @sqlc.returns *users
@@ -112,27 +135,36 @@ func (q *Queries) GetUserWithID(ctx context.Context, id uuid.UUID) (User, error)
 		&i.CreatedAt,
 		&i.Username,
 		&i.HashedPassword,
-		&i.HasAdminPrivileges,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpRequired,
+		&i.TotpLastStep,
+		&i.TotpFailedAttempts,
+		&i.TotpLockedUntil,
+		&i.AccessRestricted,
 	)
 	return i, err
 }
 
 const updateUserWhereUUID = `-- name: UpdateUserWhereUUID :exec
-UPDATE users SET username=?, hashed_password=?, has_admin_privileges = ? WHERE ID =?
+UPDATE users SET username=?, hashed_password=?, role = ?, totp_required = ? WHERE ID =?
 `
 
 type UpdateUserWhereUUIDParams struct {
-	Username           string
-	HashedPassword     string
-	HasAdminPrivileges bool
-	ID                 uuid.UUID
+	Username       string
+	HashedPassword string
+	Role           string
+	TotpRequired   bool
+	ID             uuid.UUID
 }
 
 func (q *Queries) UpdateUserWhereUUID(ctx context.Context, arg UpdateUserWhereUUIDParams) error {
 	_, err := q.exec(ctx, q.updateUserWhereUUIDStmt, updateUserWhereUUID,
 		arg.Username,
 		arg.HashedPassword,
-		arg.HasAdminPrivileges,
+		arg.Role,
+		arg.TotpRequired,
 		arg.ID,
 	)
 	return err
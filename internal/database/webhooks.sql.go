@@ -0,0 +1,121 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteWebhookForTask = `-- name: DeleteWebhookForTask :execrows
+DELETE FROM task_webhooks WHERE task_id = ?
+`
+
+func (q *Queries) DeleteWebhookForTask(ctx context.Context, taskID uuid.UUID) (int64, error) {
+	result, err := q.exec(ctx, q.deleteWebhookForTaskStmt, deleteWebhookForTask, taskID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteWebhookNoncesSeenBefore = `-- name: DeleteWebhookNoncesSeenBefore :exec
+DELETE FROM webhook_nonces WHERE seen_at < ?
+`
+
+func (q *Queries) DeleteWebhookNoncesSeenBefore(ctx context.Context, seenAt time.Time) error {
+	_, err := q.exec(ctx, q.deleteWebhookNoncesSeenBeforeStmt, deleteWebhookNoncesSeenBefore, seenAt)
+	return err
+}
+
+const getWebhookForTask = `-- name: GetWebhookForTask :one
+SELECT id, task_id, secret, created_at, created_by, last_fired_at FROM task_webhooks WHERE task_id = ? LIMIT 1
+`
+
+func (q *Queries) GetWebhookForTask(ctx context.Context, taskID uuid.UUID) (TaskWebhook, error) {
+	row := q.queryRow(ctx, q.getWebhookForTaskStmt, getWebhookForTask, taskID)
+	var i TaskWebhook
+	err := row.Scan(
+		&i.ID,
+		&i.TaskID,
+		&i.Secret,
+		&i.CreatedAt,
+		&i.CreatedBy,
+		&i.LastFiredAt,
+	)
+	return i, err
+}
+
+const insertWebhookNonce = `-- name: InsertWebhookNonce :execrows
+INSERT INTO webhook_nonces (webhook_id, nonce, seen_at) VALUES (?, ?, ?) ON CONFLICT(webhook_id, nonce) DO NOTHING
+`
+
+type InsertWebhookNonceParams struct {
+	WebhookID int64
+	Nonce     string
+	SeenAt    time.Time
+}
+
+func (q *Queries) InsertWebhookNonce(ctx context.Context, arg InsertWebhookNonceParams) (int64, error) {
+	result, err := q.exec(ctx, q.insertWebhookNonceStmt, insertWebhookNonce, arg.WebhookID, arg.Nonce, arg.SeenAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateWebhookLastFired = `-- name: UpdateWebhookLastFired :exec
+UPDATE task_webhooks SET last_fired_at = ? WHERE id = ?
+`
+
+type UpdateWebhookLastFiredParams struct {
+	LastFiredAt sql.NullTime
+	ID          int64
+}
+
+func (q *Queries) UpdateWebhookLastFired(ctx context.Context, arg UpdateWebhookLastFiredParams) error {
+	_, err := q.exec(ctx, q.updateWebhookLastFiredStmt, updateWebhookLastFired, arg.LastFiredAt, arg.ID)
+	return err
+}
+
+const upsertTaskWebhook = `-- name: UpsertTaskWebhook :one
+INSERT INTO task_webhooks (task_id, secret, created_at, created_by) VALUES (?, ?, ?, ?)
+ON CONFLICT(task_id) DO UPDATE SET
+    secret = EXCLUDED.secret,
+    created_at = EXCLUDED.created_at,
+    created_by = EXCLUDED.created_by,
+    last_fired_at = NULL
+RETURNING id, task_id, secret, created_at, created_by, last_fired_at
+`
+
+type UpsertTaskWebhookParams struct {
+	TaskID    uuid.UUID
+	Secret    []byte
+	CreatedAt time.Time
+	CreatedBy interface{}
+}
+
+func (q *Queries) UpsertTaskWebhook(ctx context.Context, arg UpsertTaskWebhookParams) (TaskWebhook, error) {
+	row := q.queryRow(ctx, q.upsertTaskWebhookStmt, upsertTaskWebhook,
+		arg.TaskID,
+		arg.Secret,
+		arg.CreatedAt,
+		arg.CreatedBy,
+	)
+	var i TaskWebhook
+	err := row.Scan(
+		&i.ID,
+		&i.TaskID,
+		&i.Secret,
+		&i.CreatedAt,
+		&i.CreatedBy,
+		&i.LastFiredAt,
+	)
+	return i, err
+}
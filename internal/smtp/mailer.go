@@ -15,7 +15,7 @@ import (
 const defaultTimeout = 10 * time.Second
 
 type Mailer struct {
-	client mail.Client
+	client *mail.Client
 	from   string
 }
 
@@ -26,7 +26,7 @@ func NewMailer(host string, port int, username, password, from string) (*Mailer,
 	}
 
 	mailer := &Mailer{
-		client: *client,
+		client: client,
 		from:   from,
 	}
 
@@ -0,0 +1,35 @@
+-- name: ListAccessGrants :many
+SELECT g.id, g.user_id, g.role, g.project, g.node, g.created_at, u.username
+FROM access_grants g
+         LEFT JOIN users u ON g.user_id = u.ID
+ORDER BY g.id;
+
+-- name: ListAccessGrantsForUser :many
+SELECT project, node FROM access_grants WHERE user_id = sqlc.arg('user_id') OR role = sqlc.arg('role') ORDER BY id;
+
+-- name: InsertAccessGrant :one
+INSERT INTO access_grants (user_id, role, project, node) VALUES (?, ?, ?, ?) RETURNING *;
+
+-- name: DeleteAccessGrant :execrows
+DELETE FROM access_grants WHERE id = ?;
+
+-- name: GetProjectAndNodeForJob :one
+SELECT project, node FROM jobs WHERE job = ? AND deleted = 0 ORDER BY id DESC LIMIT 1;
+
+-- name: GetAccessRestriction :one
+SELECT u.access_restricted, COALESCE(r.access_restricted, false) AS role_access_restricted
+FROM users u
+         LEFT JOIN roles r ON r.name = u.role
+WHERE u.ID = ?;
+
+-- name: SetUserAccessRestricted :exec
+UPDATE users SET access_restricted = ? WHERE ID = ?;
+
+-- name: SetRoleAccessRestricted :exec
+UPDATE roles SET access_restricted = ? WHERE name = ?;
+
+-- name: DeleteAccessGrantsForUser :exec
+DELETE FROM access_grants WHERE user_id = ?;
+
+-- name: DeleteAccessGrantsForRole :exec
+DELETE FROM access_grants WHERE role = ?;
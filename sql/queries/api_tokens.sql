@@ -0,0 +1,14 @@
+-- name: InsertAPIToken :one
+INSERT INTO api_tokens (user_id, name, token_hash, scope, expires_at) VALUES (?, ?, ?, ?, ?) RETURNING *;
+
+-- name: GetAPITokenWithHash :one
+SELECT * FROM api_tokens WHERE token_hash = ? LIMIT 1;
+
+-- name: ListAPITokensForUser :many
+SELECT * FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC, id DESC;
+
+-- name: RevokeAPIToken :execrows
+UPDATE api_tokens SET revoked = TRUE WHERE id = ? AND user_id = ?;
+
+-- name: UpdateAPITokenLastUsed :exec
+UPDATE api_tokens SET last_used_at = ?, last_used_ip = ? WHERE id = ?;
@@ -0,0 +1,49 @@
+-- name: ListBlackoutCalendars :many
+SELECT c.id, c.name, c.created_at, u.username AS created_by_username
+FROM blackout_calendars c
+         LEFT JOIN users u ON c.created_by = u.ID
+ORDER BY c.name;
+
+-- name: GetBlackoutCalendar :one
+SELECT * FROM blackout_calendars WHERE id = ?;
+
+-- name: GetBlackoutCalendarByName :one
+SELECT * FROM blackout_calendars WHERE name = ?;
+
+-- name: InsertBlackoutCalendar :one
+INSERT INTO blackout_calendars (name, created_by) VALUES (?, ?) RETURNING *;
+
+-- name: DeleteBlackoutCalendar :execrows
+DELETE FROM blackout_calendars WHERE id = ?;
+
+-- name: ListBlackoutWindows :many
+SELECT * FROM blackout_windows ORDER BY calendar_id, id;
+
+-- name: InsertBlackoutWindow :one
+INSERT INTO blackout_windows (calendar_id, start_date, end_date, weekdays, start_time, end_time, note)
+VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: DeleteBlackoutWindow :execrows
+DELETE FROM blackout_windows WHERE id = ?;
+
+-- name: ListTaskBlackoutCalendars :many
+SELECT calendar_id FROM task_blackout_calendars WHERE task_id = ? ORDER BY calendar_id;
+
+-- name: ListBlackoutCalendarsForTask :many
+SELECT c.name FROM task_blackout_calendars tc JOIN blackout_calendars c ON tc.calendar_id = c.id
+WHERE tc.task_id = ?
+ORDER BY c.name;
+
+-- name: InsertTaskBlackoutCalendar :exec
+INSERT OR IGNORE INTO task_blackout_calendars (task_id, calendar_id) VALUES (?, ?);
+
+-- name: DeleteTaskBlackoutCalendars :exec
+DELETE FROM task_blackout_calendars WHERE task_id = ?;
+
+-- name: ListBlackoutWindowsForTask :many
+SELECT w.*, c.name AS calendar_name
+FROM task_blackout_calendars tc
+         JOIN blackout_calendars c ON tc.calendar_id = c.id
+         JOIN blackout_windows w ON w.calendar_id = c.id
+WHERE tc.task_id = ?
+ORDER BY c.name, w.id;
@@ -1,7 +1,7 @@
 -- name: InsertJob :one
 INSERT INTO jobs (
     project, spider, job, status, deleted, create_time, update_time,
-    pages, items, pid, start, runtime, finish, href_log, href_items, node, task_id, started_by, stopped_by
+    pages, items, pid, start, runtime, finish, href_log, href_items, node, task_id, started_by, stopped_by, triggered_by
 )
 VALUES (
     sqlc.arg('project'),
@@ -22,11 +22,13 @@ VALUES (
     sqlc.arg('node'),
     sqlc.arg('task_id'),
     sqlc.narg('started_by'),
-        sqlc.narg('stopped_by')
+    sqlc.narg('stopped_by'),
+    sqlc.narg('triggered_by')
        )
     ON CONFLICT(project, spider, job)
 DO UPDATE SET
-    status = EXCLUDED.status,
+    -- A job cancelled for running past its max runtime keeps that outcome once Scrapyd reports it finished
+    status = CASE WHEN jobs.timed_out_at IS NOT NULL AND EXCLUDED.status = 'finished' THEN 'timed_out' ELSE EXCLUDED.status END,
     update_time = EXCLUDED.update_time,
     pages = COALESCE(EXCLUDED.pages, jobs.pages),
     items = COALESCE(EXCLUDED.items, jobs.items),
@@ -37,7 +39,8 @@ DO UPDATE SET
     href_log = COALESCE(EXCLUDED.href_log, jobs.href_log),
     href_items = COALESCE(EXCLUDED.href_items, jobs.href_items),
     started_by = COALESCE(EXCLUDED.started_by, jobs.started_by),
-    stopped_by = COALESCE(EXCLUDED.stopped_by, jobs.stopped_by)
+    stopped_by = COALESCE(EXCLUDED.stopped_by, jobs.stopped_by),
+    triggered_by = COALESCE(EXCLUDED.triggered_by, jobs.triggered_by)
 WHERE jobs.deleted = 0
 AND EXCLUDED.update_time >= jobs.update_time
 RETURNING *;
@@ -46,37 +49,89 @@ RETURNING *;
 SELECT jobs.Start, jobs.Runtime, jobs.Finish, jobs.href_log, jobs.href_items, jobs.spider, jobs.Project, jobs.job, jobs.node FROM jobs WHERE job = ? LIMIT 1;
 
 -- name: GetJobsForNode :many
+-- grants is the JSON array of the access grants of a restricted user, see accessScope.queryGrants, NULL matches any job.
 SELECT j.id, j.project, j.spider, j.job, j.status, j.deleted, j.create_time, j.update_time, j.pages, j.items, j.pid,
        j.start, j.runtime, j.finish, j.href_log, j.href_items, j.node, j.error, u1.username AS started_by_username,
-       u2.username AS stopped_by_username
+       u2.username AS stopped_by_username, j.triggered_by, j.attempts, j.next_retry_at, j.timed_out_at, j.spider_args
 FROM jobs j
          LEFT JOIN users u1 ON j.started_by = u1.ID
          LEFT JOIN users u2 ON j.stopped_by = u2.ID
-WHERE j.node = ? AND j.deleted = 0
+WHERE j.node = sqlc.arg('node') AND j.deleted = 0
+  AND (sqlc.narg('grants') IS NULL OR EXISTS (
+      SELECT 1 FROM (SELECT sqlc.narg('grants') AS grants) s, json_each(s.grants) g
+      WHERE json_extract(g.value, '$.project') IN ('', j.project) AND json_extract(g.value, '$.node') IN ('', j.node)))
 ORDER BY CASE
              WHEN j.finish IS NULL THEN j.runtime
              ELSE j.finish
              END DESC
-LIMIT ? OFFSET ?;
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
 
 -- name: GetTotalJobCountForNode :one
-SELECT COUNT(*) FROM jobs WHERE node = ? AND deleted = 0;
+SELECT COUNT(*) FROM jobs j
+WHERE j.node = sqlc.arg('node') AND j.deleted = 0
+  AND (sqlc.narg('grants') IS NULL OR EXISTS (
+      SELECT 1 FROM (SELECT sqlc.narg('grants') AS grants) s, json_each(s.grants) g
+      WHERE json_extract(g.value, '$.project') IN ('', j.project) AND json_extract(g.value, '$.node') IN ('', j.node)));
 
 -- name: SoftDeleteJob :exec
 UPDATE jobs SET deleted = ? WHERE job = ?;
 
 -- name: SetErrorWhereJobId :exec
 UPDATE jobs
-SET error = ?, status = 'error'
+SET error = ?, status = 'error', next_retry_at = NULL
 WHERE jobs.job = sqlc.arg('job_id') AND jobs.project=sqlc.arg('project') AND jobs.node=sqlc.arg('node');
 
+-- name: SetJobStatus :exec
+UPDATE jobs
+SET status = ?, error = ?
+WHERE jobs.job = sqlc.arg('job_id') AND jobs.project=sqlc.arg('project') AND jobs.node=sqlc.arg('node');
+
+-- name: ListActiveJobsForTask :many
+SELECT job, project, node, status FROM jobs
+WHERE task_id = sqlc.arg('task_id') AND job NOT IN (sqlc.slice('exclude_jobs')) AND deleted = 0
+  AND status IN ('scheduled', 'queued', 'pending', 'running')
+ORDER BY id;
+
+-- name: QueueJob :exec
+UPDATE jobs
+SET status = 'queued', queued_until = sqlc.arg('queued_until')
+WHERE jobs.job = sqlc.arg('job_id') AND jobs.project=sqlc.arg('project') AND jobs.node=sqlc.arg('node');
+
+-- name: RenewQueuedJob :execrows
+-- No rows are affected once the job isn't queued anymore, e.g. it was failed as orphaned.
+UPDATE jobs
+SET queued_until = sqlc.arg('queued_until')
+WHERE jobs.job = sqlc.arg('job_id') AND jobs.project=sqlc.arg('project') AND jobs.node=sqlc.arg('node')
+  AND status = 'queued' AND deleted = 0;
+
+-- name: ScheduleQueuedJob :execrows
+UPDATE jobs
+SET status = 'scheduled', queued_until = NULL
+WHERE jobs.job = sqlc.arg('job_id') AND jobs.project=sqlc.arg('project') AND jobs.node=sqlc.arg('node')
+  AND status = 'queued' AND deleted = 0;
+
+-- name: FailQueuedJobs :execrows
+-- Fails the queued jobs nobody renewed queued_until of, see RenewQueuedJob.
+UPDATE jobs
+SET status = 'error', error = sqlc.arg('reason'), queued_until = NULL
+WHERE status = 'queued' AND deleted = 0
+  AND (queued_until IS NULL OR julianday(queued_until) < julianday(sqlc.arg('now')));
+
+-- name: SetJobAttempt :exec
+UPDATE jobs
+SET attempts = ?, error = ?, next_retry_at = ?
+WHERE jobs.job = sqlc.arg('job_id') AND jobs.project=sqlc.arg('project') AND jobs.node=sqlc.arg('node');
+
+-- name: GetNodeForJob :one
+SELECT node FROM jobs WHERE job = ? AND project = ? AND deleted = 0 ORDER BY id DESC LIMIT 1;
+
 -- name: SetStoppedByOnJob :exec
 UPDATE jobs SET stopped_by=? WHERE job=? AND project=? AND node=?;
 
 -- name: SearchNodeJobs :many
 SELECT j.id, j.project, j.spider, j.job, j.status, j.deleted, j.create_time, j.update_time, j.pages, j.items, j.pid,
        j.start, j.runtime, j.finish, j.href_log, j.href_items, j.node, j.error, u1.username AS started_by_username,
-       u2.username AS stopped_by_username
+       u2.username AS stopped_by_username, j.triggered_by, j.attempts, j.next_retry_at, j.timed_out_at, j.spider_args
 FROM jobs j
          LEFT JOIN users u1 ON j.started_by = u1.ID
          LEFT JOIN users u2 ON j.stopped_by = u2.ID
@@ -85,7 +140,71 @@ WHERE
      LOWER(j.job) LIKE '%' || LOWER(@search_term) || '%')
   AND j.node = @node
   AND j.deleted = 0
+  AND (sqlc.narg('grants') IS NULL OR EXISTS (
+      SELECT 1 FROM (SELECT sqlc.narg('grants') AS grants) s, json_each(s.grants) g
+      WHERE json_extract(g.value, '$.project') IN ('', j.project) AND json_extract(g.value, '$.node') IN ('', j.node)))
 ORDER BY CASE
              WHEN j.finish IS NULL THEN j.runtime
              ELSE j.finish
              END DESC;
+
+-- name: GetLatestJobForTask :one
+SELECT j.id, j.project, j.spider, j.job, j.status, j.deleted, j.create_time, j.update_time, j.pages, j.items, j.pid,
+       j.start, j.runtime, j.finish, j.href_log, j.href_items, j.node, j.error, u1.username AS started_by_username,
+       u2.username AS stopped_by_username, j.triggered_by, j.attempts, j.next_retry_at, j.timed_out_at, j.spider_args
+FROM jobs j
+         LEFT JOIN users u1 ON j.started_by = u1.ID
+         LEFT JOIN users u2 ON j.stopped_by = u2.ID
+WHERE j.task_id = ? AND j.deleted = 0
+ORDER BY j.create_time DESC, j.id DESC
+LIMIT 1;
+
+-- name: QueryJobs :many
+SELECT j.id, j.project, j.spider, j.job, j.status, j.deleted, j.create_time, j.update_time, j.pages, j.items, j.pid,
+       j.start, j.runtime, j.finish, j.href_log, j.href_items, j.node, j.error, u1.username AS started_by_username,
+       u2.username AS stopped_by_username, j.triggered_by, j.attempts, j.next_retry_at, j.timed_out_at, j.spider_args
+FROM jobs j
+         LEFT JOIN users u1 ON j.started_by = u1.ID
+         LEFT JOIN users u2 ON j.stopped_by = u2.ID
+WHERE j.deleted = 0
+  AND (sqlc.narg('node') IS NULL OR j.node = sqlc.narg('node'))
+  AND (sqlc.narg('project') IS NULL OR j.project = sqlc.narg('project'))
+  AND (sqlc.narg('spider') IS NULL OR j.spider = sqlc.narg('spider'))
+  AND (sqlc.narg('status') IS NULL OR j.status = sqlc.narg('status'))
+  AND (sqlc.narg('task_id') IS NULL OR j.task_id = sqlc.narg('task_id'))
+  AND (sqlc.narg('started_by') IS NULL OR u1.username = sqlc.narg('started_by'))
+  AND (sqlc.narg('created_after') IS NULL OR julianday(j.create_time) >= julianday(sqlc.narg('created_after')))
+  AND (sqlc.narg('created_before') IS NULL OR julianday(j.create_time) < julianday(sqlc.narg('created_before')))
+  AND (sqlc.narg('before_id') IS NULL OR j.id < sqlc.narg('before_id'))
+  AND (sqlc.narg('grants') IS NULL OR EXISTS (
+      SELECT 1 FROM (SELECT sqlc.narg('grants') AS grants) s, json_each(s.grants) g
+      WHERE json_extract(g.value, '$.project') IN ('', j.project) AND json_extract(g.value, '$.node') IN ('', j.node)))
+ORDER BY j.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListRunningJobsWithMaxRuntime :many
+SELECT j.id, j.project, j.spider, j.job, j.node, j.start, j.timed_out_at, j.killed_at,
+       t.max_runtime_seconds AS task_max_runtime_seconds, p.max_runtime_seconds AS project_max_runtime_seconds
+FROM jobs j
+         LEFT JOIN tasks t ON j.task_id = t.id
+         LEFT JOIN project_max_runtimes p ON j.project = p.project
+WHERE j.status = 'running' AND j.deleted = 0 AND j.start IS NOT NULL
+  AND (t.max_runtime_seconds IS NOT NULL OR p.max_runtime_seconds IS NOT NULL)
+ORDER BY j.id;
+
+-- name: SetJobTimedOut :exec
+UPDATE jobs SET timed_out_at = ? WHERE id = ?;
+
+-- name: SetJobKilled :exec
+UPDATE jobs SET killed_at = ? WHERE id = ?;
+
+-- name: SetJobSpiderArgs :exec
+UPDATE jobs
+SET spider_args = ?
+WHERE jobs.job = sqlc.arg('job_id') AND jobs.project=sqlc.arg('project') AND jobs.node=sqlc.arg('node');
+
+-- name: GetLastFinishedJobForTask :one
+SELECT job FROM jobs
+WHERE task_id = ? AND status = 'finished' AND deleted = 0
+ORDER BY id DESC
+LIMIT 1;
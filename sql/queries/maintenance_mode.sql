@@ -0,0 +1,14 @@
+-- name: GetMaintenanceMode :one
+-- No rows while maintenance mode is off, including once it expired
+SELECT mm.*, u.username AS started_by_username
+FROM maintenance_mode mm
+         LEFT JOIN users u ON mm.started_by = u.id
+WHERE mm.expires_at IS NULL OR julianday(mm.expires_at) > julianday(sqlc.arg('now'))
+LIMIT 1;
+
+-- name: StartMaintenanceMode :exec
+-- Starting maintenance mode while it's on replaces its reason and expiry
+INSERT OR REPLACE INTO maintenance_mode (id, reason, started_at, started_by, expires_at) VALUES (1, ?, ?, ?, ?);
+
+-- name: EndMaintenanceMode :execrows
+DELETE FROM maintenance_mode;
@@ -0,0 +1,29 @@
+-- name: ListNodeGroups :many
+SELECT g.id, g.name, g.created_at, u.username AS created_by_username
+FROM node_groups g
+         LEFT JOIN users u ON g.created_by = u.ID
+ORDER BY g.name;
+
+-- name: ListNodeGroupMembers :many
+SELECT group_id, node FROM node_group_members ORDER BY group_id, node;
+
+-- name: GetNodeGroup :one
+SELECT * FROM node_groups WHERE id = ?;
+
+-- name: GetNodeGroupByName :one
+SELECT * FROM node_groups WHERE name = ?;
+
+-- name: InsertNodeGroup :one
+INSERT INTO node_groups (name, created_by) VALUES (?, ?) RETURNING *;
+
+-- name: DeleteNodeGroup :execrows
+DELETE FROM node_groups WHERE id = ?;
+
+-- name: InsertNodeGroupMember :exec
+INSERT OR IGNORE INTO node_group_members (group_id, node) VALUES (?, ?);
+
+-- name: DeleteNodeGroupMembers :exec
+DELETE FROM node_group_members WHERE group_id = ?;
+
+-- name: ListNodesInGroup :many
+SELECT node FROM node_group_members WHERE group_id = ? ORDER BY node;
@@ -0,0 +1,10 @@
+-- name: ListProjectMaxRuntimes :many
+SELECT * FROM project_max_runtimes ORDER BY project;
+
+-- name: UpsertProjectMaxRuntime :exec
+INSERT INTO project_max_runtimes (project, max_runtime_seconds, created_by)
+VALUES (?, ?, ?)
+ON CONFLICT(project) DO UPDATE SET max_runtime_seconds = EXCLUDED.max_runtime_seconds, created_by = EXCLUDED.created_by;
+
+-- name: DeleteProjectMaxRuntime :execrows
+DELETE FROM project_max_runtimes WHERE project = ?;
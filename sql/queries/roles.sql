@@ -0,0 +1,8 @@
+-- name: ListRoles :many
+SELECT * FROM roles ORDER BY id;
+
+-- name: GetRoleWithName :one
+SELECT * FROM roles WHERE name = ? LIMIT 1;
+
+-- name: ListPermissionsForRole :many
+SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission;
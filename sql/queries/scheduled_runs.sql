@@ -0,0 +1,46 @@
+-- name: InsertScheduledRun :exec
+INSERT INTO scheduled_runs (id, project, spider, settings_arguments, run_at, timezone, created_by)
+VALUES (?, ?, ?, ?, ?, ?, ?);
+
+-- name: UpdateScheduledRun :execrows
+-- Only a run which hasn't fired yet can be changed
+UPDATE scheduled_runs
+SET settings_arguments = ?, run_at = ?, timezone = ?, modified_by = ?
+WHERE id = ? AND status = 'pending';
+
+-- name: DeleteScheduledRun :execrows
+DELETE FROM scheduled_runs WHERE id = ?;
+
+-- name: GetScheduledRun :one
+SELECT * FROM scheduled_runs WHERE id = ? LIMIT 1;
+
+-- name: ListScheduledRuns :many
+-- Runs which are yet to fire come first, soonest first, followed by the ones which already fired, most recent first
+SELECT sr.*, u.username AS created_by_username
+FROM scheduled_runs sr
+         LEFT JOIN users u ON sr.created_by = u.id
+ORDER BY sr.status != 'pending',
+         CASE WHEN sr.status = 'pending' THEN julianday(sr.run_at) ELSE -julianday(sr.fired_at) END;
+
+-- name: ListDueScheduledRuns :many
+SELECT * FROM scheduled_runs WHERE status = 'pending' AND julianday(run_at) <= julianday(sqlc.arg('now')) ORDER BY run_at;
+
+-- name: ClaimScheduledRun :execrows
+-- Claims a due run for firing, no rows are affected when it was cancelled, changed or claimed in the meantime
+UPDATE scheduled_runs SET status = 'firing', fired_at = sqlc.arg('fired_at')
+WHERE id = sqlc.arg('id') AND status = 'pending' AND julianday(run_at) = julianday(sqlc.arg('run_at'));
+
+-- name: FinishScheduledRun :exec
+UPDATE scheduled_runs SET status = ?, error = ? WHERE id = ?;
+
+-- name: InsertScheduledRunNode :exec
+INSERT OR IGNORE INTO scheduled_run_nodes (run_id, node) VALUES (?, ?);
+
+-- name: DeleteScheduledRunNodes :exec
+DELETE FROM scheduled_run_nodes WHERE run_id = ?;
+
+-- name: ListScheduledRunNodes :many
+SELECT node FROM scheduled_run_nodes WHERE run_id = ? ORDER BY node;
+
+-- name: ListAllScheduledRunNodes :many
+SELECT run_id, node FROM scheduled_run_nodes ORDER BY run_id, node;
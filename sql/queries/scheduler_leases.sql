@@ -0,0 +1,16 @@
+-- name: AcquireSchedulerLease :execrows
+-- Takes the lease when nobody holds it, renews it when the holder already has it or takes it over once it expired. No
+-- rows are affected while another holder has it.
+INSERT INTO scheduler_leases (name, holder, acquired_at, renewed_at, expires_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+    holder = EXCLUDED.holder,
+    acquired_at = CASE WHEN scheduler_leases.holder = EXCLUDED.holder THEN scheduler_leases.acquired_at ELSE EXCLUDED.acquired_at END,
+    renewed_at = EXCLUDED.renewed_at,
+    expires_at = EXCLUDED.expires_at
+WHERE scheduler_leases.holder = EXCLUDED.holder OR julianday(scheduler_leases.expires_at) <= julianday(EXCLUDED.renewed_at);
+
+-- name: GetSchedulerLease :one
+SELECT * FROM scheduler_leases WHERE name = ? LIMIT 1;
+
+-- name: ReleaseSchedulerLease :exec
+DELETE FROM scheduler_leases WHERE name = ? AND holder = ?;
@@ -1,7 +1,7 @@
 -- name: NewScrapydNode :one
 INSERT INTO scrapyd_nodes (
-    nodeName, URL, username, password
-) VALUES (?, ?, ?, ?) RETURNING *;
+    nodeName, URL, username, password, capacity
+) VALUES (?, ?, ?, ?, ?) RETURNING *;
 
 -- name: ListScrapydNodes :many
 SELECT * FROM scrapyd_nodes;
@@ -14,4 +14,4 @@ SELECT * FROM scrapyd_nodes WHERE nodeName = ? LIMIT 1;
 
 -- name: UpdateNodeWhereName :exec
 UPDATE scrapyd_nodes SET nodeName = sqlc.arg('new_node_name'), URL = sqlc.arg('new_URL'), username = sqlc.arg('new_username'),
-                         password = sqlc.arg('new_password') WHERE nodeName = sqlc.arg('old_node_name');
+                         password = sqlc.arg('new_password'), capacity = sqlc.arg('new_capacity') WHERE nodeName = sqlc.arg('old_node_name');
@@ -0,0 +1,49 @@
+-- name: ListTaskDependencies :many
+SELECT d.id, d.task_id, d.upstream_task_id, d.min_items, d.upstream_job_id, d.satisfied, d.created_at,
+       t.name AS task_name, t.project AS task_project, CAST(COALESCE((SELECT GROUP_CONCAT(COALESCE(tt.node, g.name), ', ') FROM task_targets tt
+           LEFT JOIN node_groups g ON tt.group_id = g.id WHERE tt.task_id = t.id), '') AS TEXT) AS task_targets,
+       u.name AS upstream_name, u.project AS upstream_project, CAST(COALESCE((SELECT GROUP_CONCAT(COALESCE(tt.node, g.name), ', ') FROM task_targets tt
+           LEFT JOIN node_groups g ON tt.group_id = g.id WHERE tt.task_id = u.id), '') AS TEXT) AS upstream_targets,
+       j.job AS upstream_job, j.status AS upstream_job_status, j.items AS upstream_job_items
+FROM task_dependencies d
+         JOIN tasks t ON d.task_id = t.id
+         JOIN tasks u ON d.upstream_task_id = u.id
+         LEFT JOIN jobs j ON d.upstream_job_id = j.id
+ORDER BY d.id;
+
+-- name: ListDependenciesForTask :many
+SELECT * FROM task_dependencies WHERE task_id = ? ORDER BY id;
+
+-- name: ListDependenciesWaitingOnJob :many
+SELECT d.* FROM task_dependencies d
+    JOIN jobs j ON j.task_id = d.upstream_task_id
+WHERE j.id = ? AND j.id > d.after_job_id
+ORDER BY d.id;
+
+-- name: InsertTaskDependency :one
+INSERT INTO task_dependencies (task_id, upstream_task_id, min_items, after_job_id, created_by)
+VALUES (sqlc.arg('task_id'), sqlc.arg('upstream_task_id'), sqlc.arg('min_items'),
+        (SELECT COALESCE(MAX(id), 0) FROM jobs WHERE jobs.task_id = sqlc.arg('upstream_task_id')), sqlc.narg('created_by'))
+RETURNING *;
+
+-- name: DeleteTaskDependency :execrows
+DELETE FROM task_dependencies WHERE id = ?;
+
+-- name: GetTaskDependency :one
+SELECT * FROM task_dependencies WHERE id = ?;
+
+-- name: SetTaskDependencyUpstreamJob :exec
+UPDATE task_dependencies SET upstream_job_id = ?, satisfied = ? WHERE id = ?;
+
+-- name: ResetTaskDependencies :exec
+UPDATE task_dependencies
+SET after_job_id = COALESCE(upstream_job_id, after_job_id), upstream_job_id = NULL, satisfied = FALSE
+WHERE task_id = ?;
+
+-- name: ListDependencyRuns :many
+SELECT j.id, j.job, j.status, j.items, j.create_time, j.node, t.id AS task_id, t.name AS task_name, t.project AS task_project
+FROM jobs j
+         JOIN tasks t ON j.task_id = t.id
+WHERE j.triggered_by = 'dependencies' AND j.deleted = 0
+ORDER BY j.id DESC
+LIMIT ?;
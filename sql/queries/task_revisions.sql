@@ -0,0 +1,26 @@
+-- name: InsertTaskRevision :one
+-- Revisions are only ever appended, there's no query to change one
+INSERT INTO task_revisions (task_id, revision, name, project, spider, settings_arguments, cron_string, timezone,
+                            retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy,
+                            fan_out, misfire_policy, misfire_max_runs, max_runtime_seconds, nodes, node_groups,
+                            blackout_calendars, reverted_from, created_by, created_at)
+VALUES (sqlc.arg('task_id'),
+        (SELECT COALESCE(MAX(r.revision), 0) + 1 FROM task_revisions r WHERE r.task_id = sqlc.arg('task_id')),
+        sqlc.arg('name'), sqlc.arg('project'), sqlc.arg('spider'), sqlc.arg('settings_arguments'), sqlc.arg('cron_string'),
+        sqlc.arg('timezone'), sqlc.arg('retry_max_attempts'), sqlc.arg('retry_backoff_seconds'),
+        sqlc.arg('retry_max_backoff_seconds'), sqlc.arg('retry_on'), sqlc.arg('overlap_policy'), sqlc.arg('fan_out'),
+        sqlc.arg('misfire_policy'), sqlc.arg('misfire_max_runs'), sqlc.narg('max_runtime_seconds'), sqlc.arg('nodes'),
+        sqlc.arg('node_groups'), sqlc.arg('blackout_calendars'), sqlc.narg('reverted_from'), sqlc.arg('created_by'),
+        sqlc.arg('created_at'))
+RETURNING *;
+
+-- name: GetTaskRevision :one
+SELECT * FROM task_revisions WHERE task_id = ? AND revision = ? LIMIT 1;
+
+-- name: ListTaskRevisions :many
+-- Newest first
+SELECT sqlc.embed(r), u.username AS created_by_username
+FROM task_revisions r
+         LEFT JOIN users u ON r.created_by = u.id
+WHERE r.task_id = ?
+ORDER BY r.revision DESC;
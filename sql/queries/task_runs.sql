@@ -0,0 +1,19 @@
+-- name: InsertTaskRun :one
+INSERT INTO task_runs (task_id, trigger_source, scheduled_at, started_at, finished_at, node, job_id, outcome, error)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id;
+
+-- name: FinishTaskRun :exec
+UPDATE task_runs SET outcome = ?, error = ?, schedule_latency_ms = ?, finished_at = ? WHERE id = ?;
+
+-- name: ListTaskRuns :many
+SELECT * FROM task_runs WHERE task_id = ? ORDER BY id DESC LIMIT ? OFFSET ?;
+
+-- name: CountTaskRuns :one
+SELECT COUNT(*) FROM task_runs WHERE task_id = ?;
+
+-- name: PruneTaskRuns :exec
+-- Keeps the most recent runs of the task
+DELETE FROM task_runs
+WHERE task_runs.task_id = sqlc.arg('task_id')
+  AND task_runs.id <= (SELECT r.id FROM task_runs r WHERE r.task_id = sqlc.arg('task_id') ORDER BY r.id DESC LIMIT 1 OFFSET sqlc.arg('keep'));
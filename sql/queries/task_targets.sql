@@ -0,0 +1,31 @@
+-- name: InsertTaskTarget :exec
+INSERT OR IGNORE INTO task_targets (task_id, node, group_id) VALUES (sqlc.arg('task_id'), sqlc.narg('node'), sqlc.narg('group_id'));
+
+-- name: DeleteTaskTargets :exec
+DELETE FROM task_targets WHERE task_id = ?;
+
+-- name: ListTargetsForTask :many
+SELECT tt.node, tt.group_id, g.name AS group_name
+FROM task_targets tt
+         LEFT JOIN node_groups g ON tt.group_id = g.id
+WHERE tt.task_id = ?
+ORDER BY tt.id;
+
+-- name: ListTaskTargetNodes :many
+-- The nodes a task runs on, its node targets plus the members of its groups
+SELECT DISTINCT n.nodeName
+FROM task_targets tt
+         LEFT JOIN node_group_members m ON m.group_id = tt.group_id
+         JOIN scrapyd_nodes n ON n.nodeName = COALESCE(tt.node, m.node)
+WHERE tt.task_id = ?
+ORDER BY n.nodeName;
+
+-- name: ListResolvedTaskNodes :many
+SELECT DISTINCT tt.task_id, COALESCE(tt.node, m.node) AS node
+FROM task_targets tt
+         LEFT JOIN node_group_members m ON m.group_id = tt.group_id
+WHERE COALESCE(tt.node, m.node) IS NOT NULL
+ORDER BY tt.task_id, node;
+
+-- name: AdvanceTaskRoundRobin :one
+UPDATE tasks SET round_robin_next = round_robin_next + 1 WHERE id = ? RETURNING round_robin_next;
@@ -1,8 +1,10 @@
 -- name: InsertTask :one
 INSERT INTO tasks (
-   id, name, project, spider, jobid, settings_arguments, selected_nodes, cron_string, paused, created_by
+   id, name, project, spider, jobid, settings_arguments, cron_string, paused, created_by,
+   retry_max_attempts, retry_backoff_seconds, retry_max_backoff_seconds, retry_on, overlap_policy, fan_out,
+   misfire_policy, misfire_max_runs, timezone, max_runtime_seconds
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 ) RETURNING *;
 
 -- name: GetTasks :many
@@ -18,9 +20,16 @@ SELECT
     t.spider,
     t.jobid,
     t.settings_arguments,
-    t.selected_nodes,
+    CAST(COALESCE((SELECT GROUP_CONCAT(COALESCE(tt.node, g.name), ', ') FROM task_targets tt
+        LEFT JOIN node_groups g ON tt.group_id = g.id WHERE tt.task_id = t.id), '') AS TEXT) AS targets,
+    t.fan_out,
     t.cron_string,
+    t.timezone,
     t.paused,
+    t.retry_max_attempts,
+    t.overlap_policy,
+    t.misfire_policy,
+    t.misfire_max_runs,
     creator.username AS created_by_username,
     modifier.username AS modified_by_username,
     j.id AS job_id,
@@ -44,13 +53,15 @@ FROM tasks t
 ) j_max ON j_max.task_id = t.id
          LEFT JOIN jobs j ON j.task_id = j_max.task_id
     AND j.update_time = j_max.latest_update
-    AND j.node = t.selected_nodes
 GROUP BY t.id
 ORDER BY t.name DESC;
 
 -- name: UpdateTaskPaused :exec
 UPDATE tasks SET paused=? WHERE id = ?;
 
+-- name: UpdateTaskLastFiredAt :exec
+UPDATE tasks SET last_fired_at = ? WHERE id = ?;
+
 -- name: GetTaskWithUUID :one
 SELECT * FROM tasks WHERE id = ?;
 
@@ -65,10 +76,19 @@ SET
     spider = ?,
     jobid = ?,
     settings_arguments = ?,
-    selected_nodes = ?,
     cron_string = ?,
     paused = ?,
-    modified_by = ?
+    modified_by = ?,
+    retry_max_attempts = ?,
+    retry_backoff_seconds = ?,
+    retry_max_backoff_seconds = ?,
+    retry_on = ?,
+    overlap_policy = ?,
+    fan_out = ?,
+    misfire_policy = ?,
+    misfire_max_runs = ?,
+    timezone = ?,
+    max_runtime_seconds = ?
 WHERE id = ?;
 
 -- name: SearchTasksTable :many
@@ -81,9 +101,16 @@ SELECT
     t.spider,
     t.jobid,
     t.settings_arguments,
-    t.selected_nodes,
+    CAST(COALESCE((SELECT GROUP_CONCAT(COALESCE(tt.node, g.name), ', ') FROM task_targets tt
+        LEFT JOIN node_groups g ON tt.group_id = g.id WHERE tt.task_id = t.id), '') AS TEXT) AS targets,
+    t.fan_out,
     t.cron_string,
+    t.timezone,
     t.paused,
+    t.retry_max_attempts,
+    t.overlap_policy,
+    t.misfire_policy,
+    t.misfire_max_runs,
     creator.username AS created_by_username,
     modifier.username AS modified_by_username,
     j.id AS job_id,
@@ -107,7 +134,6 @@ FROM tasks t
 ) j_max ON j_max.task_id = t.id
          LEFT JOIN jobs j ON j.task_id = j_max.task_id
     AND j.update_time = j_max.latest_update
-    AND j.node = t.selected_nodes
 WHERE
     LOWER(t.name) LIKE '%' || LOWER(sqlc.arg(searchTerm)) || '%' OR
     LOWER(t.spider) LIKE '%' || LOWER(sqlc.arg(searchTerm)) || '%'
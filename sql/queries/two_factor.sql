@@ -0,0 +1,34 @@
+-- name: SetUserTOTPSecret :exec
+UPDATE users SET totp_secret = ?, totp_enabled = FALSE WHERE ID = ?;
+
+-- name: EnableUserTOTP :exec
+UPDATE users SET totp_enabled = TRUE WHERE ID = ?;
+
+-- name: ResetUserTOTP :exec
+UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_failed_attempts = 0, totp_locked_until = NULL WHERE ID = ?;
+
+-- name: UseTOTPStep :execrows
+-- Affects no rows when a code of the step or a later one was accepted already
+UPDATE users SET totp_last_step = sqlc.arg('step') WHERE ID = sqlc.arg('id') AND totp_last_step < sqlc.arg('step');
+
+-- name: RecordTwoFactorFailure :exec
+-- The max_failures-th incorrect code in a row locks the second factor until locked_until and starts the count over
+UPDATE users
+SET totp_failed_attempts = CASE WHEN totp_failed_attempts + 1 >= sqlc.arg('max_failures') THEN 0 ELSE totp_failed_attempts + 1 END,
+    totp_locked_until    = CASE WHEN totp_failed_attempts + 1 >= sqlc.arg('max_failures') THEN sqlc.arg('locked_until') ELSE totp_locked_until END
+WHERE ID = sqlc.arg('id');
+
+-- name: ResetTwoFactorFailures :exec
+UPDATE users SET totp_failed_attempts = 0, totp_locked_until = NULL WHERE ID = ?;
+
+-- name: InsertRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND code_hash = ? AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodesForUser :exec
+DELETE FROM recovery_codes WHERE user_id = ?;
@@ -1,5 +1,5 @@
 -- name: CreateNewUser :one
-INSERT INTO users (ID, username, hashed_password, has_admin_privileges) VALUES (?, ?, ?, ?) RETURNING *;
+INSERT INTO users (ID, username, hashed_password, role, totp_required) VALUES (?, ?, ?, ?, ?) RETURNING *;
 
 -- name: GetUserWithID :one
 /* @sqlc.returns *users */
@@ -18,4 +18,4 @@ SELECT * FROM users;
 DELETE FROM users WHERE ID = ?;
 
 -- name: UpdateUserWhereUUID :exec
-UPDATE users SET username=?, hashed_password=?, has_admin_privileges = ? WHERE ID =?;
+UPDATE users SET username=?, hashed_password=?, role = ?, totp_required = ? WHERE ID =?;
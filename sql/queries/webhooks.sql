@@ -0,0 +1,23 @@
+-- name: UpsertTaskWebhook :one
+INSERT INTO task_webhooks (task_id, secret, created_at, created_by) VALUES (?, ?, ?, ?)
+ON CONFLICT(task_id) DO UPDATE SET
+    secret = EXCLUDED.secret,
+    created_at = EXCLUDED.created_at,
+    created_by = EXCLUDED.created_by,
+    last_fired_at = NULL
+RETURNING *;
+
+-- name: GetWebhookForTask :one
+SELECT * FROM task_webhooks WHERE task_id = ? LIMIT 1;
+
+-- name: DeleteWebhookForTask :execrows
+DELETE FROM task_webhooks WHERE task_id = ?;
+
+-- name: UpdateWebhookLastFired :exec
+UPDATE task_webhooks SET last_fired_at = ? WHERE id = ?;
+
+-- name: InsertWebhookNonce :execrows
+INSERT INTO webhook_nonces (webhook_id, nonce, seen_at) VALUES (?, ?, ?) ON CONFLICT(webhook_id, nonce) DO NOTHING;
+
+-- name: DeleteWebhookNoncesSeenBefore :exec
+DELETE FROM webhook_nonces WHERE seen_at < ?;